@@ -0,0 +1,165 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+	"gorm.io/gorm"
+)
+
+var _ llmtracer.BatchAggregateStorage = (*GormAdapter)(nil)
+
+// AggregateMulti computes every aggregation in a single statement. On Postgres, specs
+// sharing one filter and grouping only by request columns are computed with GROUPING
+// SETS, scanning the requests once; otherwise the aggregations are combined with UNION ALL.
+func (a *GormAdapter) AggregateMulti(ctx context.Context, specs []llmtracer.AggregateSpec) ([][]*llmtracer.AggregateResult, error) {
+	results := make([][]*llmtracer.AggregateResult, len(specs))
+	if len(specs) == 0 {
+		return results, nil
+	}
+	for _, spec := range specs {
+		if err := validateAggregateOrder(spec.Filter); err != nil {
+			return nil, err
+		}
+	}
+	if a.db.Dialector.Name() == "postgres" && groupingSetsApply(specs) {
+		if err := a.aggregateGroupingSets(ctx, specs, results); err != nil {
+			return nil, err
+		}
+		return sortAggregateSpecs(specs, results), nil
+	}
+
+	dimensionColumns := 0
+	for _, spec := range specs {
+		dimensionColumns = max(dimensionColumns, dimensionGroupCount(spec.GroupBy))
+	}
+
+	parts := make([]string, len(specs))
+	args := make([]interface{}, len(specs))
+	dimensionKeys := make([][]string, len(specs))
+	for i, spec := range specs {
+		// Each aggregation is built in its own session so their clauses don't mix
+		args[i], dimensionKeys[i] = a.aggregateQuery(a.reader.Session(&gorm.Session{NewDB: true}), spec.GroupBy, spec.Filter, dimensionColumns)
+		parts[i] = fmt.Sprintf("SELECT %d AS spec_index, s%d.* FROM (?) s%d", i, i, i)
+	}
+
+	// The model types the request columns when scanning into maps
+	var rows []map[string]interface{}
+	if err := a.readConn(ctx).WithContext(ctx).Model(&llmtracer.Request{}).Raw(strings.Join(parts, " UNION ALL "), args...).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		i := int(int64Value(row["spec_index"]))
+		if i < 0 || i >= len(specs) {
+			return nil, fmt.Errorf("unexpected aggregation index %d", i)
+		}
+		results[i] = append(results[i], aggregateResult(row, dimensionKeys[i]))
+	}
+	// UNION ALL does not keep the order of its parts
+	return sortAggregateSpecs(specs, results), nil
+}
+
+// sortAggregateSpecs applies the aggregate order and limit of each spec's filter to its
+// results
+func sortAggregateSpecs(specs []llmtracer.AggregateSpec, results [][]*llmtracer.AggregateResult) [][]*llmtracer.AggregateResult {
+	for i, spec := range specs {
+		results[i] = llmtracer.SortAggregates(results[i], spec.Filter)
+	}
+	return results
+}
+
+// aggregateGroupingSets computes specs sharing one filter with GROUPING SETS. The
+// GROUPING() bitmask of each row identifies its grouping set, and so the specs it
+// belongs to.
+func (a *GormAdapter) aggregateGroupingSets(ctx context.Context, specs []llmtracer.AggregateSpec, results [][]*llmtracer.AggregateResult) error {
+	// Columns grouped by any spec, in select order
+	var used []string
+	selectFields := append([]string{}, aggregateSelect...)
+	for _, column := range groupColumns {
+		if !slices.ContainsFunc(specs, func(spec llmtracer.AggregateSpec) bool { return slices.Contains(spec.GroupBy, column.name) }) {
+			selectFields = append(selectFields, "NULL as "+column.name)
+			continue
+		}
+		selectFields = append(selectFields, column.expr+" as "+column.name)
+		used = append(used, column.name)
+	}
+
+	sets := make([]string, len(specs))
+	specsByMask := make(map[int64][]int)
+	for i, spec := range specs {
+		var setFields []string
+		var mask int64
+		for j, name := range used {
+			if slices.Contains(spec.GroupBy, name) {
+				setFields = append(setFields, groupColumnExpr(name))
+			} else {
+				// GROUPING() sets the bit of each argument not grouped, the first
+				// argument being the most significant
+				mask |= 1 << (len(used) - 1 - j)
+			}
+		}
+		sets[i] = "(" + strings.Join(setFields, ", ") + ")"
+		specsByMask[mask] = append(specsByMask[mask], i)
+	}
+
+	query := a.applyFilter(a.readConn(ctx).WithContext(ctx).Model(&llmtracer.Request{}), specs[0].Filter)
+	if len(used) > 0 {
+		exprs := make([]string, len(used))
+		for i, name := range used {
+			exprs[i] = groupColumnExpr(name)
+		}
+		selectFields = append(selectFields, "GROUPING("+strings.Join(exprs, ", ")+") as grouping_id")
+		query = query.Group("GROUPING SETS (" + strings.Join(sets, ", ") + ")")
+	} else {
+		selectFields = append(selectFields, "0 as grouping_id")
+	}
+	query = query.Select(strings.Join(selectFields, ", "))
+
+	var rows []map[string]interface{}
+	if err := query.Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		// Specs with the same grouping get their own copy of the results
+		for _, i := range specsByMask[int64Value(row["grouping_id"])] {
+			results[i] = append(results[i], aggregateResult(row, nil))
+		}
+	}
+	return nil
+}
+
+// groupingSetsApply reports whether specs can be computed with GROUPING SETS: they share
+// one filter and group only by request columns
+func groupingSetsApply(specs []llmtracer.AggregateSpec) bool {
+	for _, spec := range specs {
+		if dimensionGroupCount(spec.GroupBy) > 0 || !reflect.DeepEqual(spec.Filter, specs[0].Filter) {
+			return false
+		}
+	}
+	return true
+}
+
+// dimensionGroupCount returns the number of dimensions groupBy groups by
+func dimensionGroupCount(groupBy []string) int {
+	count := 0
+	for _, field := range groupBy {
+		if _, ok := llmtracer.DimensionGroupKey(field); ok {
+			count++
+		}
+	}
+	return count
+}
+
+// groupColumnExpr returns the expression of a groupable request field
+func groupColumnExpr(name string) string {
+	for _, column := range groupColumns {
+		if column.name == name {
+			return column.expr
+		}
+	}
+	return name
+}
@@ -0,0 +1,25 @@
+package adapters
+
+import (
+	"fmt"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+	"gorm.io/gorm"
+)
+
+// GormStorageFactory returns a storage factory that opens a GORM database with the given
+// driver, for use with llmtracer.RegisterStorage
+func GormStorageFactory(open func(dsn string) gorm.Dialector, opts ...gorm.Option) llmtracer.StorageFactory {
+	return func(dsn string) (llmtracer.StorageAdapter, error) {
+		db, err := gorm.Open(open(dsn), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+
+		adapter, err := NewGormAdapter(db)
+		if err != nil {
+			return nil, err
+		}
+		return adapter, nil
+	}
+}
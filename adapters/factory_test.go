@@ -0,0 +1,34 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func TestGormStorageFactory(t *testing.T) {
+	llmtracer.RegisterStorage("sqlite", GormStorageFactory(sqlite.Open))
+
+	storage, err := llmtracer.OpenStorage("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer storage.Close()
+
+	request := &llmtracer.Request{
+		ID:          "factory-test",
+		Provider:    llmtracer.ProviderOpenAI,
+		Model:       "gpt-4",
+		RequestedAt: time.Now(),
+	}
+	if err := storage.Save(context.Background(), request); err != nil {
+		t.Fatalf("Failed to save request: %v", err)
+	}
+	if _, err := storage.Get(context.Background(), "factory-test"); err != nil {
+		t.Errorf("Failed to get request: %v", err)
+	}
+}
@@ -3,54 +3,108 @@ package adapters
 import (
 	"context"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
 	llmtracer "github.com/propel-gtm/llm-request-tracer"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type GormAdapter struct {
 	db *gorm.DB
+	// reader serves Get, Query, Aggregate and QueryFeedback; it is db unless a read
+	// replica is configured. See readConn.
+	reader         *gorm.DB
+	partitioning   *partitioning
+	jsonDimensions bool
+	fullTextSearch bool
 }
 
-func NewGormAdapter(db *gorm.DB) (*GormAdapter, error) {
-	// Migrate both Request and DimensionTag tables
-	if err := db.AutoMigrate(&llmtracer.DimensionTag{}, &llmtracer.Request{}); err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+// GormOption configures a GormAdapter
+type GormOption func(*GormAdapter)
+
+// WithReadReplica sends reads to a separate connection, e.g. a Postgres read replica, so
+// dashboard queries and aggregations don't contend with the write path. Reads may lag
+// behind writes by the replication delay, except for reads made with a context from
+// llmtracer.WithPrimaryRead, such as those of compaction, which go to the primary. The
+// replica is not migrated and is closed by Close.
+//
+// To balance reads across several replicas, register gorm's dbresolver plugin on the
+// primary connection instead; the adapter needs no configuration for it.
+func WithReadReplica(reader *gorm.DB) GormOption {
+	return func(a *GormAdapter) {
+		if reader != nil {
+			a.reader = reader
+		}
 	}
+}
 
-	return &GormAdapter{
-		db: db,
-	}, nil
+// readConn returns the connection serving reads made with ctx: the primary when the
+// context asks for it with llmtracer.WithPrimaryRead, the read replica otherwise
+func (a *GormAdapter) readConn(ctx context.Context) *gorm.DB {
+	if llmtracer.IsPrimaryRead(ctx) {
+		return a.db
+	}
+	return a.reader
+}
+
+var (
+	_ llmtracer.LifecycleStorage   = (*GormAdapter)(nil)
+	_ llmtracer.SoftDeleteStorage  = (*GormAdapter)(nil)
+	_ llmtracer.FeedbackStorage    = (*GormAdapter)(nil)
+	_ llmtracer.IncidentStorage    = (*GormAdapter)(nil)
+	_ llmtracer.SavedFilterStorage = (*GormAdapter)(nil)
+	_ llmtracer.RollupStorage      = (*GormAdapter)(nil)
+)
+
+func NewGormAdapter(db *gorm.DB, opts ...GormOption) (*GormAdapter, error) {
+	adapter := &GormAdapter{
+		db:     db,
+		reader: db,
+	}
+	for _, opt := range opts {
+		opt(adapter)
+	}
+
+	if adapter.partitioning != nil {
+		if err := adapter.migratePartitioned(); err != nil {
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	} else if err := adapter.migrationSession(nil).AutoMigrate(adapter.migrationModels()...); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := adapter.indexJSONDimensions(); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := adapter.indexFullTextSearch(); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := adapter.backfillLatencyMs(); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return adapter, nil
 }
 
 func (a *GormAdapter) Save(ctx context.Context, request *llmtracer.Request) error {
-	return a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// First, find or create dimension tags
-		var processedDimensions []llmtracer.DimensionTag
-		for _, dim := range request.Dimensions {
-			var existingTag llmtracer.DimensionTag
-			// Try to find existing tag with same key-value pair
-			err := tx.Where("key = ? AND value = ?", dim.Key, dim.Value).First(&existingTag).Error
-			if err == gorm.ErrRecordNotFound {
-				// Create new tag
-				newTag := llmtracer.DimensionTag{
-					Key:   dim.Key,
-					Value: dim.Value,
-				}
-				if err := tx.Create(&newTag).Error; err != nil {
-					return err
-				}
-				processedDimensions = append(processedDimensions, newTag)
-			} else if err != nil {
-				return err
-			} else {
-				processedDimensions = append(processedDimensions, existingTag)
-			}
+	storeDurations(request)
+	if a.jsonDimensions {
+		row := newJSONDimensionsRequest(request)
+		if err := a.db.WithContext(ctx).Omit(clause.Associations).Create(row).Error; err != nil {
+			return err
 		}
+		request.CreatedAt, request.UpdatedAt = row.CreatedAt, row.UpdatedAt
+		return nil
+	}
 
+	return a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Replace dimensions with processed ones (with IDs)
+		processedDimensions, err := dimensionTags(tx, request.Dimensions)
+		if err != nil {
+			return err
+		}
 		request.Dimensions = processedDimensions
 
 		// Save the request with associations
@@ -58,27 +112,356 @@ func (a *GormAdapter) Save(ctx context.Context, request *llmtracer.Request) erro
 	})
 }
 
+// Update replaces a stored request, such as the pending row written by lifecycle tracking,
+// along with its dimensions
+func (a *GormAdapter) Update(ctx context.Context, request *llmtracer.Request) error {
+	storeDurations(request)
+	if a.jsonDimensions {
+		row := newJSONDimensionsRequest(request)
+		if err := a.db.WithContext(ctx).Omit(clause.Associations).Save(row).Error; err != nil {
+			return err
+		}
+		request.UpdatedAt = row.UpdatedAt
+		return nil
+	}
+
+	return a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		processedDimensions, err := dimensionTags(tx, request.Dimensions)
+		if err != nil {
+			return err
+		}
+		request.Dimensions = processedDimensions
+
+		if err := tx.Omit(clause.Associations).Save(request).Error; err != nil {
+			return err
+		}
+		return tx.Model(request).Association("Dimensions").Replace(processedDimensions)
+	})
+}
+
+// dimensionTags finds or creates the stored tag of each dimension
+func dimensionTags(tx *gorm.DB, dimensions []llmtracer.DimensionTag) ([]llmtracer.DimensionTag, error) {
+	var processedDimensions []llmtracer.DimensionTag
+	for _, dim := range dimensions {
+		var existingTag llmtracer.DimensionTag
+		// Try to find existing tag with same key-value pair
+		err := tx.Where("key = ? AND value = ?", dim.Key, dim.Value).First(&existingTag).Error
+		if err == gorm.ErrRecordNotFound {
+			// Create new tag
+			newTag := llmtracer.DimensionTag{
+				Key:   dim.Key,
+				Value: dim.Value,
+			}
+			if err := tx.Create(&newTag).Error; err != nil {
+				return nil, err
+			}
+			processedDimensions = append(processedDimensions, newTag)
+		} else if err != nil {
+			return nil, err
+		} else {
+			processedDimensions = append(processedDimensions, existingTag)
+		}
+	}
+	return processedDimensions, nil
+}
+
 func (a *GormAdapter) Get(ctx context.Context, id string) (*llmtracer.Request, error) {
-	var request llmtracer.Request
-	if err := a.db.WithContext(ctx).Preload("Dimensions").First(&request, "id = ?", id).Error; err != nil {
+	requests, err := a.find(a.readConn(ctx).WithContext(ctx).Where(notDeleted).Where("id = ?", id).Limit(1))
+	if err != nil {
 		return nil, err
 	}
-	return &request, nil
+	if len(requests) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return requests[0], nil
 }
 
 func (a *GormAdapter) GetByTraceID(ctx context.Context, traceID string) ([]*llmtracer.Request, error) {
-	var requests []*llmtracer.Request
-	if err := a.db.WithContext(ctx).Preload("Dimensions").Where("trace_id = ?", traceID).Find(&requests).Error; err != nil {
+	return a.find(a.readConn(ctx).WithContext(ctx).Where(notDeleted).Where("trace_id = ?", llmtracer.NormalizeTraceID(traceID)))
+}
+
+// find loads the requests selected by query along with their dimensions
+func (a *GormAdapter) find(query *gorm.DB) ([]*llmtracer.Request, error) {
+	if !a.jsonDimensions {
+		var requests []*llmtracer.Request
+		if err := query.Preload("Dimensions").Find(&requests).Error; err != nil {
+			return nil, err
+		}
+		for _, request := range requests {
+			restoreDurations(request)
+		}
+		return requests, nil
+	}
+
+	var rows []*jsonDimensionsRequest
+	if err := query.Find(&rows).Error; err != nil {
 		return nil, err
 	}
+	requests := make([]*llmtracer.Request, 0, len(rows))
+	for _, row := range rows {
+		request := row.request()
+		restoreDurations(request)
+		requests = append(requests, request)
+	}
 	return requests, nil
 }
 
+// storeDurations sets the stored milliseconds of a request's durations, unless set already
+func storeDurations(request *llmtracer.Request) {
+	if request.LatencyMs == 0 {
+		request.LatencyMs = request.Latency.Milliseconds()
+	}
+	if request.RetryAfterMs == 0 {
+		request.RetryAfterMs = request.RetryAfter.Milliseconds()
+	}
+	if request.QueueWaitMs == 0 {
+		request.QueueWaitMs = request.QueueWait.Milliseconds()
+	}
+}
+
+// restoreDurations sets the durations of a loaded request from their stored milliseconds
+func restoreDurations(request *llmtracer.Request) {
+	request.Latency = time.Duration(request.LatencyMs) * time.Millisecond
+	request.RetryAfter = time.Duration(request.RetryAfterMs) * time.Millisecond
+	request.QueueWait = time.Duration(request.QueueWaitMs) * time.Millisecond
+}
+
+// backfillLatencyMs fills latency_ms from the nanosecond latency column of databases
+// created before latencies were stored in milliseconds
+func (a *GormAdapter) backfillLatencyMs() error {
+	if !a.db.Migrator().HasColumn("requests", "latency") {
+		return nil
+	}
+	// MySQL's / returns a decimal, DIV truncates like integer division elsewhere
+	divide := "latency / 1000000"
+	if a.db.Dialector.Name() == "mysql" {
+		divide = "latency DIV 1000000"
+	}
+	return a.db.Exec("UPDATE requests SET latency_ms = " + divide + " WHERE latency_ms = 0 AND latency > 0").Error
+}
+
 func (a *GormAdapter) Query(ctx context.Context, filter *llmtracer.RequestFilter) ([]*llmtracer.Request, error) {
-	query := a.db.WithContext(ctx)
+	if filter == nil {
+		filter = &llmtracer.RequestFilter{}
+	}
+	query := a.applyFilter(a.readConn(ctx).WithContext(ctx), filter)
+
+	orderBy := "created_at"
+	if filter.OrderBy != "" {
+		orderBy = filter.OrderBy
+	}
+	// Qualify plain columns so ordering stays unambiguous next to dimension subqueries
+	if !strings.Contains(orderBy, ".") {
+		orderBy = "requests." + orderBy
+	}
+
+	if filter.OrderDesc {
+		orderBy += " DESC"
+	} else {
+		orderBy += " ASC"
+	}
+	query = query.Order(orderBy)
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	return a.find(query)
+}
+
+func (a *GormAdapter) Aggregate(ctx context.Context, groupBy []string, filter *llmtracer.RequestFilter) ([]*llmtracer.AggregateResult, error) {
+	if err := validateAggregateOrder(filter); err != nil {
+		return nil, err
+	}
+	query, dimensionKeys := a.aggregateQuery(a.readConn(ctx).WithContext(ctx), groupBy, filter, 0)
+
+	// Scan into maps since the grouped dimension columns vary per call
+	var rows []map[string]interface{}
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]*llmtracer.AggregateResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, aggregateResult(row, dimensionKeys))
+	}
+	return results, nil
+}
+
+// aggregateSelect lists the aggregate columns selected by every aggregation
+var aggregateSelect = []string{
+	"COUNT(*) as total_requests",
+	"SUM(input_tokens + output_tokens) as total_tokens",
+	"SUM(input_tokens) as total_input_tokens",
+	"SUM(output_tokens) as total_output_tokens",
+	"SUM(cost) as total_cost",
+	// Averaged in Go from an integer sum, since AVG returns a different type per database
+	"SUM(latency_ms) as total_latency_ms",
+	"SUM(CASE WHEN error IS NOT NULL AND error != '' THEN 1 ELSE 0 END) as error_count",
+	"SUM(message_count) as total_messages",
+	"SUM(request_bytes) as total_request_bytes",
+	"SUM(response_bytes) as total_response_bytes",
+}
+
+// groupColumns maps the groupable request fields to their expressions, in the order they
+// are selected
+var groupColumns = []struct {
+	name string
+	expr string
+	// null is selected when the field is not grouped; it is typed like expr so the
+	// column's type matches across a UNION ALL
+	null string
+}{
+	{"provider", "provider", "NULL"},
+	{"model", "model", "NULL"},
+	{"request_type", "request_type", "NULL"},
+	{"prompt_version", "prompt_version", "NULL"},
+	{"status_code", "status_code", "NULL + 0"},
+	{"status_class", statusClassExpr, "NULL"},
+	{"error_type", "error_type", "NULL"},
+}
+
+// aggregateQuery builds the aggregation of the requests matching filter grouped by
+// groupBy. Every groupable field is selected, as NULL when not grouped, followed by at
+// least dimensionColumns dimension columns, so aggregations with the same
+// dimensionColumns can be combined with UNION ALL. It returns the dimension keys of the
+// dim_N columns.
+func (a *GormAdapter) aggregateQuery(db *gorm.DB, groupBy []string, filter *llmtracer.RequestFilter, dimensionColumns int) (*gorm.DB, []string) {
+	query := a.applyFilter(db.Model(&llmtracer.Request{}), filter)
+
+	grouped := make(map[string]bool)
+	var selectArgs []interface{}
+	var dimensionFields []string
+	var groupFields []string
+	var dimensionKeys []string
+	for _, field := range groupBy {
+		if key, ok := llmtracer.DimensionGroupKey(field); ok && a.jsonDimensions {
+			// Group by the column alias so the key is bound only once
+			column := fmt.Sprintf("dim_%d", len(dimensionKeys))
+			dimensionFields = append(dimensionFields, a.jsonDimensionExpr()+" as "+column)
+			selectArgs = append(selectArgs, a.jsonDimensionPath(key))
+			groupFields = append(groupFields, column)
+			dimensionKeys = append(dimensionKeys, key)
+			continue
+		} else if ok {
+			// Join each grouped dimension key through a subquery so requests without
+			// the key still count (with an empty value) and rows are not multiplied
+			alias := fmt.Sprintf("gd%d", len(dimensionKeys))
+			query = query.Joins(fmt.Sprintf(
+				"LEFT JOIN (SELECT rd.request_id, dt.value FROM request_dimensions rd JOIN dimension_tags dt ON dt.id = rd.dimension_tag_id WHERE dt.key = ?) %s ON %s.request_id = requests.id",
+				alias, alias), key)
+			column := fmt.Sprintf("dim_%d", len(dimensionKeys))
+			dimensionFields = append(dimensionFields, fmt.Sprintf("%s.value as %s", alias, column))
+			groupFields = append(groupFields, alias+".value")
+			dimensionKeys = append(dimensionKeys, key)
+			continue
+		}
+		grouped[field] = true
+	}
+
+	selectFields := append([]string{}, aggregateSelect...)
+	var columnFields []string
+	for _, column := range groupColumns {
+		if !grouped[column.name] {
+			selectFields = append(selectFields, column.null+" as "+column.name)
+			continue
+		}
+		selectFields = append(selectFields, column.expr+" as "+column.name)
+		columnFields = append(columnFields, column.expr)
+	}
+	selectFields = append(selectFields, dimensionFields...)
+	for i := len(dimensionKeys); i < dimensionColumns; i++ {
+		selectFields = append(selectFields, fmt.Sprintf("NULL as dim_%d", i))
+	}
+	groupFields = append(columnFields, groupFields...)
+
+	query = query.Select(strings.Join(selectFields, ", "), selectArgs...)
+	if len(groupFields) > 0 {
+		query = query.Group(strings.Join(groupFields, ", "))
+	}
+	if filter != nil && filter.AggregateOrderBy != "" {
+		// Validated orders are the aliases of aggregateSelect columns
+		direction := " DESC"
+		if filter.AggregateAscending {
+			direction = " ASC"
+		}
+		query = query.Order(string(filter.AggregateOrderBy) + direction)
+	}
+	if filter != nil && filter.AggregateLimit > 0 {
+		query = query.Limit(filter.AggregateLimit)
+	}
+	return query, dimensionKeys
+}
+
+// validateAggregateOrder rejects orders that are not aggregate columns
+func validateAggregateOrder(filter *llmtracer.RequestFilter) error {
+	if filter != nil && !filter.AggregateOrderBy.Valid() {
+		return fmt.Errorf("unsupported aggregate order %q", filter.AggregateOrderBy)
+	}
+	return nil
+}
+
+// aggregateResult converts a row selected by aggregateQuery
+func aggregateResult(row map[string]interface{}, dimensionKeys []string) *llmtracer.AggregateResult {
+	result := &llmtracer.AggregateResult{
+		Provider:           llmtracer.Provider(stringValue(row["provider"])),
+		Model:              stringValue(row["model"]),
+		RequestType:        llmtracer.RequestType(stringValue(row["request_type"])),
+		PromptVersion:      stringValue(row["prompt_version"]),
+		StatusCode:         int(int64Value(row["status_code"])),
+		StatusClass:        stringValue(row["status_class"]),
+		ErrorType:          llmtracer.ErrorType(stringValue(row["error_type"])),
+		TotalRequests:      int64Value(row["total_requests"]),
+		TotalTokens:        int64Value(row["total_tokens"]),
+		TotalInputTokens:   int64Value(row["total_input_tokens"]),
+		TotalOutputTokens:  int64Value(row["total_output_tokens"]),
+		TotalCost:          float64Value(row["total_cost"]),
+		ErrorCount:         int64Value(row["error_count"]),
+		TotalMessages:      int64Value(row["total_messages"]),
+		TotalRequestBytes:  int64Value(row["total_request_bytes"]),
+		TotalResponseBytes: int64Value(row["total_response_bytes"]),
+		Dimensions:         []llmtracer.DimensionTag{},
+	}
+	if result.TotalRequests > 0 {
+		count := float64(result.TotalRequests)
+		result.AvgLatency = time.Duration(float64(int64Value(row["total_latency_ms"])) / count * float64(time.Millisecond))
+		result.AvgMessageCount = float64(result.TotalMessages) / count
+		result.AvgRequestBytes = float64(result.TotalRequestBytes) / count
+		result.AvgResponseBytes = float64(result.TotalResponseBytes) / count
+		result.SuccessRate = float64(result.TotalRequests-result.ErrorCount) / count
+	}
+	result.ComputeEfficiency()
+	for i, key := range dimensionKeys {
+		result.Dimensions = append(result.Dimensions, llmtracer.DimensionTag{
+			Key:   key,
+			Value: stringValue(row[fmt.Sprintf("dim_%d", i)]),
+		})
+	}
+	return result
+}
+
+// applyFilter adds the conditions of filter to query, so Query and Aggregate select the
+// same requests. Limit, Offset and ordering are left to the caller.
+func (a *GormAdapter) applyFilter(query *gorm.DB, filter *llmtracer.RequestFilter) *gorm.DB {
+	if filter == nil {
+		return query.Where(notDeleted).Where("requests.pending = ?", false)
+	}
+
+	if !filter.IncludeDeleted {
+		query = query.Where(notDeleted)
+	}
+	query = query.Where("requests.pending = ?", filter.InFlight)
 
 	if filter.TraceID != "" {
-		query = query.Where("trace_id = ?", filter.TraceID)
+		query = query.Where("trace_id = ?", llmtracer.NormalizeTraceID(filter.TraceID))
+	}
+
+	if filter.ProviderRequestID != "" {
+		query = query.Where("provider_request_id = ?", filter.ProviderRequestID)
 	}
 
 	if filter.Provider != "" {
@@ -89,10 +472,22 @@ func (a *GormAdapter) Query(ctx context.Context, filter *llmtracer.RequestFilter
 		query = query.Where("model = ?", filter.Model)
 	}
 
+	if filter.RequestType != "" {
+		query = query.Where("request_type = ?", filter.RequestType)
+	}
+
+	if filter.PromptVersion != "" {
+		query = query.Where("prompt_version = ?", filter.PromptVersion)
+	}
+
 	if filter.ErrorType != "" {
 		query = query.Where("error_type = ?", filter.ErrorType)
 	}
 
+	if len(filter.StatusCodes) > 0 {
+		query = query.Where("status_code IN ?", filter.StatusCodes)
+	}
+
 	if filter.StartTime != nil {
 		query = query.Where("requested_at >= ?", *filter.StartTime)
 	}
@@ -109,6 +504,14 @@ func (a *GormAdapter) Query(ctx context.Context, filter *llmtracer.RequestFilter
 		query = query.Where("(input_tokens + output_tokens) <= ?", *filter.MaxTokens)
 	}
 
+	if filter.MinLatency != nil {
+		query = query.Where("latency_ms >= ?", filter.MinLatency.Milliseconds())
+	}
+
+	if filter.MaxLatency != nil {
+		query = query.Where("latency_ms <= ?", filter.MaxLatency.Milliseconds())
+	}
+
 	if filter.HasError != nil {
 		if *filter.HasError {
 			query = query.Where("error IS NOT NULL AND error != ''")
@@ -117,139 +520,345 @@ func (a *GormAdapter) Query(ctx context.Context, filter *llmtracer.RequestFilter
 		}
 	}
 
-	if len(filter.Dimensions) > 0 {
-		// Join with dimension tags for filtering
-		for _, dim := range filter.Dimensions {
-			query = query.Joins("JOIN request_dimensions rd ON rd.request_id = requests.id").
-				Joins("JOIN dimension_tags dt ON dt.id = rd.dimension_tag_id").
-				Where("dt.key = ? AND dt.value = ?", dim.Key, dim.Value)
+	for _, dim := range filter.Dimensions {
+		if a.jsonDimensions {
+			condition, args := a.jsonDimensionMatch(dim.Key, dim.Value)
+			query = query.Where(condition, args...)
+			continue
 		}
+		// A subquery per dimension neither multiplies rows nor clashes with other joins
+		query = query.Where("requests.id IN (SELECT rd.request_id FROM request_dimensions rd JOIN dimension_tags dt ON dt.id = rd.dimension_tag_id WHERE dt.key = ? AND dt.value = ?)",
+			dim.Key, dim.Value)
 	}
 
-	orderBy := "created_at"
-	if filter.OrderBy != "" {
-		orderBy = filter.OrderBy
+	if filter.SearchText != "" {
+		query = a.applySearch(query, filter.SearchText)
 	}
 
-	if filter.OrderDesc {
-		orderBy += " DESC"
-	} else {
-		orderBy += " ASC"
-	}
-	query = query.Order(orderBy)
+	return query
+}
 
-	if filter.Limit > 0 {
-		query = query.Limit(filter.Limit)
-	}
+// statusClassExpr maps status_code to the classes returned by llmtracer.StatusClass
+const statusClassExpr = "CASE WHEN status_code >= 100 AND status_code < 200 THEN '1xx' " +
+	"WHEN status_code >= 200 AND status_code < 300 THEN '2xx' " +
+	"WHEN status_code >= 300 AND status_code < 400 THEN '3xx' " +
+	"WHEN status_code >= 400 AND status_code < 500 THEN '4xx' " +
+	"WHEN status_code >= 500 AND status_code < 600 THEN '5xx' ELSE '' END"
 
-	if filter.Offset > 0 {
-		query = query.Offset(filter.Offset)
+// notDeleted excludes soft-deleted requests
+const notDeleted = "requests.deleted_at IS NULL"
+
+// Delete soft deletes a request; it can be restored until it is purged
+func (a *GormAdapter) Delete(ctx context.Context, id string) error {
+	result := a.db.WithContext(ctx).Model(&llmtracer.Request{}).
+		Where(notDeleted).Where("id = ?", id).
+		Update("deleted_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
 	}
+	return nil
+}
 
-	var requests []*llmtracer.Request
-	if err := query.Preload("Dimensions").Find(&requests).Error; err != nil {
-		return nil, err
+// DeleteOlderThan soft deletes requests created before the given time
+func (a *GormAdapter) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	result := a.db.WithContext(ctx).Model(&llmtracer.Request{}).
+		Where(notDeleted).Where("created_at < ?", before).
+		Update("deleted_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// Restore undoes the soft delete of a request
+func (a *GormAdapter) Restore(ctx context.Context, id string) error {
+	result := a.db.WithContext(ctx).Model(&llmtracer.Request{}).
+		Where("deleted_at IS NOT NULL AND id = ?", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
 
-	return requests, nil
+// PurgeDeleted permanently removes requests soft deleted before the given time, along with
+// their dimension links and feedback. With WithPartitioning it also creates upcoming
+// partitions and drops expired ones.
+func (a *GormAdapter) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	if a.partitioning != nil {
+		if err := a.EnsurePartitions(ctx, time.Now()); err != nil {
+			return 0, err
+		}
+		dropped, err := a.dropPartitions(ctx, before)
+		if err != nil {
+			return dropped, err
+		}
+		purged = dropped
+	}
+	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&llmtracer.Request{}).Select("id").Where("deleted_at < ?", before)
+		if !a.jsonDimensions {
+			if err := tx.Exec("DELETE FROM request_dimensions WHERE request_id IN (?)", expired).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("request_id IN (?)", expired).Delete(&llmtracer.Feedback{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("deleted_at < ?", before).Delete(&llmtracer.Request{})
+		purged += result.RowsAffected
+		return result.Error
+	})
+	return purged, err
 }
 
-func (a *GormAdapter) Aggregate(ctx context.Context, groupBy []string, filter *llmtracer.RequestFilter) ([]*llmtracer.AggregateResult, error) {
-	query := a.db.WithContext(ctx).Model(&llmtracer.Request{})
+func (a *GormAdapter) SaveFeedback(ctx context.Context, feedback *llmtracer.Feedback) error {
+	return a.db.WithContext(ctx).Create(feedback).Error
+}
 
+func (a *GormAdapter) QueryFeedback(ctx context.Context, filter *llmtracer.FeedbackFilter) ([]*llmtracer.Feedback, error) {
+	query := a.readConn(ctx).WithContext(ctx).Model(&llmtracer.Feedback{})
 	if filter != nil {
-		if filter.Provider != "" {
-			query = query.Where("provider = ?", filter.Provider)
+		if len(filter.RequestIDs) > 0 {
+			query = query.Where("request_id IN ?", filter.RequestIDs)
 		}
-
-		if filter.Model != "" {
-			query = query.Where("model = ?", filter.Model)
+		if filter.Evaluator != "" {
+			query = query.Where("evaluator = ?", filter.Evaluator)
+		}
+		if filter.ThumbsUp != nil {
+			query = query.Where("thumbs_up = ?", *filter.ThumbsUp)
+		}
+		if filter.MinRating != nil {
+			query = query.Where("rating >= ?", *filter.MinRating)
+		}
+		if filter.MaxRating != nil {
+			query = query.Where("rating <= ?", *filter.MaxRating)
 		}
-
 		if filter.StartTime != nil {
-			query = query.Where("requested_at >= ?", *filter.StartTime)
+			query = query.Where("created_at >= ?", *filter.StartTime)
 		}
-
 		if filter.EndTime != nil {
-			query = query.Where("requested_at <= ?", *filter.EndTime)
+			query = query.Where("created_at <= ?", *filter.EndTime)
+		}
+		if filter.Limit > 0 {
+			query = query.Limit(filter.Limit)
+		}
+		if filter.Offset > 0 {
+			query = query.Offset(filter.Offset)
 		}
 	}
 
-	selectFields := []string{
-		"COUNT(*) as total_requests",
-		"SUM(input_tokens + output_tokens) as total_tokens",
-		"AVG(latency) as avg_latency",
-		"SUM(CASE WHEN error IS NOT NULL AND error != '' THEN 1 ELSE 0 END) as error_count",
-	}
-
-	var groupFields []string
-	for _, field := range groupBy {
-		switch field {
-		case "provider", "model":
-			selectFields = append(selectFields, field)
-			groupFields = append(groupFields, field)
-		default:
-			continue
-		}
+	var feedback []*llmtracer.Feedback
+	if err := query.Order("created_at DESC").Find(&feedback).Error; err != nil {
+		return nil, err
 	}
+	return feedback, nil
+}
 
-	selectClause := strings.Join(selectFields, ", ")
-	query = query.Select(selectClause)
+// SaveFilter stores a named filter, replacing the stored one with the same name
+func (a *GormAdapter) SaveFilter(ctx context.Context, filter *llmtracer.SavedFilter) error {
+	filter.WindowMs = filter.Window.Milliseconds()
+	return a.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"description", "filter", "window_ms", "updated_at"}),
+	}).Create(filter).Error
+}
 
-	if len(groupFields) > 0 {
-		query = query.Group(strings.Join(groupFields, ", "))
+// GetFilter returns the named filter, or llmtracer.ErrFilterNotFound
+func (a *GormAdapter) GetFilter(ctx context.Context, name string) (*llmtracer.SavedFilter, error) {
+	var filters []*llmtracer.SavedFilter
+	if err := a.readConn(ctx).WithContext(ctx).Where("name = ?", name).Limit(1).Find(&filters).Error; err != nil {
+		return nil, err
 	}
-
-	type aggregateRow struct {
-		Provider      llmtracer.Provider `json:"provider"`
-		Model         string             `json:"model"`
-		TotalRequests int64              `json:"total_requests"`
-		TotalTokens   int64              `json:"total_tokens"`
-		AvgLatency    float64            `json:"avg_latency"`
-		ErrorCount    int64              `json:"error_count"`
+	if len(filters) == 0 {
+		return nil, llmtracer.ErrFilterNotFound
 	}
+	filters[0].Window = time.Duration(filters[0].WindowMs) * time.Millisecond
+	return filters[0], nil
+}
 
-	var rows []aggregateRow
-	if err := query.Scan(&rows).Error; err != nil {
+// ListFilters returns every named filter, ordered by name
+func (a *GormAdapter) ListFilters(ctx context.Context) ([]*llmtracer.SavedFilter, error) {
+	var filters []*llmtracer.SavedFilter
+	if err := a.readConn(ctx).WithContext(ctx).Order("name").Find(&filters).Error; err != nil {
 		return nil, err
 	}
-
-	var results []*llmtracer.AggregateResult
-	for _, row := range rows {
-		result := &llmtracer.AggregateResult{
-			Provider:      row.Provider,
-			Model:         row.Model,
-			TotalRequests: row.TotalRequests,
-			TotalTokens:   row.TotalTokens,
-			AvgLatency:    time.Duration(int64(row.AvgLatency)),
-			ErrorCount:    row.ErrorCount,
-			Dimensions:    []llmtracer.DimensionTag{},
-		}
-		results = append(results, result)
+	for _, filter := range filters {
+		filter.Window = time.Duration(filter.WindowMs) * time.Millisecond
 	}
-
-	return results, nil
+	return filters, nil
 }
 
-func (a *GormAdapter) Delete(ctx context.Context, id string) error {
-	result := a.db.WithContext(ctx).Delete(&llmtracer.Request{}, "id = ?", id)
+// DeleteFilter removes the named filter, or returns llmtracer.ErrFilterNotFound
+func (a *GormAdapter) DeleteFilter(ctx context.Context, name string) error {
+	result := a.db.WithContext(ctx).Where("name = ?", name).Delete(&llmtracer.SavedFilter{})
 	if result.Error != nil {
 		return result.Error
 	}
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+		return llmtracer.ErrFilterNotFound
 	}
 	return nil
 }
 
-func (a *GormAdapter) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
-	result := a.db.WithContext(ctx).Where("created_at < ?", before).Delete(&llmtracer.Request{})
-	return result.RowsAffected, result.Error
+func (a *GormAdapter) SaveIncident(ctx context.Context, incident *llmtracer.ProviderIncident) error {
+	return a.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(incident).Error
+}
+
+func (a *GormAdapter) QueryIncidents(ctx context.Context, filter *llmtracer.IncidentFilter) ([]*llmtracer.ProviderIncident, error) {
+	query := a.readConn(ctx).WithContext(ctx).Model(&llmtracer.ProviderIncident{})
+	if filter != nil {
+		if filter.Provider != "" {
+			query = query.Where("provider = ?", filter.Provider)
+		}
+		if filter.StartTime != nil {
+			query = query.Where("resolved_at IS NULL OR resolved_at >= ?", *filter.StartTime)
+		}
+		if filter.EndTime != nil {
+			query = query.Where("started_at <= ?", *filter.EndTime)
+		}
+	}
+
+	var incidents []*llmtracer.ProviderIncident
+	if err := query.Order("started_at DESC").Find(&incidents).Error; err != nil {
+		return nil, err
+	}
+	return incidents, nil
+}
+
+// Capabilities reports native aggregation, soft delete, feedback, incident and saved
+// filter support
+func (a *GormAdapter) Capabilities() llmtracer.StorageCapabilities {
+	return llmtracer.StorageCapabilities{
+		Query:        true,
+		Aggregate:    true,
+		SoftDelete:   true,
+		Feedback:     true,
+		Incidents:    true,
+		Lifecycle:    true,
+		SavedFilters: true,
+		Rollups:      true,
+	}
+}
+
+// Ping verifies the database connection, and the read replica's when one is configured
+func (a *GormAdapter) Ping(ctx context.Context) error {
+	for _, conn := range []*gorm.DB{a.db, a.reader} {
+		db, err := conn.DB()
+		if err != nil {
+			return err
+		}
+		if err := db.PingContext(ctx); err != nil {
+			return err
+		}
+		if a.reader == a.db {
+			break
+		}
+	}
+	return nil
 }
 
 func (a *GormAdapter) Close() error {
+	var readerErr error
+	if a.reader != a.db {
+		if db, err := a.reader.DB(); err == nil {
+			readerErr = db.Close()
+		}
+	}
 	if db, err := a.db.DB(); err == nil {
-		return db.Close()
+		if err := db.Close(); err != nil {
+			return err
+		}
+	}
+	return readerErr
+}
+
+// int64Value converts a scanned aggregate column to int64; drivers differ in the types they return
+func int64Value(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int:
+		return int64(n)
+	case uint64:
+		return int64(min(n, math.MaxInt64))
+	case float64:
+		return clampInt64(n)
+	case float32:
+		return clampInt64(float64(n))
+	case []byte:
+		return parseInt64(string(n))
+	case string:
+		return parseInt64(n)
+	default:
+		return 0
+	}
+}
+
+// parseInt64 parses an aggregate returned as text, e.g. a Postgres NUMERIC sum, saturating
+// at the bounds of int64
+func parseInt64(s string) int64 {
+	if parsed, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return parsed
+	}
+	parsed, _ := strconv.ParseFloat(s, 64)
+	return clampInt64(parsed)
+}
+
+// clampInt64 converts f to int64, saturating at the bounds of int64
+func clampInt64(f float64) int64 {
+	switch {
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f <= math.MinInt64:
+		return math.MinInt64
+	default:
+		return int64(f)
+	}
+}
+
+// float64Value converts a scanned aggregate column to float64
+func float64Value(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case float32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case []byte:
+		parsed, _ := strconv.ParseFloat(string(n), 64)
+		return parsed
+	case string:
+		parsed, _ := strconv.ParseFloat(n, 64)
+		return parsed
+	default:
+		return 0
+	}
+}
+
+// stringValue converts a scanned column to string
+func stringValue(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", s)
 	}
-	return nil
 }
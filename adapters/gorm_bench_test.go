@@ -0,0 +1,31 @@
+package adapters
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+	"github.com/propel-gtm/llm-request-tracer/bench"
+)
+
+func BenchmarkGormAdapterSQLite(b *testing.B) {
+	bench.Benchmarks(b, func(tb testing.TB) llmtracer.StorageAdapter {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if err != nil {
+			tb.Fatalf("Failed to open database: %v", err)
+		}
+		// Every connection to :memory: is a separate database
+		sqlDB, _ := db.DB()
+		sqlDB.SetMaxOpenConns(1)
+
+		adapter, err := NewGormAdapter(db)
+		if err != nil {
+			tb.Fatalf("Failed to create adapter: %v", err)
+		}
+		tb.Cleanup(func() { adapter.Close() })
+		return adapter
+	})
+}
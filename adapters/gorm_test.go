@@ -2,6 +2,9 @@ package adapters
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,14 +30,16 @@ func TestGormAdapter(t *testing.T) {
 
 	t.Run("Save and Get", func(t *testing.T) {
 		request := &llmtracer.Request{
-			ID:           "test-id",
-			TraceID:      "test-trace",
-			Provider:     llmtracer.ProviderOpenAI,
-			Model:        "gpt-4",
-			InputTokens:  100,
-			OutputTokens: 150,
-			Latency:      1000 * time.Millisecond,
-			StatusCode:   200,
+			ID:             "test-id",
+			TraceID:        "test-trace",
+			Provider:       llmtracer.ProviderOpenAI,
+			Model:          "gpt-4",
+			InputTokens:    100,
+			OutputTokens:   150,
+			Latency:        1000 * time.Millisecond,
+			RetryAfter:     20 * time.Second,
+			StatusCode:     200,
+			RequestPayload: `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`,
 			Dimensions: []llmtracer.DimensionTag{
 				{Key: "user_id", Value: "test-user"},
 			},
@@ -55,5 +60,959 @@ func TestGormAdapter(t *testing.T) {
 		if retrieved.Provider != llmtracer.ProviderOpenAI {
 			t.Errorf("Expected provider %s, got %s", llmtracer.ProviderOpenAI, retrieved.Provider)
 		}
+		if retrieved.RequestPayload != request.RequestPayload {
+			t.Errorf("Expected payload %s, got %s", request.RequestPayload, retrieved.RequestPayload)
+		}
+		if retrieved.LatencyMs != 1000 || retrieved.Latency != time.Second {
+			t.Errorf("Expected 1000ms latency, got %dms (%v)", retrieved.LatencyMs, retrieved.Latency)
+		}
+		if retrieved.RetryAfter != 20*time.Second {
+			t.Errorf("Expected a 20s retry after, got %v", retrieved.RetryAfter)
+		}
+	})
+
+	t.Run("Aggregate payload sizes by dimension", func(t *testing.T) {
+		for i, feature := range []string{"search", "search", "chat"} {
+			request := &llmtracer.Request{
+				ID:            fmt.Sprintf("size-%d", i),
+				TraceID:       "size-trace",
+				Provider:      llmtracer.ProviderAnthropic,
+				Model:         "claude-3-haiku",
+				InputTokens:   10,
+				OutputTokens:  5,
+				MessageCount:  2 + i,
+				RequestBytes:  100 * (i + 1),
+				ResponseBytes: 50,
+				Cost:          0.25,
+				Dimensions: []llmtracer.DimensionTag{
+					{Key: "feature", Value: feature},
+				},
+				RequestedAt: time.Now(),
+				RespondedAt: time.Now(),
+			}
+			if err := adapter.Save(ctx, request); err != nil {
+				t.Fatalf("Failed to save request: %v", err)
+			}
+		}
+
+		results, err := adapter.Aggregate(ctx, []string{llmtracer.GroupByDimension("feature")}, &llmtracer.RequestFilter{
+			Provider: llmtracer.ProviderAnthropic,
+		})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+
+		byFeature := make(map[string]*llmtracer.AggregateResult)
+		for _, result := range results {
+			if len(result.Dimensions) != 1 || result.Dimensions[0].Key != "feature" {
+				t.Fatalf("Expected feature dimension on result, got %v", result.Dimensions)
+			}
+			byFeature[result.Dimensions[0].Value] = result
+		}
+
+		search := byFeature["search"]
+		if search == nil {
+			t.Fatalf("Expected search group in %v", byFeature)
+		}
+		if search.TotalRequests != 2 {
+			t.Errorf("Expected 2 search requests, got %d", search.TotalRequests)
+		}
+		if search.TotalRequestBytes != 300 {
+			t.Errorf("Expected 300 request bytes, got %d", search.TotalRequestBytes)
+		}
+		if search.AvgRequestBytes != 150 {
+			t.Errorf("Expected avg request bytes 150, got %f", search.AvgRequestBytes)
+		}
+		if search.TotalCost != 0.5 {
+			t.Errorf("Expected total cost 0.5, got %f", search.TotalCost)
+		}
+		if search.AvgMessageCount != 2.5 {
+			t.Errorf("Expected avg message count 2.5, got %f", search.AvgMessageCount)
+		}
+
+		if chat := byFeature["chat"]; chat == nil || chat.TotalMessages != 4 {
+			t.Errorf("Expected chat group with 4 messages, got %v", chat)
+		}
+	})
+
+	t.Run("Filter and group by prompt version", func(t *testing.T) {
+		for i, version := range []string{"v1", "v2", "v2"} {
+			request := &llmtracer.Request{
+				ID:            fmt.Sprintf("prompt-%d", i),
+				Provider:      llmtracer.ProviderMistral,
+				Model:         "mistral-small",
+				PromptVersion: version,
+				InputTokens:   int64(10 * (i + 1)),
+				RequestedAt:   time.Now(),
+				RespondedAt:   time.Now(),
+			}
+			if err := adapter.Save(ctx, request); err != nil {
+				t.Fatalf("Failed to save request: %v", err)
+			}
+		}
+
+		requests, err := adapter.Query(ctx, &llmtracer.RequestFilter{PromptVersion: "v2"})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if len(requests) != 2 {
+			t.Errorf("Expected 2 v2 requests, got %d", len(requests))
+		}
+
+		results, err := adapter.Aggregate(ctx, []string{"prompt_version"}, &llmtracer.RequestFilter{
+			Provider: llmtracer.ProviderMistral,
+		})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		tokens := make(map[string]int64)
+		for _, result := range results {
+			tokens[result.PromptVersion] = result.TotalTokens
+		}
+		if tokens["v1"] != 10 || tokens["v2"] != 50 {
+			t.Errorf("Unexpected tokens by prompt version: %v", tokens)
+		}
+	})
+	t.Run("Filter and group by status code", func(t *testing.T) {
+		errorTypes := map[int]llmtracer.ErrorType{429: llmtracer.ErrorTypeRateLimit, 503: llmtracer.ErrorTypeOverloaded}
+		for i, status := range []int{200, 200, 429, 503} {
+			request := &llmtracer.Request{
+				ID:          fmt.Sprintf("status-%d", i),
+				Provider:    llmtracer.ProviderOpenAI,
+				Model:       "gpt-4o-mini",
+				StatusCode:  status,
+				RequestedAt: time.Now(),
+				RespondedAt: time.Now(),
+			}
+			if status >= 400 {
+				request.Error = "request failed"
+				request.ErrorType = errorTypes[status]
+			}
+			if err := adapter.Save(ctx, request); err != nil {
+				t.Fatalf("Failed to save request: %v", err)
+			}
+		}
+
+		requests, err := adapter.Query(ctx, &llmtracer.RequestFilter{StatusCodes: []int{429, 503}})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if len(requests) != 2 {
+			t.Errorf("Expected 2 failed requests, got %d", len(requests))
+		}
+
+		filter := &llmtracer.RequestFilter{Model: "gpt-4o-mini"}
+		results, err := adapter.Aggregate(ctx, []string{"model", "status_class"}, filter)
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		counts := make(map[string]int64)
+		for _, result := range results {
+			counts[result.StatusClass] = result.TotalRequests
+		}
+		if counts["2xx"] != 2 || counts["4xx"] != 1 || counts["5xx"] != 1 {
+			t.Errorf("Unexpected counts by status class: %v", counts)
+		}
+
+		results, err = adapter.Aggregate(ctx, []string{"status_code"}, filter)
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if len(results) != 3 {
+			t.Errorf("Expected 3 status codes, got %d", len(results))
+		}
+
+		results, err = adapter.Aggregate(ctx, []string{"error_type"}, filter)
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		counts = make(map[string]int64)
+		for _, result := range results {
+			counts[string(result.ErrorType)] = result.TotalRequests
+		}
+		if counts[""] != 2 || counts["rate_limit"] != 1 || counts["overloaded"] != 1 {
+			t.Errorf("Unexpected counts by error type: %v", counts)
+		}
+
+		results, err = adapter.Aggregate(ctx, []string{"model"}, filter)
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if len(results) != 1 || results[0].SuccessRate != 0.5 {
+			t.Errorf("Expected a success rate of 0.5, got %+v", results)
+		}
+	})
+
+	t.Run("Query by dimension", func(t *testing.T) {
+		requests, err := adapter.Query(ctx, &llmtracer.RequestFilter{
+			Dimensions: []llmtracer.DimensionTag{{Key: "feature", Value: "search"}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if len(requests) != 2 {
+			t.Errorf("Expected 2 search requests, got %d", len(requests))
+		}
+	})
+	t.Run("Aggregate with the full filter", func(t *testing.T) {
+		for i, tier := range []string{"free", "free", "paid"} {
+			request := &llmtracer.Request{
+				ID:           fmt.Sprintf("filter-%d", i),
+				Provider:     llmtracer.ProviderMistral,
+				Model:        "mistral-large",
+				InputTokens:  int64(100 * (i + 1)),
+				OutputTokens: 10,
+				Cost:         float64(i + 1),
+				Dimensions: []llmtracer.DimensionTag{
+					{Key: "feature", Value: "search"},
+					{Key: "tier", Value: tier},
+				},
+				RequestedAt: time.Now(),
+				RespondedAt: time.Now(),
+			}
+			if i == 1 {
+				request.Error = "request failed"
+			}
+			if err := adapter.Save(ctx, request); err != nil {
+				t.Fatalf("Failed to save request: %v", err)
+			}
+		}
+
+		searchFree := []llmtracer.DimensionTag{{Key: "feature", Value: "search"}, {Key: "tier", Value: "free"}}
+		requests, err := adapter.Query(ctx, &llmtracer.RequestFilter{Dimensions: searchFree})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if len(requests) != 2 {
+			t.Errorf("Expected 2 free search requests, got %d", len(requests))
+		}
+
+		results, err := adapter.Aggregate(ctx, []string{"model"}, &llmtracer.RequestFilter{Dimensions: searchFree})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if len(results) != 1 || results[0].TotalRequests != 2 || results[0].TotalCost != 3 {
+			t.Errorf("Expected 2 requests costing 3, got %+v", results)
+		}
+
+		hasError := false
+		minTokens := int64(200)
+		results, err = adapter.Aggregate(ctx, []string{"model"}, &llmtracer.RequestFilter{
+			Model:      "mistral-large",
+			HasError:   &hasError,
+			MinTokens:  &minTokens,
+			Dimensions: []llmtracer.DimensionTag{{Key: "feature", Value: "search"}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if len(results) != 1 || results[0].TotalRequests != 1 || results[0].TotalCost != 3 {
+			t.Errorf("Expected only the paid request, got %+v", results)
+		}
+	})
+
+	t.Run("Query by latency", func(t *testing.T) {
+		for i, latency := range []time.Duration{500 * time.Millisecond, 30 * time.Second, 45 * time.Second} {
+			request := &llmtracer.Request{
+				ID:          fmt.Sprintf("slow-%d", i),
+				Provider:    llmtracer.ProviderOpenAI,
+				Model:       "gpt-slow",
+				Latency:     latency,
+				LatencyMs:   latency.Milliseconds(),
+				RequestedAt: time.Now(),
+			}
+			if err := adapter.Save(ctx, request); err != nil {
+				t.Fatalf("Failed to save request: %v", err)
+			}
+		}
+
+		minLatency := 30 * time.Second
+		requests, err := adapter.Query(ctx, &llmtracer.RequestFilter{Model: "gpt-slow", MinLatency: &minLatency})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if len(requests) != 2 {
+			t.Errorf("Expected 2 requests of 30s or more, got %d", len(requests))
+		}
+
+		maxLatency := 40 * time.Second
+		results, err := adapter.Aggregate(ctx, []string{"model"}, &llmtracer.RequestFilter{Model: "gpt-slow", MinLatency: &minLatency, MaxLatency: &maxLatency})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if len(results) != 1 || results[0].TotalRequests != 1 || results[0].AvgLatency != 30*time.Second {
+			t.Errorf("Expected the 30s request, got %+v", results)
+		}
+	})
+
+	t.Run("Aggregate totals beyond 32 bits", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			request := &llmtracer.Request{
+				ID:           fmt.Sprintf("large-%d", i),
+				Provider:     llmtracer.ProviderOpenAI,
+				Model:        "gpt-large",
+				InputTokens:  3_000_000_000,
+				OutputTokens: 1,
+				RequestedAt:  time.Now(),
+			}
+			if err := adapter.Save(ctx, request); err != nil {
+				t.Fatalf("Failed to save request: %v", err)
+			}
+		}
+
+		results, err := adapter.Aggregate(ctx, []string{"model"}, &llmtracer.RequestFilter{Model: "gpt-large"})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if len(results) != 1 || results[0].TotalInputTokens != 6_000_000_000 || results[0].TotalTokens != 6_000_000_002 {
+			t.Errorf("Expected 6000000000 input tokens, got %+v", results)
+		}
+
+		// Sums returned as text, e.g. Postgres NUMERIC, parse exactly and saturate
+		if got := int64Value([]byte("6000000000000000001")); got != 6000000000000000001 {
+			t.Errorf("Expected an exact parse, got %d", got)
+		}
+		if got := int64Value("1e30"); got != math.MaxInt64 {
+			t.Errorf("Expected a saturated value, got %d", got)
+		}
+	})
+
+	t.Run("Aggregate average latency", func(t *testing.T) {
+		for i, latency := range []time.Duration{1200 * time.Millisecond, 1800 * time.Millisecond, 3 * time.Second} {
+			request := &llmtracer.Request{
+				ID:          fmt.Sprintf("latency-%d", i),
+				Provider:    llmtracer.ProviderOpenAI,
+				Model:       "gpt-latency",
+				Latency:     latency,
+				RequestedAt: time.Now(),
+			}
+			if err := adapter.Save(ctx, request); err != nil {
+				t.Fatalf("Failed to save request: %v", err)
+			}
+		}
+
+		results, err := adapter.Aggregate(ctx, []string{"model"}, &llmtracer.RequestFilter{Model: "gpt-latency"})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if len(results) != 1 || results[0].AvgLatency != 2*time.Second {
+			t.Errorf("Expected a 2s average latency, got %+v", results)
+		}
+	})
+
+	t.Run("Aggregate multiple specs", func(t *testing.T) {
+		specs := []llmtracer.AggregateSpec{
+			{GroupBy: []string{"model"}, Filter: &llmtracer.RequestFilter{Provider: llmtracer.ProviderMistral}},
+			{GroupBy: []string{llmtracer.GroupByDimension("tier")}, Filter: &llmtracer.RequestFilter{Provider: llmtracer.ProviderMistral}},
+			{Filter: &llmtracer.RequestFilter{Provider: llmtracer.ProviderAnthropic}},
+			{GroupBy: []string{"status_code"}, Filter: &llmtracer.RequestFilter{Provider: "none"}},
+		}
+		results, err := adapter.AggregateMulti(ctx, specs)
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if len(results) != len(specs) {
+			t.Fatalf("Expected results for %d specs, got %d", len(specs), len(results))
+		}
+
+		// Every batched aggregation matches the aggregation run on its own
+		for i, spec := range specs {
+			expected, err := adapter.Aggregate(ctx, spec.GroupBy, spec.Filter)
+			if err != nil {
+				t.Fatalf("Failed to aggregate: %v", err)
+			}
+			if len(results[i]) != len(expected) {
+				t.Fatalf("Spec %d: expected %d results, got %d", i, len(expected), len(results[i]))
+			}
+			key := func(result *llmtracer.AggregateResult) string {
+				return fmt.Sprintf("%s/%v", result.Model, result.Dimensions)
+			}
+			byKey := make(map[string]*llmtracer.AggregateResult)
+			for _, result := range expected {
+				byKey[key(result)] = result
+			}
+			for _, result := range results[i] {
+				want := byKey[key(result)]
+				if want == nil || result.TotalRequests != want.TotalRequests || result.TotalCost != want.TotalCost {
+					t.Errorf("Spec %d: unexpected result %+v, want %+v", i, result, want)
+				}
+			}
+		}
+
+		tiers := make(map[string]int64)
+		for _, result := range results[1] {
+			tiers[result.Dimension("tier")] = result.TotalRequests
+		}
+		if tiers["free"] != 2 || tiers["paid"] != 1 {
+			t.Errorf("Expected 2 free and 1 paid requests, got %v", tiers)
+		}
+		for _, result := range results[0] {
+			if result.Model == "" || len(result.Dimensions) != 0 {
+				t.Errorf("Expected model groups without dimensions, got %+v", result)
+			}
+		}
+		if len(results[2]) != 1 || results[2][0].TotalRequests == 0 {
+			t.Errorf("Expected one ungrouped anthropic result, got %+v", results[2])
+		}
+		if len(results[3]) != 0 {
+			t.Errorf("Expected no groups for an empty selection, got %+v", results[3])
+		}
+	})
+
+	t.Run("Soft delete and restore", func(t *testing.T) {
+		request := &llmtracer.Request{
+			ID:          "soft-delete",
+			TraceID:     "soft-trace",
+			Provider:    llmtracer.ProviderGoogle,
+			Model:       "gemini-1.5-flash",
+			InputTokens: 7,
+			Dimensions: []llmtracer.DimensionTag{
+				{Key: "feature", Value: "gdpr"},
+			},
+			RequestedAt: time.Now(),
+			RespondedAt: time.Now(),
+		}
+		if err := adapter.Save(ctx, request); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+
+		if err := adapter.Delete(ctx, "soft-delete"); err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+		if err := adapter.Delete(ctx, "soft-delete"); err != gorm.ErrRecordNotFound {
+			t.Errorf("Expected ErrRecordNotFound deleting twice, got %v", err)
+		}
+		if _, err := adapter.Get(ctx, "soft-delete"); err == nil {
+			t.Error("Expected deleted request to be hidden from Get")
+		}
+		if requests, _ := adapter.GetByTraceID(ctx, "soft-trace"); len(requests) != 0 {
+			t.Errorf("Expected deleted request to be hidden from GetByTraceID, got %d", len(requests))
+		}
+		filter := &llmtracer.RequestFilter{Provider: llmtracer.ProviderGoogle}
+		if requests, _ := adapter.Query(ctx, filter); len(requests) != 0 {
+			t.Errorf("Expected deleted request to be hidden from Query, got %d", len(requests))
+		}
+		if results, _ := adapter.Aggregate(ctx, []string{"provider"}, filter); len(results) != 0 {
+			t.Errorf("Expected deleted request to be excluded from Aggregate, got %d groups", len(results))
+		}
+
+		filter.IncludeDeleted = true
+		requests, err := adapter.Query(ctx, filter)
+		if err != nil || len(requests) != 1 || requests[0].DeletedAt == nil {
+			t.Fatalf("Expected deleted request with IncludeDeleted, got %v (err %v)", requests, err)
+		}
+
+		if err := adapter.Restore(ctx, "soft-delete"); err != nil {
+			t.Fatalf("Failed to restore: %v", err)
+		}
+		restored, err := adapter.Get(ctx, "soft-delete")
+		if err != nil || restored.DeletedAt != nil || len(restored.Dimensions) != 1 {
+			t.Fatalf("Expected restored request with its dimensions, got %+v (err %v)", restored, err)
+		}
+		if err := adapter.Restore(ctx, "soft-delete"); err != gorm.ErrRecordNotFound {
+			t.Errorf("Expected ErrRecordNotFound restoring a live request, got %v", err)
+		}
+	})
+
+	t.Run("Purge deleted", func(t *testing.T) {
+		if err := adapter.Delete(ctx, "soft-delete"); err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+
+		purged, err := adapter.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+		if err != nil || purged != 0 {
+			t.Errorf("Expected nothing purged before the cutoff, got %d (err %v)", purged, err)
+		}
+		purged, err = adapter.PurgeDeleted(ctx, time.Now().Add(time.Second))
+		if err != nil || purged != 1 {
+			t.Fatalf("Expected 1 purged request, got %d (err %v)", purged, err)
+		}
+		if err := adapter.Restore(ctx, "soft-delete"); err != gorm.ErrRecordNotFound {
+			t.Errorf("Expected purged request to be gone, got %v", err)
+		}
+	})
+
+	t.Run("Update pending request", func(t *testing.T) {
+		testUpdatePending(t, adapter)
+	})
+
+	t.Run("Feedback", func(t *testing.T) {
+		if err := adapter.Save(ctx, &llmtracer.Request{ID: "rated", Provider: llmtracer.ProviderOpenAI, Model: "gpt-4"}); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+		rating, up, down := 4.0, true, false
+		for _, feedback := range []*llmtracer.Feedback{
+			{ID: "fb-1", RequestID: "rated", Rating: &rating, Evaluator: "llm-judge", CreatedAt: time.Now().Add(-time.Minute)},
+			{ID: "fb-2", RequestID: "rated", ThumbsUp: &up, Comment: "great answer", Evaluator: "user", CreatedAt: time.Now()},
+			{ID: "fb-3", RequestID: "other", ThumbsUp: &down, Evaluator: "user", CreatedAt: time.Now()},
+		} {
+			if err := adapter.SaveFeedback(ctx, feedback); err != nil {
+				t.Fatalf("Failed to save feedback: %v", err)
+			}
+		}
+
+		feedback, err := adapter.QueryFeedback(ctx, &llmtracer.FeedbackFilter{RequestIDs: []string{"rated"}})
+		if err != nil {
+			t.Fatalf("Failed to query feedback: %v", err)
+		}
+		if len(feedback) != 2 || feedback[0].ID != "fb-2" || feedback[0].Comment != "great answer" || !*feedback[0].ThumbsUp {
+			t.Errorf("Expected newest feedback first, got %+v", feedback)
+		}
+
+		minRating := 3.0
+		feedback, err = adapter.QueryFeedback(ctx, &llmtracer.FeedbackFilter{MinRating: &minRating})
+		if err != nil || len(feedback) != 1 || *feedback[0].Rating != 4 {
+			t.Errorf("Expected 1 rated feedback, got %+v (err %v)", feedback, err)
+		}
+		feedback, err = adapter.QueryFeedback(ctx, &llmtracer.FeedbackFilter{Evaluator: "user", ThumbsUp: &down})
+		if err != nil || len(feedback) != 1 || feedback[0].ID != "fb-3" {
+			t.Errorf("Expected thumbs-down feedback, got %+v (err %v)", feedback, err)
+		}
+
+		// Purging a request removes its feedback
+		if err := adapter.Delete(ctx, "rated"); err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+		if _, err := adapter.PurgeDeleted(ctx, time.Now().Add(time.Second)); err != nil {
+			t.Fatalf("Failed to purge: %v", err)
+		}
+		feedback, err = adapter.QueryFeedback(ctx, nil)
+		if err != nil || len(feedback) != 1 || feedback[0].ID != "fb-3" {
+			t.Errorf("Expected only unrelated feedback to remain, got %+v (err %v)", feedback, err)
+		}
+	})
+
+	t.Run("Incidents", func(t *testing.T) {
+		now := time.Now().UTC().Truncate(time.Second)
+		resolved := now.Add(-2 * time.Hour)
+		for _, incident := range []*llmtracer.ProviderIncident{
+			{ID: "openai:old", Provider: llmtracer.ProviderOpenAI, Name: "Elevated errors", StartedAt: now.Add(-3 * time.Hour), ResolvedAt: &resolved},
+			{ID: "openai:new", Provider: llmtracer.ProviderOpenAI, Name: "Degraded performance", Status: "investigating", StartedAt: now.Add(-10 * time.Minute)},
+			{ID: "anthropic:new", Provider: llmtracer.ProviderAnthropic, Status: "investigating", StartedAt: now.Add(-5 * time.Minute)},
+		} {
+			if err := adapter.SaveIncident(ctx, incident); err != nil {
+				t.Fatalf("Failed to save incident: %v", err)
+			}
+		}
+		// Polling again updates the stored incident
+		if err := adapter.SaveIncident(ctx, &llmtracer.ProviderIncident{ID: "openai:new", Provider: llmtracer.ProviderOpenAI, Name: "Degraded performance", Status: "monitoring", StartedAt: now.Add(-10 * time.Minute)}); err != nil {
+			t.Fatalf("Failed to update incident: %v", err)
+		}
+
+		since := now.Add(-time.Hour)
+		incidents, err := adapter.QueryIncidents(ctx, &llmtracer.IncidentFilter{Provider: llmtracer.ProviderOpenAI, StartTime: &since})
+		if err != nil {
+			t.Fatalf("Failed to query incidents: %v", err)
+		}
+		if len(incidents) != 1 || incidents[0].ID != "openai:new" || incidents[0].Status != "monitoring" {
+			t.Errorf("Expected the updated active incident, got %+v", incidents)
+		}
+
+		before := now.Add(-time.Hour)
+		incidents, err = adapter.QueryIncidents(ctx, &llmtracer.IncidentFilter{EndTime: &before})
+		if err != nil || len(incidents) != 1 || incidents[0].ID != "openai:old" {
+			t.Errorf("Expected the incident started before the range end, got %+v (err %v)", incidents, err)
+		}
+		incidents, err = adapter.QueryIncidents(ctx, nil)
+		if err != nil || len(incidents) != 3 || incidents[0].ID != "anthropic:new" {
+			t.Errorf("Expected every incident, most recent first, got %+v (err %v)", incidents, err)
+		}
+	})
+}
+
+// testUpdatePending completes a pending request the way lifecycle tracking does
+func testUpdatePending(t *testing.T, adapter *GormAdapter) {
+	ctx := context.Background()
+	now := time.Now()
+	request := &llmtracer.Request{
+		ID:          "in-flight",
+		Provider:    llmtracer.ProviderOpenAI,
+		Model:       "gpt-pending",
+		Pending:     true,
+		RequestedAt: now,
+		Dimensions:  []llmtracer.DimensionTag{{Key: "user_id", Value: "alice"}},
+	}
+	if err := adapter.Save(ctx, request); err != nil {
+		t.Fatalf("Failed to save pending request: %v", err)
+	}
+
+	requests, err := adapter.Query(ctx, &llmtracer.RequestFilter{Model: "gpt-pending"})
+	if err != nil || len(requests) != 0 {
+		t.Errorf("Expected pending requests to be excluded, got %d (err %v)", len(requests), err)
+	}
+	requests, err = adapter.Query(ctx, &llmtracer.RequestFilter{Model: "gpt-pending", InFlight: true})
+	if err != nil || len(requests) != 1 || requests[0].Dimension("user_id") != "alice" {
+		t.Fatalf("Expected the pending request in flight, got %+v (err %v)", requests, err)
+	}
+
+	completed := *requests[0]
+	completed.Pending = false
+	completed.InputTokens = 10
+	completed.StatusCode = 200
+	completed.Latency = 2 * time.Second
+	completed.Dimensions = []llmtracer.DimensionTag{{Key: "user_id", Value: "alice"}, {Key: "feature", Value: "chat"}}
+	if err := adapter.Update(ctx, &completed); err != nil {
+		t.Fatalf("Failed to update request: %v", err)
+	}
+
+	requests, err = adapter.Query(ctx, &llmtracer.RequestFilter{Model: "gpt-pending"})
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("Expected the completed request, got %d (err %v)", len(requests), err)
+	}
+	if got := requests[0]; got.InputTokens != 10 || got.LatencyMs != 2000 || got.Dimension("feature") != "chat" || got.Dimension("user_id") != "alice" || len(got.Dimensions) != 2 {
+		t.Errorf("Expected the completed fields, got %+v", got)
+	}
+	requests, err = adapter.Query(ctx, &llmtracer.RequestFilter{Model: "gpt-pending", InFlight: true})
+	if err != nil || len(requests) != 0 {
+		t.Errorf("Expected nothing in flight, got %d (err %v)", len(requests), err)
+	}
+}
+
+func TestGormAdapterBackfillsLatencyMs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := NewGormAdapter(db); err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	// Databases created before latency_ms stored nanoseconds in latency
+	if err := db.Exec("ALTER TABLE requests ADD COLUMN latency bigint").Error; err != nil {
+		t.Fatalf("Failed to add legacy column: %v", err)
+	}
+	if err := db.Exec("INSERT INTO requests (id, provider, model, latency, latency_ms) VALUES ('legacy', 'openai', 'gpt-4o', 2500000000, 0)").Error; err != nil {
+		t.Fatalf("Failed to insert legacy row: %v", err)
+	}
+
+	adapter, err := NewGormAdapter(db)
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	request, err := adapter.Get(context.Background(), "legacy")
+	if err != nil {
+		t.Fatalf("Failed to get request: %v", err)
+	}
+	if request.LatencyMs != 2500 || request.Latency != 2500*time.Millisecond {
+		t.Errorf("Expected 2500ms latency, got %dms (%v)", request.LatencyMs, request.Latency)
+	}
+}
+
+func TestGormAdapterReadReplica(t *testing.T) {
+	open := func() *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		return db
+	}
+	primaryDB, replicaDB := open(), open()
+
+	// The replica is migrated and written by its own adapter to simulate replication
+	replica, err := NewGormAdapter(replicaDB)
+	if err != nil {
+		t.Fatalf("Failed to create replica adapter: %v", err)
+	}
+	adapter, err := NewGormAdapter(primaryDB, WithReadReplica(replicaDB))
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+
+	ctx := context.Background()
+	request := func() *llmtracer.Request {
+		return &llmtracer.Request{
+			ID:          "replicated",
+			Provider:    llmtracer.ProviderOpenAI,
+			Model:       "gpt-4o",
+			InputTokens: 10,
+			RequestedAt: time.Now(),
+			RespondedAt: time.Now(),
+		}
+	}
+
+	if err := adapter.Save(ctx, request()); err != nil {
+		t.Fatalf("Failed to save request: %v", err)
+	}
+	if _, err := adapter.Get(ctx, "replicated"); err == nil {
+		t.Error("Expected reads to go to the replica before replication")
+	}
+	var written int64
+	primaryDB.Model(&llmtracer.Request{}).Count(&written)
+	if written != 1 {
+		t.Errorf("Expected the write on the primary, got %d rows", written)
+	}
+
+	if err := replica.Save(ctx, request()); err != nil {
+		t.Fatalf("Failed to replicate request: %v", err)
+	}
+	requests, err := adapter.Query(ctx, &llmtracer.RequestFilter{Provider: llmtracer.ProviderOpenAI})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(requests) != 1 {
+		t.Errorf("Expected 1 replicated request, got %d", len(requests))
+	}
+	results, err := adapter.Aggregate(ctx, []string{"model"}, &llmtracer.RequestFilter{})
+	if err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if len(results) != 1 || results[0].TotalInputTokens != 10 {
+		t.Errorf("Expected aggregates from the replica, got %+v", results)
+	}
+
+	if err := adapter.Ping(ctx); err != nil {
+		t.Errorf("Expected both connections to be reachable: %v", err)
+	}
+	if err := adapter.Close(); err != nil {
+		t.Fatalf("Failed to close adapter: %v", err)
+	}
+	if err := adapter.Ping(ctx); err == nil {
+		t.Error("Expected Ping to fail after Close")
+	}
+	sqlDB, err := replicaDB.DB()
+	if err != nil {
+		t.Fatalf("Failed to get replica connection: %v", err)
+	}
+	if err := sqlDB.Ping(); err == nil {
+		t.Error("Expected Close to close the replica")
+	}
+}
+
+func TestGormAdapterTraceIDLookup(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	adapter, err := NewGormAdapter(db)
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	ctx := context.Background()
+	request := &llmtracer.Request{
+		ID:          "traced",
+		TraceID:     llmtracer.NormalizeTraceID("4BF92F3577B34DA6A3CE929D0E0E4736"),
+		Provider:    llmtracer.ProviderOpenAI,
+		Model:       "gpt-4o",
+		RequestedAt: time.Now(),
+		RespondedAt: time.Now(),
+	}
+	if err := adapter.Save(ctx, request); err != nil {
+		t.Fatalf("Failed to save request: %v", err)
+	}
+
+	// The trace ID as other systems report it finds the stored request
+	for _, traceID := range []string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"4BF92F3577B34DA6A3CE929D0E0E4736",
+	} {
+		if requests, err := adapter.GetByTraceID(ctx, traceID); err != nil || len(requests) != 1 {
+			t.Errorf("GetByTraceID(%q): expected 1 request, got %d (%v)", traceID, len(requests), err)
+		}
+		if requests, err := adapter.Query(ctx, &llmtracer.RequestFilter{TraceID: traceID}); err != nil || len(requests) != 1 {
+			t.Errorf("Query with trace ID %q: expected 1 request, got %d (%v)", traceID, len(requests), err)
+		}
+	}
+}
+
+func TestGormAdapterSavedFilters(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	adapter, err := NewGormAdapter(db)
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	ctx := context.Background()
+	hasError := true
+	saved := &llmtracer.SavedFilter{
+		Name:   "prod-errors-24h",
+		Filter: llmtracer.RequestFilter{HasError: &hasError, Dimensions: []llmtracer.DimensionTag{{Key: "env", Value: "prod"}}},
+		Window: 24 * time.Hour,
+	}
+	if err := adapter.SaveFilter(ctx, saved); err != nil {
+		t.Fatalf("Failed to save filter: %v", err)
+	}
+	saved.Description = "Errors in production"
+	saved.Filter.Model = "gpt-4o"
+	if err := adapter.SaveFilter(ctx, saved); err != nil {
+		t.Fatalf("Failed to replace filter: %v", err)
+	}
+	if err := adapter.SaveFilter(ctx, &llmtracer.SavedFilter{Name: "anthropic", Filter: llmtracer.RequestFilter{Provider: llmtracer.ProviderAnthropic}}); err != nil {
+		t.Fatalf("Failed to save filter: %v", err)
+	}
+
+	loaded, err := adapter.GetFilter(ctx, "prod-errors-24h")
+	if err != nil {
+		t.Fatalf("Failed to get filter: %v", err)
+	}
+	if loaded.Description != "Errors in production" || loaded.Filter.Model != "gpt-4o" || loaded.Window != 24*time.Hour {
+		t.Errorf("Expected the replaced filter, got %+v", loaded)
+	}
+	if loaded.Filter.HasError == nil || !*loaded.Filter.HasError || len(loaded.Filter.Dimensions) != 1 {
+		t.Errorf("Expected the filter criteria to round-trip, got %+v", loaded.Filter)
+	}
+
+	filters, err := adapter.ListFilters(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list filters: %v", err)
+	}
+	if len(filters) != 2 || filters[0].Name != "anthropic" || filters[1].Name != "prod-errors-24h" {
+		t.Errorf("Expected filters ordered by name, got %+v", filters)
+	}
+
+	if err := adapter.DeleteFilter(ctx, "anthropic"); err != nil {
+		t.Fatalf("Failed to delete filter: %v", err)
+	}
+	if _, err := adapter.GetFilter(ctx, "anthropic"); err != llmtracer.ErrFilterNotFound {
+		t.Errorf("Expected ErrFilterNotFound for a deleted filter, got %v", err)
+	}
+	if err := adapter.DeleteFilter(ctx, "anthropic"); err != llmtracer.ErrFilterNotFound {
+		t.Errorf("Expected ErrFilterNotFound deleting a missing filter, got %v", err)
+	}
+}
+
+func TestGormAdapterEncryptedSavedFilters(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	adapter, err := NewGormAdapter(db)
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	keys, err := llmtracer.NewStaticKeyProvider("v1", map[string][]byte{"v1": []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		t.Fatalf("Failed to create key provider: %v", err)
+	}
+	client := llmtracer.NewClient(adapter, llmtracer.WithEncryption(keys))
+
+	ctx := context.Background()
+	filter := &llmtracer.RequestFilter{Dimensions: []llmtracer.DimensionTag{{Key: llmtracer.DimensionUserID, Value: "alice@example.com"}}}
+	if err := client.SaveFilter(ctx, "alice", filter); err != nil {
+		t.Fatalf("Failed to save filter: %v", err)
+	}
+
+	var raw string
+	if err := db.Raw("SELECT filter FROM saved_filters WHERE name = ?", "alice").Scan(&raw).Error; err != nil {
+		t.Fatalf("Failed to read the saved filter row: %v", err)
+	}
+	if raw == "" || strings.Contains(raw, "alice@example.com") {
+		t.Errorf("Expected the user ID to be encrypted in the stored filter, got %s", raw)
+	}
+
+	saved, err := client.GetSavedFilter(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Failed to get filter: %v", err)
+	}
+	if len(saved.Filter.Dimensions) != 1 || saved.Filter.Dimensions[0].Value != "alice@example.com" {
+		t.Errorf("Expected the user ID to be decrypted, got %+v", saved.Filter.Dimensions)
+	}
+}
+
+func TestGormAdapterAggregateOrder(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	adapter, err := NewGormAdapter(db)
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	ctx := context.Background()
+	for i, model := range []string{"gpt-4o", "gpt-4o-mini", "gpt-4o-mini", "o1", "gpt-4o"} {
+		costs := map[string]float64{"gpt-4o": 2, "gpt-4o-mini": 0.1, "o1": 15}
+		if err := adapter.Save(ctx, &llmtracer.Request{
+			ID:          fmt.Sprintf("req-%d", i),
+			Provider:    llmtracer.ProviderOpenAI,
+			Model:       model,
+			InputTokens: 100,
+			Cost:        costs[model],
+			RequestedAt: time.Now(),
+		}); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+	}
+
+	top, err := adapter.Aggregate(ctx, []string{"model"}, &llmtracer.RequestFilter{AggregateOrderBy: llmtracer.AggregateOrderCost, AggregateLimit: 2})
+	if err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if len(top) != 2 || top[0].Model != "o1" || top[1].Model != "gpt-4o" {
+		t.Errorf("Expected o1 and gpt-4o by spend, got %+v", top)
+	}
+
+	fewest, err := adapter.Aggregate(ctx, []string{"model"}, &llmtracer.RequestFilter{AggregateOrderBy: llmtracer.AggregateOrderRequests, AggregateAscending: true, AggregateLimit: 1})
+	if err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if len(fewest) != 1 || fewest[0].Model != "o1" {
+		t.Errorf("Expected o1 with the fewest requests, got %+v", fewest)
+	}
+
+	batches, err := adapter.AggregateMulti(ctx, []llmtracer.AggregateSpec{
+		{GroupBy: []string{"model"}, Filter: &llmtracer.RequestFilter{AggregateOrderBy: llmtracer.AggregateOrderTokens, AggregateLimit: 1}},
+		{GroupBy: []string{"provider"}},
 	})
+	if err != nil {
+		t.Fatalf("Failed to aggregate batch: %v", err)
+	}
+	if len(batches[0]) != 1 || batches[0][0].TotalTokens != 200 || len(batches[1]) != 1 {
+		t.Errorf("Expected the model with the most tokens and one provider, got %+v", batches)
+	}
+
+	if _, err := adapter.Aggregate(ctx, []string{"model"}, &llmtracer.RequestFilter{AggregateOrderBy: "cost; --"}); err == nil {
+		t.Error("Expected an unsupported order to be rejected")
+	}
+}
+
+func TestGormAdapterAggregateEfficiency(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	adapter, err := NewGormAdapter(db)
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	ctx := context.Background()
+	for i, latency := range []time.Duration{time.Second, 3 * time.Second} {
+		if err := adapter.Save(ctx, &llmtracer.Request{
+			ID:           fmt.Sprintf("req-%d", i),
+			Provider:     llmtracer.ProviderOpenAI,
+			Model:        "gpt-4o",
+			InputTokens:  1500,
+			OutputTokens: 500,
+			TotalTokens:  2000,
+			Cost:         0.01,
+			Latency:      latency,
+			LatencyMs:    latency.Milliseconds(),
+			RequestedAt:  time.Now(),
+		}); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+	}
+
+	results, err := adapter.Aggregate(ctx, []string{"model"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected one group, got %d", len(results))
+	}
+	if got := results[0].CostPer1KTokens; math.Abs(got-0.005) > 1e-9 {
+		t.Errorf("Expected $0.005 per 1K tokens, got %v", got)
+	}
+	if got := results[0].OutputTokensPerSecond; math.Abs(got-250) > 1e-9 {
+		t.Errorf("Expected 250 output tokens per second, got %v", got)
+	}
 }
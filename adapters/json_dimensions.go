@@ -0,0 +1,171 @@
+package adapters
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// WithJSONDimensions stores the dimensions of each request as a JSON object in a
+// dimensions column of the requests table, instead of the dimension_tags and
+// request_dimensions join tables. Saves become a single insert and dimension filters a
+// predicate on the row, which suits filter-heavy workloads; on Postgres the column is
+// jsonb with a GIN index. The mode is chosen when the adapter is created: existing
+// dimensions are not converted, so switch modes only on a fresh database.
+func WithJSONDimensions() GormOption {
+	return func(a *GormAdapter) {
+		a.jsonDimensions = true
+	}
+}
+
+// dimensionValues is a JSON object of dimension keys and values
+type dimensionValues map[string]string
+
+// GormDBDataType picks a JSON column type for the dialect
+func (dimensionValues) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return "jsonb"
+	case "mysql":
+		return "json"
+	default:
+		return "text"
+	}
+}
+
+// Value encodes the dimensions as a JSON object
+func (d dimensionValues) Value() (driver.Value, error) {
+	if d == nil {
+		d = dimensionValues{}
+	}
+	data, err := json.Marshal(map[string]string(d))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan decodes a JSON object
+func (d *dimensionValues) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("unsupported dimensions type %T", value)
+	}
+	return json.Unmarshal(data, (*map[string]string)(d))
+}
+
+// jsonDimensionsRequest is the row of a request in JSON dimensions mode
+type jsonDimensionsRequest struct {
+	llmtracer.Request
+	DimensionValues dimensionValues `gorm:"column:dimensions"`
+}
+
+func (jsonDimensionsRequest) TableName() string {
+	return "requests"
+}
+
+func newJSONDimensionsRequest(request *llmtracer.Request) *jsonDimensionsRequest {
+	values := make(dimensionValues, len(request.Dimensions))
+	for _, dim := range request.Dimensions {
+		values[dim.Key] = dim.Value
+	}
+	return &jsonDimensionsRequest{Request: *request, DimensionValues: values}
+}
+
+// request returns the stored request with its dimensions sorted by key
+func (r *jsonDimensionsRequest) request() *llmtracer.Request {
+	request := r.Request
+	request.Dimensions = make([]llmtracer.DimensionTag, 0, len(r.DimensionValues))
+	for key, value := range r.DimensionValues {
+		request.Dimensions = append(request.Dimensions, llmtracer.DimensionTag{Key: key, Value: value})
+	}
+	sort.Slice(request.Dimensions, func(i, j int) bool {
+		return request.Dimensions[i].Key < request.Dimensions[j].Key
+	})
+	return &request
+}
+
+// requestModel returns the model the requests table is migrated from
+func (a *GormAdapter) requestModel() interface{} {
+	if a.jsonDimensions {
+		return &jsonDimensionsRequest{}
+	}
+	return &llmtracer.Request{}
+}
+
+// migrationModels returns every model the adapter migrates
+func (a *GormAdapter) migrationModels() []interface{} {
+	if a.jsonDimensions {
+		return []interface{}{&jsonDimensionsRequest{}, &llmtracer.Feedback{}, &llmtracer.ProviderIncident{}, &llmtracer.SavedFilter{}, &llmtracer.RequestRollup{}}
+	}
+	return []interface{}{&llmtracer.DimensionTag{}, &llmtracer.Request{}, &llmtracer.Feedback{}, &llmtracer.ProviderIncident{}, &llmtracer.SavedFilter{}, &llmtracer.RequestRollup{}}
+}
+
+// migrationSession returns a session for migrations with its own copy of the config, so
+// migration settings don't leak into the adapter's connection
+func (a *GormAdapter) migrationSession(configure func(*gorm.Config)) *gorm.DB {
+	config := *a.db.Config
+	// Requests embed the dimension relation even when dimensions are stored as JSON
+	config.IgnoreRelationshipsWhenMigrating = a.jsonDimensions
+	if configure != nil {
+		configure(&config)
+	}
+	session := a.db.Session(&gorm.Session{})
+	session.Config = &config
+	return session
+}
+
+// indexJSONDimensions adds a GIN index on the dimensions column on Postgres
+func (a *GormAdapter) indexJSONDimensions() error {
+	if !a.jsonDimensions || a.db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return a.db.Exec("CREATE INDEX IF NOT EXISTS idx_requests_dimensions ON requests USING GIN (dimensions)").Error
+}
+
+// jsonDimensionMatch returns a condition matching requests whose dimension key has value
+func (a *GormAdapter) jsonDimensionMatch(key, value string) (string, []interface{}) {
+	switch a.db.Dialector.Name() {
+	case "postgres":
+		// Containment uses the GIN index
+		data, _ := json.Marshal(map[string]string{key: value})
+		return "requests.dimensions @> ?", []interface{}{string(data)}
+	default:
+		return a.jsonDimensionExpr() + " = ?", []interface{}{a.jsonDimensionPath(key), value}
+	}
+}
+
+// jsonDimensionExpr returns an expression extracting the value of the dimension key bound
+// to its placeholder
+func (a *GormAdapter) jsonDimensionExpr() string {
+	switch a.db.Dialector.Name() {
+	case "postgres":
+		return "requests.dimensions ->> ?"
+	case "mysql":
+		return "JSON_UNQUOTE(JSON_EXTRACT(requests.dimensions, ?))"
+	default:
+		return "json_extract(requests.dimensions, ?)"
+	}
+}
+
+// jsonDimensionPath returns the placeholder value selecting key in jsonDimensionExpr
+func (a *GormAdapter) jsonDimensionPath(key string) string {
+	if a.db.Dialector.Name() == "postgres" {
+		return key
+	}
+	quoted, _ := json.Marshal(key)
+	return "$." + string(quoted)
+}
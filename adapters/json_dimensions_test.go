@@ -0,0 +1,135 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func TestGormAdapterJSONDimensions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	adapter, err := NewGormAdapter(db, WithJSONDimensions())
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	for _, table := range []string{"dimension_tags", "request_dimensions"} {
+		if db.Migrator().HasTable(table) {
+			t.Errorf("Expected no %s table in JSON mode", table)
+		}
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	requests := []*llmtracer.Request{
+		{ID: "r1", TraceID: "t1", Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o", InputTokens: 10, OutputTokens: 5, Cost: 1, RequestedAt: now,
+			Dimensions: []llmtracer.DimensionTag{{Key: "team", Value: "search"}, {Key: "env", Value: "prod"}}},
+		{ID: "r2", TraceID: "t1", Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o", InputTokens: 20, OutputTokens: 5, Cost: 2, RequestedAt: now,
+			Dimensions: []llmtracer.DimensionTag{{Key: "team", Value: "search"}, {Key: "env", Value: "staging"}}},
+		{ID: "r3", TraceID: "t2", Provider: llmtracer.ProviderAnthropic, Model: "claude", InputTokens: 30, OutputTokens: 5, Cost: 4, RequestedAt: now,
+			Dimensions: []llmtracer.DimensionTag{{Key: "team", Value: "ads"}}},
+		{ID: "r4", TraceID: "t2", Provider: llmtracer.ProviderAnthropic, Model: "claude", InputTokens: 40, OutputTokens: 5, Cost: 8, RequestedAt: now},
+	}
+	for _, request := range requests {
+		if err := adapter.Save(ctx, request); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+	}
+
+	t.Run("Get returns dimensions sorted by key", func(t *testing.T) {
+		got, err := adapter.Get(ctx, "r1")
+		if err != nil {
+			t.Fatalf("Failed to get request: %v", err)
+		}
+		if len(got.Dimensions) != 2 || got.Dimensions[0].Key != "env" || got.Dimension("team") != "search" {
+			t.Errorf("Unexpected dimensions: %+v", got.Dimensions)
+		}
+		if _, err := adapter.Get(ctx, "missing"); err != gorm.ErrRecordNotFound {
+			t.Errorf("Expected ErrRecordNotFound, got %v", err)
+		}
+
+		traced, err := adapter.GetByTraceID(ctx, "t2")
+		if err != nil {
+			t.Fatalf("Failed to get trace: %v", err)
+		}
+		if len(traced) != 2 {
+			t.Errorf("Expected 2 requests, got %d", len(traced))
+		}
+	})
+
+	t.Run("Query filters on several dimensions", func(t *testing.T) {
+		results, err := adapter.Query(ctx, &llmtracer.RequestFilter{
+			Dimensions: []llmtracer.DimensionTag{{Key: "team", Value: "search"}, {Key: "env", Value: "prod"}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != "r1" {
+			t.Errorf("Expected only r1, got %d results", len(results))
+		}
+	})
+
+	t.Run("Aggregate groups by dimension", func(t *testing.T) {
+		results, err := adapter.Aggregate(ctx, []string{llmtracer.GroupByDimension("team")}, &llmtracer.RequestFilter{})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		costs := make(map[string]float64)
+		for _, result := range results {
+			costs[result.Dimension("team")] = result.TotalCost
+		}
+		if costs["search"] != 3 || costs["ads"] != 4 || costs[""] != 8 || len(costs) != 3 {
+			t.Errorf("Unexpected costs by team: %v", costs)
+		}
+	})
+
+	t.Run("AggregateMulti groups by dimension", func(t *testing.T) {
+		results, err := adapter.AggregateMulti(ctx, []llmtracer.AggregateSpec{
+			{GroupBy: []string{llmtracer.GroupByDimension("team")}},
+			{GroupBy: []string{"provider"}, Filter: &llmtracer.RequestFilter{Dimensions: []llmtracer.DimensionTag{{Key: "team", Value: "ads"}}}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		costs := make(map[string]float64)
+		for _, result := range results[0] {
+			costs[result.Dimension("team")] = result.TotalCost
+		}
+		if costs["search"] != 3 || costs["ads"] != 4 || costs[""] != 8 || len(costs) != 3 {
+			t.Errorf("Unexpected costs by team: %v", costs)
+		}
+		var adsCost float64
+		for _, result := range results[1] {
+			adsCost += result.TotalCost
+		}
+		if adsCost != 4 {
+			t.Errorf("Expected ads to cost 4, got %v", adsCost)
+		}
+	})
+
+	t.Run("Update pending request", func(t *testing.T) {
+		testUpdatePending(t, adapter)
+	})
+
+	t.Run("PurgeDeleted", func(t *testing.T) {
+		if err := adapter.Delete(ctx, "r4"); err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+		purged, err := adapter.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to purge: %v", err)
+		}
+		if purged != 1 {
+			t.Errorf("Expected 1 purged request, got %d", purged)
+		}
+	})
+}
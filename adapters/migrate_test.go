@@ -0,0 +1,63 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func TestMigrateBetweenGormAdapters(t *testing.T) {
+	open := func() *GormAdapter {
+		t.Helper()
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		adapter, err := NewGormAdapter(db)
+		if err != nil {
+			t.Fatalf("Failed to create adapter: %v", err)
+		}
+		return adapter
+	}
+	from, to := open(), open()
+	defer from.Close()
+	defer to.Close()
+
+	ctx := context.Background()
+	client := llmtracer.NewClient(from)
+	for _, feature := range []string{"search", "chat", "search"} {
+		if err := client.TrackRequest(llmtracer.WithFeature(ctx, feature), llmtracer.ProviderOpenAI, "gpt-4o", 100, 20, time.Second, nil, nil); err != nil {
+			t.Fatalf("Failed to track request: %v", err)
+		}
+	}
+
+	progress, err := llmtracer.Migrate(ctx, from, to, nil, 2)
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if progress.Copied != 3 {
+		t.Errorf("Expected 3 requests copied, got %+v", progress)
+	}
+
+	migrated, err := to.Query(ctx, &llmtracer.RequestFilter{Dimensions: []llmtracer.DimensionTag{{Key: llmtracer.DimensionFeature, Value: "search"}}})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(migrated) != 2 || migrated[0].Latency != time.Second || migrated[0].InputTokens != 100 {
+		t.Errorf("Expected the search requests with their fields, got %+v", migrated)
+	}
+
+	// Running again finds the requests already copied
+	progress, err = llmtracer.Migrate(ctx, from, to, nil, 2)
+	if err != nil {
+		t.Fatalf("Failed to migrate again: %v", err)
+	}
+	if progress.Copied != 0 || progress.Skipped != 3 {
+		t.Errorf("Expected every request to be skipped, got %+v", progress)
+	}
+}
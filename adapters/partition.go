@@ -0,0 +1,213 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+	"gorm.io/gorm"
+)
+
+// PartitionInterval is the time range covered by each partition of the requests table
+type PartitionInterval string
+
+const (
+	PartitionDaily   PartitionInterval = "daily"
+	PartitionMonthly PartitionInterval = "monthly"
+)
+
+// partitioning configures time-based partitioning of the requests table
+type partitioning struct {
+	interval PartitionInterval
+	premake  int
+}
+
+// WithPartitioning range partitions the requests table by created_at on Postgres, so
+// retention drops whole partitions instead of deleting rows one by one. The adapter
+// creates the partitioned table when it does not exist yet, keeps the current and next
+// premake partitions created, and routes rows outside them to a default partition.
+//
+// PurgeDeleted creates upcoming partitions and drops partitions that ended before the
+// cutoff once all of their requests are soft deleted before it, so WithRetention on the
+// client maintains partitions too. Without retention, call EnsurePartitions periodically.
+//
+// Postgres requires the partition key in the primary key, so the table's primary key
+// is (id, created_at) and request_dimensions has no foreign key to requests. An existing
+// unpartitioned requests table must be migrated manually.
+func WithPartitioning(interval PartitionInterval, premake int) GormOption {
+	return func(a *GormAdapter) {
+		a.partitioning = &partitioning{interval: interval, premake: max(premake, 0)}
+	}
+}
+
+// start returns the start of the partition containing t
+func (p *partitioning) start(t time.Time) time.Time {
+	t = t.UTC()
+	if p.interval == PartitionDaily {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// next returns the start of the partition after the one starting at start
+func (p *partitioning) next(start time.Time) time.Time {
+	if p.interval == PartitionDaily {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// layout formats partition name suffixes
+func (p *partitioning) layout() string {
+	if p.interval == PartitionDaily {
+		return "20060102"
+	}
+	return "200601"
+}
+
+// name returns the table name of the partition starting at start
+func (p *partitioning) name(start time.Time) string {
+	return "requests_p" + start.Format(p.layout())
+}
+
+// parse returns the start of the partition with the given table name, if the adapter
+// created it
+func (p *partitioning) parse(name string) (time.Time, bool) {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	suffix, ok := strings.CutPrefix(strings.Trim(name, `"`), "requests_p")
+	if !ok {
+		return time.Time{}, false
+	}
+	start, err := time.Parse(p.layout(), suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+// partitionBound formats a partition bound as a Postgres timestamptz literal
+func partitionBound(t time.Time) string {
+	return "'" + t.UTC().Format("2006-01-02 15:04:05") + "+00'"
+}
+
+// migratePartitioned creates the requests table partitioned by created_at and then
+// migrates the remaining tables
+func (a *GormAdapter) migratePartitioned() error {
+	if a.partitioning.interval != PartitionDaily && a.partitioning.interval != PartitionMonthly {
+		return fmt.Errorf("invalid partition interval %q", a.partitioning.interval)
+	}
+	if name := a.db.Dialector.Name(); name != "postgres" {
+		return fmt.Errorf("partitioning requires postgres, got %s", name)
+	}
+
+	// Postgres cannot enforce foreign keys to id alone on a partitioned table
+	migrator := a.migrationSession(func(config *gorm.Config) {
+		config.DisableForeignKeyConstraintWhenMigrating = true
+	})
+
+	if !migrator.Migrator().HasTable(&llmtracer.Request{}) {
+		// Let GORM derive the columns, then recreate them as a partitioned table
+		err := migrator.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Table("requests_template").AutoMigrate(a.requestModel()); err != nil {
+				return err
+			}
+			for _, statement := range []string{
+				"CREATE TABLE requests (LIKE requests_template INCLUDING DEFAULTS) PARTITION BY RANGE (created_at)",
+				"ALTER TABLE requests ADD PRIMARY KEY (id, created_at)",
+				"CREATE TABLE requests_default PARTITION OF requests DEFAULT",
+				"DROP TABLE requests_template",
+			} {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create partitioned table: %w", err)
+		}
+	} else {
+		var partitioned int64
+		if err := a.db.Raw("SELECT count(*) FROM pg_partitioned_table WHERE partrelid = to_regclass('requests')").Scan(&partitioned).Error; err != nil {
+			return err
+		}
+		if partitioned == 0 {
+			return fmt.Errorf("requests table exists and is not partitioned")
+		}
+	}
+
+	if err := migrator.AutoMigrate(a.migrationModels()...); err != nil {
+		return err
+	}
+	return a.EnsurePartitions(context.Background(), time.Now())
+}
+
+// EnsurePartitions creates the partition containing now and the configured number of
+// partitions after it. It does nothing unless WithPartitioning is configured.
+func (a *GormAdapter) EnsurePartitions(ctx context.Context, now time.Time) error {
+	if a.partitioning == nil {
+		return nil
+	}
+	start := a.partitioning.start(now)
+	for i := 0; i <= a.partitioning.premake; i++ {
+		end := a.partitioning.next(start)
+		statement := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF requests FOR VALUES FROM (%s) TO (%s)",
+			a.partitioning.name(start), partitionBound(start), partitionBound(end))
+		if err := a.db.WithContext(ctx).Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", a.partitioning.name(start), err)
+		}
+		start = end
+	}
+	return nil
+}
+
+// dropPartitions drops the partitions that ended before the cutoff and hold only
+// requests soft deleted before it, along with their dimension links and feedback
+func (a *GormAdapter) dropPartitions(ctx context.Context, before time.Time) (int64, error) {
+	var names []string
+	if err := a.db.WithContext(ctx).
+		Raw("SELECT inhrelid::regclass::text FROM pg_inherits WHERE inhparent = to_regclass('requests')").
+		Scan(&names).Error; err != nil {
+		return 0, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	var dropped int64
+	for _, name := range names {
+		start, ok := a.partitioning.parse(name)
+		if !ok || a.partitioning.next(start).After(before) {
+			continue
+		}
+		var rows int64
+		err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var live int64
+			if err := tx.Table(name).Where("deleted_at IS NULL OR deleted_at >= ?", before).Count(&live).Error; err != nil {
+				return err
+			}
+			if live > 0 {
+				return nil
+			}
+			if err := tx.Table(name).Count(&rows).Error; err != nil {
+				return err
+			}
+			ids := tx.Table(name).Select("id")
+			if !a.jsonDimensions {
+				if err := tx.Exec("DELETE FROM request_dimensions WHERE request_id IN (?)", ids).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("request_id IN (?)", ids).Delete(&llmtracer.Feedback{}).Error; err != nil {
+				return err
+			}
+			return tx.Exec("DROP TABLE " + name).Error
+		})
+		if err != nil {
+			return dropped, fmt.Errorf("failed to drop partition %s: %w", name, err)
+		}
+		dropped += rows
+	}
+	return dropped, nil
+}
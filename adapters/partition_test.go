@@ -0,0 +1,65 @@
+package adapters
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPartitioning(t *testing.T) {
+	at := time.Date(2024, time.December, 31, 23, 30, 0, 0, time.FixedZone("PST", -8*3600))
+
+	monthly := &partitioning{interval: PartitionMonthly}
+	start := monthly.start(at)
+	if !start.Equal(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected partitions to start on UTC month boundaries, got %v", start)
+	}
+	if name := monthly.name(start); name != "requests_p202501" {
+		t.Errorf("Expected requests_p202501, got %s", name)
+	}
+	if next := monthly.next(start); !next.Equal(time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected February, got %v", next)
+	}
+
+	daily := &partitioning{interval: PartitionDaily}
+	start = daily.start(at)
+	if name := daily.name(start); name != "requests_p20250101" {
+		t.Errorf("Expected requests_p20250101, got %s", name)
+	}
+	if bound := partitionBound(daily.next(start)); bound != "'2025-01-02 00:00:00+00'" {
+		t.Errorf("Unexpected bound %s", bound)
+	}
+
+	for name, ok := range map[string]bool{
+		"requests_p20250101":          true,
+		`public."requests_p20250101"`: true,
+		"requests_default":            false,
+		"requests_p202501":            false,
+	} {
+		parsed, parsedOK := daily.parse(name)
+		if parsedOK != ok {
+			t.Errorf("parse(%q) = %v, expected %v", name, parsedOK, ok)
+		}
+		if ok && !parsed.Equal(start) {
+			t.Errorf("parse(%q) = %v, expected %v", name, parsed, start)
+		}
+	}
+}
+
+func TestPartitioningRequiresPostgres(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = NewGormAdapter(db, WithPartitioning(PartitionMonthly, 2))
+	if err == nil || !strings.Contains(err.Error(), "requires postgres") {
+		t.Errorf("Expected partitioning to require postgres, got %v", err)
+	}
+	_, err = NewGormAdapter(db, WithPartitioning("weekly", 2))
+	if err == nil || !strings.Contains(err.Error(), "invalid partition interval") {
+		t.Errorf("Expected invalid interval error, got %v", err)
+	}
+}
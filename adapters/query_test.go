@@ -0,0 +1,52 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func TestClientQueryCountsWithGorm(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	adapter, err := NewGormAdapter(db)
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	ctx := context.Background()
+	client := llmtracer.NewClient(adapter)
+	for _, feature := range []string{"search", "chat", "search", "search"} {
+		if err := client.TrackRequest(llmtracer.WithFeature(ctx, feature), llmtracer.ProviderOpenAI, "gpt-4o", 10, 5, time.Millisecond, nil, nil); err != nil {
+			t.Fatalf("Failed to track request: %v", err)
+		}
+	}
+
+	filter := &llmtracer.RequestFilter{
+		Dimensions: []llmtracer.DimensionTag{{Key: llmtracer.DimensionFeature, Value: "search"}},
+		Limit:      2,
+	}
+	first, err := client.Query(ctx, filter, &llmtracer.QueryOptions{CountTotal: true})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(first.Requests) != 2 || !first.Truncated || first.Total == nil || *first.Total != 3 {
+		t.Errorf("Expected 2 of 3 search requests, got %+v", first)
+	}
+
+	second, err := client.Query(ctx, filter, &llmtracer.QueryOptions{Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("Failed to query the next page: %v", err)
+	}
+	if len(second.Requests) != 1 || second.Truncated || second.Requests[0].ID == first.Requests[0].ID || second.Requests[0].ID == first.Requests[1].ID {
+		t.Errorf("Expected the last search request, got %+v", second)
+	}
+}
@@ -0,0 +1,107 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// CompactRequests permanently deletes the compacted requests with their dimension links
+// and feedback, then adds rollups to the request_rollups table, in one transaction. If any
+// of the requests is already gone, e.g. because the batch was compacted before, nothing is
+// changed and llmtracer.ErrCompactionConflict is returned.
+func (a *GormAdapter) CompactRequests(ctx context.Context, rollups []*llmtracer.RequestRollup, requestIDs []string) error {
+	return a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(requestIDs) > 0 {
+			if !a.jsonDimensions {
+				if err := tx.Exec("DELETE FROM request_dimensions WHERE request_id IN ?", requestIDs).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("request_id IN ?", requestIDs).Delete(&llmtracer.Feedback{}).Error; err != nil {
+				return err
+			}
+			result := tx.Where("id IN ?", requestIDs).Delete(&llmtracer.Request{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected != int64(len(requestIDs)) {
+				return fmt.Errorf("%w: deleted %d of %d requests", llmtracer.ErrCompactionConflict, result.RowsAffected, len(requestIDs))
+			}
+		}
+
+		if len(rollups) == 0 {
+			return nil
+		}
+		ids := make([]string, len(rollups))
+		for i, rollup := range rollups {
+			ids[i] = rollup.ID
+		}
+		var existing []*llmtracer.RequestRollup
+		if err := tx.Where("id IN ?", ids).Find(&existing).Error; err != nil {
+			return err
+		}
+		stored := make(map[string]*llmtracer.RequestRollup, len(existing))
+		for _, rollup := range existing {
+			stored[rollup.ID] = rollup
+		}
+
+		for _, rollup := range rollups {
+			if current, ok := stored[rollup.ID]; ok {
+				current.Merge(rollup)
+				if err := tx.Save(current).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Create(rollup).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// QueryRollups returns the rollups matching the filter. Dimensions are stored as JSON, so
+// they are matched after the other criteria are applied in SQL.
+func (a *GormAdapter) QueryRollups(ctx context.Context, filter *llmtracer.RequestFilter) ([]*llmtracer.RequestRollup, error) {
+	query := a.readConn(ctx).WithContext(ctx).Model(&llmtracer.RequestRollup{})
+	if filter != nil {
+		if filter.StartTime != nil {
+			query = query.Where("hour >= ?", filter.StartTime.UTC())
+		}
+		if filter.EndTime != nil {
+			query = query.Where("hour <= ?", filter.EndTime.UTC())
+		}
+		if filter.Provider != "" {
+			query = query.Where("provider = ?", filter.Provider)
+		}
+		if filter.Model != "" {
+			query = query.Where("model = ?", filter.Model)
+		}
+		if filter.RequestType != "" {
+			query = query.Where("request_type = ?", filter.RequestType)
+		}
+		if filter.ErrorType != "" {
+			query = query.Where("error_type = ?", filter.ErrorType)
+		}
+	}
+
+	var rollups []*llmtracer.RequestRollup
+	if err := query.Order("hour ASC").Find(&rollups).Error; err != nil {
+		return nil, err
+	}
+	if filter == nil || len(filter.Dimensions) == 0 {
+		return rollups, nil
+	}
+	matching := rollups[:0]
+	for _, rollup := range rollups {
+		if filter.MatchesRollup(rollup) {
+			matching = append(matching, rollup)
+		}
+	}
+	return matching, nil
+}
@@ -0,0 +1,179 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func TestGormAdapterCompaction(t *testing.T) {
+	for _, opts := range [][]GormOption{nil, {WithJSONDimensions()}} {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		adapter, err := NewGormAdapter(db, opts...)
+		if err != nil {
+			t.Fatalf("Failed to create adapter: %v", err)
+		}
+
+		ctx := context.Background()
+		old := time.Now().Add(-72 * time.Hour)
+		for i, feature := range []string{"search", "search", "chat"} {
+			request := &llmtracer.Request{
+				ID:           []string{"a", "b", "c"}[i],
+				Provider:     llmtracer.ProviderOpenAI,
+				Model:        "gpt-4o",
+				InputTokens:  100,
+				OutputTokens: 20,
+				Cost:         0.25,
+				LatencyMs:    300,
+				RequestedAt:  old,
+				Dimensions:   []llmtracer.DimensionTag{{Key: llmtracer.DimensionFeature, Value: feature}},
+			}
+			if err := adapter.Save(ctx, request); err != nil {
+				t.Fatalf("Failed to save request: %v", err)
+			}
+		}
+		if err := adapter.SaveFeedback(ctx, &llmtracer.Feedback{ID: "f1", RequestID: "a", Comment: "good"}); err != nil {
+			t.Fatalf("Failed to save feedback: %v", err)
+		}
+		if err := adapter.Save(ctx, &llmtracer.Request{ID: "recent", Model: "gpt-4o", RequestedAt: time.Now()}); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+
+		client := llmtracer.NewClient(adapter)
+		// Compacting twice adds requests of the same hour to the stored rollup
+		result, err := client.Compact(ctx, llmtracer.CompactionOptions{OlderThan: 24 * time.Hour, BatchSize: 2})
+		if err != nil {
+			t.Fatalf("Failed to compact: %v", err)
+		}
+		if result.Requests != 3 || result.Rollups != 2 {
+			t.Errorf("Expected 3 requests in 2 rollups, got %+v", result)
+		}
+		if err := adapter.Save(ctx, &llmtracer.Request{
+			ID: "late", Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o", InputTokens: 100, RequestedAt: old,
+			Dimensions: []llmtracer.DimensionTag{{Key: llmtracer.DimensionFeature, Value: "search"}},
+		}); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+		if _, err := client.Compact(ctx, llmtracer.CompactionOptions{OlderThan: 24 * time.Hour}); err != nil {
+			t.Fatalf("Failed to compact again: %v", err)
+		}
+
+		remaining, err := adapter.Query(ctx, &llmtracer.RequestFilter{IncludeDeleted: true})
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if len(remaining) != 1 || remaining[0].ID != "recent" {
+			t.Errorf("Expected only the recent request to remain, got %+v", remaining)
+		}
+		feedback, err := adapter.QueryFeedback(ctx, &llmtracer.FeedbackFilter{RequestIDs: []string{"a"}})
+		if err != nil {
+			t.Fatalf("Failed to query feedback: %v", err)
+		}
+		if len(feedback) != 0 {
+			t.Errorf("Expected the feedback of compacted requests to be deleted, got %+v", feedback)
+		}
+
+		start := old.Add(-2 * time.Hour)
+		results, err := client.GetRollupAggregates(ctx, []string{llmtracer.GroupByDimension(llmtracer.DimensionFeature)},
+			&llmtracer.RequestFilter{StartTime: &start, Model: "gpt-4o"})
+		if err != nil {
+			t.Fatalf("Failed to aggregate rollups: %v", err)
+		}
+		totals := make(map[string]*llmtracer.AggregateResult)
+		for _, result := range results {
+			totals[result.Dimension(llmtracer.DimensionFeature)] = result
+		}
+		search := totals["search"]
+		if search == nil || search.TotalRequests != 3 || search.TotalInputTokens != 300 || search.TotalCost != 0.5 {
+			t.Errorf("Expected 3 search requests from both compactions, got %+v", search)
+		}
+		if chat := totals["chat"]; chat == nil || chat.TotalRequests != 1 || chat.AvgLatency != 300*time.Millisecond {
+			t.Errorf("Expected 1 chat request, got %+v", chat)
+		}
+
+		client.Shutdown(ctx)
+	}
+}
+
+func TestGormAdapterCompactionReplay(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	adapter, err := NewGormAdapter(db)
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+
+	ctx := context.Background()
+	old := time.Now().Add(-72 * time.Hour).UTC().Truncate(time.Hour)
+	for _, id := range []string{"a", "b"} {
+		if err := adapter.Save(ctx, &llmtracer.Request{ID: id, Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o", InputTokens: 100, Cost: 0.25, RequestedAt: old}); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+	}
+
+	compact := func() error {
+		rollup := &llmtracer.RequestRollup{ID: "rollup", Hour: old, Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o", Requests: 2, InputTokens: 200, Cost: 0.5}
+		return adapter.CompactRequests(ctx, []*llmtracer.RequestRollup{rollup}, []string{"a", "b"})
+	}
+	if err := compact(); err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	// Replaying the batch, e.g. after reading it from a lagging replica, must not count
+	// the requests twice
+	if err := compact(); !errors.Is(err, llmtracer.ErrCompactionConflict) {
+		t.Errorf("Expected ErrCompactionConflict when compacting again, got %v", err)
+	}
+
+	rollups, err := adapter.QueryRollups(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to query rollups: %v", err)
+	}
+	if len(rollups) != 1 || rollups[0].Requests != 2 || rollups[0].InputTokens != 200 || rollups[0].Cost != 0.5 {
+		t.Errorf("Expected the rollup totals of one compaction, got %+v", rollups)
+	}
+}
+
+func TestGormAdapterCompactionReadsPrimary(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	// An empty replica stands in for one that has not replicated the requests yet
+	replica, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open replica: %v", err)
+	}
+	if _, err := NewGormAdapter(replica); err != nil {
+		t.Fatalf("Failed to migrate replica: %v", err)
+	}
+	adapter, err := NewGormAdapter(db, WithReadReplica(replica))
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := adapter.Save(ctx, &llmtracer.Request{ID: "a", Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o", InputTokens: 100, RequestedAt: time.Now().Add(-72 * time.Hour)}); err != nil {
+		t.Fatalf("Failed to save request: %v", err)
+	}
+
+	client := llmtracer.NewClient(adapter)
+	defer client.Shutdown(ctx)
+	result, err := client.Compact(ctx, llmtracer.CompactionOptions{OlderThan: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	if result.Requests != 1 {
+		t.Errorf("Expected the request on the primary to be compacted, got %+v", result)
+	}
+}
@@ -0,0 +1,109 @@
+package adapters
+
+import (
+	"fmt"
+	"strings"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+	"gorm.io/gorm"
+)
+
+// WithFullTextSearch indexes the error and captured request payload of each request for
+// RequestFilter.SearchText. On Postgres it creates a GIN index on their tsvector; on
+// SQLite it creates an FTS5 table kept in sync by triggers, which needs the driver built
+// with FTS5 (the sqlite_fts5 build tag for mattn/go-sqlite3). Requests saved before the
+// FTS5 table is created are indexed when it is.
+//
+// Without it SearchText still works: Postgres matches the same tsvector without an index,
+// and other databases fall back to LIKE, which also matches terms inside words.
+func WithFullTextSearch() GormOption {
+	return func(a *GormAdapter) {
+		a.fullTextSearch = true
+	}
+}
+
+// searchVectorExpr is the tsvector searched on Postgres; the GIN index is built on the
+// same expression so queries can use it. The simple configuration neither stems words nor
+// drops stop words, which suits error codes and identifiers.
+const searchVectorExpr = "to_tsvector('simple', coalesce(error, '') || ' ' || coalesce(request_payload, ''))"
+
+// indexFullTextSearch creates the search index for WithFullTextSearch
+func (a *GormAdapter) indexFullTextSearch() error {
+	if !a.fullTextSearch {
+		return nil
+	}
+	switch name := a.db.Dialector.Name(); name {
+	case "postgres":
+		return a.db.Exec("CREATE INDEX IF NOT EXISTS idx_requests_search ON requests USING GIN (" + searchVectorExpr + ")").Error
+	case "sqlite":
+		return a.createFTS5Table()
+	default:
+		return fmt.Errorf("full-text search requires postgres or sqlite, got %s", name)
+	}
+}
+
+// createFTS5Table creates the requests_fts table indexing the requests table, and the
+// triggers keeping it in sync
+func (a *GormAdapter) createFTS5Table() error {
+	created := !a.db.Migrator().HasTable("requests_fts")
+	statements := []string{
+		"CREATE VIRTUAL TABLE IF NOT EXISTS requests_fts USING fts5(error, request_payload, content='requests', content_rowid='rowid')",
+		`CREATE TRIGGER IF NOT EXISTS requests_fts_insert AFTER INSERT ON requests BEGIN
+			INSERT INTO requests_fts(rowid, error, request_payload) VALUES (new.rowid, new.error, new.request_payload);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS requests_fts_delete AFTER DELETE ON requests BEGIN
+			INSERT INTO requests_fts(requests_fts, rowid, error, request_payload) VALUES ('delete', old.rowid, old.error, old.request_payload);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS requests_fts_update AFTER UPDATE OF error, request_payload ON requests BEGIN
+			INSERT INTO requests_fts(requests_fts, rowid, error, request_payload) VALUES ('delete', old.rowid, old.error, old.request_payload);
+			INSERT INTO requests_fts(rowid, error, request_payload) VALUES (new.rowid, new.error, new.request_payload);
+		END`,
+	}
+	if created {
+		statements = append(statements, "INSERT INTO requests_fts(requests_fts) VALUES ('rebuild')")
+	}
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// applySearch adds the conditions of filter.SearchText to query
+func (a *GormAdapter) applySearch(query *gorm.DB, text string) *gorm.DB {
+	terms := llmtracer.SearchTerms(text)
+	if len(terms) == 0 {
+		return query
+	}
+
+	switch {
+	case a.db.Dialector.Name() == "postgres":
+		for _, term := range terms {
+			query = query.Where(searchVectorExpr+" @@ phraseto_tsquery('simple', ?)", term)
+		}
+	case a.fullTextSearch:
+		// Quoted terms are matched as phrases, so FTS5 operators in them are not interpreted
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		}
+		query = query.Where("requests.rowid IN (SELECT rowid FROM requests_fts WHERE requests_fts MATCH ?)", strings.Join(quoted, " "))
+	default:
+		// MySQL escapes with a backslash by default and would read '\' as an open string
+		like := `LIKE ? ESCAPE '\'`
+		if a.db.Dialector.Name() == "mysql" {
+			like = "LIKE ?"
+		}
+		for _, term := range terms {
+			pattern := "%" + likeEscaper.Replace(strings.ToLower(term)) + "%"
+			query = query.Where("(LOWER(coalesce(error, '')) "+like+" OR LOWER(coalesce(request_payload, '')) "+like+")", pattern, pattern)
+		}
+	}
+	return query
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,96 @@
+package adapters
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func TestGormAdapterSearchText(t *testing.T) {
+	for _, mode := range []struct {
+		name string
+		opts []GormOption
+	}{
+		{"LIKE", nil},
+		{"FTS5", []GormOption{WithFullTextSearch()}},
+	} {
+		t.Run(mode.name, func(t *testing.T) {
+			db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+			if err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			adapter, err := NewGormAdapter(db, mode.opts...)
+			if err != nil && strings.Contains(err.Error(), "fts5") {
+				t.Skip("SQLite driver built without FTS5; run with -tags sqlite_fts5")
+			}
+			if err != nil {
+				t.Fatalf("Failed to create adapter: %v", err)
+			}
+			defer adapter.Close()
+
+			ctx := context.Background()
+			now := time.Now()
+			for _, request := range []*llmtracer.Request{
+				{ID: "missing", Provider: llmtracer.ProviderOpenAI, Model: "gpt-5", RequestedAt: now, Error: "error code: model_not_found"},
+				{ID: "rate", Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o", RequestedAt: now, Error: "Rate limit reached for gpt-4o"},
+				{ID: "payload", Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o", RequestedAt: now, RequestPayload: `{"messages":[{"content":"Summarize the refund policy"}]}`},
+				{ID: "ok", Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o", RequestedAt: now},
+			} {
+				if err := adapter.Save(ctx, request); err != nil {
+					t.Fatalf("Failed to save request: %v", err)
+				}
+			}
+
+			search := func(text string) []string {
+				t.Helper()
+				requests, err := adapter.Query(ctx, &llmtracer.RequestFilter{SearchText: text})
+				if err != nil {
+					t.Fatalf("Failed to search %q: %v", text, err)
+				}
+				ids := []string{}
+				for _, request := range requests {
+					ids = append(ids, request.ID)
+				}
+				sort.Strings(ids)
+				return ids
+			}
+			for text, want := range map[string]string{
+				"model_not_found":         "missing",
+				`"rate limit" gpt-4o`:     "rate",
+				"RATE LIMIT":              "rate",
+				"refund policy":           "payload",
+				`"refund summarize"`:      "",
+				"model_not_found refund":  "",
+				"gpt-4o OR model":         "",
+				`"limit reached" "rate"`:  "rate",
+				"code: model_not_found  ": "missing",
+			} {
+				if got := strings.Join(search(text), ","); got != want {
+					t.Errorf("Search %q returned %q, want %q", text, got, want)
+				}
+			}
+
+			if err := adapter.Delete(ctx, "missing"); err != nil {
+				t.Fatalf("Failed to delete request: %v", err)
+			}
+			if got := search("model_not_found"); len(got) != 0 {
+				t.Errorf("Expected deleted requests to be excluded, got %v", got)
+			}
+
+			results, err := adapter.Aggregate(ctx, []string{"model"}, &llmtracer.RequestFilter{SearchText: "gpt-4o"})
+			if err != nil {
+				t.Fatalf("Failed to aggregate: %v", err)
+			}
+			if len(results) != 1 || results[0].TotalRequests != 1 {
+				t.Errorf("Expected the search to apply to aggregations, got %+v", results)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func TestShadowStorageJSONDimensions(t *testing.T) {
+	open := func(opts ...GormOption) *GormAdapter {
+		t.Helper()
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		adapter, err := NewGormAdapter(db, opts...)
+		if err != nil {
+			t.Fatalf("Failed to create adapter: %v", err)
+		}
+		return adapter
+	}
+	// Validate the JSON dimensions layout against the default one before switching
+	primary, shadow := open(), open(WithJSONDimensions())
+	storage := llmtracer.NewShadowStorage(primary, shadow)
+	defer storage.Close()
+
+	client := llmtracer.NewClient(storage, llmtracer.WithGlobalDimensions(map[string]any{"env": "prod"}))
+	ctx := llmtracer.WithFeature(llmtracer.WithUserID(context.Background(), "user-1"), "search")
+	start := time.Now().Add(-time.Minute)
+	for i := 0; i < 3; i++ {
+		if err := client.TrackRequest(ctx, llmtracer.ProviderOpenAI, "gpt-4o", 100, int64(i), time.Second, nil, nil); err != nil {
+			t.Fatalf("Failed to track request: %v", err)
+		}
+	}
+
+	report, err := storage.Compare(ctx, llmtracer.ShadowCompareOptions{Start: start, End: time.Now().Add(time.Minute), Interval: time.Minute})
+	if err != nil {
+		t.Fatalf("Failed to compare: %v", err)
+	}
+	if report.Diverged() || report.PrimaryRequests != 3 || report.ShadowRequests != 3 {
+		t.Errorf("Expected matching adapters, got %+v", report)
+	}
+
+	requests, err := shadow.Query(ctx, &llmtracer.RequestFilter{Limit: 1})
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("Failed to query shadow: %v", err)
+	}
+	if err := shadow.Delete(ctx, requests[0].ID); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	report, err = storage.Compare(ctx, llmtracer.ShadowCompareOptions{Start: start, End: time.Now().Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("Failed to compare: %v", err)
+	}
+	if !report.Diverged() || len(report.MissingInShadow) != 1 || report.MissingInShadow[0] != requests[0].ID {
+		t.Errorf("Expected the deleted request to be missing in the shadow, got %+v", report)
+	}
+}
@@ -0,0 +1,77 @@
+//go:build !llmtracer_no_openai
+
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// TestAbortedStreamIsStored checks that a stream whose context was canceled is still saved,
+// which needs tracking to drop the cancellation before reaching a real database
+func TestAbortedStreamIsStored(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	config := openai.DefaultConfig("test-key")
+	config.BaseURL = server.URL + "/v1"
+	openaiClient := openai.NewClientWithConfig(config)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	adapter, err := NewGormAdapter(db)
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	var trackErr error
+	client := llmtracer.NewClient(adapter, llmtracer.WithTrackResultCallback(func(request *llmtracer.Request, err error) {
+		trackErr = err
+	}))
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.TraceOpenAIStream(ctx, openai.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Say hello to everyone"}},
+	}, openaiClient.CreateChatCompletionStream)
+	if err != nil {
+		t.Fatalf("Failed to start stream: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Failed to read the first chunk: %v", err)
+	}
+	cancel()
+	if _, err := stream.Recv(); err == nil {
+		t.Fatal("Expected the canceled stream to fail")
+	}
+	stream.Close()
+
+	if trackErr != nil {
+		t.Fatalf("Failed to save the aborted stream: %v", trackErr)
+	}
+	var stored []*llmtracer.Request
+	if err := db.Find(&stored).Error; err != nil {
+		t.Fatalf("Failed to read requests: %v", err)
+	}
+	if len(stored) != 1 {
+		t.Fatalf("Expected the aborted stream to be stored, got %d rows", len(stored))
+	}
+	if stored[0].StatusCode != llmtracer.StatusClientClosedRequest || stored[0].ErrorType != llmtracer.ErrorTypeAborted {
+		t.Errorf("Expected an aborted request, got status %d and error type %q", stored[0].StatusCode, stored[0].ErrorType)
+	}
+}
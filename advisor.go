@@ -0,0 +1,136 @@
+package llmtracer
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// Thresholds used by GetMaxTokensAdvice
+const (
+	// TruncationAlertRate is the share of requests stopped by max_tokens above which a
+	// dimension value is flagged as truncating
+	TruncationAlertRate = 0.05
+	// maxTokensHeadroom is the margin recommended over the P99 output
+	maxTokensHeadroom = 1.2
+	// maxTokensRounding is the multiple recommendations are rounded up to
+	maxTokensRounding = 64
+)
+
+// MaxTokensAdvice recommends a max_tokens setting for the requests of one dimension value,
+// e.g. one feature, from their observed output tokens and finish reasons
+type MaxTokensAdvice struct {
+	DimensionValue string `json:"dimension_value"`
+	Requests       int64  `json:"requests"`
+	// Truncated counts the requests that stopped because they reached max_tokens
+	Truncated      int64   `json:"truncated"`
+	TruncationRate float64 `json:"truncation_rate"`
+	// ConfiguredMaxTokens is the max_tokens most requests were sent with, or nil when the
+	// parameter was not captured (see WithGenerationParamsCapture)
+	ConfiguredMaxTokens *int `json:"configured_max_tokens,omitempty"`
+	OutputP50           int  `json:"output_p50"`
+	OutputP95           int  `json:"output_p95"`
+	OutputP99           int  `json:"output_p99"`
+	OutputMax           int  `json:"output_max"`
+	// RecommendedMaxTokens leaves headroom over the P99 output, or raises a truncating
+	// limit, whose outputs hide how long the full answers would be
+	RecommendedMaxTokens int `json:"recommended_max_tokens"`
+	// Truncating is set when TruncationRate is at least TruncationAlertRate
+	Truncating bool `json:"truncating"`
+	// Oversized is set when the configured limit is more than twice the recommendation;
+	// such limits reserve rate-limit capacity and let runaway generations run long
+	Oversized bool `json:"oversized"`
+}
+
+// GetMaxTokensAdvice recommends max_tokens settings per value of dimension (e.g. "feature")
+// for the successful chat requests matching filter (nil matches everything), and flags
+// values that routinely hit the length finish reason. Results are ordered by truncation
+// rate, highest first, then by dimension value. Requests tracked before finish reasons
+// were recorded count as not truncated.
+func (c *Client) GetMaxTokensAdvice(ctx context.Context, dimension string, filter *RequestFilter) ([]*MaxTokensAdvice, error) {
+	if filter == nil {
+		filter = &RequestFilter{}
+	}
+	requests, err := c.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		advice     *MaxTokensAdvice
+		outputs    []int
+		configured map[int]int
+	}
+	groups := make(map[string]*group)
+	for _, req := range requests {
+		if req.Error != "" || (req.RequestType != "" && req.RequestType != RequestTypeChat) {
+			continue
+		}
+		value := req.Dimension(dimension)
+		g, ok := groups[value]
+		if !ok {
+			g = &group{advice: &MaxTokensAdvice{DimensionValue: value}, configured: make(map[int]int)}
+			groups[value] = g
+		}
+		g.advice.Requests++
+		g.outputs = append(g.outputs, int(req.OutputTokens))
+		if req.FinishReason == FinishReasonLength {
+			g.advice.Truncated++
+		}
+		if req.Params.MaxTokens != nil {
+			g.configured[*req.Params.MaxTokens]++
+		}
+	}
+
+	results := make([]*MaxTokensAdvice, 0, len(groups))
+	for _, g := range groups {
+		advice := g.advice
+		sort.Ints(g.outputs)
+		advice.OutputP50 = percentile(g.outputs, 50)
+		advice.OutputP95 = percentile(g.outputs, 95)
+		advice.OutputP99 = percentile(g.outputs, 99)
+		advice.OutputMax = g.outputs[len(g.outputs)-1]
+		advice.TruncationRate = float64(advice.Truncated) / float64(advice.Requests)
+		advice.Truncating = advice.TruncationRate >= TruncationAlertRate
+		advice.ConfiguredMaxTokens = mostCommon(g.configured)
+
+		advice.RecommendedMaxTokens = roundUpTokens(float64(advice.OutputP99) * maxTokensHeadroom)
+		if advice.Truncating {
+			limit := advice.OutputMax
+			if advice.ConfiguredMaxTokens != nil {
+				limit = max(limit, *advice.ConfiguredMaxTokens)
+			}
+			advice.RecommendedMaxTokens = max(advice.RecommendedMaxTokens, roundUpTokens(float64(limit)*1.5))
+		}
+		if advice.ConfiguredMaxTokens != nil {
+			advice.Oversized = *advice.ConfiguredMaxTokens > 2*advice.RecommendedMaxTokens
+		}
+		results = append(results, advice)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].TruncationRate != results[j].TruncationRate {
+			return results[i].TruncationRate > results[j].TruncationRate
+		}
+		return results[i].DimensionValue < results[j].DimensionValue
+	})
+	return results, nil
+}
+
+// roundUpTokens rounds a token count up to a multiple of maxTokensRounding, never below it
+func roundUpTokens(tokens float64) int {
+	return max(int(math.Ceil(tokens/maxTokensRounding))*maxTokensRounding, maxTokensRounding)
+}
+
+// mostCommon returns the value counted most often, preferring the larger on ties, or nil
+// when there are none
+func mostCommon(counts map[int]int) *int {
+	var best *int
+	for value, count := range counts {
+		if best == nil || count > counts[*best] || (count == counts[*best] && value > *best) {
+			value := value
+			best = &value
+		}
+	}
+	return best
+}
@@ -0,0 +1,26 @@
+//go:build !llmtracer_no_anthropic
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnthropicFinishReason(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	_, err := client.TraceAnthropicRequest(context.Background(), anthropic.MessageNewParams{Model: "claude-3-5-haiku-latest"}, func(ctx context.Context, body anthropic.MessageNewParams, opts ...option.RequestOption) (*anthropic.Message, error) {
+		return &anthropic.Message{StopReason: anthropic.StopReasonEndTurn}, nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, storage.SaveCalls, 1)
+	assert.Equal(t, FinishReasonStop, storage.SaveCalls[0].Request.FinishReason)
+}
@@ -0,0 +1,26 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIFinishReason(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	_, err := client.TraceOpenAIRequest(context.Background(), openai.ChatCompletionRequest{Model: "gpt-4o"},
+		func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			return openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{{FinishReason: openai.FinishReasonLength}}}, nil
+		})
+	require.NoError(t, err)
+
+	require.Len(t, storage.SaveCalls, 1)
+	assert.Equal(t, FinishReasonLength, storage.SaveCalls[0].Request.FinishReason)
+}
@@ -0,0 +1,85 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMaxTokensAdvice(t *testing.T) {
+	ctx := context.Background()
+	limit := func(n int) GenerationParams { return GenerationParams{MaxTokens: &n} }
+	feature := func(name string) []DimensionTag { return []DimensionTag{{Key: "feature", Value: name}} }
+
+	var requests []*Request
+	// Summaries stay far below a generous limit
+	for i := int64(1); i <= 100; i++ {
+		requests = append(requests, &Request{OutputTokens: 2 * i, FinishReason: FinishReasonStop, Params: limit(4096), Dimensions: feature("summary")})
+	}
+	// One in five answers is cut off at 256 tokens
+	for i := 0; i < 10; i++ {
+		reason, output := FinishReasonStop, int64(150)
+		if i%5 == 0 {
+			reason, output = FinishReasonLength, 256
+		}
+		requests = append(requests, &Request{OutputTokens: output, FinishReason: reason, Params: limit(256), Dimensions: feature("answer")})
+	}
+	// Failed calls and embeddings are ignored
+	requests = append(requests,
+		&Request{OutputTokens: 0, Error: "timeout", Dimensions: feature("answer")},
+		&Request{RequestType: RequestTypeEmbedding, Dimensions: feature("search")},
+	)
+
+	client := NewClient(&MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			return requests, nil
+		},
+	})
+	advice, err := client.GetMaxTokensAdvice(ctx, "feature", nil)
+	require.NoError(t, err)
+	require.Len(t, advice, 2)
+
+	answer := advice[0]
+	assert.Equal(t, "answer", answer.DimensionValue)
+	assert.Equal(t, int64(10), answer.Requests)
+	assert.Equal(t, int64(2), answer.Truncated)
+	assert.InDelta(t, 0.2, answer.TruncationRate, 1e-9)
+	assert.True(t, answer.Truncating)
+	require.NotNil(t, answer.ConfiguredMaxTokens)
+	assert.Equal(t, 256, *answer.ConfiguredMaxTokens)
+	assert.Equal(t, 256, answer.OutputMax)
+	assert.Equal(t, 384, answer.RecommendedMaxTokens, "raised 50% over the truncating limit")
+	assert.False(t, answer.Oversized)
+
+	summary := advice[1]
+	assert.Equal(t, "summary", summary.DimensionValue)
+	assert.False(t, summary.Truncating)
+	assert.Equal(t, 100, summary.OutputP50)
+	assert.Equal(t, 198, summary.OutputP99)
+	assert.Equal(t, 256, summary.RecommendedMaxTokens, "P99 plus headroom, rounded up")
+	assert.True(t, summary.Oversized)
+}
+
+func TestFinishReasonCapture(t *testing.T) {
+	assert.Equal(t, FinishReasonLength, normalizeFinishReason("MAX_TOKENS"))
+	assert.Equal(t, FinishReasonLength, normalizeFinishReason("max_output_tokens"))
+	assert.Equal(t, FinishReasonToolCalls, normalizeFinishReason("tool_use"))
+	assert.Equal(t, "recitation", normalizeFinishReason("RECITATION"))
+
+	// Response bodies and stream events seen by the HTTP transport
+	for body, want := range map[string]string{
+		`{"choices":[{"finish_reason":"length"}]}`:                                    FinishReasonLength,
+		`{"type":"message","stop_reason":"max_tokens"}`:                               FinishReasonLength,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`:                 FinishReasonToolCalls,
+		`{"candidates":[{"finishReason":"SAFETY"}]}`:                                  FinishReasonContentFilter,
+		`{"status":"incomplete","incomplete_details":{"reason":"max_output_tokens"}}`: FinishReasonLength,
+		`{"type":"response.completed","response":{"status":"completed"}}`:             FinishReasonStop,
+		`{"type":"response.created","response":{"status":"in_progress"}}`:             "",
+	} {
+		request := &Request{}
+		require.NoError(t, applyResponseBody(request, []byte(body)))
+		assert.Equal(t, want, request.FinishReason, body)
+	}
+}
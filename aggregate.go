@@ -0,0 +1,109 @@
+package llmtracer
+
+import (
+	"context"
+	"sort"
+)
+
+// AggregateSpec is one aggregation of a batch: the requests matching Filter grouped by
+// GroupBy, as passed to StorageAdapter.Aggregate
+type AggregateSpec struct {
+	GroupBy []string
+	Filter  *RequestFilter
+}
+
+// BatchAggregateStorage is implemented by adapters that can compute several aggregations
+// in one round trip to the backend
+type BatchAggregateStorage interface {
+	// AggregateMulti returns the results of each spec, in the order of specs
+	AggregateMulti(ctx context.Context, specs []AggregateSpec) ([][]*AggregateResult, error)
+}
+
+// GetAggregatesMulti computes several aggregations at once, e.g. every panel of a
+// dashboard, and returns the results of each spec in the order of specs. Adapters
+// implementing BatchAggregateStorage answer the whole batch in one round trip; others are
+// asked for each spec in turn.
+func (c *Client) GetAggregatesMulti(ctx context.Context, specs []AggregateSpec) ([][]*AggregateResult, error) {
+	return aggregateMulti(ctx, c.storage, specs)
+}
+
+// aggregateMulti computes the aggregations of specs on storage, in one call when it
+// implements BatchAggregateStorage
+func aggregateMulti(ctx context.Context, storage StorageAdapter, specs []AggregateSpec) ([][]*AggregateResult, error) {
+	if batch, ok := storage.(BatchAggregateStorage); ok {
+		results, err := batch.AggregateMulti(ctx, specs)
+		if err != nil {
+			return nil, err
+		}
+		// Applied here too, for adapters that don't order or limit aggregates themselves
+		for i := range results {
+			if i < len(specs) {
+				results[i] = SortAggregates(results[i], specs[i].Filter)
+			}
+		}
+		return results, nil
+	}
+	results := make([][]*AggregateResult, len(specs))
+	for i, spec := range specs {
+		specResults, err := storage.Aggregate(ctx, spec.GroupBy, spec.Filter)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = SortAggregates(specResults, spec.Filter)
+	}
+	return results, nil
+}
+
+// AggregateOrder is a total aggregate results can be sorted by, named like the
+// AggregateResult field's JSON key
+type AggregateOrder string
+
+const (
+	AggregateOrderCost     AggregateOrder = "total_cost"
+	AggregateOrderTokens   AggregateOrder = "total_tokens"
+	AggregateOrderRequests AggregateOrder = "total_requests"
+)
+
+// Valid reports whether o is one of the supported orders; the empty order leaves results
+// unsorted
+func (o AggregateOrder) Valid() bool {
+	switch o {
+	case "", AggregateOrderCost, AggregateOrderTokens, AggregateOrderRequests:
+		return true
+	}
+	return false
+}
+
+// value returns the total of result that o sorts by
+func (o AggregateOrder) value(result *AggregateResult) float64 {
+	switch o {
+	case AggregateOrderCost:
+		return result.TotalCost
+	case AggregateOrderTokens:
+		return float64(result.TotalTokens)
+	case AggregateOrderRequests:
+		return float64(result.TotalRequests)
+	}
+	return 0
+}
+
+// SortAggregates applies the AggregateOrderBy, AggregateAscending and AggregateLimit of
+// filter to results, for adapters that aggregate in memory. Results already sorted and
+// limited are returned unchanged, so it is safe to apply twice.
+func SortAggregates(results []*AggregateResult, filter *RequestFilter) []*AggregateResult {
+	if filter == nil {
+		return results
+	}
+	if order := filter.AggregateOrderBy; order != "" && order.Valid() {
+		sort.SliceStable(results, func(i, j int) bool {
+			if filter.AggregateAscending {
+				return order.value(results[i]) < order.value(results[j])
+			}
+			return order.value(results[i]) > order.value(results[j])
+		})
+	}
+	if filter.AggregateLimit > 0 && len(results) > filter.AggregateLimit {
+		results = results[:filter.AggregateLimit]
+	}
+	return results
+}
@@ -0,0 +1,136 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchStorage answers batches in one call
+type batchStorage struct {
+	*MockStorageAdapter
+	batches [][]AggregateSpec
+}
+
+func (s *batchStorage) AggregateMulti(ctx context.Context, specs []AggregateSpec) ([][]*AggregateResult, error) {
+	s.batches = append(s.batches, specs)
+	results := make([][]*AggregateResult, len(specs))
+	for i, spec := range specs {
+		results[i] = []*AggregateResult{{Model: spec.Filter.Model, TotalRequests: int64(i + 1)}}
+	}
+	return results, nil
+}
+
+func TestGetAggregatesMulti(t *testing.T) {
+	ctx := context.Background()
+	specs := []AggregateSpec{
+		{GroupBy: []string{"provider"}, Filter: &RequestFilter{Model: "gpt-4o"}},
+		{GroupBy: []string{GroupByDimension("user_id")}, Filter: &RequestFilter{Model: "claude-3-5-sonnet"}},
+	}
+
+	t.Run("Batches on adapters that support it", func(t *testing.T) {
+		storage := &batchStorage{MockStorageAdapter: &MockStorageAdapter{}}
+		results, err := NewClient(storage).GetAggregatesMulti(ctx, specs)
+		require.NoError(t, err)
+		require.Len(t, storage.batches, 1)
+		require.Len(t, results, 2)
+		assert.Equal(t, "claude-3-5-sonnet", results[1][0].Model)
+	})
+
+	t.Run("Aggregates each spec otherwise", func(t *testing.T) {
+		var calls [][]string
+		storage := &MockStorageAdapter{
+			AggregateFunc: func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+				calls = append(calls, groupBy)
+				return []*AggregateResult{{Model: filter.Model}}, nil
+			},
+		}
+		results, err := NewClient(storage).GetAggregatesMulti(ctx, specs)
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"provider"}, {GroupByDimension("user_id")}}, calls)
+		require.Len(t, results, 2)
+		assert.Equal(t, "gpt-4o", results[0][0].Model)
+
+		_, err = NewClient(&MockStorageAdapter{}).GetAggregatesMulti(ctx, specs)
+		assert.ErrorIs(t, err, ErrAggregateNotSupported)
+	})
+
+	t.Run("Wrappers transform each spec", func(t *testing.T) {
+		storage := &batchStorage{MockStorageAdapter: &MockStorageAdapter{}}
+		secret := []byte("pseudonymization-secret")
+		client := NewClient(storage, WithPseudonymization(secret, "user_id"))
+
+		_, err := client.GetAggregatesMulti(ctx, []AggregateSpec{
+			{Filter: &RequestFilter{Dimensions: []DimensionTag{{Key: "user_id", Value: "alice"}}}},
+		})
+		require.NoError(t, err)
+		require.Len(t, storage.batches, 1, "the batch reaches the wrapped adapter in one call")
+		assert.True(t, isPseudonym(storage.batches[0][0].Filter.Dimensions[0].Value))
+	})
+}
+
+func TestSortAggregates(t *testing.T) {
+	results := func() []*AggregateResult {
+		return []*AggregateResult{
+			{Model: "a", TotalCost: 1, TotalTokens: 300, TotalRequests: 2},
+			{Model: "b", TotalCost: 3, TotalTokens: 100, TotalRequests: 1},
+			{Model: "c", TotalCost: 2, TotalTokens: 200, TotalRequests: 3},
+		}
+	}
+	models := func(results []*AggregateResult) []string {
+		var names []string
+		for _, result := range results {
+			names = append(names, result.Model)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"b", "c"}, models(SortAggregates(results(), &RequestFilter{AggregateOrderBy: AggregateOrderCost, AggregateLimit: 2})))
+	assert.Equal(t, []string{"a", "c", "b"}, models(SortAggregates(results(), &RequestFilter{AggregateOrderBy: AggregateOrderTokens})))
+	assert.Equal(t, []string{"b", "a", "c"}, models(SortAggregates(results(), &RequestFilter{AggregateOrderBy: AggregateOrderRequests, AggregateAscending: true})))
+	assert.Equal(t, []string{"a"}, models(SortAggregates(results(), &RequestFilter{AggregateLimit: 1})))
+	assert.Equal(t, []string{"a", "b", "c"}, models(SortAggregates(results(), nil)))
+
+	assert.True(t, AggregateOrderCost.Valid())
+	assert.False(t, AggregateOrder("cost; DROP TABLE requests").Valid())
+}
+
+func TestGetAggregatesMultiOrdersResults(t *testing.T) {
+	storage := &MockStorageAdapter{
+		AggregateFunc: func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+			// An adapter that ignores the ordering
+			return []*AggregateResult{{Model: "cheap", TotalCost: 1}, {Model: "pricey", TotalCost: 9}, {Model: "mid", TotalCost: 5}}, nil
+		},
+	}
+	results, err := NewClient(storage).GetAggregatesMulti(context.Background(), []AggregateSpec{
+		{GroupBy: []string{"model"}, Filter: &RequestFilter{AggregateOrderBy: AggregateOrderCost, AggregateLimit: 2}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results[0], 2)
+	assert.Equal(t, "pricey", results[0][0].Model)
+	assert.Equal(t, "mid", results[0][1].Model)
+}
+
+func TestComputeEfficiency(t *testing.T) {
+	result := &AggregateResult{
+		TotalRequests:     4,
+		TotalTokens:       10_000,
+		TotalOutputTokens: 2_000,
+		TotalCost:         0.05,
+		AvgLatency:        2500 * time.Millisecond,
+	}
+	result.ComputeEfficiency()
+	assert.InDelta(t, 0.005, result.CostPer1KTokens, 1e-12)
+	assert.InDelta(t, 200, result.OutputTokensPerSecond, 1e-9)
+
+	empty := &AggregateResult{TotalRequests: 1}
+	empty.ComputeEfficiency()
+	assert.Zero(t, empty.CostPer1KTokens)
+	assert.Zero(t, empty.OutputTokensPerSecond)
+
+	var missing *AggregateResult
+	assert.NotPanics(t, missing.ComputeEfficiency)
+}
@@ -0,0 +1,207 @@
+//go:build !llmtracer_no_anthropic
+
+package llmtracer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// AnthropicMessageNewFunc represents the signature of Anthropic's MessageService.New method
+type AnthropicMessageNewFunc func(ctx context.Context, body anthropic.MessageNewParams, opts ...option.RequestOption) (res *anthropic.Message, err error)
+
+// AnthropicCountTokensFunc represents the signature of Anthropic's MessageService.CountTokens method
+type AnthropicCountTokensFunc func(ctx context.Context, body anthropic.MessageCountTokensParams, opts ...option.RequestOption) (*anthropic.MessageTokensCount, error)
+
+// TraceAnthropicRequest wraps Anthropic's MessageService.New and automatically tracks token usage
+func (c *Client) TraceAnthropicRequest(ctx context.Context, params anthropic.MessageNewParams, messageNew AnthropicMessageNewFunc) (*anthropic.Message, error) {
+	if messageNew == nil {
+		return nil, fmt.Errorf("messageNew function cannot be nil")
+	}
+	if err := c.admit(ctx, ProviderAnthropic, string(params.Model)); err != nil {
+		return nil, err
+	}
+	release, queueWait, err := c.acquire(ctx, ProviderAnthropic, string(params.Model))
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := c.startRequest(ctx, &Request{
+		Provider:     ProviderAnthropic,
+		Model:        string(params.Model),
+		MessageCount: len(params.Messages),
+		QueueWait:    queueWait,
+	})
+	startTime := time.Now()
+
+	// Make the actual Anthropic API call using the provided function, capturing the raw
+	// HTTP response so the provider request ID can be recorded
+	var httpResponse *http.Response
+	response, err := messageNew(ctx, params, option.WithResponseInto(&httpResponse))
+	release()
+
+	// Track the request - even if it failed
+	tracked.RequestedAt = startTime
+	tracked.Latency = time.Since(startTime)
+	if err == nil {
+		tracked.SetUsage(AnthropicUsage(response.Usage))
+		tracked.FinishReason = normalizeFinishReason(string(response.StopReason))
+	}
+	applyAnthropicMetadata(tracked, httpResponse, err)
+	if c.captureGenerationParams {
+		tracked.Params = anthropicGenerationParams(params)
+	}
+	if c.capturePayloadSizes {
+		tracked.RequestBytes = jsonSize(params)
+		if err == nil {
+			tracked.ResponseBytes = jsonSize(response)
+		}
+	}
+	if c.capturePayloads {
+		tracked.RequestPayload = jsonString(params)
+	}
+
+	// The raw response is also captured for errors, so rate-limit headers are recorded
+	// on 429s
+	if httpResponse != nil {
+		c.recordRateLimitHeaders(ProviderAnthropic, httpResponse.Header).apply(tracked)
+	}
+
+	// Extract tracking context from context if available
+	trackingContext := GetDimensionsFromContext(ctx)
+
+	c.track(ctx, tracked, err, trackingContext)
+
+	// Return the original response and error
+	return response, err
+}
+
+// TraceAnthropicCountTokens wraps Anthropic's MessageService.CountTokens and tracks the call
+// as a RequestTypeTokenCount request
+func (c *Client) TraceAnthropicCountTokens(ctx context.Context, params anthropic.MessageCountTokensParams, countTokens AnthropicCountTokensFunc) (*anthropic.MessageTokensCount, error) {
+	if countTokens == nil {
+		return nil, fmt.Errorf("countTokens function cannot be nil")
+	}
+	if err := c.admit(ctx, ProviderAnthropic, string(params.Model)); err != nil {
+		return nil, err
+	}
+	release, queueWait, err := c.acquire(ctx, ProviderAnthropic, string(params.Model))
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := c.startRequest(ctx, &Request{
+		Provider:     ProviderAnthropic,
+		Model:        string(params.Model),
+		RequestType:  RequestTypeTokenCount,
+		MessageCount: len(params.Messages),
+		QueueWait:    queueWait,
+	})
+	startTime := time.Now()
+
+	var httpResponse *http.Response
+	response, err := countTokens(ctx, params, option.WithResponseInto(&httpResponse))
+	release()
+
+	tracked.RequestedAt = startTime
+	tracked.Latency = time.Since(startTime)
+	applyAnthropicMetadata(tracked, httpResponse, err)
+
+	if httpResponse != nil {
+		c.recordRateLimitHeaders(ProviderAnthropic, httpResponse.Header).apply(tracked)
+	}
+
+	trackingContext := GetDimensionsFromContext(ctx)
+	if err == nil {
+		trackingContext[DimensionCountedTokens] = response.InputTokens
+	}
+
+	c.track(ctx, tracked, err, trackingContext)
+
+	return response, err
+}
+
+// AnthropicUsage maps an Anthropic usage report to Usage. Anthropic reports cache reads and
+// writes apart from the other input tokens; they are counted as input tokens here.
+func AnthropicUsage(usage anthropic.Usage) Usage {
+	return Usage{
+		InputTokens:       usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens,
+		OutputTokens:      usage.OutputTokens,
+		CachedInputTokens: usage.CacheReadInputTokens,
+	}
+}
+
+// anthropicGenerationParams extracts sampling parameters from Anthropic message parameters
+func anthropicGenerationParams(body anthropic.MessageNewParams) GenerationParams {
+	params := GenerationParams{
+		ToolCount: len(body.Tools),
+	}
+
+	if body.Temperature.Valid() {
+		temperature := body.Temperature.Value
+		params.Temperature = &temperature
+	}
+	if body.TopP.Valid() {
+		topP := body.TopP.Value
+		params.TopP = &topP
+	}
+	if body.MaxTokens > 0 {
+		maxTokens := int(body.MaxTokens)
+		params.MaxTokens = &maxTokens
+	}
+
+	return params
+}
+
+// applyAnthropicMetadata copies the provider request ID, status code and error type from an Anthropic call.
+// Anthropic errors carry a type but no separate code, so ProviderErrorCode stays empty.
+func applyAnthropicMetadata(request *Request, httpResponse *http.Response, err error) {
+	if httpResponse != nil {
+		request.ProviderRequestID = httpResponse.Header.Get("Request-Id")
+		if err != nil {
+			request.RetryAfter = parseRetryAfter(httpResponse.Header, time.Now())
+		}
+	}
+
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		request.StatusCode = apiErr.StatusCode
+		if apiErr.RequestID != "" {
+			request.ProviderRequestID = apiErr.RequestID
+		}
+
+		// Anthropic error bodies look like {"type":"error","error":{"type":"overloaded_error","message":"..."}}
+		var body struct {
+			Error struct {
+				Type string `json:"type"`
+			} `json:"error"`
+		}
+		if json.Unmarshal([]byte(apiErr.RawJSON()), &body) == nil {
+			request.ProviderErrorType = body.Error.Type
+		}
+	}
+}
+
+// AnthropicReplay returns a ReplayFunc that decodes captured Anthropic message payloads
+// and re-issues them through client.TraceAnthropicRequest. A non-empty model replaces the
+// captured one.
+func AnthropicReplay(client *Client, messageNew AnthropicMessageNewFunc, model string) ReplayFunc {
+	return func(ctx context.Context, original *Request) error {
+		var params anthropic.MessageNewParams
+		if err := json.Unmarshal([]byte(original.RequestPayload), &params); err != nil {
+			return fmt.Errorf("failed to decode Anthropic payload: %w", err)
+		}
+		if model != "" {
+			params.Model = anthropic.Model(model)
+		}
+		_, err := client.TraceAnthropicRequest(ctx, params, messageNew)
+		return err
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !llmtracer_no_anthropic
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnthropicCountTokens(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	countTokens := func(ctx context.Context, body anthropic.MessageCountTokensParams, opts ...option.RequestOption) (*anthropic.MessageTokensCount, error) {
+		return &anthropic.MessageTokensCount{InputTokens: 42}, nil
+	}
+	response, err := client.TraceAnthropicCountTokens(context.Background(), anthropic.MessageCountTokensParams{
+		Model: anthropic.ModelClaude3_5SonnetLatest,
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock("hello")),
+		},
+	}, countTokens)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), response.InputTokens)
+
+	require.Len(t, storage.SaveCalls, 1)
+	tracked := storage.SaveCalls[0].Request
+	assert.Equal(t, RequestTypeTokenCount, tracked.RequestType)
+	assert.Equal(t, int64(0), tracked.TotalTokens)
+	assert.Equal(t, 1, tracked.MessageCount)
+	assert.Equal(t, "42", tracked.Dimension(DimensionCountedTokens))
+}
@@ -0,0 +1,28 @@
+//go:build !llmtracer_no_google
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoogleCountTokens(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	countTokens := func(ctx context.Context, parts ...genai.Part) (*genai.CountTokensResponse, error) {
+		return &genai.CountTokensResponse{TotalTokens: 7}, nil
+	}
+	_, err := client.TraceGoogleCountTokens(context.Background(), "gemini-1.5-flash", []genai.Part{genai.Text("hi")}, countTokens)
+	require.NoError(t, err)
+
+	require.Len(t, storage.SaveCalls, 1)
+	tracked := storage.SaveCalls[0].Request
+	assert.Equal(t, RequestTypeTokenCount, tracked.RequestType)
+	assert.Equal(t, "7", tracked.Dimension(DimensionCountedTokens))
+}
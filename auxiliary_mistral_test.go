@@ -0,0 +1,48 @@
+//go:build !llmtracer_no_mistral
+
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mistral "github.com/gage-technologies/mistral-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMistralEmbeddings(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	embeddings := func(model string, input []string) (*mistral.EmbeddingResponse, error) {
+		return &mistral.EmbeddingResponse{
+			ID:    "emb-123",
+			Model: model,
+			Usage: mistral.UsageInfo{PromptTokens: 16, TotalTokens: 16},
+		}, nil
+	}
+	_, err := client.TraceMistralEmbeddings(context.Background(), "mistral-embed", []string{"a", "b"}, embeddings)
+	require.NoError(t, err)
+
+	require.Len(t, storage.SaveCalls, 1)
+	tracked := storage.SaveCalls[0].Request
+	assert.Equal(t, RequestTypeEmbedding, tracked.RequestType)
+	assert.Equal(t, int64(16), tracked.InputTokens)
+	assert.Empty(t, tracked.ProviderRequestID, "the embedding ID identifies the response")
+}
+
+func TestMistralEmbeddingsError(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	embeddings := func(model string, input []string) (*mistral.EmbeddingResponse, error) {
+		return nil, errors.New("(HTTP Error 429) rate limit exceeded")
+	}
+	_, err := client.TraceMistralEmbeddings(context.Background(), "mistral-embed", []string{"a"}, embeddings)
+	assert.Error(t, err)
+
+	require.Len(t, storage.SaveCalls, 1)
+	assert.Equal(t, 429, storage.SaveCalls[0].Request.StatusCode)
+}
@@ -0,0 +1,35 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuxiliaryEndpoints(t *testing.T) {
+
+	t.Run("chat requests default to chat type", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		assert.Equal(t, RequestTypeChat, storage.SaveCalls[0].Request.RequestType)
+	})
+}
+
+func TestTransportRequestType(t *testing.T) {
+	tests := map[string]RequestType{
+		"/v1/chat/completions":                            RequestTypeChat,
+		"/v1/messages":                                    RequestTypeChat,
+		"/v1/messages/count_tokens":                       RequestTypeTokenCount,
+		"/v1beta/models/gemini-1.5-flash:countTokens":     RequestTypeTokenCount,
+		"/v1/embeddings":                                  RequestTypeEmbedding,
+		"/v1beta/models/text-embedding-004:embedContent":  RequestTypeEmbedding,
+		"/v1beta/models/gemini-1.5-flash:generateContent": RequestTypeChat,
+	}
+	for path, want := range tests {
+		assert.Equal(t, want, transportRequestType(path), path)
+	}
+}
@@ -0,0 +1,128 @@
+package llmtracer
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// backoffWindow is how long a throttled response informs SuggestedBackoff
+	backoffWindow = 5 * time.Minute
+	// minBackoff and maxBackoff bound the delay suggested when no Retry-After was given
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// parseRetryAfter returns how long a provider asked callers to wait, from OpenAI's
+// Retry-After-Ms header or the standard Retry-After header in seconds or as an HTTP date.
+// It returns zero when neither is set.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if header == nil {
+		return 0
+	}
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// throttled reports whether a request was turned away for lack of capacity
+func (r *Request) throttled() bool {
+	switch r.ErrorType {
+	case ErrorTypeRateLimit, ErrorTypeOverloaded:
+		return true
+	}
+	return r.StatusCode == http.StatusTooManyRequests
+}
+
+// backoffState is the throttling seen for one provider model
+type backoffState struct {
+	// consecutive counts the throttled responses since the last successful one
+	consecutive int
+	// lastThrottledAt is when the latest throttled response was received
+	lastThrottledAt time.Time
+	// retryAt is when the latest throttled response allows calls again
+	retryAt time.Time
+}
+
+// providerBackoffs holds the throttling seen per provider model
+type providerBackoffs struct {
+	mu     sync.Mutex
+	states map[string]*backoffState
+}
+
+// record updates the throttling of a tracked request's provider model. Successful
+// responses clear it.
+func (b *providerBackoffs) record(request *Request) {
+	key := string(request.Provider) + "/" + request.Model
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if request.Error == "" {
+		delete(b.states, key)
+		return
+	}
+	if !request.throttled() {
+		return
+	}
+	if b.states == nil {
+		b.states = make(map[string]*backoffState)
+	}
+	state, ok := b.states[key]
+	if !ok || request.RespondedAt.Sub(state.lastThrottledAt) > backoffWindow {
+		state = &backoffState{}
+		b.states[key] = state
+	}
+	if request.RespondedAt.Before(state.lastThrottledAt) {
+		// Async tracking can record responses out of order; the newest one decides
+		state.consecutive++
+		return
+	}
+
+	state.consecutive++
+	state.lastThrottledAt = request.RespondedAt
+	delay := request.RetryAfter
+	if delay <= 0 {
+		delay = minBackoff << min(state.consecutive-1, 6)
+		delay = min(delay, maxBackoff)
+	}
+	state.retryAt = request.RespondedAt.Add(delay)
+}
+
+// SuggestedBackoff returns how long to wait before calling a provider model again, from
+// the rate-limited and overloaded responses this client tracked in the last five minutes.
+// When the latest one carried Retry-After, the suggestion is the time left until it
+// elapses; otherwise it is a delay that doubles with each consecutive throttled response,
+// from one second up to a minute. It returns zero once a call succeeds or the wait is over,
+// so retry loops can sleep for it before each attempt:
+//
+//	time.Sleep(tracer.SuggestedBackoff(llmtracer.ProviderOpenAI, "gpt-4o"))
+func (c *Client) SuggestedBackoff(provider Provider, model string) time.Duration {
+	c.backoffs.mu.Lock()
+	defer c.backoffs.mu.Unlock()
+
+	state, ok := c.backoffs.states[string(provider)+"/"+model]
+	if !ok {
+		return 0
+	}
+	now := time.Now()
+	if now.Sub(state.lastThrottledAt) > backoffWindow || !now.Before(state.retryAt) {
+		return 0
+	}
+	return state.retryAt.Sub(now)
+}
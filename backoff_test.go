@@ -0,0 +1,82 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		header   http.Header
+		expected time.Duration
+	}{
+		{http.Header{}, 0},
+		{http.Header{"Retry-After": {"20"}}, 20 * time.Second},
+		{http.Header{"Retry-After": {"1.5"}}, 1500 * time.Millisecond},
+		{http.Header{"Retry-After": {now.Add(time.Minute).Format(http.TimeFormat)}}, time.Minute},
+		{http.Header{"Retry-After": {now.Add(-time.Minute).Format(http.TimeFormat)}}, 0},
+		{http.Header{"Retry-After": {"soon"}}, 0},
+		{http.Header{"Retry-After": {"20"}, "Retry-After-Ms": {"250"}}, 250 * time.Millisecond},
+	} {
+		assert.Equal(t, tt.expected, parseRetryAfter(tt.header, now), "%v", tt.header)
+	}
+}
+
+func TestSuggestedBackoff(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Retry-After from the transport", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"Rate limit reached","type":"requests"}}`)
+		}))
+		defer server.Close()
+
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+		resp, err := NewTracingTransport(client, nil).HTTPClient().Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"gpt-4o","messages":[]}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Len(t, storage.SaveCalls, 1)
+		assert.Equal(t, 30*time.Second, storage.SaveCalls[0].Request.RetryAfter)
+		assert.Equal(t, int64(30_000), storage.SaveCalls[0].Request.RetryAfterMs)
+
+		backoff := client.SuggestedBackoff(ProviderOpenAI, "gpt-4o")
+		assert.InDelta(t, float64(30*time.Second), float64(backoff), float64(time.Second))
+		assert.Zero(t, client.SuggestedBackoff(ProviderOpenAI, "gpt-4o-mini"))
+	})
+
+	t.Run("Doubles without Retry-After", func(t *testing.T) {
+		client := NewClient(&MockStorageAdapter{})
+		rateLimited := errors.New("429 too many requests")
+
+		var backoffs []time.Duration
+		for i := 0; i < 3; i++ {
+			require.NoError(t, client.TrackRequest(ctx, ProviderAnthropic, "claude", 0, 0, 0, rateLimited, nil))
+			backoffs = append(backoffs, client.SuggestedBackoff(ProviderAnthropic, "claude"))
+		}
+		assert.InDelta(t, float64(time.Second), float64(backoffs[0]), float64(100*time.Millisecond))
+		assert.InDelta(t, float64(2*time.Second), float64(backoffs[1]), float64(100*time.Millisecond))
+		assert.InDelta(t, float64(4*time.Second), float64(backoffs[2]), float64(100*time.Millisecond))
+
+		// Other errors leave the backoff alone, and a success clears it
+		require.NoError(t, client.TrackRequest(ctx, ProviderAnthropic, "claude", 0, 0, 0, errors.New("invalid request"), nil))
+		assert.NotZero(t, client.SuggestedBackoff(ProviderAnthropic, "claude"))
+		require.NoError(t, client.TrackRequest(ctx, ProviderAnthropic, "claude", 1, 1, 0, nil, nil))
+		assert.Zero(t, client.SuggestedBackoff(ProviderAnthropic, "claude"))
+	})
+}
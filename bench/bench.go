@@ -0,0 +1,306 @@
+// Package bench measures storage adapter throughput with synthetic workloads. Run it as a
+// load test against real storage to size a deployment, or call Benchmarks from an
+// adapter's tests so `go test -bench` catches performance regressions:
+//
+//	func BenchmarkMyAdapter(b *testing.B) {
+//		bench.Benchmarks(b, func(tb testing.TB) llmtracer.StorageAdapter { return newAdapter(tb) })
+//	}
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// Workload describes the synthetic requests written and read by a run
+type Workload struct {
+	// Requests is the number of requests saved
+	Requests int
+	// Concurrency is the number of goroutines saving requests; defaults to 1
+	Concurrency int
+	// Models are spread over the providers in order; defaults to one model per provider
+	Models []ModelSpec
+	// DimensionKeys are attached to every request, each with DimensionCardinality values
+	DimensionKeys        []string
+	DimensionCardinality int
+	// ErrorRate is the fraction of requests recorded as failed
+	ErrorRate float64
+	// Queries and Aggregates are the number of reads timed after the writes
+	Queries    int
+	Aggregates int
+	// Seed makes the generated requests reproducible
+	Seed int64
+}
+
+// ModelSpec is a provider/model pair used by a workload
+type ModelSpec struct {
+	Provider llmtracer.Provider
+	Model    string
+}
+
+// DefaultWorkload is a mid-sized mix of providers, models and dimensions
+var DefaultWorkload = Workload{
+	Requests:    5000,
+	Concurrency: 4,
+	Models: []ModelSpec{
+		{llmtracer.ProviderOpenAI, "gpt-4o"},
+		{llmtracer.ProviderOpenAI, "gpt-4o-mini"},
+		{llmtracer.ProviderAnthropic, "claude-3-5-sonnet-latest"},
+		{llmtracer.ProviderGoogle, "gemini-1.5-flash"},
+	},
+	DimensionKeys:        []string{"user_id", "feature"},
+	DimensionCardinality: 50,
+	ErrorRate:            0.02,
+	Queries:              100,
+	Aggregates:           20,
+	Seed:                 1,
+}
+
+// Result is the throughput of one operation
+type Result struct {
+	Operation    string
+	Ops          int
+	Duration     time.Duration
+	OpsPerSecond float64
+}
+
+// Report holds the results of a Run
+type Report struct {
+	Save      Result
+	Query     Result
+	Aggregate Result
+}
+
+// String formats the report as a table
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-10s %8s %12s %12s\n", "operation", "ops", "duration", "ops/sec")
+	for _, result := range []Result{r.Save, r.Query, r.Aggregate} {
+		fmt.Fprintf(&b, "%-10s %8d %12s %12.1f\n", result.Operation, result.Ops, result.Duration.Round(time.Millisecond), result.OpsPerSecond)
+	}
+	return b.String()
+}
+
+// GenerateRequests returns the requests of a workload. The same seed always produces the
+// same requests, spread over the last 24 hours.
+func GenerateRequests(w Workload) []*llmtracer.Request {
+	w = w.withDefaults()
+	rng := rand.New(rand.NewSource(w.Seed))
+	now := time.Now()
+
+	requests := make([]*llmtracer.Request, w.Requests)
+	for i := range requests {
+		spec := w.Models[i%len(w.Models)]
+		input := 50 + rng.Int63n(2000)
+		output := 10 + rng.Int63n(800)
+		latency := time.Duration(200+rng.Intn(3000)) * time.Millisecond
+		respondedAt := now.Add(-time.Duration(rng.Int63n(int64(24 * time.Hour))))
+
+		request := &llmtracer.Request{
+			ID:           fmt.Sprintf("bench-%d-%d", w.Seed, i),
+			TraceID:      fmt.Sprintf("bench-trace-%d", i/3),
+			Provider:     spec.Provider,
+			Model:        spec.Model,
+			RequestType:  llmtracer.RequestTypeChat,
+			InputTokens:  input,
+			OutputTokens: output,
+			TotalTokens:  input + output,
+			Cost:         float64(input+output) / 1e6,
+			Latency:      latency,
+			StatusCode:   200,
+			MessageCount: 1 + rng.Intn(10),
+			RequestedAt:  respondedAt.Add(-latency),
+			RespondedAt:  respondedAt,
+		}
+		if rng.Float64() < w.ErrorRate {
+			request.StatusCode = 429
+			request.Error = "rate limit exceeded"
+			request.ErrorType = llmtracer.ErrorTypeRateLimit
+		}
+		for _, key := range w.DimensionKeys {
+			request.Dimensions = append(request.Dimensions, llmtracer.DimensionTag{
+				Key:   key,
+				Value: fmt.Sprintf("%s-%d", key, rng.Intn(w.DimensionCardinality)),
+			})
+		}
+		requests[i] = request
+	}
+	return requests
+}
+
+// Run saves the workload's requests into adapter and then times queries and aggregations
+// over them. Use a dedicated database: the requests are not deleted afterwards.
+func Run(ctx context.Context, adapter llmtracer.StorageAdapter, w Workload) (*Report, error) {
+	w = w.withDefaults()
+	requests := GenerateRequests(w)
+	report := &Report{}
+
+	start := time.Now()
+	if err := saveConcurrently(ctx, adapter, requests, w.Concurrency); err != nil {
+		return nil, err
+	}
+	report.Save = newResult("save", len(requests), time.Since(start))
+
+	filters := readFilters(w)
+	start = time.Now()
+	for i := 0; i < w.Queries; i++ {
+		if _, err := adapter.Query(ctx, filters[i%len(filters)]); err != nil {
+			return nil, fmt.Errorf("query failed: %w", err)
+		}
+	}
+	report.Query = newResult("query", w.Queries, time.Since(start))
+
+	groupBys := aggregateGroupBys(w)
+	start = time.Now()
+	for i := 0; i < w.Aggregates; i++ {
+		if _, err := adapter.Aggregate(ctx, groupBys[i%len(groupBys)], &llmtracer.RequestFilter{}); err != nil {
+			return nil, fmt.Errorf("aggregate failed: %w", err)
+		}
+	}
+	report.Aggregate = newResult("aggregate", w.Aggregates, time.Since(start))
+
+	return report, nil
+}
+
+// Benchmarks runs Save, Query and Aggregate sub-benchmarks against adapters created by
+// newAdapter. Query and Aggregate read a DefaultWorkload-shaped dataset of 1000 requests.
+func Benchmarks(b *testing.B, newAdapter func(tb testing.TB) llmtracer.StorageAdapter) {
+	ctx := context.Background()
+	w := DefaultWorkload
+	w.Requests = 1000
+
+	b.Run("Save", func(b *testing.B) {
+		adapter := newAdapter(b)
+		seed := w
+		seed.Requests = b.N
+		requests := GenerateRequests(seed)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := adapter.Save(ctx, requests[i]); err != nil {
+				b.Fatalf("Save failed: %v", err)
+			}
+		}
+	})
+
+	b.Run("Query", func(b *testing.B) {
+		adapter := seededAdapter(b, newAdapter, w)
+		filters := readFilters(w)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := adapter.Query(ctx, filters[i%len(filters)]); err != nil {
+				b.Fatalf("Query failed: %v", err)
+			}
+		}
+	})
+
+	b.Run("Aggregate", func(b *testing.B) {
+		adapter := seededAdapter(b, newAdapter, w)
+		groupBys := aggregateGroupBys(w)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := adapter.Aggregate(ctx, groupBys[i%len(groupBys)], &llmtracer.RequestFilter{}); err != nil {
+				b.Fatalf("Aggregate failed: %v", err)
+			}
+		}
+	})
+}
+
+func seededAdapter(b *testing.B, newAdapter func(tb testing.TB) llmtracer.StorageAdapter, w Workload) llmtracer.StorageAdapter {
+	b.Helper()
+	adapter := newAdapter(b)
+	if err := saveConcurrently(context.Background(), adapter, GenerateRequests(w), 1); err != nil {
+		b.Fatalf("Failed to seed adapter: %v", err)
+	}
+	return adapter
+}
+
+func (w Workload) withDefaults() Workload {
+	if w.Concurrency < 1 {
+		w.Concurrency = 1
+	}
+	if len(w.Models) == 0 {
+		w.Models = []ModelSpec{
+			{llmtracer.ProviderOpenAI, "gpt-4o"},
+			{llmtracer.ProviderAnthropic, "claude-3-5-sonnet-latest"},
+			{llmtracer.ProviderGoogle, "gemini-1.5-flash"},
+			{llmtracer.ProviderMistral, "mistral-large-latest"},
+		}
+	}
+	if w.DimensionCardinality < 1 {
+		w.DimensionCardinality = 1
+	}
+	return w
+}
+
+// saveConcurrently saves requests from the given number of goroutines, returning the
+// first error
+func saveConcurrently(ctx context.Context, adapter llmtracer.StorageAdapter, requests []*llmtracer.Request, concurrency int) error {
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	work := make(chan *llmtracer.Request)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for request := range work {
+				if err := adapter.Save(ctx, request); err != nil {
+					once.Do(func() { firstErr = fmt.Errorf("save failed: %w", err) })
+				}
+			}
+		}()
+	}
+	for _, request := range requests {
+		work <- request
+	}
+	close(work)
+	wg.Wait()
+	return firstErr
+}
+
+// readFilters are the queries a dashboard typically issues: recent requests, per model,
+// per dimension value and errors only
+func readFilters(w Workload) []*llmtracer.RequestFilter {
+	since := time.Now().Add(-time.Hour)
+	hasError := true
+	filters := []*llmtracer.RequestFilter{
+		{StartTime: &since, Limit: 100},
+		{Provider: w.Models[0].Provider, Model: w.Models[0].Model, Limit: 100},
+		{HasError: &hasError, Limit: 100},
+	}
+	if len(w.DimensionKeys) > 0 {
+		key := w.DimensionKeys[0]
+		filters = append(filters, &llmtracer.RequestFilter{
+			Dimensions: []llmtracer.DimensionTag{{Key: key, Value: key + "-0"}},
+			Limit:      100,
+		})
+	}
+	return filters
+}
+
+func aggregateGroupBys(w Workload) [][]string {
+	groupBys := [][]string{{"provider", "model"}}
+	if len(w.DimensionKeys) > 0 {
+		groupBys = append(groupBys, []string{llmtracer.GroupByDimension(w.DimensionKeys[0])})
+	}
+	return groupBys
+}
+
+func newResult(operation string, ops int, duration time.Duration) Result {
+	result := Result{Operation: operation, Ops: ops, Duration: duration}
+	if duration > 0 {
+		result.OpsPerSecond = float64(ops) / duration.Seconds()
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package bench
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+	"github.com/propel-gtm/llm-request-tracer/adapters"
+)
+
+func newSQLiteAdapter(tb testing.TB) llmtracer.StorageAdapter {
+	tb.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		tb.Fatalf("Failed to open database: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	adapter, err := adapters.NewGormAdapter(db)
+	if err != nil {
+		tb.Fatalf("Failed to create adapter: %v", err)
+	}
+	tb.Cleanup(func() { adapter.Close() })
+	return adapter
+}
+
+func TestGenerateRequests(t *testing.T) {
+	w := DefaultWorkload
+	w.Requests = 50
+
+	first := GenerateRequests(w)
+	second := GenerateRequests(w)
+	if len(first) != 50 {
+		t.Fatalf("Expected 50 requests, got %d", len(first))
+	}
+	for i := range first {
+		if first[i].ID != second[i].ID || first[i].InputTokens != second[i].InputTokens ||
+			first[i].Dimensions[0].Value != second[i].Dimensions[0].Value {
+			t.Fatalf("Expected the same seed to generate the same requests at %d", i)
+		}
+		if len(first[i].Dimensions) != len(w.DimensionKeys) {
+			t.Errorf("Expected %d dimensions, got %d", len(w.DimensionKeys), len(first[i].Dimensions))
+		}
+	}
+
+	w.Seed = 2
+	if GenerateRequests(w)[0].ID == first[0].ID {
+		t.Error("Expected a different seed to generate different IDs")
+	}
+}
+
+func TestRun(t *testing.T) {
+	w := DefaultWorkload
+	w.Requests = 200
+	w.Queries = 8
+	w.Aggregates = 4
+
+	report, err := Run(context.Background(), newSQLiteAdapter(t), w)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Save.Ops != 200 || report.Query.Ops != 8 || report.Aggregate.Ops != 4 {
+		t.Errorf("Unexpected op counts: %+v", report)
+	}
+	if report.Save.OpsPerSecond <= 0 {
+		t.Errorf("Expected positive save throughput, got %f", report.Save.OpsPerSecond)
+	}
+	if !strings.Contains(report.String(), "aggregate") {
+		t.Errorf("Expected report table, got %q", report.String())
+	}
+}
@@ -0,0 +1,115 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrBudgetExceeded is returned by the trace wrappers when the trace budget of the context
+// is spent
+var ErrBudgetExceeded = errors.New("trace budget exceeded")
+
+const budgetKey contextKey = "llm_budget"
+
+// Budget accumulates the cost of the calls made with a context, e.g. every iteration of an
+// agent loop, and stops the loop once the cost reaches its limit. A Budget is safe for
+// concurrent use.
+type Budget struct {
+	maxCost float64
+	parent  *Budget
+
+	mu    sync.Mutex
+	spent float64
+}
+
+// TraceBudget returns a context whose calls share a running cost of at most maxCost USD.
+// Every tracked call made with the context, or one derived from it, adds its cost, and
+// once the total reaches maxCost the trace wrappers return ErrBudgetExceeded instead of
+// calling the provider. The call that crosses the limit is not interrupted, so spend can
+// overshoot by one call. Costs come from RequestOptions or the client's pricing; calls
+// without a known cost count as free.
+//
+// Unless ctx already carries a trace ID, a new one is set so the calls share a trace.
+// Budgets nest: a call is charged to every enclosing budget and rejected when any of them
+// is spent.
+func TraceBudget(ctx context.Context, maxCost float64) (context.Context, *Budget) {
+	budget := &Budget{maxCost: maxCost, parent: budgetFromContext(ctx)}
+	if traceID, ok := ctx.Value(traceIDKey).(string); !ok || traceID == "" {
+		ctx = WithTraceID(ctx, GetTraceIDFromContext(nil))
+	}
+	return context.WithValue(ctx, budgetKey, budget), budget
+}
+
+// budgetFromContext returns the innermost budget of ctx, or nil
+func budgetFromContext(ctx context.Context) *Budget {
+	if ctx == nil {
+		return nil
+	}
+	budget, _ := ctx.Value(budgetKey).(*Budget)
+	return budget
+}
+
+// MaxCost returns the budget's limit
+func (b *Budget) MaxCost() float64 {
+	return b.maxCost
+}
+
+// Spent returns the cost charged to the budget so far
+func (b *Budget) Spent() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
+
+// Remaining returns the cost left before the budget is spent, never less than zero
+func (b *Budget) Remaining() float64 {
+	return max(b.maxCost-b.Spent(), 0)
+}
+
+// Exceeded reports whether the budget, or an enclosing one, is spent
+func (b *Budget) Exceeded() bool {
+	return b.check() != nil
+}
+
+// Allows reports whether a call costing cost fits in the remaining budget and every
+// enclosing one; use it with EstimateCost to skip calls that would overshoot
+func (b *Budget) Allows(cost float64) bool {
+	for budget := b; budget != nil; budget = budget.parent {
+		if budget.Spent()+cost > budget.maxCost {
+			return false
+		}
+	}
+	return true
+}
+
+// charge adds cost to the budget and every enclosing one
+func (b *Budget) charge(cost float64) {
+	for budget := b; budget != nil; budget = budget.parent {
+		budget.mu.Lock()
+		budget.spent += cost
+		budget.mu.Unlock()
+	}
+}
+
+// check returns ErrBudgetExceeded when the budget or an enclosing one is spent
+func (b *Budget) check() error {
+	for budget := b; budget != nil; budget = budget.parent {
+		if spent := budget.Spent(); spent >= budget.maxCost {
+			return fmt.Errorf("%w: spent $%.4f of $%.4f", ErrBudgetExceeded, spent, budget.maxCost)
+		}
+	}
+	return nil
+}
+
+// chargeBudget charges the cost of a tracked request to the budget of ctx. It runs in the
+// caller's goroutine, so the next call of a loop sees the updated spend even when tracking
+// is asynchronous.
+func (c *Client) chargeBudget(ctx context.Context, request *Request) {
+	budget := budgetFromContext(ctx)
+	if budget == nil {
+		return
+	}
+	budget.charge(c.requestCost(request))
+}
@@ -0,0 +1,92 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceBudget(t *testing.T) {
+	mock, _ := newRecordingStorage()
+	pricing := NewPricingRegistry()
+	pricing.Set(ProviderOpenAI, "gpt-4o", ModelPrice{InputPerMillion: 2000, OutputPerMillion: 8000})
+	client := NewClient(mock, WithPricing(pricing))
+
+	ctx, budget := TraceBudget(context.Background(), 1)
+	assert.NotEmpty(t, GetTraceIDFromContext(ctx))
+	assert.Equal(t, 1.0, budget.MaxCost())
+	assert.Equal(t, 1.0, budget.Remaining())
+
+	calls := 0
+	openAIFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+		calls++
+		// $0.20 + $0.16 per call
+		return openai.ChatCompletionResponse{Usage: openai.Usage{PromptTokens: 100, CompletionTokens: 20}}, nil
+	}
+
+	// An agent loop runs until the budget stops it
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		_, err = client.TraceOpenAIRequest(ctx, openai.ChatCompletionRequest{Model: "gpt-4o"}, openAIFunc)
+	}
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBudgetExceeded))
+	assert.Equal(t, 3, calls, "the third call crosses the limit")
+	assert.InDelta(t, 1.08, budget.Spent(), 1e-9)
+	assert.Zero(t, budget.Remaining())
+	assert.True(t, budget.Exceeded())
+
+	requests, err := mock.Query(context.Background(), &RequestFilter{})
+	require.NoError(t, err)
+	assert.Len(t, requests, 3, "rejected calls are not tracked")
+	for _, req := range requests {
+		assert.Equal(t, GetTraceIDFromContext(ctx), req.TraceID)
+	}
+
+	t.Run("explicit costs are charged", func(t *testing.T) {
+		ctx, budget := TraceBudget(WithTraceID(context.Background(), "trace-1"), 0.5)
+		assert.Equal(t, "trace-1", GetTraceIDFromContext(ctx))
+		cost := 0.2
+		require.NoError(t, client.TrackRequest(ctx, ProviderAnthropic, "claude", 10, 10, time.Second, nil, &RequestOptions{Cost: &cost}))
+		assert.InDelta(t, 0.2, budget.Spent(), 1e-9)
+		assert.False(t, budget.Exceeded())
+	})
+
+	t.Run("regional prices are charged", func(t *testing.T) {
+		regional := NewPricingRegistry()
+		regional.Set(ProviderOpenAI, "gpt-4o", ModelPrice{
+			InputPerMillion: 2000,
+			Regions:         map[string]ModelPrice{"eu": {InputPerMillion: 4000}},
+		})
+		client := NewClient(mock, WithPricing(regional), WithGlobalDimensions(map[string]any{DimensionRegion: "eu"}))
+
+		ctx, budget := TraceBudget(context.Background(), 10)
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 0, time.Second, nil, nil))
+		assert.InDelta(t, 0.4, budget.Spent(), 1e-9)
+	})
+
+	t.Run("nested budgets", func(t *testing.T) {
+		outer, session := TraceBudget(context.Background(), 0.5)
+		inner, task := TraceBudget(outer, 10)
+		assert.Equal(t, GetTraceIDFromContext(outer), GetTraceIDFromContext(inner))
+
+		_, err := client.TraceOpenAIRequest(inner, openai.ChatCompletionRequest{Model: "gpt-4o"}, openAIFunc)
+		require.NoError(t, err)
+		_, err = client.TraceOpenAIRequest(inner, openai.ChatCompletionRequest{Model: "gpt-4o"}, openAIFunc)
+		require.NoError(t, err)
+		assert.InDelta(t, 0.72, session.Spent(), 1e-9)
+		assert.InDelta(t, 0.72, task.Spent(), 1e-9)
+		assert.False(t, task.Remaining() == 0)
+		assert.True(t, task.Exceeded(), "the enclosing budget is spent")
+
+		_, err = client.TraceOpenAIRequest(inner, openai.ChatCompletionRequest{Model: "gpt-4o"}, openAIFunc)
+		assert.True(t, errors.Is(err, ErrBudgetExceeded))
+	})
+}
@@ -0,0 +1,181 @@
+package llmtracer
+
+import (
+	"sort"
+	"time"
+)
+
+// BurnRateWindow names one of the two windows of a burn-rate alert
+type BurnRateWindow string
+
+const (
+	// BurnRateWindowFast is the short window that catches sharp spikes
+	BurnRateWindowFast BurnRateWindow = "fast"
+	// BurnRateWindowSlow is the long window that catches sustained degradation
+	BurnRateWindowSlow BurnRateWindow = "slow"
+)
+
+// BurnRateAlert alerts when one error type spends an error budget too fast. The burn rate
+// is the observed error rate divided by the budget: at a burn rate of 1 the budget lasts
+// exactly as planned. A high threshold over a short window pages on spikes quickly, and a
+// lower threshold over a long window catches degradation too mild for the short window,
+// which a single error-rate threshold would either page on too often or miss.
+type BurnRateAlert struct {
+	Name string `json:"name"`
+	// Provider selects the requests covered; when empty, each provider is tracked and
+	// alerted on separately
+	Provider Provider `json:"provider,omitempty"`
+	// ErrorType is the error that spends the budget, e.g. ErrorTypeRateLimit
+	ErrorType ErrorType `json:"error_type"`
+	// ErrorBudget is the fraction (0-1) of requests allowed to fail with ErrorType
+	ErrorBudget float64 `json:"error_budget"`
+	// FastWindow and FastBurnRate default to 5 minutes and 14.4
+	FastWindow   time.Duration `json:"fast_window"`
+	FastBurnRate float64       `json:"fast_burn_rate"`
+	// SlowWindow and SlowBurnRate default to 6 hours and 6
+	SlowWindow   time.Duration `json:"slow_window"`
+	SlowBurnRate float64       `json:"slow_burn_rate"`
+	// MinRequests is the number of requests a window needs before it can breach; defaults
+	// to 10
+	MinRequests int64 `json:"min_requests"`
+}
+
+// matches reports whether the alert covers request
+func (a *BurnRateAlert) matches(request *Request) bool {
+	return a.Provider == "" || a.Provider == request.Provider
+}
+
+// BurnRateStatus is the burn rate of an alert's error budget over one of its windows
+type BurnRateStatus struct {
+	Alert    BurnRateAlert  `json:"alert"`
+	Provider Provider       `json:"provider"`
+	Window   BurnRateWindow `json:"window"`
+	Requests int64          `json:"requests"`
+	Errors   int64          `json:"errors"`
+	// ErrorRate is the fraction of requests that failed with the alert's error type
+	ErrorRate float64 `json:"error_rate"`
+	BurnRate  float64 `json:"burn_rate"`
+	// Threshold is the burn rate the window breaches at
+	Threshold float64 `json:"threshold"`
+	Breached  bool    `json:"breached"`
+}
+
+// WithBurnRateAlert adds an error budget burn-rate alert to the monitor. Breaches and
+// recoveries of each window are reported as separate events, so e.g. fast-window breaches
+// can page while slow-window breaches open a ticket.
+func WithBurnRateAlert(alert BurnRateAlert) SLOOption {
+	return func(m *SLOMonitor) {
+		if alert.FastWindow <= 0 {
+			alert.FastWindow = 5 * time.Minute
+		}
+		if alert.FastBurnRate <= 0 {
+			alert.FastBurnRate = 14.4
+		}
+		if alert.SlowWindow <= 0 {
+			alert.SlowWindow = 6 * time.Hour
+		}
+		if alert.SlowBurnRate <= 0 {
+			alert.SlowBurnRate = 6
+		}
+		if alert.MinRequests <= 0 {
+			alert.MinRequests = 10
+		}
+		m.burnRates = append(m.burnRates, &burnRateAlert{
+			alert:     alert,
+			providers: make(map[Provider][]*burnRateWindow),
+		})
+	}
+}
+
+// burnRateAlert holds the windows of an alert per provider
+type burnRateAlert struct {
+	alert     BurnRateAlert
+	providers map[Provider][]*burnRateWindow
+}
+
+// burnRateWindow is one window of an alert for one provider
+type burnRateWindow struct {
+	kind      BurnRateWindow
+	threshold float64
+	window    rollingWindow
+	breached  bool
+}
+
+// windows returns the fast and slow windows of provider
+func (a *burnRateAlert) windows(provider Provider) []*burnRateWindow {
+	windows, ok := a.providers[provider]
+	if !ok {
+		windows = []*burnRateWindow{
+			{kind: BurnRateWindowFast, threshold: a.alert.FastBurnRate, window: newRollingWindow(a.alert.FastWindow)},
+			{kind: BurnRateWindowSlow, threshold: a.alert.SlowBurnRate, window: newRollingWindow(a.alert.SlowWindow)},
+		}
+		a.providers[provider] = windows
+	}
+	return windows
+}
+
+// record counts a request in both windows of its provider
+func (a *burnRateAlert) record(request *Request, now time.Time) {
+	failed := request.ErrorType == a.alert.ErrorType
+	for _, w := range a.windows(request.Provider) {
+		w.window.add(request.RequestedAt, now, failed)
+	}
+}
+
+// observe records a request and returns an event for every window of its provider that
+// entered or left breach
+func (a *burnRateAlert) observe(request *Request, now time.Time) []SLOEvent {
+	a.record(request, now)
+
+	var events []SLOEvent
+	for _, w := range a.windows(request.Provider) {
+		status := a.status(request.Provider, w, now)
+		if status.Breached == w.breached {
+			continue
+		}
+		w.breached = status.Breached
+		event := SLOEvent{Type: SLOEventBreach, BurnRate: &status, At: now}
+		if !status.Breached {
+			event.Type = SLOEventRecovered
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// status returns the burn rate of one window
+func (a *burnRateAlert) status(provider Provider, w *burnRateWindow, now time.Time) BurnRateStatus {
+	status := BurnRateStatus{Alert: a.alert, Provider: provider, Window: w.kind, Threshold: w.threshold}
+	status.Requests, status.Errors = w.window.counts(now)
+	if status.Requests > 0 {
+		status.ErrorRate = float64(status.Errors) / float64(status.Requests)
+	}
+	if a.alert.ErrorBudget > 0 {
+		status.BurnRate = status.ErrorRate / a.alert.ErrorBudget
+	}
+	status.Breached = status.Requests >= a.alert.MinRequests && status.BurnRate >= w.threshold
+	return status
+}
+
+// BurnRates returns the burn rates of every alert's windows, per provider seen so
+// far, in the order the alerts were added and then by provider, fast window first
+func (m *SLOMonitor) BurnRates() []BurnRateStatus {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var statuses []BurnRateStatus
+	for _, alert := range m.burnRates {
+		providers := make([]Provider, 0, len(alert.providers))
+		for provider := range alert.providers {
+			providers = append(providers, provider)
+		}
+		sort.Slice(providers, func(i, j int) bool { return providers[i] < providers[j] })
+		for _, provider := range providers {
+			for _, w := range alert.providers[provider] {
+				statuses = append(statuses, alert.status(provider, w, now))
+			}
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,118 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBurnRateAlert(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	var events []SLOEvent
+	monitor := NewSLOMonitor(NewClient(&MockStorageAdapter{}),
+		WithBurnRateAlert(BurnRateAlert{Name: "rate-limits", ErrorType: ErrorTypeRateLimit, ErrorBudget: 0.01, SlowWindow: time.Hour}),
+		WithSLOHook(func(event SLOEvent) { events = append(events, event) }),
+	)
+	monitor.now = func() time.Time { return now }
+	observe := func(provider Provider, errorType ErrorType) {
+		monitor.observe(&Request{Provider: provider, ErrorType: errorType, RequestedAt: now})
+	}
+
+	t.Run("Sustained degradation breaches the slow window only", func(t *testing.T) {
+		events = nil
+		// 9% of requests over 50 minutes, never more than one error per 5 minutes
+		for i := 0; i < 100; i++ {
+			errorType := ErrorTypeNone
+			if i%12 == 0 {
+				errorType = ErrorTypeRateLimit
+			}
+			observe(ProviderAnthropic, errorType)
+			now = now.Add(30 * time.Second)
+		}
+
+		require.Len(t, events, 1)
+		status := events[0].BurnRate
+		require.NotNil(t, status)
+		assert.Equal(t, SLOEventBreach, events[0].Type)
+		assert.Equal(t, ProviderAnthropic, status.Provider)
+		assert.Equal(t, BurnRateWindowSlow, status.Window)
+		assert.Equal(t, 6.0, status.Threshold)
+	})
+
+	t.Run("Spikes breach the fast window and recover", func(t *testing.T) {
+		events = nil
+		for i := 0; i < 10; i++ {
+			observe(ProviderOpenAI, ErrorTypeNone)
+		}
+		// Other error types don't spend the budget
+		observe(ProviderOpenAI, ErrorTypeServerError)
+		assert.Empty(t, events)
+		for i := 0; i < 10; i++ {
+			observe(ProviderOpenAI, ErrorTypeRateLimit)
+		}
+
+		require.Len(t, events, 2)
+		assert.Equal(t, BurnRateWindowSlow, events[0].BurnRate.Window)
+		assert.Equal(t, BurnRateWindowFast, events[1].BurnRate.Window)
+		assert.Equal(t, ProviderOpenAI, events[1].BurnRate.Provider)
+		assert.Equal(t, 14.4, events[1].BurnRate.Threshold)
+
+		statuses := monitor.BurnRates()
+		require.Len(t, statuses, 4)
+		assert.Equal(t, ProviderAnthropic, statuses[0].Provider)
+		fast := statuses[2]
+		assert.Equal(t, BurnRateWindowFast, fast.Window)
+		assert.Equal(t, int64(21), fast.Requests)
+		assert.Equal(t, int64(10), fast.Errors)
+		assert.InDelta(t, 10.0/21/0.01, fast.BurnRate, 1e-9)
+		assert.True(t, fast.Breached)
+
+		// The spike leaves the fast window but not the slow one
+		events = nil
+		now = now.Add(10 * time.Minute)
+		observe(ProviderOpenAI, ErrorTypeNone)
+		require.Len(t, events, 1)
+		assert.Equal(t, SLOEventRecovered, events[0].Type)
+		assert.Equal(t, BurnRateWindowFast, events[0].BurnRate.Window)
+	})
+}
+
+func TestBurnRateAlertLoad(t *testing.T) {
+	now := time.Now()
+	var requests []*Request
+	for i := 0; i < 20; i++ {
+		request := &Request{Provider: ProviderGoogle, RequestedAt: now.Add(-time.Duration(i) * time.Second)}
+		if i%2 == 0 {
+			request.ErrorType = ErrorTypeServerError
+		}
+		requests = append(requests, request)
+	}
+	client := NewClient(&MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			return requests, nil
+		},
+	})
+
+	var events []SLOEvent
+	monitor := NewSLOMonitor(client,
+		WithBurnRateAlert(BurnRateAlert{Name: "google-5xx", Provider: ProviderGoogle, ErrorType: ErrorTypeServerError, ErrorBudget: 0.05}),
+		WithSLOHook(func(event SLOEvent) { events = append(events, event) }),
+	)
+	require.NoError(t, monitor.Load(context.Background()))
+
+	statuses := monitor.BurnRates()
+	require.Len(t, statuses, 2)
+	for _, status := range statuses {
+		assert.Equal(t, int64(20), status.Requests)
+		assert.InDelta(t, 10.0, status.BurnRate, 1e-9)
+	}
+	assert.False(t, statuses[0].Breached, "below the fast threshold")
+	assert.True(t, statuses[1].Breached)
+
+	// Loading restores the breach state without replaying events
+	monitor.observe(&Request{Provider: ProviderGoogle, ErrorType: ErrorTypeServerError, RequestedAt: now})
+	assert.Empty(t, events)
+}
@@ -0,0 +1,89 @@
+package llmtracer
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// CardinalityPeriod is the number of distinct values a dimension key had in one period
+type CardinalityPeriod struct {
+	Start  time.Time `json:"start"`
+	Values int       `json:"values"`
+	// NewValues are the values not seen in earlier periods
+	NewValues int `json:"new_values"`
+}
+
+// DimensionCardinality reports how many distinct values a dimension key has, in total and
+// per period, so keys whose values grow without bound can be spotted before they slow
+// down dimension queries
+type DimensionCardinality struct {
+	Key     string              `json:"key"`
+	Values  int                 `json:"values"`
+	Periods []CardinalityPeriod `json:"periods"`
+}
+
+// GetDimensionCardinality counts the distinct values of every dimension key among the
+// requests matching filter (nil matches everything), bucketed into periods of interval
+// (daily when zero) in UTC. Periods without requests for a key are omitted. Keys are
+// sorted by total distinct values, highest first.
+func (c *Client) GetDimensionCardinality(ctx context.Context, filter *RequestFilter, interval time.Duration) ([]*DimensionCardinality, error) {
+	if filter == nil {
+		filter = &RequestFilter{}
+	}
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	requests, err := c.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Key -> value -> start of the first period the value was seen in
+	firstSeen := make(map[string]map[string]time.Time)
+	// Key -> period start -> distinct values
+	periods := make(map[string]map[time.Time]map[string]struct{})
+	for _, req := range requests {
+		start := req.RequestedAt.UTC().Truncate(interval)
+		for _, dim := range req.Dimensions {
+			if firstSeen[dim.Key] == nil {
+				firstSeen[dim.Key] = make(map[string]time.Time)
+				periods[dim.Key] = make(map[time.Time]map[string]struct{})
+			}
+			if first, ok := firstSeen[dim.Key][dim.Value]; !ok || start.Before(first) {
+				firstSeen[dim.Key][dim.Value] = start
+			}
+			values := periods[dim.Key][start]
+			if values == nil {
+				values = make(map[string]struct{})
+				periods[dim.Key][start] = values
+			}
+			values[dim.Value] = struct{}{}
+		}
+	}
+
+	cardinalities := make([]*DimensionCardinality, 0, len(firstSeen))
+	for key, values := range firstSeen {
+		cardinality := &DimensionCardinality{Key: key, Values: len(values), Periods: []CardinalityPeriod{}}
+		for start, periodValues := range periods[key] {
+			period := CardinalityPeriod{Start: start, Values: len(periodValues)}
+			for value := range periodValues {
+				if values[value].Equal(start) {
+					period.NewValues++
+				}
+			}
+			cardinality.Periods = append(cardinality.Periods, period)
+		}
+		sort.Slice(cardinality.Periods, func(i, j int) bool {
+			return cardinality.Periods[i].Start.Before(cardinality.Periods[j].Start)
+		})
+		cardinalities = append(cardinalities, cardinality)
+	}
+	sort.Slice(cardinalities, func(i, j int) bool {
+		if cardinalities[i].Values != cardinalities[j].Values {
+			return cardinalities[i].Values > cardinalities[j].Values
+		}
+		return cardinalities[i].Key < cardinalities[j].Key
+	})
+	return cardinalities, nil
+}
@@ -0,0 +1,79 @@
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDimensionCardinality(t *testing.T) {
+	day1 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	request := func(at time.Time, dims ...string) *Request {
+		req := &Request{RequestedAt: at}
+		for i := 0; i < len(dims); i += 2 {
+			req.Dimensions = append(req.Dimensions, DimensionTag{Key: dims[i], Value: dims[i+1]})
+		}
+		return req
+	}
+	requests := []*Request{
+		request(day1, "team", "search", "session_id", "s-1"),
+		request(day1.Add(time.Hour), "team", "search", "session_id", "s-2"),
+		request(day2, "team", "ads", "session_id", "s-3"),
+		request(day2, "team", "search", "session_id", "s-4"),
+		request(day2, "session_id", "s-5"),
+	}
+	client := NewClient(&MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			return requests, nil
+		},
+	})
+
+	cardinalities, err := client.GetDimensionCardinality(context.Background(), nil, 0)
+	require.NoError(t, err)
+	require.Len(t, cardinalities, 2)
+
+	sessions := cardinalities[0]
+	assert.Equal(t, "session_id", sessions.Key)
+	assert.Equal(t, 5, sessions.Values)
+	assert.Equal(t, []CardinalityPeriod{
+		{Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Values: 2, NewValues: 2},
+		{Start: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Values: 3, NewValues: 3},
+	}, sessions.Periods)
+
+	teams := cardinalities[1]
+	assert.Equal(t, "team", teams.Key)
+	assert.Equal(t, 2, teams.Values)
+	require.Len(t, teams.Periods, 2)
+	assert.Equal(t, 2, teams.Periods[1].Values)
+	assert.Equal(t, 1, teams.Periods[1].NewValues, "search was seen the day before")
+
+	t.Run("write-only storage", func(t *testing.T) {
+		client := NewClient(&MockStorageAdapter{CapabilitiesFunc: func() StorageCapabilities { return StorageCapabilities{} }})
+		_, err := client.GetDimensionCardinality(context.Background(), nil, time.Hour)
+		assert.ErrorIs(t, err, ErrQueryNotSupported)
+	})
+}
+
+func TestDimensionCardinalityAlert(t *testing.T) {
+	type alert struct {
+		key    string
+		values int
+	}
+	var alerts []alert
+	mock, _ := newRecordingStorage()
+	client := NewClient(mock, WithDimensionCardinalityAlert(3, func(key string, values int) {
+		alerts = append(alerts, alert{key, values})
+	}))
+
+	for i := 0; i < 10; i++ {
+		ctx := WithDimensions(context.Background(), map[string]interface{}{"session_id": fmt.Sprintf("s-%d", i), "team": "search"})
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, time.Second, nil, nil))
+	}
+	assert.Equal(t, []alert{{"session_id", 3}}, alerts, "alerts once, and values are still saved")
+	assert.Zero(t, client.Metrics().DimensionsOverflowed)
+}
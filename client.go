@@ -4,38 +4,91 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
 	"time"
-
-	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/option"
-	mistral "github.com/gage-technologies/mistral-go"
-	"github.com/google/generative-ai-go/genai"
-	"github.com/google/uuid"
-	"github.com/sashabaranov/go-openai"
-	"go.uber.org/zap"
 )
 
-// Logger interface for logging tracking errors
-type Logger interface {
-	Error(msg string, fields ...zap.Field)
-	Warn(msg string, fields ...zap.Field)
-	Info(msg string, fields ...zap.Field)
-	Debug(msg string, fields ...zap.Field)
-}
-
 // Client provides a unified interface for calling different AI providers with automatic token tracking
 type Client struct {
 	// Internal tracking
-	storage        StorageAdapter
-	logger         Logger
-	asyncTracking  bool
-	circuitBreaker *CircuitBreaker
+	storage                 StorageAdapter
+	logger                  Logger
+	asyncTracking           bool
+	circuitBreaker          *CircuitBreaker
+	captureGenerationParams bool
+	capturePayloadSizes     bool
+	capturePayloads         bool
+	sampleRate              float64
+	pricing                 *PricingRegistry
+	tokenizer               Tokenizer
+	dimensions              dimensionPolicy
+	trackResult             func(*Request, error)
+	lifecycleTracking       bool
+	accessScoping           bool
+	newID                   IDGenerator
+
+	// Retention
+	retention         time.Duration
+	retentionInterval time.Duration
+	stopRetention     chan struct{}
+
+	// Compaction
+	compaction         *CompactionOptions
+	compactionInterval time.Duration
+	stopCompaction     chan struct{}
+
+	// Provider status polling
+	incidentInterval    time.Duration
+	incidentSources     []StatusSource
+	stopIncidentPolling chan struct{}
+
+	// In-flight watchdog
+	watchdogTimeout  time.Duration
+	watchdogInterval time.Duration
+	stopWatchdog     chan struct{}
+
+	// Usage pushed by WithMetricsPush
+	metricsPush *metricsPush
+
+	// Recently tracked calls, for WithDeduplication
+	dedup *dedupWindow
+
+	// Shutdown
+	shutdownMu sync.RWMutex
+	closing    bool
+	pending    sync.WaitGroup
+	closeOnce  sync.Once
+	closeErr   error
+
+	// Live subscriptions
+	watchers  watchHub
+	observers observerList
+
+	// Latest provider-reported rate limits
+	rateLimits providerRateLimits
+	backoffs   providerBackoffs
+	throttles  modelThrottles
+
+	// Dimensions inherited within traces
+	inheritance traceInheritance
+
+	// Self-metrics
+	metrics clientCounters
+
+	// Pre-call checks run by the trace wrappers
+	admissionMu     sync.RWMutex
+	admissionChecks []func(ctx context.Context, provider Provider, model string) error
+	// Slots of the providers limited by WithConcurrencyLimit
+	concurrency map[Provider]chan struct{}
 }
 
 // ClientOption allows configuring the Client
 type ClientOption func(*Client)
 
-// WithLogger sets a custom logger for the client
+// WithLogger sets a custom logger for the client; see NewSlogLogger and the zaptracer
+// package for adapters
 func WithLogger(logger Logger) ClientOption {
 	return func(c *Client) {
 		c.logger = logger
@@ -56,6 +109,71 @@ func WithCircuitBreaker(maxFailures int, resetTimeout time.Duration) ClientOptio
 	}
 }
 
+// WithGenerationParamsCapture records sampling parameters (temperature, top_p, max_tokens,
+// tool count, response format) from outgoing requests. Google requests are not covered since
+// their parameters live on the GenerativeModel rather than the call.
+func WithGenerationParamsCapture(capture bool) ClientOption {
+	return func(c *Client) {
+		c.captureGenerationParams = capture
+	}
+}
+
+// WithPayloadSizeCapture records the JSON-encoded size of each request and response.
+// Sizes are measured by re-encoding the values, so this adds some CPU cost per call.
+func WithPayloadSizeCapture(capture bool) ClientOption {
+	return func(c *Client) {
+		c.capturePayloadSizes = capture
+	}
+}
+
+// WithPayloadCapture stores the JSON request in RequestPayload for the OpenAI and Anthropic
+// trace wrappers and for every request made through the HTTP transport, so calls can be
+// replayed later.
+// Payloads contain the full prompt; only enable this where storing prompts is acceptable.
+func WithPayloadCapture(capture bool) ClientOption {
+	return func(c *Client) {
+		c.capturePayloads = capture
+	}
+}
+
+// WithPricing computes the cost of each request from its token counts. Requests for models
+// without a price, and requests whose cost was set explicitly, are left as they are.
+func WithPricing(pricing *PricingRegistry) ClientOption {
+	return func(c *Client) {
+		c.pricing = pricing
+	}
+}
+
+// WithSampleRate records only the given fraction (0-1) of successful requests to reduce storage
+// volume on high-traffic services. Failed requests are always recorded, and requests whose
+// context carries a decision from WithSampled follow it instead.
+func WithSampleRate(rate float64) ClientOption {
+	return func(c *Client) {
+		c.sampleRate = rate
+	}
+}
+
+// WithRetention deletes requests older than maxAge from storage, checking every interval
+// (hourly when interval is zero) until the client is closed. With a SoftDeleteStorage,
+// expired requests stay restorable for another maxAge before they are purged.
+func WithRetention(maxAge, interval time.Duration) ClientOption {
+	return func(c *Client) {
+		c.retention = maxAge
+		c.retentionInterval = interval
+	}
+}
+
+// WithTrackResultCallback calls fn with every request the client tries to save and the
+// outcome: nil once the request is persisted, or the error that lost it, including
+// ErrClientClosed for calls the trace wrappers complete after shutdown. In async mode
+// this is the only way to learn about failed saves. fn runs in the tracking goroutine and
+// must not block; requests skipped by sampling are not reported.
+func WithTrackResultCallback(fn func(*Request, error)) ClientOption {
+	return func(c *Client) {
+		c.trackResult = fn
+	}
+}
+
 // NewClient creates a new AI client with token tracking
 func NewClient(storage StorageAdapter, opts ...ClientOption) *Client {
 	if storage == nil {
@@ -63,8 +181,10 @@ func NewClient(storage StorageAdapter, opts ...ClientOption) *Client {
 	}
 
 	client := &Client{
-		storage: storage,
-		logger:  zap.NewNop(), // Default to no-op logger
+		storage:    storage,
+		logger:     nopLogger{}, // Default to no-op logger
+		sampleRate: 1,
+		newID:      NewUUID,
 	}
 
 	// Apply options
@@ -74,240 +194,437 @@ func NewClient(storage StorageAdapter, opts ...ClientOption) *Client {
 		}
 	}
 
-	return client
-}
-
-// OpenAICreateChatCompletionFunc represents the signature of OpenAI's CreateChatCompletion method
-type OpenAICreateChatCompletionFunc func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
-
-// AnthropicMessageNewFunc represents the signature of Anthropic's MessageService.New method
-type AnthropicMessageNewFunc func(ctx context.Context, body anthropic.MessageNewParams, opts ...option.RequestOption) (res *anthropic.Message, err error)
-
-// MistralChatFunc represents the signature of Mistral's Chat method
-type MistralChatFunc func(model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams) (*mistral.ChatCompletionResponse, error)
-
-// GoogleGenerateContentFunc represents the signature of Google's GenerativeModel.GenerateContent method
-type GoogleGenerateContentFunc func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error)
-
-// TraceOpenAIRequest wraps OpenAI's CreateChatCompletion and automatically tracks token usage
-func (c *Client) TraceOpenAIRequest(ctx context.Context, request openai.ChatCompletionRequest, createChatCompletion OpenAICreateChatCompletionFunc) (openai.ChatCompletionResponse, error) {
-	if createChatCompletion == nil {
-		return openai.ChatCompletionResponse{}, fmt.Errorf("createChatCompletion function cannot be nil")
+	// Wrapped last so scopes apply to the requests as the caller sees them
+	if client.accessScoping {
+		client.storage = NewScopedStorage(client.storage)
 	}
 
-	startTime := time.Now()
-
-	// Make the actual OpenAI API call using the provided function
-	response, err := createChatCompletion(ctx, request)
-
-	duration := time.Since(startTime)
-
-	// Track the request - even if it failed
-	inputTokens := 0
-	outputTokens := 0
-	if err == nil && (response.Usage.PromptTokens > 0 || response.Usage.CompletionTokens > 0) {
-		inputTokens = response.Usage.PromptTokens
-		outputTokens = response.Usage.CompletionTokens
+	if client.retention > 0 {
+		if client.retentionInterval <= 0 {
+			client.retentionInterval = time.Hour
+		}
+		client.stopRetention = make(chan struct{})
+		go client.runRetention()
+	}
+	if client.compaction != nil {
+		if client.compactionInterval <= 0 {
+			client.compactionInterval = 24 * time.Hour
+		}
+		client.stopCompaction = make(chan struct{})
+		go client.runCompaction()
+	}
+	if len(client.incidentSources) > 0 {
+		if client.incidentInterval <= 0 {
+			client.incidentInterval = 5 * time.Minute
+		}
+		client.stopIncidentPolling = make(chan struct{})
+		go client.runIncidentPolling()
+	}
+	if client.watchdogTimeout > 0 {
+		if client.watchdogInterval <= 0 {
+			client.watchdogInterval = time.Minute
+		}
+		client.stopWatchdog = make(chan struct{})
+		go client.runWatchdog()
+	}
+	if client.metricsPush != nil && client.metricsPush.config.Interval > 0 {
+		client.metricsPush.stop = make(chan struct{})
+		go client.runMetricsPush()
 	}
 
-	// Extract tracking context from context if available
-	trackingContext := GetDimensionsFromContext(ctx)
-
-	c.track(ctx, ProviderOpenAI, request.Model, inputTokens, outputTokens, duration, err, trackingContext)
+	return client
+}
 
-	// Return the original response and error
-	return response, err
+// addAdmissionCheck registers a check run by the trace wrappers before calling the provider
+func (c *Client) addAdmissionCheck(check func(ctx context.Context, provider Provider, model string) error) {
+	c.admissionMu.Lock()
+	defer c.admissionMu.Unlock()
+	c.admissionChecks = append(c.admissionChecks, check)
 }
 
-// TraceAnthropicRequest wraps Anthropic's MessageService.New and automatically tracks token usage
-func (c *Client) TraceAnthropicRequest(ctx context.Context, params anthropic.MessageNewParams, messageNew AnthropicMessageNewFunc) (*anthropic.Message, error) {
-	if messageNew == nil {
-		return nil, fmt.Errorf("messageNew function cannot be nil")
+// admit runs the admission checks, returning the first rejection. Rejected calls are
+// not sent to the provider and are not tracked.
+func (c *Client) admit(ctx context.Context, provider Provider, model string) error {
+	if budget := budgetFromContext(ctx); budget != nil {
+		if err := budget.check(); err != nil {
+			return err
+		}
 	}
 
-	startTime := time.Now()
-
-	// Make the actual Anthropic API call using the provided function
-	response, err := messageNew(ctx, params)
+	c.admissionMu.RLock()
+	defer c.admissionMu.RUnlock()
 
-	duration := time.Since(startTime)
-
-	// Track the request - even if it failed
-	inputTokens := 0
-	outputTokens := 0
-	if err == nil {
-		inputTokens = int(response.Usage.InputTokens)
-		outputTokens = int(response.Usage.OutputTokens)
+	for _, check := range c.admissionChecks {
+		if err := check(ctx, provider, model); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	// Extract tracking context from context if available
-	trackingContext := GetDimensionsFromContext(ctx)
-
-	c.track(ctx, ProviderAnthropic, string(params.Model), inputTokens, outputTokens, duration, err, trackingContext)
-
-	// Return the original response and error
-	return response, err
+// RequestOptions carries optional details for TrackRequest
+type RequestOptions struct {
+	// StatusCode is the HTTP status returned by the provider; derived from err when zero
+	StatusCode int
+	// ProviderRequestID is the request identifier issued by the provider
+	ProviderRequestID string
+	// MessageCount is the number of messages sent to the model
+	MessageCount int
+	// PromptVersion overrides the prompt version found in the context
+	PromptVersion string
+	// RequestType defaults to RequestTypeChat
+	RequestType RequestType
+	// Cost is the price of the call in USD; computed from the client's pricing when nil
+	Cost *float64
+	// Dimensions are merged with the dimensions found in the context, taking precedence
+	Dimensions map[string]interface{}
+	// Usage replaces the token counts passed to TrackRequest when set
+	Usage *Usage
+	// IdempotencyKey identifies the call for WithDeduplication, like WithIdempotencyKey
+	IdempotencyKey string
+	// ID is the ID of the tracked request, like WithRequestID; generated when empty
+	ID string
+	// RequestedAt is when the call started. When zero it is derived from the duration and
+	// the time TrackRequest is called, so pass it when tracking a call after the fact.
+	RequestedAt time.Time
 }
 
-// TraceMistralRequest wraps Mistral's Chat method and automatically tracks token usage
-func (c *Client) TraceMistralRequest(ctx context.Context, model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams, chat MistralChatFunc) (*mistral.ChatCompletionResponse, error) {
-	if chat == nil {
-		return nil, fmt.Errorf("chat function cannot be nil")
-	}
-	if model == "" {
-		return nil, fmt.Errorf("model cannot be empty")
+// TrackRequest records a provider call made outside the trace wrappers, e.g. from framework
+// callbacks or hand-rolled HTTP clients. In async mode it returns immediately and storage
+// errors are only logged.
+func (c *Client) TrackRequest(ctx context.Context, provider Provider, model string, inputTokens, outputTokens int64, duration time.Duration, err error, opts *RequestOptions) error {
+	tracked := &Request{
+		Provider:     provider,
+		Model:        model,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		Latency:      duration,
 	}
 
-	startTime := time.Now()
-
-	// Make the actual Mistral API call using the provided function
-	response, err := chat(model, messages, params)
-
-	duration := time.Since(startTime)
-
-	// Track the request - even if it failed
-	inputTokens := 0
-	outputTokens := 0
-	if err == nil {
-		inputTokens = response.Usage.PromptTokens
-		outputTokens = response.Usage.CompletionTokens
+	if c.isClosing() {
+		return ErrClientClosed
 	}
 
-	// Extract tracking context from context if available
 	trackingContext := GetDimensionsFromContext(ctx)
+	if opts != nil {
+		tracked.StatusCode = opts.StatusCode
+		tracked.ProviderRequestID = opts.ProviderRequestID
+		tracked.MessageCount = opts.MessageCount
+		tracked.PromptVersion = opts.PromptVersion
+		tracked.RequestType = opts.RequestType
+		tracked.RequestedAt = opts.RequestedAt
+		if opts.Cost != nil {
+			tracked.Cost = *opts.Cost
+			tracked.costSet = true
+		}
+		if opts.Usage != nil {
+			tracked.SetUsage(*opts.Usage)
+		}
+		for key, value := range opts.Dimensions {
+			trackingContext[key] = value
+		}
+		if opts.IdempotencyKey != "" {
+			ctx = WithIdempotencyKey(ctx, opts.IdempotencyKey)
+		}
+		if opts.ID != "" {
+			ctx = WithRequestID(ctx, opts.ID)
+		}
+	}
 
-	c.track(ctx, ProviderMistral, model, inputTokens, outputTokens, duration, err, trackingContext)
+	if c.asyncTracking {
+		c.track(ctx, tracked, err, trackingContext)
+		return nil
+	}
 
-	// Return the original response and error
-	return response, err
+	if !c.beginTrack() {
+		return ErrClientClosed
+	}
+	defer c.pending.Done()
+
+	// The budget is charged once the dimensions, which carry the region, are resolved
+	tracked.TraceID = GetTraceIDFromContext(ctx)
+	tracked.Dimensions = c.storedInheritedDimensions(ctx, tracked.TraceID, trackingContext)
+	c.chargeBudget(ctx, tracked)
+	trackErr := c.trackRequest(ctx, tracked, err, nil)
+	recordRequestHandle(ctx, tracked)
+	return trackErr
 }
 
-// TraceGoogleRequest wraps Google's GenerativeModel.GenerateContent method and automatically tracks token usage
-func (c *Client) TraceGoogleRequest(ctx context.Context, model string, parts []genai.Part, generateContent GoogleGenerateContentFunc) (*genai.GenerateContentResponse, error) {
-	if generateContent == nil {
-		return nil, fmt.Errorf("generateContent function cannot be nil")
+// track handles request tracking, either synchronously or asynchronously. Everything
+// derived from the caller's context (trace ID, dimensions, response time) is captured
+// before tracking is handed off, so async records are identical to sync ones even when
+// the context is canceled or its values change afterwards.
+func (c *Client) track(ctx context.Context, request *Request, apiErr error, trackingContext map[string]interface{}) {
+	request.TraceID = GetTraceIDFromContext(ctx)
+	if request.PromptVersion == "" {
+		request.PromptVersion = GetPromptVersionFromContext(ctx)
 	}
-	if model == "" {
-		return nil, fmt.Errorf("model cannot be empty")
+	dimensions, complete := c.inheritDimensions(ctx, request.TraceID, trackingContext)
+	request.Dimensions = dimensions
+	// Dimensions inherited from a trace's stored requests are read when the request is saved
+	var inheritContext map[string]interface{}
+	if !complete {
+		inheritContext = trackingContext
 	}
-
-	startTime := time.Now()
-
-	// Make the actual Google API call using the provided function
-	response, err := generateContent(ctx, parts...)
-
-	duration := time.Since(startTime)
-
-	// Track the request - even if it failed
-	inputTokens := 0
-	outputTokens := 0
-	if err == nil && response.UsageMetadata != nil {
-		inputTokens = int(response.UsageMetadata.PromptTokenCount)
-		outputTokens = int(response.UsageMetadata.CandidatesTokenCount)
+	request.RespondedAt = time.Now()
+	c.chargeBudget(ctx, request)
+
+	if !c.beginTrack() {
+		c.metrics.dropped.Add(1)
+		c.logger.Warn("Dropping request tracked after shutdown",
+			slog.String("provider", string(request.Provider)),
+			slog.String("model", request.Model),
+		)
+		c.reportTrackResult(request, ErrClientClosed)
+		return
 	}
 
-	// Extract tracking context from context if available
-	trackingContext := GetDimensionsFromContext(ctx)
-
-	c.track(ctx, ProviderGoogle, model, inputTokens, outputTokens, duration, err, trackingContext)
-
-	// Return the original response and error
-	return response, err
-}
-
-// track handles request tracking, either synchronously or asynchronously
-func (c *Client) track(ctx context.Context, provider Provider, model string, inputTokens, outputTokens int, duration time.Duration, apiErr error, trackingContext map[string]interface{}) {
 	if c.asyncTracking {
+		// The goroutine keeps filling in the request, so the handle gets its copy first
+		recordRequestHandle(ctx, request)
+
 		// Track asynchronously to avoid blocking the API response
+		c.metrics.inFlight.Add(1)
 		go func() {
-			// Create a background context to avoid cancellation issues
-			bgCtx := context.Background()
-			c.doTrack(bgCtx, provider, model, inputTokens, outputTokens, duration, apiErr, trackingContext)
+			defer c.pending.Done()
+			defer c.metrics.inFlight.Add(-1)
+
+			// Detach from the caller's cancellation but keep its values, such as the tenant
+			bgCtx := context.WithoutCancel(ctx)
+			c.doTrack(bgCtx, request, apiErr, inheritContext)
 		}()
 	} else {
 		// Track synchronously
-		c.doTrack(ctx, provider, model, inputTokens, outputTokens, duration, apiErr, trackingContext)
+		c.doTrack(ctx, request, apiErr, inheritContext)
+		c.pending.Done()
+		recordRequestHandle(ctx, request)
 	}
 }
 
 // doTrack handles the actual tracking with error logging
-func (c *Client) doTrack(ctx context.Context, provider Provider, model string, inputTokens, outputTokens int, duration time.Duration, apiErr error, trackingContext map[string]interface{}) {
-	trackErr := c.trackRequest(ctx, provider, model, inputTokens, outputTokens, duration, apiErr, trackingContext)
+func (c *Client) doTrack(ctx context.Context, request *Request, apiErr error, trackingContext map[string]interface{}) {
+	trackErr := c.trackRequest(ctx, request, apiErr, trackingContext)
 	if trackErr != nil {
 		// Log but don't fail the request
-		providerStr := string(provider)
+		providerStr := string(request.Provider)
 		c.logger.Error("Failed to track request",
-			zap.Error(trackErr),
-			zap.String("provider", providerStr),
-			zap.String("model", model),
-			zap.Int("input_tokens", inputTokens),
-			zap.Int("output_tokens", outputTokens),
+			slog.Any("error", trackErr),
+			slog.String("provider", providerStr),
+			slog.String("model", request.Model),
+			slog.Int64("input_tokens", request.InputTokens),
+			slog.Int64("output_tokens", request.OutputTokens),
 		)
 	}
 }
 
-// trackRequest internally tracks the token usage
-func (c *Client) trackRequest(ctx context.Context, provider Provider, model string, inputTokens, outputTokens int, duration time.Duration, err error, trackingContext map[string]interface{}) error {
+// sampled reports whether a successful request should be recorded. An upstream decision
+// recorded with WithSampled takes precedence over the sample rate.
+func (c *Client) sampled(ctx context.Context) bool {
+	if sampled, ok := GetSampledFromContext(ctx); ok {
+		return sampled
+	}
+	return c.sampleRate >= 1 || rand.Float64() < c.sampleRate
+}
+
+// trackRequest internally tracks the token usage. The request carries whatever the trace
+// wrapper observed (provider, model, tokens, latency, provider metadata); the remaining
+// bookkeeping fields are filled in here.
+func (c *Client) trackRequest(ctx context.Context, request *Request, err error, trackingContext map[string]interface{}) error {
+	// The trace ID may already have been captured by track
+	if request.TraceID == "" {
+		request.TraceID = GetTraceIDFromContext(ctx)
+	}
+	if c.isDuplicate(ctx, request) {
+		if request.pendingSaved() {
+			return c.storage.Delete(ctx, request.ID)
+		}
+		return nil
+	}
+
+	// Sampling never drops failed requests so error rates stay visible
+	if err == nil && !c.sampled(ctx) {
+		c.metrics.sampledOut.Add(1)
+		c.backoffs.record(request)
+		c.throttles.charge(request, time.Now())
+		if request.pendingSaved() {
+			return c.storage.Delete(ctx, request.ID)
+		}
+		return nil
+	}
+
 	// Validate token counts
-	if inputTokens < 0 {
-		inputTokens = 0
+	if request.InputTokens < 0 {
+		request.InputTokens = 0
 	}
-	if outputTokens < 0 {
-		outputTokens = 0
+	if request.OutputTokens < 0 {
+		request.OutputTokens = 0
 	}
-	// Convert map dimensions to DimensionTag slice
-	var dimensions []DimensionTag
-	for key, value := range trackingContext {
-		dimensions = append(dimensions, DimensionTag{
-			Key:   key,
-			Value: fmt.Sprintf("%v", value),
-		})
+	request.TotalTokens = AddTokens(request.InputTokens, request.OutputTokens)
+	if request.RequestType == "" {
+		request.RequestType = RequestTypeChat
 	}
 
-	request := &Request{
-		ID:           uuid.New().String(),
-		TraceID:      GetTraceIDFromContext(ctx),
-		Provider:     provider,
-		Model:        model,
-		InputTokens:  inputTokens,
-		OutputTokens: outputTokens,
-		Latency:      duration,
-		StatusCode:   200,
-		Dimensions:   dimensions,
-		RequestedAt:  time.Now().Add(-duration),
-		RespondedAt:  time.Now(),
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+	// Context-derived fields may already have been captured by track
+	if request.PromptVersion == "" {
+		request.PromptVersion = GetPromptVersionFromContext(ctx)
 	}
+	if trackingContext != nil {
+		request.Dimensions = c.storedInheritedDimensions(ctx, request.TraceID, trackingContext)
+	}
+
+	// Priced after the dimensions are set, since they carry the region
+	request.Cost = c.requestCost(request)
 
+	now := time.Now()
+	// Wrappers record when the call started, and TrackRequest callers can pass it; only
+	// otherwise is it derived from when the call was tracked, which drifts when tracking
+	// is delayed
+	if request.RequestedAt.IsZero() {
+		if request.RespondedAt.IsZero() {
+			request.RespondedAt = now
+		}
+		request.RequestedAt = request.RespondedAt.Add(-request.Latency)
+	} else {
+		request.RespondedAt = request.RequestedAt.Add(request.Latency)
+	}
+	// Requests started with lifecycle tracking keep the ID and creation time of their
+	// pending row
+	if request.pendingWrite == nil {
+		request.ID = c.requestID(ctx)
+		request.CreatedAt = now
+	}
+	request.LatencyMs = request.Latency.Milliseconds()
+	request.RetryAfterMs = request.RetryAfter.Milliseconds()
+	request.QueueWaitMs = request.QueueWait.Milliseconds()
+	request.UpdatedAt = now
+
+	// Error matchers only see statuses reported by the provider, not the 500 filled in below
+	reportedStatus := 0
+	if request.StatusCode >= 400 {
+		reportedStatus = request.StatusCode
+	}
+	aborted := errors.Is(err, ErrStreamAborted)
 	if err != nil {
-		request.StatusCode = 500
+		switch {
+		case aborted:
+			request.StatusCode = StatusClientClosedRequest
+		case request.StatusCode < 400:
+			// Keep the provider-reported status when the wrapper could extract one
+			request.StatusCode = 500
+		}
 		request.Error = err.Error()
+	} else if request.StatusCode == 0 {
+		request.StatusCode = 200
 	}
 
 	// Categorize the error if present
-	if request.Error != "" {
-		request.ErrorType = CategorizeError(errors.New(request.Error))
+	if aborted {
+		request.ErrorType = ErrorTypeAborted
+	} else if request.Error != "" {
+		categorized := err
+		if categorized == nil {
+			categorized = errors.New(request.Error)
+		}
+		request.ErrorType = CategorizeErrorInfo(ErrorInfo{Provider: request.Provider, StatusCode: reportedStatus, Err: categorized})
+	}
+	c.backoffs.record(request)
+	c.throttles.charge(request, now)
+	if c.metricsPush != nil {
+		c.metricsPush.usage.record(request)
+	}
+
+	saveStart := time.Now()
+	saveErr := c.callStorage(func() error {
+		if request.pendingSaved() {
+			return updateRequest(ctx, c.storage, request)
+		}
+		return c.storage.Save(ctx, request)
+	})
+	c.metrics.recordSave(time.Since(saveStart), saveErr)
+	c.reportTrackResult(request, saveErr)
+	if saveErr != nil {
+		return saveErr
 	}
 
-	// Use circuit breaker if enabled
+	c.observers.notify(request)
+	c.watchers.publish(request)
+	return nil
+}
+
+// callStorage runs a storage write through the circuit breaker when one is enabled
+func (c *Client) callStorage(write func() error) error {
 	if c.circuitBreaker != nil {
-		return c.circuitBreaker.Call(func() error {
-			return c.storage.Save(ctx, request)
-		})
+		return c.circuitBreaker.Call(write)
 	}
+	return write()
+}
 
-	return c.storage.Save(ctx, request)
+// reportTrackResult passes the outcome of tracking a request to the result callback
+func (c *Client) reportTrackResult(request *Request, err error) {
+	if c.trackResult != nil {
+		c.trackResult(request, err)
+	}
 }
 
-// GetTokenStats returns token usage statistics
+// requestCost returns the cost of a request, computing it from the client's pricing when
+// none was supplied. A supplied cost of zero, e.g. for a free tier, is kept.
+func (c *Client) requestCost(request *Request) float64 {
+	if !request.costSet && c.pricing != nil {
+		if cost, ok := c.pricing.RequestCost(request); ok {
+			return cost
+		}
+	}
+	return request.Cost
+}
+
+// query reads requests from storage, failing with ErrQueryNotSupported for write-only
+// adapters
+func (c *Client) query(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+	if !c.storage.Capabilities().Query {
+		return nil, ErrQueryNotSupported
+	}
+	return c.storage.Query(ctx, filter)
+}
+
+// GetTokenStats returns token usage statistics per provider/model. The aggregation is pushed
+// down to the storage adapter; adapters that cannot aggregate natively are aggregated in
+// memory instead, which loads every matching request.
 func (c *Client) GetTokenStats(ctx context.Context, since *time.Time) (map[string]*TokenStats, error) {
 	filter := &RequestFilter{}
 	if since != nil {
 		filter.StartTime = since
 	}
 
-	requests, err := c.storage.Query(ctx, filter)
+	if !c.storage.Capabilities().Aggregate {
+		return c.tokenStatsFromQuery(ctx, filter)
+	}
+	results, err := c.storage.Aggregate(ctx, []string{"provider", "model"}, filter)
+	if errors.Is(err, ErrAggregateNotSupported) {
+		return c.tokenStatsFromQuery(ctx, filter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]*TokenStats)
+	for _, result := range results {
+		stats[string(result.Provider)+"/"+result.Model] = &TokenStats{
+			Provider:      result.Provider,
+			Model:         result.Model,
+			TotalRequests: result.TotalRequests,
+			InputTokens:   result.TotalInputTokens,
+			OutputTokens:  result.TotalOutputTokens,
+			TotalCost:     result.TotalCost,
+			ErrorCount:    result.ErrorCount,
+		}
+	}
+
+	return stats, nil
+}
+
+// tokenStatsFromQuery aggregates token usage statistics in memory
+func (c *Client) tokenStatsFromQuery(ctx context.Context, filter *RequestFilter) (map[string]*TokenStats, error) {
+	requests, err := c.query(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -325,8 +642,9 @@ func (c *Client) GetTokenStats(ctx context.Context, since *time.Time) (map[strin
 
 		s := stats[key]
 		s.TotalRequests++
-		s.InputTokens += int64(req.InputTokens)
-		s.OutputTokens += int64(req.OutputTokens)
+		s.InputTokens = AddTokens(s.InputTokens, req.InputTokens)
+		s.OutputTokens = AddTokens(s.OutputTokens, req.OutputTokens)
+		s.TotalCost += req.Cost
 
 		if req.Error != "" {
 			s.ErrorCount++
@@ -343,10 +661,133 @@ type TokenStats struct {
 	TotalRequests int64    `json:"total_requests"`
 	InputTokens   int64    `json:"input_tokens"`
 	OutputTokens  int64    `json:"output_tokens"`
+	TotalCost     float64  `json:"total_cost"`
 	ErrorCount    int64    `json:"error_count"`
 }
 
-// Close closes the underlying storage
+// runRetention periodically deletes requests older than the retention period
+func (c *Client) runRetention() {
+	ticker := time.NewTicker(c.retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		c.applyRetention()
+
+		select {
+		case <-ticker.C:
+		case <-c.stopRetention:
+			return
+		}
+	}
+}
+
+// applyRetention deletes requests older than the retention period
+func (c *Client) applyRetention() {
+	deleted, err := c.storage.DeleteOlderThan(context.Background(), time.Now().Add(-c.retention))
+	if err != nil {
+		c.logger.Error("Failed to apply retention", slog.Any("error", err))
+		return
+	}
+	if deleted > 0 {
+		c.logger.Debug("Deleted expired requests", slog.Int64("count", deleted))
+	}
+
+	softDelete, ok := c.storage.(SoftDeleteStorage)
+	if !ok {
+		return
+	}
+	purged, err := softDelete.PurgeDeleted(context.Background(), time.Now().Add(-c.retention))
+	if err != nil {
+		c.logger.Error("Failed to purge deleted requests", slog.Any("error", err))
+		return
+	}
+	if purged > 0 {
+		c.logger.Debug("Purged deleted requests", slog.Int64("count", purged))
+	}
+}
+
+// beginTrack registers a track that Shutdown waits for, or reports false once Shutdown
+// has started
+func (c *Client) beginTrack() bool {
+	c.shutdownMu.RLock()
+	defer c.shutdownMu.RUnlock()
+	if c.closing {
+		return false
+	}
+	c.pending.Add(1)
+	return true
+}
+
+func (c *Client) isClosing() bool {
+	c.shutdownMu.RLock()
+	defer c.shutdownMu.RUnlock()
+	return c.closing
+}
+
+// Shutdown stops accepting new tracks, stops background retention, compaction, status
+// polling and the in-flight watchdog, waits for in-flight tracks to be saved, ends watch
+// subscriptions and then closes the underlying storage.
+// Requests tracked after Shutdown starts are dropped, and TrackRequest returns
+// ErrClientClosed; the trace wrappers still call the provider. If ctx ends before the
+// in-flight tracks are saved, storage is closed anyway and the context error is returned.
+// Calls after the first return its result without doing anything.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.shutdownMu.Lock()
+	c.closing = true
+	c.shutdownMu.Unlock()
+
+	c.closeOnce.Do(func() {
+		if c.stopRetention != nil {
+			close(c.stopRetention)
+		}
+		if c.stopCompaction != nil {
+			close(c.stopCompaction)
+		}
+		if c.stopIncidentPolling != nil {
+			close(c.stopIncidentPolling)
+		}
+		if c.stopWatchdog != nil {
+			close(c.stopWatchdog)
+		}
+		if c.metricsPush != nil && c.metricsPush.stop != nil {
+			close(c.metricsPush.stop)
+		}
+
+		flushed := make(chan struct{})
+		go func() {
+			c.pending.Wait()
+			close(flushed)
+		}()
+
+		var flushErr error
+		select {
+		case <-flushed:
+		case <-ctx.Done():
+			flushErr = fmt.Errorf("%d tracks not saved before shutdown: %w", c.metrics.inFlight.Load(), ctx.Err())
+		}
+
+		// Pushed even when the flush timed out, so the usage tracked so far isn't lost
+		pushErr := c.PushMetrics(context.WithoutCancel(ctx))
+
+		c.watchers.closeAll()
+		c.closeErr = errors.Join(flushErr, pushErr, c.storage.Close())
+	})
+	return c.closeErr
+}
+
+// HealthCheck verifies that the client is open and its storage backend is reachable, for
+// use in service readiness probes
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if c.isClosing() {
+		return ErrClientClosed
+	}
+	if err := c.storage.Ping(ctx); err != nil {
+		return fmt.Errorf("storage is unreachable: %w", err)
+	}
+	return nil
+}
+
+// Close is Shutdown without a deadline: it waits for every in-flight track to be saved
 func (c *Client) Close() error {
-	return c.storage.Close()
+	return c.Shutdown(context.Background())
 }
@@ -0,0 +1,84 @@
+//go:build !llmtracer_no_google
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test TraceGoogleRequest
+func TestTraceGoogleRequest(t *testing.T) {
+	mockStorage := &MockStorageAdapter{}
+	client := NewClient(mockStorage)
+
+	// Setup test data
+	parts := []genai.Part{
+		genai.Text("Hello Google"),
+	}
+
+	response := &genai.GenerateContentResponse{
+		UsageMetadata: &genai.UsageMetadata{
+			PromptTokenCount:     5,
+			CandidatesTokenCount: 15,
+			TotalTokenCount:      20,
+		},
+		Candidates: []*genai.Candidate{
+			{
+				Content: &genai.Content{
+					Parts: []genai.Part{genai.Text("Hello from Google!")},
+				},
+			},
+		},
+	}
+
+	// Mock Google function
+	mockGoogleFunc := func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+		return response, nil
+	}
+
+	// Call the method with model parameter
+	result, err := client.TraceGoogleRequest(context.Background(), "gemini-pro", parts, mockGoogleFunc)
+
+	// Verify response
+	assert.NoError(t, err)
+	assert.Equal(t, response, result)
+
+	// Verify tracking
+	require.Len(t, mockStorage.SaveCalls, 1)
+	savedRequest := mockStorage.SaveCalls[0].Request
+
+	assert.Equal(t, ProviderGoogle, savedRequest.Provider)
+	assert.Equal(t, "gemini-pro", savedRequest.Model) // Now uses the actual model parameter
+	assert.Equal(t, int64(5), savedRequest.InputTokens)
+	assert.Equal(t, int64(15), savedRequest.OutputTokens)
+	assert.Equal(t, 200, savedRequest.StatusCode)
+}
+
+func TestValidationGoogleNilFunction(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	_, err := client.TraceGoogleRequest(context.Background(), "model", nil, nil)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be nil")
+}
+
+func TestValidationGoogleEmptyModel(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	mockFunc := func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+		return &genai.GenerateContentResponse{}, nil
+	}
+
+	_, err := client.TraceGoogleRequest(context.Background(), "", nil, mockFunc)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "model cannot be empty")
+}
@@ -0,0 +1,139 @@
+//go:build !llmtracer_no_mistral
+
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mistral "github.com/gage-technologies/mistral-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test TraceMistralRequest
+func TestTraceMistralRequest(t *testing.T) {
+	mockStorage := &MockStorageAdapter{}
+	client := NewClient(mockStorage)
+
+	// Setup test data
+	model := mistral.ModelMistralLargeLatest
+	messages := []mistral.ChatMessage{
+		{Role: mistral.RoleUser, Content: "Hello"},
+	}
+	params := &mistral.ChatRequestParams{
+		MaxTokens: 1000,
+	}
+
+	response := &mistral.ChatCompletionResponse{
+		Model: model,
+		Usage: mistral.UsageInfo{
+			PromptTokens:     15,
+			CompletionTokens: 25,
+			TotalTokens:      40,
+		},
+		Choices: []mistral.ChatCompletionResponseChoice{
+			{
+				Index: 0,
+				Message: mistral.ChatMessage{
+					Role:    mistral.RoleAssistant,
+					Content: "Hi!",
+				},
+				FinishReason: "stop",
+			},
+		},
+	}
+
+	// Mock Mistral function
+	mockMistralFunc := func(model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams) (*mistral.ChatCompletionResponse, error) {
+		return response, nil
+	}
+
+	// Call the method
+	result, err := client.TraceMistralRequest(context.Background(), model, messages, params, mockMistralFunc)
+
+	// Verify response
+	assert.NoError(t, err)
+	assert.Equal(t, response, result)
+
+	// Verify tracking
+	require.Len(t, mockStorage.SaveCalls, 1)
+	savedRequest := mockStorage.SaveCalls[0].Request
+
+	assert.Equal(t, ProviderMistral, savedRequest.Provider)
+	assert.Equal(t, model, savedRequest.Model)
+	assert.Equal(t, int64(15), savedRequest.InputTokens)
+	assert.Equal(t, int64(25), savedRequest.OutputTokens)
+	assert.Equal(t, 200, savedRequest.StatusCode)
+}
+
+// Test error scenarios
+func TestErrorHandling(t *testing.T) {
+	t.Run("storage query error", func(t *testing.T) {
+		mockStorage := &MockStorageAdapter{
+			QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+				return nil, errors.New("database connection failed")
+			},
+		}
+
+		client := NewClient(mockStorage)
+		stats, err := client.GetTokenStats(context.Background(), nil)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "database connection failed")
+		assert.Nil(t, stats)
+	})
+
+	t.Run("nil usage data handling", func(t *testing.T) {
+		mockStorage := &MockStorageAdapter{}
+		client := NewClient(mockStorage)
+
+		// Mistral with empty usage
+		mistralResponse := &mistral.ChatCompletionResponse{
+			Model: "mistral-small",
+			Usage: mistral.UsageInfo{}, // empty usage
+		}
+
+		mockMistralFunc := func(model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams) (*mistral.ChatCompletionResponse, error) {
+			return mistralResponse, nil
+		}
+
+		_, err := client.TraceMistralRequest(
+			context.Background(),
+			"mistral-small",
+			[]mistral.ChatMessage{{Role: mistral.RoleUser, Content: "test"}},
+			nil,
+			mockMistralFunc,
+		)
+
+		assert.NoError(t, err)
+		require.Len(t, mockStorage.SaveCalls, 1)
+		assert.Equal(t, int64(0), mockStorage.SaveCalls[0].Request.InputTokens)
+		assert.Equal(t, int64(0), mockStorage.SaveCalls[0].Request.OutputTokens)
+	})
+}
+
+func TestValidationMistralNilFunction(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	_, err := client.TraceMistralRequest(context.Background(), "model", nil, nil, nil)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be nil")
+}
+
+func TestValidationMistralEmptyModel(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	mockFunc := func(model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams) (*mistral.ChatCompletionResponse, error) {
+		return &mistral.ChatCompletionResponse{}, nil
+	}
+
+	_, err := client.TraceMistralRequest(context.Background(), "", nil, nil, mockFunc)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "model cannot be empty")
+}
@@ -3,19 +3,13 @@ package llmtracer
 import (
 	"context"
 	"errors"
-	"fmt"
-	"strings"
+	"log/slog"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/option"
-	mistral "github.com/gage-technologies/mistral-go"
-	"github.com/google/generative-ai-go/genai"
-	"github.com/sashabaranov/go-openai"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.uber.org/zap"
 )
 
 // MockStorageAdapter implements StorageAdapter for testing
@@ -27,9 +21,13 @@ type MockStorageAdapter struct {
 	AggregateFunc       func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error)
 	DeleteFunc          func(ctx context.Context, id string) error
 	DeleteOlderThanFunc func(ctx context.Context, before time.Time) (int64, error)
+	CapabilitiesFunc    func() StorageCapabilities
+	PingFunc            func(ctx context.Context) error
 	CloseFunc           func() error
 
-	// Track calls for assertions
+	// Track calls for assertions. Async tracks save from other goroutines, so calls are
+	// recorded under mu; read them once the tracks are done.
+	mu         sync.Mutex
 	SaveCalls  []SaveCall
 	QueryCalls []QueryCall
 }
@@ -45,7 +43,9 @@ type QueryCall struct {
 }
 
 func (m *MockStorageAdapter) Save(ctx context.Context, request *Request) error {
+	m.mu.Lock()
 	m.SaveCalls = append(m.SaveCalls, SaveCall{Ctx: ctx, Request: request})
+	m.mu.Unlock()
 	if m.SaveFunc != nil {
 		return m.SaveFunc(ctx, request)
 	}
@@ -67,7 +67,9 @@ func (m *MockStorageAdapter) GetByTraceID(ctx context.Context, traceID string) (
 }
 
 func (m *MockStorageAdapter) Query(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+	m.mu.Lock()
 	m.QueryCalls = append(m.QueryCalls, QueryCall{Ctx: ctx, Filter: filter})
+	m.mu.Unlock()
 	if m.QueryFunc != nil {
 		return m.QueryFunc(ctx, filter)
 	}
@@ -78,7 +80,7 @@ func (m *MockStorageAdapter) Aggregate(ctx context.Context, groupBy []string, fi
 	if m.AggregateFunc != nil {
 		return m.AggregateFunc(ctx, groupBy, filter)
 	}
-	return nil, errors.New("not implemented")
+	return nil, ErrAggregateNotSupported
 }
 
 func (m *MockStorageAdapter) Delete(ctx context.Context, id string) error {
@@ -95,6 +97,20 @@ func (m *MockStorageAdapter) DeleteOlderThan(ctx context.Context, before time.Ti
 	return 0, errors.New("not implemented")
 }
 
+func (m *MockStorageAdapter) Capabilities() StorageCapabilities {
+	if m.CapabilitiesFunc != nil {
+		return m.CapabilitiesFunc()
+	}
+	return StorageCapabilities{Query: true, Aggregate: m.AggregateFunc != nil}
+}
+
+func (m *MockStorageAdapter) Ping(ctx context.Context) error {
+	if m.PingFunc != nil {
+		return m.PingFunc(ctx)
+	}
+	return nil
+}
+
 func (m *MockStorageAdapter) Close() error {
 	if m.CloseFunc != nil {
 		return m.CloseFunc()
@@ -112,22 +128,22 @@ type MockLogger struct {
 
 type LogCall struct {
 	Message string
-	Fields  []zap.Field
+	Fields  []slog.Attr
 }
 
-func (m *MockLogger) Error(msg string, fields ...zap.Field) {
+func (m *MockLogger) Error(msg string, fields ...slog.Attr) {
 	m.ErrorCalls = append(m.ErrorCalls, LogCall{Message: msg, Fields: fields})
 }
 
-func (m *MockLogger) Warn(msg string, fields ...zap.Field) {
+func (m *MockLogger) Warn(msg string, fields ...slog.Attr) {
 	m.WarnCalls = append(m.WarnCalls, LogCall{Message: msg, Fields: fields})
 }
 
-func (m *MockLogger) Info(msg string, fields ...zap.Field) {
+func (m *MockLogger) Info(msg string, fields ...slog.Attr) {
 	m.InfoCalls = append(m.InfoCalls, LogCall{Message: msg, Fields: fields})
 }
 
-func (m *MockLogger) Debug(msg string, fields ...zap.Field) {
+func (m *MockLogger) Debug(msg string, fields ...slog.Attr) {
 	m.DebugCalls = append(m.DebugCalls, LogCall{Message: msg, Fields: fields})
 }
 
@@ -184,462 +200,6 @@ func TestNewClientWithCircuitBreaker(t *testing.T) {
 	assert.NotNil(t, client.circuitBreaker)
 }
 
-// Test TraceOpenAIRequest
-func TestTraceOpenAIRequest(t *testing.T) {
-	tests := []struct {
-		name         string
-		request      openai.ChatCompletionRequest
-		response     openai.ChatCompletionResponse
-		responseErr  error
-		saveErr      error
-		expectedSave bool
-		checkRequest func(t *testing.T, req *Request)
-	}{
-		{
-			name: "successful request with tracking",
-			request: openai.ChatCompletionRequest{
-				Model: "gpt-3.5-turbo",
-				Messages: []openai.ChatCompletionMessage{
-					{Role: openai.ChatMessageRoleUser, Content: "Hello"},
-				},
-			},
-			response: openai.ChatCompletionResponse{
-				Model: "gpt-3.5-turbo",
-				Usage: openai.Usage{
-					PromptTokens:     5,
-					CompletionTokens: 10,
-					TotalTokens:      15,
-				},
-				Choices: []openai.ChatCompletionChoice{
-					{Message: openai.ChatCompletionMessage{Content: "Hi there!"}},
-				},
-			},
-			responseErr:  nil,
-			saveErr:      nil,
-			expectedSave: true,
-			checkRequest: func(t *testing.T, req *Request) {
-				assert.Equal(t, ProviderOpenAI, req.Provider)
-				assert.Equal(t, "gpt-3.5-turbo", req.Model)
-				assert.Equal(t, 5, req.InputTokens)
-				assert.Equal(t, 10, req.OutputTokens)
-				assert.Equal(t, 200, req.StatusCode)
-				assert.Empty(t, req.Error)
-				assert.NotEmpty(t, req.ID)
-				assert.NotEmpty(t, req.TraceID)
-			},
-		},
-		{
-			name: "failed API request still tracks",
-			request: openai.ChatCompletionRequest{
-				Model: "gpt-4",
-			},
-			response:     openai.ChatCompletionResponse{},
-			responseErr:  errors.New("API error: rate limit exceeded"),
-			saveErr:      nil,
-			expectedSave: true,
-			checkRequest: func(t *testing.T, req *Request) {
-				assert.Equal(t, ProviderOpenAI, req.Provider)
-				assert.Equal(t, "gpt-4", req.Model)
-				assert.Equal(t, 0, req.InputTokens)
-				assert.Equal(t, 0, req.OutputTokens)
-				assert.Equal(t, 500, req.StatusCode)
-				assert.Contains(t, req.Error, "rate limit exceeded")
-			},
-		},
-		{
-			name: "tracking failure is logged",
-			request: openai.ChatCompletionRequest{
-				Model: "gpt-3.5-turbo",
-			},
-			response: openai.ChatCompletionResponse{
-				Model: "gpt-3.5-turbo",
-				Usage: openai.Usage{
-					PromptTokens:     5,
-					CompletionTokens: 10,
-				},
-			},
-			responseErr:  nil,
-			saveErr:      errors.New("database connection failed"),
-			expectedSave: true,
-		},
-		{
-			name: "context with user metadata",
-			request: openai.ChatCompletionRequest{
-				Model: "gpt-3.5-turbo",
-			},
-			response: openai.ChatCompletionResponse{
-				Model: "gpt-3.5-turbo",
-				Usage: openai.Usage{
-					PromptTokens: 5,
-				},
-			},
-			responseErr:  nil,
-			saveErr:      nil,
-			expectedSave: true,
-			checkRequest: func(t *testing.T, req *Request) {
-				assert.Len(t, req.Dimensions, 2)
-				// Check that dimensions contain user_id and feature
-				foundUserID := false
-				foundFeature := false
-				for _, dim := range req.Dimensions {
-					if dim.Key == "user_id" && dim.Value == "test-user-123" {
-						foundUserID = true
-					}
-					if dim.Key == "feature" && dim.Value == "test-feature" {
-						foundFeature = true
-					}
-				}
-				assert.True(t, foundUserID, "user_id dimension not found")
-				assert.True(t, foundFeature, "feature dimension not found")
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Setup mock storage
-			mockStorage := &MockStorageAdapter{
-				SaveFunc: func(ctx context.Context, request *Request) error {
-					return tt.saveErr
-				},
-			}
-
-			// Setup mock logger for tracking failure test
-			var mockLogger *MockLogger
-			var client *Client
-			if tt.name == "tracking failure is logged" {
-				mockLogger = &MockLogger{}
-				client = NewClient(mockStorage, WithLogger(mockLogger))
-			} else {
-				client = NewClient(mockStorage)
-			}
-
-			// Setup context with metadata for the last test
-			ctx := context.Background()
-			if tt.name == "context with user metadata" {
-				ctx = WithUserID(ctx, "test-user-123")
-				ctx = WithFeature(ctx, "test-feature")
-			}
-
-			// Mock OpenAI function
-			mockOpenAIFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
-				// Verify the request is passed through correctly
-				assert.Equal(t, tt.request.Model, request.Model)
-				return tt.response, tt.responseErr
-			}
-
-			// Call the method
-			response, err := client.TraceOpenAIRequest(ctx, tt.request, mockOpenAIFunc)
-
-			// Verify response and error are passed through
-			if tt.responseErr != nil {
-				assert.Error(t, err)
-				assert.Equal(t, tt.responseErr, err)
-			} else {
-				assert.NoError(t, err)
-				assert.Equal(t, tt.response, response)
-			}
-
-			// Verify tracking was attempted
-			if tt.expectedSave {
-				assert.Len(t, mockStorage.SaveCalls, 1)
-
-				if tt.checkRequest != nil && len(mockStorage.SaveCalls) > 0 {
-					tt.checkRequest(t, mockStorage.SaveCalls[0].Request)
-				}
-			}
-
-			// Verify logging for tracking failure test
-			if tt.name == "tracking failure is logged" && mockLogger != nil {
-				assert.Len(t, mockLogger.ErrorCalls, 1)
-				assert.Equal(t, "Failed to track request", mockLogger.ErrorCalls[0].Message)
-
-				// Verify error fields contain expected information
-				fields := mockLogger.ErrorCalls[0].Fields
-				assert.True(t, len(fields) > 0)
-
-				// Check that error field is present
-				var hasError bool
-				for _, field := range fields {
-					if field.Key == "error" {
-						hasError = true
-						break
-					}
-				}
-				assert.True(t, hasError, "Error field should be present in log")
-			}
-		})
-	}
-}
-
-// Test async tracking
-func TestAsyncTracking(t *testing.T) {
-	// Channel to detect when async operation completes
-	saveDone := make(chan bool, 1)
-
-	mockStorage := &MockStorageAdapter{
-		SaveFunc: func(ctx context.Context, request *Request) error {
-			saveDone <- true // Signal that save was called
-			return nil
-		},
-	}
-
-	// Create client with async tracking
-	client := NewClient(mockStorage, WithAsyncTracking(true))
-
-	// Setup test data
-	request := openai.ChatCompletionRequest{
-		Model: "gpt-3.5-turbo",
-	}
-
-	response := openai.ChatCompletionResponse{
-		Model: "gpt-3.5-turbo",
-		Usage: openai.Usage{
-			PromptTokens:     5,
-			CompletionTokens: 10,
-		},
-	}
-
-	// Mock OpenAI function
-	mockOpenAIFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
-		return response, nil
-	}
-
-	// Call the method - should return immediately even though tracking is async
-	result, err := client.TraceOpenAIRequest(context.Background(), request, mockOpenAIFunc)
-
-	// Verify API call succeeded immediately
-	assert.NoError(t, err)
-	assert.Equal(t, response, result)
-
-	// Wait for async tracking to complete (with timeout)
-	select {
-	case <-saveDone:
-		// Good, async tracking completed
-		assert.Len(t, mockStorage.SaveCalls, 1)
-	case <-time.After(100 * time.Millisecond):
-		t.Fatal("Async tracking did not complete within timeout")
-	}
-}
-
-// Test sync vs async tracking timing
-func TestSyncVsAsyncTracking(t *testing.T) {
-	// This test verifies that async tracking doesn't block the API response
-	slowSaveCount := 0
-	mockStorage := &MockStorageAdapter{
-		SaveFunc: func(ctx context.Context, request *Request) error {
-			slowSaveCount++
-			time.Sleep(50 * time.Millisecond) // Simulate slow storage
-			return nil
-		},
-	}
-
-	tests := []struct {
-		name          string
-		asyncTracking bool
-		maxDuration   time.Duration
-	}{
-		{
-			name:          "sync tracking waits for storage",
-			asyncTracking: false,
-			maxDuration:   100 * time.Millisecond, // Should take at least 50ms
-		},
-		{
-			name:          "async tracking doesn't wait",
-			asyncTracking: true,
-			maxDuration:   30 * time.Millisecond, // Should be much faster
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			client := NewClient(mockStorage, WithAsyncTracking(tt.asyncTracking))
-
-			request := openai.ChatCompletionRequest{Model: "gpt-3.5-turbo"}
-			response := openai.ChatCompletionResponse{
-				Model: "gpt-3.5-turbo",
-				Usage: openai.Usage{PromptTokens: 5},
-			}
-
-			mockFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
-				return response, nil
-			}
-
-			start := time.Now()
-			result, err := client.TraceOpenAIRequest(context.Background(), request, mockFunc)
-			duration := time.Since(start)
-
-			assert.NoError(t, err)
-			assert.Equal(t, response, result)
-
-			if tt.asyncTracking {
-				// Async should return quickly
-				assert.Less(t, duration, tt.maxDuration, "Async tracking should not block API response")
-
-				// Wait a bit for async operation to complete, then verify it happened
-				time.Sleep(100 * time.Millisecond)
-			} else {
-				// Sync should take longer due to slow storage
-				assert.Greater(t, duration, 40*time.Millisecond, "Sync tracking should wait for storage")
-			}
-		})
-	}
-}
-
-// Test TraceAnthropicRequest
-func TestTraceAnthropicRequest(t *testing.T) {
-	mockStorage := &MockStorageAdapter{}
-	client := NewClient(mockStorage)
-
-	// Setup test data
-	request := anthropic.MessageNewParams{
-		Model:     anthropic.ModelClaude3_5SonnetLatest,
-		MaxTokens: 1000,
-		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(
-				anthropic.NewTextBlock("Hello"),
-			),
-		},
-	}
-
-	response := &anthropic.Message{
-		Model: anthropic.ModelClaude3_5SonnetLatest,
-		Usage: anthropic.Usage{
-			InputTokens:  10,
-			OutputTokens: 20,
-		},
-		// Content field is complex, we'll just verify the tracking works
-	}
-
-	// Mock Anthropic function
-	mockAnthropicFunc := func(ctx context.Context, body anthropic.MessageNewParams, opts ...option.RequestOption) (*anthropic.Message, error) {
-		return response, nil
-	}
-
-	// Call the method
-	ctx := WithTraceID(context.Background(), "test-trace-123")
-	result, err := client.TraceAnthropicRequest(ctx, request, mockAnthropicFunc)
-
-	// Verify response
-	assert.NoError(t, err)
-	assert.Equal(t, response, result)
-
-	// Verify tracking
-	require.Len(t, mockStorage.SaveCalls, 1)
-	savedRequest := mockStorage.SaveCalls[0].Request
-
-	assert.Equal(t, ProviderAnthropic, savedRequest.Provider)
-	assert.Equal(t, string(anthropic.ModelClaude3_5SonnetLatest), savedRequest.Model)
-	assert.Equal(t, 10, savedRequest.InputTokens)
-	assert.Equal(t, 20, savedRequest.OutputTokens)
-	assert.Equal(t, "test-trace-123", savedRequest.TraceID)
-	assert.Equal(t, 200, savedRequest.StatusCode)
-}
-
-// Test TraceMistralRequest
-func TestTraceMistralRequest(t *testing.T) {
-	mockStorage := &MockStorageAdapter{}
-	client := NewClient(mockStorage)
-
-	// Setup test data
-	model := mistral.ModelMistralLargeLatest
-	messages := []mistral.ChatMessage{
-		{Role: mistral.RoleUser, Content: "Hello"},
-	}
-	params := &mistral.ChatRequestParams{
-		MaxTokens: 1000,
-	}
-
-	response := &mistral.ChatCompletionResponse{
-		Model: model,
-		Usage: mistral.UsageInfo{
-			PromptTokens:     15,
-			CompletionTokens: 25,
-			TotalTokens:      40,
-		},
-		Choices: []mistral.ChatCompletionResponseChoice{
-			{
-				Index: 0,
-				Message: mistral.ChatMessage{
-					Role:    mistral.RoleAssistant,
-					Content: "Hi!",
-				},
-				FinishReason: "stop",
-			},
-		},
-	}
-
-	// Mock Mistral function
-	mockMistralFunc := func(model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams) (*mistral.ChatCompletionResponse, error) {
-		return response, nil
-	}
-
-	// Call the method
-	result, err := client.TraceMistralRequest(context.Background(), model, messages, params, mockMistralFunc)
-
-	// Verify response
-	assert.NoError(t, err)
-	assert.Equal(t, response, result)
-
-	// Verify tracking
-	require.Len(t, mockStorage.SaveCalls, 1)
-	savedRequest := mockStorage.SaveCalls[0].Request
-
-	assert.Equal(t, ProviderMistral, savedRequest.Provider)
-	assert.Equal(t, model, savedRequest.Model)
-	assert.Equal(t, 15, savedRequest.InputTokens)
-	assert.Equal(t, 25, savedRequest.OutputTokens)
-	assert.Equal(t, 200, savedRequest.StatusCode)
-}
-
-// Test TraceGoogleRequest
-func TestTraceGoogleRequest(t *testing.T) {
-	mockStorage := &MockStorageAdapter{}
-	client := NewClient(mockStorage)
-
-	// Setup test data
-	parts := []genai.Part{
-		genai.Text("Hello Google"),
-	}
-
-	response := &genai.GenerateContentResponse{
-		UsageMetadata: &genai.UsageMetadata{
-			PromptTokenCount:     5,
-			CandidatesTokenCount: 15,
-			TotalTokenCount:      20,
-		},
-		Candidates: []*genai.Candidate{
-			{
-				Content: &genai.Content{
-					Parts: []genai.Part{genai.Text("Hello from Google!")},
-				},
-			},
-		},
-	}
-
-	// Mock Google function
-	mockGoogleFunc := func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
-		return response, nil
-	}
-
-	// Call the method with model parameter
-	result, err := client.TraceGoogleRequest(context.Background(), "gemini-pro", parts, mockGoogleFunc)
-
-	// Verify response
-	assert.NoError(t, err)
-	assert.Equal(t, response, result)
-
-	// Verify tracking
-	require.Len(t, mockStorage.SaveCalls, 1)
-	savedRequest := mockStorage.SaveCalls[0].Request
-
-	assert.Equal(t, ProviderGoogle, savedRequest.Provider)
-	assert.Equal(t, "gemini-pro", savedRequest.Model) // Now uses the actual model parameter
-	assert.Equal(t, 5, savedRequest.InputTokens)
-	assert.Equal(t, 15, savedRequest.OutputTokens)
-	assert.Equal(t, 200, savedRequest.StatusCode)
-}
-
 // Test GetTokenStats
 func TestGetTokenStats(t *testing.T) {
 	tests := []struct {
@@ -742,50 +302,107 @@ func TestGetTokenStats(t *testing.T) {
 	}
 }
 
-// Test error scenarios
-func TestErrorHandling(t *testing.T) {
-	t.Run("storage query error", func(t *testing.T) {
+func TestGetTokenStatsAggregatePushdown(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	mockStorage := &MockStorageAdapter{
+		AggregateFunc: func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+			assert.Equal(t, []string{"provider", "model"}, groupBy)
+			assert.Equal(t, &since, filter.StartTime)
+			return []*AggregateResult{{
+				Provider:          ProviderOpenAI,
+				Model:             "gpt-4",
+				TotalRequests:     3,
+				TotalTokens:       700,
+				TotalInputTokens:  300,
+				TotalOutputTokens: 400,
+				TotalCost:         0.02,
+				ErrorCount:        1,
+			}}, nil
+		},
+	}
+
+	client := NewClient(mockStorage)
+	stats, err := client.GetTokenStats(context.Background(), &since)
+
+	assert.NoError(t, err)
+	assert.Empty(t, mockStorage.QueryCalls)
+	assert.Equal(t, map[string]*TokenStats{
+		"openai/gpt-4": {
+			Provider:      ProviderOpenAI,
+			Model:         "gpt-4",
+			TotalRequests: 3,
+			InputTokens:   300,
+			OutputTokens:  400,
+			TotalCost:     0.02,
+			ErrorCount:    1,
+		},
+	}, stats)
+}
+
+func TestStorageCapabilities(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("No native aggregation", func(t *testing.T) {
 		mockStorage := &MockStorageAdapter{
-			QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
-				return nil, errors.New("database connection failed")
+			AggregateFunc: func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+				t.Error("Aggregate should not be called")
+				return nil, nil
+			},
+			CapabilitiesFunc: func() StorageCapabilities {
+				return StorageCapabilities{Query: true}
 			},
 		}
+		_, err := NewClient(mockStorage).GetTokenStats(ctx, nil)
+		assert.NoError(t, err)
+		assert.Len(t, mockStorage.QueryCalls, 1)
+	})
 
+	t.Run("Write-only", func(t *testing.T) {
+		mockStorage := &MockStorageAdapter{
+			CapabilitiesFunc: func() StorageCapabilities { return StorageCapabilities{} },
+		}
 		client := NewClient(mockStorage)
-		stats, err := client.GetTokenStats(context.Background(), nil)
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4", 10, 10, time.Second, nil, nil))
 
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "database connection failed")
-		assert.Nil(t, stats)
+		_, err := client.GetTokenStats(ctx, nil)
+		assert.ErrorIs(t, err, ErrQueryNotSupported)
+		_, err = client.GetTokenDistribution(ctx, nil, nil)
+		assert.ErrorIs(t, err, ErrQueryNotSupported)
+		assert.Empty(t, mockStorage.QueryCalls)
 	})
 
-	t.Run("nil usage data handling", func(t *testing.T) {
-		mockStorage := &MockStorageAdapter{}
-		client := NewClient(mockStorage)
-
-		// Mistral with empty usage
-		mistralResponse := &mistral.ChatCompletionResponse{
-			Model: "mistral-small",
-			Usage: mistral.UsageInfo{}, // empty usage
+	t.Run("Wrappers", func(t *testing.T) {
+		mockStorage := &MockStorageAdapter{
+			CapabilitiesFunc: func() StorageCapabilities {
+				return StorageCapabilities{Query: true, Aggregate: true, Watch: true, SoftDelete: true}
+			},
 		}
+		keys, err := NewStaticKeyProvider("k1", map[string][]byte{"k1": make([]byte, 32)})
+		require.NoError(t, err)
+		wrapped := NewPseudonymizedStorage(NewEncryptedStorage(mockStorage, keys), []byte("secret"))
 
-		mockMistralFunc := func(model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams) (*mistral.ChatCompletionResponse, error) {
-			return mistralResponse, nil
-		}
+		// Wrappers do not forward a change feed
+		assert.Equal(t, StorageCapabilities{Query: true, Aggregate: true, SoftDelete: true}, wrapped.Capabilities())
+	})
+}
 
-		_, err := client.TraceMistralRequest(
-			context.Background(),
-			"mistral-small",
-			[]mistral.ChatMessage{{Role: mistral.RoleUser, Content: "test"}},
-			nil,
-			mockMistralFunc,
-		)
+func TestHealthCheck(t *testing.T) {
+	ctx := context.Background()
+	var pingErr error
+	mockStorage := &MockStorageAdapter{
+		PingFunc: func(ctx context.Context) error { return pingErr },
+	}
+	client := NewClient(mockStorage)
 
-		assert.NoError(t, err)
-		require.Len(t, mockStorage.SaveCalls, 1)
-		assert.Equal(t, 0, mockStorage.SaveCalls[0].Request.InputTokens)
-		assert.Equal(t, 0, mockStorage.SaveCalls[0].Request.OutputTokens)
-	})
+	assert.NoError(t, client.HealthCheck(ctx))
+
+	pingErr = errors.New("connection refused")
+	err := client.HealthCheck(ctx)
+	assert.ErrorIs(t, err, pingErr)
+
+	pingErr = nil
+	require.NoError(t, client.Close())
+	assert.ErrorIs(t, client.HealthCheck(ctx), ErrClientClosed)
 }
 
 // Test Close method
@@ -820,152 +437,6 @@ func TestCloseError(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to close connection")
 }
 
-// Test circuit breaker integration
-func TestCircuitBreakerIntegration(t *testing.T) {
-	t.Run("circuit breaker opens after storage failures", func(t *testing.T) {
-		failCount := 0
-		storage := &MockStorageAdapter{
-			SaveFunc: func(ctx context.Context, request *Request) error {
-				failCount++
-				return errors.New("storage error")
-			},
-		}
-
-		// Circuit breaker with max 2 failures
-		client := NewClient(storage, WithCircuitBreaker(2, 50*time.Millisecond))
-
-		request := openai.ChatCompletionRequest{Model: "gpt-3.5-turbo"}
-		mockFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
-			return openai.ChatCompletionResponse{}, nil
-		}
-
-		// First two calls should fail normally
-		for i := 0; i < 2; i++ {
-			_, err := client.TraceOpenAIRequest(context.Background(), request, mockFunc)
-			assert.NoError(t, err) // API call succeeds, only tracking fails
-		}
-		assert.Equal(t, 2, failCount)
-
-		// Circuit should be open now - next call should fail fast
-		_, err := client.TraceOpenAIRequest(context.Background(), request, mockFunc)
-		assert.NoError(t, err)        // API call still succeeds
-		assert.Equal(t, 2, failCount) // Storage not called due to circuit breaker
-
-		// Wait for circuit to reset
-		time.Sleep(60 * time.Millisecond)
-
-		// Update storage to succeed
-		storage.SaveFunc = func(ctx context.Context, request *Request) error {
-			return nil
-		}
-
-		// Should try again
-		_, err = client.TraceOpenAIRequest(context.Background(), request, mockFunc)
-		assert.NoError(t, err)
-	})
-
-	t.Run("circuit breaker logs when open", func(t *testing.T) {
-		storage := &MockStorageAdapter{
-			SaveFunc: func(ctx context.Context, request *Request) error {
-				return errors.New("storage error")
-			},
-		}
-
-		logger := &MockLogger{}
-		client := NewClient(storage, WithLogger(logger), WithCircuitBreaker(1, 100*time.Millisecond))
-
-		request := openai.ChatCompletionRequest{Model: "gpt-3.5-turbo"}
-		mockFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
-			return openai.ChatCompletionResponse{}, nil
-		}
-
-		// First call opens the circuit
-		client.TraceOpenAIRequest(context.Background(), request, mockFunc)
-
-		// Second call should log circuit open error
-		client.TraceOpenAIRequest(context.Background(), request, mockFunc)
-
-		// Should have logged the circuit open error
-		assert.True(t, len(logger.ErrorCalls) >= 1)
-		foundCircuitOpen := false
-		for _, call := range logger.ErrorCalls {
-			// Check if the error message mentions circuit breaker
-			if strings.Contains(call.Message, "Failed to track request") {
-				for _, field := range call.Fields {
-					// Convert field to string and check for circuit breaker error
-					fieldStr := fmt.Sprintf("%v", field)
-					if strings.Contains(fieldStr, "circuit breaker is open") {
-						foundCircuitOpen = true
-						break
-					}
-				}
-			}
-		}
-		assert.True(t, foundCircuitOpen, "Expected to find circuit breaker open error in logs")
-	})
-}
-
-// Test error categorization
-func TestErrorCategorization(t *testing.T) {
-	tests := []struct {
-		name              string
-		apiError          error
-		expectedErrorType ErrorType
-	}{
-		{
-			name:              "rate limit error",
-			apiError:          errors.New("error: rate limit exceeded"),
-			expectedErrorType: ErrorTypeRateLimit,
-		},
-		{
-			name:              "authentication error",
-			apiError:          errors.New("401 Unauthorized: Invalid API key"),
-			expectedErrorType: ErrorTypeAuthentication,
-		},
-		{
-			name:              "timeout error",
-			apiError:          errors.New("context deadline exceeded"),
-			expectedErrorType: ErrorTypeTimeout,
-		},
-		{
-			name:              "network error",
-			apiError:          errors.New("dial tcp: connection refused"),
-			expectedErrorType: ErrorTypeNetwork,
-		},
-		{
-			name:              "server error",
-			apiError:          errors.New("500 Internal Server Error"),
-			expectedErrorType: ErrorTypeServerError,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			var savedRequest *Request
-			storage := &MockStorageAdapter{
-				SaveFunc: func(ctx context.Context, request *Request) error {
-					savedRequest = request
-					return nil
-				},
-			}
-
-			client := NewClient(storage)
-
-			request := openai.ChatCompletionRequest{Model: "gpt-3.5-turbo"}
-			mockFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
-				return openai.ChatCompletionResponse{}, tt.apiError
-			}
-
-			_, err := client.TraceOpenAIRequest(context.Background(), request, mockFunc)
-			assert.Equal(t, tt.apiError, err)
-
-			require.NotNil(t, savedRequest)
-			assert.Equal(t, tt.expectedErrorType, savedRequest.ErrorType)
-			assert.Equal(t, tt.apiError.Error(), savedRequest.Error)
-		})
-	}
-}
-
 // Test validation
 func TestValidation(t *testing.T) {
 	t.Run("NewClient with nil storage panics", func(t *testing.T) {
@@ -980,99 +451,200 @@ func TestValidation(t *testing.T) {
 		assert.NotNil(t, client)
 	})
 
-	t.Run("TraceOpenAIRequest with nil function", func(t *testing.T) {
+	t.Run("Negative token counts are normalized to zero", func(t *testing.T) {
 		storage := &MockStorageAdapter{}
 		client := NewClient(storage)
 
-		request := openai.ChatCompletionRequest{Model: "gpt-3.5-turbo"}
-		_, err := client.TraceOpenAIRequest(context.Background(), request, nil)
+		// Use trackRequest directly to test token validation
+		err := client.trackRequest(
+			context.Background(),
+			&Request{
+				Provider:     ProviderOpenAI,
+				Model:        "gpt-3.5-turbo",
+				InputTokens:  -10, // negative input tokens
+				OutputTokens: -5,  // negative output tokens
+				Latency:      time.Millisecond,
+			},
+			nil,
+			nil,
+		)
+
+		assert.NoError(t, err)
+		require.Len(t, storage.SaveCalls, 1)
 
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "cannot be nil")
+		request := storage.SaveCalls[0].Request
+		assert.Equal(t, int64(0), request.InputTokens)  // Should be normalized to 0
+		assert.Equal(t, int64(0), request.OutputTokens) // Should be normalized to 0
 	})
+}
 
-	t.Run("TraceAnthropicRequest with nil function", func(t *testing.T) {
-		storage := &MockStorageAdapter{}
-		client := NewClient(storage)
-
-		params := anthropic.MessageNewParams{
-			Model: anthropic.ModelClaude3_5SonnetLatest,
-		}
-		_, err := client.TraceAnthropicRequest(context.Background(), params, nil)
+func TestTrackRequest(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
 
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "cannot be nil")
+	ctx := WithFeature(context.Background(), "search")
+	err := client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 10, 5, 50*time.Millisecond, nil, &RequestOptions{
+		ProviderRequestID: "req_123",
+		MessageCount:      3,
+		Dimensions:        map[string]interface{}{"chain": "qa"},
 	})
+	assert.NoError(t, err)
 
-	t.Run("TraceMistralRequest with nil function", func(t *testing.T) {
-		storage := &MockStorageAdapter{}
-		client := NewClient(storage)
+	require.Len(t, storage.SaveCalls, 1)
+	request := storage.SaveCalls[0].Request
+	assert.Equal(t, ProviderOpenAI, request.Provider)
+	assert.Equal(t, int64(15), request.InputTokens+request.OutputTokens)
+	assert.Equal(t, 200, request.StatusCode)
+	assert.Equal(t, "req_123", request.ProviderRequestID)
+	assert.Equal(t, 3, request.MessageCount)
+	assert.Equal(t, int64(15), request.TotalTokens)
+	assert.Equal(t, int64(50), request.LatencyMs)
+	assert.Equal(t, "qa", request.Dimension("chain"))
+	assert.Equal(t, map[string]string{"chain": "qa", "feature": "search"}, request.DimensionsMap())
+}
 
-		_, err := client.TraceMistralRequest(context.Background(), "model", nil, nil, nil)
+func TestTrackRequestRequestedAt(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithAsyncTracking(true))
 
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "cannot be nil")
-	})
+	// Tracked long after the call, e.g. from a queue
+	startedAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	err := client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 10, 5, 2*time.Second, nil, &RequestOptions{RequestedAt: startedAt})
+	assert.NoError(t, err)
+	require.NoError(t, client.Shutdown(context.Background()))
 
-	t.Run("TraceMistralRequest with empty model", func(t *testing.T) {
-		storage := &MockStorageAdapter{}
-		client := NewClient(storage)
+	require.Len(t, storage.SaveCalls, 1)
+	request := storage.SaveCalls[0].Request
+	assert.True(t, startedAt.Equal(request.RequestedAt))
+	assert.True(t, startedAt.Add(2*time.Second).Equal(request.RespondedAt))
+}
 
-		mockFunc := func(model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams) (*mistral.ChatCompletionResponse, error) {
-			return &mistral.ChatCompletionResponse{}, nil
-		}
+func TestTrackRequestCost(t *testing.T) {
+	pricing := NewPricingRegistry()
+	pricing.Set(ProviderOpenAI, "gpt-4o", ModelPrice{InputPerMillion: 2.5, OutputPerMillion: 10})
 
-		_, err := client.TraceMistralRequest(context.Background(), "", nil, nil, mockFunc)
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithPricing(pricing))
 
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "model cannot be empty")
-	})
+	// Computed from pricing
+	err := client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1000, 500, time.Millisecond, nil, nil)
+	assert.NoError(t, err)
 
-	t.Run("TraceGoogleRequest with nil function", func(t *testing.T) {
-		storage := &MockStorageAdapter{}
-		client := NewClient(storage)
+	// Explicit cost wins over pricing
+	cost := 0.42
+	err = client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1000, 500, time.Millisecond, nil, &RequestOptions{Cost: &cost})
+	assert.NoError(t, err)
 
-		_, err := client.TraceGoogleRequest(context.Background(), "model", nil, nil)
+	// Unpriced models have no cost
+	err = client.TrackRequest(context.Background(), ProviderOpenAI, "unknown-model", 1000, 500, time.Millisecond, nil, nil)
+	assert.NoError(t, err)
 
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "cannot be nil")
-	})
+	// An explicit zero cost is kept rather than priced
+	free := 0.0
+	err = client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1000, 500, time.Millisecond, nil, &RequestOptions{Cost: &free})
+	assert.NoError(t, err)
 
-	t.Run("TraceGoogleRequest with empty model", func(t *testing.T) {
-		storage := &MockStorageAdapter{}
-		client := NewClient(storage)
+	require.Len(t, storage.SaveCalls, 4)
+	assert.InDelta(t, 0.0075, storage.SaveCalls[0].Request.Cost, 1e-9)
+	assert.Equal(t, 0.42, storage.SaveCalls[1].Request.Cost)
+	assert.Equal(t, 0.0, storage.SaveCalls[2].Request.Cost)
+	assert.Equal(t, 0.0, storage.SaveCalls[3].Request.Cost)
+}
+
+func TestSampleRate(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithSampleRate(0))
 
-		mockFunc := func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
-			return &genai.GenerateContentResponse{}, nil
+	for i := 0; i < 10; i++ {
+		err := client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 10, 5, time.Millisecond, nil, nil)
+		assert.NoError(t, err)
+	}
+	assert.Empty(t, storage.SaveCalls)
+
+	// Failed requests are always recorded
+	err := client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 10, 0, time.Millisecond, errors.New("server error"), nil)
+	assert.NoError(t, err)
+	assert.Len(t, storage.SaveCalls, 1)
+}
+
+func TestSampledContext(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithSampleRate(0))
+
+	// An upstream decision overrides the sample rate
+	err := client.TrackRequest(WithSampled(context.Background(), true), ProviderOpenAI, "gpt-4o", 10, 5, time.Millisecond, nil, nil)
+	assert.NoError(t, err)
+	assert.Len(t, storage.SaveCalls, 1)
+
+	storage = &MockStorageAdapter{}
+	client = NewClient(storage)
+	unsampled := WithSampled(context.Background(), false)
+	err = client.TrackRequest(unsampled, ProviderOpenAI, "gpt-4o", 10, 5, time.Millisecond, nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, storage.SaveCalls)
+	assert.Equal(t, int64(1), client.Metrics().SampledOut)
+
+	// Failed requests are recorded even when not sampled
+	err = client.TrackRequest(unsampled, ProviderOpenAI, "gpt-4o", 10, 0, time.Millisecond, errors.New("server error"), nil)
+	assert.NoError(t, err)
+	assert.Len(t, storage.SaveCalls, 1)
+}
+
+func TestRetention(t *testing.T) {
+	cutoffs := make(chan time.Time, 10)
+	storage := &MockStorageAdapter{
+		DeleteOlderThanFunc: func(ctx context.Context, before time.Time) (int64, error) {
+			cutoffs <- before
+			return 1, nil
+		},
+	}
+
+	client := NewClient(storage, WithRetention(24*time.Hour, 10*time.Millisecond))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case before := <-cutoffs:
+			assert.WithinDuration(t, time.Now().Add(-24*time.Hour), before, time.Minute)
+		case <-time.After(time.Second):
+			t.Fatal("retention did not run")
 		}
+	}
 
-		_, err := client.TraceGoogleRequest(context.Background(), "", nil, mockFunc)
+	assert.NoError(t, client.Close())
+	assert.NoError(t, client.Close())
+}
 
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "model cannot be empty")
-	})
+// softDeleteStorage adds soft-delete support to the mock
+type softDeleteStorage struct {
+	*MockStorageAdapter
+	purges chan time.Time
+}
 
-	t.Run("Negative token counts are normalized to zero", func(t *testing.T) {
-		storage := &MockStorageAdapter{}
-		client := NewClient(storage)
+func (s *softDeleteStorage) Restore(ctx context.Context, id string) error {
+	return nil
+}
 
-		// Use trackRequest directly to test token validation
-		err := client.trackRequest(
-			context.Background(),
-			ProviderOpenAI,
-			"gpt-3.5-turbo",
-			-10, // negative input tokens
-			-5,  // negative output tokens
-			time.Millisecond,
-			nil,
-			nil,
-		)
+func (s *softDeleteStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	s.purges <- before
+	return 0, nil
+}
 
-		assert.NoError(t, err)
-		require.Len(t, storage.SaveCalls, 1)
+func TestRetentionPurgesSoftDeleted(t *testing.T) {
+	storage := &softDeleteStorage{
+		MockStorageAdapter: &MockStorageAdapter{
+			DeleteOlderThanFunc: func(ctx context.Context, before time.Time) (int64, error) {
+				return 0, nil
+			},
+		},
+		purges: make(chan time.Time, 10),
+	}
+	client := NewClient(storage, WithRetention(24*time.Hour, time.Hour))
+	defer client.Close()
 
-		request := storage.SaveCalls[0].Request
-		assert.Equal(t, 0, request.InputTokens)  // Should be normalized to 0
-		assert.Equal(t, 0, request.OutputTokens) // Should be normalized to 0
-	})
+	select {
+	case before := <-storage.purges:
+		assert.WithinDuration(t, time.Now().Add(-24*time.Hour), before, time.Minute)
+	case <-time.After(time.Second):
+		t.Fatal("purge did not run")
+	}
 }
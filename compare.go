@@ -0,0 +1,186 @@
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Period is the time range from Start up to, but not including, End
+type Period struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// PeriodComparison compares the requests of two periods per group, e.g. this week against
+// last week
+type PeriodComparison struct {
+	PeriodA Period `json:"period_a"`
+	PeriodB Period `json:"period_b"`
+	// Total compares all requests matching the filter
+	Total *GroupComparison `json:"total"`
+	// Groups compares each group found in either period, the largest change in cost first
+	Groups []*GroupComparison `json:"groups"`
+}
+
+// GroupComparison compares the totals of one group in two periods. Deltas are the value in
+// period B minus the value in period A, and changes are deltas relative to period A, e.g.
+// 0.25 for a 25% increase; changes are 0 when period A had nothing to compare with.
+type GroupComparison struct {
+	// A and B hold the group's totals in each period. A group missing from a period has a
+	// result with its grouped fields and zero totals.
+	A *AggregateResult `json:"a"`
+	B *AggregateResult `json:"b"`
+
+	RequestsDelta    int64         `json:"requests_delta"`
+	RequestsChange   float64       `json:"requests_change"`
+	CostDelta        float64       `json:"cost_delta"`
+	CostChange       float64       `json:"cost_change"`
+	TokensDelta      int64         `json:"tokens_delta"`
+	TokensChange     float64       `json:"tokens_change"`
+	AvgLatencyDelta  time.Duration `json:"avg_latency_delta"`
+	AvgLatencyChange float64       `json:"avg_latency_change"`
+	ErrorRateDelta   float64       `json:"error_rate_delta"`
+}
+
+// ComparePeriods aggregates the requests matching filter in periodA and in periodB, grouped
+// by groupBy as in Aggregate, and compares each group, answering "what changed since last
+// week" in one call. The filter's time range and aggregate ordering are replaced by each
+// period's. Both periods are aggregated in one batch; see GetAggregatesMulti.
+func (c *Client) ComparePeriods(ctx context.Context, filter *RequestFilter, periodA, periodB Period, groupBy []string) (*PeriodComparison, error) {
+	for _, period := range []Period{periodA, periodB} {
+		if !period.End.After(period.Start) {
+			return nil, fmt.Errorf("period end %s must be after its start %s", period.End, period.Start)
+		}
+	}
+
+	results, err := c.GetAggregatesMulti(ctx, []AggregateSpec{
+		{GroupBy: groupBy, Filter: periodFilter(filter, periodA)},
+		{GroupBy: groupBy, Filter: periodFilter(filter, periodB)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &PeriodComparison{PeriodA: periodA, PeriodB: periodB}
+	groups := make(map[string]*GroupComparison)
+	group := func(result *AggregateResult) *GroupComparison {
+		key := aggregateGroupKey(result)
+		g, ok := groups[key]
+		if !ok {
+			g = &GroupComparison{A: emptyGroup(result), B: emptyGroup(result)}
+			groups[key] = g
+			comparison.Groups = append(comparison.Groups, g)
+		}
+		return g
+	}
+	totalA := &AggregateResult{Dimensions: []DimensionTag{}}
+	totalB := &AggregateResult{Dimensions: []DimensionTag{}}
+	for _, result := range results[0] {
+		group(result).A = result
+		addAggregate(totalA, result)
+	}
+	for _, result := range results[1] {
+		group(result).B = result
+		addAggregate(totalB, result)
+	}
+
+	for _, g := range comparison.Groups {
+		g.compare()
+	}
+	sort.SliceStable(comparison.Groups, func(i, j int) bool {
+		return math.Abs(comparison.Groups[i].CostDelta) > math.Abs(comparison.Groups[j].CostDelta)
+	})
+	comparison.Total = &GroupComparison{A: finishAggregate(totalA), B: finishAggregate(totalB)}
+	comparison.Total.compare()
+	return comparison, nil
+}
+
+// compare computes the deltas and changes from A to B
+func (g *GroupComparison) compare() {
+	a, b := g.A, g.B
+	g.RequestsDelta = b.TotalRequests - a.TotalRequests
+	g.RequestsChange = relativeChange(float64(a.TotalRequests), float64(b.TotalRequests))
+	g.CostDelta = b.TotalCost - a.TotalCost
+	g.CostChange = relativeChange(a.TotalCost, b.TotalCost)
+	g.TokensDelta = b.TotalTokens - a.TotalTokens
+	g.TokensChange = relativeChange(float64(a.TotalTokens), float64(b.TotalTokens))
+	g.AvgLatencyDelta = b.AvgLatency - a.AvgLatency
+	g.AvgLatencyChange = relativeChange(float64(a.AvgLatency), float64(b.AvgLatency))
+	g.ErrorRateDelta = errorRate(b) - errorRate(a)
+}
+
+// periodFilter returns a copy of filter restricted to period
+func periodFilter(filter *RequestFilter, period Period) *RequestFilter {
+	scoped := RequestFilter{}
+	if filter != nil {
+		scoped = *filter
+	}
+	start := period.Start
+	// EndTime is inclusive
+	end := period.End.Add(-time.Nanosecond)
+	scoped.StartTime = &start
+	scoped.EndTime = &end
+	scoped.AggregateOrderBy = ""
+	scoped.AggregateLimit = 0
+	return &scoped
+}
+
+// aggregateGroupKey identifies the group of an aggregate result by its grouped fields
+func aggregateGroupKey(result *AggregateResult) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%d\x00%s\x00%s\x00%v",
+		result.Provider, result.Model, result.RequestType, result.PromptVersion,
+		result.StatusCode, result.StatusClass, result.ErrorType, result.Dimensions)
+}
+
+// emptyGroup returns a result with the grouped fields of result and zero totals
+func emptyGroup(result *AggregateResult) *AggregateResult {
+	return &AggregateResult{
+		Provider:      result.Provider,
+		Model:         result.Model,
+		RequestType:   result.RequestType,
+		PromptVersion: result.PromptVersion,
+		StatusCode:    result.StatusCode,
+		StatusClass:   result.StatusClass,
+		ErrorType:     result.ErrorType,
+		Dimensions:    append([]DimensionTag{}, result.Dimensions...),
+	}
+}
+
+// addAggregate adds the totals of result to total, summing latency in AvgLatency until
+// finishAggregate averages it
+func addAggregate(total, result *AggregateResult) {
+	total.TotalRequests += result.TotalRequests
+	total.ErrorCount += result.ErrorCount
+	total.TotalInputTokens = AddTokens(total.TotalInputTokens, result.TotalInputTokens)
+	total.TotalOutputTokens = AddTokens(total.TotalOutputTokens, result.TotalOutputTokens)
+	total.TotalTokens = AddTokens(total.TotalTokens, result.TotalTokens)
+	total.TotalCost += result.TotalCost
+	total.AvgLatency += result.AvgLatency * time.Duration(result.TotalRequests)
+	total.TotalMessages += result.TotalMessages
+	total.TotalRequestBytes += result.TotalRequestBytes
+	total.TotalResponseBytes += result.TotalResponseBytes
+}
+
+// finishAggregate computes the averages of a total built with addAggregate
+func finishAggregate(total *AggregateResult) *AggregateResult {
+	if count := total.TotalRequests; count > 0 {
+		total.AvgLatency /= time.Duration(count)
+		total.SuccessRate = float64(count-total.ErrorCount) / float64(count)
+		total.AvgMessageCount = float64(total.TotalMessages) / float64(count)
+		total.AvgRequestBytes = float64(total.TotalRequestBytes) / float64(count)
+		total.AvgResponseBytes = float64(total.TotalResponseBytes) / float64(count)
+	}
+	total.ComputeEfficiency()
+	return total
+}
+
+// errorRate returns the share of failed requests in result
+func errorRate(result *AggregateResult) float64 {
+	if result.TotalRequests == 0 {
+		return 0
+	}
+	return float64(result.ErrorCount) / float64(result.TotalRequests)
+}
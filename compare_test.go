@@ -0,0 +1,77 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparePeriods(t *testing.T) {
+	thisWeek := Period{Start: time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 6, 17, 0, 0, 0, 0, time.UTC)}
+	lastWeek := Period{Start: thisWeek.Start.AddDate(0, 0, -7), End: thisWeek.Start}
+
+	var filters []*RequestFilter
+	storage := &MockStorageAdapter{
+		AggregateFunc: func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+			filters = append(filters, filter)
+			if filter.StartTime.Equal(lastWeek.Start) {
+				return []*AggregateResult{
+					{Model: "gpt-4o", TotalRequests: 100, TotalTokens: 10_000, TotalCost: 10, AvgLatency: time.Second, ErrorCount: 2},
+					{Model: "gpt-3.5-turbo", TotalRequests: 50, TotalTokens: 5_000, TotalCost: 1, AvgLatency: time.Second},
+				}, nil
+			}
+			return []*AggregateResult{
+				{Model: "gpt-4o", TotalRequests: 150, TotalTokens: 12_000, TotalCost: 15, AvgLatency: 1500 * time.Millisecond, ErrorCount: 6},
+				{Model: "gpt-4o-mini", TotalRequests: 40, TotalTokens: 4_000, TotalCost: 0.5, AvgLatency: 500 * time.Millisecond},
+			}, nil
+		},
+	}
+	client := NewClient(storage)
+
+	comparison, err := client.ComparePeriods(context.Background(), &RequestFilter{Provider: ProviderOpenAI, AggregateLimit: 1},
+		lastWeek, thisWeek, []string{"model"})
+	require.NoError(t, err)
+
+	require.Len(t, filters, 2)
+	assert.Equal(t, ProviderOpenAI, filters[0].Provider)
+	assert.True(t, filters[0].EndTime.Before(thisWeek.Start), "period A ends before period B starts")
+	assert.Zero(t, filters[1].AggregateLimit, "periods are compared in full")
+
+	require.Len(t, comparison.Groups, 3)
+	gpt4o := comparison.Groups[0]
+	assert.Equal(t, "gpt-4o", gpt4o.B.Model, "the largest cost change comes first")
+	assert.Equal(t, int64(50), gpt4o.RequestsDelta)
+	assert.InDelta(t, 0.5, gpt4o.RequestsChange, 1e-9)
+	assert.InDelta(t, 5, gpt4o.CostDelta, 1e-9)
+	assert.InDelta(t, 0.2, gpt4o.TokensChange, 1e-9)
+	assert.Equal(t, 500*time.Millisecond, gpt4o.AvgLatencyDelta)
+	assert.InDelta(t, 0.02, gpt4o.ErrorRateDelta, 1e-9)
+
+	byModel := make(map[string]*GroupComparison)
+	for _, g := range comparison.Groups {
+		byModel[g.A.Model] = g
+	}
+	gone := byModel["gpt-3.5-turbo"]
+	require.NotNil(t, gone)
+	assert.Zero(t, gone.B.TotalRequests)
+	assert.InDelta(t, -1, gone.CostChange, 1e-9)
+	added := byModel["gpt-4o-mini"]
+	require.NotNil(t, added)
+	assert.Zero(t, added.A.TotalRequests)
+	assert.Zero(t, added.CostChange, "no change relative to nothing")
+
+	assert.Equal(t, int64(150), comparison.Total.A.TotalRequests)
+	assert.Equal(t, int64(190), comparison.Total.B.TotalRequests)
+	assert.InDelta(t, 4.5, comparison.Total.CostDelta, 1e-9)
+	assert.Equal(t, 1000*time.Millisecond, comparison.Total.A.AvgLatency)
+}
+
+func TestComparePeriodsRejectsEmptyPeriods(t *testing.T) {
+	client := NewClient(&MockStorageAdapter{})
+	now := time.Now()
+	_, err := client.ComparePeriods(context.Background(), nil, Period{Start: now, End: now}, Period{Start: now, End: now.Add(time.Hour)}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,59 @@
+package llmtracer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithConcurrencyLimit lets at most n trace wrapper calls to provider run at once; further
+// calls wait for a slot, or until their context is done, before they are sent. Keeping the
+// number of concurrent calls under the provider's limits avoids bursts that end in a storm
+// of 429s. Streams hold their slot until they are finished or closed.
+//
+// The time spent waiting is recorded in Request.QueueWait and is not part of Latency. Calls
+// made through the HTTP transport are not limited. A limit of zero or less removes it.
+func WithConcurrencyLimit(provider Provider, n int) ClientOption {
+	return func(c *Client) {
+		if n <= 0 {
+			delete(c.concurrency, provider)
+			return
+		}
+		if c.concurrency == nil {
+			c.concurrency = make(map[Provider]chan struct{})
+		}
+		c.concurrency[provider] = make(chan struct{}, n)
+	}
+}
+
+// acquire waits until a call to a provider model fits its rate limits and gets a
+// concurrency slot, returning the function releasing the slot and how long the call waited
+// in all. It returns the context's error when ctx is done first.
+func (c *Client) acquire(ctx context.Context, provider Provider, model string) (func(), time.Duration, error) {
+	wait, err := c.throttle(ctx, provider, model)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	slots, ok := c.concurrency[provider]
+	if !ok {
+		return func() {}, wait, nil
+	}
+
+	select {
+	case slots <- struct{}{}:
+	default:
+		start := time.Now()
+		select {
+		case slots <- struct{}{}:
+			wait += time.Since(start)
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-slots })
+	}, wait, nil
+}
@@ -0,0 +1,78 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	storage := newLifecycleStorage()
+	client := NewClient(storage, WithConcurrencyLimit(ProviderOpenAI, 1))
+
+	var running, maxRunning atomic.Int32
+	started := make(chan struct{}, 2)
+	unblock := make(chan struct{})
+	call := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		if n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		started <- struct{}{}
+		if request.Model == "first" {
+			<-unblock
+		}
+		return openai.ChatCompletionResponse{}, nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, err := client.TraceOpenAIRequest(context.Background(), openai.ChatCompletionRequest{Model: "first"}, call)
+		assert.NoError(t, err)
+	}()
+	<-started
+
+	t.Run("Calls are canceled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := client.TraceOpenAIRequest(ctx, openai.ChatCompletionRequest{Model: "canceled"}, call)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	go func() {
+		defer wg.Done()
+		_, err := client.TraceOpenAIRequest(context.Background(), openai.ChatCompletionRequest{Model: "second"}, call)
+		assert.NoError(t, err)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(unblock)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxRunning.Load())
+	require.Len(t, storage.saves, 2, "the canceled call is not sent or tracked")
+	second := storage.saves[0]
+	if second.Model != "second" {
+		second = storage.saves[1]
+	}
+	assert.GreaterOrEqual(t, second.QueueWait, 40*time.Millisecond)
+	assert.Equal(t, second.QueueWait.Milliseconds(), second.QueueWaitMs)
+	assert.Less(t, second.Latency, second.QueueWait, "the wait is not part of the latency")
+
+	t.Run("Other providers are not limited", func(t *testing.T) {
+		release, wait, err := client.acquire(context.Background(), ProviderAnthropic, "claude-3-5-haiku-latest")
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+		release()
+	})
+}
@@ -0,0 +1,255 @@
+package llmtracer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables read by LoadConfig. They override values from the config file.
+const (
+	EnvStorageType                = "LLMTRACER_STORAGE_TYPE"
+	EnvStorageDSN                 = "LLMTRACER_STORAGE_DSN"
+	EnvAsync                      = "LLMTRACER_ASYNC"
+	EnvSampleRate                 = "LLMTRACER_SAMPLE_RATE"
+	EnvPricingFile                = "LLMTRACER_PRICING_FILE"
+	EnvRetention                  = "LLMTRACER_RETENTION"
+	EnvRetentionInterval          = "LLMTRACER_RETENTION_INTERVAL"
+	EnvCircuitBreakerMaxFailures  = "LLMTRACER_CIRCUIT_BREAKER_MAX_FAILURES"
+	EnvCircuitBreakerResetTimeout = "LLMTRACER_CIRCUIT_BREAKER_RESET_TIMEOUT"
+	EnvServiceName                = "LLMTRACER_SERVICE_NAME"
+	EnvServiceVersion             = "LLMTRACER_SERVICE_VERSION"
+	EnvEnvironment                = "LLMTRACER_ENVIRONMENT"
+	EnvRegion                     = "LLMTRACER_REGION"
+)
+
+// Config describes a tracer deployment. It is usually loaded with LoadConfig.
+type Config struct {
+	Storage        StorageConfig        `yaml:"storage"`
+	Async          AsyncConfig          `yaml:"async"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+	// SampleRate is the fraction of successful requests recorded; nil records everything
+	SampleRate *float64        `yaml:"sample_rate"`
+	Retention  RetentionConfig `yaml:"retention"`
+	Capture    CaptureConfig   `yaml:"capture"`
+	// PricingFile is a pricing file loaded with LoadPricingFile
+	PricingFile string        `yaml:"pricing_file"`
+	Service     ServiceConfig `yaml:"service"`
+	// Dimensions are stamped on every request; see WithGlobalDimensions
+	Dimensions map[string]string `yaml:"dimensions"`
+}
+
+// StorageConfig selects a registered storage adapter and its data source
+type StorageConfig struct {
+	Type string `yaml:"type"`
+	DSN  string `yaml:"dsn"`
+}
+
+// AsyncConfig controls asynchronous tracking
+type AsyncConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// CircuitBreakerConfig enables the storage circuit breaker when MaxFailures is positive
+type CircuitBreakerConfig struct {
+	MaxFailures  int           `yaml:"max_failures"`
+	ResetTimeout time.Duration `yaml:"reset_timeout"`
+}
+
+// RetentionConfig enables deletion of old requests when MaxAge is positive
+type RetentionConfig struct {
+	MaxAge   time.Duration `yaml:"max_age"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// CaptureConfig toggles optional request details
+type CaptureConfig struct {
+	GenerationParams bool `yaml:"generation_params"`
+	PayloadSizes     bool `yaml:"payload_sizes"`
+	Payloads         bool `yaml:"payloads"`
+}
+
+// ServiceConfig describes the service stamped on every request; see WithServiceInfo and
+// WithRegion
+type ServiceConfig struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
+	Environment string `yaml:"environment"`
+	Region      string `yaml:"region"`
+}
+
+// LoadConfig reads a YAML config file and applies LLMTRACER_* environment overrides.
+// ${VAR} references in the file are expanded so secrets such as DSNs can stay in the
+// environment. An empty path loads the configuration from the environment alone.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// applyEnv overrides config values with the environment variables that are set
+func (cfg *Config) applyEnv() error {
+	if v, ok := os.LookupEnv(EnvStorageType); ok {
+		cfg.Storage.Type = v
+	}
+	if v, ok := os.LookupEnv(EnvStorageDSN); ok {
+		cfg.Storage.DSN = v
+	}
+	if v, ok := os.LookupEnv(EnvPricingFile); ok {
+		cfg.PricingFile = v
+	}
+	if v, ok := os.LookupEnv(EnvServiceName); ok {
+		cfg.Service.Name = v
+	}
+	if v, ok := os.LookupEnv(EnvServiceVersion); ok {
+		cfg.Service.Version = v
+	}
+	if v, ok := os.LookupEnv(EnvEnvironment); ok {
+		cfg.Service.Environment = v
+	}
+	if v, ok := os.LookupEnv(EnvRegion); ok {
+		cfg.Service.Region = v
+	}
+	if v, ok := os.LookupEnv(EnvAsync); ok {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvAsync, err)
+		}
+		cfg.Async.Enabled = enabled
+	}
+	if v, ok := os.LookupEnv(EnvSampleRate); ok {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvSampleRate, err)
+		}
+		cfg.SampleRate = &rate
+	}
+	if v, ok := os.LookupEnv(EnvCircuitBreakerMaxFailures); ok {
+		maxFailures, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvCircuitBreakerMaxFailures, err)
+		}
+		cfg.CircuitBreaker.MaxFailures = maxFailures
+	}
+
+	durations := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{EnvRetention, &cfg.Retention.MaxAge},
+		{EnvRetentionInterval, &cfg.Retention.Interval},
+		{EnvCircuitBreakerResetTimeout, &cfg.CircuitBreaker.ResetTimeout},
+	}
+	for _, d := range durations {
+		if v, ok := os.LookupEnv(d.env); ok {
+			duration, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", d.env, err)
+			}
+			*d.dst = duration
+		}
+	}
+
+	return nil
+}
+
+// Validate reports configuration errors
+func (cfg *Config) Validate() error {
+	if cfg.Storage.Type == "" {
+		return errors.New("storage type is required")
+	}
+	if cfg.SampleRate != nil && (*cfg.SampleRate < 0 || *cfg.SampleRate > 1) {
+		return fmt.Errorf("sample rate must be between 0 and 1, got %v", *cfg.SampleRate)
+	}
+	if cfg.CircuitBreaker.MaxFailures > 0 && cfg.CircuitBreaker.ResetTimeout <= 0 {
+		return errors.New("circuit breaker reset timeout is required")
+	}
+	if cfg.Retention.MaxAge < 0 {
+		return errors.New("retention max age cannot be negative")
+	}
+	return nil
+}
+
+// ClientOptions returns the client options described by the config. It fails when the
+// pricing file cannot be loaded.
+func (cfg *Config) ClientOptions() ([]ClientOption, error) {
+	opts := []ClientOption{
+		WithAsyncTracking(cfg.Async.Enabled),
+		WithGenerationParamsCapture(cfg.Capture.GenerationParams),
+		WithPayloadSizeCapture(cfg.Capture.PayloadSizes),
+		WithPayloadCapture(cfg.Capture.Payloads),
+	}
+	if cfg.CircuitBreaker.MaxFailures > 0 {
+		opts = append(opts, WithCircuitBreaker(cfg.CircuitBreaker.MaxFailures, cfg.CircuitBreaker.ResetTimeout))
+	}
+	if cfg.SampleRate != nil {
+		opts = append(opts, WithSampleRate(*cfg.SampleRate))
+	}
+	if cfg.Retention.MaxAge > 0 {
+		opts = append(opts, WithRetention(cfg.Retention.MaxAge, cfg.Retention.Interval))
+	}
+	if cfg.Service.Name != "" || cfg.Service.Version != "" || cfg.Service.Environment != "" {
+		opts = append(opts, WithServiceInfo(cfg.Service.Name, cfg.Service.Version, cfg.Service.Environment))
+	}
+	if cfg.Service.Region != "" {
+		opts = append(opts, WithRegion(cfg.Service.Region))
+	}
+	if len(cfg.Dimensions) > 0 {
+		dimensions := make(map[string]any, len(cfg.Dimensions))
+		for key, value := range cfg.Dimensions {
+			dimensions[key] = value
+		}
+		opts = append(opts, WithGlobalDimensions(dimensions))
+	}
+	if cfg.PricingFile != "" {
+		pricing, err := LoadPricingFile(cfg.PricingFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithPricing(pricing))
+	}
+	return opts, nil
+}
+
+// NewFromConfig loads the config at path (see LoadConfig), opens the configured storage
+// adapter and returns a client wired up accordingly. opts are applied after the config,
+// so code-level options such as WithLogger take precedence.
+func NewFromConfig(path string, opts ...ClientOption) (*Client, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	configOpts, err := cfg.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := OpenStorage(cfg.Storage.Type, cfg.Storage.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+
+	return NewClient(storage, append(configOpts, opts...)...), nil
+}
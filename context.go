@@ -2,6 +2,7 @@ package llmtracer
 
 import (
 	"context"
+	"sync"
 
 	"github.com/google/uuid"
 )
@@ -14,11 +15,46 @@ const (
 	workflowKey   contextKey = "llm_workflow"
 	featureKey    contextKey = "llm_feature"
 	dimensionsKey contextKey = "llm_dimensions"
+
+	promptVersionKey contextKey = "llm_prompt_version"
+	requestHandleKey contextKey = "llm_request_handle"
+	sampledKey       contextKey = "llm_sampled"
+	newTraceKey      contextKey = "llm_new_trace"
+	primaryReadKey   contextKey = "llm_primary_read"
 )
 
-// WithTraceID adds a trace ID to the context
+// WithTraceID adds a trace ID to the context. The ID may come from another tracing system;
+// it is stored as returned by NormalizeTraceID.
 func WithTraceID(ctx context.Context, traceID string) context.Context {
-	return context.WithValue(ctx, traceIDKey, traceID)
+	return context.WithValue(ctx, traceIDKey, NormalizeTraceID(traceID))
+}
+
+// withNewTraceID adds a trace ID generated by this process, which no other service can
+// have recorded requests for yet
+func withNewTraceID(ctx context.Context) context.Context {
+	traceID := uuid.New().String()
+	return context.WithValue(WithTraceID(ctx, traceID), newTraceKey, traceID)
+}
+
+// isNewTrace reports whether traceID was generated by this process for the context
+func isNewTrace(ctx context.Context, traceID string) bool {
+	generated, _ := ctx.Value(newTraceKey).(string)
+	return generated != "" && generated == traceID
+}
+
+// WithPrimaryRead asks storage adapters with a read replica to serve the reads made with
+// the context from the primary, for reads that must see the latest writes
+func WithPrimaryRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadKey, true)
+}
+
+// IsPrimaryRead reports whether reads made with the context must be served by the primary
+func IsPrimaryRead(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	primary, _ := ctx.Value(primaryReadKey).(bool)
+	return primary
 }
 
 // WithUserID adds a user ID to the context
@@ -36,11 +72,98 @@ func WithFeature(ctx context.Context, feature string) context.Context {
 	return context.WithValue(ctx, featureKey, feature)
 }
 
+// WithPromptVersion adds the version of the prompt template used by the request to the context
+func WithPromptVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, promptVersionKey, version)
+}
+
+// WithSampled records an upstream sampling decision, e.g. the sampled flag of an OpenTelemetry
+// span, in the context. Successful requests made with the context are recorded when sampled
+// and skipped when not, instead of applying WithSampleRate, so the tracer keeps the same
+// traces as distributed tracing. Failed requests are recorded regardless.
+func WithSampled(ctx context.Context, sampled bool) context.Context {
+	return context.WithValue(ctx, sampledKey, sampled)
+}
+
+// GetSampledFromContext returns the sampling decision recorded with WithSampled, and whether
+// one was recorded
+func GetSampledFromContext(ctx context.Context) (sampled bool, ok bool) {
+	if ctx == nil {
+		return false, false
+	}
+	sampled, ok = ctx.Value(sampledKey).(bool)
+	return sampled, ok
+}
+
 // WithDimensions adds custom dimensions to the context
 func WithDimensions(ctx context.Context, dimensions map[string]interface{}) context.Context {
 	return context.WithValue(ctx, dimensionsKey, dimensions)
 }
 
+// ContextFromRequest returns a context carrying the trace ID of a tracked request, so a
+// follow-up call in the same workflow joins its trace
+func ContextFromRequest(ctx context.Context, request *Request) context.Context {
+	if request == nil || request.TraceID == "" {
+		return ctx
+	}
+	return WithTraceID(ctx, request.TraceID)
+}
+
+// RequestHandle receives the requests tracked by trace wrappers called with its context.
+// It lets callers learn the trace ID a wrapper generated without managing IDs themselves.
+type RequestHandle struct {
+	mu      sync.Mutex
+	request *Request
+}
+
+// WithRequestHandle returns a context that records tracked requests into the returned handle
+func WithRequestHandle(ctx context.Context) (context.Context, *RequestHandle) {
+	handle := &RequestHandle{}
+	return context.WithValue(ctx, requestHandleKey, handle), handle
+}
+
+// Request returns a copy of the most recently tracked request, or nil if none was tracked.
+// With async tracking the copy is taken before the request is saved, so ID is not set.
+func (h *RequestHandle) Request() *Request {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.request == nil {
+		return nil
+	}
+	request := *h.request
+	return &request
+}
+
+// TraceID returns the trace ID of the most recently tracked request, or "" if none was tracked
+func (h *RequestHandle) TraceID() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.request == nil {
+		return ""
+	}
+	return h.request.TraceID
+}
+
+// Context returns ctx carrying the trace ID of the most recently tracked request
+func (h *RequestHandle) Context(ctx context.Context) context.Context {
+	return ContextFromRequest(ctx, h.Request())
+}
+
+// recordRequestHandle stores a copy of request in the context's handle, if any
+func recordRequestHandle(ctx context.Context, request *Request) {
+	if ctx == nil {
+		return
+	}
+	handle, ok := ctx.Value(requestHandleKey).(*RequestHandle)
+	if !ok {
+		return
+	}
+	snapshot := *request
+	handle.mu.Lock()
+	handle.request = &snapshot
+	handle.mu.Unlock()
+}
+
 // GetTraceIDFromContext extracts trace ID from context, generates one if missing
 func GetTraceIDFromContext(ctx context.Context) string {
 	if ctx == nil {
@@ -54,6 +177,16 @@ func GetTraceIDFromContext(ctx context.Context) string {
 	return uuid.New().String()
 }
 
+// GetPromptVersionFromContext extracts the prompt version from context, or "" when unset
+func GetPromptVersionFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	version, _ := ctx.Value(promptVersionKey).(string)
+	return version
+}
+
 // GetDimensionsFromContext extracts all tracking dimensions from context
 func GetDimensionsFromContext(ctx context.Context) map[string]interface{} {
 	dimensions := make(map[string]interface{})
@@ -71,15 +204,15 @@ func GetDimensionsFromContext(ctx context.Context) map[string]interface{} {
 
 	// Add individual context values
 	if userID, ok := ctx.Value(userIDKey).(string); ok && userID != "" {
-		dimensions["user_id"] = userID
+		dimensions[DimensionUserID] = userID
 	}
 
 	if workflow, ok := ctx.Value(workflowKey).(string); ok && workflow != "" {
-		dimensions["workflow"] = workflow
+		dimensions[DimensionWorkflow] = workflow
 	}
 
 	if feature, ok := ctx.Value(featureKey).(string); ok && feature != "" {
-		dimensions["feature"] = feature
+		dimensions[DimensionFeature] = feature
 	}
 
 	return dimensions
@@ -3,6 +3,7 @@ package llmtracer
 import (
 	"context"
 	"testing"
+	"time"
 )
 
 func TestContextHelpers(t *testing.T) {
@@ -37,6 +38,17 @@ func TestContextHelpers(t *testing.T) {
 		}
 	})
 
+	t.Run("WithPromptVersion", func(t *testing.T) {
+		ctx := WithPromptVersion(context.Background(), "summarize-v3")
+
+		if version := GetPromptVersionFromContext(ctx); version != "summarize-v3" {
+			t.Errorf("Expected prompt version summarize-v3, got %s", version)
+		}
+		if version := GetPromptVersionFromContext(context.Background()); version != "" {
+			t.Errorf("Expected empty prompt version, got %s", version)
+		}
+	})
+
 	t.Run("GetDimensionsFromContext", func(t *testing.T) {
 		ctx := context.Background()
 
@@ -79,3 +91,64 @@ func TestContextHelpers(t *testing.T) {
 		}
 	})
 }
+
+func TestTraceIDPropagation(t *testing.T) {
+	t.Run("ContextFromRequest", func(t *testing.T) {
+		ctx := ContextFromRequest(context.Background(), &Request{TraceID: "trace-1"})
+		if got := GetTraceIDFromContext(ctx); got != "trace-1" {
+			t.Errorf("Expected trace ID trace-1, got %s", got)
+		}
+
+		base := context.Background()
+		if ContextFromRequest(base, nil) != base {
+			t.Error("Expected nil request to leave the context unchanged")
+		}
+	})
+
+	t.Run("chained calls share the generated trace ID", func(t *testing.T) {
+		for _, async := range []bool{false, true} {
+			saved := make(chan *Request, 2)
+			storage := &MockStorageAdapter{
+				SaveFunc: func(ctx context.Context, request *Request) error {
+					saved <- request
+					return nil
+				},
+			}
+			client := NewClient(storage, WithAsyncTracking(async))
+
+			ctx, handle := WithRequestHandle(context.Background())
+			if handle.Request() != nil || handle.TraceID() != "" {
+				t.Fatal("Expected empty handle before any call")
+			}
+
+			waitSaved := func() *Request {
+				select {
+				case r := <-saved:
+					return r
+				case <-time.After(time.Second):
+					t.Fatalf("Request was not saved (async=%v)", async)
+					return nil
+				}
+			}
+
+			if err := client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 10, 5, 0, nil, nil); err != nil {
+				t.Fatalf("TrackRequest failed: %v", err)
+			}
+			traceID := handle.TraceID()
+			if traceID == "" {
+				t.Fatalf("Expected handle to capture a trace ID (async=%v)", async)
+			}
+			first := waitSaved()
+
+			next := handle.Context(context.Background())
+			if err := client.TrackRequest(next, ProviderAnthropic, "claude-3-5-haiku-latest", 3, 1, 0, nil, nil); err != nil {
+				t.Fatalf("TrackRequest failed: %v", err)
+			}
+			second := waitSaved()
+
+			if first.TraceID != traceID || second.TraceID != traceID {
+				t.Errorf("Expected both requests in trace %s, got %s and %s (async=%v)", traceID, first.TraceID, second.TraceID, async)
+			}
+		}
+	})
+}
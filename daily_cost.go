@@ -0,0 +1,139 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// dailyCostBatchDays caps the days aggregated per storage round trip, keeping the batched
+// statement within database limits on compound queries
+const dailyCostBatchDays = 90
+
+// DailyUserCost is the usage of one user on one day
+type DailyUserCost struct {
+	// Day is midnight at the start of the day, in the location of the since time
+	Day time.Time `json:"day"`
+	// UserID is empty for requests tracked without a user ID
+	UserID       string  `json:"user_id"`
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// GetDailyCostByUser returns the usage of each user per day, from the day containing since
+// until today. Days are calendar days in since's location, so pass a time in the zone the
+// report is read in. Results are ordered by day, then by cost, highest first.
+//
+// Each day is aggregated by the storage adapter, in batches of days per round trip, so no
+// raw requests are loaded; adapters that cannot aggregate are aggregated in memory instead.
+func (c *Client) GetDailyCostByUser(ctx context.Context, since time.Time) ([]*DailyUserCost, error) {
+	location := since.Location()
+	start := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, location)
+	now := time.Now().In(location)
+
+	var days []time.Time
+	for day := start; !day.After(now); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	var costs []*DailyUserCost
+	var err error
+	if c.storage.Capabilities().Aggregate {
+		costs, err = c.dailyCostFromAggregates(ctx, days)
+	}
+	if !c.storage.Capabilities().Aggregate || errors.Is(err, ErrAggregateNotSupported) {
+		costs, err = c.dailyCostFromQuery(ctx, days)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(costs, func(i, j int) bool {
+		if !costs[i].Day.Equal(costs[j].Day) {
+			return costs[i].Day.Before(costs[j].Day)
+		}
+		if costs[i].Cost != costs[j].Cost {
+			return costs[i].Cost > costs[j].Cost
+		}
+		return costs[i].UserID < costs[j].UserID
+	})
+	return costs, nil
+}
+
+// dailyCostFromAggregates aggregates each day grouped by user
+func (c *Client) dailyCostFromAggregates(ctx context.Context, days []time.Time) ([]*DailyUserCost, error) {
+	var costs []*DailyUserCost
+	for batchStart := 0; batchStart < len(days); batchStart += dailyCostBatchDays {
+		batch := days[batchStart:min(batchStart+dailyCostBatchDays, len(days))]
+		specs := make([]AggregateSpec, len(batch))
+		for i, day := range batch {
+			// Filter time bounds are inclusive, and UTC so databases that compare
+			// timestamps as text see consistent offsets
+			first := day.UTC()
+			last := day.AddDate(0, 0, 1).Add(-time.Nanosecond).UTC()
+			specs[i] = AggregateSpec{
+				GroupBy: []string{GroupByDimension(DimensionUserID)},
+				Filter:  &RequestFilter{StartTime: &first, EndTime: &last},
+			}
+		}
+
+		results, err := c.GetAggregatesMulti(ctx, specs)
+		if err != nil {
+			return nil, err
+		}
+		for i, dayResults := range results {
+			for _, result := range dayResults {
+				if result.TotalRequests == 0 {
+					continue
+				}
+				costs = append(costs, &DailyUserCost{
+					Day:          batch[i],
+					UserID:       result.Dimension(DimensionUserID),
+					Requests:     result.TotalRequests,
+					InputTokens:  result.TotalInputTokens,
+					OutputTokens: result.TotalOutputTokens,
+					Cost:         result.TotalCost,
+				})
+			}
+		}
+	}
+	return costs, nil
+}
+
+// dailyCostFromQuery groups the requests of the days by day and user in memory
+func (c *Client) dailyCostFromQuery(ctx context.Context, days []time.Time) ([]*DailyUserCost, error) {
+	if len(days) == 0 {
+		return nil, nil
+	}
+	first := days[0].UTC()
+	requests, err := c.query(ctx, &RequestFilter{StartTime: &first})
+	if err != nil {
+		return nil, err
+	}
+
+	type dayUser struct {
+		day    time.Time
+		userID string
+	}
+	location := days[0].Location()
+	groups := make(map[dayUser]*DailyUserCost)
+	var costs []*DailyUserCost
+	for _, request := range requests {
+		at := request.RequestedAt.In(location)
+		key := dayUser{time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, location), request.Dimension(DimensionUserID)}
+		cost, ok := groups[key]
+		if !ok {
+			cost = &DailyUserCost{Day: key.day, UserID: key.userID}
+			groups[key] = cost
+			costs = append(costs, cost)
+		}
+		cost.Requests++
+		cost.InputTokens = AddTokens(cost.InputTokens, request.InputTokens)
+		cost.OutputTokens = AddTokens(cost.OutputTokens, request.OutputTokens)
+		cost.Cost += request.Cost
+	}
+	return costs, nil
+}
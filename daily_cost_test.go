@@ -0,0 +1,127 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDailyCostByUser(t *testing.T) {
+	ctx := context.Background()
+	location := time.FixedZone("UTC-5", -5*60*60)
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	yesterday := today.AddDate(0, 0, -1)
+
+	request := func(at time.Time, userID string, cost float64) *Request {
+		return &Request{
+			Provider:     ProviderOpenAI,
+			Model:        "gpt-4o",
+			InputTokens:  100,
+			OutputTokens: 10,
+			Cost:         cost,
+			RequestedAt:  at,
+			Dimensions:   []DimensionTag{{Key: "user_id", Value: userID}},
+		}
+	}
+	requests := []*Request{
+		request(yesterday.Add(-time.Minute), "alice", 100), // before the range
+		request(yesterday.Add(time.Hour), "alice", 1),
+		request(yesterday.Add(2*time.Hour), "alice", 2),
+		request(yesterday.Add(3*time.Hour), "bob", 5),
+		// Late yesterday in UTC-5 is already today in UTC
+		request(today.Add(-time.Hour), "bob", 1),
+		request(today, "alice", 4),
+		{Provider: ProviderOpenAI, Model: "gpt-4o", Cost: 0.5, RequestedAt: today.Add(time.Second)},
+	}
+
+	expected := []*DailyUserCost{
+		{Day: yesterday, UserID: "bob", Requests: 2, InputTokens: 200, OutputTokens: 20, Cost: 6},
+		{Day: yesterday, UserID: "alice", Requests: 2, InputTokens: 200, OutputTokens: 20, Cost: 3},
+		{Day: today, UserID: "alice", Requests: 1, InputTokens: 100, OutputTokens: 10, Cost: 4},
+		{Day: today, UserID: "", Requests: 1, Cost: 0.5},
+	}
+
+	t.Run("Aggregated per day by the adapter", func(t *testing.T) {
+		var batches int
+		mock := &MockStorageAdapter{
+			CapabilitiesFunc: func() StorageCapabilities { return StorageCapabilities{Query: true, Aggregate: true} },
+			AggregateFunc: func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+				t.Fatal("days are aggregated in one batch")
+				return nil, nil
+			},
+		}
+		client := NewClient(&aggregatingStorage{MockStorageAdapter: mock, requests: requests, batches: &batches})
+
+		costs, err := client.GetDailyCostByUser(ctx, yesterday.Add(5*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 1, batches)
+		assertDailyCosts(t, expected, costs)
+	})
+
+	t.Run("Grouped in memory", func(t *testing.T) {
+		storage := &MockStorageAdapter{
+			QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+				var matching []*Request
+				for _, request := range requests {
+					if filter.Matches(request) {
+						matching = append(matching, request)
+					}
+				}
+				return matching, nil
+			},
+		}
+		costs, err := NewClient(storage).GetDailyCostByUser(ctx, yesterday)
+		require.NoError(t, err)
+		assertDailyCosts(t, expected, costs)
+	})
+}
+
+// aggregatingStorage aggregates fixed requests by user for each spec of a batch
+type aggregatingStorage struct {
+	*MockStorageAdapter
+	requests []*Request
+	batches  *int
+}
+
+func (s *aggregatingStorage) AggregateMulti(ctx context.Context, specs []AggregateSpec) ([][]*AggregateResult, error) {
+	*s.batches++
+	results := make([][]*AggregateResult, len(specs))
+	for i, spec := range specs {
+		groups := make(map[string]*AggregateResult)
+		for _, request := range s.requests {
+			if !spec.Filter.Matches(request) {
+				continue
+			}
+			userID := request.Dimension("user_id")
+			group, ok := groups[userID]
+			if !ok {
+				group = &AggregateResult{Dimensions: []DimensionTag{{Key: "user_id", Value: userID}}}
+				groups[userID] = group
+				results[i] = append(results[i], group)
+			}
+			group.TotalRequests++
+			group.TotalInputTokens += int64(request.InputTokens)
+			group.TotalOutputTokens += int64(request.OutputTokens)
+			group.TotalCost += request.Cost
+		}
+	}
+	return results, nil
+}
+
+func assertDailyCosts(t *testing.T, expected, actual []*DailyUserCost) {
+	t.Helper()
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		assert.True(t, expected[i].Day.Equal(actual[i].Day), "day %d: expected %v, got %v", i, expected[i].Day, actual[i].Day)
+		assert.Equal(t, expected[i].Day.Location(), actual[i].Day.Location())
+		assert.Equal(t, expected[i].UserID, actual[i].UserID)
+		assert.Equal(t, expected[i].Requests, actual[i].Requests)
+		assert.Equal(t, expected[i].InputTokens, actual[i].InputTokens)
+		assert.Equal(t, expected[i].OutputTokens, actual[i].OutputTokens)
+		assert.InDelta(t, expected[i].Cost, actual[i].Cost, 1e-9)
+	}
+}
@@ -0,0 +1,110 @@
+package llmtracer
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const idempotencyKeyKey contextKey = "llm_idempotency_key"
+
+// WithIdempotencyKey marks the calls tracked with the context as one logical call, so a
+// client created with WithDeduplication records it once however many wrappers track it
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey, key)
+}
+
+// GetIdempotencyKeyFromContext returns the idempotency key of a context, or "" when it has none
+func GetIdempotencyKeyFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	key, _ := ctx.Value(idempotencyKeyKey).(string)
+	return key
+}
+
+// WithDeduplication drops track calls that repeat one tracked less than window earlier,
+// protecting aggregates from code paths instrumented twice, e.g. by a trace wrapper and
+// the HTTP transport during a migration. Calls repeat each other when they share an
+// idempotency key (see WithIdempotencyKey and RequestOptions.IdempotencyKey), or when they
+// have no key and share the trace ID, provider, model and token counts. Calls without
+// tokens, such as most failed calls, are only deduplicated by key, so retries are kept.
+//
+// Dropped calls are counted in ClientMetrics.Deduplicated. Calls are remembered in memory,
+// so duplicates are only caught within one process.
+func WithDeduplication(window time.Duration) ClientOption {
+	return func(c *Client) {
+		if window > 0 {
+			c.dedup = newDedupWindow(window)
+		}
+	}
+}
+
+// dedupWindow remembers when each key was last tracked
+type dedupWindow struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+func newDedupWindow(window time.Duration) *dedupWindow {
+	return &dedupWindow{window: window, seen: make(map[string]time.Time)}
+}
+
+// duplicate reports whether key was tracked less than the window before now, remembering
+// it otherwise
+func (d *dedupWindow) duplicate(key string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Sub(d.lastPrune) >= d.window {
+		for seenKey, seenAt := range d.seen {
+			if now.Sub(seenAt) >= d.window {
+				delete(d.seen, seenKey)
+			}
+		}
+		d.lastPrune = now
+	}
+
+	if seenAt, ok := d.seen[key]; ok && now.Sub(seenAt) < d.window {
+		return true
+	}
+	d.seen[key] = now
+	return false
+}
+
+// dedupKey returns the key identifying a tracked call for WithDeduplication, or "" when
+// the call cannot be matched
+func dedupKey(ctx context.Context, request *Request) string {
+	if key := GetIdempotencyKeyFromContext(ctx); key != "" {
+		return "key\x00" + key
+	}
+	if request.TraceID == "" || (request.InputTokens <= 0 && request.OutputTokens <= 0) {
+		return ""
+	}
+	return strings.Join([]string{
+		"call",
+		request.TraceID,
+		string(request.Provider),
+		request.Model,
+		strconv.FormatInt(request.InputTokens, 10),
+		strconv.FormatInt(request.OutputTokens, 10),
+	}, "\x00")
+}
+
+// isDuplicate reports whether WithDeduplication drops the tracked call
+func (c *Client) isDuplicate(ctx context.Context, request *Request) bool {
+	if c.dedup == nil {
+		return false
+	}
+	key := dedupKey(ctx, request)
+	if key == "" || !c.dedup.duplicate(key, time.Now()) {
+		return false
+	}
+	c.metrics.deduplicated.Add(1)
+	return true
+}
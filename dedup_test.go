@@ -0,0 +1,74 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDeduplication(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithDeduplication(time.Minute))
+	ctx := WithTraceID(context.Background(), "trace-1")
+
+	// Same trace, provider, model and tokens: tracked twice by overlapping instrumentation
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 20, time.Second, nil, nil))
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 20, time.Second, nil, nil))
+	assert.Len(t, storage.SaveCalls, 1)
+
+	// Different tokens, model or trace are separate calls
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 21, time.Second, nil, nil))
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o-mini", 100, 20, time.Second, nil, nil))
+	require.NoError(t, client.TrackRequest(WithTraceID(ctx, "trace-2"), ProviderOpenAI, "gpt-4o", 100, 20, time.Second, nil, nil))
+	assert.Len(t, storage.SaveCalls, 4)
+
+	// Failed calls without tokens are retries, not duplicates
+	apiErr := errors.New("rate limit exceeded")
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 0, 0, time.Second, apiErr, nil))
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 0, 0, time.Second, apiErr, nil))
+	assert.Len(t, storage.SaveCalls, 6)
+
+	assert.Equal(t, int64(1), client.Metrics().Deduplicated)
+}
+
+func TestWithDeduplicationIdempotencyKey(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithDeduplication(time.Minute))
+
+	// The key matches calls whatever their trace and tokens
+	ctx := WithIdempotencyKey(context.Background(), "order-42")
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 20, time.Second, nil, nil))
+	require.NoError(t, client.TrackRequest(WithTraceID(ctx, "other"), ProviderOpenAI, "gpt-4o", 0, 0, time.Second, errors.New("timeout"), nil))
+	assert.Len(t, storage.SaveCalls, 1)
+
+	opts := &RequestOptions{IdempotencyKey: "order-43"}
+	require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 1, time.Second, nil, opts))
+	require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 2, 2, time.Second, nil, opts))
+	assert.Len(t, storage.SaveCalls, 2)
+	assert.Equal(t, int64(2), client.Metrics().Deduplicated)
+}
+
+func TestWithDeduplicationDisabled(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+	ctx := WithIdempotencyKey(WithTraceID(context.Background(), "trace-1"), "order-42")
+
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 20, time.Second, nil, nil))
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 20, time.Second, nil, nil))
+	assert.Len(t, storage.SaveCalls, 2)
+}
+
+func TestDedupWindowExpires(t *testing.T) {
+	window := newDedupWindow(time.Second)
+	now := time.Now()
+
+	assert.False(t, window.duplicate("a", now))
+	assert.True(t, window.duplicate("a", now.Add(500*time.Millisecond)))
+	assert.False(t, window.duplicate("a", now.Add(time.Second)), "calls outside the window are kept")
+	assert.False(t, window.duplicate("b", now.Add(3*time.Second)))
+	assert.Len(t, window.seen, 1, "expired keys are pruned")
+}
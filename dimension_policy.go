@@ -0,0 +1,169 @@
+package llmtracer
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// DimensionOverflowValue replaces the values of a dimension key once the key has reached
+// the limit set by WithMaxDimensionCardinality
+const DimensionOverflowValue = "__overflow__"
+
+// dimensionPolicy decides which dimensions are persisted
+type dimensionPolicy struct {
+	allow          map[string]bool
+	deny           map[string]bool
+	maxCardinality int
+	alertThreshold int
+	alert          func(key string, values int)
+	normalizeKeys  bool
+	rules          map[string]DimensionRule
+	defaults       map[string]string
+
+	mu         sync.Mutex
+	values     map[string]map[string]struct{}
+	overflowed map[string]bool
+}
+
+// WithDimensionAllowlist persists only the given dimension keys; every other key is
+// stripped before saving. Keys set by the tracer itself, such as the workflow dimensions,
+// must be listed too to be kept.
+func WithDimensionAllowlist(keys ...string) ClientOption {
+	return func(c *Client) {
+		c.dimensions.allow = keySet(keys)
+	}
+}
+
+// WithDimensionDenylist strips the given dimension keys before saving
+func WithDimensionDenylist(keys ...string) ClientOption {
+	return func(c *Client) {
+		c.dimensions.deny = keySet(keys)
+	}
+}
+
+// WithMaxDimensionCardinality caps the distinct values saved per dimension key, so a caller
+// that puts e.g. a request UUID in a dimension cannot grow the dimension table without
+// bound. Once a key has seen max values, new values are saved as DimensionOverflowValue
+// and a warning is logged. Values are counted per client since it was created.
+func WithMaxDimensionCardinality(max int) ClientOption {
+	return func(c *Client) {
+		c.dimensions.maxCardinality = max
+	}
+}
+
+// WithDimensionCardinalityAlert calls alert once per dimension key when the key reaches
+// threshold distinct values, counted per client since it was created. alert runs in the
+// tracking path and must not block.
+func WithDimensionCardinalityAlert(threshold int, alert func(key string, values int)) ClientOption {
+	return func(c *Client) {
+		c.dimensions.alertThreshold = threshold
+		c.dimensions.alert = alert
+	}
+}
+
+func keySet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set
+}
+
+// dimensionTags converts map dimensions to a DimensionTag slice, applying the client's
+// dimension policy
+func (c *Client) dimensionTags(trackingContext map[string]interface{}) []DimensionTag {
+	if c.dimensions.normalizeKeys {
+		trackingContext = normalizeDimensionKeys(trackingContext)
+	}
+	trackingContext = c.withDefaultDimensions(trackingContext)
+
+	var dimensions []DimensionTag
+	for key, value := range trackingContext {
+		if (c.dimensions.allow != nil && !c.dimensions.allow[key]) || c.dimensions.deny[key] {
+			continue
+		}
+		formatted := fmt.Sprintf("%v", value)
+		if rule, ok := c.dimensions.rules[key]; ok {
+			formatted = rule.apply(formatted)
+		}
+		dimensions = append(dimensions, DimensionTag{
+			Key:   key,
+			Value: c.limitCardinality(key, formatted),
+		})
+	}
+	return dimensions
+}
+
+// normalizeDimensionKeys returns the dimensions with snake_case keys. When several keys
+// normalize to the same key, the one already in snake_case wins, or else the smallest, so
+// the result doesn't depend on map order.
+func normalizeDimensionKeys(trackingContext map[string]interface{}) map[string]interface{} {
+	sources := make(map[string]string, len(trackingContext))
+	for key := range trackingContext {
+		normalized := NormalizeDimensionKey(key)
+		source, taken := sources[normalized]
+		if !taken || (source != normalized && (key == normalized || key < source)) {
+			sources[normalized] = key
+		}
+	}
+
+	normalized := make(map[string]interface{}, len(sources))
+	for key, source := range sources {
+		normalized[key] = trackingContext[source]
+	}
+	return normalized
+}
+
+// limitCardinality returns value, or DimensionOverflowValue when the key has already seen
+// the maximum number of distinct values, and raises the cardinality alert
+func (c *Client) limitCardinality(key, value string) string {
+	p := &c.dimensions
+	alerting := p.alertThreshold > 0 && p.alert != nil
+	if p.maxCardinality <= 0 && !alerting {
+		return value
+	}
+
+	p.mu.Lock()
+	if p.values == nil {
+		p.values = make(map[string]map[string]struct{})
+		p.overflowed = make(map[string]bool)
+	}
+	seen, ok := p.values[key]
+	if !ok {
+		seen = make(map[string]struct{})
+		p.values[key] = seen
+	}
+	if _, ok := seen[value]; ok {
+		p.mu.Unlock()
+		return value
+	}
+
+	if p.maxCardinality > 0 && len(seen) >= p.maxCardinality {
+		warn := !p.overflowed[key]
+		p.overflowed[key] = true
+		p.mu.Unlock()
+
+		c.metrics.dimensionsOverflowed.Add(1)
+		if warn {
+			c.logger.Warn("Dimension reached its cardinality limit; new values are saved as "+DimensionOverflowValue,
+				slog.String("dimension", key),
+				slog.Int("max_cardinality", p.maxCardinality),
+			)
+		}
+		return DimensionOverflowValue
+	}
+	// Without a cap, values past the alert threshold need not be remembered
+	if p.maxCardinality <= 0 && len(seen) >= p.alertThreshold {
+		p.mu.Unlock()
+		return value
+	}
+	seen[value] = struct{}{}
+	alert := alerting && len(seen) == p.alertThreshold
+	p.mu.Unlock()
+
+	if alert {
+		p.alert(key, p.alertThreshold)
+	}
+	return value
+}
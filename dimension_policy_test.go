@@ -0,0 +1,62 @@
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDimensionPolicy(t *testing.T) {
+	track := func(t *testing.T, client *Client, dims map[string]interface{}) {
+		ctx := WithDimensions(context.Background(), dims)
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, time.Second, nil, nil))
+	}
+
+	t.Run("allowlist", func(t *testing.T) {
+		mock, saved := newRecordingStorage()
+		client := NewClient(mock, WithDimensionAllowlist("team"))
+		track(t, client, map[string]interface{}{"team": "search", "request_uuid": "abc"})
+
+		require.Len(t, saved, 1)
+		for _, req := range saved {
+			assert.Equal(t, map[string]string{"team": "search"}, req.DimensionsMap())
+		}
+	})
+
+	t.Run("denylist", func(t *testing.T) {
+		mock, saved := newRecordingStorage()
+		client := NewClient(mock, WithDimensionDenylist("request_uuid"))
+		opts := &RequestOptions{Dimensions: map[string]interface{}{"request_uuid": "def"}}
+		ctx := WithDimensions(context.Background(), map[string]interface{}{"team": "search", "env": "prod"})
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, time.Second, nil, opts))
+
+		require.Len(t, saved, 1)
+		for _, req := range saved {
+			assert.Equal(t, map[string]string{"team": "search", "env": "prod"}, req.DimensionsMap())
+		}
+	})
+
+	t.Run("cardinality limit", func(t *testing.T) {
+		mock, saved := newRecordingStorage()
+		client := NewClient(mock, WithMaxDimensionCardinality(2))
+		for i := 0; i < 5; i++ {
+			track(t, client, map[string]interface{}{"request_uuid": fmt.Sprintf("id-%d", i), "team": "search"})
+		}
+		// Values seen before the limit keep being saved
+		track(t, client, map[string]interface{}{"request_uuid": "id-1"})
+
+		values := make(map[string]int)
+		for _, req := range saved {
+			values[req.Dimension("request_uuid")]++
+			if team, ok := req.LookupDimension("team"); ok {
+				assert.Equal(t, "search", team)
+			}
+		}
+		assert.Equal(t, map[string]int{"id-0": 1, "id-1": 2, DimensionOverflowValue: 3}, values)
+		assert.Equal(t, int64(3), client.Metrics().DimensionsOverflowed)
+	})
+}
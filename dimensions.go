@@ -0,0 +1,104 @@
+package llmtracer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Well-known dimension keys. Using them, rather than spelling keys out, keeps the same
+// dimension from being split across keys like "userId" and "user_id".
+const (
+	DimensionUserID      = "user_id"
+	DimensionOrgID       = "org_id"
+	DimensionFeature     = "feature"
+	DimensionWorkflow    = "workflow"
+	DimensionEnvironment = "environment"
+	DimensionRegion      = "region"
+)
+
+// DimensionRule normalizes the values of a dimension key before they are saved
+type DimensionRule struct {
+	// TrimSpace removes leading and trailing whitespace
+	TrimSpace bool `json:"trim_space,omitempty"`
+	// Lowercase saves values in lower case, e.g. so "Prod" and "prod" are one environment
+	Lowercase bool `json:"lowercase,omitempty"`
+	// MaxLength truncates longer values to this many characters when positive
+	MaxLength int `json:"max_length,omitempty"`
+}
+
+// apply returns value normalized by the rule
+func (r DimensionRule) apply(value string) string {
+	if r.TrimSpace {
+		value = strings.TrimSpace(value)
+	}
+	if r.Lowercase {
+		value = strings.ToLower(value)
+	}
+	if r.MaxLength > 0 && utf8.RuneCountInString(value) > r.MaxLength {
+		value = string([]rune(value)[:r.MaxLength])
+	}
+	return value
+}
+
+// DefaultDimensionRules returns rules for the well-known dimension keys: every value is
+// trimmed and capped at 128 characters, and environments and regions are lowercased. IDs
+// keep their case, since it may be significant.
+func DefaultDimensionRules() map[string]DimensionRule {
+	return map[string]DimensionRule{
+		DimensionUserID:      {TrimSpace: true, MaxLength: 128},
+		DimensionOrgID:       {TrimSpace: true, MaxLength: 128},
+		DimensionFeature:     {TrimSpace: true, MaxLength: 128},
+		DimensionWorkflow:    {TrimSpace: true, MaxLength: 128},
+		DimensionEnvironment: {TrimSpace: true, Lowercase: true, MaxLength: 128},
+		DimensionRegion:      {TrimSpace: true, Lowercase: true, MaxLength: 128},
+	}
+}
+
+// WithDimensionRules normalizes the values of the given dimension keys before saving;
+// see DefaultDimensionRules. Rules are looked up after key normalization, when enabled.
+// Filters are not normalized, so filter on normalized values.
+func WithDimensionRules(rules map[string]DimensionRule) ClientOption {
+	return func(c *Client) {
+		c.dimensions.rules = rules
+	}
+}
+
+// WithDimensionKeyNormalization saves dimension keys in snake_case, so "userId", "UserID"
+// and "user-id" are all saved as user_id. When a context sets several keys that normalize
+// to the same key, the one already in snake_case wins. Allowlists, denylists and rules
+// apply to the normalized keys.
+func WithDimensionKeyNormalization(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.dimensions.normalizeKeys = enabled
+	}
+}
+
+// NormalizeDimensionKey returns key in snake_case: words split at case changes, spaces,
+// hyphens and dots are joined with underscores and lowercased
+func NormalizeDimensionKey(key string) string {
+	runes := []rune(strings.TrimSpace(key))
+	var b strings.Builder
+	separate := false
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == '.' || unicode.IsSpace(r) {
+			separate = b.Len() > 0
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// A word starts at a lower-to-upper change, or at the last capital of an
+			// acronym followed by a lowercase word, e.g. the S of "HTTPStatus"
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextLower) {
+				separate = b.Len() > 0
+			}
+		}
+		if separate {
+			b.WriteByte('_')
+			separate = false
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package llmtracer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDimensionKey(t *testing.T) {
+	for key, expected := range map[string]string{
+		"user_id":      "user_id",
+		"userId":       "user_id",
+		"UserID":       "user_id",
+		"userID":       "user_id",
+		"user-id":      "user_id",
+		"User ID":      "user_id",
+		" org.id ":     "org_id",
+		"HTTPStatus":   "http_status",
+		"region2Zone":  "region2_zone",
+		"__feature__":  "feature",
+		"Environment":  "environment",
+		"feature__key": "feature_key",
+	} {
+		assert.Equal(t, expected, NormalizeDimensionKey(key), key)
+	}
+}
+
+func TestDimensionRules(t *testing.T) {
+	track := func(t *testing.T, client *Client, storage *MockStorageAdapter, dimensions map[string]interface{}) *Request {
+		t.Helper()
+		require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, &RequestOptions{Dimensions: dimensions}))
+		require.NotEmpty(t, storage.SaveCalls)
+		return storage.SaveCalls[len(storage.SaveCalls)-1].Request
+	}
+
+	t.Run("Keys are normalized", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage, WithDimensionKeyNormalization(true), WithDimensionDenylist("secret_token"))
+
+		request := track(t, client, storage, map[string]interface{}{"userId": "alice", "OrgID": 42, "secretToken": "x"})
+		assert.Equal(t, map[string]string{DimensionUserID: "alice", DimensionOrgID: "42"}, request.DimensionsMap())
+
+		// The key already in snake_case wins
+		for i := 0; i < 10; i++ {
+			request = track(t, client, storage, map[string]interface{}{"userId": "a", "user_id": "b", "UserID": "c"})
+			assert.Equal(t, "b", request.Dimension(DimensionUserID))
+		}
+		request = track(t, client, storage, map[string]interface{}{"userId": "a", "UserID": "c"})
+		assert.Equal(t, "c", request.Dimension(DimensionUserID), "the smallest key wins")
+	})
+
+	t.Run("Values are normalized", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage, WithDimensionRules(DefaultDimensionRules()))
+
+		request := track(t, client, storage, map[string]interface{}{
+			DimensionEnvironment: " Prod ",
+			DimensionRegion:      "EU-West-1",
+			DimensionUserID:      " Alice ",
+			DimensionFeature:     strings.Repeat("é", 200),
+			"custom":             " Kept ",
+		})
+		assert.Equal(t, "prod", request.Dimension(DimensionEnvironment))
+		assert.Equal(t, "eu-west-1", request.Dimension(DimensionRegion))
+		assert.Equal(t, "Alice", request.Dimension(DimensionUserID))
+		assert.Equal(t, strings.Repeat("é", 128), request.Dimension(DimensionFeature))
+		assert.Equal(t, " Kept ", request.Dimension("custom"))
+	})
+
+	t.Run("Rules apply to normalized keys", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage,
+			WithDimensionKeyNormalization(true),
+			WithDimensionRules(map[string]DimensionRule{DimensionEnvironment: {Lowercase: true}}),
+		)
+
+		request := track(t, client, storage, map[string]interface{}{"Environment": "STAGING"})
+		assert.Equal(t, "staging", request.Dimension(DimensionEnvironment))
+	})
+}
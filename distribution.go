@@ -0,0 +1,109 @@
+package llmtracer
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// DefaultTokenBuckets are the histogram upper bounds used when none are given
+var DefaultTokenBuckets = []int{100, 250, 500, 1000, 2000, 4000, 8000, 16000, 32000, 64000, 128000}
+
+// TokenBucket counts the requests whose token count is in (LowerBound, UpperBound]. The
+// last bucket of a histogram is unbounded and has an UpperBound of math.MaxInt.
+type TokenBucket struct {
+	LowerBound int   `json:"lower_bound"`
+	UpperBound int   `json:"upper_bound"`
+	Count      int64 `json:"count"`
+}
+
+// TokenHistogram describes the distribution of a token count over requests
+type TokenHistogram struct {
+	Buckets []TokenBucket `json:"buckets"`
+	Min     int           `json:"min"`
+	Max     int           `json:"max"`
+	Mean    float64       `json:"mean"`
+	P50     int           `json:"p50"`
+	P90     int           `json:"p90"`
+	P95     int           `json:"p95"`
+	P99     int           `json:"p99"`
+}
+
+// TokenDistribution holds the per-request distributions of input and output tokens
+type TokenDistribution struct {
+	Requests int64          `json:"requests"`
+	Input    TokenHistogram `json:"input"`
+	Output   TokenHistogram `json:"output"`
+}
+
+// GetTokenDistribution returns histograms and percentiles of the input and output tokens
+// of each request matching filter, to find outlier prompts and pick max_tokens defaults.
+// buckets are ascending upper bounds; DefaultTokenBuckets is used when empty, and an
+// unbounded bucket is always added for larger counts. Matching requests are loaded into
+// memory, so narrow the filter on large datasets.
+func (c *Client) GetTokenDistribution(ctx context.Context, filter *RequestFilter, buckets []int) (*TokenDistribution, error) {
+	if len(buckets) == 0 {
+		buckets = DefaultTokenBuckets
+	}
+	bounds := append([]int(nil), buckets...)
+	sort.Ints(bounds)
+
+	if filter == nil {
+		filter = &RequestFilter{}
+	}
+	requests, err := c.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	input := make([]int, len(requests))
+	output := make([]int, len(requests))
+	for i, req := range requests {
+		input[i] = int(req.InputTokens)
+		output[i] = int(req.OutputTokens)
+	}
+
+	return &TokenDistribution{
+		Requests: int64(len(requests)),
+		Input:    tokenHistogram(input, bounds),
+		Output:   tokenHistogram(output, bounds),
+	}, nil
+}
+
+// tokenHistogram buckets counts by the sorted upper bounds
+func tokenHistogram(counts []int, bounds []int) TokenHistogram {
+	histogram := TokenHistogram{Buckets: make([]TokenBucket, 0, len(bounds)+1)}
+	lower := 0
+	for _, upper := range bounds {
+		if upper <= lower && len(histogram.Buckets) > 0 {
+			continue // duplicate bound
+		}
+		histogram.Buckets = append(histogram.Buckets, TokenBucket{LowerBound: lower, UpperBound: upper})
+		lower = upper
+	}
+	histogram.Buckets = append(histogram.Buckets, TokenBucket{LowerBound: lower, UpperBound: math.MaxInt})
+
+	if len(counts) == 0 {
+		return histogram
+	}
+
+	sort.Ints(counts)
+	total := 0
+	for _, count := range counts {
+		total += count
+		// The first bucket also holds counts at or below its lower bound, e.g. zero
+		i := sort.Search(len(histogram.Buckets), func(i int) bool {
+			return count <= histogram.Buckets[i].UpperBound
+		})
+		histogram.Buckets[i].Count++
+	}
+
+	histogram.Min = counts[0]
+	histogram.Max = counts[len(counts)-1]
+	histogram.Mean = float64(total) / float64(len(counts))
+	histogram.P50 = percentile(counts, 50)
+	histogram.P90 = percentile(counts, 90)
+	histogram.P95 = percentile(counts, 95)
+	histogram.P99 = percentile(counts, 99)
+	return histogram
+}
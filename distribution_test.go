@@ -0,0 +1,64 @@
+package llmtracer
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTokenDistribution(t *testing.T) {
+	ctx := context.Background()
+	var requests []*Request
+	for _, tokens := range [][2]int64{{50, 10}, {100, 20}, {300, 30}, {900, 40}, {20000, 500}} {
+		requests = append(requests, &Request{Model: "gpt-4o", InputTokens: tokens[0], OutputTokens: tokens[1]})
+	}
+
+	var gotFilter *RequestFilter
+	client := NewClient(&MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			gotFilter = filter
+			return requests, nil
+		},
+	})
+
+	t.Run("Custom buckets", func(t *testing.T) {
+		filter := &RequestFilter{Model: "gpt-4o"}
+		distribution, err := client.GetTokenDistribution(ctx, filter, []int{1000, 100})
+		require.NoError(t, err)
+		assert.Same(t, filter, gotFilter)
+		assert.Equal(t, int64(5), distribution.Requests)
+
+		assert.Equal(t, []TokenBucket{
+			{LowerBound: 0, UpperBound: 100, Count: 2},
+			{LowerBound: 100, UpperBound: 1000, Count: 2},
+			{LowerBound: 1000, UpperBound: math.MaxInt, Count: 1},
+		}, distribution.Input.Buckets)
+		assert.Equal(t, int64(4), distribution.Output.Buckets[0].Count)
+
+		assert.Equal(t, 50, distribution.Input.Min)
+		assert.Equal(t, 20000, distribution.Input.Max)
+		assert.Equal(t, 4270.0, distribution.Input.Mean)
+		assert.Equal(t, 300, distribution.Input.P50)
+		assert.Equal(t, 20000, distribution.Input.P99)
+		assert.Equal(t, 30, distribution.Output.P50)
+	})
+
+	t.Run("Default buckets", func(t *testing.T) {
+		distribution, err := client.GetTokenDistribution(ctx, nil, nil)
+		require.NoError(t, err)
+		assert.NotNil(t, gotFilter)
+		assert.Len(t, distribution.Input.Buckets, len(DefaultTokenBuckets)+1)
+	})
+
+	t.Run("No requests", func(t *testing.T) {
+		requests = nil
+		distribution, err := client.GetTokenDistribution(ctx, nil, []int{10})
+		require.NoError(t, err)
+		assert.Zero(t, distribution.Requests)
+		assert.Len(t, distribution.Input.Buckets, 2)
+		assert.Zero(t, distribution.Input.P95)
+	})
+}
@@ -0,0 +1,477 @@
+package llmtracer
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks a stored value as ciphertext: enc:v1:<key id>:<base64 nonce+sealed>
+const encryptedPrefix = "enc:v1:"
+
+// KeyProvider supplies the AES keys used by encrypted storage. Implement it to fetch or
+// unwrap data keys from a KMS; the static provider covers keys loaded from configuration.
+type KeyProvider interface {
+	// CurrentKey returns the ID and the 16, 24 or 32 byte key used to encrypt new values.
+	// Key IDs are stored next to each value and must not contain ':'.
+	CurrentKey(ctx context.Context) (keyID string, key []byte, err error)
+
+	// Key returns the key with the given ID, so values written before a rotation can
+	// still be decrypted
+	Key(ctx context.Context, keyID string) ([]byte, error)
+}
+
+type staticKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyProvider returns a KeyProvider over fixed keys, encrypting with the key
+// named current. Keep retired keys in the map for as long as values written with them
+// are stored.
+func NewStaticKeyProvider(current string, keys map[string][]byte) (KeyProvider, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q not found", current)
+	}
+	copied := make(map[string][]byte, len(keys))
+	for id, key := range keys {
+		if strings.Contains(id, ":") {
+			return nil, fmt.Errorf("key id %q must not contain ':'", id)
+		}
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+		copied[id] = append([]byte(nil), key...)
+	}
+	return &staticKeyProvider{current: current, keys: copied}, nil
+}
+
+func (p *staticKeyProvider) CurrentKey(ctx context.Context) (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *staticKeyProvider) Key(ctx context.Context, keyID string) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", keyID)
+	}
+	return key, nil
+}
+
+// WithEncryption encrypts error messages, captured payloads and the given dimension values
+// (user_id when none are given) with AES-GCM before they reach storage, and decrypts them
+// on reads through the client. See NewEncryptedStorage for the trade-offs.
+func WithEncryption(keys KeyProvider, dimensionKeys ...string) ClientOption {
+	return func(c *Client) {
+		c.storage = NewEncryptedStorage(c.storage, keys, dimensionKeys...)
+	}
+}
+
+// NewEncryptedStorage wraps storage so that Request.Error, Request.RequestPayload and the
+// values of the given dimension keys (user_id when none are given) are stored as AES-GCM
+// ciphertext and decrypted again on Get, GetByTraceID and Query. Dimension values in
+// rollups and saved filters are encrypted the same way.
+//
+// Error and payload values use a random nonce. Dimension values are encrypted
+// deterministically, so equal values produce equal ciphertext and dimension filters and
+// GroupByDimension keep working; the database only reveals which requests share a value.
+// Values encrypted with a retired key form separate groups from the same value under the
+// current key, and long values may exceed the adapter's dimension value size. Values
+// stored before encryption was enabled are read back unchanged. Errors and payloads
+// cannot be searched, so reads with RequestFilter.SearchText fail with
+// ErrSearchNotSupported.
+//
+// Soft delete support of the wrapped adapter is preserved.
+func NewEncryptedStorage(storage StorageAdapter, keys KeyProvider, dimensionKeys ...string) StorageAdapter {
+	if storage == nil {
+		panic("storage adapter cannot be nil")
+	}
+	if keys == nil {
+		panic("key provider cannot be nil")
+	}
+	if len(dimensionKeys) == 0 {
+		dimensionKeys = []string{DimensionUserID}
+	}
+
+	encrypted := &encryptedStorage{
+		StorageAdapter: storage,
+		keys:           keys,
+		dimensionKeys:  make(map[string]bool, len(dimensionKeys)),
+	}
+	for _, key := range dimensionKeys {
+		encrypted.dimensionKeys[key] = true
+	}
+	return preserveSoftDelete(encrypted, storage)
+}
+
+// encryptedStorage encrypts sensitive fields on the way into the wrapped adapter
+type encryptedStorage struct {
+	StorageAdapter
+	keys          KeyProvider
+	dimensionKeys map[string]bool
+}
+
+func (s *encryptedStorage) unwrap() StorageAdapter {
+	return s.StorageAdapter
+}
+
+func (s *encryptedStorage) Capabilities() StorageCapabilities {
+	return wrappedCapabilities(s.StorageAdapter)
+}
+
+func (s *encryptedStorage) Save(ctx context.Context, request *Request) error {
+	return s.write(ctx, request, s.StorageAdapter.Save)
+}
+
+func (s *encryptedStorage) Update(ctx context.Context, request *Request) error {
+	return s.write(ctx, request, func(ctx context.Context, stored *Request) error {
+		return updateRequest(ctx, s.StorageAdapter, stored)
+	})
+}
+
+// write encrypts a request and stores it with store
+func (s *encryptedStorage) write(ctx context.Context, request *Request, store func(context.Context, *Request) error) error {
+	// Encrypt a copy so the caller's request, which is also published to watchers,
+	// stays readable
+	stored := *request
+	var err error
+	if stored.Error, err = s.encrypt(ctx, request.Error, nil); err != nil {
+		return err
+	}
+	if stored.RequestPayload, err = s.encrypt(ctx, request.RequestPayload, nil); err != nil {
+		return err
+	}
+	if stored.Dimensions, err = s.encryptDimensions(ctx, request.Dimensions); err != nil {
+		return err
+	}
+
+	if err := store(ctx, &stored); err != nil {
+		return err
+	}
+	request.CreatedAt = stored.CreatedAt
+	request.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (s *encryptedStorage) Get(ctx context.Context, id string) (*Request, error) {
+	request, err := s.StorageAdapter.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decryptRequest(ctx, request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (s *encryptedStorage) GetByTraceID(ctx context.Context, traceID string) ([]*Request, error) {
+	requests, err := s.StorageAdapter.GetByTraceID(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	return requests, s.decryptRequests(ctx, requests)
+}
+
+func (s *encryptedStorage) Query(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+	filter, err := s.encryptRequestFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	requests, err := s.StorageAdapter.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return requests, s.decryptRequests(ctx, requests)
+}
+
+func (s *encryptedStorage) Aggregate(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+	filter, err := s.encryptRequestFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.StorageAdapter.Aggregate(ctx, groupBy, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if err := s.decryptDimensions(ctx, result.Dimensions); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (s *encryptedStorage) AggregateMulti(ctx context.Context, specs []AggregateSpec) ([][]*AggregateResult, error) {
+	encrypted := make([]AggregateSpec, len(specs))
+	for i, spec := range specs {
+		filter, err := s.encryptRequestFilter(ctx, spec.Filter)
+		if err != nil {
+			return nil, err
+		}
+		encrypted[i] = AggregateSpec{GroupBy: spec.GroupBy, Filter: filter}
+	}
+	results, err := aggregateMulti(ctx, s.StorageAdapter, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	for _, specResults := range results {
+		for _, result := range specResults {
+			if err := s.decryptDimensions(ctx, result.Dimensions); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return results, nil
+}
+
+// CompactRequests encrypts the dimension values of rollups like those of requests
+func (s *encryptedStorage) CompactRequests(ctx context.Context, rollups []*RequestRollup, requestIDs []string) error {
+	storage, ok := storageCapability[RollupStorage](s.StorageAdapter)
+	if !ok {
+		return ErrRollupsNotSupported
+	}
+	encrypted := make([]*RequestRollup, len(rollups))
+	for i, rollup := range rollups {
+		stored := *rollup
+		dimensions, err := s.encryptDimensions(ctx, rollupDimensions(rollup))
+		if err != nil {
+			return err
+		}
+		stored.setDimensions(dimensions)
+		stored.ID = rollupID(&stored)
+		encrypted[i] = &stored
+	}
+	return storage.CompactRequests(ctx, encrypted, requestIDs)
+}
+
+func (s *encryptedStorage) QueryRollups(ctx context.Context, filter *RequestFilter) ([]*RequestRollup, error) {
+	storage, ok := storageCapability[RollupStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrRollupsNotSupported
+	}
+	filter, err := s.encryptFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	rollups, err := storage.QueryRollups(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, rollup := range rollups {
+		dimensions := rollupDimensions(rollup)
+		if err := s.decryptDimensions(ctx, dimensions); err != nil {
+			return nil, err
+		}
+		rollup.setDimensions(dimensions)
+	}
+	return rollups, nil
+}
+
+// SaveFilter encrypts the dimension values of saved filters like those of requests
+func (s *encryptedStorage) SaveFilter(ctx context.Context, filter *SavedFilter) error {
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return ErrSavedFiltersNotSupported
+	}
+	dimensions, err := s.encryptDimensions(ctx, filter.Filter.Dimensions)
+	if err != nil {
+		return err
+	}
+	stored := *filter
+	stored.Filter.Dimensions = dimensions
+	return storage.SaveFilter(ctx, &stored)
+}
+
+func (s *encryptedStorage) GetFilter(ctx context.Context, name string) (*SavedFilter, error) {
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrSavedFiltersNotSupported
+	}
+	filter, err := storage.GetFilter(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decryptDimensions(ctx, filter.Filter.Dimensions); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func (s *encryptedStorage) ListFilters(ctx context.Context) ([]*SavedFilter, error) {
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrSavedFiltersNotSupported
+	}
+	filters, err := storage.ListFilters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, filter := range filters {
+		if err := s.decryptDimensions(ctx, filter.Filter.Dimensions); err != nil {
+			return nil, err
+		}
+	}
+	return filters, nil
+}
+
+func (s *encryptedStorage) DeleteFilter(ctx context.Context, name string) error {
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return ErrSavedFiltersNotSupported
+	}
+	return storage.DeleteFilter(ctx, name)
+}
+
+func (s *encryptedStorage) encryptDimensions(ctx context.Context, dimensions []DimensionTag) ([]DimensionTag, error) {
+	if len(dimensions) == 0 {
+		return dimensions, nil
+	}
+	encrypted := make([]DimensionTag, len(dimensions))
+	for i, dim := range dimensions {
+		encrypted[i] = dim
+		if !s.dimensionKeys[dim.Key] {
+			continue
+		}
+		value, err := s.encrypt(ctx, dim.Value, []byte(dim.Key))
+		if err != nil {
+			return nil, err
+		}
+		encrypted[i].Value = value
+	}
+	return encrypted, nil
+}
+
+// encryptRequestFilter is encryptFilter for filters on requests. Errors and payloads are
+// encrypted with a random nonce, so filters searching them fail with ErrSearchNotSupported.
+func (s *encryptedStorage) encryptRequestFilter(ctx context.Context, filter *RequestFilter) (*RequestFilter, error) {
+	if filter != nil && filter.SearchText != "" {
+		return nil, ErrSearchNotSupported
+	}
+	return s.encryptFilter(ctx, filter)
+}
+
+// encryptFilter returns a copy of filter whose dimension values match the stored ciphertext
+func (s *encryptedStorage) encryptFilter(ctx context.Context, filter *RequestFilter) (*RequestFilter, error) {
+	if filter == nil || len(filter.Dimensions) == 0 {
+		return filter, nil
+	}
+	dimensions, err := s.encryptDimensions(ctx, filter.Dimensions)
+	if err != nil {
+		return nil, err
+	}
+	copied := *filter
+	copied.Dimensions = dimensions
+	return &copied, nil
+}
+
+func (s *encryptedStorage) decryptRequests(ctx context.Context, requests []*Request) error {
+	for _, request := range requests {
+		if err := s.decryptRequest(ctx, request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *encryptedStorage) decryptRequest(ctx context.Context, request *Request) error {
+	var err error
+	if request.Error, err = s.decrypt(ctx, request.Error, nil); err != nil {
+		return err
+	}
+	if request.RequestPayload, err = s.decrypt(ctx, request.RequestPayload, nil); err != nil {
+		return err
+	}
+	return s.decryptDimensions(ctx, request.Dimensions)
+}
+
+func (s *encryptedStorage) decryptDimensions(ctx context.Context, dimensions []DimensionTag) error {
+	for i, dim := range dimensions {
+		if !s.dimensionKeys[dim.Key] {
+			continue
+		}
+		value, err := s.decrypt(ctx, dim.Value, []byte(dim.Key))
+		if err != nil {
+			return err
+		}
+		dimensions[i].Value = value
+	}
+	return nil
+}
+
+// encrypt seals value with the current key. Non-nil aad (the dimension key) makes the
+// result deterministic: the nonce is derived from the key, aad and value, and aad is
+// authenticated so ciphertext can't be moved between dimensions.
+func (s *encryptedStorage) encrypt(ctx context.Context, value string, aad []byte) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	keyID, key, err := s.keys.CurrentKey(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get encryption key: %w", err)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if aad != nil {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte("llmtracer nonce"))
+		mac.Write(aad)
+		mac.Write([]byte{0})
+		mac.Write([]byte(value))
+		copy(nonce, mac.Sum(nil))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), aad)
+	return encryptedPrefix + keyID + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value written by encrypt; values without the prefix are returned as is
+func (s *encryptedStorage) decrypt(ctx context.Context, value string, aad []byte) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	keyID, encoded, ok := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !ok {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+
+	key, err := s.keys.Key(ctx, keyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get decryption key: %w", err)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], aad)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value with key %q: %w", keyID, err)
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,229 @@
+package llmtracer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRecordingStorage returns a mock that keeps saved requests in memory, as stored
+func newRecordingStorage() (*MockStorageAdapter, map[string]*Request) {
+	stored := make(map[string]*Request)
+	copyRequest := func(request *Request) *Request {
+		copied := *request
+		copied.Dimensions = append([]DimensionTag(nil), request.Dimensions...)
+		return &copied
+	}
+	return &MockStorageAdapter{
+		SaveFunc: func(ctx context.Context, request *Request) error {
+			stored[request.ID] = copyRequest(request)
+			return nil
+		},
+		GetFunc: func(ctx context.Context, id string) (*Request, error) {
+			request, ok := stored[id]
+			if !ok {
+				return nil, errors.New("not found")
+			}
+			return copyRequest(request), nil
+		},
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			var results []*Request
+			for _, request := range stored {
+				if filter.Matches(request) {
+					results = append(results, copyRequest(request))
+				}
+			}
+			return results, nil
+		},
+	}, stored
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	ctx := context.Background()
+	keyV1 := bytes.Repeat([]byte{1}, 32)
+	keyV2 := bytes.Repeat([]byte{2}, 32)
+	keys, err := NewStaticKeyProvider("v1", map[string][]byte{"v1": keyV1})
+	require.NoError(t, err)
+
+	t.Run("Round trip", func(t *testing.T) {
+		mock, stored := newRecordingStorage()
+		client := NewClient(mock, WithEncryption(keys))
+
+		ctx := WithFeature(WithUserID(ctx, "alice@example.com"), "chat")
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4", 10, 5, time.Second, errors.New("prompt was: my secret"), nil))
+		require.Len(t, stored, 1)
+
+		var raw *Request
+		for _, request := range stored {
+			raw = request
+		}
+		assert.True(t, strings.HasPrefix(raw.Error, encryptedPrefix+"v1:"))
+		assert.NotContains(t, raw.Error, "secret")
+		assert.True(t, strings.HasPrefix(raw.Dimension("user_id"), encryptedPrefix))
+		assert.Equal(t, "chat", raw.Dimension("feature"))
+
+		request, err := client.storage.Get(ctx, raw.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "prompt was: my secret", request.Error)
+		assert.Equal(t, "alice@example.com", request.Dimension("user_id"))
+
+		results, err := client.storage.Query(ctx, &RequestFilter{
+			Dimensions: []DimensionTag{{Key: "user_id", Value: "alice@example.com"}},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "alice@example.com", results[0].Dimension("user_id"))
+	})
+
+	t.Run("Deterministic dimensions", func(t *testing.T) {
+		mock, stored := newRecordingStorage()
+		storage := NewEncryptedStorage(mock, keys, "user_id", "tenant")
+
+		for _, id := range []string{"a", "b"} {
+			require.NoError(t, storage.Save(ctx, &Request{
+				ID:             id,
+				Error:          "boom",
+				RequestPayload: `{"model":"gpt-4"}`,
+				Dimensions:     []DimensionTag{{Key: "user_id", Value: "u1"}, {Key: "tenant", Value: "u1"}},
+			}))
+		}
+
+		assert.Equal(t, stored["a"].Dimension("user_id"), stored["b"].Dimension("user_id"))
+		assert.NotEqual(t, stored["a"].Dimension("user_id"), stored["a"].Dimension("tenant"))
+		assert.NotEqual(t, stored["a"].Error, stored["b"].Error)
+		assert.NotEqual(t, stored["a"].RequestPayload, stored["b"].RequestPayload)
+
+		request, err := storage.Get(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, `{"model":"gpt-4"}`, request.RequestPayload)
+		assert.Equal(t, "u1", request.Dimension("tenant"))
+	})
+
+	t.Run("Caller's request is not modified", func(t *testing.T) {
+		mock, _ := newRecordingStorage()
+		storage := NewEncryptedStorage(mock, keys)
+
+		request := &Request{ID: "plain", Error: "boom", Dimensions: []DimensionTag{{Key: "user_id", Value: "u1"}}}
+		require.NoError(t, storage.Save(ctx, request))
+		assert.Equal(t, "boom", request.Error)
+		assert.Equal(t, "u1", request.Dimension("user_id"))
+	})
+
+	t.Run("Key rotation", func(t *testing.T) {
+		mock, stored := newRecordingStorage()
+		require.NoError(t, NewEncryptedStorage(mock, keys).Save(ctx, &Request{ID: "old", Error: "old error"}))
+
+		rotated, err := NewStaticKeyProvider("v2", map[string][]byte{"v1": keyV1, "v2": keyV2})
+		require.NoError(t, err)
+		storage := NewEncryptedStorage(mock, rotated)
+		require.NoError(t, storage.Save(ctx, &Request{ID: "new", Error: "new error"}))
+		assert.True(t, strings.HasPrefix(stored["new"].Error, encryptedPrefix+"v2:"))
+
+		for id, want := range map[string]string{"old": "old error", "new": "new error"} {
+			request, err := storage.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, want, request.Error)
+		}
+
+		_, err = NewEncryptedStorage(mock, keys).Get(ctx, "new")
+		assert.Error(t, err)
+	})
+
+	t.Run("Tampered values", func(t *testing.T) {
+		mock, stored := newRecordingStorage()
+		storage := NewEncryptedStorage(mock, keys, "user_id", "tenant")
+		require.NoError(t, storage.Save(ctx, &Request{
+			ID:         "tampered",
+			Dimensions: []DimensionTag{{Key: "user_id", Value: "u1"}, {Key: "tenant", Value: "t1"}},
+		}))
+
+		// Ciphertext moved to another dimension fails authentication
+		stored["tampered"].Dimensions[1].Value = stored["tampered"].Dimensions[0].Value
+		_, err := storage.Get(ctx, "tampered")
+		assert.Error(t, err)
+	})
+
+	t.Run("Plaintext values are read unchanged", func(t *testing.T) {
+		mock, stored := newRecordingStorage()
+		stored["legacy"] = &Request{ID: "legacy", Error: "boom", Dimensions: []DimensionTag{{Key: "user_id", Value: "u1"}}}
+
+		request, err := NewEncryptedStorage(mock, keys).Get(ctx, "legacy")
+		require.NoError(t, err)
+		assert.Equal(t, "boom", request.Error)
+		assert.Equal(t, "u1", request.Dimension("user_id"))
+	})
+
+	t.Run("Aggregate dimensions are decrypted", func(t *testing.T) {
+		storage := NewEncryptedStorage(&MockStorageAdapter{
+			AggregateFunc: func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+				assert.True(t, strings.HasPrefix(filter.Dimensions[0].Value, encryptedPrefix))
+				return []*AggregateResult{{Dimensions: []DimensionTag{{Key: "user_id", Value: filter.Dimensions[0].Value}}}}, nil
+			},
+		}, keys)
+
+		results, err := storage.Aggregate(ctx, []string{GroupByDimension("user_id")}, &RequestFilter{
+			Dimensions: []DimensionTag{{Key: "user_id", Value: "u1"}},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "u1", results[0].Dimensions[0].Value)
+	})
+
+	t.Run("Search is not supported", func(t *testing.T) {
+		storage := NewEncryptedStorage(&MockStorageAdapter{}, keys)
+
+		_, err := storage.Query(ctx, &RequestFilter{SearchText: "secret"})
+		assert.ErrorIs(t, err, ErrSearchNotSupported)
+		_, err = storage.Aggregate(ctx, nil, &RequestFilter{SearchText: "secret"})
+		assert.ErrorIs(t, err, ErrSearchNotSupported)
+		_, err = storage.Query(ctx, &RequestFilter{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Saved filter dimensions", func(t *testing.T) {
+		storage := &savedFilterStorage{MockStorageAdapter: &MockStorageAdapter{}, filters: make(map[string]*SavedFilter)}
+		client := NewClient(storage, WithEncryption(keys))
+
+		filter := &RequestFilter{Dimensions: []DimensionTag{{Key: "user_id", Value: "alice@example.com"}, {Key: "feature", Value: "chat"}}}
+		require.NoError(t, client.SaveFilter(ctx, "alice", filter))
+		raw := storage.filters["alice"].Filter.Dimensions
+		assert.True(t, strings.HasPrefix(raw[0].Value, encryptedPrefix))
+		assert.Equal(t, "chat", raw[1].Value)
+		assert.Equal(t, "alice@example.com", filter.Dimensions[0].Value, "the caller's filter is not modified")
+
+		saved, err := client.GetSavedFilter(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, filter.Dimensions, saved.Filter.Dimensions)
+	})
+
+	t.Run("Soft delete support is preserved", func(t *testing.T) {
+		_, ok := NewEncryptedStorage(&MockStorageAdapter{}, keys).(SoftDeleteStorage)
+		assert.False(t, ok)
+		_, ok = NewEncryptedStorage(&softDeleteStorage{MockStorageAdapter: &MockStorageAdapter{}}, keys).(SoftDeleteStorage)
+		assert.True(t, ok)
+	})
+}
+
+func TestNewStaticKeyProvider(t *testing.T) {
+	_, err := NewStaticKeyProvider("missing", map[string][]byte{"v1": make([]byte, 32)})
+	assert.Error(t, err)
+
+	_, err = NewStaticKeyProvider("v1", map[string][]byte{"v1": make([]byte, 7)})
+	assert.Error(t, err)
+
+	_, err = NewStaticKeyProvider("a:b", map[string][]byte{"a:b": make([]byte, 32)})
+	assert.Error(t, err)
+
+	keys, err := NewStaticKeyProvider("v1", map[string][]byte{"v1": make([]byte, 16)})
+	require.NoError(t, err)
+	id, key, err := keys.CurrentKey(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "v1", id)
+	assert.Len(t, key, 16)
+}
@@ -0,0 +1,188 @@
+package llmtracer
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrorInfo describes a failed call for error matchers
+type ErrorInfo struct {
+	// Provider is empty when the provider is not known
+	Provider Provider
+	// StatusCode is the HTTP status reported by the provider, or 0 when none was
+	StatusCode int
+	// Err is the error returned by the call. Requests recategorized from storage carry an
+	// error with the stored message, so typed matchers don't apply to them.
+	Err error
+}
+
+// message returns the error message in lower case
+func (i ErrorInfo) message() string {
+	if i.Err == nil {
+		return ""
+	}
+	return strings.ToLower(i.Err.Error())
+}
+
+// ErrorMatcher returns the type of the errors it recognizes, and false for the others
+type ErrorMatcher func(info ErrorInfo) (ErrorType, bool)
+
+var (
+	errorMatchersMu sync.RWMutex
+	errorMatchers   []ErrorMatcher
+)
+
+// RegisterErrorMatcher adds a matcher consulted by CategorizeError before the built-in
+// rules, e.g. to give provider-specific messages their own type:
+//
+//	llmtracer.RegisterErrorMatcher(llmtracer.MatchErrorMessage("quota",
+//		regexp.MustCompile(`(?i)quota exceeded for project`)))
+//
+// Matchers are tried in the order they were registered, and the first match wins. It is
+// safe to call while requests are tracked.
+func RegisterErrorMatcher(matcher ErrorMatcher) {
+	if matcher == nil {
+		panic("error matcher cannot be nil")
+	}
+
+	errorMatchersMu.Lock()
+	defer errorMatchersMu.Unlock()
+	errorMatchers = append(errorMatchers, matcher)
+}
+
+// MatchErrorMessage matches errors whose message matches pattern. Add (?i) to the pattern
+// to match regardless of case.
+func MatchErrorMessage(errorType ErrorType, pattern *regexp.Regexp) ErrorMatcher {
+	return func(info ErrorInfo) (ErrorType, bool) {
+		if info.Err != nil && pattern.MatchString(info.Err.Error()) {
+			return errorType, true
+		}
+		return "", false
+	}
+}
+
+// MatchStatusCodes matches errors with one of the given HTTP statuses
+func MatchStatusCodes(errorType ErrorType, codes ...int) ErrorMatcher {
+	return func(info ErrorInfo) (ErrorType, bool) {
+		if info.StatusCode != 0 && slices.Contains(codes, info.StatusCode) {
+			return errorType, true
+		}
+		return "", false
+	}
+}
+
+// MatchErrorAs matches errors with an error of type T in their chain, such as an SDK's API
+// error type, as found by errors.As
+func MatchErrorAs[T error](errorType ErrorType) ErrorMatcher {
+	return func(info ErrorInfo) (ErrorType, bool) {
+		var target T
+		if info.Err != nil && errors.As(info.Err, &target) {
+			return errorType, true
+		}
+		return "", false
+	}
+}
+
+// MatchError matches errors wrapping target, as found by errors.Is
+func MatchError(errorType ErrorType, target error) ErrorMatcher {
+	return func(info ErrorInfo) (ErrorType, bool) {
+		if info.Err != nil && errors.Is(info.Err, target) {
+			return errorType, true
+		}
+		return "", false
+	}
+}
+
+// ForProvider restricts matcher to the errors of one provider
+func ForProvider(provider Provider, matcher ErrorMatcher) ErrorMatcher {
+	return func(info ErrorInfo) (ErrorType, bool) {
+		if info.Provider != provider {
+			return "", false
+		}
+		return matcher(info)
+	}
+}
+
+// messageContains matches errors whose lower-cased message contains any of substrings
+func messageContains(errorType ErrorType, substrings ...string) ErrorMatcher {
+	return func(info ErrorInfo) (ErrorType, bool) {
+		message := info.message()
+		for _, substring := range substrings {
+			if strings.Contains(message, substring) {
+				return errorType, true
+			}
+		}
+		return "", false
+	}
+}
+
+// builtinErrorMatchers are tried after the registered matchers. Messages are matched
+// first, since providers often report a more specific cause than the status, and the
+// order of the message rules matters: specific causes are checked before the rate limit
+// and server errors they are reported with, and network errors before timeouts so
+// "dial tcp: connection timeout" counts as a network error.
+var builtinErrorMatchers = []ErrorMatcher{
+	messageContains(ErrorTypeQuota, "insufficient_quota", "exceeded your current quota", "credit balance is too low", "billing"),
+	messageContains(ErrorTypeContentFilter, "content_filter", "content filter", "content management policy", "content policy",
+		"responsibleaipolicyviolation", "blocked due to safety"),
+	messageContains(ErrorTypeContextLength, "context_length_exceeded", "context length", "context window", "prompt is too long",
+		"input is too long"),
+	messageContains(ErrorTypeOverloaded, "overloaded"),
+	messageContains(ErrorTypeRateLimit, "rate limit", "too many requests", "429"),
+	messageContains(ErrorTypeAuthentication, "unauthorized", "authentication", "api key", "401", "403", "forbidden"),
+	messageContains(ErrorTypeNetwork, "connection", "network", "dial tcp", "dns", "no such host"),
+	func(info ErrorInfo) (ErrorType, bool) {
+		errorType, ok := messageContains(ErrorTypeTimeout, "timeout", "deadline exceeded", "context canceled")(info)
+		// 504 Gateway Timeout is a server error
+		if ok && strings.Contains(info.message(), "504") {
+			return ErrorTypeServerError, true
+		}
+		return errorType, ok
+	},
+	messageContains(ErrorTypeInvalidRequest, "invalid", "bad request", "400", "malformed"),
+	messageContains(ErrorTypeServerError, "500", "502", "503", "504", "server error", "internal error"),
+
+	MatchStatusCodes(ErrorTypeQuota, 402),
+	ForProvider(ProviderAnthropic, MatchStatusCodes(ErrorTypeOverloaded, 529)),
+	MatchStatusCodes(ErrorTypeRateLimit, 429),
+	MatchStatusCodes(ErrorTypeAuthentication, 401, 403),
+	MatchStatusCodes(ErrorTypeTimeout, 408),
+	MatchStatusCodes(ErrorTypeInvalidRequest, 400, 404, 413, 422),
+	func(info ErrorInfo) (ErrorType, bool) {
+		if info.StatusCode >= 500 && info.StatusCode <= 599 {
+			return ErrorTypeServerError, true
+		}
+		return "", false
+	},
+}
+
+// CategorizeError analyzes an error message and returns the appropriate ErrorType. See
+// CategorizeErrorInfo.
+func CategorizeError(err error) ErrorType {
+	return CategorizeErrorInfo(ErrorInfo{Err: err})
+}
+
+// CategorizeErrorInfo returns the type of a failed call: the type given by the first
+// registered matcher that recognizes it, otherwise by the built-in rules on the message,
+// then the status code, and ErrorTypeUnknown when none apply
+func CategorizeErrorInfo(info ErrorInfo) ErrorType {
+	if info.Err == nil && info.StatusCode == 0 {
+		return ErrorTypeNone
+	}
+
+	errorMatchersMu.RLock()
+	registered := errorMatchers
+	errorMatchersMu.RUnlock()
+
+	for _, matchers := range [][]ErrorMatcher{registered, builtinErrorMatchers} {
+		for _, matcher := range matchers {
+			if errorType, ok := matcher(info); ok {
+				return errorType
+			}
+		}
+	}
+	return ErrorTypeUnknown
+}
@@ -0,0 +1,84 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerErrorMatcher registers a matcher for the duration of a test
+func registerErrorMatcher(t *testing.T, matcher ErrorMatcher) {
+	errorMatchersMu.RLock()
+	previous := errorMatchers
+	errorMatchersMu.RUnlock()
+	t.Cleanup(func() {
+		errorMatchersMu.Lock()
+		defer errorMatchersMu.Unlock()
+		errorMatchers = previous
+	})
+	RegisterErrorMatcher(matcher)
+}
+
+type sdkError struct {
+	code int
+}
+
+func (e *sdkError) Error() string {
+	return fmt.Sprintf("sdk error %d", e.code)
+}
+
+func TestCategorizeErrorInfo(t *testing.T) {
+	t.Run("Status codes back up the message", func(t *testing.T) {
+		err := errors.New("the model is not available")
+		assert.Equal(t, ErrorTypeUnknown, CategorizeErrorInfo(ErrorInfo{Err: err}))
+		assert.Equal(t, ErrorTypeInvalidRequest, CategorizeErrorInfo(ErrorInfo{Err: err, StatusCode: 404}))
+		assert.Equal(t, ErrorTypeServerError, CategorizeErrorInfo(ErrorInfo{Err: err, StatusCode: 529}))
+		assert.Equal(t, ErrorTypeOverloaded, CategorizeErrorInfo(ErrorInfo{Provider: ProviderAnthropic, Err: err, StatusCode: 529}))
+		assert.Equal(t, ErrorTypeQuota, CategorizeErrorInfo(ErrorInfo{Err: err, StatusCode: 402}))
+		assert.Equal(t, ErrorTypeNetwork, CategorizeErrorInfo(ErrorInfo{Err: errors.New("connection reset"), StatusCode: 502}),
+			"the message wins over the status")
+	})
+
+	t.Run("Registered matchers come first", func(t *testing.T) {
+		quota := ErrorType("project_quota")
+		registerErrorMatcher(t, MatchErrorMessage(quota, regexp.MustCompile(`(?i)quota exceeded for project`)))
+
+		assert.Equal(t, quota, CategorizeError(errors.New("429: Quota exceeded for project my-project")))
+		assert.Equal(t, ErrorTypeRateLimit, CategorizeError(errors.New("429: rate limit exceeded")))
+	})
+
+	t.Run("Typed errors", func(t *testing.T) {
+		registerErrorMatcher(t, MatchErrorAs[*sdkError](ErrorTypeServerError))
+		sentinel := errors.New("upstream unavailable")
+		registerErrorMatcher(t, MatchError(ErrorTypeNetwork, sentinel))
+
+		assert.Equal(t, ErrorTypeServerError, CategorizeError(fmt.Errorf("call failed: %w", &sdkError{code: 1})))
+		assert.Equal(t, ErrorTypeNetwork, CategorizeError(fmt.Errorf("call failed: %w", sentinel)))
+	})
+
+	t.Run("Provider-specific matchers", func(t *testing.T) {
+		registerErrorMatcher(t, ForProvider(ProviderMistral, MatchStatusCodes(ErrorTypeOverloaded, 503)))
+
+		err := errors.New("busy")
+		assert.Equal(t, ErrorTypeOverloaded, CategorizeErrorInfo(ErrorInfo{Provider: ProviderMistral, StatusCode: 503, Err: err}))
+		assert.Equal(t, ErrorTypeServerError, CategorizeErrorInfo(ErrorInfo{Provider: ProviderOpenAI, StatusCode: 503, Err: err}))
+	})
+
+	t.Run("Tracked requests", func(t *testing.T) {
+		registerErrorMatcher(t, MatchErrorAs[*sdkError](ErrorTypeAuthentication))
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 0, time.Second, &sdkError{code: 7}, nil))
+		require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 0, time.Second, errors.New("something odd"), nil))
+		require.Len(t, storage.SaveCalls, 2)
+		assert.Equal(t, ErrorTypeAuthentication, storage.SaveCalls[0].Request.ErrorType)
+		assert.Equal(t, ErrorTypeUnknown, storage.SaveCalls[1].Request.ErrorType, "the 500 filled in for failed calls is not a server error")
+	})
+}
@@ -0,0 +1,85 @@
+package llmtracer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoPrice is returned by EstimateCost when the client has no price for the model
+var ErrNoPrice = errors.New("model has no price")
+
+// messageOverheadTokens approximates the tokens providers add around each message for its
+// role and delimiters
+const messageOverheadTokens = 4
+
+// Tokenizer counts the tokens of text as a model would. Plug in the provider's tokenizer,
+// e.g. tiktoken for OpenAI models, with WithTokenizer for exact prompt counts.
+type Tokenizer interface {
+	CountTokens(provider Provider, model, text string) int
+}
+
+// TokenizerFunc adapts a function to a Tokenizer
+type TokenizerFunc func(provider Provider, model, text string) int
+
+// CountTokens calls f
+func (f TokenizerFunc) CountTokens(provider Provider, model, text string) int {
+	return f(provider, model, text)
+}
+
+// WithTokenizer sets the tokenizer EstimateCost counts prompt tokens with. By default
+// tokens are approximated as four characters each.
+func WithTokenizer(tokenizer Tokenizer) ClientOption {
+	return func(c *Client) {
+		c.tokenizer = tokenizer
+	}
+}
+
+// CostEstimate is the expected cost range of a request before it is sent
+type CostEstimate struct {
+	Provider     Provider `json:"provider"`
+	Model        string   `json:"model"`
+	InputTokens  int64    `json:"input_tokens"`
+	OutputTokens int64    `json:"output_tokens"`
+	// MinCost is the cost of the prompt alone
+	MinCost float64 `json:"min_cost"`
+	// MaxCost is the cost of the prompt and OutputTokens of output
+	MaxCost float64 `json:"max_cost"`
+}
+
+// EstimateCost tokenizes the prompt messages and prices them with the client's pricing, at
+// the price of the region set with WithRegion, so a call can be checked against a budget
+// before it is made. The range spans from no
+// output to maxTokens of output; with maxTokens zero both ends are the prompt cost.
+// Returns ErrNoPrice when the model has no price.
+func (c *Client) EstimateCost(provider Provider, model string, messages []string, maxTokens int) (*CostEstimate, error) {
+	if c.pricing == nil {
+		return nil, fmt.Errorf("%w: no pricing configured", ErrNoPrice)
+	}
+	price, ok := c.pricing.Lookup(provider, model)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoPrice, provider, model)
+	}
+	price = price.InRegion(c.dimensions.defaults[DimensionRegion])
+
+	var inputTokens int64
+	for _, message := range messages {
+		inputTokens += int64(c.countTokens(provider, model, message) + messageOverheadTokens)
+	}
+	outputTokens := int64(max(maxTokens, 0))
+	return &CostEstimate{
+		Provider:     provider,
+		Model:        model,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		MinCost:      price.Cost(inputTokens, 0),
+		MaxCost:      price.Cost(inputTokens, outputTokens),
+	}, nil
+}
+
+// countTokens counts the tokens of text with the client's tokenizer
+func (c *Client) countTokens(provider Provider, model, text string) int {
+	if c.tokenizer != nil {
+		return c.tokenizer.CountTokens(provider, model, text)
+	}
+	return int(estimateTokens(len(text)))
+}
@@ -0,0 +1,69 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateCost(t *testing.T) {
+	mock, _ := newRecordingStorage()
+	pricing := NewPricingRegistry()
+	pricing.Set(ProviderOpenAI, "gpt-4o", ModelPrice{InputPerMillion: 2.50, OutputPerMillion: 10.00})
+	client := NewClient(mock, WithPricing(pricing))
+
+	// 40 characters are approximated as 10 tokens, plus the message overhead
+	estimate, err := client.EstimateCost(ProviderOpenAI, "gpt-4o", []string{"You are a helpful assistant. Be concise.", ""}, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10+2*messageOverheadTokens), estimate.InputTokens)
+	assert.Equal(t, int64(1000), estimate.OutputTokens)
+	assert.InDelta(t, 18*2.50/1_000_000, estimate.MinCost, 1e-12)
+	assert.InDelta(t, (18*2.50+1000*10.00)/1_000_000, estimate.MaxCost, 1e-12)
+
+	// Without a max token count the range collapses to the prompt cost
+	estimate, err = client.EstimateCost(ProviderOpenAI, "gpt-4o", []string{"hi"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, estimate.MinCost, estimate.MaxCost)
+
+	_, err = client.EstimateCost(ProviderAnthropic, "claude-3-5-sonnet", []string{"hi"}, 100)
+	assert.True(t, errors.Is(err, ErrNoPrice))
+	_, err = NewClient(mock).EstimateCost(ProviderOpenAI, "gpt-4o", []string{"hi"}, 100)
+	assert.True(t, errors.Is(err, ErrNoPrice))
+}
+
+func TestEstimateCostWithTokenizer(t *testing.T) {
+	mock, _ := newRecordingStorage()
+	pricing := NewPricingRegistry()
+	pricing.Set(ProviderAnthropic, "claude-*", ModelPrice{InputPerMillion: 1_000_000, OutputPerMillion: 2_000_000})
+
+	var seen []string
+	tokenizer := TokenizerFunc(func(provider Provider, model, text string) int {
+		assert.Equal(t, ProviderAnthropic, provider)
+		assert.Equal(t, "claude-3-5-haiku", model)
+		seen = append(seen, text)
+		return 100
+	})
+	client := NewClient(mock, WithPricing(pricing), WithTokenizer(tokenizer))
+
+	estimate, err := client.EstimateCost(ProviderAnthropic, "claude-3-5-haiku", []string{"a", "b"}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, seen)
+	assert.Equal(t, int64(200+2*messageOverheadTokens), estimate.InputTokens)
+	assert.Equal(t, 208.0, estimate.MinCost)
+	assert.Equal(t, 228.0, estimate.MaxCost)
+
+	// Budget gating before the call
+	_, budget := TraceBudget(context.Background(), 1000)
+	assert.True(t, budget.Allows(estimate.MaxCost))
+	budget.charge(780)
+	assert.False(t, budget.Allows(estimate.MaxCost))
+	assert.True(t, budget.Allows(estimate.MinCost))
+
+	outerCtx, outer := TraceBudget(context.Background(), 500)
+	_, inner := TraceBudget(outerCtx, 1000)
+	outer.charge(400)
+	assert.False(t, inner.Allows(estimate.MaxCost), "an enclosing budget is too small")
+}
@@ -0,0 +1,166 @@
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Dimensions recorded for requests made during an evaluation run
+const (
+	DimensionEvalDataset = "eval_dataset"
+	DimensionEvalRun     = "eval_run"
+	DimensionGitSHA      = "git_sha"
+)
+
+// EvalRun identifies an offline evaluation run
+type EvalRun struct {
+	// RunID names the run and must be unique across runs
+	RunID     string
+	DatasetID string
+	GitSHA    string
+}
+
+// WithEvalRun tags every request tracked with ctx as part of the evaluation run, keeping
+// any dimensions already set on ctx. Empty fields of run are not recorded.
+func WithEvalRun(ctx context.Context, run EvalRun) context.Context {
+	dimensions := make(map[string]interface{})
+	for key, value := range map[string]string{
+		DimensionEvalRun:     run.RunID,
+		DimensionEvalDataset: run.DatasetID,
+		DimensionGitSHA:      run.GitSHA,
+	} {
+		if value != "" {
+			dimensions[key] = value
+		}
+	}
+	return mergeDimensions(ctx, dimensions)
+}
+
+// EvalRunStats summarizes the requests of an evaluation run. Latency percentiles cover
+// successful requests only.
+type EvalRunStats struct {
+	EvalRun
+	TotalRequests     int64         `json:"total_requests"`
+	ErrorCount        int64         `json:"error_count"`
+	ErrorRate         float64       `json:"error_rate"`
+	TotalCost         float64       `json:"total_cost"`
+	AvgCost           float64       `json:"avg_cost"`
+	TotalInputTokens  int64         `json:"total_input_tokens"`
+	TotalOutputTokens int64         `json:"total_output_tokens"`
+	AvgTokens         float64       `json:"avg_tokens"`
+	AvgLatency        time.Duration `json:"avg_latency"`
+	LatencyP50        time.Duration `json:"latency_p50"`
+	LatencyP95        time.Duration `json:"latency_p95"`
+	FirstRequestAt    time.Time     `json:"first_request_at"`
+	LastRequestAt     time.Time     `json:"last_request_at"`
+}
+
+// EvalRunComparison compares a candidate run with a baseline. Deltas are candidate minus
+// baseline, and changes are the delta relative to the baseline (0 when the baseline is 0).
+// Per-request averages are compared so runs over datasets of different sizes stay comparable.
+type EvalRunComparison struct {
+	Baseline  *EvalRunStats `json:"baseline"`
+	Candidate *EvalRunStats `json:"candidate"`
+
+	AvgCostDelta    float64       `json:"avg_cost_delta"`
+	AvgCostChange   float64       `json:"avg_cost_change"`
+	AvgTokensDelta  float64       `json:"avg_tokens_delta"`
+	AvgTokensChange float64       `json:"avg_tokens_change"`
+	AvgLatencyDelta time.Duration `json:"avg_latency_delta"`
+	LatencyChange   float64       `json:"latency_change"`
+	LatencyP95Delta time.Duration `json:"latency_p95_delta"`
+	ErrorRateDelta  float64       `json:"error_rate_delta"`
+}
+
+// GetEvalRunStats summarizes the requests tagged with the given run ID by WithEvalRun
+func (c *Client) GetEvalRunStats(ctx context.Context, runID string) (*EvalRunStats, error) {
+	if runID == "" {
+		return nil, fmt.Errorf("run ID cannot be empty")
+	}
+	requests, err := c.query(ctx, &RequestFilter{
+		Dimensions: []DimensionTag{{Key: DimensionEvalRun, Value: runID}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no requests found for evaluation run %q", runID)
+	}
+
+	stats := &EvalRunStats{EvalRun: EvalRun{RunID: runID}}
+	var totalLatency time.Duration
+	var latencies []time.Duration
+	for _, req := range requests {
+		stats.TotalRequests++
+		stats.TotalCost += req.Cost
+		stats.TotalInputTokens = AddTokens(stats.TotalInputTokens, req.InputTokens)
+		stats.TotalOutputTokens = AddTokens(stats.TotalOutputTokens, req.OutputTokens)
+		totalLatency += req.Latency
+		if req.Error != "" {
+			stats.ErrorCount++
+		} else {
+			latencies = append(latencies, req.Latency)
+		}
+
+		if stats.DatasetID == "" {
+			stats.DatasetID = req.Dimension(DimensionEvalDataset)
+		}
+		if stats.GitSHA == "" {
+			stats.GitSHA = req.Dimension(DimensionGitSHA)
+		}
+		if stats.FirstRequestAt.IsZero() || req.RequestedAt.Before(stats.FirstRequestAt) {
+			stats.FirstRequestAt = req.RequestedAt
+		}
+		if req.RequestedAt.After(stats.LastRequestAt) {
+			stats.LastRequestAt = req.RequestedAt
+		}
+	}
+
+	n := float64(stats.TotalRequests)
+	stats.ErrorRate = float64(stats.ErrorCount) / n
+	stats.AvgCost = stats.TotalCost / n
+	stats.AvgTokens = float64(stats.TotalInputTokens+stats.TotalOutputTokens) / n
+	stats.AvgLatency = totalLatency / time.Duration(stats.TotalRequests)
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats.LatencyP50 = percentile(latencies, 50)
+		stats.LatencyP95 = percentile(latencies, 95)
+	}
+	return stats, nil
+}
+
+// CompareEvalRuns compares the candidate run with the baseline run on cost, tokens,
+// latency and errors
+func (c *Client) CompareEvalRuns(ctx context.Context, baselineRunID, candidateRunID string) (*EvalRunComparison, error) {
+	baseline, err := c.GetEvalRunStats(ctx, baselineRunID)
+	if err != nil {
+		return nil, err
+	}
+	candidate, err := c.GetEvalRunStats(ctx, candidateRunID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EvalRunComparison{
+		Baseline:        baseline,
+		Candidate:       candidate,
+		AvgCostDelta:    candidate.AvgCost - baseline.AvgCost,
+		AvgCostChange:   relativeChange(baseline.AvgCost, candidate.AvgCost),
+		AvgTokensDelta:  candidate.AvgTokens - baseline.AvgTokens,
+		AvgTokensChange: relativeChange(baseline.AvgTokens, candidate.AvgTokens),
+		AvgLatencyDelta: candidate.AvgLatency - baseline.AvgLatency,
+		LatencyChange:   relativeChange(float64(baseline.AvgLatency), float64(candidate.AvgLatency)),
+		LatencyP95Delta: candidate.LatencyP95 - baseline.LatencyP95,
+		ErrorRateDelta:  candidate.ErrorRate - baseline.ErrorRate,
+	}, nil
+}
+
+// relativeChange returns (candidate - baseline) / baseline, or 0 for a zero baseline
+func relativeChange(baseline, candidate float64) float64 {
+	if baseline == 0 {
+		return 0
+	}
+	return (candidate - baseline) / baseline
+}
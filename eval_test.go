@@ -0,0 +1,70 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalRuns(t *testing.T) {
+	mock, _ := newRecordingStorage()
+	client := NewClient(mock)
+	ctx := WithDimensions(context.Background(), map[string]interface{}{"team": "search"})
+
+	baselineCtx := WithEvalRun(ctx, EvalRun{RunID: "run-1", DatasetID: "support-qa", GitSHA: "abc123"})
+	candidateCtx := WithEvalRun(ctx, EvalRun{RunID: "run-2", DatasetID: "support-qa", GitSHA: "def456"})
+
+	dims := GetDimensionsFromContext(baselineCtx)
+	assert.Equal(t, "run-1", dims[DimensionEvalRun])
+	assert.Equal(t, "support-qa", dims[DimensionEvalDataset])
+	assert.Equal(t, "abc123", dims[DimensionGitSHA])
+	assert.Equal(t, "search", dims["team"])
+
+	cost := func(c float64) *RequestOptions { return &RequestOptions{Cost: &c} }
+	for _, latency := range []time.Duration{time.Second, 3 * time.Second} {
+		require.NoError(t, client.TrackRequest(baselineCtx, ProviderOpenAI, "gpt-4o", 100, 50, latency, nil, cost(0.01)))
+	}
+	require.NoError(t, client.TrackRequest(candidateCtx, ProviderOpenAI, "gpt-4o-mini", 100, 20, time.Second, nil, cost(0.002)))
+	require.NoError(t, client.TrackRequest(candidateCtx, ProviderOpenAI, "gpt-4o-mini", 100, 0, 500*time.Millisecond, errors.New("timeout"), cost(0.002)))
+	// Outside any run
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 50, time.Second, nil, cost(1)))
+
+	t.Run("Stats", func(t *testing.T) {
+		stats, err := client.GetEvalRunStats(ctx, "run-1")
+		require.NoError(t, err)
+		assert.Equal(t, "support-qa", stats.DatasetID)
+		assert.Equal(t, "abc123", stats.GitSHA)
+		assert.Equal(t, int64(2), stats.TotalRequests)
+		assert.InDelta(t, 0.02, stats.TotalCost, 1e-9)
+		assert.InDelta(t, 0.01, stats.AvgCost, 1e-9)
+		assert.Equal(t, 150.0, stats.AvgTokens)
+		assert.Equal(t, 2*time.Second, stats.AvgLatency)
+		assert.Equal(t, 3*time.Second, stats.LatencyP95)
+		assert.Zero(t, stats.ErrorRate)
+	})
+
+	t.Run("Compare", func(t *testing.T) {
+		comparison, err := client.CompareEvalRuns(ctx, "run-1", "run-2")
+		require.NoError(t, err)
+		assert.Equal(t, "def456", comparison.Candidate.GitSHA)
+		assert.InDelta(t, -0.008, comparison.AvgCostDelta, 1e-9)
+		assert.InDelta(t, -0.8, comparison.AvgCostChange, 1e-9)
+		assert.Equal(t, -40.0, comparison.AvgTokensDelta)
+		assert.Equal(t, -1250*time.Millisecond, comparison.AvgLatencyDelta)
+		assert.Equal(t, -2*time.Second, comparison.LatencyP95Delta)
+		assert.Equal(t, 0.5, comparison.ErrorRateDelta)
+	})
+
+	t.Run("Unknown run", func(t *testing.T) {
+		_, err := client.GetEvalRunStats(ctx, "missing")
+		assert.Error(t, err)
+		_, err = client.CompareEvalRuns(ctx, "run-1", "missing")
+		assert.Error(t, err)
+		_, err = client.GetEvalRunStats(ctx, "")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,40 @@
+//go:build !llmtracer_no_anthropic
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func init() {
+	examples = append(examples, anthropicExample)
+}
+
+// anthropicExample calls Anthropic with automatic tracking. Set ANTHROPIC_API_KEY first.
+func anthropicExample(client *llmtracer.Client) {
+	anthropicClient := anthropic.NewClient()
+
+	ctx := llmtracer.WithUserID(context.Background(), "user-123")
+	ctx = llmtracer.WithFeature(ctx, "creative-writing")
+
+	anthropicResponse, err := client.TraceAnthropicRequest(ctx, anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaude3_5SonnetLatest,
+		MaxTokens: 1000,
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(
+				anthropic.NewTextBlock("Write a haiku about programming"),
+			),
+		},
+	}, anthropicClient.Messages.New)
+	if err != nil {
+		log.Printf("Anthropic error: %v", err)
+	} else if len(anthropicResponse.Content) > 0 {
+		textBlock := anthropicResponse.Content[0].AsText()
+		fmt.Printf("Anthropic Response: %s\n", textBlock.Text)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !llmtracer_no_google
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/generative-ai-go/genai"
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func init() {
+	examples = append(examples, googleExample)
+}
+
+// googleExample calls Google Generative AI with automatic tracking. Set GOOGLE_API_KEY first.
+func googleExample(client *llmtracer.Client) {
+	googleClient, err := genai.NewClient(context.Background())
+	if err != nil {
+		log.Printf("Failed to create Google client: %v", err)
+		return
+	}
+	defer googleClient.Close()
+	googleModel := googleClient.GenerativeModel("gemini-1.5-flash")
+
+	ctx := llmtracer.WithUserID(context.Background(), "user-123")
+	ctx = llmtracer.WithFeature(ctx, "creative-writing")
+
+	googleResponse, err := client.TraceGoogleRequest(ctx, "gemini-1.5-flash", []genai.Part{
+		genai.Text("Write a short poem about artificial intelligence"),
+	}, googleModel.GenerateContent)
+	if err != nil {
+		log.Printf("Google error: %v", err)
+	} else if len(googleResponse.Candidates) > 0 && len(googleResponse.Candidates[0].Content.Parts) > 0 {
+		if text, ok := googleResponse.Candidates[0].Content.Parts[0].(genai.Text); ok {
+			fmt.Printf("Google Response: %s\n", text)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !llmtracer_no_mistral
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	mistral "github.com/gage-technologies/mistral-go"
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func init() {
+	examples = append(examples, mistralExample)
+}
+
+// mistralExample calls Mistral with automatic tracking
+func mistralExample(client *llmtracer.Client) {
+	mistralClient := mistral.NewMistralClientDefault("your-mistral-api-key-here")
+
+	ctx := llmtracer.WithUserID(context.Background(), "user-123")
+	ctx = llmtracer.WithFeature(ctx, "code-help")
+
+	mistralResponse, err := client.TraceMistralRequest(ctx, mistral.ModelMistralLargeLatest, []mistral.ChatMessage{
+		{Role: mistral.RoleSystem, Content: "You are a helpful coding assistant."},
+		{Role: mistral.RoleUser, Content: "Write a simple hello world function in Go"},
+	}, &mistral.ChatRequestParams{
+		MaxTokens:   1000,
+		Temperature: 0.7,
+	}, mistralClient.Chat)
+	if err != nil {
+		log.Printf("Mistral error: %v", err)
+	} else if len(mistralResponse.Choices) > 0 {
+		fmt.Printf("Mistral Response: %s\n", mistralResponse.Choices[0].Message.Content)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build !llmtracer_no_openai
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+	"github.com/sashabaranov/go-openai"
+)
+
+func init() {
+	examples = append(examples, openAIExample)
+}
+
+// openAIExample calls OpenAI twice with automatic tracking, each under a different feature
+func openAIExample(client *llmtracer.Client) {
+	openaiClient := openai.NewClient("your-openai-api-key-here")
+
+	ctx := context.Background()
+	ctx = llmtracer.WithUserID(ctx, "user-123")
+	ctx = llmtracer.WithFeature(ctx, "geography-quiz")
+
+	response, err := client.TraceOpenAIRequest(ctx, openai.ChatCompletionRequest{
+		Model: "gpt-3.5-turbo",
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "You are a helpful assistant."},
+			{Role: openai.ChatMessageRoleUser, Content: "What is the capital of France?"},
+		},
+	}, openaiClient.CreateChatCompletion)
+	if err != nil {
+		log.Printf("OpenAI error: %v", err)
+	} else if len(response.Choices) > 0 {
+		fmt.Printf("OpenAI Response: %s\n", response.Choices[0].Message.Content)
+	}
+
+	// Another OpenAI call with different context
+	ctx = llmtracer.WithUserID(context.Background(), "user-123")
+	ctx = llmtracer.WithFeature(ctx, "math-help")
+
+	response, err = client.TraceOpenAIRequest(ctx, openai.ChatCompletionRequest{
+		Model: "gpt-4",
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "You are a helpful assistant."},
+			{Role: openai.ChatMessageRoleUser, Content: "What is 2+2?"},
+		},
+	}, openaiClient.CreateChatCompletion)
+	if err != nil {
+		log.Printf("OpenAI error: %v", err)
+	} else if len(response.Choices) > 0 {
+		fmt.Printf("OpenAI Response: %s\n", response.Choices[0].Message.Content)
+	}
+}
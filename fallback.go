@@ -0,0 +1,132 @@
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Dimension keys recorded on fallback attempts
+const (
+	DimensionFallbackFrom    = "fallback_from"
+	DimensionFallbackAttempt = "fallback_attempt"
+)
+
+// TokenUsage is the token count reported by a provider call
+type TokenUsage struct {
+	InputTokens  int64
+	OutputTokens int64
+}
+
+// FallbackCall is one provider in a fallback chain. Call makes the provider request and
+// converts its response to the chain's common result type.
+type FallbackCall[T any] struct {
+	Provider Provider
+	Model    string
+	Call     func(ctx context.Context) (T, TokenUsage, error)
+}
+
+// FallbackOption configures a FallbackClient
+type FallbackOption func(*fallbackConfig)
+
+type fallbackConfig struct {
+	retryable func(error) bool
+}
+
+// WithRetryableFunc decides which errors move on to the next provider. By default rate
+// limits, exhausted quotas, overloaded providers, server errors, timeouts and network errors
+// do (see IsRetryableError).
+func WithRetryableFunc(retryable func(error) bool) FallbackOption {
+	return func(cfg *fallbackConfig) {
+		cfg.retryable = retryable
+	}
+}
+
+// IsRetryableError reports whether an error is likely to succeed on another provider
+func IsRetryableError(err error) bool {
+	switch CategorizeError(err) {
+	case ErrorTypeRateLimit, ErrorTypeQuota, ErrorTypeOverloaded, ErrorTypeServerError, ErrorTypeTimeout, ErrorTypeNetwork:
+		return true
+	}
+	return false
+}
+
+// FallbackClient tries an ordered list of providers until one succeeds. Every attempt is
+// tracked under the same trace ID; attempts after the first record the provider/model
+// they fell back from in the fallback_from dimension and their position in
+// fallback_attempt.
+type FallbackClient[T any] struct {
+	client *Client
+	calls  []FallbackCall[T]
+	cfg    fallbackConfig
+}
+
+// NewFallbackClient creates a fallback chain tracked by client
+func NewFallbackClient[T any](client *Client, calls []FallbackCall[T], opts ...FallbackOption) *FallbackClient[T] {
+	if client == nil {
+		panic("client cannot be nil")
+	}
+	if len(calls) == 0 {
+		panic("fallback chain needs at least one call")
+	}
+
+	cfg := fallbackConfig{retryable: IsRetryableError}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	return &FallbackClient[T]{
+		client: client,
+		calls:  calls,
+		cfg:    cfg,
+	}
+}
+
+// Do calls the providers in order, stopping at the first success or non-retryable error
+func (f *FallbackClient[T]) Do(ctx context.Context) (T, error) {
+	// Pin the trace ID so every attempt is linked
+	ctx = WithTraceID(ctx, GetTraceIDFromContext(ctx))
+
+	var result T
+	var err error
+	var previous string
+	for i, call := range f.calls {
+		if err = f.client.admit(ctx, call.Provider, call.Model); err != nil {
+			return result, err
+		}
+		release, queueWait, acquireErr := f.client.acquire(ctx, call.Provider, call.Model)
+		if acquireErr != nil {
+			return result, acquireErr
+		}
+
+		tracked := f.client.startRequest(ctx, &Request{
+			Provider:  call.Provider,
+			Model:     call.Model,
+			QueueWait: queueWait,
+		})
+		startTime := time.Now()
+		var usage TokenUsage
+		result, usage, err = call.Call(ctx)
+		release()
+
+		tracked.InputTokens = usage.InputTokens
+		tracked.OutputTokens = usage.OutputTokens
+		tracked.RequestedAt = startTime
+		tracked.Latency = time.Since(startTime)
+		trackingContext := GetDimensionsFromContext(ctx)
+		if previous != "" {
+			trackingContext[DimensionFallbackFrom] = previous
+			trackingContext[DimensionFallbackAttempt] = i + 1
+		}
+		f.client.track(ctx, tracked, err, trackingContext)
+
+		if err == nil || !f.cfg.retryable(err) {
+			return result, err
+		}
+		previous = string(call.Provider) + "/" + call.Model
+	}
+
+	return result, fmt.Errorf("all %d providers failed: %w", len(f.calls), err)
+}
@@ -0,0 +1,73 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallbackClient(t *testing.T) {
+	succeed := func(text string) func(ctx context.Context) (string, TokenUsage, error) {
+		return func(ctx context.Context) (string, TokenUsage, error) {
+			return text, TokenUsage{InputTokens: 10, OutputTokens: 5}, nil
+		}
+	}
+	fail := func(err error) func(ctx context.Context) (string, TokenUsage, error) {
+		return func(ctx context.Context) (string, TokenUsage, error) {
+			return "", TokenUsage{}, err
+		}
+	}
+
+	t.Run("falls back on retryable errors", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+		fallback := NewFallbackClient(client, []FallbackCall[string]{
+			{Provider: ProviderOpenAI, Model: "gpt-4o", Call: fail(errors.New("429 too many requests"))},
+			{Provider: ProviderAnthropic, Model: "claude-3-5-sonnet-latest", Call: succeed("hello")},
+			{Provider: ProviderMistral, Model: "mistral-large", Call: succeed("unused")},
+		})
+
+		ctx := WithFeature(context.Background(), "chat")
+		result, err := fallback.Do(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", result)
+
+		require.Len(t, storage.SaveCalls, 2)
+		first, second := storage.SaveCalls[0].Request, storage.SaveCalls[1].Request
+		assert.Equal(t, ErrorTypeRateLimit, first.ErrorType)
+		assert.Empty(t, first.Dimension(DimensionFallbackFrom))
+		assert.Equal(t, "openai/gpt-4o", second.Dimension(DimensionFallbackFrom))
+		assert.Equal(t, "2", second.Dimension(DimensionFallbackAttempt))
+		assert.Equal(t, "chat", second.Dimension("feature"))
+		assert.Equal(t, int64(15), second.TotalTokens)
+		assert.Equal(t, first.TraceID, second.TraceID)
+	})
+
+	t.Run("stops on non-retryable errors", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		fallback := NewFallbackClient(NewClient(storage), []FallbackCall[string]{
+			{Provider: ProviderOpenAI, Model: "gpt-4o", Call: fail(errors.New("invalid request: bad schema"))},
+			{Provider: ProviderAnthropic, Model: "claude-3-5-sonnet-latest", Call: succeed("hello")},
+		})
+
+		_, err := fallback.Do(context.Background())
+		assert.EqualError(t, err, "invalid request: bad schema")
+		assert.Len(t, storage.SaveCalls, 1)
+	})
+
+	t.Run("reports exhaustion", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		lastErr := errors.New("503 service unavailable")
+		fallback := NewFallbackClient(NewClient(storage), []FallbackCall[string]{
+			{Provider: ProviderOpenAI, Model: "gpt-4o", Call: fail(errors.New("connection reset"))},
+			{Provider: ProviderAnthropic, Model: "claude-3-5-sonnet-latest", Call: fail(lastErr)},
+		}, WithRetryableFunc(func(err error) bool { return true }))
+
+		_, err := fallback.Do(context.Background())
+		assert.ErrorIs(t, err, lastErr)
+		assert.Len(t, storage.SaveCalls, 2)
+	})
+}
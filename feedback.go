@@ -0,0 +1,178 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrFeedbackNotSupported is returned by the feedback methods when the storage adapter does
+// not implement FeedbackStorage
+var ErrFeedbackNotSupported = errors.New("feedback is not supported by this storage adapter")
+
+// Feedback is a quality signal about a tracked request, from an end user, a human reviewer
+// or an automated evaluator. Set whichever of Rating, ThumbsUp and Comment apply.
+type Feedback struct {
+	ID        string `json:"id" gorm:"primaryKey"`
+	RequestID string `json:"request_id" gorm:"index"`
+	// Rating is a numeric score on a scale chosen by the evaluator, e.g. 1-5
+	Rating *float64 `json:"rating,omitempty"`
+	// ThumbsUp is true for a thumbs up and false for a thumbs down
+	ThumbsUp *bool  `json:"thumbs_up,omitempty"`
+	Comment  string `json:"comment,omitempty" gorm:"type:text"`
+	// Evaluator identifies who or what gave the feedback, e.g. "user", "reviewer:alice"
+	// or "llm-judge-v2"
+	Evaluator string    `json:"evaluator,omitempty" gorm:"index"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// TableName keeps the table name singular, since "feedback" has no plural
+func (Feedback) TableName() string {
+	return "feedback"
+}
+
+// FeedbackFilter selects feedback records. Zero fields match everything.
+type FeedbackFilter struct {
+	RequestIDs []string
+	Evaluator  string
+	ThumbsUp   *bool
+	MinRating  *float64
+	MaxRating  *float64
+	StartTime  *time.Time
+	EndTime    *time.Time
+	Limit      int
+	Offset     int
+}
+
+// Matches reports whether a feedback record satisfies the filter's criteria. A nil filter
+// matches every record; Limit and Offset are ignored. Rating bounds only match records
+// with a rating.
+func (f *FeedbackFilter) Matches(feedback *Feedback) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.RequestIDs) > 0 {
+		found := false
+		for _, id := range f.RequestIDs {
+			if feedback.RequestID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Evaluator != "" && feedback.Evaluator != f.Evaluator {
+		return false
+	}
+	if f.ThumbsUp != nil && (feedback.ThumbsUp == nil || *feedback.ThumbsUp != *f.ThumbsUp) {
+		return false
+	}
+	if f.MinRating != nil && (feedback.Rating == nil || *feedback.Rating < *f.MinRating) {
+		return false
+	}
+	if f.MaxRating != nil && (feedback.Rating == nil || *feedback.Rating > *f.MaxRating) {
+		return false
+	}
+	if f.StartTime != nil && feedback.CreatedAt.Before(*f.StartTime) {
+		return false
+	}
+	if f.EndTime != nil && feedback.CreatedAt.After(*f.EndTime) {
+		return false
+	}
+
+	return true
+}
+
+// FeedbackStorage is implemented by adapters that can store feedback next to requests
+type FeedbackStorage interface {
+	SaveFeedback(ctx context.Context, feedback *Feedback) error
+
+	// QueryFeedback returns matching feedback, newest first
+	QueryFeedback(ctx context.Context, filter *FeedbackFilter) ([]*Feedback, error)
+}
+
+// RequestFeedback is a request together with the feedback recorded for it
+type RequestFeedback struct {
+	Request  *Request
+	Feedback []*Feedback
+}
+
+// RecordFeedback stores feedback for the tracked request feedback.RequestID, which must
+// exist. The ID and creation time are filled in when empty.
+func (c *Client) RecordFeedback(ctx context.Context, feedback *Feedback) error {
+	storage, ok := storageCapability[FeedbackStorage](c.storage)
+	if !ok {
+		return ErrFeedbackNotSupported
+	}
+	if feedback == nil || feedback.RequestID == "" {
+		return fmt.Errorf("feedback must reference a request ID")
+	}
+	if _, err := c.storage.Get(ctx, feedback.RequestID); err != nil {
+		return fmt.Errorf("failed to find request %q: %w", feedback.RequestID, err)
+	}
+
+	if feedback.ID == "" {
+		feedback.ID = uuid.New().String()
+	}
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now()
+	}
+	return storage.SaveFeedback(ctx, feedback)
+}
+
+// QueryFeedback returns the feedback matching filter, newest first
+func (c *Client) QueryFeedback(ctx context.Context, filter *FeedbackFilter) ([]*Feedback, error) {
+	storage, ok := storageCapability[FeedbackStorage](c.storage)
+	if !ok {
+		return nil, ErrFeedbackNotSupported
+	}
+	return storage.QueryFeedback(ctx, filter)
+}
+
+// GetRequestFeedback returns the requests matching filter, each with its feedback, so
+// quality signals can be compared with cost and latency:
+//
+//	results, _ := tracer.GetRequestFeedback(ctx, &llmtracer.RequestFilter{PromptVersion: "v7"})
+//	for _, r := range results {
+//		fmt.Println(r.Request.Cost, r.Request.Latency, len(r.Feedback))
+//	}
+func (c *Client) GetRequestFeedback(ctx context.Context, filter *RequestFilter) ([]*RequestFeedback, error) {
+	storage, ok := storageCapability[FeedbackStorage](c.storage)
+	if !ok {
+		return nil, ErrFeedbackNotSupported
+	}
+
+	requests, err := c.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, nil
+	}
+
+	results := make([]*RequestFeedback, len(requests))
+	byRequest := make(map[string]*RequestFeedback, len(requests))
+	ids := make([]string, len(requests))
+	for i, request := range requests {
+		results[i] = &RequestFeedback{Request: request}
+		byRequest[request.ID] = results[i]
+		ids[i] = request.ID
+	}
+
+	feedback, err := storage.QueryFeedback(ctx, &FeedbackFilter{RequestIDs: ids})
+	if err != nil {
+		return nil, err
+	}
+	for _, fb := range feedback {
+		if result, ok := byRequest[fb.RequestID]; ok {
+			result.Feedback = append(result.Feedback, fb)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,126 @@
+package llmtracer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// feedbackStorage adds in-memory feedback support to the mock
+type feedbackStorage struct {
+	*MockStorageAdapter
+	feedback []*Feedback
+}
+
+func (s *feedbackStorage) SaveFeedback(ctx context.Context, feedback *Feedback) error {
+	s.feedback = append(s.feedback, feedback)
+	return nil
+}
+
+func (s *feedbackStorage) QueryFeedback(ctx context.Context, filter *FeedbackFilter) ([]*Feedback, error) {
+	var results []*Feedback
+	for _, feedback := range s.feedback {
+		if filter.Matches(feedback) {
+			results = append(results, feedback)
+		}
+	}
+	return results, nil
+}
+
+func TestFeedback(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Record and join with requests", func(t *testing.T) {
+		mock, _ := newRecordingStorage()
+		storage := &feedbackStorage{MockStorageAdapter: mock}
+		client := NewClient(storage)
+
+		ctx, handle := WithRequestHandle(ctx)
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4", 10, 5, time.Second, nil, nil))
+		requestID := handle.Request().ID
+
+		up := true
+		feedback := &Feedback{RequestID: requestID, ThumbsUp: &up, Comment: "helpful", Evaluator: "user"}
+		require.NoError(t, client.RecordFeedback(ctx, feedback))
+		assert.NotEmpty(t, feedback.ID)
+		assert.False(t, feedback.CreatedAt.IsZero())
+
+		err := client.RecordFeedback(ctx, &Feedback{RequestID: "missing", ThumbsUp: &up})
+		assert.Error(t, err)
+		assert.Error(t, client.RecordFeedback(ctx, &Feedback{}))
+
+		results, err := client.QueryFeedback(ctx, &FeedbackFilter{Evaluator: "user"})
+		require.NoError(t, err)
+		assert.Len(t, results, 1)
+
+		joined, err := client.GetRequestFeedback(ctx, &RequestFilter{Model: "gpt-4"})
+		require.NoError(t, err)
+		require.Len(t, joined, 1)
+		assert.Equal(t, requestID, joined[0].Request.ID)
+		require.Len(t, joined[0].Feedback, 1)
+		assert.Equal(t, "helpful", joined[0].Feedback[0].Comment)
+	})
+
+	t.Run("Wrapped storage", func(t *testing.T) {
+		keys, err := NewStaticKeyProvider("v1", map[string][]byte{"v1": bytes.Repeat([]byte{1}, 32)})
+		require.NoError(t, err)
+		mock, _ := newRecordingStorage()
+		storage := &feedbackStorage{MockStorageAdapter: mock}
+		client := NewClient(storage, WithEncryption(keys), WithPseudonymization([]byte("secret")))
+
+		ctx, handle := WithRequestHandle(ctx)
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4", 10, 5, time.Second, nil, nil))
+
+		rating := 5.0
+		require.NoError(t, client.RecordFeedback(ctx, &Feedback{RequestID: handle.Request().ID, Rating: &rating}))
+		assert.Len(t, storage.feedback, 1)
+	})
+
+	t.Run("Unsupported storage", func(t *testing.T) {
+		client := NewClient(&MockStorageAdapter{})
+
+		assert.ErrorIs(t, client.RecordFeedback(ctx, &Feedback{RequestID: "id"}), ErrFeedbackNotSupported)
+		_, err := client.QueryFeedback(ctx, nil)
+		assert.ErrorIs(t, err, ErrFeedbackNotSupported)
+		_, err = client.GetRequestFeedback(ctx, nil)
+		assert.ErrorIs(t, err, ErrFeedbackNotSupported)
+	})
+}
+
+func TestFeedbackFilterMatches(t *testing.T) {
+	now := time.Now()
+	rating, up := 3.0, true
+	feedback := &Feedback{RequestID: "req-1", Rating: &rating, ThumbsUp: &up, Evaluator: "user", CreatedAt: now}
+
+	low, high, down := 4.0, 2.0, false
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		filter *FeedbackFilter
+		want   bool
+	}{
+		{"nil filter", nil, true},
+		{"empty filter", &FeedbackFilter{}, true},
+		{"request ID", &FeedbackFilter{RequestIDs: []string{"other", "req-1"}}, true},
+		{"other request ID", &FeedbackFilter{RequestIDs: []string{"other"}}, false},
+		{"evaluator", &FeedbackFilter{Evaluator: "llm-judge"}, false},
+		{"thumbs down", &FeedbackFilter{ThumbsUp: &down}, false},
+		{"min rating", &FeedbackFilter{MinRating: &low}, false},
+		{"max rating", &FeedbackFilter{MaxRating: &high}, false},
+		{"time range", &FeedbackFilter{StartTime: &before, EndTime: &after}, true},
+		{"after end", &FeedbackFilter{EndTime: &before}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(feedback))
+		})
+	}
+
+	// Rating bounds skip feedback without a rating
+	assert.False(t, (&FeedbackFilter{MaxRating: &low}).Matches(&Feedback{}))
+}
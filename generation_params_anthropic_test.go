@@ -0,0 +1,37 @@
+//go:build !llmtracer_no_anthropic
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnthropicGenerationParams(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithGenerationParamsCapture(true))
+
+	body := anthropic.MessageNewParams{
+		Model:       anthropic.ModelClaude3_5SonnetLatest,
+		MaxTokens:   1000,
+		Temperature: anthropic.Float(0.2),
+	}
+	mockFunc := func(ctx context.Context, body anthropic.MessageNewParams, opts ...option.RequestOption) (*anthropic.Message, error) {
+		return &anthropic.Message{}, nil
+	}
+
+	_, err := client.TraceAnthropicRequest(context.Background(), body, mockFunc)
+	assert.NoError(t, err)
+
+	require.Len(t, storage.SaveCalls, 1)
+	params := storage.SaveCalls[0].Request.Params
+	require.NotNil(t, params.Temperature)
+	assert.Equal(t, 0.2, *params.Temperature)
+	require.NotNil(t, params.MaxTokens)
+	assert.Equal(t, 1000, *params.MaxTokens)
+}
@@ -0,0 +1,37 @@
+//go:build !llmtracer_no_mistral
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	mistral "github.com/gage-technologies/mistral-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMistralGenerationParams(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithGenerationParamsCapture(true))
+
+	mockFunc := func(model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams) (*mistral.ChatCompletionResponse, error) {
+		return &mistral.ChatCompletionResponse{}, nil
+	}
+
+	_, err := client.TraceMistralRequest(context.Background(), "mistral-small", nil, &mistral.ChatRequestParams{
+		Temperature:    0.7,
+		TopP:           1,
+		MaxTokens:      100,
+		ResponseFormat: mistral.ResponseFormatJsonObject,
+	}, mockFunc)
+	assert.NoError(t, err)
+
+	require.Len(t, storage.SaveCalls, 1)
+	params := storage.SaveCalls[0].Request.Params
+	require.NotNil(t, params.Temperature)
+	assert.Equal(t, 0.7, *params.Temperature)
+	require.NotNil(t, params.MaxTokens)
+	assert.Equal(t, 100, *params.MaxTokens)
+	assert.Equal(t, "json_object", params.ResponseFormat)
+}
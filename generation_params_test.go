@@ -0,0 +1,59 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerationParamsCapture(t *testing.T) {
+	openAIRequest := openai.ChatCompletionRequest{
+		Model:       "gpt-4o",
+		Temperature: 0.5,
+		MaxTokens:   256,
+		Tools: []openai.Tool{
+			{Type: openai.ToolTypeFunction},
+			{Type: openai.ToolTypeFunction},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		},
+	}
+	openAIFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+		return openai.ChatCompletionResponse{}, nil
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		_, err := client.TraceOpenAIRequest(context.Background(), openAIRequest, openAIFunc)
+		assert.NoError(t, err)
+
+		require.Len(t, storage.SaveCalls, 1)
+		assert.Equal(t, GenerationParams{}, storage.SaveCalls[0].Request.Params)
+	})
+
+	t.Run("OpenAI parameters", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage, WithGenerationParamsCapture(true))
+
+		_, err := client.TraceOpenAIRequest(context.Background(), openAIRequest, openAIFunc)
+		assert.NoError(t, err)
+
+		require.Len(t, storage.SaveCalls, 1)
+		params := storage.SaveCalls[0].Request.Params
+		require.NotNil(t, params.Temperature)
+		assert.Equal(t, 0.5, *params.Temperature)
+		assert.Nil(t, params.TopP)
+		require.NotNil(t, params.MaxTokens)
+		assert.Equal(t, 256, *params.MaxTokens)
+		assert.Equal(t, 2, params.ToolCount)
+		assert.Equal(t, "json_object", params.ResponseFormat)
+	})
+}
@@ -0,0 +1,164 @@
+//go:build !llmtracer_no_google
+
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// GoogleGenerateContentFunc represents the signature of Google's GenerativeModel.GenerateContent method
+type GoogleGenerateContentFunc func(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error)
+
+// GoogleCountTokensFunc represents the signature of Google's GenerativeModel.CountTokens method
+type GoogleCountTokensFunc func(ctx context.Context, parts ...genai.Part) (*genai.CountTokensResponse, error)
+
+// TraceGoogleRequest wraps Google's GenerativeModel.GenerateContent method and automatically tracks token usage
+func (c *Client) TraceGoogleRequest(ctx context.Context, model string, parts []genai.Part, generateContent GoogleGenerateContentFunc) (*genai.GenerateContentResponse, error) {
+	if generateContent == nil {
+		return nil, fmt.Errorf("generateContent function cannot be nil")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("model cannot be empty")
+	}
+	if err := c.admit(ctx, ProviderGoogle, model); err != nil {
+		return nil, err
+	}
+	release, queueWait, err := c.acquire(ctx, ProviderGoogle, model)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := &Request{
+		Provider:  ProviderGoogle,
+		Model:     model,
+		QueueWait: queueWait,
+	}
+	// GenerateContent sends the parts as a single user turn
+	if len(parts) > 0 {
+		tracked.MessageCount = 1
+	}
+	tracked = c.startRequest(ctx, tracked)
+	startTime := time.Now()
+
+	// Make the actual Google API call using the provided function
+	response, err := generateContent(ctx, parts...)
+	release()
+
+	// Track the request - even if it failed
+	tracked.RequestedAt = startTime
+	tracked.Latency = time.Since(startTime)
+	if err == nil {
+		tracked.SetUsage(GoogleUsage(response.UsageMetadata))
+	}
+	if err == nil && len(response.Candidates) > 0 {
+		tracked.FinishReason = googleFinishReason(response.Candidates[0].FinishReason)
+	}
+	applyGoogleMetadata(tracked, err)
+	if c.capturePayloadSizes {
+		tracked.RequestBytes = jsonSize(parts)
+		if err == nil {
+			tracked.ResponseBytes = jsonSize(response)
+		}
+	}
+
+	// Extract tracking context from context if available
+	trackingContext := GetDimensionsFromContext(ctx)
+
+	c.track(ctx, tracked, err, trackingContext)
+
+	// Return the original response and error
+	return response, err
+}
+
+// TraceGoogleCountTokens wraps Google's GenerativeModel.CountTokens and tracks the call as a
+// RequestTypeTokenCount request
+func (c *Client) TraceGoogleCountTokens(ctx context.Context, model string, parts []genai.Part, countTokens GoogleCountTokensFunc) (*genai.CountTokensResponse, error) {
+	if countTokens == nil {
+		return nil, fmt.Errorf("countTokens function cannot be nil")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("model cannot be empty")
+	}
+	if err := c.admit(ctx, ProviderGoogle, model); err != nil {
+		return nil, err
+	}
+	release, queueWait, err := c.acquire(ctx, ProviderGoogle, model)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := &Request{
+		Provider:    ProviderGoogle,
+		Model:       model,
+		RequestType: RequestTypeTokenCount,
+		QueueWait:   queueWait,
+	}
+	if len(parts) > 0 {
+		tracked.MessageCount = 1
+	}
+	tracked = c.startRequest(ctx, tracked)
+	startTime := time.Now()
+
+	response, err := countTokens(ctx, parts...)
+	release()
+
+	tracked.RequestedAt = startTime
+	tracked.Latency = time.Since(startTime)
+	applyGoogleMetadata(tracked, err)
+
+	trackingContext := GetDimensionsFromContext(ctx)
+	if err == nil && response != nil {
+		trackingContext[DimensionCountedTokens] = response.TotalTokens
+	}
+
+	c.track(ctx, tracked, err, trackingContext)
+
+	return response, err
+}
+
+// GoogleUsage maps Gemini usage metadata, which may be nil, to Usage
+func GoogleUsage(metadata *genai.UsageMetadata) Usage {
+	if metadata == nil {
+		return Usage{}
+	}
+	return Usage{
+		InputTokens:       int64(metadata.PromptTokenCount),
+		OutputTokens:      int64(metadata.CandidatesTokenCount),
+		CachedInputTokens: int64(metadata.CachedContentTokenCount),
+	}
+}
+
+// applyGoogleMetadata copies the status code and error reason from a Google call
+func applyGoogleMetadata(request *Request, err error) {
+	// Google errors come from gax/googleapi; match on behavior to avoid importing their packages
+	var httpCoder interface{ HTTPCode() int }
+	if errors.As(err, &httpCoder) && httpCoder.HTTPCode() > 0 {
+		request.StatusCode = httpCoder.HTTPCode()
+	}
+
+	var reasoner interface{ Reason() string }
+	if errors.As(err, &reasoner) {
+		request.ProviderErrorCode = reasoner.Reason()
+	}
+}
+
+// googleFinishReason maps a Gemini finish reason to the common names
+func googleFinishReason(reason genai.FinishReason) string {
+	switch reason {
+	case genai.FinishReasonUnspecified:
+		return ""
+	case genai.FinishReasonStop:
+		return FinishReasonStop
+	case genai.FinishReasonMaxTokens:
+		return FinishReasonLength
+	case genai.FinishReasonSafety:
+		return FinishReasonContentFilter
+	}
+	return normalizeFinishReason(strings.TrimPrefix(reason.String(), "FinishReason"))
+}
@@ -0,0 +1,135 @@
+package llmtracer
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+// ProviderHealth summarizes the availability, errors and latency of a provider model
+type ProviderHealth struct {
+	Provider      Provider `json:"provider"`
+	Model         string   `json:"model"`
+	TotalRequests int64    `json:"total_requests"`
+	ErrorCount    int64    `json:"error_count"`
+	// Availability is the fraction of requests that did not fail on the provider's side
+	// (server errors, timeouts, network errors and rate limits). Invalid requests and
+	// authentication failures are the caller's fault and do not reduce availability.
+	Availability float64 `json:"availability"`
+	// ErrorRate is the fraction of requests that failed for any reason
+	ErrorRate float64 `json:"error_rate"`
+	// ErrorRates is the fraction of requests that failed with each error type
+	ErrorRates map[ErrorType]float64 `json:"error_rates"`
+	AvgLatency time.Duration         `json:"avg_latency"`
+	LatencyP50 time.Duration         `json:"latency_p50"`
+	LatencyP90 time.Duration         `json:"latency_p90"`
+	LatencyP95 time.Duration         `json:"latency_p95"`
+	LatencyP99 time.Duration         `json:"latency_p99"`
+	// IncidentActive is set when the provider had a status page incident during the
+	// window; Incidents lists them. Both need incident storage (see WithIncidentPolling).
+	IncidentActive bool                `json:"incident_active"`
+	Incidents      []*ProviderIncident `json:"incidents,omitempty"`
+}
+
+// providerSideErrors are the error types that count against availability
+var providerSideErrors = map[ErrorType]bool{
+	ErrorTypeServerError: true,
+	ErrorTypeTimeout:     true,
+	ErrorTypeNetwork:     true,
+	ErrorTypeRateLimit:   true,
+	ErrorTypeOverloaded:  true,
+}
+
+// GetProviderHealth reports the health of each provider model over the trailing window,
+// keyed by "provider/model", so routing layers can prefer the healthiest provider.
+// Latency percentiles cover successful requests only, since failures often return early.
+// When the storage adapter stores incidents, each model is annotated with the incidents
+// of its provider during the window.
+func (c *Client) GetProviderHealth(ctx context.Context, window time.Duration) (map[string]*ProviderHealth, error) {
+	now := time.Now()
+	since := now.Add(-window)
+	requests, err := c.query(ctx, &RequestFilter{StartTime: &since})
+	if err != nil {
+		return nil, err
+	}
+
+	var incidents map[Provider][]*ProviderIncident
+	if storage, ok := storageCapability[IncidentStorage](c.storage); ok {
+		active, err := storage.QueryIncidents(ctx, &IncidentFilter{StartTime: &since, EndTime: &now})
+		if err != nil {
+			return nil, err
+		}
+		incidents = incidentsByProvider(active)
+	}
+
+	health := make(map[string]*ProviderHealth)
+	latencies := make(map[string][]time.Duration)
+	unavailable := make(map[string]int64)
+
+	for _, req := range requests {
+		key := string(req.Provider) + "/" + req.Model
+		h, exists := health[key]
+		if !exists {
+			h = &ProviderHealth{
+				Provider:   req.Provider,
+				Model:      req.Model,
+				ErrorRates: make(map[ErrorType]float64),
+				Incidents:  incidents[req.Provider],
+			}
+			h.IncidentActive = len(h.Incidents) > 0
+			health[key] = h
+		}
+
+		h.TotalRequests++
+		if req.Error != "" {
+			h.ErrorCount++
+			errorType := req.ErrorType
+			if errorType == ErrorTypeNone {
+				errorType = ErrorTypeUnknown
+			}
+			h.ErrorRates[errorType]++
+			if providerSideErrors[errorType] {
+				unavailable[key]++
+			}
+			continue
+		}
+		latencies[key] = append(latencies[key], req.Latency)
+	}
+
+	for key, h := range health {
+		total := float64(h.TotalRequests)
+		h.Availability = 1 - float64(unavailable[key])/total
+		h.ErrorRate = float64(h.ErrorCount) / total
+		for errorType, count := range h.ErrorRates {
+			h.ErrorRates[errorType] = count / total
+		}
+
+		sorted := latencies[key]
+		if len(sorted) == 0 {
+			continue
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		var sum time.Duration
+		for _, latency := range sorted {
+			sum += latency
+		}
+		h.AvgLatency = sum / time.Duration(len(sorted))
+		h.LatencyP50 = percentile(sorted, 50)
+		h.LatencyP90 = percentile(sorted, 90)
+		h.LatencyP95 = percentile(sorted, 95)
+		h.LatencyP99 = percentile(sorted, 99)
+	}
+
+	return health, nil
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile[T any](sorted []T, p float64) T {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
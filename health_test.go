@@ -0,0 +1,56 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetProviderHealth(t *testing.T) {
+	var requests []*Request
+	for i := 1; i <= 100; i++ {
+		requests = append(requests, &Request{
+			Provider: ProviderOpenAI,
+			Model:    "gpt-4o",
+			Latency:  time.Duration(i) * time.Millisecond,
+		})
+	}
+	requests = append(requests,
+		&Request{Provider: ProviderOpenAI, Model: "gpt-4o", Error: "HTTP 503", ErrorType: ErrorTypeServerError, Latency: time.Second},
+		&Request{Provider: ProviderOpenAI, Model: "gpt-4o", Error: "HTTP 400", ErrorType: ErrorTypeInvalidRequest},
+		&Request{Provider: ProviderAnthropic, Model: "claude-3-haiku", Error: "timeout", ErrorType: ErrorTypeTimeout},
+	)
+
+	storage := &MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			require.NotNil(t, filter.StartTime)
+			assert.WithinDuration(t, time.Now().Add(-time.Hour), *filter.StartTime, time.Minute)
+			return requests, nil
+		},
+	}
+	client := NewClient(storage)
+
+	health, err := client.GetProviderHealth(context.Background(), time.Hour)
+	require.NoError(t, err)
+	require.Len(t, health, 2)
+
+	openAI := health["openai/gpt-4o"]
+	require.NotNil(t, openAI)
+	assert.Equal(t, int64(102), openAI.TotalRequests)
+	assert.Equal(t, int64(2), openAI.ErrorCount)
+	assert.InDelta(t, 1-1.0/102, openAI.Availability, 1e-9)
+	assert.InDelta(t, 2.0/102, openAI.ErrorRate, 1e-9)
+	assert.InDelta(t, 1.0/102, openAI.ErrorRates[ErrorTypeServerError], 1e-9)
+	assert.Equal(t, 50*time.Millisecond, openAI.LatencyP50)
+	assert.Equal(t, 95*time.Millisecond, openAI.LatencyP95)
+	assert.Equal(t, 99*time.Millisecond, openAI.LatencyP99)
+	assert.Equal(t, 50500*time.Microsecond, openAI.AvgLatency)
+
+	anthropic := health["anthropic/claude-3-haiku"]
+	require.NotNil(t, anthropic)
+	assert.Equal(t, 0.0, anthropic.Availability)
+	assert.Zero(t, anthropic.LatencyP50)
+}
@@ -0,0 +1,160 @@
+package llmtracer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const requestIDKey contextKey = "llm_request_id"
+
+// IDGenerator returns the ID of a new request. IDs must be unique; the GORM adapter stores
+// them in a string primary key.
+type IDGenerator func() string
+
+// NewUUID returns a random UUIDv4, the default request ID
+func NewUUID() string {
+	return uuid.New().String()
+}
+
+// crockford is the base32 alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var ulidState struct {
+	mu      sync.Mutex
+	ms      uint64
+	entropy [10]byte
+}
+
+// NewULID returns a ULID: 26 characters that sort by creation time, so new requests are
+// appended to the end of the primary key index instead of scattered across it. IDs created
+// within the same millisecond by one process still sort in creation order.
+func NewULID() string {
+	ms := uint64(time.Now().UnixMilli())
+
+	ulidState.mu.Lock()
+	if ms > ulidState.ms {
+		ulidState.ms = ms
+		if _, err := rand.Read(ulidState.entropy[:]); err != nil {
+			panic(err)
+		}
+	} else {
+		// Same millisecond, or the clock went back: increment the last ID's entropy
+		ms = ulidState.ms
+		for i := len(ulidState.entropy) - 1; i >= 0; i-- {
+			ulidState.entropy[i]++
+			if ulidState.entropy[i] != 0 {
+				break
+			}
+		}
+	}
+	entropy := ulidState.entropy
+	ulidState.mu.Unlock()
+	return encodeULID(ms, entropy)
+}
+
+// encodeULID encodes a 48-bit millisecond timestamp and 80 bits of entropy in Crockford
+// base32
+func encodeULID(ms uint64, entropy [10]byte) string {
+	var raw [16]byte
+	binary.BigEndian.PutUint16(raw[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(raw[2:6], uint32(ms))
+	copy(raw[6:], entropy[:])
+
+	// 128 bits in 26 characters of 5 bits, the first holding the 3 leftover bits
+	hi := binary.BigEndian.Uint64(raw[0:8])
+	lo := binary.BigEndian.Uint64(raw[8:16])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// Snowflake IDs hold a millisecond timestamp, a node number and a per-millisecond sequence
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	// MaxSnowflakeNode is the largest node number of NewSnowflakeGenerator
+	MaxSnowflakeNode = 1<<snowflakeNodeBits - 1
+)
+
+// snowflakeEpoch is the start of snowflake timestamps; their 41 bits last until 2089
+var snowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+// NewSnowflakeGenerator returns a generator of snowflake IDs: 63-bit integers made of the
+// milliseconds since 2020, the node and a sequence number, for systems that key requests
+// by number. Every process generating IDs for the same storage needs its own node, from 0
+// to MaxSnowflakeNode. IDs are zero-padded to 19 digits, so they sort by creation time as
+// strings as well as numbers. When a process generates more than 4096 IDs in a
+// millisecond, or its clock goes back, IDs borrow the following milliseconds instead of
+// waiting.
+func NewSnowflakeGenerator(node int) (IDGenerator, error) {
+	if node < 0 || node > MaxSnowflakeNode {
+		return nil, fmt.Errorf("snowflake node must be between 0 and %d, got %d", MaxSnowflakeNode, node)
+	}
+
+	var mu sync.Mutex
+	var last, sequence int64
+	return func() string {
+		ms := time.Now().UnixMilli() - snowflakeEpoch
+
+		mu.Lock()
+		if ms > last {
+			last = ms
+			sequence = 0
+		} else {
+			sequence++
+			if sequence == 1<<snowflakeSequenceBits {
+				last++
+				sequence = 0
+			}
+		}
+		id := last<<(snowflakeNodeBits+snowflakeSequenceBits) | int64(node)<<snowflakeSequenceBits | sequence
+		mu.Unlock()
+		return fmt.Sprintf("%019d", id)
+	}, nil
+}
+
+// WithIDGenerator sets how the IDs of tracked requests are generated, e.g.
+// WithIDGenerator(NewULID) for IDs that sort by time, or a NewSnowflakeGenerator for
+// numeric ones. IDs set with WithRequestID or
+// RequestOptions.ID take precedence.
+func WithIDGenerator(generate IDGenerator) ClientOption {
+	return func(c *Client) {
+		if generate != nil {
+			c.newID = generate
+		}
+	}
+}
+
+// WithRequestID sets the ID of the request tracked with the context, e.g. the identifier a
+// gateway gave the call, so both systems refer to it by the same ID. The ID must be unique:
+// storage rejects a second request saved with it, so use a context per call.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// GetRequestIDFromContext returns the request ID set with WithRequestID, or "" when unset
+func GetRequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// requestID returns the ID of a request tracked with ctx
+func (c *Client) requestID(ctx context.Context) string {
+	if id := GetRequestIDFromContext(ctx); id != "" {
+		return id
+	}
+	return c.newID()
+}
@@ -0,0 +1,98 @@
+package llmtracer
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewULID(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	id := encodeULID(uint64(at.UnixMilli()), [10]byte{9: 1})
+	// The first 10 characters encode the millisecond timestamp
+	assert.Equal(t, "01HWT0D7G00000000000000001", id)
+	assert.Regexp(t, "^[0-9A-HJKMNP-TV-Z]{26}$", NewULID())
+
+	var ids []string
+	for i := 0; i < 1000; i++ {
+		ids = append(ids, NewULID())
+	}
+	assert.True(t, sort.StringsAreSorted(ids), "IDs sort in creation order, also within a millisecond")
+
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		assert.False(t, seen[id], "duplicate ULID %s", id)
+		seen[id] = true
+	}
+	assert.Less(t, id, ids[0], "older IDs sort first")
+}
+
+func TestNewSnowflakeGenerator(t *testing.T) {
+	_, err := NewSnowflakeGenerator(-1)
+	assert.Error(t, err)
+	_, err = NewSnowflakeGenerator(MaxSnowflakeNode + 1)
+	assert.Error(t, err)
+
+	generate, err := NewSnowflakeGenerator(7)
+	require.NoError(t, err)
+	start := time.Now()
+	var ids []string
+	// More than one millisecond's worth of sequence numbers
+	for i := 0; i < 5000; i++ {
+		ids = append(ids, generate())
+	}
+	assert.True(t, sort.StringsAreSorted(ids), "IDs sort in creation order")
+
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		assert.Regexp(t, "^[0-9]{19}$", id)
+		assert.False(t, seen[id], "duplicate snowflake ID %s", id)
+		seen[id] = true
+	}
+
+	id, err := strconv.ParseInt(ids[0], 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), id>>12&MaxSnowflakeNode)
+	created := time.UnixMilli(id>>22 + snowflakeEpoch)
+	assert.WithinDuration(t, start, created, time.Second)
+}
+
+func TestRequestIDs(t *testing.T) {
+	var saved []*Request
+	storage := &MockStorageAdapter{
+		SaveFunc: func(ctx context.Context, request *Request) error {
+			saved = append(saved, request)
+			return nil
+		},
+	}
+	client := NewClient(storage, WithIDGenerator(NewULID))
+	ctx := context.Background()
+
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 10, 5, time.Second, nil, nil))
+	require.NoError(t, client.TrackRequest(WithRequestID(ctx, "gw-123"), ProviderOpenAI, "gpt-4o", 10, 5, time.Second, nil, nil))
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 10, 5, time.Second, nil, &RequestOptions{ID: "gw-456"}))
+
+	require.Len(t, saved, 3)
+	assert.Len(t, saved[0].ID, 26)
+	assert.Equal(t, "gw-123", saved[1].ID)
+	assert.Equal(t, "gw-456", saved[2].ID)
+}
+
+func TestDefaultRequestIDsAreUUIDs(t *testing.T) {
+	var saved *Request
+	storage := &MockStorageAdapter{
+		SaveFunc: func(ctx context.Context, request *Request) error {
+			saved = request
+			return nil
+		},
+	}
+	client := NewClient(storage)
+	require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 10, 5, time.Second, nil, nil))
+	require.NotNil(t, saved)
+	assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[0-9a-f]{4}-[0-9a-f]{12}$", saved.ID)
+}
@@ -0,0 +1,245 @@
+package llmtracer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrIncidentsNotSupported is returned by the incident methods when the storage adapter
+// does not implement IncidentStorage
+var ErrIncidentsNotSupported = errors.New("incidents are not supported by this storage adapter")
+
+// ProviderIncident is an incident published on a provider's status page
+type ProviderIncident struct {
+	// ID is the provider followed by the status page's incident ID, e.g. "openai:abc123"
+	ID       string   `json:"id" gorm:"primaryKey"`
+	Provider Provider `json:"provider" gorm:"index"`
+	Name     string   `json:"name"`
+	// Impact is the status page's rating, e.g. "none", "minor", "major" or "critical"
+	Impact string `json:"impact"`
+	// Status is the status page's incident status, e.g. "investigating" or "resolved"
+	Status    string    `json:"status"`
+	URL       string    `json:"url,omitempty"`
+	StartedAt time.Time `json:"started_at" gorm:"index"`
+	// ResolvedAt is nil while the incident is active
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" gorm:"index"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// ActiveDuring reports whether the incident was active at any time between start and end
+func (i *ProviderIncident) ActiveDuring(start, end time.Time) bool {
+	return !i.StartedAt.After(end) && (i.ResolvedAt == nil || !i.ResolvedAt.Before(start))
+}
+
+// IncidentFilter selects incidents. Zero fields match everything.
+type IncidentFilter struct {
+	Provider Provider
+	// StartTime and EndTime select the incidents active at any time in the range
+	StartTime *time.Time
+	EndTime   *time.Time
+}
+
+// Matches reports whether an incident satisfies the filter's criteria. A nil filter
+// matches every incident.
+func (f *IncidentFilter) Matches(incident *ProviderIncident) bool {
+	if f == nil {
+		return true
+	}
+	if f.Provider != "" && incident.Provider != f.Provider {
+		return false
+	}
+	if f.StartTime != nil && incident.ResolvedAt != nil && incident.ResolvedAt.Before(*f.StartTime) {
+		return false
+	}
+	if f.EndTime != nil && incident.StartedAt.After(*f.EndTime) {
+		return false
+	}
+	return true
+}
+
+// IncidentStorage is implemented by adapters that can store provider incidents
+type IncidentStorage interface {
+	// SaveIncident stores an incident, replacing the stored one with the same ID
+	SaveIncident(ctx context.Context, incident *ProviderIncident) error
+
+	// QueryIncidents returns matching incidents, most recently started first
+	QueryIncidents(ctx context.Context, filter *IncidentFilter) ([]*ProviderIncident, error)
+}
+
+// StatusSource fetches the recent incidents of a provider from its status page or API
+type StatusSource interface {
+	Incidents(ctx context.Context) ([]*ProviderIncident, error)
+}
+
+// StatusSourceFunc adapts a function to a StatusSource
+type StatusSourceFunc func(ctx context.Context) ([]*ProviderIncident, error)
+
+// Incidents calls f
+func (f StatusSourceFunc) Incidents(ctx context.Context) ([]*ProviderIncident, error) {
+	return f(ctx)
+}
+
+// StatuspageSource reads the incidents of a status page hosted on Atlassian Statuspage,
+// or another host serving its API, from pageURL + "/api/v2/incidents.json", e.g.
+// StatuspageSource(ProviderAnthropic, "https://status.anthropic.com")
+func StatuspageSource(provider Provider, pageURL string) StatusSource {
+	return &statuspageSource{
+		provider:   provider,
+		url:        strings.TrimSuffix(pageURL, "/") + "/api/v2/incidents.json",
+		httpClient: http.DefaultClient,
+	}
+}
+
+type statuspageSource struct {
+	provider   Provider
+	url        string
+	httpClient *http.Client
+}
+
+func (s *statuspageSource) Incidents(ctx context.Context) ([]*ProviderIncident, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status page returned HTTP %d", resp.StatusCode)
+	}
+
+	var page struct {
+		Incidents []struct {
+			ID         string     `json:"id"`
+			Name       string     `json:"name"`
+			Status     string     `json:"status"`
+			Impact     string     `json:"impact"`
+			Shortlink  string     `json:"shortlink"`
+			CreatedAt  time.Time  `json:"created_at"`
+			StartedAt  *time.Time `json:"started_at"`
+			ResolvedAt *time.Time `json:"resolved_at"`
+			UpdatedAt  time.Time  `json:"updated_at"`
+		} `json:"incidents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to parse status page: %w", err)
+	}
+
+	incidents := make([]*ProviderIncident, 0, len(page.Incidents))
+	for _, item := range page.Incidents {
+		incident := &ProviderIncident{
+			ID:         string(s.provider) + ":" + item.ID,
+			Provider:   s.provider,
+			Name:       item.Name,
+			Impact:     item.Impact,
+			Status:     item.Status,
+			URL:        item.Shortlink,
+			StartedAt:  item.CreatedAt,
+			ResolvedAt: item.ResolvedAt,
+			UpdatedAt:  item.UpdatedAt,
+		}
+		if item.StartedAt != nil {
+			incident.StartedAt = *item.StartedAt
+		}
+		incidents = append(incidents, incident)
+	}
+	return incidents, nil
+}
+
+// WithIncidentPolling polls the status sources every interval (every five minutes when
+// zero) and stores their incidents until the client is closed, so health reports and
+// aggregates can attribute elevated error rates to provider incidents. The storage
+// adapter must implement IncidentStorage.
+func WithIncidentPolling(interval time.Duration, sources ...StatusSource) ClientOption {
+	return func(c *Client) {
+		c.incidentInterval = interval
+		c.incidentSources = sources
+	}
+}
+
+// runIncidentPolling periodically polls the status sources
+func (c *Client) runIncidentPolling() {
+	ticker := time.NewTicker(c.incidentInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.PollIncidents(context.Background(), c.incidentSources...); err != nil {
+			c.logger.Error("Failed to poll provider status", slog.Any("error", err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.stopIncidentPolling:
+			return
+		}
+	}
+}
+
+// PollIncidents fetches the incidents of each source once and stores them. Sources that
+// fail are skipped; their errors are joined in the result.
+func (c *Client) PollIncidents(ctx context.Context, sources ...StatusSource) error {
+	storage, ok := storageCapability[IncidentStorage](c.storage)
+	if !ok {
+		return ErrIncidentsNotSupported
+	}
+
+	var errs []error
+	for _, source := range sources {
+		incidents, err := source.Incidents(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, incident := range incidents {
+			if err := storage.SaveIncident(ctx, incident); err != nil {
+				errs = append(errs, fmt.Errorf("failed to save incident %q: %w", incident.ID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GetIncidents returns the stored incidents matching filter, most recently started first
+func (c *Client) GetIncidents(ctx context.Context, filter *IncidentFilter) ([]*ProviderIncident, error) {
+	storage, ok := storageCapability[IncidentStorage](c.storage)
+	if !ok {
+		return nil, ErrIncidentsNotSupported
+	}
+	return storage.QueryIncidents(ctx, filter)
+}
+
+// AnnotateIncidents attaches to each aggregate result the incidents its provider had
+// between start and end, so elevated error rates can be attributed to the provider.
+// Results not grouped by provider are left unchanged.
+func (c *Client) AnnotateIncidents(ctx context.Context, results []*AggregateResult, start, end time.Time) error {
+	incidents, err := c.GetIncidents(ctx, &IncidentFilter{StartTime: &start, EndTime: &end})
+	if err != nil {
+		return err
+	}
+	byProvider := incidentsByProvider(incidents)
+	for _, result := range results {
+		if result.Provider == "" {
+			continue
+		}
+		result.Incidents = byProvider[result.Provider]
+		result.IncidentActive = len(result.Incidents) > 0
+	}
+	return nil
+}
+
+// incidentsByProvider groups incidents by provider
+func incidentsByProvider(incidents []*ProviderIncident) map[Provider][]*ProviderIncident {
+	byProvider := make(map[Provider][]*ProviderIncident)
+	for _, incident := range incidents {
+		byProvider[incident.Provider] = append(byProvider[incident.Provider], incident)
+	}
+	return byProvider
+}
@@ -0,0 +1,162 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// incidentStorage adds in-memory incident support to the mock
+type incidentStorage struct {
+	*MockStorageAdapter
+	mu        sync.Mutex
+	incidents map[string]*ProviderIncident
+}
+
+func (s *incidentStorage) SaveIncident(ctx context.Context, incident *ProviderIncident) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.incidents == nil {
+		s.incidents = make(map[string]*ProviderIncident)
+	}
+	s.incidents[incident.ID] = incident
+	return nil
+}
+
+func (s *incidentStorage) QueryIncidents(ctx context.Context, filter *IncidentFilter) ([]*ProviderIncident, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var results []*ProviderIncident
+	for _, incident := range s.incidents {
+		if filter.Matches(incident) {
+			results = append(results, incident)
+		}
+	}
+	return results, nil
+}
+
+const statuspageIncidents = `{"page":{"id":"p1","name":"Provider"},"incidents":[
+	{"id":"inc1","name":"Elevated error rates","status":"investigating","impact":"major","shortlink":"https://stspg.io/inc1",
+	 "created_at":"2025-01-01T10:00:00Z","started_at":"2025-01-01T09:55:00Z","resolved_at":null,"updated_at":"2025-01-01T10:05:00Z"},
+	{"id":"inc0","name":"Slow responses","status":"resolved","impact":"minor","shortlink":"https://stspg.io/inc0",
+	 "created_at":"2024-12-30T08:00:00Z","started_at":"2024-12-30T08:00:00Z","resolved_at":"2024-12-30T09:00:00Z","updated_at":"2024-12-30T09:00:00Z"}
+]}`
+
+func TestStatuspageSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/incidents.json", r.URL.Path)
+		w.Write([]byte(statuspageIncidents))
+	}))
+	defer server.Close()
+
+	incidents, err := StatuspageSource(ProviderAnthropic, server.URL+"/").Incidents(context.Background())
+	require.NoError(t, err)
+	require.Len(t, incidents, 2)
+
+	incident := incidents[0]
+	assert.Equal(t, "anthropic:inc1", incident.ID)
+	assert.Equal(t, ProviderAnthropic, incident.Provider)
+	assert.Equal(t, "Elevated error rates", incident.Name)
+	assert.Equal(t, "major", incident.Impact)
+	assert.Equal(t, "investigating", incident.Status)
+	assert.Equal(t, "https://stspg.io/inc1", incident.URL)
+	assert.Equal(t, time.Date(2025, 1, 1, 9, 55, 0, 0, time.UTC), incident.StartedAt)
+	assert.Nil(t, incident.ResolvedAt)
+	require.NotNil(t, incidents[1].ResolvedAt)
+
+	// Active between the start and an end after it
+	assert.True(t, incident.ActiveDuring(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, incidents[1].ActiveDuring(time.Date(2024, 12, 30, 9, 30, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)))
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	_, err = StatuspageSource(ProviderOpenAI, failing.URL).Incidents(context.Background())
+	assert.Error(t, err)
+}
+
+// staticSource returns fixed incidents
+type staticSource []*ProviderIncident
+
+func (s staticSource) Incidents(ctx context.Context) ([]*ProviderIncident, error) {
+	return s, nil
+}
+
+func TestIncidentCorrelation(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	resolved := now.Add(-2 * time.Hour)
+	source := staticSource{
+		{ID: "openai:1", Provider: ProviderOpenAI, Name: "Elevated errors", StartedAt: now.Add(-10 * time.Minute)},
+		{ID: "openai:0", Provider: ProviderOpenAI, StartedAt: now.Add(-3 * time.Hour), ResolvedAt: &resolved},
+	}
+
+	mock := &MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			return []*Request{
+				{Provider: ProviderOpenAI, Model: "gpt-4o", Error: "HTTP 500", ErrorType: ErrorTypeServerError},
+				{Provider: ProviderAnthropic, Model: "claude-3-5-sonnet", Latency: time.Second},
+			}, nil
+		},
+	}
+	storage := &incidentStorage{MockStorageAdapter: mock}
+	client := NewClient(storage)
+	require.NoError(t, client.PollIncidents(ctx, source))
+
+	incidents, err := client.GetIncidents(ctx, &IncidentFilter{Provider: ProviderOpenAI})
+	require.NoError(t, err)
+	assert.Len(t, incidents, 2)
+
+	t.Run("Health reports", func(t *testing.T) {
+		health, err := client.GetProviderHealth(ctx, time.Hour)
+		require.NoError(t, err)
+		openai := health["openai/gpt-4o"]
+		assert.True(t, openai.IncidentActive)
+		require.Len(t, openai.Incidents, 1, "the resolved incident ended before the window")
+		assert.Equal(t, "openai:1", openai.Incidents[0].ID)
+		assert.False(t, health["anthropic/claude-3-5-sonnet"].IncidentActive)
+	})
+
+	t.Run("Aggregates", func(t *testing.T) {
+		results := []*AggregateResult{{Provider: ProviderOpenAI, ErrorCount: 1}, {Provider: ProviderAnthropic}, {Model: "gpt-4o"}}
+		require.NoError(t, client.AnnotateIncidents(ctx, results, now.Add(-4*time.Hour), now))
+		assert.True(t, results[0].IncidentActive)
+		assert.Len(t, results[0].Incidents, 2)
+		assert.False(t, results[1].IncidentActive)
+		assert.Nil(t, results[2].Incidents)
+	})
+
+	t.Run("Unsupported storage", func(t *testing.T) {
+		plain := NewClient(&MockStorageAdapter{QueryFunc: mock.QueryFunc})
+		assert.ErrorIs(t, plain.PollIncidents(ctx, source), ErrIncidentsNotSupported)
+		_, err := plain.GetIncidents(ctx, nil)
+		assert.ErrorIs(t, err, ErrIncidentsNotSupported)
+
+		health, err := plain.GetProviderHealth(ctx, time.Hour)
+		require.NoError(t, err)
+		assert.False(t, health["openai/gpt-4o"].IncidentActive)
+	})
+}
+
+func TestIncidentPolling(t *testing.T) {
+	storage := &incidentStorage{MockStorageAdapter: &MockStorageAdapter{}}
+	failing := StatusSourceFunc(func(ctx context.Context) ([]*ProviderIncident, error) {
+		return nil, errors.New("status page unreachable")
+	})
+	client := NewClient(storage, WithIncidentPolling(time.Hour, failing,
+		staticSource{{ID: "google:1", Provider: ProviderGoogle, StartedAt: time.Now()}}))
+
+	require.Eventually(t, func() bool {
+		incidents, _ := client.GetIncidents(context.Background(), nil)
+		return len(incidents) == 1
+	}, time.Second, 10*time.Millisecond, "a failing source does not stop the others")
+	require.NoError(t, client.Close())
+}
@@ -0,0 +1,187 @@
+package llmtracer
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// inheritedTraceTTL is how long the dimensions of a trace are remembered after its last
+// request
+const inheritedTraceTTL = time.Hour
+
+// WithTraceDimensionInheritance fills in the given dimension keys, such as user_id and
+// feature, from the first request of the trace when a request doesn't set them, so calls
+// made deep in library code without the caller's context are still attributed. Only
+// requests whose context carries a trace ID (see WithTraceID) inherit.
+//
+// The dimensions of each trace are remembered in memory for an hour after its last
+// request. The first time a process sees a trace, its earliest stored request is read
+// from storage, so traces spanning services inherit too. The read happens where the
+// request is saved, in the tracking goroutine with WithAsyncTracking, and goes through
+// the circuit breaker. Traces whose ID HTTPMiddleware generated are never read, and
+// adapters without Query support only inherit within the process. Dimensions set by the call take precedence over
+// inherited ones, which take precedence over WithGlobalDimensions.
+func WithTraceDimensionInheritance(keys ...string) ClientOption {
+	return func(c *Client) {
+		c.inheritance.keys = append(c.inheritance.keys, keys...)
+	}
+}
+
+// inheritedTrace holds the dimensions a trace's requests inherit
+type inheritedTrace struct {
+	dimensions map[string]string
+	lastSeen   time.Time
+}
+
+// traceInheritance remembers the inherited dimensions of recent traces
+type traceInheritance struct {
+	keys []string
+
+	mu        sync.Mutex
+	traces    map[string]*inheritedTrace
+	lastPrune time.Time
+}
+
+// lookup returns the remembered dimensions of a trace
+func (t *traceInheritance) lookup(traceID string, now time.Time) (map[string]string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+	trace, ok := t.traces[traceID]
+	if !ok {
+		return nil, false
+	}
+	trace.lastSeen = now
+	return trace.dimensions, true
+}
+
+// remember records the dimensions of a trace's first request, unless another request of
+// the trace was remembered first, and returns the dimensions remembered
+func (t *traceInheritance) remember(traceID string, dimensions []DimensionTag, now time.Time) map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if trace, ok := t.traces[traceID]; ok {
+		trace.lastSeen = now
+		return trace.dimensions
+	}
+	inherited := make(map[string]string, len(t.keys))
+	for _, key := range t.keys {
+		if value, ok := lookupDimension(dimensions, key); ok {
+			inherited[key] = value
+		}
+	}
+	if t.traces == nil {
+		t.traces = make(map[string]*inheritedTrace)
+	}
+	t.traces[traceID] = &inheritedTrace{dimensions: inherited, lastSeen: now}
+	return inherited
+}
+
+// prune forgets traces without requests for inheritedTraceTTL, at most once a minute
+func (t *traceInheritance) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < time.Minute {
+		return
+	}
+	t.lastPrune = now
+	for traceID, trace := range t.traces {
+		if now.Sub(trace.lastSeen) > inheritedTraceTTL {
+			delete(t.traces, traceID)
+		}
+	}
+}
+
+// inheritDimensions returns the dimension tags of a request, with the keys configured by
+// WithTraceDimensionInheritance filled in from the remembered first request of its trace.
+// complete is false when the trace is not remembered and its first request must be read
+// from storage with storedInheritedDimensions, which the caller does off the hot path.
+func (c *Client) inheritDimensions(ctx context.Context, traceID string, trackingContext map[string]interface{}) (dimensions []DimensionTag, complete bool) {
+	if len(c.inheritance.keys) == 0 || ctx == nil {
+		return c.dimensionTags(trackingContext), true
+	}
+	if explicit, _ := ctx.Value(traceIDKey).(string); explicit == "" || explicit != traceID {
+		return c.dimensionTags(trackingContext), true
+	}
+
+	now := time.Now()
+	if inherited, ok := c.inheritance.lookup(traceID, now); ok {
+		return c.mergeInherited(inherited, trackingContext), true
+	}
+	dimensions = c.dimensionTags(trackingContext)
+	// No other process has seen a trace generated here, so storage has nothing to add
+	if isNewTrace(ctx, traceID) || !c.storage.Capabilities().Query {
+		c.inheritance.remember(traceID, dimensions, now)
+		return dimensions, true
+	}
+	return dimensions, false
+}
+
+// storedInheritedDimensions returns the dimension tags of a request, reading the first
+// request of its trace from storage when the trace is not remembered. The read goes
+// through the circuit breaker like storage writes.
+func (c *Client) storedInheritedDimensions(ctx context.Context, traceID string, trackingContext map[string]interface{}) []DimensionTag {
+	dimensions, complete := c.inheritDimensions(ctx, traceID, trackingContext)
+	if complete {
+		return dimensions
+	}
+
+	now := time.Now()
+	first := c.firstStoredRequest(ctx, traceID)
+	if first == nil {
+		c.inheritance.remember(traceID, dimensions, now)
+		return dimensions
+	}
+	return c.mergeInherited(c.inheritance.remember(traceID, first.Dimensions, now), trackingContext)
+}
+
+// mergeInherited returns the dimension tags of trackingContext with inherited dimensions
+// filling in the keys it doesn't set
+func (c *Client) mergeInherited(inherited map[string]string, trackingContext map[string]interface{}) []DimensionTag {
+	if len(inherited) == 0 {
+		return c.dimensionTags(trackingContext)
+	}
+	merged := make(map[string]interface{}, len(trackingContext)+len(inherited))
+	for key, value := range inherited {
+		merged[key] = value
+	}
+	for key, value := range trackingContext {
+		merged[key] = value
+	}
+	return c.dimensionTags(merged)
+}
+
+// firstStoredRequest returns the earliest completed request of a trace in storage, or nil
+// when there is none or it cannot be read
+func (c *Client) firstStoredRequest(ctx context.Context, traceID string) *Request {
+	var requests []*Request
+	err := c.callStorage(func() error {
+		var err error
+		requests, err = c.storage.GetByTraceID(ctx, traceID)
+		return err
+	})
+	if err != nil {
+		c.logger.Warn("Failed to read trace for dimension inheritance",
+			slog.String("trace_id", traceID),
+			slog.String("error", err.Error()),
+		)
+		return nil
+	}
+
+	var completed []*Request
+	for _, request := range requests {
+		if !request.Pending {
+			completed = append(completed, request)
+		}
+	}
+	if len(completed) == 0 {
+		return nil
+	}
+	sort.SliceStable(completed, func(i, j int) bool {
+		return completed[i].RequestedAt.Before(completed[j].RequestedAt)
+	})
+	return completed[0]
+}
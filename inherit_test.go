@@ -0,0 +1,126 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTraceDimensionInheritance(t *testing.T) {
+	t.Run("Requests inherit from the first request of their trace", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage, WithTraceDimensionInheritance(DimensionUserID, DimensionFeature))
+
+		ctx := WithTraceID(context.Background(), "trace-1")
+		first := WithFeature(WithUserID(ctx, "alice"), "search")
+		require.NoError(t, client.TrackRequest(first, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		require.NoError(t, client.TrackRequest(WithFeature(ctx, "rerank"), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+
+		require.Len(t, storage.SaveCalls, 4)
+		assert.Equal(t, map[string]string{DimensionUserID: "alice", DimensionFeature: "search"}, storage.SaveCalls[1].Request.DimensionsMap())
+		assert.Equal(t, map[string]string{DimensionUserID: "alice", DimensionFeature: "rerank"}, storage.SaveCalls[2].Request.DimensionsMap(),
+			"the call's dimensions take precedence")
+		assert.Empty(t, storage.SaveCalls[3].Request.DimensionsMap(), "requests without a trace ID don't inherit")
+	})
+
+	t.Run("Traces started elsewhere are read from storage", func(t *testing.T) {
+		earlier := time.Now().Add(-time.Minute)
+		lookups := 0
+		storage := &MockStorageAdapter{
+			GetByTraceIDFunc: func(ctx context.Context, traceID string) ([]*Request, error) {
+				lookups++
+				return []*Request{
+					{TraceID: traceID, RequestedAt: earlier.Add(time.Second), Dimensions: []DimensionTag{{Key: DimensionUserID, Value: "bob"}}},
+					{TraceID: traceID, RequestedAt: earlier, Dimensions: []DimensionTag{{Key: DimensionUserID, Value: "alice"}, {Key: "team", Value: "search"}}},
+					{TraceID: traceID, Pending: true, Dimensions: []DimensionTag{{Key: DimensionUserID, Value: "carol"}}},
+				}, nil
+			},
+		}
+		client := NewClient(storage, WithTraceDimensionInheritance(DimensionUserID))
+
+		ctx := WithTraceID(context.Background(), "trace-2")
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+
+		require.Len(t, storage.SaveCalls, 2)
+		for _, call := range storage.SaveCalls {
+			assert.Equal(t, map[string]string{DimensionUserID: "alice"}, call.Request.DimensionsMap(), "only the configured keys are inherited")
+		}
+		assert.Equal(t, 1, lookups, "the trace is remembered after the first lookup")
+	})
+	t.Run("Storage is read in the tracking goroutine", func(t *testing.T) {
+		release := make(chan struct{})
+		storage := &MockStorageAdapter{
+			GetByTraceIDFunc: func(ctx context.Context, traceID string) ([]*Request, error) {
+				<-release
+				return []*Request{{TraceID: traceID, Dimensions: []DimensionTag{{Key: DimensionUserID, Value: "alice"}}}}, nil
+			},
+		}
+		client := NewClient(storage, WithAsyncTracking(true), WithTraceDimensionInheritance(DimensionUserID))
+
+		tracked := make(chan error, 1)
+		go func() {
+			tracked <- client.TrackRequest(WithTraceID(context.Background(), "trace-3"), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil)
+		}()
+		select {
+		case err := <-tracked:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("tracking blocked the caller on the storage read")
+		}
+
+		close(release)
+		require.NoError(t, client.Shutdown(context.Background()))
+		require.Len(t, storage.SaveCalls, 1)
+		assert.Equal(t, "alice", storage.SaveCalls[0].Request.Dimension(DimensionUserID))
+	})
+
+	t.Run("Storage reads go through the circuit breaker", func(t *testing.T) {
+		lookups := 0
+		storage := &MockStorageAdapter{
+			GetByTraceIDFunc: func(ctx context.Context, traceID string) ([]*Request, error) {
+				lookups++
+				return nil, errors.New("database is down")
+			},
+			SaveFunc: func(ctx context.Context, request *Request) error {
+				return errors.New("database is down")
+			},
+		}
+		client := NewClient(storage, WithCircuitBreaker(1, time.Hour), WithTraceDimensionInheritance(DimensionUserID))
+
+		client.TrackRequest(WithTraceID(context.Background(), "trace-4"), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil)
+		client.TrackRequest(WithTraceID(context.Background(), "trace-5"), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil)
+		assert.Equal(t, 1, lookups, "an open circuit skips the read")
+	})
+
+	t.Run("Traces generated by the middleware are not read", func(t *testing.T) {
+		lookups := 0
+		storage := &MockStorageAdapter{
+			GetByTraceIDFunc: func(ctx context.Context, traceID string) ([]*Request, error) {
+				lookups++
+				return nil, nil
+			},
+		}
+		client := NewClient(storage, WithTraceDimensionInheritance(DimensionUserID))
+
+		handler := HTTPMiddleware(WithUserIDHeader(HeaderUserID))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, client.TrackRequest(r.Context(), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+			require.NoError(t, client.TrackRequest(context.WithValue(r.Context(), userIDKey, ""), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderUserID, "alice")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Zero(t, lookups)
+		require.Len(t, storage.SaveCalls, 2)
+		assert.Equal(t, "alice", storage.SaveCalls[1].Request.Dimension(DimensionUserID), "requests of the trace still inherit in-process")
+	})
+}
@@ -0,0 +1,83 @@
+//go:build !llmtracer_no_anthropic
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/propel-gtm/llm-request-tracer/testutil"
+)
+
+func TestAnthropicAgainstFakeProvider(t *testing.T) {
+	server := testutil.NewServer(t)
+	ctx := context.Background()
+
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+	server.Enqueue(testutil.Response{InputTokens: 20, OutputTokens: 5})
+
+	_, err := client.TraceAnthropicRequest(ctx, anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaude3_5HaikuLatest,
+		MaxTokens: 64,
+	}, server.AnthropicClient().Messages.New)
+	require.NoError(t, err)
+
+	require.Len(t, storage.SaveCalls, 1)
+	saved := storage.SaveCalls[0].Request
+	assert.Equal(t, int64(20), saved.InputTokens)
+	assert.Equal(t, int64(5), saved.OutputTokens)
+	assert.NotEmpty(t, saved.ProviderRequestID)
+}
+
+func TestAnthropicOverloadedAgainstFakeProvider(t *testing.T) {
+	server := testutil.NewServer(t)
+	ctx := context.Background()
+
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+	server.Enqueue(testutil.Response{Status: 529, ErrorType: "overloaded_error"})
+
+	_, err := client.TraceAnthropicRequest(ctx, anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaude3_5HaikuLatest,
+		MaxTokens: 64,
+	}, server.AnthropicClient().Messages.New)
+	require.Error(t, err)
+
+	require.Len(t, storage.SaveCalls, 1)
+	saved := storage.SaveCalls[0].Request
+	assert.Equal(t, 529, saved.StatusCode)
+	assert.Equal(t, "overloaded_error", saved.ProviderErrorType)
+	assert.Empty(t, saved.ProviderErrorCode, "Anthropic errors have no code")
+}
+
+func TestTransportAgainstFakeProvider(t *testing.T) {
+	server := testutil.NewServer(t)
+	ctx := context.Background()
+
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+	server.Enqueue(testutil.Response{Content: "a b c", InputTokens: 9, OutputTokens: 3})
+
+	transport := NewTracingTransport(client, nil)
+	anthropicClient := server.AnthropicClient(option.WithHTTPClient(transport.HTTPClient()))
+	stream := anthropicClient.Messages.NewStreaming(ctx, anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaude3_5HaikuLatest,
+		MaxTokens: 64,
+	})
+	for stream.Next() {
+	}
+	require.NoError(t, stream.Err())
+	require.NoError(t, stream.Close())
+
+	require.Len(t, storage.SaveCalls, 1)
+	saved := storage.SaveCalls[0].Request
+	assert.Equal(t, ProviderAnthropic, saved.Provider)
+	assert.Equal(t, int64(9), saved.InputTokens)
+	assert.Equal(t, int64(3), saved.OutputTokens)
+}
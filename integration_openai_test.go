@@ -0,0 +1,69 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/propel-gtm/llm-request-tracer/testutil"
+)
+
+// TestWrappersAgainstFakeProvider runs the OpenAI tracking wrappers against the in-process
+// fake provider. The Anthropic wrappers and the HTTP transport are covered in
+// integration_anthropic_test.go
+func TestWrappersAgainstFakeProvider(t *testing.T) {
+	server := testutil.NewServer(t)
+	ctx := context.Background()
+
+	t.Run("OpenAI", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+		server.Enqueue(testutil.Response{Content: "hi", InputTokens: 12, OutputTokens: 3})
+
+		_, err := client.TraceOpenAIRequest(ctx, openai.ChatCompletionRequest{Model: "gpt-4o"}, server.OpenAIClient().CreateChatCompletion)
+		require.NoError(t, err)
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, int64(12), saved.InputTokens)
+		assert.Equal(t, int64(3), saved.OutputTokens)
+		assert.NotEmpty(t, saved.ProviderRequestID)
+	})
+
+	t.Run("OpenAI rate limit", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+		server.Enqueue(testutil.Response{Status: http.StatusTooManyRequests, ErrorType: "rate_limit_exceeded"})
+
+		_, err := client.TraceOpenAIRequest(ctx, openai.ChatCompletionRequest{Model: "gpt-4o"}, server.OpenAIClient().CreateChatCompletion)
+		require.Error(t, err)
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, http.StatusTooManyRequests, saved.StatusCode)
+		assert.Equal(t, ErrorTypeRateLimit, saved.ErrorType)
+		assert.Equal(t, "rate_limit_exceeded", saved.ProviderErrorCode)
+	})
+
+	t.Run("OpenAI stream", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+		server.Enqueue(testutil.Response{Content: "streamed reply", InputTokens: 8, OutputTokens: 2})
+
+		stream, err := client.TraceOpenAIStream(ctx, openai.ChatCompletionRequest{Model: "gpt-4o"}, server.OpenAIClient().CreateChatCompletionStream)
+		require.NoError(t, err)
+		assert.Equal(t, "streamed reply", drain(t, stream))
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, int64(8), saved.InputTokens)
+		assert.Equal(t, int64(2), saved.OutputTokens)
+		assert.Empty(t, saved.Dimension(DimensionUsageEstimated))
+	})
+}
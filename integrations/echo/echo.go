@@ -0,0 +1,22 @@
+// Package echotracer adapts llmtracer's HTTP middleware to the Echo web framework.
+package echotracer
+
+import (
+	"github.com/labstack/echo/v4"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// Middleware seeds the tracer context (trace ID, user ID, feature) of every request and
+// echoes the trace ID with llmtracer.WithRequestIDEcho, following the same rules as
+// llmtracer.HTTPMiddleware. Use c.Request().Context() when making traced LLM calls from
+// handlers.
+func Middleware(opts ...llmtracer.MiddlewareOption) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := llmtracer.SeedHTTPRequest(c.Response(), c.Request(), opts...)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
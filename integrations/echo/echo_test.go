@@ -0,0 +1,48 @@
+package echotracer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func TestMiddleware(t *testing.T) {
+	// serve runs a request through the middleware and returns the trace ID and user ID seen
+	// by the handler
+	serve := func(req *http.Request, opts ...llmtracer.MiddlewareOption) (traceID, userID string, recorder *httptest.ResponseRecorder) {
+		e := echo.New()
+		e.Use(Middleware(opts...))
+		e.GET("/", func(c echo.Context) error {
+			traceID = llmtracer.GetTraceIDFromContext(c.Request().Context())
+			userID, _ = llmtracer.GetDimensionsFromContext(c.Request().Context())["user_id"].(string)
+			return c.NoContent(http.StatusOK)
+		})
+		recorder = httptest.NewRecorder()
+		e.ServeHTTP(recorder, req)
+		return traceID, userID, recorder
+	}
+
+	t.Run("seeds the context", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", "req-123")
+		req.Header.Set("X-User-ID", "user-42")
+
+		traceID, userID, recorder := serve(req, llmtracer.WithUserIDHeader(llmtracer.HeaderUserID))
+		assert.Equal(t, "req-123", traceID)
+		assert.Equal(t, "user-42", userID)
+		assert.Empty(t, recorder.Header().Get("X-Request-ID"), "the trace ID is only echoed on request")
+	})
+
+	t.Run("echoes the trace ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		traceID, _, recorder := serve(req, llmtracer.WithRequestIDEcho(true))
+		assert.NotEmpty(t, traceID)
+		assert.Equal(t, traceID, recorder.Header().Get("X-Request-ID"))
+	})
+}
@@ -0,0 +1,20 @@
+// Package gintracer adapts llmtracer's HTTP middleware to the Gin web framework.
+package gintracer
+
+import (
+	"github.com/gin-gonic/gin"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// Middleware seeds the tracer context (trace ID, user ID, feature) of every request and
+// echoes the trace ID with llmtracer.WithRequestIDEcho, following the same rules as
+// llmtracer.HTTPMiddleware. Use c.Request.Context() when making traced LLM calls from
+// handlers.
+func Middleware(opts ...llmtracer.MiddlewareOption) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := llmtracer.SeedHTTPRequest(c.Writer, c.Request, opts...)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
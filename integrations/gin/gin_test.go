@@ -0,0 +1,49 @@
+package gintracer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// serve runs a request through the middleware and returns the trace ID and user ID seen
+	// by the handler
+	serve := func(req *http.Request, opts ...llmtracer.MiddlewareOption) (traceID, userID string, recorder *httptest.ResponseRecorder) {
+		router := gin.New()
+		router.Use(Middleware(opts...))
+		router.GET("/", func(c *gin.Context) {
+			traceID = llmtracer.GetTraceIDFromContext(c.Request.Context())
+			userID, _ = llmtracer.GetDimensionsFromContext(c.Request.Context())["user_id"].(string)
+		})
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return traceID, userID, recorder
+	}
+
+	t.Run("seeds the context", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", "req-123")
+		req.Header.Set("X-User-ID", "user-42")
+
+		traceID, userID, recorder := serve(req, llmtracer.WithUserIDHeader(llmtracer.HeaderUserID))
+		assert.Equal(t, "req-123", traceID)
+		assert.Equal(t, "user-42", userID)
+		assert.Empty(t, recorder.Header().Get("X-Request-ID"), "the trace ID is only echoed on request")
+	})
+
+	t.Run("echoes the trace ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		traceID, _, recorder := serve(req, llmtracer.WithRequestIDEcho(true))
+		assert.NotEmpty(t, traceID)
+		assert.Equal(t, traceID, recorder.Header().Get("X-Request-ID"))
+	})
+}
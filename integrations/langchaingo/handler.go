@@ -0,0 +1,179 @@
+// Package langchaintracer tracks langchaingo LLM calls through llmtracer by implementing
+// langchaingo's callbacks.Handler interface.
+package langchaintracer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// Dimension keys recorded by the handler
+const (
+	DimensionChain     = "chain"
+	DimensionAgent     = "agent"
+	DimensionAgentTool = "agent_tool"
+)
+
+// Handler converts langchaingo LLM start/end events into tracked requests. langchaingo
+// does not report which provider or model produced a response, so both are configured
+// per handler; attach one handler per LLM.
+type Handler struct {
+	callbacks.SimpleHandler
+
+	client   *llmtracer.Client
+	provider llmtracer.Provider
+	model    string
+	chain    string
+	agent    string
+
+	state *handlerState
+}
+
+// handlerState is shared between a handler and the copies made by ForChain/ForAgent
+type handlerState struct {
+	mu       sync.Mutex
+	inFlight map[context.Context]*llmCall
+	lastTool map[context.Context]string
+}
+
+// llmCall holds what was observed when a generation started
+type llmCall struct {
+	startTime    time.Time
+	messageCount int
+}
+
+var _ callbacks.Handler = (*Handler)(nil)
+
+// New creates a handler tracking calls to the given provider and model
+func New(client *llmtracer.Client, provider llmtracer.Provider, model string) *Handler {
+	if client == nil {
+		panic("client cannot be nil")
+	}
+	return &Handler{
+		client:   client,
+		provider: provider,
+		model:    model,
+		state: &handlerState{
+			inFlight: make(map[context.Context]*llmCall),
+			lastTool: make(map[context.Context]string),
+		},
+	}
+}
+
+// ForChain returns a handler that records the chain name as a dimension
+func (h *Handler) ForChain(name string) *Handler {
+	clone := *h
+	clone.chain = name
+	return &clone
+}
+
+// ForAgent returns a handler that records the agent name as a dimension, along with the
+// tool chosen by the agent's latest action
+func (h *Handler) ForAgent(name string) *Handler {
+	clone := *h
+	clone.agent = name
+	return &clone
+}
+
+// HandleLLMGenerateContentStart records the start of a generation
+func (h *Handler) HandleLLMGenerateContentStart(ctx context.Context, ms []llms.MessageContent) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	h.state.inFlight[ctx] = &llmCall{
+		startTime:    time.Now(),
+		messageCount: len(ms),
+	}
+}
+
+// HandleLLMGenerateContentEnd tracks a completed generation
+func (h *Handler) HandleLLMGenerateContentEnd(ctx context.Context, res *llms.ContentResponse) {
+	inputTokens, outputTokens := usageFromResponse(res)
+	h.finish(ctx, inputTokens, outputTokens, nil)
+}
+
+// HandleLLMError tracks a failed generation
+func (h *Handler) HandleLLMError(ctx context.Context, err error) {
+	h.finish(ctx, 0, 0, err)
+}
+
+// HandleAgentAction remembers the tool selected by the agent for the next tracked call
+func (h *Handler) HandleAgentAction(ctx context.Context, action schema.AgentAction) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	h.state.lastTool[ctx] = action.Tool
+}
+
+// HandleAgentFinish forgets the agent's tool selection
+func (h *Handler) HandleAgentFinish(ctx context.Context, finish schema.AgentFinish) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	delete(h.state.lastTool, ctx)
+}
+
+// finish pairs the end event with its start and tracks the call
+func (h *Handler) finish(ctx context.Context, inputTokens, outputTokens int64, err error) {
+	h.state.mu.Lock()
+	call := h.state.inFlight[ctx]
+	delete(h.state.inFlight, ctx)
+	tool := h.state.lastTool[ctx]
+	h.state.mu.Unlock()
+
+	opts := &llmtracer.RequestOptions{
+		Dimensions: make(map[string]interface{}),
+	}
+	var duration time.Duration
+	if call != nil {
+		duration = time.Since(call.startTime)
+		opts.MessageCount = call.messageCount
+	}
+	if h.chain != "" {
+		opts.Dimensions[DimensionChain] = h.chain
+	}
+	if h.agent != "" {
+		opts.Dimensions[DimensionAgent] = h.agent
+		if tool != "" {
+			opts.Dimensions[DimensionAgentTool] = tool
+		}
+	}
+
+	// Tracking errors are logged by the client; callbacks have no way to report them
+	_ = h.client.TrackRequest(ctx, h.provider, h.model, inputTokens, outputTokens, duration, err, opts)
+}
+
+// usageFromResponse reads token counts from the generation info of the first choice.
+// langchaingo providers use different keys: PromptTokens/CompletionTokens (OpenAI),
+// InputTokens/OutputTokens (Anthropic) and input_tokens/output_tokens (Google AI).
+func usageFromResponse(res *llms.ContentResponse) (int64, int64) {
+	if res == nil || len(res.Choices) == 0 || res.Choices[0] == nil {
+		return 0, 0
+	}
+	info := res.Choices[0].GenerationInfo
+
+	inputTokens := firstInt(info, "PromptTokens", "InputTokens", "input_tokens")
+	outputTokens := firstInt(info, "CompletionTokens", "OutputTokens", "output_tokens")
+	return inputTokens, outputTokens
+}
+
+// firstInt returns the first integer value found under the given keys
+func firstInt(info map[string]any, keys ...string) int64 {
+	for _, key := range keys {
+		switch v := info[key].(type) {
+		case int:
+			return int64(v)
+		case int32:
+			return int64(v)
+		case int64:
+			return v
+		case float64:
+			return int64(v)
+		}
+	}
+	return 0
+}
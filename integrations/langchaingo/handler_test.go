@@ -0,0 +1,117 @@
+package langchaintracer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// recordingStorage captures saved requests; the other methods are unused by the handler
+type recordingStorage struct {
+	llmtracer.StorageAdapter
+	mu    sync.Mutex
+	saved []*llmtracer.Request
+}
+
+func (s *recordingStorage) Save(ctx context.Context, request *llmtracer.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, request)
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("tracks generation with chain and agent dimensions", func(t *testing.T) {
+		storage := &recordingStorage{}
+		client := llmtracer.NewClient(storage)
+		handler := New(client, llmtracer.ProviderOpenAI, "gpt-4o").ForChain("qa").ForAgent("researcher")
+
+		ctx := llmtracer.WithUserID(context.Background(), "user-1")
+		handler.HandleAgentAction(ctx, schema.AgentAction{Tool: "search"})
+		handler.HandleLLMGenerateContentStart(ctx, []llms.MessageContent{
+			llms.TextParts(llms.ChatMessageTypeSystem, "be brief"),
+			llms.TextParts(llms.ChatMessageTypeHuman, "hello"),
+		})
+		handler.HandleLLMGenerateContentEnd(ctx, &llms.ContentResponse{
+			Choices: []*llms.ContentChoice{{
+				GenerationInfo: map[string]any{"PromptTokens": 12, "CompletionTokens": 4},
+			}},
+		})
+
+		if len(storage.saved) != 1 {
+			t.Fatalf("expected 1 tracked request, got %d", len(storage.saved))
+		}
+		request := storage.saved[0]
+		if request.Provider != llmtracer.ProviderOpenAI || request.Model != "gpt-4o" {
+			t.Errorf("unexpected provider/model: %s/%s", request.Provider, request.Model)
+		}
+		if request.InputTokens != 12 || request.OutputTokens != 4 {
+			t.Errorf("unexpected tokens: %d/%d", request.InputTokens, request.OutputTokens)
+		}
+		if request.MessageCount != 2 {
+			t.Errorf("expected 2 messages, got %d", request.MessageCount)
+		}
+		for key, want := range map[string]string{
+			DimensionChain:     "qa",
+			DimensionAgent:     "researcher",
+			DimensionAgentTool: "search",
+			"user_id":          "user-1",
+		} {
+			if got := request.Dimension(key); got != want {
+				t.Errorf("dimension %s: expected %q, got %q", key, want, got)
+			}
+		}
+	})
+
+	t.Run("tracks errors", func(t *testing.T) {
+		storage := &recordingStorage{}
+		client := llmtracer.NewClient(storage)
+		handler := New(client, llmtracer.ProviderAnthropic, "claude-3-5-sonnet-latest")
+
+		ctx := context.Background()
+		handler.HandleLLMGenerateContentStart(ctx, nil)
+		handler.HandleLLMError(ctx, errors.New("rate limit exceeded"))
+
+		if len(storage.saved) != 1 {
+			t.Fatalf("expected 1 tracked request, got %d", len(storage.saved))
+		}
+		request := storage.saved[0]
+		if request.ErrorType != llmtracer.ErrorTypeRateLimit {
+			t.Errorf("expected rate_limit error type, got %q", request.ErrorType)
+		}
+		if len(handler.state.inFlight) != 0 {
+			t.Errorf("expected in-flight calls to be cleared")
+		}
+	})
+}
+
+func TestUsageFromResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		info   map[string]any
+		input  int64
+		output int64
+	}{
+		{"openai", map[string]any{"PromptTokens": 10, "CompletionTokens": 2}, 10, 2},
+		{"anthropic", map[string]any{"InputTokens": 7, "OutputTokens": 3}, 7, 3},
+		{"googleai", map[string]any{"input_tokens": int32(5), "output_tokens": int32(1)}, 5, 1},
+		{"missing", map[string]any{}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, output := usageFromResponse(&llms.ContentResponse{
+				Choices: []*llms.ContentChoice{{GenerationInfo: tt.info}},
+			})
+			if input != tt.input || output != tt.output {
+				t.Errorf("expected %d/%d, got %d/%d", tt.input, tt.output, input, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Package zaptracer adapts a zap logger to llmtracer.Logger, for applications that log
+// with zap rather than log/slog.
+package zaptracer
+
+import (
+	"log/slog"
+
+	"go.uber.org/zap"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// NewLogger returns an llmtracer.Logger that writes to logger, converting the tracer's
+// slog attributes to zap fields
+func NewLogger(logger *zap.Logger) llmtracer.Logger {
+	return &zapLogger{logger: logger}
+}
+
+type zapLogger struct {
+	logger *zap.Logger
+}
+
+func (l *zapLogger) Error(msg string, fields ...slog.Attr) {
+	l.logger.Error(msg, Fields(fields...)...)
+}
+
+func (l *zapLogger) Warn(msg string, fields ...slog.Attr) {
+	l.logger.Warn(msg, Fields(fields...)...)
+}
+
+func (l *zapLogger) Info(msg string, fields ...slog.Attr) {
+	l.logger.Info(msg, Fields(fields...)...)
+}
+
+func (l *zapLogger) Debug(msg string, fields ...slog.Attr) {
+	l.logger.Debug(msg, Fields(fields...)...)
+}
+
+// Fields converts slog attributes to zap fields; groups become nested objects
+func Fields(attrs ...slog.Attr) []zap.Field {
+	fields := make([]zap.Field, 0, len(attrs))
+	for _, attr := range attrs {
+		value := attr.Value.Resolve()
+		switch value.Kind() {
+		case slog.KindString:
+			fields = append(fields, zap.String(attr.Key, value.String()))
+		case slog.KindInt64:
+			fields = append(fields, zap.Int64(attr.Key, value.Int64()))
+		case slog.KindUint64:
+			fields = append(fields, zap.Uint64(attr.Key, value.Uint64()))
+		case slog.KindFloat64:
+			fields = append(fields, zap.Float64(attr.Key, value.Float64()))
+		case slog.KindBool:
+			fields = append(fields, zap.Bool(attr.Key, value.Bool()))
+		case slog.KindDuration:
+			fields = append(fields, zap.Duration(attr.Key, value.Duration()))
+		case slog.KindTime:
+			fields = append(fields, zap.Time(attr.Key, value.Time()))
+		case slog.KindGroup:
+			fields = append(fields, zap.Dict(attr.Key, Fields(value.Group()...)...))
+		default:
+			if err, ok := value.Any().(error); ok && attr.Key == "error" {
+				fields = append(fields, zap.Error(err))
+				continue
+			}
+			fields = append(fields, zap.Any(attr.Key, value.Any()))
+		}
+	}
+	return fields
+}
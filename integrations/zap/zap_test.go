@@ -0,0 +1,46 @@
+package zaptracer
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := NewLogger(zap.New(core))
+
+	logger.Error("Failed to track request",
+		slog.Any("error", errors.New("database unavailable")),
+		slog.String("provider", "openai"),
+		slog.Int("input_tokens", 12),
+		slog.Group("usage", slog.Float64("cost", 0.5)),
+	)
+	logger.Debug("Purged deleted requests", slog.Int64("count", 3))
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Level != zapcore.ErrorLevel || entry.Message != "Failed to track request" {
+		t.Errorf("Unexpected entry: %v %q", entry.Level, entry.Message)
+	}
+	fields := entry.ContextMap()
+	if fields["error"] != "database unavailable" {
+		t.Errorf("Expected error field, got %v", fields["error"])
+	}
+	if fields["provider"] != "openai" || fields["input_tokens"] != int64(12) {
+		t.Errorf("Unexpected fields: %v", fields)
+	}
+	if usage, ok := fields["usage"].(map[string]interface{}); !ok || usage["cost"] != 0.5 {
+		t.Errorf("Expected nested usage group, got %v", fields["usage"])
+	}
+	if entries[1].Level != zapcore.DebugLevel {
+		t.Errorf("Expected debug level, got %v", entries[1].Level)
+	}
+}
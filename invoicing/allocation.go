@@ -0,0 +1,206 @@
+package invoicing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// DefaultUnallocated is the cost center of usage that no allocation rule matches
+const DefaultUnallocated = "unallocated"
+
+// AllocationRule assigns usage to cost centers. Usage matches when every dimension in
+// Match has the given value. Matched usage is split across the cost centers of Split in
+// proportion to their weights, e.g. {"eng": 60, "sales": 40}.
+type AllocationRule struct {
+	Match map[string]string  `json:"match"`
+	Split map[string]float64 `json:"split"`
+}
+
+// Allocation is an ordered set of rules; the first rule that matches a group of usage
+// allocates it
+type Allocation struct {
+	Rules []AllocationRule `json:"rules"`
+	// Unallocated is the cost center of unmatched usage; defaults to DefaultUnallocated
+	Unallocated string `json:"unallocated,omitempty"`
+}
+
+// Validate checks that every rule matches something and has positive weights
+func (a *Allocation) Validate() error {
+	for i, rule := range a.Rules {
+		if len(rule.Match) == 0 {
+			return fmt.Errorf("rule %d: match cannot be empty", i)
+		}
+		if len(rule.Split) == 0 {
+			return fmt.Errorf("rule %d: split cannot be empty", i)
+		}
+		for costCenter, weight := range rule.Split {
+			if weight <= 0 {
+				return fmt.Errorf("rule %d: weight of %q must be positive", i, costCenter)
+			}
+		}
+	}
+	return nil
+}
+
+// dimensionKeys returns the dimension keys referenced by the rules, sorted
+func (a *Allocation) dimensionKeys() []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, rule := range a.Rules {
+		for key := range rule.Match {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AllocatedUsage is the share of one aggregate group assigned to a cost center. Requests
+// and tokens are fractional when usage is split.
+type AllocatedUsage struct {
+	Dimensions   map[string]string `json:"dimensions"`
+	Share        float64           `json:"share"`
+	Requests     float64           `json:"requests"`
+	InputTokens  float64           `json:"input_tokens"`
+	OutputTokens float64           `json:"output_tokens"`
+	Cost         float64           `json:"cost"`
+}
+
+// CostCenterReport is the usage allocated to one cost center
+type CostCenterReport struct {
+	CostCenter   string            `json:"cost_center"`
+	Requests     float64           `json:"requests"`
+	InputTokens  float64           `json:"input_tokens"`
+	OutputTokens float64           `json:"output_tokens"`
+	Cost         float64           `json:"cost"`
+	Sources      []*AllocatedUsage `json:"sources"`
+}
+
+// AllocationReport is the allocated usage of every cost center, sorted by name
+type AllocationReport struct {
+	CostCenters []*CostCenterReport `json:"cost_centers"`
+	TotalCost   float64             `json:"total_cost"`
+}
+
+// CostCenter returns the report of the given cost center
+func (r *AllocationReport) CostCenter(name string) (*CostCenterReport, bool) {
+	for _, report := range r.CostCenters {
+		if report.CostCenter == name {
+			return report, true
+		}
+	}
+	return nil, false
+}
+
+// Apply allocates aggregate results grouped by (at least) the dimensions the rules
+// match on
+func (a *Allocation) Apply(results []*llmtracer.AggregateResult) (*AllocationReport, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	unallocated := a.Unallocated
+	if unallocated == "" {
+		unallocated = DefaultUnallocated
+	}
+
+	report := &AllocationReport{CostCenters: []*CostCenterReport{}}
+	costCenters := make(map[string]*CostCenterReport)
+	allocate := func(costCenter string, share float64, dimensions map[string]string, result *llmtracer.AggregateResult) {
+		center, ok := costCenters[costCenter]
+		if !ok {
+			center = &CostCenterReport{CostCenter: costCenter}
+			costCenters[costCenter] = center
+			report.CostCenters = append(report.CostCenters, center)
+		}
+		usage := &AllocatedUsage{
+			Dimensions:   dimensions,
+			Share:        share,
+			Requests:     float64(result.TotalRequests) * share,
+			InputTokens:  float64(result.TotalInputTokens) * share,
+			OutputTokens: float64(result.TotalOutputTokens) * share,
+			Cost:         result.TotalCost * share,
+		}
+		center.Sources = append(center.Sources, usage)
+		center.Requests += usage.Requests
+		center.InputTokens += usage.InputTokens
+		center.OutputTokens += usage.OutputTokens
+		center.Cost += usage.Cost
+	}
+
+	for _, result := range results {
+		report.TotalCost += result.TotalCost
+		dimensions := result.DimensionsMap()
+
+		rule := a.match(dimensions)
+		if rule == nil {
+			allocate(unallocated, 1, dimensions, result)
+			continue
+		}
+
+		var total float64
+		for _, weight := range rule.Split {
+			total += weight
+		}
+		// Allocate in a stable order so reports are reproducible
+		names := make([]string, 0, len(rule.Split))
+		for costCenter := range rule.Split {
+			names = append(names, costCenter)
+		}
+		sort.Strings(names)
+		for _, costCenter := range names {
+			allocate(costCenter, rule.Split[costCenter]/total, dimensions, result)
+		}
+	}
+
+	sort.Slice(report.CostCenters, func(i, j int) bool {
+		return report.CostCenters[i].CostCenter < report.CostCenters[j].CostCenter
+	})
+	return report, nil
+}
+
+// match returns the first rule matching the dimension values
+func (a *Allocation) match(dimensions map[string]string) *AllocationRule {
+	for i, rule := range a.Rules {
+		matched := true
+		for key, value := range rule.Match {
+			if dimensions[key] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return &a.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Allocate aggregates the requests matching filter by the dimensions the rules match on
+// and allocates them to cost centers
+func Allocate(ctx context.Context, storage llmtracer.StorageAdapter, filter *llmtracer.RequestFilter, allocation Allocation) (*AllocationReport, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("storage adapter cannot be nil")
+	}
+	if err := allocation.Validate(); err != nil {
+		return nil, err
+	}
+
+	var groupBy []string
+	for _, key := range allocation.dimensionKeys() {
+		groupBy = append(groupBy, llmtracer.GroupByDimension(key))
+	}
+	if filter == nil {
+		filter = &llmtracer.RequestFilter{}
+	}
+	results, err := aggregate(ctx, storage, groupBy, filter)
+	if err != nil {
+		return nil, err
+	}
+	return allocation.Apply(results)
+}
@@ -0,0 +1,114 @@
+package invoicing
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestAllocationApply(t *testing.T) {
+	allocation := Allocation{
+		Rules: []AllocationRule{
+			{Match: map[string]string{"feature": "platform"}, Split: map[string]float64{"cc-100": 60, "cc-200": 40}},
+			{Match: map[string]string{"feature": "search"}, Split: map[string]float64{"cc-100": 1}},
+			{Match: map[string]string{"feature": "chat", "team": "support"}, Split: map[string]float64{"cc-300": 1}},
+		},
+		Unallocated: "shared",
+	}
+	group := func(feature, team string, requests int64, cost float64) *llmtracer.AggregateResult {
+		return &llmtracer.AggregateResult{
+			TotalRequests:    requests,
+			TotalInputTokens: requests * 100,
+			TotalCost:        cost,
+			Dimensions: []llmtracer.DimensionTag{
+				{Key: "feature", Value: feature},
+				{Key: "team", Value: team},
+			},
+		}
+	}
+
+	report, err := allocation.Apply([]*llmtracer.AggregateResult{
+		group("platform", "", 10, 100),
+		group("search", "web", 4, 20),
+		group("chat", "support", 2, 5),
+		group("chat", "sales", 1, 3),
+	})
+	if err != nil {
+		t.Fatalf("Failed to apply allocation: %v", err)
+	}
+
+	if !approxEqual(report.TotalCost, 128) {
+		t.Errorf("Expected a total cost of 128, got %v", report.TotalCost)
+	}
+	want := map[string]float64{"cc-100": 80, "cc-200": 40, "cc-300": 5, "shared": 3}
+	if len(report.CostCenters) != len(want) {
+		t.Fatalf("Expected %d cost centers, got %d", len(want), len(report.CostCenters))
+	}
+	for name, cost := range want {
+		center, ok := report.CostCenter(name)
+		if !ok || !approxEqual(center.Cost, cost) {
+			t.Errorf("Expected %s to be allocated %v, got %+v", name, cost, center)
+		}
+	}
+
+	cc100, _ := report.CostCenter("cc-100")
+	if !approxEqual(cc100.Requests, 10) || !approxEqual(cc100.InputTokens, 1000) || len(cc100.Sources) != 2 {
+		t.Errorf("Unexpected cc-100 allocation: %+v", cc100)
+	}
+	if cc100.Sources[0].Dimensions["feature"] != "platform" || !approxEqual(cc100.Sources[0].Share, 0.6) {
+		t.Errorf("Unexpected cc-100 source: %+v", cc100.Sources[0])
+	}
+	if report.CostCenters[0].CostCenter != "cc-100" {
+		t.Errorf("Expected cost centers sorted by name, got %s first", report.CostCenters[0].CostCenter)
+	}
+}
+
+func TestAllocationValidate(t *testing.T) {
+	invalid := []Allocation{
+		{Rules: []AllocationRule{{Split: map[string]float64{"a": 1}}}},
+		{Rules: []AllocationRule{{Match: map[string]string{"feature": "x"}}}},
+		{Rules: []AllocationRule{{Match: map[string]string{"feature": "x"}, Split: map[string]float64{"a": 0}}}},
+	}
+	for i, allocation := range invalid {
+		if _, err := allocation.Apply(nil); err == nil {
+			t.Errorf("Expected allocation %d to be invalid", i)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	storage := newStorage(t)
+	start := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.June, 30, 23, 59, 59, 0, time.UTC)
+	filter := &llmtracer.RequestFilter{StartTime: &start, EndTime: &end}
+	allocation := Allocation{Rules: []AllocationRule{
+		{Match: map[string]string{"org_id": "acme"}, Split: map[string]float64{"eng": 3, "sales": 1}},
+		{Match: map[string]string{"org_id": "globex"}, Split: map[string]float64{"sales": 1}},
+	}}
+
+	for name, adapter := range map[string]llmtracer.StorageAdapter{
+		"Aggregate": storage,
+		"Query":     queryOnlyStorage{storage},
+	} {
+		t.Run(name, func(t *testing.T) {
+			report, err := Allocate(context.Background(), adapter, filter, allocation)
+			if err != nil {
+				t.Fatalf("Failed to allocate: %v", err)
+			}
+			want := map[string]float64{"eng": 0.57, "sales": 0.19 + 1, DefaultUnallocated: 2}
+			for name, cost := range want {
+				center, ok := report.CostCenter(name)
+				if !ok || !approxEqual(center.Cost, cost) {
+					t.Errorf("Expected %s to be allocated %v, got %+v", name, cost, center)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+// Package invoicing turns tracked usage into monthly statements for internal chargeback:
+// one account per value of a dimension such as org_id, user_id or feature, with a line
+// item per provider model. Statements marshal to JSON and can be written as CSV.
+// Allocation rules split usage across cost centers on top of the same aggregates, and
+// Reconcile compares tracked usage with the usage exports of providers.
+//
+//	statement, err := invoicing.GenerateMonthly(ctx, storage, 2024, time.June, "org_id")
+//	statement.WriteCSV(os.Stdout)
+package invoicing
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// Totals are the usage and cost of a statement, account or line item
+type Totals struct {
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	TotalTokens  int64   `json:"total_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+func (t *Totals) add(other Totals) {
+	t.Requests += other.Requests
+	t.InputTokens = llmtracer.AddTokens(t.InputTokens, other.InputTokens)
+	t.OutputTokens = llmtracer.AddTokens(t.OutputTokens, other.OutputTokens)
+	t.TotalTokens = llmtracer.AddTokens(t.TotalTokens, other.TotalTokens)
+	t.Cost += other.Cost
+}
+
+// LineItem is the usage of one provider model by an account
+type LineItem struct {
+	Provider llmtracer.Provider `json:"provider"`
+	Model    string             `json:"model"`
+	Totals
+}
+
+// Account is the usage of one dimension value. Requests without the dimension are billed
+// to an account with an empty key.
+type Account struct {
+	Key       string      `json:"key"`
+	LineItems []*LineItem `json:"line_items"`
+	Totals
+}
+
+// Statement is the usage of every account over one month
+type Statement struct {
+	// GroupBy is the dimension key accounts are keyed by
+	GroupBy     string     `json:"group_by"`
+	Currency    string     `json:"currency"`
+	PeriodStart time.Time  `json:"period_start"`
+	PeriodEnd   time.Time  `json:"period_end"`
+	GeneratedAt time.Time  `json:"generated_at"`
+	Accounts    []*Account `json:"accounts"`
+	Totals
+}
+
+// Account returns the account with the given key
+func (s *Statement) Account(key string) (*Account, bool) {
+	for _, account := range s.Accounts {
+		if account.Key == key {
+			return account, true
+		}
+	}
+	return nil, false
+}
+
+// csvHeader is the header row written by WriteCSV
+var csvHeader = []string{
+	"period_start", "account", "provider", "model",
+	"requests", "input_tokens", "output_tokens", "total_tokens", "cost", "currency",
+}
+
+// WriteCSV writes one row per line item, so the statement can be loaded into a
+// spreadsheet or billing system
+func (s *Statement) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	period := s.PeriodStart.Format("2006-01")
+	for _, account := range s.Accounts {
+		for _, item := range account.LineItems {
+			row := []string{
+				period, account.Key, string(item.Provider), item.Model,
+				strconv.FormatInt(item.Requests, 10),
+				strconv.FormatInt(item.InputTokens, 10),
+				strconv.FormatInt(item.OutputTokens, 10),
+				strconv.FormatInt(item.TotalTokens, 10),
+				strconv.FormatFloat(item.Cost, 'f', 6, 64),
+				s.Currency,
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Option configures statement generation
+type Option func(*options)
+
+type options struct {
+	location *time.Location
+	filter   llmtracer.RequestFilter
+	now      func() time.Time
+}
+
+// WithLocation sets the time zone of month boundaries (UTC by default)
+func WithLocation(location *time.Location) Option {
+	return func(o *options) {
+		o.location = location
+	}
+}
+
+// WithFilter restricts the statement to matching requests, e.g. one provider. Time
+// bounds of the filter are replaced by the month.
+func WithFilter(filter llmtracer.RequestFilter) Option {
+	return func(o *options) {
+		o.filter = filter
+	}
+}
+
+// GenerateMonthly builds the statement of the given month with one account per value of
+// the groupBy dimension key (e.g. "org_id"). Costs are those recorded on the requests,
+// in USD. The aggregation is pushed down to the storage adapter; adapters that return
+// llmtracer.ErrAggregateNotSupported are aggregated in memory instead.
+func GenerateMonthly(ctx context.Context, storage llmtracer.StorageAdapter, year int, month time.Month, groupBy string, opts ...Option) (*Statement, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("storage adapter cannot be nil")
+	}
+	if groupBy == "" {
+		return nil, fmt.Errorf("groupBy dimension key cannot be empty")
+	}
+
+	o := &options{location: time.UTC, now: time.Now}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+
+	start := time.Date(year, month, 1, 0, 0, 0, 0, o.location)
+	end := start.AddDate(0, 1, 0)
+	// Filter time bounds are inclusive, and UTC so databases that compare timestamps as
+	// text see consistent offsets
+	first := start.UTC()
+	last := end.Add(-time.Nanosecond).UTC()
+	filter := o.filter
+	filter.StartTime = &first
+	filter.EndTime = &last
+	filter.Limit, filter.Offset = 0, 0
+
+	items, err := lineItems(ctx, storage, groupBy, &filter)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{
+		GroupBy:     groupBy,
+		Currency:    "USD",
+		PeriodStart: start,
+		PeriodEnd:   end,
+		GeneratedAt: o.now(),
+		Accounts:    []*Account{},
+	}
+	for key, keyItems := range items {
+		account := &Account{Key: key, LineItems: keyItems}
+		for _, item := range keyItems {
+			account.add(item.Totals)
+		}
+		sort.Slice(account.LineItems, func(i, j int) bool {
+			a, b := account.LineItems[i], account.LineItems[j]
+			if a.Provider != b.Provider {
+				return a.Provider < b.Provider
+			}
+			return a.Model < b.Model
+		})
+		statement.Accounts = append(statement.Accounts, account)
+		statement.add(account.Totals)
+	}
+	sort.Slice(statement.Accounts, func(i, j int) bool {
+		return statement.Accounts[i].Key < statement.Accounts[j].Key
+	})
+	return statement, nil
+}
+
+// lineItems returns the line items of each account key
+func lineItems(ctx context.Context, storage llmtracer.StorageAdapter, groupBy string, filter *llmtracer.RequestFilter) (map[string][]*LineItem, error) {
+	results, err := aggregate(ctx, storage, []string{"provider", "model", llmtracer.GroupByDimension(groupBy)}, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[string][]*LineItem)
+	for _, result := range results {
+		key := result.Dimension(groupBy)
+		items[key] = append(items[key], &LineItem{
+			Provider: result.Provider,
+			Model:    result.Model,
+			Totals:   totalsOf(result),
+		})
+	}
+	return items, nil
+}
+
+// aggregate runs storage.Aggregate, grouping Query results in memory for adapters that
+// cannot aggregate natively. Only provider, model and dimension groups
+// are supported in memory.
+func aggregate(ctx context.Context, storage llmtracer.StorageAdapter, groupBy []string, filter *llmtracer.RequestFilter) ([]*llmtracer.AggregateResult, error) {
+	if storage.Capabilities().Aggregate {
+		results, err := storage.Aggregate(ctx, groupBy, filter)
+		if err == nil {
+			return results, nil
+		}
+		if !errors.Is(err, llmtracer.ErrAggregateNotSupported) {
+			return nil, fmt.Errorf("failed to aggregate usage: %w", err)
+		}
+	}
+
+	requests, err := storage.Query(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage: %w", err)
+	}
+	var results []*llmtracer.AggregateResult
+	groups := make(map[string]*llmtracer.AggregateResult)
+	for _, req := range requests {
+		group := &llmtracer.AggregateResult{Dimensions: []llmtracer.DimensionTag{}}
+		for _, field := range groupBy {
+			if key, ok := llmtracer.DimensionGroupKey(field); ok {
+				group.Dimensions = append(group.Dimensions, llmtracer.DimensionTag{Key: key, Value: req.Dimension(key)})
+				continue
+			}
+			switch field {
+			case "provider":
+				group.Provider = req.Provider
+			case "model":
+				group.Model = req.Model
+			}
+		}
+
+		groupKey := fmt.Sprintf("%s\x00%s\x00%v", group.Provider, group.Model, group.Dimensions)
+		result, ok := groups[groupKey]
+		if !ok {
+			result = group
+			groups[groupKey] = result
+			results = append(results, result)
+		}
+		result.TotalRequests++
+		result.TotalInputTokens = llmtracer.AddTokens(result.TotalInputTokens, req.InputTokens)
+		result.TotalOutputTokens = llmtracer.AddTokens(result.TotalOutputTokens, req.OutputTokens)
+		result.TotalTokens = llmtracer.AddTokens(result.TotalTokens, req.InputTokens, req.OutputTokens)
+		result.TotalCost += req.Cost
+	}
+	for _, result := range results {
+		result.ComputeEfficiency()
+	}
+	return results, nil
+}
+
+func totalsOf(result *llmtracer.AggregateResult) Totals {
+	return Totals{
+		Requests:     result.TotalRequests,
+		InputTokens:  result.TotalInputTokens,
+		OutputTokens: result.TotalOutputTokens,
+		TotalTokens:  result.TotalTokens,
+		Cost:         result.TotalCost,
+	}
+}
@@ -0,0 +1,159 @@
+package invoicing
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+	"github.com/propel-gtm/llm-request-tracer/adapters"
+)
+
+// queryOnlyStorage makes the in-memory aggregation path testable
+type queryOnlyStorage struct {
+	*adapters.GormAdapter
+}
+
+func (s queryOnlyStorage) Aggregate(ctx context.Context, groupBy []string, filter *llmtracer.RequestFilter) ([]*llmtracer.AggregateResult, error) {
+	return nil, llmtracer.ErrAggregateNotSupported
+}
+
+func newStorage(t *testing.T) *adapters.GormAdapter {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	storage, err := adapters.NewGormAdapter(db)
+	if err != nil {
+		t.Fatalf("Failed to create adapter: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	june := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+	requests := []struct {
+		org   string
+		model string
+		at    time.Time
+		cost  float64
+	}{
+		{"acme", "gpt-4o", june, 0.5},
+		{"acme", "gpt-4o", june.Add(time.Hour), 0.25},
+		{"acme", "gpt-4o-mini", june, 0.01},
+		{"globex", "gpt-4o", june, 1},
+		{"", "gpt-4o", june, 2},
+		{"acme", "gpt-4o", time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC), 100},
+		{"acme", "gpt-4o", time.Date(2024, time.May, 31, 23, 59, 0, 0, time.UTC), 100},
+	}
+	for i, r := range requests {
+		request := &llmtracer.Request{
+			ID:           fmt.Sprintf("req-%d", i),
+			Provider:     llmtracer.ProviderOpenAI,
+			Model:        r.model,
+			InputTokens:  100,
+			OutputTokens: 10,
+			TotalTokens:  110,
+			Cost:         r.cost,
+			RequestedAt:  r.at,
+			RespondedAt:  r.at,
+		}
+		if r.org != "" {
+			request.Dimensions = []llmtracer.DimensionTag{{Key: "org_id", Value: r.org}}
+		}
+		if err := storage.Save(context.Background(), request); err != nil {
+			t.Fatalf("Failed to save request: %v", err)
+		}
+	}
+	return storage
+}
+
+func TestGenerateMonthly(t *testing.T) {
+	ctx := context.Background()
+	storage := newStorage(t)
+
+	for name, adapter := range map[string]llmtracer.StorageAdapter{
+		"Aggregate": storage,
+		"Query":     queryOnlyStorage{storage},
+	} {
+		t.Run(name, func(t *testing.T) {
+			statement, err := GenerateMonthly(ctx, adapter, 2024, time.June, "org_id")
+			if err != nil {
+				t.Fatalf("Failed to generate statement: %v", err)
+			}
+
+			if statement.Requests != 5 || statement.TotalTokens != 550 {
+				t.Errorf("Expected 5 requests and 550 tokens, got %+v", statement.Totals)
+			}
+			if len(statement.Accounts) != 3 || statement.Accounts[0].Key != "" {
+				t.Fatalf("Expected 3 accounts with the unattributed one first, got %d", len(statement.Accounts))
+			}
+
+			acme, ok := statement.Account("acme")
+			if !ok {
+				t.Fatal("Expected an acme account")
+			}
+			if acme.Requests != 3 || acme.Cost < 0.7599 || acme.Cost > 0.7601 {
+				t.Errorf("Unexpected acme totals: %+v", acme.Totals)
+			}
+			if len(acme.LineItems) != 2 || acme.LineItems[0].Model != "gpt-4o" || acme.LineItems[0].Requests != 2 {
+				t.Errorf("Unexpected acme line items: %+v", acme.LineItems)
+			}
+		})
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		statement, err := GenerateMonthly(ctx, storage, 2024, time.June, "org_id")
+		if err != nil {
+			t.Fatalf("Failed to generate statement: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := statement.WriteCSV(&buf); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+		rows, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to read CSV: %v", err)
+		}
+		if len(rows) != 5 {
+			t.Fatalf("Expected a header and 4 line items, got %d rows", len(rows))
+		}
+		if rows[0][1] != "account" || rows[2][0] != "2024-06" || rows[2][1] != "acme" || rows[2][8] != "0.750000" {
+			t.Errorf("Unexpected rows: %v", rows)
+		}
+	})
+
+	t.Run("Filter and location", func(t *testing.T) {
+		location := time.FixedZone("UTC-2", -2*60*60)
+		statement, err := GenerateMonthly(ctx, storage, 2024, time.June, "org_id",
+			WithLocation(location),
+			WithFilter(llmtracer.RequestFilter{Model: "gpt-4o"}))
+		if err != nil {
+			t.Fatalf("Failed to generate statement: %v", err)
+		}
+		// The July 1st 00:00 UTC request falls in June at UTC-2
+		if statement.Requests != 5 {
+			t.Errorf("Expected 5 gpt-4o requests, got %d", statement.Requests)
+		}
+	})
+
+	t.Run("Invalid arguments", func(t *testing.T) {
+		if _, err := GenerateMonthly(ctx, nil, 2024, time.June, "org_id"); err == nil {
+			t.Error("Expected an error without storage")
+		}
+		if _, err := GenerateMonthly(ctx, storage, 2024, time.June, ""); err == nil {
+			t.Error("Expected an error without a groupBy key")
+		}
+	})
+}
@@ -0,0 +1,408 @@
+package invoicing
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// ProviderUsage is the usage of one model on one UTC day as reported by a provider
+type ProviderUsage struct {
+	Date         time.Time          `json:"date"`
+	Provider     llmtracer.Provider `json:"provider"`
+	Model        string             `json:"model"`
+	Requests     int64              `json:"requests"`
+	InputTokens  int64              `json:"input_tokens"`
+	OutputTokens int64              `json:"output_tokens"`
+	Cost         float64            `json:"cost"`
+}
+
+// CSVColumns maps the columns of a usage export. Each field lists candidate header names,
+// matched case-insensitively. For Date, Model, Requests and Cost the first column present
+// is used; token columns that are present are summed, so exports that split input tokens
+// by cache usage are counted in full. Only Date and Model are required.
+type CSVColumns struct {
+	Date         []string
+	Model        []string
+	Requests     []string
+	InputTokens  []string
+	OutputTokens []string
+	Cost         []string
+}
+
+// OpenAIColumns matches OpenAI usage exports
+var OpenAIColumns = CSVColumns{
+	Date:         []string{"date", "start_time", "start_time_iso", "timestamp"},
+	Model:        []string{"model", "snapshot_id"},
+	Requests:     []string{"num_model_requests", "n_requests"},
+	InputTokens:  []string{"input_tokens", "n_context_tokens_total"},
+	OutputTokens: []string{"output_tokens", "n_generated_tokens_total"},
+	Cost:         []string{"cost", "cost_usd", "amount_value"},
+}
+
+// AnthropicColumns matches Anthropic Console usage exports
+var AnthropicColumns = CSVColumns{
+	Date:     []string{"usage_date_utc", "date", "starting_at"},
+	Model:    []string{"model_version", "model"},
+	Requests: []string{"requests", "num_requests"},
+	InputTokens: []string{
+		"input_tokens", "input_tokens_no_cache", "input_tokens_cache_write_5m",
+		"input_tokens_cache_write_1h", "input_tokens_cache_write", "input_tokens_cache_read",
+	},
+	OutputTokens: []string{"output_tokens"},
+	Cost:         []string{"cost_usd", "cost", "amount"},
+}
+
+// ImportOpenAIUsage reads an OpenAI usage export
+func ImportOpenAIUsage(r io.Reader) ([]*ProviderUsage, error) {
+	return ImportUsageCSV(r, llmtracer.ProviderOpenAI, OpenAIColumns)
+}
+
+// ImportAnthropicUsage reads an Anthropic usage export
+func ImportAnthropicUsage(r io.Reader) ([]*ProviderUsage, error) {
+	return ImportUsageCSV(r, llmtracer.ProviderAnthropic, AnthropicColumns)
+}
+
+// ImportUsageCSV reads a provider usage export and sums its rows per UTC day and model.
+// Dates may be RFC 3339 timestamps, "2006-01-02" dates or Unix seconds.
+func ImportUsageCSV(r io.Reader, provider llmtracer.Provider, columns CSVColumns) ([]*ProviderUsage, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	first := func(candidates []string) int {
+		for _, name := range candidates {
+			if i, ok := index[strings.ToLower(name)]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	all := func(candidates []string) []int {
+		var found []int
+		for _, name := range candidates {
+			if i, ok := index[strings.ToLower(name)]; ok {
+				found = append(found, i)
+			}
+		}
+		return found
+	}
+
+	dateCol, modelCol := first(columns.Date), first(columns.Model)
+	if dateCol < 0 || modelCol < 0 {
+		return nil, fmt.Errorf("usage export must have date and model columns, got %v", header)
+	}
+	requestsCol, costCol := first(columns.Requests), first(columns.Cost)
+	inputCols, outputCols := all(columns.InputTokens), all(columns.OutputTokens)
+
+	var usage []*ProviderUsage
+	byKey := make(map[string]*ProviderUsage)
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		date, err := parseUsageDate(field(dateCol))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		model := field(modelCol)
+
+		row := ProviderUsage{Date: date, Provider: provider, Model: model}
+		if row.Requests, err = parseCount(field(requestsCol)); err != nil {
+			return nil, fmt.Errorf("line %d: invalid requests: %w", line, err)
+		}
+		for _, i := range inputCols {
+			tokens, err := parseCount(field(i))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s: %w", line, header[i], err)
+			}
+			row.InputTokens += tokens
+		}
+		for _, i := range outputCols {
+			tokens, err := parseCount(field(i))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s: %w", line, header[i], err)
+			}
+			row.OutputTokens += tokens
+		}
+		if cost := field(costCol); cost != "" {
+			if row.Cost, err = strconv.ParseFloat(strings.TrimPrefix(cost, "$"), 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid cost: %w", line, err)
+			}
+		}
+
+		key := date.Format("2006-01-02") + "/" + model
+		existing, ok := byKey[key]
+		if !ok {
+			existing = &ProviderUsage{Date: date, Provider: provider, Model: model}
+			byKey[key] = existing
+			usage = append(usage, existing)
+		}
+		existing.Requests += row.Requests
+		existing.InputTokens += row.InputTokens
+		existing.OutputTokens += row.OutputTokens
+		existing.Cost += row.Cost
+	}
+	return usage, nil
+}
+
+// parseUsageDate returns the UTC day of an export timestamp
+func parseUsageDate(value string) (time.Time, error) {
+	var t time.Time
+	var err error
+	if seconds, parseErr := strconv.ParseInt(value, 10, 64); parseErr == nil {
+		t = time.Unix(seconds, 0)
+	} else if t, err = time.Parse(time.RFC3339, value); err != nil {
+		if t, err = time.Parse("2006-01-02 15:04:05", value); err != nil {
+			if t, err = time.Parse("2006-01-02", value); err != nil {
+				return time.Time{}, fmt.Errorf("invalid date %q", value)
+			}
+		}
+	}
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// parseCount parses a token or request count; empty values are zero
+func parseCount(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	value = strings.ReplaceAll(value, ",", "")
+	if count, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return count, nil
+	}
+	// Some exports write counts as floats, e.g. "1200.0"
+	count, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Round(count)), nil
+}
+
+// ReconciliationRow compares provider-reported and tracked usage of one model on one day.
+// Gaps are provider minus tracked: positive gaps are usage the tracer missed, e.g. from
+// code paths that bypass it.
+type ReconciliationRow struct {
+	Date     time.Time          `json:"date"`
+	Provider llmtracer.Provider `json:"provider"`
+	Model    string             `json:"model"`
+
+	ProviderRequests     int64   `json:"provider_requests"`
+	ProviderInputTokens  int64   `json:"provider_input_tokens"`
+	ProviderOutputTokens int64   `json:"provider_output_tokens"`
+	ProviderCost         float64 `json:"provider_cost"`
+
+	TrackedRequests     int64   `json:"tracked_requests"`
+	TrackedInputTokens  int64   `json:"tracked_input_tokens"`
+	TrackedOutputTokens int64   `json:"tracked_output_tokens"`
+	TrackedCost         float64 `json:"tracked_cost"`
+
+	InputTokenGap  int64   `json:"input_token_gap"`
+	OutputTokenGap int64   `json:"output_token_gap"`
+	CostGap        float64 `json:"cost_gap"`
+	// Coverage is the fraction of provider-reported tokens that were tracked
+	Coverage float64 `json:"coverage"`
+	// Flagged is set when the token gap exceeds the tolerance in either direction
+	Flagged bool `json:"flagged"`
+}
+
+// ReconciliationReport is the comparison of every day and model, ordered by date, provider
+// and model
+type ReconciliationReport struct {
+	Rows []*ReconciliationRow `json:"rows"`
+	// Flagged are the rows whose gap exceeds the tolerance
+	Flagged []*ReconciliationRow `json:"flagged"`
+}
+
+// ReconcileOption configures Reconcile
+type ReconcileOption func(*reconcileOptions)
+
+type reconcileOptions struct {
+	tolerance float64
+	modelName func(provider llmtracer.Provider, model string) string
+}
+
+// WithTolerance sets the relative token gap above which a row is flagged (0.02 by default)
+func WithTolerance(tolerance float64) ReconcileOption {
+	return func(o *reconcileOptions) {
+		o.tolerance = tolerance
+	}
+}
+
+// WithModelName normalizes model names on both sides before they are compared, e.g. to
+// map dated snapshots such as "gpt-4o-2024-08-06" to the alias the application requests
+func WithModelName(modelName func(provider llmtracer.Provider, model string) string) ReconcileOption {
+	return func(o *reconcileOptions) {
+		o.modelName = modelName
+	}
+}
+
+// Reconcile compares provider-reported usage with the tracked requests of the same
+// providers, days and models. Tracked requests are loaded for the days covered by usage.
+func Reconcile(ctx context.Context, storage llmtracer.StorageAdapter, usage []*ProviderUsage, opts ...ReconcileOption) (*ReconciliationReport, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("storage adapter cannot be nil")
+	}
+	o := &reconcileOptions{
+		tolerance: 0.02,
+		modelName: func(provider llmtracer.Provider, model string) string { return model },
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+
+	report := &ReconciliationReport{Rows: []*ReconciliationRow{}, Flagged: []*ReconciliationRow{}}
+	if len(usage) == 0 {
+		return report, nil
+	}
+
+	rows := make(map[string]*ReconciliationRow)
+	row := func(date time.Time, provider llmtracer.Provider, model string) *ReconciliationRow {
+		model = o.modelName(provider, model)
+		key := date.Format("2006-01-02") + "/" + string(provider) + "/" + model
+		r, ok := rows[key]
+		if !ok {
+			r = &ReconciliationRow{Date: date, Provider: provider, Model: model}
+			rows[key] = r
+			report.Rows = append(report.Rows, r)
+		}
+		return r
+	}
+
+	providers := make(map[llmtracer.Provider]bool)
+	var start, end time.Time
+	for _, u := range usage {
+		r := row(u.Date, u.Provider, u.Model)
+		r.ProviderRequests += u.Requests
+		r.ProviderInputTokens += u.InputTokens
+		r.ProviderOutputTokens += u.OutputTokens
+		r.ProviderCost += u.Cost
+
+		providers[u.Provider] = true
+		if start.IsZero() || u.Date.Before(start) {
+			start = u.Date
+		}
+		if u.Date.After(end) {
+			end = u.Date
+		}
+	}
+
+	last := end.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	for provider := range providers {
+		requests, err := storage.Query(ctx, &llmtracer.RequestFilter{
+			Provider:  provider,
+			StartTime: &start,
+			EndTime:   &last,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query tracked usage: %w", err)
+		}
+		for _, req := range requests {
+			at := req.RequestedAt.UTC()
+			r := row(time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC), req.Provider, req.Model)
+			r.TrackedRequests++
+			r.TrackedInputTokens = llmtracer.AddTokens(r.TrackedInputTokens, req.InputTokens)
+			r.TrackedOutputTokens = llmtracer.AddTokens(r.TrackedOutputTokens, req.OutputTokens)
+			r.TrackedCost += req.Cost
+		}
+	}
+
+	for _, r := range report.Rows {
+		r.InputTokenGap = r.ProviderInputTokens - r.TrackedInputTokens
+		r.OutputTokenGap = r.ProviderOutputTokens - r.TrackedOutputTokens
+		r.CostGap = r.ProviderCost - r.TrackedCost
+
+		providerTokens := llmtracer.AddTokens(r.ProviderInputTokens, r.ProviderOutputTokens)
+		trackedTokens := llmtracer.AddTokens(r.TrackedInputTokens, r.TrackedOutputTokens)
+		gap := math.Abs(float64(providerTokens - trackedTokens))
+		if providerTokens > 0 {
+			r.Coverage = float64(trackedTokens) / float64(providerTokens)
+			r.Flagged = gap/float64(providerTokens) > o.tolerance
+		} else {
+			r.Flagged = trackedTokens > 0
+		}
+	}
+
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.Model < b.Model
+	})
+	for _, r := range report.Rows {
+		if r.Flagged {
+			report.Flagged = append(report.Flagged, r)
+		}
+	}
+	return report, nil
+}
+
+// WriteCSV writes one row per day and model
+func (r *ReconciliationReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{
+		"date", "provider", "model",
+		"provider_requests", "provider_input_tokens", "provider_output_tokens", "provider_cost",
+		"tracked_requests", "tracked_input_tokens", "tracked_output_tokens", "tracked_cost",
+		"input_token_gap", "output_token_gap", "cost_gap", "coverage", "flagged",
+	}); err != nil {
+		return err
+	}
+	for _, row := range r.Rows {
+		if err := writer.Write([]string{
+			row.Date.Format("2006-01-02"), string(row.Provider), row.Model,
+			strconv.FormatInt(row.ProviderRequests, 10),
+			strconv.FormatInt(row.ProviderInputTokens, 10),
+			strconv.FormatInt(row.ProviderOutputTokens, 10),
+			strconv.FormatFloat(row.ProviderCost, 'f', 6, 64),
+			strconv.FormatInt(row.TrackedRequests, 10),
+			strconv.FormatInt(row.TrackedInputTokens, 10),
+			strconv.FormatInt(row.TrackedOutputTokens, 10),
+			strconv.FormatFloat(row.TrackedCost, 'f', 6, 64),
+			strconv.FormatInt(row.InputTokenGap, 10),
+			strconv.FormatInt(row.OutputTokenGap, 10),
+			strconv.FormatFloat(row.CostGap, 'f', 6, 64),
+			strconv.FormatFloat(row.Coverage, 'f', 4, 64),
+			strconv.FormatBool(row.Flagged),
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,150 @@
+package invoicing
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+func TestImportUsageCSV(t *testing.T) {
+	t.Run("OpenAI", func(t *testing.T) {
+		// 1718020800 is 2024-06-10T12:00:00Z
+		export := "start_time,end_time,model,num_model_requests,input_tokens,output_tokens,cost\n" +
+			"1718020800,1718024400,gpt-4o,3,300,30,2.5\n" +
+			"1718024400,1718028000,gpt-4o,1,100,10,1.25\n" +
+			"1718107200,1718110800,gpt-4o,2,\"1,000\",100.0,$5\n"
+		usage, err := ImportOpenAIUsage(strings.NewReader(export))
+		if err != nil {
+			t.Fatalf("Failed to import usage: %v", err)
+		}
+		if len(usage) != 2 {
+			t.Fatalf("Expected 2 days, got %d", len(usage))
+		}
+		day := usage[0]
+		if !day.Date.Equal(time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected 2024-06-10, got %v", day.Date)
+		}
+		if day.Provider != llmtracer.ProviderOpenAI || day.Model != "gpt-4o" {
+			t.Errorf("Unexpected provider or model: %s %s", day.Provider, day.Model)
+		}
+		if day.Requests != 4 || day.InputTokens != 400 || day.OutputTokens != 40 || day.Cost != 3.75 {
+			t.Errorf("Unexpected totals: %+v", day)
+		}
+		if usage[1].InputTokens != 1000 || usage[1].OutputTokens != 100 || usage[1].Cost != 5 {
+			t.Errorf("Unexpected totals: %+v", usage[1])
+		}
+	})
+
+	t.Run("Anthropic", func(t *testing.T) {
+		export := "usage_date_utc,model_version,workspace,input_tokens_no_cache,input_tokens_cache_write_5m,input_tokens_cache_read,output_tokens\n" +
+			"2024-06-10,claude-3-5-sonnet-20240620,default,100,20,30,40\n" +
+			"2024-06-10,claude-3-5-sonnet-20240620,research,50,0,0,10\n"
+		usage, err := ImportAnthropicUsage(strings.NewReader(export))
+		if err != nil {
+			t.Fatalf("Failed to import usage: %v", err)
+		}
+		if len(usage) != 1 {
+			t.Fatalf("Expected 1 day, got %d", len(usage))
+		}
+		if usage[0].InputTokens != 200 || usage[0].OutputTokens != 50 {
+			t.Errorf("Expected cache columns to be summed into 200 input tokens, got %+v", usage[0])
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := ImportOpenAIUsage(strings.NewReader("day,tokens\n")); err == nil {
+			t.Error("Expected error for missing columns")
+		}
+		if _, err := ImportOpenAIUsage(strings.NewReader("date,model,input_tokens\nyesterday,gpt-4o,1\n")); err == nil {
+			t.Error("Expected error for invalid date")
+		}
+		if _, err := ImportOpenAIUsage(strings.NewReader("date,model,input_tokens\n2024-06-10,gpt-4o,many\n")); err == nil {
+			t.Error("Expected error for invalid token count")
+		}
+	})
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	storage := newStorage(t)
+
+	june10 := time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC)
+	usage := []*ProviderUsage{
+		{Date: june10, Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o", Requests: 4, InputTokens: 400, OutputTokens: 40, Cost: 3.75},
+		{Date: june10, Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o-mini-2024-07-18", Requests: 5, InputTokens: 500, OutputTokens: 50, Cost: 0.05},
+		{Date: june10.AddDate(0, 0, 1), Provider: llmtracer.ProviderOpenAI, Model: "gpt-4o", Requests: 1, InputTokens: 100, OutputTokens: 10, Cost: 1},
+	}
+	report, err := Reconcile(ctx, storage, usage, WithModelName(func(provider llmtracer.Provider, model string) string {
+		return strings.TrimSuffix(model, "-2024-07-18")
+	}))
+	if err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+	if len(report.Rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(report.Rows))
+	}
+
+	matched := report.Rows[0]
+	if matched.Model != "gpt-4o" || matched.TrackedRequests != 4 || matched.InputTokenGap != 0 || matched.Flagged {
+		t.Errorf("Expected gpt-4o to reconcile, got %+v", matched)
+	}
+	if matched.Coverage != 1 {
+		t.Errorf("Expected full coverage, got %f", matched.Coverage)
+	}
+
+	mini := report.Rows[1]
+	if mini.Model != "gpt-4o-mini" || mini.TrackedRequests != 1 {
+		t.Fatalf("Expected the snapshot to be mapped to gpt-4o-mini, got %+v", mini)
+	}
+	if mini.InputTokenGap != 400 || mini.OutputTokenGap != 40 || !mini.Flagged {
+		t.Errorf("Expected untracked gpt-4o-mini usage to be flagged, got %+v", mini)
+	}
+	if mini.Coverage != 0.2 {
+		t.Errorf("Expected coverage 0.2, got %f", mini.Coverage)
+	}
+
+	untracked := report.Rows[2]
+	if untracked.TrackedRequests != 0 || untracked.CostGap != 1 || !untracked.Flagged {
+		t.Errorf("Expected June 11 to be untracked, got %+v", untracked)
+	}
+	if len(report.Flagged) != 2 {
+		t.Errorf("Expected 2 flagged rows, got %d", len(report.Flagged))
+	}
+
+	t.Run("Tolerance", func(t *testing.T) {
+		report, err := Reconcile(ctx, storage, usage[1:2], WithTolerance(0.9))
+		if err != nil {
+			t.Fatalf("Failed to reconcile: %v", err)
+		}
+		// Without the model mapping the tracked gpt-4o-mini requests are reported
+		// separately, as is tracked gpt-4o usage that the provider did not report
+		if len(report.Rows) != 3 {
+			t.Fatalf("Expected 3 rows, got %d", len(report.Rows))
+		}
+		if len(report.Flagged) != 3 {
+			t.Errorf("Expected every unmatched row to be flagged, got %d", len(report.Flagged))
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := report.WriteCSV(&buf); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to read CSV: %v", err)
+		}
+		if len(records) != 4 {
+			t.Fatalf("Expected header and 3 rows, got %d", len(records))
+		}
+		if records[2][2] != "gpt-4o-mini" || records[2][11] != "400" || records[2][15] != "true" {
+			t.Errorf("Unexpected row: %v", records[2])
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// DimensionAbandoned marks pending requests the watchdog closed as timeouts because no
+// response was tracked, e.g. after the process tracking them crashed
+const DimensionAbandoned = "abandoned"
+
+// ErrLifecycleNotSupported is returned when updating requests through a storage adapter
+// that does not implement LifecycleStorage
+var ErrLifecycleNotSupported = errors.New("lifecycle tracking is not supported by this storage adapter")
+
+// WithLifecycleTracking makes the trace wrappers and the HTTP transport write a pending
+// request when a call starts, and complete that row when the call returns, so calls in
+// flight, and calls stuck without a response, can be seen in storage. It needs an adapter
+// implementing LifecycleStorage and is ignored otherwise.
+//
+// Each call costs an extra write, made in the background in async mode. Pending requests
+// are excluded from reads unless RequestFilter.InFlight is set, and successful calls
+// skipped by sampling have their pending row deleted.
+func WithLifecycleTracking(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.lifecycleTracking = enabled
+	}
+}
+
+// WithInFlightWatchdog closes pending requests written by lifecycle tracking that have
+// gone without a response for longer than timeout, checking every interval (every minute
+// when interval is zero) until the client is closed. See AbandonStuckRequests.
+func WithInFlightWatchdog(timeout, interval time.Duration) ClientOption {
+	return func(c *Client) {
+		c.watchdogTimeout = timeout
+		c.watchdogInterval = interval
+	}
+}
+
+// pendingWrite is the write of a pending request, which completing the request waits for
+type pendingWrite struct {
+	done chan struct{}
+	err  error
+}
+
+// startRequest writes request as pending before a call is made, when lifecycle tracking
+// is enabled, and returns it for the wrapper to complete and track
+func (c *Client) startRequest(ctx context.Context, request *Request) *Request {
+	if !c.lifecycleTracking || !c.storage.Capabilities().Lifecycle {
+		return request
+	}
+	if _, ok := c.storage.(LifecycleStorage); !ok {
+		return request
+	}
+	if !c.beginTrack() {
+		return request
+	}
+
+	now := time.Now()
+	request.ID = c.requestID(ctx)
+	if request.RequestType == "" {
+		request.RequestType = RequestTypeChat
+	}
+	request.TraceID = GetTraceIDFromContext(ctx)
+	request.PromptVersion = GetPromptVersionFromContext(ctx)
+	request.RequestedAt = now
+	request.CreatedAt = now
+	request.UpdatedAt = now
+
+	pending := *request
+	pending.Pending = true
+	pending.Dimensions = c.dimensionTags(GetDimensionsFromContext(ctx))
+
+	write := &pendingWrite{done: make(chan struct{})}
+	request.pendingWrite = write
+	save := func(ctx context.Context) {
+		defer c.pending.Done()
+		defer close(write.done)
+		write.err = c.callStorage(func() error {
+			return c.storage.Save(ctx, &pending)
+		})
+	}
+	if c.asyncTracking {
+		go save(context.WithoutCancel(ctx))
+	} else {
+		save(ctx)
+	}
+	return request
+}
+
+// pendingSaved waits for the pending write of a request started with lifecycle tracking,
+// reporting whether the pending row was stored
+func (r *Request) pendingSaved() bool {
+	if r.pendingWrite == nil {
+		return false
+	}
+	<-r.pendingWrite.done
+	return r.pendingWrite.err == nil
+}
+
+// GetInFlightRequests returns the pending requests written by lifecycle tracking that
+// started more than olderThan ago, oldest first. A zero olderThan returns every request in
+// flight; a longer one finds calls stuck without a response.
+func (c *Client) GetInFlightRequests(ctx context.Context, olderThan time.Duration) ([]*Request, error) {
+	filter := &RequestFilter{InFlight: true}
+	if olderThan > 0 {
+		// Filter time bounds are inclusive
+		startedBefore := time.Now().Add(-olderThan).UTC()
+		filter.EndTime = &startedBefore
+	}
+	requests, err := c.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].RequestedAt.Before(requests[j].RequestedAt)
+	})
+	return requests, nil
+}
+
+// AbandonStuckRequests closes the pending requests that started more than olderThan ago as
+// failed with ErrorTypeTimeout, status 504 and the abandoned dimension, so crashed
+// processes don't leave calls in flight forever. A call that completes afterwards still
+// replaces its request. It returns the number of requests closed.
+func (c *Client) AbandonStuckRequests(ctx context.Context, olderThan time.Duration) (int, error) {
+	if !c.storage.Capabilities().Lifecycle {
+		return 0, ErrLifecycleNotSupported
+	}
+	stuck, err := c.GetInFlightRequests(ctx, olderThan)
+	if err != nil {
+		return 0, err
+	}
+
+	abandoned := 0
+	for _, request := range stuck {
+		now := time.Now()
+		request.Pending = false
+		request.RespondedAt = now
+		request.UpdatedAt = now
+		request.Latency = now.Sub(request.RequestedAt)
+		request.LatencyMs = request.Latency.Milliseconds()
+		request.StatusCode = http.StatusGatewayTimeout
+		request.Error = fmt.Sprintf("abandoned: no response after %v", olderThan)
+		request.ErrorType = ErrorTypeTimeout
+		request.Dimensions = append(request.Dimensions, DimensionTag{Key: DimensionAbandoned, Value: "true"})
+
+		err := c.callStorage(func() error {
+			return updateRequest(ctx, c.storage, request)
+		})
+		if err != nil {
+			return abandoned, err
+		}
+		abandoned++
+	}
+	return abandoned, nil
+}
+
+// runWatchdog periodically abandons stuck requests
+func (c *Client) runWatchdog() {
+	ticker := time.NewTicker(c.watchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		abandoned, err := c.AbandonStuckRequests(context.Background(), c.watchdogTimeout)
+		if err != nil {
+			c.logger.Error("Failed to abandon stuck requests", slog.Any("error", err))
+		}
+		if abandoned > 0 {
+			c.logger.Warn("Abandoned stuck requests", slog.Int("count", abandoned))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.stopWatchdog:
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleTracking(t *testing.T) {
+	ctx := WithUserID(context.Background(), "alice")
+	request := openai.ChatCompletionRequest{Model: "gpt-4o"}
+	response := openai.ChatCompletionResponse{Usage: openai.Usage{PromptTokens: 10, CompletionTokens: 5}}
+
+	t.Run("Pending row completed by the response", func(t *testing.T) {
+		storage := newLifecycleStorage()
+		client := NewClient(storage, WithLifecycleTracking(true))
+
+		_, err := client.TraceOpenAIRequest(ctx, request, func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			inFlight, err := client.GetInFlightRequests(ctx, 0)
+			require.NoError(t, err)
+			require.Len(t, inFlight, 1)
+			assert.Equal(t, "gpt-4o", inFlight[0].Model)
+			assert.Equal(t, "alice", inFlight[0].Dimension("user_id"))
+
+			completed, err := storage.Query(ctx, nil)
+			require.NoError(t, err)
+			assert.Empty(t, completed, "pending requests are excluded by default")
+			return response, nil
+		})
+		require.NoError(t, err)
+
+		require.Len(t, storage.saves, 1)
+		require.Len(t, storage.updates, 1)
+		pending, completed := storage.saves[0], storage.updates[0]
+		assert.True(t, pending.Pending)
+		assert.False(t, completed.Pending)
+		assert.Equal(t, pending.ID, completed.ID)
+		assert.True(t, pending.CreatedAt.Equal(completed.CreatedAt))
+		assert.Equal(t, int64(15), completed.TotalTokens)
+		assert.Equal(t, 200, completed.StatusCode)
+
+		inFlight, err := client.GetInFlightRequests(ctx, 0)
+		require.NoError(t, err)
+		assert.Empty(t, inFlight)
+	})
+
+	t.Run("Async", func(t *testing.T) {
+		storage := newLifecycleStorage()
+		client := NewClient(storage, WithLifecycleTracking(true), WithAsyncTracking(true))
+
+		_, err := client.TraceOpenAIRequest(ctx, request, func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			return response, nil
+		})
+		require.NoError(t, err)
+		require.NoError(t, client.Close())
+
+		require.Len(t, storage.saves, 1)
+		require.Len(t, storage.updates, 1)
+		assert.Equal(t, storage.saves[0].ID, storage.updates[0].ID)
+	})
+
+	t.Run("Through wrappers", func(t *testing.T) {
+		storage := newLifecycleStorage()
+		client := NewClient(storage, WithLifecycleTracking(true), WithPseudonymization([]byte("pseudonymization-secret"), "user_id"))
+
+		_, err := client.TraceOpenAIRequest(ctx, request, func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			return response, nil
+		})
+		require.NoError(t, err)
+		require.Len(t, storage.saves, 1)
+		require.Len(t, storage.updates, 1)
+		assert.True(t, isPseudonym(storage.saves[0].Dimension("user_id")))
+		assert.True(t, isPseudonym(storage.updates[0].Dimension("user_id")))
+	})
+
+	t.Run("Sampled out", func(t *testing.T) {
+		storage := newLifecycleStorage()
+		client := NewClient(storage, WithLifecycleTracking(true), WithSampleRate(0))
+
+		_, err := client.TraceOpenAIRequest(ctx, request, func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			return response, nil
+		})
+		require.NoError(t, err)
+		require.Len(t, storage.saves, 1)
+		assert.Equal(t, []string{storage.saves[0].ID}, storage.deleted)
+		assert.Empty(t, storage.requests)
+	})
+
+	t.Run("Ignored without lifecycle storage", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage, WithLifecycleTracking(true))
+
+		_, err := client.TraceOpenAIRequest(ctx, request, func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			assert.Empty(t, storage.SaveCalls)
+			return response, nil
+		})
+		require.NoError(t, err)
+		assert.Len(t, storage.SaveCalls, 1)
+	})
+
+	t.Run("Stuck requests", func(t *testing.T) {
+		storage := newLifecycleStorage()
+		started := time.Now().Add(-time.Hour)
+		storage.requests["stuck"] = Request{ID: "stuck", Pending: true, RequestedAt: started}
+		storage.requests["recent"] = Request{ID: "recent", Pending: true, RequestedAt: time.Now()}
+		storage.requests["done"] = Request{ID: "done", RequestedAt: started}
+
+		stuck, err := NewClient(storage).GetInFlightRequests(ctx, 10*time.Minute)
+		require.NoError(t, err)
+		require.Len(t, stuck, 1)
+		assert.Equal(t, "stuck", stuck[0].ID)
+	})
+}
@@ -0,0 +1,126 @@
+package llmtracer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lifecycleStorage keeps requests by ID, recording copies of the saves and updates. Queries
+// are ordered by ID.
+type lifecycleStorage struct {
+	*MockStorageAdapter
+	mu       sync.Mutex
+	requests map[string]Request
+	saves    []Request
+	updates  []Request
+	deleted  []string
+}
+
+func newLifecycleStorage() *lifecycleStorage {
+	return &lifecycleStorage{
+		MockStorageAdapter: &MockStorageAdapter{
+			CapabilitiesFunc: func() StorageCapabilities { return StorageCapabilities{Query: true, Lifecycle: true} },
+		},
+		requests: make(map[string]Request),
+	}
+}
+
+func (s *lifecycleStorage) Save(ctx context.Context, request *Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saves = append(s.saves, *request)
+	s.requests[request.ID] = *request
+	return nil
+}
+
+func (s *lifecycleStorage) Update(ctx context.Context, request *Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updates = append(s.updates, *request)
+	s.requests[request.ID] = *request
+	return nil
+}
+
+func (s *lifecycleStorage) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = append(s.deleted, id)
+	delete(s.requests, id)
+	return nil
+}
+
+func (s *lifecycleStorage) Query(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var requests []*Request
+	for _, request := range s.requests {
+		if filter.Matches(&request) {
+			requests = append(requests, &request)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID < requests[j].ID })
+	if filter != nil {
+		requests = requests[min(filter.Offset, len(requests)):]
+		if filter.Limit > 0 {
+			requests = requests[:min(filter.Limit, len(requests))]
+		}
+	}
+	return requests, nil
+}
+
+func TestAbandonStuckRequests(t *testing.T) {
+	ctx := context.Background()
+	started := time.Now().Add(-time.Hour)
+
+	t.Run("Closes stuck requests as timeouts", func(t *testing.T) {
+		storage := newLifecycleStorage()
+		storage.requests["stuck"] = Request{ID: "stuck", Pending: true, RequestedAt: started,
+			Dimensions: []DimensionTag{{Key: "user_id", Value: "alice"}}}
+		storage.requests["recent"] = Request{ID: "recent", Pending: true, RequestedAt: time.Now()}
+		client := NewClient(storage)
+
+		abandoned, err := client.AbandonStuckRequests(ctx, 10*time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, 1, abandoned)
+
+		require.Len(t, storage.updates, 1)
+		request := storage.updates[0]
+		assert.Equal(t, "stuck", request.ID)
+		assert.False(t, request.Pending)
+		assert.Equal(t, ErrorTypeTimeout, request.ErrorType)
+		assert.Equal(t, 504, request.StatusCode)
+		assert.NotEmpty(t, request.Error)
+		assert.Equal(t, "true", request.Dimension(DimensionAbandoned))
+		assert.Equal(t, "alice", request.Dimension("user_id"))
+		assert.GreaterOrEqual(t, request.Latency, time.Hour)
+
+		inFlight, err := client.GetInFlightRequests(ctx, 0)
+		require.NoError(t, err)
+		require.Len(t, inFlight, 1)
+		assert.Equal(t, "recent", inFlight[0].ID)
+	})
+
+	t.Run("Watchdog", func(t *testing.T) {
+		storage := newLifecycleStorage()
+		storage.requests["stuck"] = Request{ID: "stuck", Pending: true, RequestedAt: started}
+		client := NewClient(storage, WithInFlightWatchdog(10*time.Minute, time.Millisecond))
+		defer client.Close()
+
+		assert.Eventually(t, func() bool {
+			storage.mu.Lock()
+			defer storage.mu.Unlock()
+			return !storage.requests["stuck"].Pending
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("Needs lifecycle storage", func(t *testing.T) {
+		_, err := NewClient(&MockStorageAdapter{}).AbandonStuckRequests(ctx, time.Minute)
+		assert.ErrorIs(t, err, ErrLifecycleNotSupported)
+	})
+}
@@ -0,0 +1,49 @@
+package llmtracer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger receives the client's log records, mainly tracking errors. Fields are slog
+// attributes so the package does not depend on a logging library; use NewSlogLogger for
+// a *slog.Logger and the zaptracer package for zap.
+type Logger interface {
+	Error(msg string, fields ...slog.Attr)
+	Warn(msg string, fields ...slog.Attr)
+	Info(msg string, fields ...slog.Attr)
+	Debug(msg string, fields ...slog.Attr)
+}
+
+// NewSlogLogger adapts a *slog.Logger to Logger
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Error(msg string, fields ...slog.Attr) {
+	l.logger.LogAttrs(context.Background(), slog.LevelError, msg, fields...)
+}
+
+func (l slogLogger) Warn(msg string, fields ...slog.Attr) {
+	l.logger.LogAttrs(context.Background(), slog.LevelWarn, msg, fields...)
+}
+
+func (l slogLogger) Info(msg string, fields ...slog.Attr) {
+	l.logger.LogAttrs(context.Background(), slog.LevelInfo, msg, fields...)
+}
+
+func (l slogLogger) Debug(msg string, fields ...slog.Attr) {
+	l.logger.LogAttrs(context.Background(), slog.LevelDebug, msg, fields...)
+}
+
+// nopLogger discards every record
+type nopLogger struct{}
+
+func (nopLogger) Error(string, ...slog.Attr) {}
+func (nopLogger) Warn(string, ...slog.Attr)  {}
+func (nopLogger) Info(string, ...slog.Attr)  {}
+func (nopLogger) Debug(string, ...slog.Attr) {}
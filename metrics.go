@@ -0,0 +1,169 @@
+package llmtracer
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// String returns the state name used in metrics
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// ClientMetrics describes the tracer's own overhead, so operators can check that tracking
+// isn't becoming the bottleneck
+type ClientMetrics struct {
+	// InFlight is the number of async tracks that have not finished saving
+	InFlight int64
+	// Saved is the number of requests saved
+	Saved int64
+	// Dropped is the number of requests lost because saving failed or the circuit was open
+	Dropped int64
+	// SampledOut is the number of requests skipped by WithSampleRate or WithSampled
+	SampledOut int64
+	// Deduplicated is the number of track calls dropped by WithDeduplication
+	Deduplicated int64
+	// DimensionsOverflowed is the number of dimension values saved as DimensionOverflowValue
+	DimensionsOverflowed int64
+	// Throttled is the number of calls delayed by WithModelRateLimits, and ThrottleWait
+	// the total time they waited
+	Throttled    int64
+	ThrottleWait time.Duration
+	// SaveCount, SaveDuration and MaxSaveDuration cover every save attempt
+	SaveCount       int64
+	SaveDuration    time.Duration
+	MaxSaveDuration time.Duration
+	// CircuitBreakerState is "" when no circuit breaker is configured
+	CircuitBreakerState string
+}
+
+// AvgSaveDuration returns the mean duration of a save attempt
+func (m ClientMetrics) AvgSaveDuration() time.Duration {
+	if m.SaveCount == 0 {
+		return 0
+	}
+	return m.SaveDuration / time.Duration(m.SaveCount)
+}
+
+// clientCounters holds the counters behind ClientMetrics
+type clientCounters struct {
+	inFlight             atomic.Int64
+	saved                atomic.Int64
+	dropped              atomic.Int64
+	sampledOut           atomic.Int64
+	deduplicated         atomic.Int64
+	dimensionsOverflowed atomic.Int64
+	throttled            atomic.Int64
+	throttleNanos        atomic.Int64
+	saveCount            atomic.Int64
+	saveNanos            atomic.Int64
+	maxSaveDuration      atomic.Int64
+}
+
+// recordSave records the outcome and duration of one save attempt
+func (m *clientCounters) recordSave(duration time.Duration, err error) {
+	m.saveCount.Add(1)
+	m.saveNanos.Add(int64(duration))
+	for {
+		current := m.maxSaveDuration.Load()
+		if int64(duration) <= current || m.maxSaveDuration.CompareAndSwap(current, int64(duration)) {
+			break
+		}
+	}
+	if err != nil {
+		m.dropped.Add(1)
+	} else {
+		m.saved.Add(1)
+	}
+}
+
+// Metrics returns a snapshot of the tracer's own metrics
+func (c *Client) Metrics() ClientMetrics {
+	metrics := ClientMetrics{
+		InFlight:             c.metrics.inFlight.Load(),
+		Saved:                c.metrics.saved.Load(),
+		Dropped:              c.metrics.dropped.Load(),
+		SampledOut:           c.metrics.sampledOut.Load(),
+		Deduplicated:         c.metrics.deduplicated.Load(),
+		DimensionsOverflowed: c.metrics.dimensionsOverflowed.Load(),
+		Throttled:            c.metrics.throttled.Load(),
+		ThrottleWait:         time.Duration(c.metrics.throttleNanos.Load()),
+		SaveCount:            c.metrics.saveCount.Load(),
+		SaveDuration:         time.Duration(c.metrics.saveNanos.Load()),
+		MaxSaveDuration:      time.Duration(c.metrics.maxSaveDuration.Load()),
+	}
+	if c.circuitBreaker != nil {
+		metrics.CircuitBreakerState = c.circuitBreaker.GetState().String()
+	}
+	return metrics
+}
+
+// PublishExpvar publishes the tracer's metrics under name in expvar, so they are served
+// at /debug/vars. Like expvar.Publish, it panics if name is already published.
+func (c *Client) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		metrics := c.Metrics()
+		return map[string]interface{}{
+			"in_flight":             metrics.InFlight,
+			"saved":                 metrics.Saved,
+			"dropped":               metrics.Dropped,
+			"sampled_out":           metrics.SampledOut,
+			"deduplicated":          metrics.Deduplicated,
+			"dimensions_overflowed": metrics.DimensionsOverflowed,
+			"throttled":             metrics.Throttled,
+			"throttle_wait_seconds": metrics.ThrottleWait.Seconds(),
+			"save_count":            metrics.SaveCount,
+			"save_avg_seconds":      metrics.AvgSaveDuration().Seconds(),
+			"save_max_seconds":      metrics.MaxSaveDuration.Seconds(),
+			"circuit_breaker_state": metrics.CircuitBreakerState,
+		}
+	}))
+}
+
+// MetricsHandler serves the tracer's metrics in the Prometheus text exposition format
+func (c *Client) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics := c.Metrics()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		writeMetric := func(name, kind, help string, value interface{}) {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+		}
+		writeMetric("llmtracer_tracking_in_flight", "gauge", "Async tracks that have not finished saving.", metrics.InFlight)
+		writeMetric("llmtracer_requests_saved_total", "counter", "Requests saved to storage.", metrics.Saved)
+		writeMetric("llmtracer_requests_dropped_total", "counter", "Requests lost because saving failed or the circuit breaker was open.", metrics.Dropped)
+		writeMetric("llmtracer_requests_sampled_out_total", "counter", "Requests skipped by sampling.", metrics.SampledOut)
+		writeMetric("llmtracer_requests_deduplicated_total", "counter", "Track calls dropped as duplicates of a recent call.", metrics.Deduplicated)
+		writeMetric("llmtracer_dimensions_overflowed_total", "counter", "Dimension values replaced because their key reached its cardinality limit.", metrics.DimensionsOverflowed)
+		writeMetric("llmtracer_calls_throttled_total", "counter", "Calls delayed to stay under model rate limits.", metrics.Throttled)
+		writeMetric("llmtracer_throttle_wait_seconds_total", "counter", "Time calls waited to stay under model rate limits.", metrics.ThrottleWait.Seconds())
+
+		fmt.Fprint(w, "# HELP llmtracer_save_duration_seconds Duration of storage saves.\n# TYPE llmtracer_save_duration_seconds summary\n")
+		fmt.Fprintf(w, "llmtracer_save_duration_seconds_sum %v\n", metrics.SaveDuration.Seconds())
+		fmt.Fprintf(w, "llmtracer_save_duration_seconds_count %d\n", metrics.SaveCount)
+		writeMetric("llmtracer_save_duration_max_seconds", "gauge", "Longest storage save.", metrics.MaxSaveDuration.Seconds())
+
+		if metrics.CircuitBreakerState != "" {
+			fmt.Fprint(w, "# HELP llmtracer_circuit_breaker_state Current circuit breaker state (1 for the active state).\n# TYPE llmtracer_circuit_breaker_state gauge\n")
+			for _, state := range []CircuitBreakerState{StateClosed, StateOpen, StateHalfOpen} {
+				active := 0
+				if state.String() == metrics.CircuitBreakerState {
+					active = 1
+				}
+				fmt.Fprintf(w, "llmtracer_circuit_breaker_state{state=%q} %d\n", state.String(), active)
+			}
+		}
+	})
+}
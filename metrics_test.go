@@ -0,0 +1,100 @@
+package llmtracer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("counts saves and drops", func(t *testing.T) {
+		fail := false
+		storage := &MockStorageAdapter{
+			SaveFunc: func(ctx context.Context, request *Request) error {
+				if fail {
+					return errors.New("database unavailable")
+				}
+				return nil
+			},
+		}
+		client := NewClient(storage, WithCircuitBreaker(1, time.Minute))
+
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		fail = true
+		assert.Error(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		assert.ErrorIs(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil), ErrCircuitOpen)
+
+		metrics := client.Metrics()
+		assert.Equal(t, int64(1), metrics.Saved)
+		assert.Equal(t, int64(2), metrics.Dropped)
+		assert.Equal(t, int64(3), metrics.SaveCount)
+		assert.Equal(t, "open", metrics.CircuitBreakerState)
+		assert.GreaterOrEqual(t, metrics.MaxSaveDuration, metrics.AvgSaveDuration())
+	})
+
+	t.Run("counts sampled out requests", func(t *testing.T) {
+		client := NewClient(&MockStorageAdapter{}, WithSampleRate(0))
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+
+		metrics := client.Metrics()
+		assert.Equal(t, int64(1), metrics.SampledOut)
+		assert.Equal(t, int64(0), metrics.SaveCount)
+		assert.Empty(t, metrics.CircuitBreakerState)
+	})
+
+	t.Run("reports async tracks in flight", func(t *testing.T) {
+		release := make(chan struct{})
+		saved := make(chan struct{})
+		storage := &MockStorageAdapter{
+			SaveFunc: func(ctx context.Context, request *Request) error {
+				<-release
+				close(saved)
+				return nil
+			},
+		}
+		client := NewClient(storage, WithAsyncTracking(true))
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		assert.Equal(t, int64(1), client.Metrics().InFlight)
+
+		close(release)
+		<-saved
+		assert.Eventually(t, func() bool { return client.Metrics().InFlight == 0 }, time.Second, time.Millisecond)
+	})
+
+	t.Run("Prometheus handler", func(t *testing.T) {
+		client := NewClient(&MockStorageAdapter{}, WithCircuitBreaker(5, time.Minute))
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+
+		recorder := httptest.NewRecorder()
+		client.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		body, _ := io.ReadAll(recorder.Body)
+
+		assert.Contains(t, string(body), "llmtracer_requests_saved_total 1\n")
+		assert.Contains(t, string(body), "llmtracer_save_duration_seconds_count 1\n")
+		assert.Contains(t, string(body), `llmtracer_circuit_breaker_state{state="closed"} 1`)
+		assert.Contains(t, string(body), `llmtracer_circuit_breaker_state{state="open"} 0`)
+	})
+
+	t.Run("expvar", func(t *testing.T) {
+		client := NewClient(&MockStorageAdapter{})
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		// expvar names can't be published twice, also not across runs with -count
+		name := fmt.Sprintf("llmtracer_test_metrics_%d", time.Now().UnixNano())
+		client.PublishExpvar(name)
+
+		var values map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &values))
+		assert.Equal(t, float64(1), values["saved"])
+	})
+}
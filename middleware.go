@@ -0,0 +1,167 @@
+package llmtracer
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Default headers read by the HTTP middleware
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceParent = "traceparent"
+	HeaderUserID      = "X-User-ID"
+)
+
+// MiddlewareOption configures HTTPMiddleware and the framework adapters built on it
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	requestIDHeader string
+	userIDFunc      func(r *http.Request) string
+	featureFunc     func(r *http.Request) string
+	echoRequestID   bool
+}
+
+// WithRequestIDHeader sets the header used as the trace ID when no traceparent is present
+func WithRequestIDHeader(header string) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.requestIDHeader = header
+	}
+}
+
+// WithUserIDHeader reads the user ID from the given request header
+func WithUserIDHeader(header string) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.userIDFunc = func(r *http.Request) string {
+			return r.Header.Get(header)
+		}
+	}
+}
+
+// WithUserIDFunc resolves the user ID from the incoming request, e.g. from an auth session
+func WithUserIDFunc(fn func(r *http.Request) string) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.userIDFunc = fn
+	}
+}
+
+// WithFeatureFunc resolves the feature name from the incoming request, e.g. from the route
+func WithFeatureFunc(fn func(r *http.Request) string) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.featureFunc = fn
+	}
+}
+
+// WithRequestIDEcho writes the resolved trace ID back on the response's request ID header
+func WithRequestIDEcho(echo bool) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.echoRequestID = echo
+	}
+}
+
+func newMiddlewareConfig(opts []MiddlewareOption) *middlewareConfig {
+	cfg := &middlewareConfig{
+		requestIDHeader: HeaderRequestID,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+	return cfg
+}
+
+// HTTPMiddleware seeds the tracer context of every incoming request so downstream LLM
+// calls are attributed without manual plumbing. The trace ID is taken from a W3C
+// traceparent header, then the request ID header, and generated when neither is present.
+// The sampled flag of a traceparent header is recorded with WithSampled.
+func HTTPMiddleware(opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	cfg := newMiddlewareConfig(opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(cfg.seedRequest(w, r)))
+		})
+	}
+}
+
+// ContextFromHTTPRequest returns the request's context seeded with tracing metadata, using
+// the same rules as HTTPMiddleware. It has no response to write to, so WithRequestIDEcho
+// is ignored; use SeedHTTPRequest when there is one.
+func ContextFromHTTPRequest(r *http.Request, opts ...MiddlewareOption) context.Context {
+	return newMiddlewareConfig(opts).seedContext(r)
+}
+
+// SeedHTTPRequest returns the request's context seeded with tracing metadata and, with
+// WithRequestIDEcho, sets the trace ID on the response's request ID header, exactly as
+// HTTPMiddleware does. Framework adapters use it to share behavior.
+func SeedHTTPRequest(w http.ResponseWriter, r *http.Request, opts ...MiddlewareOption) context.Context {
+	return newMiddlewareConfig(opts).seedRequest(w, r)
+}
+
+// seedRequest seeds the request's context and echoes the trace ID when configured
+func (cfg *middlewareConfig) seedRequest(w http.ResponseWriter, r *http.Request) context.Context {
+	ctx := cfg.seedContext(r)
+	if cfg.echoRequestID {
+		w.Header().Set(cfg.requestIDHeader, GetTraceIDFromContext(ctx))
+	}
+	return ctx
+}
+
+// seedContext adds the trace ID, user ID and feature found on the request to its context
+func (cfg *middlewareConfig) seedContext(r *http.Request) context.Context {
+	ctx := r.Context()
+
+	traceID, sampled := parseTraceParent(r.Header.Get(HeaderTraceParent))
+	if traceID != "" {
+		// Keep the caller's sampling decision so both tracers record the same traces
+		ctx = WithSampled(ctx, sampled)
+	}
+	if traceID == "" {
+		traceID = r.Header.Get(cfg.requestIDHeader)
+	}
+	if traceID == "" {
+		// Reuse an ID already on the context
+		traceID, _ = ctx.Value(traceIDKey).(string)
+	}
+	if traceID != "" {
+		ctx = WithTraceID(ctx, traceID)
+	} else {
+		// Generate one, so every call in this request shares it
+		ctx = withNewTraceID(ctx)
+	}
+
+	if cfg.userIDFunc != nil {
+		if userID := cfg.userIDFunc(r); userID != "" {
+			ctx = WithUserID(ctx, userID)
+		}
+	}
+
+	if cfg.featureFunc != nil {
+		if feature := cfg.featureFunc(r); feature != "" {
+			ctx = WithFeature(ctx, feature)
+		}
+	}
+
+	return ctx
+}
+
+// parseTraceParent extracts the trace ID and sampled flag from a W3C traceparent header
+// ("version-traceid-parentid-flags"), returning "" when the header is malformed
+func parseTraceParent(header string) (traceID string, sampled bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 {
+		return "", false
+	}
+	traceID = strings.ToLower(parts[1])
+	if strings.Trim(traceID, "0123456789abcdef") != "" || traceID == strings.Repeat("0", 32) {
+		return "", false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil || len(parts[3]) != 2 {
+		return "", false
+	}
+	return traceID, flags&0x01 != 0
+}
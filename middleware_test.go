@@ -0,0 +1,82 @@
+package llmtracer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	// serve runs the middleware and returns the context seen by the handler
+	serve := func(req *http.Request, opts ...MiddlewareOption) (context.Context, *httptest.ResponseRecorder) {
+		var seen context.Context
+		handler := HTTPMiddleware(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = r.Context()
+		}))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return seen, recorder
+	}
+
+	t.Run("uses traceparent trace ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("traceparent", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01")
+		req.Header.Set("X-Request-ID", "ignored")
+
+		ctx, _ := serve(req)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", GetTraceIDFromContext(ctx))
+	})
+
+	t.Run("propagates traceparent sampled flag", func(t *testing.T) {
+		for flags, want := range map[string]bool{"01": true, "00": false, "03": true} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-"+flags)
+
+			ctx, _ := serve(req)
+			sampled, ok := GetSampledFromContext(ctx)
+			assert.True(t, ok, flags)
+			assert.Equal(t, want, sampled, flags)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", "req-123")
+		ctx, _ := serve(req)
+		_, ok := GetSampledFromContext(ctx)
+		assert.False(t, ok, "no decision without a traceparent")
+	})
+
+	t.Run("falls back to request ID header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("traceparent", "garbage")
+		req.Header.Set("X-Request-ID", "req-123")
+
+		ctx, _ := serve(req)
+		assert.Equal(t, "req-123", GetTraceIDFromContext(ctx))
+	})
+
+	t.Run("generates a stable trace ID when absent", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		ctx, recorder := serve(req, WithRequestIDEcho(true))
+		traceID := GetTraceIDFromContext(ctx)
+		assert.NotEmpty(t, traceID)
+		assert.Equal(t, traceID, GetTraceIDFromContext(ctx), "trace ID should be stored, not regenerated")
+		assert.Equal(t, traceID, recorder.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("user and feature resolution", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+		req.Header.Set("X-User-ID", "user-42")
+
+		ctx, _ := serve(req,
+			WithUserIDHeader(HeaderUserID),
+			WithFeatureFunc(func(r *http.Request) string { return r.URL.Path }),
+		)
+		dimensions := GetDimensionsFromContext(ctx)
+		assert.Equal(t, "user-42", dimensions["user_id"])
+		assert.Equal(t, "/chat", dimensions["feature"])
+	})
+}
@@ -0,0 +1,128 @@
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MigrateProgress reports how far Migrate has got. It can be saved, e.g. as JSON, and
+// passed back with WithMigrationResume to continue an interrupted migration.
+type MigrateProgress struct {
+	// Scanned is the number of requests read from the source
+	Scanned int64 `json:"scanned"`
+	// Copied is the number of requests saved to the destination
+	Copied int64 `json:"copied"`
+	// Skipped is the number of requests the destination already had
+	Skipped int64 `json:"skipped"`
+	// Cutoff is the time the migration started. Requests sent later are not copied, so
+	// the batches stay stable while the source keeps receiving requests.
+	Cutoff time.Time `json:"cutoff"`
+}
+
+// MigrateOption configures Migrate
+type MigrateOption func(*migrateOptions)
+
+type migrateOptions struct {
+	resume   *MigrateProgress
+	progress func(MigrateProgress)
+}
+
+// WithMigrationResume continues a migration from the progress it last reported
+func WithMigrationResume(progress MigrateProgress) MigrateOption {
+	return func(o *migrateOptions) {
+		o.resume = &progress
+	}
+}
+
+// WithMigrationProgress calls fn after each batch with the totals so far. Saving them lets
+// an interrupted migration resume with WithMigrationResume.
+func WithMigrationProgress(fn func(MigrateProgress)) MigrateOption {
+	return func(o *migrateOptions) {
+		o.progress = fn
+	}
+}
+
+// Migrate copies the requests matching filter, with their dimensions, from one adapter to
+// another, e.g. from SQLite to Postgres, batchSize at a time (500 when zero). Requests are
+// read in batches ordered by ID; the filter's Limit, Offset and ordering are ignored, and
+// its EndTime is capped at the cutoff recorded in the progress.
+//
+// Requests the destination already has are skipped, so a migration can also be resumed
+// by running it again; WithMigrationResume avoids rescanning the batches already copied.
+// Feedback, incidents and saved filters are not copied.
+func Migrate(ctx context.Context, from, to StorageAdapter, filter *RequestFilter, batchSize int, opts ...MigrateOption) (MigrateProgress, error) {
+	if from == nil || to == nil {
+		return MigrateProgress{}, fmt.Errorf("storage adapter cannot be nil")
+	}
+	if !from.Capabilities().Query {
+		return MigrateProgress{}, ErrQueryNotSupported
+	}
+	options := migrateOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if batchSize <= 0 {
+		batchSize = defaultRecalculateBatchSize
+	}
+
+	progress := MigrateProgress{Cutoff: time.Now()}
+	if options.resume != nil {
+		progress = *options.resume
+	}
+
+	batchFilter := RequestFilter{}
+	if filter != nil {
+		batchFilter = *filter
+	}
+	if batchFilter.EndTime == nil || batchFilter.EndTime.After(progress.Cutoff) {
+		batchFilter.EndTime = &progress.Cutoff
+	}
+	batchFilter.Limit = batchSize
+	batchFilter.OrderBy = "id"
+	batchFilter.OrderDesc = false
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		batchFilter.Offset = int(progress.Scanned)
+		requests, err := from.Query(ctx, &batchFilter)
+		if err != nil {
+			return progress, fmt.Errorf("failed to read requests: %w", err)
+		}
+
+		for _, request := range requests {
+			copied, err := migrateRequest(ctx, to, request)
+			if err != nil {
+				return progress, err
+			}
+			progress.Scanned++
+			if copied {
+				progress.Copied++
+			} else {
+				progress.Skipped++
+			}
+		}
+
+		if options.progress != nil && len(requests) > 0 {
+			options.progress(progress)
+		}
+		if len(requests) < batchSize {
+			return progress, nil
+		}
+	}
+}
+
+// migrateRequest saves request to storage, reporting false when storage already has it
+func migrateRequest(ctx context.Context, storage StorageAdapter, request *Request) (bool, error) {
+	saveErr := storage.Save(ctx, portableCopy(request))
+	if saveErr == nil {
+		return true, nil
+	}
+	// Most adapters reject a duplicate ID, e.g. from a batch copied before an interruption
+	if existing, err := storage.Get(ctx, request.ID); err == nil && existing != nil {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to save request %s: %w", request.ID, saveErr)
+}
@@ -0,0 +1,93 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	from, to := newMemoryStore(), newMemoryStore()
+	source := from.adapter()
+	ctx := context.Background()
+	now := time.Now()
+	for i := 0; i < 7; i++ {
+		require.NoError(t, source.Save(ctx, &Request{
+			ID:          fmt.Sprintf("req-%d", i),
+			Model:       "gpt-4o",
+			RequestedAt: now.Add(-time.Duration(i) * time.Hour),
+			Dimensions:  []DimensionTag{{Key: DimensionFeature, Value: "search"}},
+		}))
+	}
+	require.NoError(t, source.Save(ctx, &Request{ID: "other", Model: "gpt-4o-mini", RequestedAt: now}))
+
+	var reported []MigrateProgress
+	progress, err := Migrate(ctx, source, to.adapter(), &RequestFilter{Model: "gpt-4o", Limit: 1}, 3,
+		WithMigrationProgress(func(p MigrateProgress) { reported = append(reported, p) }))
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), progress.Scanned)
+	assert.Equal(t, int64(7), progress.Copied)
+	assert.Len(t, reported, 3)
+	assert.Len(t, to.requests, 7)
+	assert.NotContains(t, to.requests, "other")
+
+	copied := to.requests["req-3"]
+	require.Len(t, copied.Dimensions, 1)
+	assert.Equal(t, "search", copied.Dimensions[0].Value)
+	assert.Zero(t, copied.Dimensions[0].ID, "dimension IDs of the source are not copied")
+
+	// Running again skips the requests already copied
+	progress, err = Migrate(ctx, source, to.adapter(), &RequestFilter{Model: "gpt-4o"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), progress.Copied)
+	assert.Equal(t, int64(7), progress.Skipped)
+}
+
+func TestMigrateResume(t *testing.T) {
+	from, to := newMemoryStore(), newMemoryStore()
+	source := from.adapter()
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 6; i++ {
+		require.NoError(t, source.Save(ctx, &Request{ID: fmt.Sprintf("req-%d", i), RequestedAt: start}))
+	}
+
+	// The destination fails after the first batch and a half
+	saves := 0
+	failing := to.adapter()
+	save := failing.SaveFunc
+	failing.SaveFunc = func(ctx context.Context, request *Request) error {
+		if saves++; saves > 3 {
+			return errors.New("connection reset")
+		}
+		return save(ctx, request)
+	}
+
+	var last MigrateProgress
+	_, err := Migrate(ctx, source, failing, nil, 2, WithMigrationProgress(func(p MigrateProgress) { last = p }))
+	require.Error(t, err)
+	assert.Equal(t, int64(2), last.Scanned)
+
+	// Requests sent after the migration started are left for a later run
+	require.NoError(t, source.Save(ctx, &Request{ID: "req-late", RequestedAt: time.Now().Add(time.Minute)}))
+
+	progress, err := Migrate(ctx, source, to.adapter(), nil, 2, WithMigrationResume(last))
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), progress.Scanned)
+	assert.Equal(t, int64(5), progress.Copied, "totals carry on from the resumed progress")
+	assert.Equal(t, int64(1), progress.Skipped, "the request saved before the failure is skipped")
+	assert.Equal(t, last.Cutoff, progress.Cutoff)
+	assert.Len(t, to.requests, 6)
+	assert.NotContains(t, to.requests, "req-late")
+}
+
+func TestMigrateRequiresQuery(t *testing.T) {
+	writeOnly := &MockStorageAdapter{CapabilitiesFunc: func() StorageCapabilities { return StorageCapabilities{} }}
+	_, err := Migrate(context.Background(), writeOnly, &MockStorageAdapter{}, nil, 0)
+	assert.ErrorIs(t, err, ErrQueryNotSupported)
+}
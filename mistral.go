@@ -0,0 +1,180 @@
+//go:build !llmtracer_no_mistral
+
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	mistral "github.com/gage-technologies/mistral-go"
+)
+
+// MistralChatFunc represents the signature of Mistral's Chat method
+type MistralChatFunc func(model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams) (*mistral.ChatCompletionResponse, error)
+
+// MistralEmbeddingsFunc represents the signature of Mistral's Embeddings method
+type MistralEmbeddingsFunc func(model string, input []string) (*mistral.EmbeddingResponse, error)
+
+// TraceMistralRequest wraps Mistral's Chat method and automatically tracks token usage
+func (c *Client) TraceMistralRequest(ctx context.Context, model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams, chat MistralChatFunc) (*mistral.ChatCompletionResponse, error) {
+	if chat == nil {
+		return nil, fmt.Errorf("chat function cannot be nil")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("model cannot be empty")
+	}
+	if err := c.admit(ctx, ProviderMistral, model); err != nil {
+		return nil, err
+	}
+	release, queueWait, err := c.acquire(ctx, ProviderMistral, model)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := c.startRequest(ctx, &Request{
+		Provider:     ProviderMistral,
+		Model:        model,
+		MessageCount: len(messages),
+		QueueWait:    queueWait,
+	})
+	startTime := time.Now()
+
+	// Make the actual Mistral API call using the provided function
+	response, err := chat(model, messages, params)
+	release()
+
+	// Track the request - even if it failed
+	tracked.RequestedAt = startTime
+	tracked.Latency = time.Since(startTime)
+	if err == nil {
+		tracked.SetUsage(MistralUsage(response.Usage))
+	}
+	applyMistralMetadata(tracked, response, err)
+	if c.captureGenerationParams {
+		tracked.Params = mistralGenerationParams(params)
+	}
+	if c.capturePayloadSizes {
+		tracked.RequestBytes = jsonSize(messages) + jsonSize(params)
+		if err == nil {
+			tracked.ResponseBytes = jsonSize(response)
+		}
+	}
+
+	// Extract tracking context from context if available
+	trackingContext := GetDimensionsFromContext(ctx)
+
+	c.track(ctx, tracked, err, trackingContext)
+
+	// Return the original response and error
+	return response, err
+}
+
+// TraceMistralEmbeddings wraps Mistral's Embeddings method and tracks the call as a
+// RequestTypeEmbedding request, recording the embedded tokens as input tokens
+func (c *Client) TraceMistralEmbeddings(ctx context.Context, model string, input []string, embeddings MistralEmbeddingsFunc) (*mistral.EmbeddingResponse, error) {
+	if embeddings == nil {
+		return nil, fmt.Errorf("embeddings function cannot be nil")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("model cannot be empty")
+	}
+	if err := c.admit(ctx, ProviderMistral, model); err != nil {
+		return nil, err
+	}
+	release, queueWait, err := c.acquire(ctx, ProviderMistral, model)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := c.startRequest(ctx, &Request{
+		Provider:    ProviderMistral,
+		Model:       model,
+		RequestType: RequestTypeEmbedding,
+		QueueWait:   queueWait,
+	})
+	startTime := time.Now()
+
+	response, err := embeddings(model, input)
+	release()
+
+	tracked.RequestedAt = startTime
+	tracked.Latency = time.Since(startTime)
+	applyMistralMetadata(tracked, nil, err)
+	if err == nil && response != nil {
+		tracked.InputTokens = int64(response.Usage.PromptTokens)
+	}
+	if c.capturePayloadSizes {
+		tracked.RequestBytes = jsonSize(input)
+		if err == nil {
+			tracked.ResponseBytes = jsonSize(response)
+		}
+	}
+
+	c.track(ctx, tracked, err, GetDimensionsFromContext(ctx))
+
+	return response, err
+}
+
+// MistralUsage maps a Mistral usage report to Usage
+func MistralUsage(usage mistral.UsageInfo) Usage {
+	return Usage{
+		InputTokens:  int64(usage.PromptTokens),
+		OutputTokens: int64(usage.CompletionTokens),
+	}
+}
+
+// mistralGenerationParams extracts sampling parameters from Mistral chat request parameters
+func mistralGenerationParams(requestParams *mistral.ChatRequestParams) GenerationParams {
+	if requestParams == nil {
+		return GenerationParams{}
+	}
+
+	// Mistral sends every field, so the values are recorded as-is
+	temperature := requestParams.Temperature
+	topP := requestParams.TopP
+	params := GenerationParams{
+		Temperature:    &temperature,
+		TopP:           &topP,
+		ToolCount:      len(requestParams.Tools),
+		ResponseFormat: string(requestParams.ResponseFormat),
+	}
+	if requestParams.MaxTokens > 0 {
+		maxTokens := requestParams.MaxTokens
+		params.MaxTokens = &maxTokens
+	}
+
+	return params
+}
+
+// mistralHTTPErrorPattern matches the plain errors returned by the Mistral client for non-2xx responses
+var mistralHTTPErrorPattern = regexp.MustCompile(`^\(HTTP Error (\d{3})\)`)
+
+// applyMistralMetadata copies the finish reason, status code and error details from a Mistral call.
+// The Mistral client only exposes response headers on API errors, so the provider request ID
+// is recorded for failed calls alone; the completion ID identifies the response, not the request.
+func applyMistralMetadata(request *Request, response *mistral.ChatCompletionResponse, err error) {
+	if response != nil && len(response.Choices) > 0 {
+		request.FinishReason = normalizeFinishReason(string(response.Choices[0].FinishReason))
+	}
+
+	var apiErr *mistral.MistralAPIError
+	if errors.As(err, &apiErr) {
+		request.StatusCode = apiErr.HTTPStatus
+		if ids := http.Header(apiErr.Headers).Get("X-Request-Id"); ids != "" {
+			request.ProviderRequestID = ids
+		}
+		request.RetryAfter = parseRetryAfter(http.Header(apiErr.Headers), time.Now())
+		return
+	}
+
+	if err != nil {
+		if match := mistralHTTPErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+			request.StatusCode, _ = strconv.Atoi(match[1])
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DimensionModerationFlagged records whether a moderation call flagged the content
+const DimensionModerationFlagged = "moderation_flagged"
+
+// ModerateFunc runs a moderation check, returning whether the content was flagged
+type ModerateFunc func(ctx context.Context) (flagged bool, err error)
+
+// TraceModeration tracks a moderation call made with a client without a dedicated wrapper,
+// such as a call to Azure AI Content Safety, as a RequestTypeModeration request of the
+// given provider and model. The flagged result is recorded in the moderation_flagged
+// dimension. Moderation calls report no token usage, so they cost nothing unless the
+// model has a PerRequest price.
+func (c *Client) TraceModeration(ctx context.Context, provider Provider, model string, moderate ModerateFunc) (bool, error) {
+	if moderate == nil {
+		return false, fmt.Errorf("moderate function cannot be nil")
+	}
+	if err := c.admit(ctx, provider, model); err != nil {
+		return false, err
+	}
+	release, queueWait, err := c.acquire(ctx, provider, model)
+	if err != nil {
+		return false, err
+	}
+
+	tracked := c.startRequest(ctx, &Request{
+		Provider:    provider,
+		Model:       model,
+		RequestType: RequestTypeModeration,
+		QueueWait:   queueWait,
+	})
+	startTime := time.Now()
+
+	flagged, err := moderate(ctx)
+	release()
+
+	tracked.RequestedAt = startTime
+	tracked.Latency = time.Since(startTime)
+	trackingContext := GetDimensionsFromContext(ctx)
+	if err == nil {
+		trackingContext[DimensionModerationFlagged] = flagged
+	}
+	c.track(ctx, tracked, err, trackingContext)
+
+	return flagged, err
+}
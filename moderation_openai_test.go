@@ -0,0 +1,36 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceOpenAIModeration(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	ctx := WithFeature(context.Background(), "chat")
+	response, err := client.TraceOpenAIModeration(ctx, openai.ModerationRequest{Input: "hello"},
+		func(ctx context.Context, request openai.ModerationRequest) (openai.ModerationResponse, error) {
+			return openai.ModerationResponse{
+				Model:   "omni-moderation-2024-09-26",
+				Results: []openai.Result{{Flagged: false}, {Flagged: true}},
+			}, nil
+		})
+	require.NoError(t, err)
+	assert.Len(t, response.Results, 2)
+
+	require.Len(t, storage.SaveCalls, 1)
+	saved := storage.SaveCalls[0].Request
+	assert.Equal(t, RequestTypeModeration, saved.RequestType)
+	assert.Equal(t, "omni-moderation-2024-09-26", saved.Model)
+	assert.Equal(t, "true", saved.Dimension(DimensionModerationFlagged))
+	assert.Equal(t, "chat", saved.Dimension("feature"))
+	assert.Zero(t, saved.Cost)
+}
@@ -0,0 +1,36 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceModeration(t *testing.T) {
+	pricing := NewPricingRegistry()
+	pricing.Set(ProviderAzure, "content-safety", ModelPrice{PerRequest: 0.00075})
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithPricing(pricing))
+
+	flagged, err := client.TraceModeration(context.Background(), ProviderAzure, "content-safety", func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+	require.NoError(t, err)
+	assert.False(t, flagged)
+
+	_, err = client.TraceModeration(context.Background(), ProviderAzure, "content-safety", func(ctx context.Context) (bool, error) {
+		return false, errors.New("401 unauthorized")
+	})
+	assert.EqualError(t, err, "401 unauthorized")
+
+	require.Len(t, storage.SaveCalls, 2)
+	succeeded, failed := storage.SaveCalls[0].Request, storage.SaveCalls[1].Request
+	assert.Equal(t, RequestTypeModeration, succeeded.RequestType)
+	assert.Equal(t, "false", succeeded.Dimension(DimensionModerationFlagged))
+	assert.InDelta(t, 0.00075, succeeded.Cost, 1e-12)
+	assert.Equal(t, ErrorTypeAuthentication, failed.ErrorType)
+	assert.Empty(t, failed.Dimension(DimensionModerationFlagged))
+}
@@ -0,0 +1,223 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAICreateChatCompletionFunc represents the signature of OpenAI's CreateChatCompletion method
+type OpenAICreateChatCompletionFunc func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+
+// TraceOpenAIRequest wraps OpenAI's CreateChatCompletion and automatically tracks token usage
+func (c *Client) TraceOpenAIRequest(ctx context.Context, request openai.ChatCompletionRequest, createChatCompletion OpenAICreateChatCompletionFunc) (openai.ChatCompletionResponse, error) {
+	if createChatCompletion == nil {
+		return openai.ChatCompletionResponse{}, fmt.Errorf("createChatCompletion function cannot be nil")
+	}
+	if err := c.admit(ctx, ProviderOpenAI, request.Model); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	release, queueWait, err := c.acquire(ctx, ProviderOpenAI, request.Model)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	tracked := c.startRequest(ctx, &Request{
+		Provider:     ProviderOpenAI,
+		Model:        request.Model,
+		MessageCount: len(request.Messages),
+		QueueWait:    queueWait,
+	})
+	startTime := time.Now()
+
+	// Make the actual OpenAI API call using the provided function
+	callCtx, headers := withResponseHeaders(ctx)
+	response, err := createChatCompletion(callCtx, request)
+	release()
+
+	// Track the request - even if it failed
+	tracked.RequestedAt = startTime
+	tracked.Latency = time.Since(startTime)
+	if err == nil {
+		tracked.SetUsage(OpenAIUsage(response.Usage))
+	}
+	applyOpenAIMetadata(tracked, response, err)
+	if c.captureGenerationParams {
+		tracked.Params = openAIGenerationParams(request)
+	}
+	if c.capturePayloadSizes {
+		tracked.RequestBytes = jsonSize(request)
+		if err == nil {
+			tracked.ResponseBytes = jsonSize(response)
+		}
+	}
+	if c.capturePayloads {
+		tracked.RequestPayload = jsonString(request)
+	}
+
+	// Failed calls have no response headers; they are only seen through a
+	// ResponseHeaderTransport
+	header := response.Header()
+	if header == nil {
+		header = headers.get()
+	}
+	c.recordRateLimitHeaders(ProviderOpenAI, header).apply(tracked)
+
+	// Extract tracking context from context if available
+	trackingContext := GetDimensionsFromContext(ctx)
+
+	c.track(ctx, tracked, err, trackingContext)
+
+	// Return the original response and error
+	return response, err
+}
+
+// OpenAIUsage maps an OpenAI usage report to Usage
+func OpenAIUsage(usage openai.Usage) Usage {
+	normalized := Usage{
+		InputTokens:  int64(usage.PromptTokens),
+		OutputTokens: int64(usage.CompletionTokens),
+	}
+	if usage.PromptTokensDetails != nil {
+		normalized.CachedInputTokens = int64(usage.PromptTokensDetails.CachedTokens)
+	}
+	if usage.CompletionTokensDetails != nil {
+		normalized.ReasoningTokens = int64(usage.CompletionTokensDetails.ReasoningTokens)
+	}
+	return normalized
+}
+
+// openAIGenerationParams extracts sampling parameters from an OpenAI chat completion request
+func openAIGenerationParams(request openai.ChatCompletionRequest) GenerationParams {
+	params := GenerationParams{
+		ToolCount: len(request.Tools) + len(request.Functions),
+	}
+
+	// go-openai omits zero values, so zero means the parameter was not sent
+	if request.Temperature != 0 {
+		temperature := float64(request.Temperature)
+		params.Temperature = &temperature
+	}
+	if request.TopP != 0 {
+		topP := float64(request.TopP)
+		params.TopP = &topP
+	}
+	if request.MaxCompletionTokens > 0 {
+		maxTokens := request.MaxCompletionTokens
+		params.MaxTokens = &maxTokens
+	} else if request.MaxTokens > 0 {
+		maxTokens := request.MaxTokens
+		params.MaxTokens = &maxTokens
+	}
+	if request.ResponseFormat != nil {
+		params.ResponseFormat = string(request.ResponseFormat.Type)
+	}
+
+	return params
+}
+
+// applyOpenAIMetadata copies the provider request ID, finish reason, status code and error details from an OpenAI call
+func applyOpenAIMetadata(request *Request, response openai.ChatCompletionResponse, err error) {
+	if header := response.Header(); header != nil {
+		request.ProviderRequestID = header.Get("X-Request-Id")
+	}
+	if len(response.Choices) > 0 {
+		request.FinishReason = normalizeFinishReason(string(response.Choices[0].FinishReason))
+	}
+
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		request.StatusCode = apiErr.HTTPStatusCode
+		request.ProviderErrorType = apiErr.Type
+		if apiErr.Code != nil {
+			request.ProviderErrorCode = fmt.Sprintf("%v", apiErr.Code)
+		}
+	case errors.As(err, &reqErr):
+		request.StatusCode = reqErr.HTTPStatusCode
+	}
+}
+
+// OpenAIReplay returns a ReplayFunc that decodes captured OpenAI chat completion payloads
+// and re-issues them through client.TraceOpenAIRequest. A non-empty model replaces the
+// captured one.
+func OpenAIReplay(client *Client, createChatCompletion OpenAICreateChatCompletionFunc, model string) ReplayFunc {
+	return func(ctx context.Context, original *Request) error {
+		var request openai.ChatCompletionRequest
+		if err := json.Unmarshal([]byte(original.RequestPayload), &request); err != nil {
+			return fmt.Errorf("failed to decode OpenAI payload: %w", err)
+		}
+		if model != "" {
+			request.Model = model
+		}
+		_, err := client.TraceOpenAIRequest(ctx, request, createChatCompletion)
+		return err
+	}
+}
+
+// OpenAIModerationsFunc represents the signature of OpenAI's Moderations method
+type OpenAIModerationsFunc func(ctx context.Context, request openai.ModerationRequest) (openai.ModerationResponse, error)
+
+// TraceOpenAIModeration wraps OpenAI's Moderations and tracks the call as a
+// RequestTypeModeration request, recording whether any result was flagged in the
+// moderation_flagged dimension. The moderations endpoint is free and reports no usage, so
+// the request costs nothing unless the model has a PerRequest price.
+func (c *Client) TraceOpenAIModeration(ctx context.Context, request openai.ModerationRequest, moderations OpenAIModerationsFunc) (openai.ModerationResponse, error) {
+	if moderations == nil {
+		return openai.ModerationResponse{}, fmt.Errorf("moderations function cannot be nil")
+	}
+	if err := c.admit(ctx, ProviderOpenAI, request.Model); err != nil {
+		return openai.ModerationResponse{}, err
+	}
+	release, queueWait, err := c.acquire(ctx, ProviderOpenAI, request.Model)
+	if err != nil {
+		return openai.ModerationResponse{}, err
+	}
+
+	tracked := c.startRequest(ctx, &Request{
+		Provider:    ProviderOpenAI,
+		Model:       request.Model,
+		RequestType: RequestTypeModeration,
+		QueueWait:   queueWait,
+	})
+	startTime := time.Now()
+
+	response, err := moderations(ctx, request)
+	release()
+
+	tracked.RequestedAt = startTime
+	tracked.Latency = time.Since(startTime)
+	applyOpenAIMetadata(tracked, openai.ChatCompletionResponse{}, err)
+	trackingContext := GetDimensionsFromContext(ctx)
+	if err == nil {
+		// The response names the model used when the request left it to the default
+		if response.Model != "" {
+			tracked.Model = response.Model
+		}
+		if header := response.Header(); header != nil {
+			tracked.ProviderRequestID = header.Get("X-Request-Id")
+		}
+		flagged := false
+		for _, result := range response.Results {
+			flagged = flagged || result.Flagged
+		}
+		trackingContext[DimensionModerationFlagged] = flagged
+	}
+	if c.capturePayloadSizes {
+		tracked.RequestBytes = jsonSize(request)
+		if err == nil {
+			tracked.ResponseBytes = jsonSize(response)
+		}
+	}
+
+	c.track(ctx, tracked, err, trackingContext)
+
+	return response, err
+}
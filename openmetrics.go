@@ -0,0 +1,169 @@
+package llmtracer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// OpenMetricsContentType is the content type of the text written by WriteOpenMetrics
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// OpenMetricsOptions configures Client.WriteOpenMetrics
+type OpenMetricsOptions struct {
+	// Filter selects the requests counted, e.g. a time window; nil counts every request
+	Filter *RequestFilter
+	// Dimensions are dimension keys added as labels next to provider and model, e.g.
+	// "feature". Requests without a dimension have an empty label.
+	Dimensions []string
+	// Prefix starts every metric name; "llmtracer" when empty
+	Prefix string
+}
+
+// openMetricsSeries is one usage metric written by WriteOpenMetrics
+type openMetricsSeries struct {
+	name, kind, help string
+	samples          func(result *AggregateResult) []openMetricsSample
+}
+
+type openMetricsSample struct {
+	suffix string
+	value  interface{}
+}
+
+// openMetricsUsage lists the usage metrics in the order they are written
+var openMetricsUsage = []openMetricsSeries{
+	{"usage_requests", "counter", "Requests tracked.", func(r *AggregateResult) []openMetricsSample {
+		return []openMetricsSample{{"_total", r.TotalRequests}}
+	}},
+	{"usage_errors", "counter", "Requests that failed.", func(r *AggregateResult) []openMetricsSample {
+		return []openMetricsSample{{"_total", r.ErrorCount}}
+	}},
+	{"usage_input_tokens", "counter", "Input tokens used.", func(r *AggregateResult) []openMetricsSample {
+		return []openMetricsSample{{"_total", r.TotalInputTokens}}
+	}},
+	{"usage_output_tokens", "counter", "Output tokens used.", func(r *AggregateResult) []openMetricsSample {
+		return []openMetricsSample{{"_total", r.TotalOutputTokens}}
+	}},
+	{"usage_cost_usd", "counter", "Cost in USD.", func(r *AggregateResult) []openMetricsSample {
+		return []openMetricsSample{{"_total", r.TotalCost}}
+	}},
+	{"usage_latency_seconds", "summary", "Provider latency of the requests.", func(r *AggregateResult) []openMetricsSample {
+		return []openMetricsSample{
+			{"_sum", r.AvgLatency.Seconds() * float64(r.TotalRequests)},
+			{"_count", r.TotalRequests},
+		}
+	}},
+}
+
+// WriteOpenMetrics renders the usage aggregated per provider, model and the requested
+// dimensions in the OpenMetrics text format: requests, errors, input and output tokens and
+// cost as counters, and latency as a summary. It needs no server, so a cron job can write
+// the snapshot to a file for a node exporter's textfile collector or push it to a
+// Pushgateway:
+//
+//	var buf bytes.Buffer
+//	err := tracer.WriteOpenMetrics(ctx, &buf, llmtracer.OpenMetricsOptions{Dimensions: []string{"feature"}})
+//
+// Values are totals over the requests matching the filter, so they only behave as
+// counters while the filter's start stays fixed and retention keeps every request. The
+// aggregation is pushed down to storage, and ErrAggregateNotSupported is returned for
+// adapters that cannot aggregate.
+func (c *Client) WriteOpenMetrics(ctx context.Context, w io.Writer, opts OpenMetricsOptions) error {
+	prefix := opts.Prefix
+	if prefix == "" {
+		prefix = "llmtracer"
+	}
+	groupBy := []string{"provider", "model"}
+	for _, key := range opts.Dimensions {
+		groupBy = append(groupBy, GroupByDimension(key))
+	}
+
+	results, err := c.storage.Aggregate(ctx, groupBy, opts.Filter)
+	if err != nil {
+		return err
+	}
+
+	return writeUsageMetrics(w, results, opts.Dimensions, prefix, true)
+}
+
+// writeUsageMetrics renders aggregates grouped by provider, model and the given dimensions
+// as usage metrics, in the OpenMetrics text format or the Prometheus text format
+func writeUsageMetrics(w io.Writer, results []*AggregateResult, dimensions []string, prefix string, openMetrics bool) error {
+	labels := []string{"provider", "model"}
+	for _, key := range dimensions {
+		labels = append(labels, openMetricsName(key))
+	}
+	labelSets := make([]string, len(results))
+	for i, result := range results {
+		values := []string{string(result.Provider), result.Model}
+		for _, key := range dimensions {
+			values = append(values, result.Dimension(key))
+		}
+		labelSets[i] = openMetricsLabels(labels, values)
+	}
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return labelSets[order[i]] < labelSets[order[j]]
+	})
+
+	out := bufio.NewWriter(w)
+	for _, series := range openMetricsUsage {
+		name := prefix + "_" + series.name
+		// The Prometheus text format names counter families after their samples
+		family := name
+		if !openMetrics && series.kind == "counter" {
+			family += "_total"
+		}
+		fmt.Fprintf(out, "# TYPE %s %s\n# HELP %s %s\n", family, series.kind, family, series.help)
+		for _, i := range order {
+			for _, sample := range series.samples(results[i]) {
+				fmt.Fprintf(out, "%s%s%s %v\n", name, sample.suffix, labelSets[i], sample.value)
+			}
+		}
+	}
+	if openMetrics {
+		fmt.Fprint(out, "# EOF\n")
+	}
+	return out.Flush()
+}
+
+// openMetricsLabels formats a label set, escaping the values
+func openMetricsLabels(names, values []string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(openMetricsEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var openMetricsEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// openMetricsName replaces the characters not allowed in metric and label names with
+// underscores
+func openMetricsName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', i > 0 && r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package llmtracer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	var groupedBy []string
+	storage := &MockStorageAdapter{
+		AggregateFunc: func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+			groupedBy = groupBy
+			return []*AggregateResult{
+				{Provider: ProviderOpenAI, Model: "gpt-4o", TotalRequests: 4, ErrorCount: 1, TotalInputTokens: 400, TotalOutputTokens: 100, TotalCost: 0.25, AvgLatency: 500 * time.Millisecond,
+					Dimensions: []DimensionTag{{Key: DimensionFeature, Value: `say "hi"`}}},
+				{Provider: ProviderAnthropic, Model: "claude-3-haiku", TotalRequests: 2, TotalInputTokens: 20, TotalOutputTokens: 10, TotalCost: 0.01, AvgLatency: time.Second},
+			}, nil
+		},
+	}
+
+	var buf bytes.Buffer
+	err := NewClient(storage).WriteOpenMetrics(context.Background(), &buf, OpenMetricsOptions{Dimensions: []string{DimensionFeature, "team-name"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"provider", "model", GroupByDimension(DimensionFeature), GroupByDimension("team-name")}, groupedBy)
+
+	out := buf.String()
+	anthropic := `{provider="anthropic",model="claude-3-haiku",feature="",team_name=""}`
+	openai := `{provider="openai",model="gpt-4o",feature="say \"hi\"",team_name=""}`
+	for _, line := range []string{
+		"# TYPE llmtracer_usage_requests counter",
+		"llmtracer_usage_requests_total" + anthropic + " 2",
+		"llmtracer_usage_requests_total" + openai + " 4",
+		"llmtracer_usage_errors_total" + openai + " 1",
+		"llmtracer_usage_input_tokens_total" + openai + " 400",
+		"llmtracer_usage_output_tokens_total" + openai + " 100",
+		"llmtracer_usage_cost_usd_total" + openai + " 0.25",
+		"# TYPE llmtracer_usage_latency_seconds summary",
+		"llmtracer_usage_latency_seconds_sum" + openai + " 2",
+		"llmtracer_usage_latency_seconds_count" + openai + " 4",
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+	assert.Less(t, strings.Index(out, anthropic), strings.Index(out, openai), "series are sorted by label set")
+	assert.True(t, strings.HasSuffix(out, "\n# EOF\n"))
+
+	t.Run("Prefix", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := NewClient(storage).WriteOpenMetrics(context.Background(), &buf, OpenMetricsOptions{Prefix: "acme_llm"})
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), `acme_llm_usage_requests_total{provider="openai",model="gpt-4o"} 4`)
+	})
+
+	t.Run("Aggregate errors are returned", func(t *testing.T) {
+		storage := &MockStorageAdapter{
+			AggregateFunc: func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+				return nil, ErrAggregateNotSupported
+			},
+		}
+		var buf bytes.Buffer
+		err := NewClient(storage).WriteOpenMetrics(context.Background(), &buf, OpenMetricsOptions{})
+		assert.ErrorIs(t, err, ErrAggregateNotSupported)
+		assert.Empty(t, buf.String())
+	})
+}
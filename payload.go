@@ -0,0 +1,21 @@
+package llmtracer
+
+import "encoding/json"
+
+// jsonString returns v encoded as JSON, or "" if it cannot be encoded
+func jsonString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// jsonSize returns the size in bytes of v encoded as JSON, or 0 if it cannot be encoded
+func jsonSize(v interface{}) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
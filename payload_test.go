@@ -0,0 +1,52 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadSizeCapture(t *testing.T) {
+	request := openai.ChatCompletionRequest{
+		Model: "gpt-4o-mini",
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "You are helpful."},
+			{Role: openai.ChatMessageRoleUser, Content: "Hello"},
+		},
+	}
+	mockFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+		return openai.ChatCompletionResponse{ID: "chatcmpl-1"}, nil
+	}
+
+	t.Run("message count is always recorded", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		_, err := client.TraceOpenAIRequest(context.Background(), request, mockFunc)
+		assert.NoError(t, err)
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, 2, saved.MessageCount)
+		assert.Zero(t, saved.RequestBytes)
+		assert.Zero(t, saved.ResponseBytes)
+	})
+
+	t.Run("sizes recorded when enabled", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage, WithPayloadSizeCapture(true))
+
+		_, err := client.TraceOpenAIRequest(context.Background(), request, mockFunc)
+		assert.NoError(t, err)
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, jsonSize(request), saved.RequestBytes)
+		assert.Greater(t, saved.ResponseBytes, 0)
+	})
+}
@@ -0,0 +1,146 @@
+package llmtracer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	InputPerMillion  float64 `yaml:"input_per_million" json:"input_per_million"`
+	OutputPerMillion float64 `yaml:"output_per_million" json:"output_per_million"`
+	// PerRequest is a flat price per call, for services billed by the call rather than by
+	// the token, such as moderation endpoints
+	PerRequest float64 `yaml:"per_request" json:"per_request,omitempty"`
+	// Regions holds the prices of regions that differ from this one, keyed by region name
+	// as recorded in the region dimension, e.g. for Azure or Bedrock deployments
+	Regions map[string]ModelPrice `yaml:"regions" json:"regions,omitempty"`
+}
+
+// InRegion returns the price in region: its regional price when one is set, regardless of
+// case, and otherwise this price
+func (p ModelPrice) InRegion(region string) ModelPrice {
+	if region == "" || len(p.Regions) == 0 {
+		return p
+	}
+	if price, ok := p.Regions[region]; ok {
+		return price
+	}
+	for name, price := range p.Regions {
+		if strings.EqualFold(name, region) {
+			return price
+		}
+	}
+	return p
+}
+
+// Cost returns the price in USD of a call with the given token counts
+func (p ModelPrice) Cost(inputTokens, outputTokens int64) float64 {
+	return p.PerRequest + (float64(inputTokens)*p.InputPerMillion+float64(outputTokens)*p.OutputPerMillion)/1_000_000
+}
+
+// PricingRegistry maps provider models to prices. Model names ending in "*" match any
+// model with that prefix (e.g. "gpt-4o-2024-*"); exact names take precedence, then the
+// longest matching prefix. It is safe for concurrent use.
+type PricingRegistry struct {
+	mu     sync.RWMutex
+	prices map[Provider]map[string]ModelPrice
+}
+
+// NewPricingRegistry creates an empty pricing registry
+func NewPricingRegistry() *PricingRegistry {
+	return &PricingRegistry{
+		prices: make(map[Provider]map[string]ModelPrice),
+	}
+}
+
+// LoadPricingFile reads a YAML (or JSON) pricing file keyed by provider and model:
+//
+//	openai:
+//	  gpt-4o:
+//	    input_per_million: 2.50
+//	    output_per_million: 10.00
+//	    regions:
+//	      swedencentral:
+//	        input_per_million: 2.75
+//	        output_per_million: 11.00
+func LoadPricingFile(path string) (*PricingRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing file: %w", err)
+	}
+
+	var prices map[Provider]map[string]ModelPrice
+	if err := yaml.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("failed to parse pricing file: %w", err)
+	}
+
+	registry := NewPricingRegistry()
+	for provider, models := range prices {
+		for model, price := range models {
+			registry.Set(provider, model, price)
+		}
+	}
+	return registry, nil
+}
+
+// Set registers the price of a provider model
+func (r *PricingRegistry) Set(provider Provider, model string, price ModelPrice) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.prices[provider] == nil {
+		r.prices[provider] = make(map[string]ModelPrice)
+	}
+	r.prices[provider][model] = price
+}
+
+// Lookup returns the price registered for a provider model
+func (r *PricingRegistry) Lookup(provider Provider, model string) (ModelPrice, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	models := r.prices[provider]
+	if price, ok := models[model]; ok {
+		return price, true
+	}
+
+	// Fall back to the longest matching wildcard
+	var patterns []string
+	for pattern := range models {
+		if strings.HasSuffix(pattern, "*") && strings.HasPrefix(model, strings.TrimSuffix(pattern, "*")) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return ModelPrice{}, false
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return len(patterns[i]) > len(patterns[j])
+	})
+	return models[patterns[0]], true
+}
+
+// Cost returns the price in USD of a request, or false when the model has no price
+func (r *PricingRegistry) Cost(provider Provider, model string, inputTokens, outputTokens int64) (float64, bool) {
+	price, ok := r.Lookup(provider, model)
+	if !ok {
+		return 0, false
+	}
+	return price.Cost(inputTokens, outputTokens), true
+}
+
+// RequestCost returns the price in USD of a tracked request at the price of its region,
+// or false when the model has no price
+func (r *PricingRegistry) RequestCost(request *Request) (float64, bool) {
+	price, ok := r.Lookup(request.Provider, request.Model)
+	if !ok {
+		return 0, false
+	}
+	return price.InRegion(request.Region()).Cost(request.InputTokens, request.OutputTokens), true
+}
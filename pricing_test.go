@@ -0,0 +1,78 @@
+package llmtracer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPricingRegistry(t *testing.T) {
+	registry := NewPricingRegistry()
+	registry.Set(ProviderOpenAI, "gpt-4o", ModelPrice{InputPerMillion: 2.5, OutputPerMillion: 10})
+	registry.Set(ProviderOpenAI, "gpt-4o-*", ModelPrice{InputPerMillion: 5, OutputPerMillion: 15})
+	registry.Set(ProviderOpenAI, "gpt-4o-mini*", ModelPrice{InputPerMillion: 0.15, OutputPerMillion: 0.6})
+
+	t.Run("exact match", func(t *testing.T) {
+		cost, ok := registry.Cost(ProviderOpenAI, "gpt-4o", 1_000_000, 1_000_000)
+		assert.True(t, ok)
+		assert.InDelta(t, 12.5, cost, 1e-9)
+	})
+
+	t.Run("longest wildcard wins", func(t *testing.T) {
+		price, ok := registry.Lookup(ProviderOpenAI, "gpt-4o-mini-2024-07-18")
+		assert.True(t, ok)
+		assert.Equal(t, 0.15, price.InputPerMillion)
+
+		price, ok = registry.Lookup(ProviderOpenAI, "gpt-4o-2024-08-06")
+		assert.True(t, ok)
+		assert.Equal(t, 5.0, price.InputPerMillion)
+	})
+
+	t.Run("unknown model", func(t *testing.T) {
+		_, ok := registry.Cost(ProviderOpenAI, "gpt-3.5-turbo", 10, 10)
+		assert.False(t, ok)
+
+		_, ok = registry.Cost(ProviderAnthropic, "gpt-4o", 10, 10)
+		assert.False(t, ok)
+	})
+}
+
+func TestLoadPricingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+openai:
+  gpt-4o:
+    input_per_million: 2.5
+    output_per_million: 10
+    regions:
+      westeurope:
+        input_per_million: 2.75
+        output_per_million: 11
+anthropic:
+  claude-3-5-sonnet-*:
+    input_per_million: 3
+    output_per_million: 15
+`), 0o600))
+
+	registry, err := LoadPricingFile(path)
+	require.NoError(t, err)
+
+	cost, ok := registry.Cost(ProviderAnthropic, "claude-3-5-sonnet-20241022", 1000, 1000)
+	assert.True(t, ok)
+	assert.InDelta(t, 0.018, cost, 1e-9)
+
+	cost, ok = registry.RequestCost(&Request{
+		Provider:    ProviderOpenAI,
+		Model:       "gpt-4o",
+		InputTokens: 1_000_000,
+		Dimensions:  []DimensionTag{{Key: DimensionRegion, Value: "westeurope"}},
+	})
+	assert.True(t, ok)
+	assert.InDelta(t, 2.75, cost, 1e-9)
+
+	_, err = LoadPricingFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+//go:build !llmtracer_no_mistral
+
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mistral "github.com/gage-technologies/mistral-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMistralProviderMetadata(t *testing.T) {
+	tests := []struct {
+		name               string
+		response           *mistral.ChatCompletionResponse
+		err                error
+		expectedStatus     int
+		expectedProviderID string
+	}{
+		{
+			name:           "completion ID is not a request ID",
+			response:       &mistral.ChatCompletionResponse{ID: "cmpl-123"},
+			expectedStatus: 200,
+		},
+		{
+			name:           "plain HTTP error status is parsed",
+			err:            errors.New("(HTTP Error 429) {\"message\":\"Requests rate limit exceeded\"}"),
+			expectedStatus: 429,
+		},
+		{
+			name:               "API error status and request ID",
+			err:                mistral.NewMistralAPIError("service unavailable", 503, map[string][]string{"X-Request-Id": {"mr-1"}}),
+			expectedStatus:     503,
+			expectedProviderID: "mr-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &MockStorageAdapter{}
+			client := NewClient(storage)
+
+			mockFunc := func(model string, messages []mistral.ChatMessage, params *mistral.ChatRequestParams) (*mistral.ChatCompletionResponse, error) {
+				return tt.response, tt.err
+			}
+
+			_, _ = client.TraceMistralRequest(context.Background(), "mistral-small", nil, nil, mockFunc)
+
+			require.Len(t, storage.SaveCalls, 1)
+			assert.Equal(t, tt.expectedStatus, storage.SaveCalls[0].Request.StatusCode)
+			assert.Equal(t, tt.expectedProviderID, storage.SaveCalls[0].Request.ProviderRequestID)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIProviderMetadata(t *testing.T) {
+	t.Run("captures API error code and status", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		apiErr := &openai.APIError{
+			Code:           "context_length_exceeded",
+			Type:           "invalid_request_error",
+			Message:        "maximum context length exceeded",
+			HTTPStatusCode: 400,
+		}
+		mockFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			return openai.ChatCompletionResponse{}, fmt.Errorf("wrapped: %w", apiErr)
+		}
+
+		_, err := client.TraceOpenAIRequest(context.Background(), openai.ChatCompletionRequest{Model: "gpt-4"}, mockFunc)
+		assert.Error(t, err)
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, 400, saved.StatusCode)
+		assert.Equal(t, "context_length_exceeded", saved.ProviderErrorCode)
+		assert.Equal(t, "invalid_request_error", saved.ProviderErrorType)
+	})
+
+	t.Run("captures request ID header", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		response := openai.ChatCompletionResponse{}
+		response.SetHeader(map[string][]string{"X-Request-Id": {"req_abc123"}})
+		mockFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			return response, nil
+		}
+
+		_, err := client.TraceOpenAIRequest(context.Background(), openai.ChatCompletionRequest{Model: "gpt-4"}, mockFunc)
+		assert.NoError(t, err)
+
+		require.Len(t, storage.SaveCalls, 1)
+		assert.Equal(t, "req_abc123", storage.SaveCalls[0].Request.ProviderRequestID)
+		assert.Equal(t, 200, storage.SaveCalls[0].Request.StatusCode)
+	})
+}
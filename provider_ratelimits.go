@@ -0,0 +1,219 @@
+package llmtracer
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProviderRateLimitStatus is the rate-limit state a provider reported on its most recent
+// response. Limits and remaining counts are -1 when the provider did not report them, and
+// reset times are zero.
+type ProviderRateLimitStatus struct {
+	Provider          Provider
+	RequestLimit      int
+	RemainingRequests int
+	RequestsResetAt   time.Time
+	TokenLimit        int
+	RemainingTokens   int
+	TokensResetAt     time.Time
+	UpdatedAt         time.Time
+}
+
+// apply records the remaining counts and reset times on the request. It is a no-op on a
+// nil status so callers can chain it after recordRateLimitHeaders.
+func (s *ProviderRateLimitStatus) apply(request *Request) {
+	if s == nil {
+		return
+	}
+	if s.RemainingRequests >= 0 {
+		remaining := s.RemainingRequests
+		request.RateLimits.RemainingRequests = &remaining
+	}
+	if s.RemainingTokens >= 0 {
+		remaining := s.RemainingTokens
+		request.RateLimits.RemainingTokens = &remaining
+	}
+	if !s.RequestsResetAt.IsZero() {
+		reset := s.RequestsResetAt.UTC()
+		request.RateLimits.RequestsResetAt = &reset
+	}
+	if !s.TokensResetAt.IsZero() {
+		reset := s.TokensResetAt.UTC()
+		request.RateLimits.TokensResetAt = &reset
+	}
+}
+
+// rateLimitHeaderNames lists the headers a provider uses for each rate-limit value
+type rateLimitHeaderNames struct {
+	requestLimit, remainingRequests, resetRequests string
+	tokenLimit, remainingTokens, resetTokens       string
+	// relativeReset is set when reset values are durations ("6m0s") rather than timestamps
+	relativeReset bool
+}
+
+var rateLimitHeaders = map[Provider]rateLimitHeaderNames{
+	ProviderOpenAI: {
+		requestLimit:      "X-Ratelimit-Limit-Requests",
+		remainingRequests: "X-Ratelimit-Remaining-Requests",
+		resetRequests:     "X-Ratelimit-Reset-Requests",
+		tokenLimit:        "X-Ratelimit-Limit-Tokens",
+		remainingTokens:   "X-Ratelimit-Remaining-Tokens",
+		resetTokens:       "X-Ratelimit-Reset-Tokens",
+		relativeReset:     true,
+	},
+	ProviderAnthropic: {
+		requestLimit:      "Anthropic-Ratelimit-Requests-Limit",
+		remainingRequests: "Anthropic-Ratelimit-Requests-Remaining",
+		resetRequests:     "Anthropic-Ratelimit-Requests-Reset",
+		tokenLimit:        "Anthropic-Ratelimit-Tokens-Limit",
+		remainingTokens:   "Anthropic-Ratelimit-Tokens-Remaining",
+		resetTokens:       "Anthropic-Ratelimit-Tokens-Reset",
+	},
+}
+
+// parseRateLimitHeaders reads the rate-limit headers of an OpenAI or Anthropic response.
+// It returns nil when the provider is not supported or reported none.
+func parseRateLimitHeaders(provider Provider, header http.Header, now time.Time) *ProviderRateLimitStatus {
+	names, ok := rateLimitHeaders[provider]
+	if !ok || header == nil {
+		return nil
+	}
+
+	found := false
+	count := func(name string) int {
+		value, err := strconv.Atoi(header.Get(name))
+		if err != nil {
+			return -1
+		}
+		found = true
+		return value
+	}
+	reset := func(name string) time.Time {
+		value := header.Get(name)
+		if value == "" {
+			return time.Time{}
+		}
+		if names.relativeReset {
+			if d, err := time.ParseDuration(value); err == nil {
+				found = true
+				return now.Add(d)
+			}
+			return time.Time{}
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			found = true
+			return t
+		}
+		return time.Time{}
+	}
+
+	status := &ProviderRateLimitStatus{
+		Provider:          provider,
+		RequestLimit:      count(names.requestLimit),
+		RemainingRequests: count(names.remainingRequests),
+		RequestsResetAt:   reset(names.resetRequests),
+		TokenLimit:        count(names.tokenLimit),
+		RemainingTokens:   count(names.remainingTokens),
+		TokensResetAt:     reset(names.resetTokens),
+		UpdatedAt:         now,
+	}
+	if !found {
+		return nil
+	}
+	return status
+}
+
+// providerRateLimits holds the latest rate-limit status per provider
+type providerRateLimits struct {
+	mu     sync.RWMutex
+	latest map[Provider]ProviderRateLimitStatus
+}
+
+// recordRateLimitHeaders parses the rate-limit headers of a provider response and stores
+// them as the provider's latest status. It returns nil when there were none.
+func (c *Client) recordRateLimitHeaders(provider Provider, header http.Header) *ProviderRateLimitStatus {
+	status := parseRateLimitHeaders(provider, header, time.Now())
+	if status == nil {
+		return nil
+	}
+
+	c.rateLimits.mu.Lock()
+	defer c.rateLimits.mu.Unlock()
+	if c.rateLimits.latest == nil {
+		c.rateLimits.latest = make(map[Provider]ProviderRateLimitStatus)
+	}
+	// Async or concurrent calls can finish out of order; keep the newest
+	if previous, ok := c.rateLimits.latest[provider]; !ok || !status.UpdatedAt.Before(previous.UpdatedAt) {
+		c.rateLimits.latest[provider] = *status
+	}
+	return status
+}
+
+// responseHeaderKey carries the responseHeaders a ResponseHeaderTransport fills
+const responseHeaderKey contextKey = "llm_response_headers"
+
+// responseHeaders holds the headers of the last provider response of a call
+type responseHeaders struct {
+	mu     sync.Mutex
+	header http.Header
+}
+
+// withResponseHeaders returns a context in which a ResponseHeaderTransport records the
+// headers of provider responses into the returned holder
+func withResponseHeaders(ctx context.Context) (context.Context, *responseHeaders) {
+	headers := &responseHeaders{}
+	return context.WithValue(ctx, responseHeaderKey, headers), headers
+}
+
+// get returns the recorded headers, or nil when no response was seen
+func (h *responseHeaders) get() http.Header {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.header
+}
+
+// ResponseHeaderTransport is an http.RoundTripper for the HTTP client of the OpenAI SDK.
+// go-openai's errors don't carry the response headers, so TraceOpenAIRequest and
+// TraceOpenAIStream can only record the rate-limit headers of failed calls, such as 429s,
+// when the SDK sends its requests through this transport. It tracks nothing
+// itself; use TracingTransport to track calls at the HTTP level instead.
+type ResponseHeaderTransport struct {
+	base http.RoundTripper
+}
+
+// NewResponseHeaderTransport wraps base (http.DefaultTransport when nil)
+func NewResponseHeaderTransport(base http.RoundTripper) *ResponseHeaderTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &ResponseHeaderTransport{base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *ResponseHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if headers, ok := req.Context().Value(responseHeaderKey).(*responseHeaders); ok {
+		headers.mu.Lock()
+		headers.header = resp.Header
+		headers.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// GetRateLimitStatus returns the rate-limit status reported on the most recent OpenAI or
+// Anthropic response seen by this client, and false if none has been seen
+func (c *Client) GetRateLimitStatus(provider Provider) (*ProviderRateLimitStatus, bool) {
+	c.rateLimits.mu.RLock()
+	defer c.rateLimits.mu.RUnlock()
+	status, ok := c.rateLimits.latest[provider]
+	if !ok {
+		return nil, false
+	}
+	return &status, true
+}
@@ -0,0 +1,64 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIRateLimitHeadersOnErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Ratelimit-Remaining-Tokens", "0")
+		w.Header().Set("X-Ratelimit-Reset-Tokens", "30s")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"message":"Rate limit reached","type":"tokens"}}`)
+	}))
+	defer server.Close()
+
+	config := openai.DefaultConfig("test")
+	config.BaseURL = server.URL + "/v1"
+	config.HTTPClient = &http.Client{Transport: NewResponseHeaderTransport(nil)}
+	openaiClient := openai.NewClientWithConfig(config)
+	request := openai.ChatCompletionRequest{Model: "gpt-4o", Messages: []openai.ChatCompletionMessage{{Role: "user", Content: "hi"}}}
+
+	t.Run("wrapper", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		_, err := client.TraceOpenAIRequest(context.Background(), request, openaiClient.CreateChatCompletion)
+		require.Error(t, err)
+
+		status, ok := client.GetRateLimitStatus(ProviderOpenAI)
+		require.True(t, ok)
+		assert.Equal(t, 0, status.RemainingTokens)
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, http.StatusTooManyRequests, saved.StatusCode)
+		require.NotNil(t, saved.RateLimits.RemainingTokens)
+		assert.Equal(t, 0, *saved.RateLimits.RemainingTokens)
+		assert.NotNil(t, saved.RateLimits.TokensResetAt)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		_, err := client.TraceOpenAIStream(context.Background(), request, openaiClient.CreateChatCompletionStream)
+		require.Error(t, err)
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		require.NotNil(t, saved.RateLimits.RemainingTokens)
+		assert.Equal(t, 0, *saved.RateLimits.RemainingTokens)
+	})
+}
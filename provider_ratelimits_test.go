@@ -0,0 +1,123 @@
+//go:build !llmtracer_no_anthropic
+
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderRateLimits(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("OpenAI relative resets", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("x-ratelimit-limit-requests", "500")
+		header.Set("x-ratelimit-remaining-requests", "499")
+		header.Set("x-ratelimit-reset-requests", "120ms")
+		header.Set("x-ratelimit-limit-tokens", "30000")
+		header.Set("x-ratelimit-remaining-tokens", "0")
+		header.Set("x-ratelimit-reset-tokens", "6m0s")
+
+		status := parseRateLimitHeaders(ProviderOpenAI, header, now)
+		require.NotNil(t, status)
+		assert.Equal(t, 500, status.RequestLimit)
+		assert.Equal(t, 499, status.RemainingRequests)
+		assert.Equal(t, 0, status.RemainingTokens)
+		assert.Equal(t, now.Add(120*time.Millisecond), status.RequestsResetAt)
+		assert.Equal(t, now.Add(6*time.Minute), status.TokensResetAt)
+	})
+
+	t.Run("Anthropic absolute resets", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("anthropic-ratelimit-requests-remaining", "49")
+		header.Set("anthropic-ratelimit-tokens-reset", "2024-01-02T03:05:00Z")
+
+		status := parseRateLimitHeaders(ProviderAnthropic, header, now)
+		require.NotNil(t, status)
+		assert.Equal(t, 49, status.RemainingRequests)
+		assert.Equal(t, -1, status.RequestLimit, "missing headers are reported as -1")
+		assert.Equal(t, time.Date(2024, 1, 2, 3, 5, 0, 0, time.UTC), status.TokensResetAt)
+		assert.True(t, status.RequestsResetAt.IsZero())
+	})
+
+	t.Run("no headers", func(t *testing.T) {
+		assert.Nil(t, parseRateLimitHeaders(ProviderOpenAI, http.Header{}, now))
+		assert.Nil(t, parseRateLimitHeaders(ProviderGoogle, http.Header{"X-Ratelimit-Remaining-Requests": {"1"}}, now))
+	})
+
+	t.Run("transport records 429 headers", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Ratelimit-Remaining-Requests", "0")
+			w.Header().Set("X-Ratelimit-Reset-Requests", "20s")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"Rate limit reached","type":"requests"}}`)
+		}))
+		defer server.Close()
+
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+		_, ok := client.GetRateLimitStatus(ProviderOpenAI)
+		assert.False(t, ok)
+
+		resp, err := NewTracingTransport(client, nil).HTTPClient().Post(server.URL+"/v1/chat/completions",
+			"application/json", strings.NewReader(`{"model":"gpt-4o"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		status, ok := client.GetRateLimitStatus(ProviderOpenAI)
+		require.True(t, ok)
+		assert.Equal(t, 0, status.RemainingRequests)
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, http.StatusTooManyRequests, saved.StatusCode)
+		require.NotNil(t, saved.RateLimits.RemainingRequests)
+		assert.Equal(t, 0, *saved.RateLimits.RemainingRequests)
+		assert.NotNil(t, saved.RateLimits.RequestsResetAt)
+		assert.Nil(t, saved.RateLimits.RemainingTokens)
+		assert.Empty(t, saved.Dimensions)
+	})
+
+	t.Run("Anthropic wrapper", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Anthropic-Ratelimit-Tokens-Remaining", "39000")
+			w.Header().Set("Anthropic-Ratelimit-Tokens-Reset", "2024-01-02T03:05:00Z")
+			fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[],"usage":{"input_tokens":10,"output_tokens":2}}`)
+		}))
+		defer server.Close()
+
+		anthropicClient := anthropic.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		_, err := client.TraceAnthropicRequest(context.Background(), anthropic.MessageNewParams{
+			Model:     anthropic.ModelClaude3_5HaikuLatest,
+			MaxTokens: 16,
+		}, anthropicClient.Messages.New)
+		require.NoError(t, err)
+
+		status, ok := client.GetRateLimitStatus(ProviderAnthropic)
+		require.True(t, ok)
+		assert.Equal(t, 39000, status.RemainingTokens)
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		require.NotNil(t, saved.RateLimits.RemainingTokens)
+		assert.Equal(t, 39000, *saved.RateLimits.RemainingTokens)
+		require.NotNil(t, saved.RateLimits.TokensResetAt)
+		assert.Equal(t, time.Date(2024, 1, 2, 3, 5, 0, 0, time.UTC), *saved.RateLimits.TokensResetAt)
+	})
+}
@@ -0,0 +1,111 @@
+// Package proxy runs an LLM gateway: a reverse proxy in front of an OpenAI-compatible,
+// Anthropic or Gemini API that tracks every request passing through, including streams.
+// Services in any language get tracking by pointing their SDK's base URL at the proxy.
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// Proxy is an http.Handler that forwards requests to an upstream provider API and
+// tracks them through an llmtracer.Client
+type Proxy struct {
+	target            *url.URL
+	reverseProxy      *httputil.ReverseProxy
+	middlewareOptions []llmtracer.MiddlewareOption
+	headers           http.Header
+	base              http.RoundTripper
+}
+
+// Option configures a Proxy
+type Option func(*Proxy)
+
+// WithUpstreamHeader sets a header on every upstream request, overriding the caller's
+// value. Use it to keep provider API keys in the proxy:
+// WithUpstreamHeader("Authorization", "Bearer "+key).
+func WithUpstreamHeader(name, value string) Option {
+	return func(p *Proxy) {
+		p.headers.Set(name, value)
+	}
+}
+
+// WithMiddlewareOptions configures how trace IDs, user IDs and features are read from
+// incoming requests (see llmtracer.HTTPMiddleware)
+func WithMiddlewareOptions(opts ...llmtracer.MiddlewareOption) Option {
+	return func(p *Proxy) {
+		p.middlewareOptions = append(p.middlewareOptions, opts...)
+	}
+}
+
+// WithBaseTransport sets the transport used for upstream requests (http.DefaultTransport by default)
+func WithBaseTransport(base http.RoundTripper) Option {
+	return func(p *Proxy) {
+		p.base = base
+	}
+}
+
+// New creates a proxy forwarding to target, e.g. "https://api.openai.com"
+func New(client *llmtracer.Client, target string, opts ...Option) (*Proxy, error) {
+	if client == nil {
+		return nil, fmt.Errorf("client cannot be nil")
+	}
+
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target URL: %w", err)
+	}
+	if targetURL.Scheme == "" || targetURL.Host == "" {
+		return nil, fmt.Errorf("invalid target URL %q: scheme and host are required", target)
+	}
+
+	p := &Proxy{
+		target:  targetURL,
+		headers: make(http.Header),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(p)
+		}
+	}
+
+	p.reverseProxy = &httputil.ReverseProxy{
+		Rewrite:   p.rewrite,
+		Transport: llmtracer.NewTracingTransport(client, p.base),
+		// Flush immediately so streamed tokens reach the caller without buffering
+		FlushInterval: -1,
+	}
+
+	return p, nil
+}
+
+// rewrite points the outgoing request at the upstream API
+func (p *Proxy) rewrite(r *httputil.ProxyRequest) {
+	r.SetURL(p.target)
+	r.SetXForwarded()
+	r.Out.Host = p.target.Host
+
+	// Let the transport negotiate compression so response bodies can be parsed for usage
+	r.Out.Header.Del("Accept-Encoding")
+
+	for name, values := range p.headers {
+		r.Out.Header[name] = values
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := llmtracer.ContextFromHTTPRequest(r, p.middlewareOptions...)
+	p.reverseProxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// Handler mounts the proxy under prefix, stripping it before forwarding, so several
+// providers can share one server: mux.Handle("/anthropic/", proxy.Handler("/anthropic")).
+func (p *Proxy) Handler(prefix string) http.Handler {
+	return http.StripPrefix(strings.TrimSuffix(prefix, "/"), p)
+}
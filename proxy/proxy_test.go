@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	llmtracer "github.com/propel-gtm/llm-request-tracer"
+)
+
+// recordingStorage captures saved requests; the other methods are unused by the proxy
+type recordingStorage struct {
+	llmtracer.StorageAdapter
+	mu    sync.Mutex
+	saved []*llmtracer.Request
+}
+
+func (s *recordingStorage) Save(ctx context.Context, request *llmtracer.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, request)
+	return nil
+}
+
+func (s *recordingStorage) requests() []*llmtracer.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*llmtracer.Request(nil), s.saved...)
+}
+
+func TestProxy(t *testing.T) {
+	var upstreamAuth, upstreamPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamAuth = r.Header.Get("Authorization")
+		upstreamPath = r.URL.Path
+		if strings.Contains(r.URL.RawQuery, "stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n")
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":1}}\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"usage":{"prompt_tokens":20,"completion_tokens":5}}`)
+	}))
+	defer upstream.Close()
+
+	storage := &recordingStorage{}
+	client := llmtracer.NewClient(storage)
+	p, err := New(client, upstream.URL,
+		WithUpstreamHeader("Authorization", "Bearer upstream-key"),
+		WithMiddlewareOptions(llmtracer.WithUserIDHeader("X-User-ID")),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/openai/", p.Handler("/openai"))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Run("tracks proxied requests", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/openai/v1/chat/completions",
+			strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("Authorization", "Bearer caller-key")
+		req.Header.Set("X-User-ID", "user-7")
+		req.Header.Set("X-Request-ID", "trace-abc")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if !strings.Contains(string(body), "prompt_tokens") {
+			t.Errorf("Expected upstream body to be returned, got %s", body)
+		}
+		if upstreamAuth != "Bearer upstream-key" {
+			t.Errorf("Expected upstream API key to be injected, got %q", upstreamAuth)
+		}
+		if upstreamPath != "/v1/chat/completions" {
+			t.Errorf("Expected prefix to be stripped, got %q", upstreamPath)
+		}
+
+		saved := storage.requests()
+		if len(saved) != 1 {
+			t.Fatalf("Expected 1 tracked request, got %d", len(saved))
+		}
+		if saved[0].TraceID != "trace-abc" || saved[0].InputTokens != 20 || saved[0].OutputTokens != 5 {
+			t.Errorf("Unexpected tracked request: %+v", saved[0])
+		}
+		found := false
+		for _, dim := range saved[0].Dimensions {
+			if dim.Key == "user_id" && dim.Value == "user-7" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected user_id dimension, got %v", saved[0].Dimensions)
+		}
+	})
+
+	t.Run("tracks streamed requests", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/openai/v1/chat/completions?stream=true", "application/json",
+			strings.NewReader(`{"model":"gpt-4o-mini","stream":true}`))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		_, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		// The proxy finishes tracking after the response is written
+		deadline := time.Now().Add(time.Second)
+		for len(storage.requests()) < 2 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		saved := storage.requests()
+		if len(saved) != 2 {
+			t.Fatalf("Expected 2 tracked requests, got %d", len(saved))
+		}
+		if saved[1].Model != "gpt-4o-mini" || saved[1].InputTokens != 3 || saved[1].OutputTokens != 1 {
+			t.Errorf("Unexpected tracked stream: %+v", saved[1])
+		}
+	})
+
+	t.Run("rejects invalid targets", func(t *testing.T) {
+		if _, err := New(client, "not-a-url"); err == nil {
+			t.Error("Expected error for target without scheme")
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package llmtracer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// pseudonymPrefix marks a dimension value that has already been replaced by a pseudonym
+const pseudonymPrefix = "hmac:"
+
+// WithPseudonymization replaces the values of the given dimension keys (user_id when none
+// are given) with keyed HMAC-SHA256 pseudonyms before they reach storage. See
+// NewPseudonymizedStorage for how reads behave.
+func WithPseudonymization(secret []byte, dimensionKeys ...string) ClientOption {
+	return func(c *Client) {
+		c.storage = NewPseudonymizedStorage(c.storage, secret, dimensionKeys...)
+	}
+}
+
+// NewPseudonymizedStorage wraps storage so that the values of the given dimension keys
+// (user_id when none are given) are stored as HMAC-SHA256 pseudonyms of the value. The
+// same value always maps to the same pseudonym, so filters, GroupByDimension and quotas
+// keep working, but the original value can't be recovered from the database without the
+// secret.
+//
+// Dimension filters may use either the original value or a pseudonym returned by an
+// earlier read. Reads return pseudonyms, including the dimension values of rollups and
+// saved filters. Changing the secret starts new pseudonyms, so
+// keep it stable for as long as usage is compared over time. Requests observed in-process,
+// by Watch, rate limiters and quotas, still carry the original values.
+func NewPseudonymizedStorage(storage StorageAdapter, secret []byte, dimensionKeys ...string) StorageAdapter {
+	if storage == nil {
+		panic("storage adapter cannot be nil")
+	}
+	if len(secret) == 0 {
+		panic("pseudonymization secret cannot be empty")
+	}
+	if len(dimensionKeys) == 0 {
+		dimensionKeys = []string{DimensionUserID}
+	}
+
+	pseudonymized := &pseudonymizedStorage{
+		StorageAdapter: storage,
+		secret:         append([]byte(nil), secret...),
+		dimensionKeys:  make(map[string]bool, len(dimensionKeys)),
+	}
+	for _, key := range dimensionKeys {
+		pseudonymized.dimensionKeys[key] = true
+	}
+	return preserveSoftDelete(pseudonymized, storage)
+}
+
+// pseudonymizedStorage replaces configured dimension values on the way into the wrapped adapter
+type pseudonymizedStorage struct {
+	StorageAdapter
+	secret        []byte
+	dimensionKeys map[string]bool
+}
+
+func (s *pseudonymizedStorage) unwrap() StorageAdapter {
+	return s.StorageAdapter
+}
+
+func (s *pseudonymizedStorage) Capabilities() StorageCapabilities {
+	return wrappedCapabilities(s.StorageAdapter)
+}
+
+func (s *pseudonymizedStorage) Save(ctx context.Context, request *Request) error {
+	return s.write(ctx, request, s.StorageAdapter.Save)
+}
+
+func (s *pseudonymizedStorage) Update(ctx context.Context, request *Request) error {
+	return s.write(ctx, request, func(ctx context.Context, stored *Request) error {
+		return updateRequest(ctx, s.StorageAdapter, stored)
+	})
+}
+
+// write pseudonymizes a request and stores it with store
+func (s *pseudonymizedStorage) write(ctx context.Context, request *Request, store func(context.Context, *Request) error) error {
+	// Pseudonymize a copy so observers and watchers of the caller's request still see
+	// the original values
+	stored := *request
+	stored.Dimensions = s.pseudonymizeDimensions(request.Dimensions)
+
+	if err := store(ctx, &stored); err != nil {
+		return err
+	}
+	request.CreatedAt = stored.CreatedAt
+	request.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (s *pseudonymizedStorage) Query(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+	return s.StorageAdapter.Query(ctx, s.pseudonymizeFilter(filter))
+}
+
+func (s *pseudonymizedStorage) Aggregate(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+	return s.StorageAdapter.Aggregate(ctx, groupBy, s.pseudonymizeFilter(filter))
+}
+
+func (s *pseudonymizedStorage) AggregateMulti(ctx context.Context, specs []AggregateSpec) ([][]*AggregateResult, error) {
+	pseudonymized := make([]AggregateSpec, len(specs))
+	for i, spec := range specs {
+		pseudonymized[i] = AggregateSpec{GroupBy: spec.GroupBy, Filter: s.pseudonymizeFilter(spec.Filter)}
+	}
+	return aggregateMulti(ctx, s.StorageAdapter, pseudonymized)
+}
+
+// CompactRequests pseudonymizes the dimension values of rollups like those of requests
+func (s *pseudonymizedStorage) CompactRequests(ctx context.Context, rollups []*RequestRollup, requestIDs []string) error {
+	storage, ok := storageCapability[RollupStorage](s.StorageAdapter)
+	if !ok {
+		return ErrRollupsNotSupported
+	}
+	pseudonymized := make([]*RequestRollup, len(rollups))
+	for i, rollup := range rollups {
+		stored := *rollup
+		stored.setDimensions(s.pseudonymizeDimensions(rollupDimensions(rollup)))
+		stored.ID = rollupID(&stored)
+		pseudonymized[i] = &stored
+	}
+	return storage.CompactRequests(ctx, pseudonymized, requestIDs)
+}
+
+func (s *pseudonymizedStorage) QueryRollups(ctx context.Context, filter *RequestFilter) ([]*RequestRollup, error) {
+	storage, ok := storageCapability[RollupStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrRollupsNotSupported
+	}
+	return storage.QueryRollups(ctx, s.pseudonymizeFilter(filter))
+}
+
+// SaveFilter pseudonymizes the dimension values of saved filters like those of requests,
+// which GetFilter and ListFilters then return
+func (s *pseudonymizedStorage) SaveFilter(ctx context.Context, filter *SavedFilter) error {
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return ErrSavedFiltersNotSupported
+	}
+	stored := *filter
+	stored.Filter.Dimensions = s.pseudonymizeDimensions(filter.Filter.Dimensions)
+	return storage.SaveFilter(ctx, &stored)
+}
+
+func (s *pseudonymizedStorage) GetFilter(ctx context.Context, name string) (*SavedFilter, error) {
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrSavedFiltersNotSupported
+	}
+	return storage.GetFilter(ctx, name)
+}
+
+func (s *pseudonymizedStorage) ListFilters(ctx context.Context) ([]*SavedFilter, error) {
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrSavedFiltersNotSupported
+	}
+	return storage.ListFilters(ctx)
+}
+
+func (s *pseudonymizedStorage) DeleteFilter(ctx context.Context, name string) error {
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return ErrSavedFiltersNotSupported
+	}
+	return storage.DeleteFilter(ctx, name)
+}
+
+func (s *pseudonymizedStorage) pseudonymizeDimensions(dimensions []DimensionTag) []DimensionTag {
+	if len(dimensions) == 0 {
+		return dimensions
+	}
+	pseudonymized := make([]DimensionTag, len(dimensions))
+	for i, dim := range dimensions {
+		pseudonymized[i] = dim
+		if s.dimensionKeys[dim.Key] {
+			pseudonymized[i].Value = s.pseudonym(dim.Key, dim.Value)
+		}
+	}
+	return pseudonymized
+}
+
+// pseudonymizeFilter returns a copy of filter whose dimension values match the stored
+// pseudonyms
+func (s *pseudonymizedStorage) pseudonymizeFilter(filter *RequestFilter) *RequestFilter {
+	if filter == nil || len(filter.Dimensions) == 0 {
+		return filter
+	}
+	copied := *filter
+	copied.Dimensions = s.pseudonymizeDimensions(filter.Dimensions)
+	return &copied
+}
+
+// pseudonym returns the pseudonym of a dimension value. The key is part of the MAC so the
+// same value gets unrelated pseudonyms in different dimensions. Empty values and values
+// that already are pseudonyms are returned unchanged.
+func (s *pseudonymizedStorage) pseudonym(key, value string) string {
+	if value == "" || isPseudonym(value) {
+		return value
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return pseudonymPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func isPseudonym(value string) bool {
+	if !strings.HasPrefix(value, pseudonymPrefix) || len(value) != len(pseudonymPrefix)+2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(strings.TrimPrefix(value, pseudonymPrefix))
+	return err == nil
+}
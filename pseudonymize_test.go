@@ -0,0 +1,98 @@
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPseudonymizedStorage(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("pseudonymization-secret")
+
+	t.Run("Values are replaced before storage", func(t *testing.T) {
+		mock, stored := newRecordingStorage()
+		client := NewClient(mock, WithPseudonymization(secret, "user_id", "email"))
+
+		observed := make(chan *Request, 1)
+		client.observers.add(func(request *Request) { observed <- request })
+
+		ctx := WithDimensions(WithUserID(ctx, "alice"), map[string]interface{}{"email": "alice@example.com", "team": "support"})
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4", 10, 5, time.Second, nil, nil))
+		require.Len(t, stored, 1)
+
+		var raw *Request
+		for _, request := range stored {
+			raw = request
+		}
+		assert.True(t, isPseudonym(raw.Dimension("user_id")))
+		assert.True(t, isPseudonym(raw.Dimension("email")))
+		assert.NotContains(t, raw.Dimension("email"), "alice")
+		assert.Equal(t, "support", raw.Dimension("team"))
+
+		// Observers such as quotas keep the original values
+		assert.Equal(t, "alice", (<-observed).Dimension("user_id"))
+	})
+
+	t.Run("Pseudonyms are stable per secret and key", func(t *testing.T) {
+		storage := NewPseudonymizedStorage(&MockStorageAdapter{}, secret, "user_id", "email").(*pseudonymizedStorage)
+		other := NewPseudonymizedStorage(&MockStorageAdapter{}, []byte("other-secret")).(*pseudonymizedStorage)
+
+		assert.Equal(t, storage.pseudonym("user_id", "alice"), storage.pseudonym("user_id", "alice"))
+		assert.NotEqual(t, storage.pseudonym("user_id", "alice"), storage.pseudonym("user_id", "bob"))
+		assert.NotEqual(t, storage.pseudonym("user_id", "alice"), storage.pseudonym("email", "alice"))
+		assert.NotEqual(t, storage.pseudonym("user_id", "alice"), other.pseudonym("user_id", "alice"))
+
+		pseudonym := storage.pseudonym("user_id", "alice")
+		assert.Equal(t, pseudonym, storage.pseudonym("user_id", pseudonym))
+		assert.Equal(t, "", storage.pseudonym("user_id", ""))
+	})
+
+	t.Run("Filters accept original values and pseudonyms", func(t *testing.T) {
+		mock, _ := newRecordingStorage()
+		storage := NewPseudonymizedStorage(mock, secret)
+		for i, user := range []string{"alice", "alice", "bob"} {
+			require.NoError(t, storage.Save(ctx, &Request{
+				ID:         fmt.Sprintf("%s-%d", user, i),
+				Dimensions: []DimensionTag{{Key: "user_id", Value: user}},
+			}))
+		}
+
+		results, err := storage.Query(ctx, &RequestFilter{Dimensions: []DimensionTag{{Key: "user_id", Value: "alice"}}})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		pseudonym := results[0].Dimension("user_id")
+		assert.True(t, strings.HasPrefix(pseudonym, pseudonymPrefix))
+
+		results, err = storage.Query(ctx, &RequestFilter{Dimensions: []DimensionTag{{Key: "user_id", Value: pseudonym}}})
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+	})
+
+	t.Run("Saved filter dimensions", func(t *testing.T) {
+		storage := &savedFilterStorage{MockStorageAdapter: &MockStorageAdapter{}, filters: make(map[string]*SavedFilter)}
+		client := NewClient(storage, WithPseudonymization(secret))
+
+		require.NoError(t, client.SaveFilter(ctx, "alice", &RequestFilter{Dimensions: []DimensionTag{{Key: "user_id", Value: "alice"}}}))
+		saved, err := client.GetSavedFilter(ctx, "alice")
+		require.NoError(t, err)
+		assert.True(t, isPseudonym(saved.Filter.Dimensions[0].Value))
+		assert.Equal(t, saved.Filter.Dimensions[0].Value, storage.filters["alice"].Filter.Dimensions[0].Value)
+	})
+
+	t.Run("Quotas load usage by original value", func(t *testing.T) {
+		mock, _ := newRecordingStorage()
+		client := NewClient(mock, WithPseudonymization(secret))
+		require.NoError(t, client.TrackRequest(WithUserID(ctx, "alice"), ProviderOpenAI, "gpt-4", 100, 50, time.Second, nil, nil))
+
+		quotas := NewQuotaManager(client, "user_id", WithDefaultQuota(Quota{TokenLimit: 1000}))
+		status, err := quotas.GetQuotaStatus(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, int64(150), status.TokensUsed)
+	})
+}
@@ -0,0 +1,386 @@
+package llmtracer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsPushConfig configures WithMetricsPush. At least one of PushgatewayURL and
+// OTLPEndpoint must be set for anything to be pushed.
+type MetricsPushConfig struct {
+	// PushgatewayURL is the base URL of a Prometheus Pushgateway, e.g. "http://pushgateway:9091"
+	PushgatewayURL string
+	// Job names the Pushgateway group; "llmtracer" when empty
+	Job string
+	// OTLPEndpoint is the URL of an OTLP/HTTP metrics endpoint, e.g.
+	// "http://collector:4318/v1/metrics"; metrics are sent JSON-encoded
+	OTLPEndpoint string
+	// Labels identify the pushing process: they extend the Pushgateway grouping key and
+	// become OTLP resource attributes, e.g. {"instance": hostname}
+	Labels map[string]string
+	// Headers are sent with every push, e.g. for authentication
+	Headers map[string]string
+	// Dimensions are dimension keys added as labels next to provider and model
+	Dimensions []string
+	// Interval also pushes periodically while the client is open; zero pushes only when
+	// the client shuts down
+	Interval time.Duration
+	// Timeout bounds each push; 10 seconds when zero
+	Timeout time.Duration
+	// HTTPClient sends the pushes; http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// WithMetricsPush counts the usage of the requests this client tracks and pushes it to a
+// Prometheus Pushgateway or an OTLP endpoint when the client shuts down, so batch jobs and
+// CLIs that exit before being scraped still show up in monitoring. The pushed metrics are
+// the ones written by WriteOpenMetrics, counted since the client was created rather than
+// read from storage; requests skipped by WithSampleRate are not counted.
+// Set Interval to also push periodically.
+//
+// A failed push at shutdown is returned by Shutdown and Close; periodic failures are
+// logged.
+func WithMetricsPush(config MetricsPushConfig) ClientOption {
+	return func(c *Client) {
+		if config.Job == "" {
+			config.Job = "llmtracer"
+		}
+		if config.Timeout <= 0 {
+			config.Timeout = 10 * time.Second
+		}
+		if config.HTTPClient == nil {
+			config.HTTPClient = http.DefaultClient
+		}
+		c.metricsPush = &metricsPush{
+			config: config,
+			usage:  newUsageCounters(config.Dimensions),
+		}
+	}
+}
+
+// metricsPush holds the usage counted for WithMetricsPush
+type metricsPush struct {
+	config MetricsPushConfig
+	usage  *usageCounters
+	stop   chan struct{}
+}
+
+// usageCounters adds up the usage of tracked requests per provider, model and dimensions
+type usageCounters struct {
+	dimensions []string
+	start      time.Time
+
+	mu     sync.Mutex
+	series map[string]*usageSeries
+}
+
+type usageSeries struct {
+	result  AggregateResult
+	latency time.Duration
+}
+
+func newUsageCounters(dimensions []string) *usageCounters {
+	return &usageCounters{
+		dimensions: dimensions,
+		start:      time.Now(),
+		series:     make(map[string]*usageSeries),
+	}
+}
+
+// record counts a tracked request
+func (u *usageCounters) record(request *Request) {
+	values := []string{string(request.Provider), request.Model}
+	for _, key := range u.dimensions {
+		values = append(values, request.Dimension(key))
+	}
+	key := strings.Join(values, "\x00")
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	series, ok := u.series[key]
+	if !ok {
+		series = &usageSeries{result: AggregateResult{Provider: request.Provider, Model: request.Model}}
+		for i, key := range u.dimensions {
+			series.result.Dimensions = append(series.result.Dimensions, DimensionTag{Key: key, Value: values[i+2]})
+		}
+		u.series[key] = series
+	}
+	series.result.TotalRequests++
+	series.result.TotalInputTokens = AddTokens(series.result.TotalInputTokens, request.InputTokens)
+	series.result.TotalOutputTokens = AddTokens(series.result.TotalOutputTokens, request.OutputTokens)
+	series.result.TotalCost += request.Cost
+	if request.Error != "" {
+		series.result.ErrorCount++
+	}
+	series.latency += request.Latency
+}
+
+// snapshot returns the usage counted so far
+func (u *usageCounters) snapshot() []*AggregateResult {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	results := make([]*AggregateResult, 0, len(u.series))
+	for _, series := range u.series {
+		result := series.result
+		result.AvgLatency = series.latency / time.Duration(result.TotalRequests)
+		result.TotalTokens = AddTokens(result.TotalInputTokens, result.TotalOutputTokens)
+		result.ComputeEfficiency()
+		results = append(results, &result)
+	}
+	return results
+}
+
+// PushMetrics pushes the usage counted by WithMetricsPush right away. It does nothing when
+// the client was not configured to push.
+func (c *Client) PushMetrics(ctx context.Context) error {
+	if c.metricsPush == nil {
+		return nil
+	}
+	config := c.metricsPush.config
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	results := c.metricsPush.usage.snapshot()
+	var errs []error
+	if config.PushgatewayURL != "" {
+		var body bytes.Buffer
+		if err := writeUsageMetrics(&body, results, config.Dimensions, "llmtracer", false); err != nil {
+			return err
+		}
+		target, err := pushgatewayURL(config)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, c.sendMetrics(ctx, http.MethodPut, target, "text/plain; version=0.0.4; charset=utf-8", &body))
+	}
+	if config.OTLPEndpoint != "" {
+		body, err := json.Marshal(newOTLPMetrics(results, c.metricsPush.usage.start, time.Now(), config))
+		if err != nil {
+			return err
+		}
+		errs = append(errs, c.sendMetrics(ctx, http.MethodPost, config.OTLPEndpoint, "application/json", bytes.NewReader(body)))
+	}
+	return errors.Join(errs...)
+}
+
+// sendMetrics sends one push and checks its response status
+func (c *Client) sendMetrics(ctx context.Context, method, target, contentType string, body io.Reader) error {
+	config := c.metricsPush.config
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range config.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := config.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to push metrics to %s: %s: %s", target, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// runMetricsPush pushes the usage every interval until the client shuts down
+func (c *Client) runMetricsPush() {
+	ticker := time.NewTicker(c.metricsPush.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.PushMetrics(context.Background()); err != nil {
+				c.logger.Warn("Failed to push metrics", slog.String("error", err.Error()))
+			}
+		case <-c.metricsPush.stop:
+			return
+		}
+	}
+}
+
+// pushgatewayURL returns the URL of the Pushgateway group for the job and labels. Label
+// values that cannot appear in a URL path segment use the Pushgateway's base64 encoding.
+func pushgatewayURL(config MetricsPushConfig) (string, error) {
+	if _, err := url.Parse(config.PushgatewayURL); err != nil {
+		return "", fmt.Errorf("invalid Pushgateway URL: %w", err)
+	}
+	target := strings.TrimSuffix(config.PushgatewayURL, "/") + "/metrics/" + pushgatewaySegment("job", config.Job)
+
+	names := make([]string, 0, len(config.Labels))
+	for name := range config.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target += "/" + pushgatewaySegment(openMetricsName(name), config.Labels[name])
+	}
+	return target, nil
+}
+
+// pushgatewaySegment encodes a grouping key label as URL path segments
+func pushgatewaySegment(name, value string) string {
+	if value == "" || strings.Contains(value, "/") {
+		encoded := base64.URLEncoding.EncodeToString([]byte(value))
+		if encoded == "" {
+			encoded = "="
+		}
+		return name + "@base64/" + encoded
+	}
+	return name + "/" + url.PathEscape(value)
+}
+
+// otlpMetrics is the JSON encoding of an OTLP ExportMetricsServiceRequest
+type otlpMetrics struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Sum         *otlpSum     `json:"sum,omitempty"`
+	Summary     *otlpSummary `json:"summary,omitempty"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type otlpNumberPoint struct {
+	Attributes        []otlpAttribute `json:"attributes"`
+	StartTimeUnixNano int64           `json:"startTimeUnixNano,string"`
+	TimeUnixNano      int64           `json:"timeUnixNano,string"`
+	AsInt             *int64          `json:"asInt,omitempty,string"`
+	AsDouble          *float64        `json:"asDouble,omitempty"`
+}
+
+type otlpSummary struct {
+	DataPoints []otlpSummaryPoint `json:"dataPoints"`
+}
+
+type otlpSummaryPoint struct {
+	Attributes        []otlpAttribute `json:"attributes"`
+	StartTimeUnixNano int64           `json:"startTimeUnixNano,string"`
+	TimeUnixNano      int64           `json:"timeUnixNano,string"`
+	Count             int64           `json:"count,string"`
+	Sum               float64         `json:"sum"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value otlpStringAttr `json:"value"`
+}
+
+type otlpStringAttr struct {
+	StringValue string `json:"stringValue"`
+}
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE
+const otlpCumulative = 2
+
+// newOTLPMetrics converts usage counted between start and now into OTLP cumulative sums
+// and a latency summary
+func newOTLPMetrics(results []*AggregateResult, start, now time.Time, config MetricsPushConfig) *otlpMetrics {
+	resource := otlpResource{Attributes: otlpAttributes(config.Labels)}
+	if _, ok := config.Labels["service.name"]; !ok {
+		resource.Attributes = append(resource.Attributes, otlpAttribute{Key: "service.name", Value: otlpStringAttr{config.Job}})
+	}
+
+	var metrics []otlpMetric
+	for _, series := range openMetricsUsage {
+		metric := otlpMetric{Name: "llmtracer_" + series.name, Description: series.help}
+		for _, result := range results {
+			labels := map[string]string{"provider": string(result.Provider), "model": result.Model}
+			for _, key := range config.Dimensions {
+				labels[key] = result.Dimension(key)
+			}
+			attributes := otlpAttributes(labels)
+
+			samples := series.samples(result)
+			if series.kind == "summary" {
+				if metric.Summary == nil {
+					metric.Summary = &otlpSummary{}
+				}
+				metric.Summary.DataPoints = append(metric.Summary.DataPoints, otlpSummaryPoint{
+					Attributes:        attributes,
+					StartTimeUnixNano: start.UnixNano(),
+					TimeUnixNano:      now.UnixNano(),
+					Count:             result.TotalRequests,
+					Sum:               samples[0].value.(float64),
+				})
+				continue
+			}
+
+			if metric.Sum == nil {
+				metric.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+			}
+			point := otlpNumberPoint{Attributes: attributes, StartTimeUnixNano: start.UnixNano(), TimeUnixNano: now.UnixNano()}
+			switch value := samples[0].value.(type) {
+			case int64:
+				point.AsInt = &value
+			case float64:
+				point.AsDouble = &value
+			}
+			metric.Sum.DataPoints = append(metric.Sum.DataPoints, point)
+		}
+		if metric.Sum != nil || metric.Summary != nil {
+			metrics = append(metrics, metric)
+		}
+	}
+
+	return &otlpMetrics{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: resource,
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "github.com/propel-gtm/llm-request-tracer"},
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+// otlpAttributes converts labels into string attributes sorted by key
+func otlpAttributes(labels map[string]string) []otlpAttribute {
+	attributes := make([]otlpAttribute, 0, len(labels))
+	for key, value := range labels {
+		attributes = append(attributes, otlpAttribute{Key: key, Value: otlpStringAttr{value}})
+	}
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].Key < attributes[j].Key
+	})
+	return attributes
+}
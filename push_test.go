@@ -0,0 +1,117 @@
+package llmtracer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedPush struct {
+	method, path, contentType, auth string
+	body                            []byte
+}
+
+func newPushServer(t *testing.T, status int) (*httptest.Server, func() []recordedPush) {
+	var mu sync.Mutex
+	var pushes []recordedPush
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		pushes = append(pushes, recordedPush{r.Method, r.URL.EscapedPath(), r.Header.Get("Content-Type"), r.Header.Get("Authorization"), body})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []recordedPush {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedPush(nil), pushes...)
+	}
+}
+
+func TestWithMetricsPush(t *testing.T) {
+	t.Run("Usage is pushed on Close", func(t *testing.T) {
+		server, pushes := newPushServer(t, http.StatusOK)
+		client := NewClient(&MockStorageAdapter{}, WithMetricsPush(MetricsPushConfig{
+			PushgatewayURL: server.URL,
+			OTLPEndpoint:   server.URL + "/v1/metrics",
+			Job:            "nightly-eval",
+			Labels:         map[string]string{"instance": "runner/1"},
+			Headers:        map[string]string{"Authorization": "Bearer secret"},
+			Dimensions:     []string{DimensionFeature},
+		}))
+
+		ctx := WithFeature(context.Background(), "summarize")
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 20, time.Second, errors.New("boom"), nil))
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 50, 10, 3*time.Second, nil, nil))
+		assert.Empty(t, pushes(), "nothing is pushed before Close")
+		require.NoError(t, client.Close())
+
+		recorded := pushes()
+		require.Len(t, recorded, 2)
+		gateway, otlp := recorded[0], recorded[1]
+
+		assert.Equal(t, http.MethodPut, gateway.method)
+		assert.Equal(t, "/metrics/job/nightly-eval/instance@base64/cnVubmVyLzE=", gateway.path)
+		assert.Equal(t, "Bearer secret", gateway.auth)
+		labels := `{provider="openai",model="gpt-4o",feature="summarize"}`
+		assert.Contains(t, string(gateway.body), "# TYPE llmtracer_usage_requests_total counter\n")
+		assert.Contains(t, string(gateway.body), "llmtracer_usage_requests_total"+labels+" 2\n")
+		assert.Contains(t, string(gateway.body), "llmtracer_usage_errors_total"+labels+" 1\n")
+		assert.Contains(t, string(gateway.body), "llmtracer_usage_input_tokens_total"+labels+" 150\n")
+		assert.Contains(t, string(gateway.body), "llmtracer_usage_latency_seconds_sum"+labels+" 4\n")
+		assert.NotContains(t, string(gateway.body), "# EOF")
+
+		assert.Equal(t, http.MethodPost, otlp.method)
+		assert.Equal(t, "/v1/metrics", otlp.path)
+		assert.Equal(t, "application/json", otlp.contentType)
+		var payload otlpMetrics
+		require.NoError(t, json.Unmarshal(otlp.body, &payload))
+		require.Len(t, payload.ResourceMetrics, 1)
+		assert.Contains(t, payload.ResourceMetrics[0].Resource.Attributes, otlpAttribute{Key: "service.name", Value: otlpStringAttr{"nightly-eval"}})
+		metrics := payload.ResourceMetrics[0].ScopeMetrics[0].Metrics
+		require.Len(t, metrics, len(openMetricsUsage))
+		requests := metrics[0]
+		assert.Equal(t, "llmtracer_usage_requests", requests.Name)
+		require.NotNil(t, requests.Sum)
+		assert.True(t, requests.Sum.IsMonotonic)
+		require.Len(t, requests.Sum.DataPoints, 1)
+		assert.Equal(t, int64(2), *requests.Sum.DataPoints[0].AsInt)
+		assert.Contains(t, requests.Sum.DataPoints[0].Attributes, otlpAttribute{Key: DimensionFeature, Value: otlpStringAttr{"summarize"}})
+		latency := metrics[len(metrics)-1]
+		require.NotNil(t, latency.Summary)
+		assert.Equal(t, int64(2), latency.Summary.DataPoints[0].Count)
+		assert.InDelta(t, 4.0, latency.Summary.DataPoints[0].Sum, 1e-9)
+	})
+
+	t.Run("Failed pushes are returned by Close", func(t *testing.T) {
+		server, _ := newPushServer(t, http.StatusBadRequest)
+		client := NewClient(&MockStorageAdapter{}, WithMetricsPush(MetricsPushConfig{PushgatewayURL: server.URL}))
+		require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+
+		err := client.Close()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "400 Bad Request")
+	})
+
+	t.Run("Usage is pushed periodically", func(t *testing.T) {
+		server, pushes := newPushServer(t, http.StatusOK)
+		client := NewClient(&MockStorageAdapter{}, WithMetricsPush(MetricsPushConfig{PushgatewayURL: server.URL, Interval: 10 * time.Millisecond}))
+		defer client.Close()
+
+		assert.Eventually(t, func() bool { return len(pushes()) >= 2 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Clients without push do nothing", func(t *testing.T) {
+		assert.NoError(t, NewClient(&MockStorageAdapter{}).PushMetrics(context.Background()))
+	})
+}
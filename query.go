@@ -0,0 +1,135 @@
+package llmtracer
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned by Client.Query for a cursor it did not issue
+var ErrInvalidCursor = errors.New("invalid query cursor")
+
+// cursorPrefix versions the cursor format
+const cursorPrefix = "o:"
+
+// QueryOptions configures Client.Query
+type QueryOptions struct {
+	// Cursor continues from the NextCursor of a previous result of the same filter,
+	// replacing the filter's Offset
+	Cursor string
+	// CountTotal also counts every request matching the filter, regardless of Limit and
+	// Offset. It costs a second query.
+	CountTotal bool
+}
+
+// QueryResult is a page of requests returned by Client.Query
+type QueryResult struct {
+	Requests []*Request `json:"requests"`
+	// Total is the number of requests matching the filter; set only with CountTotal
+	Total *int64 `json:"total,omitempty"`
+	// Truncated is true when Limit cut the results short, i.e. there is another page
+	Truncated bool `json:"truncated"`
+	// NextCursor reads the next page when Truncated is set
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Duration is how long the query took, including the count
+	Duration time.Duration `json:"duration"`
+}
+
+// Query returns the requests matching filter with what a pagination UI needs: whether
+// Limit cut the results short, a cursor to the next page and, with opts.CountTotal, the
+// number of matching requests. opts may be nil.
+//
+// Cursors hold the position of the next page, so requests inserted earlier in the
+// filter's order while paging shift the pages; the default order by creation time only
+// appends new requests at the end.
+func (c *Client) Query(ctx context.Context, filter *RequestFilter, opts *QueryOptions) (*QueryResult, error) {
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+	start := time.Now()
+
+	page := RequestFilter{}
+	if filter != nil {
+		page = *filter
+	}
+	if opts.Cursor != "" {
+		offset, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		page.Offset = offset
+	}
+	// One extra row tells whether there is another page
+	limit := page.Limit
+	if limit > 0 {
+		page.Limit = limit + 1
+	}
+
+	requests, err := c.query(ctx, &page)
+	if err != nil {
+		return nil, err
+	}
+	result := &QueryResult{Requests: requests}
+	if limit > 0 && len(requests) > limit {
+		result.Requests = requests[:limit]
+		result.Truncated = true
+		result.NextCursor = encodeCursor(page.Offset + limit)
+	}
+
+	if opts.CountTotal {
+		total, err := c.countRequests(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// countRequests counts the requests matching filter, ignoring its Limit and Offset. The
+// count is pushed down to the storage adapter when it aggregates natively.
+func (c *Client) countRequests(ctx context.Context, filter RequestFilter) (int64, error) {
+	filter.Limit = 0
+	filter.Offset = 0
+
+	if c.storage.Capabilities().Aggregate {
+		results, err := c.storage.Aggregate(ctx, nil, &filter)
+		if err == nil {
+			var total int64
+			for _, result := range results {
+				total += result.TotalRequests
+			}
+			return total, nil
+		}
+		if !errors.Is(err, ErrAggregateNotSupported) {
+			return 0, err
+		}
+	}
+
+	requests, err := c.query(ctx, &filter)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(requests)), nil
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return offset, nil
+}
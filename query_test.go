@@ -0,0 +1,93 @@
+package llmtracer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryPages(t *testing.T) {
+	store := newMemoryStore()
+	storage := store.adapter()
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, storage.Save(ctx, &Request{ID: fmt.Sprintf("req-%d", i), Model: "gpt-4o", RequestedAt: time.Now()}))
+	}
+	require.NoError(t, storage.Save(ctx, &Request{ID: "other", Model: "gpt-4o-mini", RequestedAt: time.Now()}))
+	client := NewClient(storage)
+
+	filter := &RequestFilter{Model: "gpt-4o", Limit: 2}
+	var ids []string
+	opts := &QueryOptions{CountTotal: true}
+	for page := 0; ; page++ {
+		result, err := client.Query(ctx, filter, opts)
+		require.NoError(t, err)
+		require.NotNil(t, result.Total)
+		assert.Equal(t, int64(5), *result.Total)
+		for _, request := range result.Requests {
+			ids = append(ids, request.ID)
+		}
+		if !result.Truncated {
+			assert.Empty(t, result.NextCursor)
+			assert.Equal(t, 2, page)
+			break
+		}
+		assert.Len(t, result.Requests, 2)
+		opts.Cursor = result.NextCursor
+	}
+	assert.Equal(t, []string{"req-0", "req-1", "req-2", "req-3", "req-4"}, ids)
+	assert.Equal(t, 2, filter.Limit, "the caller's filter is not modified")
+}
+
+func TestQueryWithoutLimit(t *testing.T) {
+	store := newMemoryStore()
+	storage := store.adapter()
+	require.NoError(t, storage.Save(context.Background(), &Request{ID: "req", RequestedAt: time.Now()}))
+
+	result, err := NewClient(storage).Query(context.Background(), nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, result.Requests, 1)
+	assert.False(t, result.Truncated)
+	assert.Nil(t, result.Total, "not counted unless asked")
+	assert.Positive(t, result.Duration)
+}
+
+func TestQueryCountsWithAggregate(t *testing.T) {
+	var counted *RequestFilter
+	storage := &MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			return []*Request{{ID: "a"}, {ID: "b"}}, nil
+		},
+		AggregateFunc: func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+			counted = filter
+			return []*AggregateResult{{TotalRequests: 42}}, nil
+		},
+	}
+
+	result, err := NewClient(storage).Query(context.Background(), &RequestFilter{Limit: 1, Offset: 10}, &QueryOptions{CountTotal: true})
+	require.NoError(t, err)
+	assert.Len(t, result.Requests, 1)
+	assert.True(t, result.Truncated)
+	require.NotNil(t, result.Total)
+	assert.Equal(t, int64(42), *result.Total)
+	require.NotNil(t, counted)
+	assert.Zero(t, counted.Limit)
+	assert.Zero(t, counted.Offset)
+
+	next, err := decodeCursor(result.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, 11, next)
+}
+
+func TestQueryInvalidCursor(t *testing.T) {
+	client := NewClient(&MockStorageAdapter{})
+	for _, cursor := range []string{"not base64!", base64.RawURLEncoding.EncodeToString([]byte("x:1")), base64.RawURLEncoding.EncodeToString([]byte("o:-1"))} {
+		_, err := client.Query(context.Background(), nil, &QueryOptions{Cursor: cursor})
+		assert.ErrorIs(t, err, ErrInvalidCursor, cursor)
+	}
+}
@@ -0,0 +1,286 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned by the trace wrappers when quota enforcement rejects a call
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaPeriod is the reset schedule of a quota
+type QuotaPeriod string
+
+const (
+	QuotaPeriodDaily   QuotaPeriod = "daily"
+	QuotaPeriodWeekly  QuotaPeriod = "weekly"
+	QuotaPeriodMonthly QuotaPeriod = "monthly"
+)
+
+// Quota is a token and/or cost allowance per period. Zero limits are unlimited.
+type Quota struct {
+	TokenLimit int64
+	CostLimit  float64
+	// Period defaults to QuotaPeriodMonthly. Weekly periods start on Monday.
+	Period QuotaPeriod
+	// ResetDay is the day of the month (1-28) monthly periods start on; defaults to 1
+	ResetDay int
+	// Location is the time zone of period boundaries; defaults to UTC
+	Location *time.Location
+	// StartedAt is when the quota took effect. With Prorate, the allowance of the period
+	// containing StartedAt is reduced to the fraction of the period remaining at that time.
+	StartedAt time.Time
+	Prorate   bool
+}
+
+// QuotaStatus reports the usage of a quota in the current period
+type QuotaStatus struct {
+	Key             string    `json:"key"`
+	PeriodStart     time.Time `json:"period_start"`
+	ResetAt         time.Time `json:"reset_at"`
+	TokensUsed      int64     `json:"tokens_used"`
+	TokenLimit      int64     `json:"token_limit"`
+	TokensRemaining int64     `json:"tokens_remaining"`
+	CostUsed        float64   `json:"cost_used"`
+	CostLimit       float64   `json:"cost_limit"`
+	CostRemaining   float64   `json:"cost_remaining"`
+	Exceeded        bool      `json:"exceeded"`
+}
+
+// QuotaOption configures a QuotaManager
+type QuotaOption func(*QuotaManager)
+
+// WithDefaultQuota applies a quota to every key without its own quota
+func WithDefaultQuota(quota Quota) QuotaOption {
+	return func(m *QuotaManager) {
+		m.defaultQuota = &quota
+	}
+}
+
+// WithQuotaEnforcement makes the client's trace wrappers reject calls with ErrQuotaExceeded,
+// without calling the provider, once the quota of the context's dimension value is used up
+func WithQuotaEnforcement() QuotaOption {
+	return func(m *QuotaManager) {
+		m.enforce = true
+	}
+}
+
+// QuotaManager tracks token and cost quotas per dimension value (e.g. per org_id). Usage is
+// loaded from storage once per key and period, then kept current from the requests the
+// client tracks, so the status of a key is cheap to check on every call.
+type QuotaManager struct {
+	client       *Client
+	dimension    string
+	defaultQuota *Quota
+	enforce      bool
+	now          func() time.Time
+
+	mu     sync.Mutex
+	quotas map[string]Quota
+	usage  map[string]*quotaUsage
+}
+
+// quotaUsage is the cached usage of a key in one period
+type quotaUsage struct {
+	periodStart time.Time
+	tokens      int64
+	cost        float64
+}
+
+// NewQuotaManager creates a quota manager keyed by the given dimension
+func NewQuotaManager(client *Client, dimension string, opts ...QuotaOption) *QuotaManager {
+	if client == nil {
+		panic("client cannot be nil")
+	}
+
+	m := &QuotaManager{
+		client:    client,
+		dimension: dimension,
+		now:       time.Now,
+		quotas:    make(map[string]Quota),
+		usage:     make(map[string]*quotaUsage),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(m)
+		}
+	}
+
+	client.observers.add(m.observe)
+	if m.enforce {
+		client.addAdmissionCheck(m.check)
+	}
+	return m
+}
+
+// SetQuota sets the quota of a dimension value, replacing the default quota
+func (m *QuotaManager) SetQuota(key string, quota Quota) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quotas[key] = quota
+}
+
+// GetQuotaStatus returns the current period's usage of a dimension value. Keys without a
+// quota report their usage with zero (unlimited) limits.
+func (m *QuotaManager) GetQuotaStatus(ctx context.Context, key string) (*QuotaStatus, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	quota, hasQuota := m.quotaFor(key)
+	start, end := quota.period(now)
+	usage, cached := m.usage[key]
+	if cached && !usage.periodStart.Equal(start) {
+		cached = false
+	}
+	m.mu.Unlock()
+
+	if !cached {
+		loaded, err := m.loadUsage(ctx, key, start)
+		if err != nil {
+			return nil, err
+		}
+
+		m.mu.Lock()
+		m.usage[key] = loaded
+		m.mu.Unlock()
+		usage = loaded
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := &QuotaStatus{
+		Key:         key,
+		PeriodStart: start,
+		ResetAt:     end,
+		TokensUsed:  usage.tokens,
+		CostUsed:    usage.cost,
+	}
+	if !hasQuota {
+		return status, nil
+	}
+
+	status.TokenLimit, status.CostLimit = quota.limits(start, end)
+	if status.TokenLimit > 0 {
+		status.TokensRemaining = max(status.TokenLimit-status.TokensUsed, 0)
+		status.Exceeded = status.TokensUsed >= status.TokenLimit
+	}
+	if status.CostLimit > 0 {
+		status.CostRemaining = max(status.CostLimit-status.CostUsed, 0)
+		status.Exceeded = status.Exceeded || status.CostUsed >= status.CostLimit
+	}
+	return status, nil
+}
+
+// quotaFor returns the quota of a key, falling back to the default quota
+func (m *QuotaManager) quotaFor(key string) (Quota, bool) {
+	if quota, ok := m.quotas[key]; ok {
+		return quota, true
+	}
+	if m.defaultQuota != nil {
+		return *m.defaultQuota, true
+	}
+	return Quota{}, false
+}
+
+// loadUsage sums the usage of a key since the start of its period from storage
+func (m *QuotaManager) loadUsage(ctx context.Context, key string, start time.Time) (*quotaUsage, error) {
+	requests, err := m.client.query(ctx, &RequestFilter{
+		StartTime:  &start,
+		Dimensions: []DimensionTag{{Key: m.dimension, Value: key}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quota usage: %w", err)
+	}
+
+	usage := &quotaUsage{periodStart: start}
+	for _, req := range requests {
+		usage.tokens = AddTokens(usage.tokens, req.InputTokens, req.OutputTokens)
+		usage.cost += req.Cost
+	}
+	return usage, nil
+}
+
+// observe adds a tracked request to the cached usage of its key
+func (m *QuotaManager) observe(request *Request) {
+	key := request.Dimension(m.dimension)
+	if key == "" {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	usage, ok := m.usage[key]
+	if !ok || request.RequestedAt.Before(usage.periodStart) {
+		return
+	}
+	usage.tokens = AddTokens(usage.tokens, request.InputTokens, request.OutputTokens)
+	usage.cost += request.Cost
+}
+
+// check is the admission check used for enforcement. It fails open when usage cannot be
+// loaded, so a storage outage does not block provider calls.
+func (m *QuotaManager) check(ctx context.Context, provider Provider, model string) error {
+	value, ok := GetDimensionsFromContext(ctx)[m.dimension]
+	if !ok {
+		return nil
+	}
+	key := fmt.Sprintf("%v", value)
+
+	status, err := m.GetQuotaStatus(ctx, key)
+	if err != nil {
+		m.client.logger.Warn("Failed to check quota",
+			slog.Any("error", err),
+			slog.String("dimension", m.dimension),
+			slog.String("key", key),
+		)
+		return nil
+	}
+	if status.Exceeded {
+		return fmt.Errorf("%w for %s %q until %s", ErrQuotaExceeded, m.dimension, key, status.ResetAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// period returns the boundaries of the period containing now
+func (q Quota) period(now time.Time) (time.Time, time.Time) {
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t := now.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+	switch q.Period {
+	case QuotaPeriodDaily:
+		return midnight, midnight.AddDate(0, 0, 1)
+	case QuotaPeriodWeekly:
+		start := midnight.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	default:
+		day := q.ResetDay
+		if day < 1 || day > 28 {
+			day = 1
+		}
+		start := time.Date(t.Year(), t.Month(), day, 0, 0, 0, 0, loc)
+		if t.Before(start) {
+			start = start.AddDate(0, -1, 0)
+		}
+		return start, start.AddDate(0, 1, 0)
+	}
+}
+
+// limits returns the limits of the period, prorated when the quota started during it
+func (q Quota) limits(start, end time.Time) (int64, float64) {
+	if !q.Prorate || !q.StartedAt.After(start) || !q.StartedAt.Before(end) {
+		return q.TokenLimit, q.CostLimit
+	}
+
+	fraction := float64(end.Sub(q.StartedAt)) / float64(end.Sub(start))
+	return int64(float64(q.TokenLimit) * fraction), q.CostLimit * fraction
+}
@@ -0,0 +1,93 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaManager(t *testing.T) {
+	now := time.Date(2024, 3, 14, 15, 0, 0, 0, time.UTC)
+
+	storage := &MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			require.Len(t, filter.Dimensions, 1)
+			if filter.Dimensions[0].Value != "acme" {
+				return nil, nil
+			}
+			return []*Request{
+				{InputTokens: 400, OutputTokens: 100, Cost: 0.5},
+				{InputTokens: 300, OutputTokens: 100, Cost: 0.25},
+			}, nil
+		},
+	}
+	client := NewClient(storage)
+	quotas := NewQuotaManager(client, "org_id", WithQuotaEnforcement())
+	quotas.now = func() time.Time { return now }
+	quotas.SetQuota("acme", Quota{TokenLimit: 1000})
+
+	t.Run("usage loaded from storage", func(t *testing.T) {
+		status, err := quotas.GetQuotaStatus(context.Background(), "acme")
+		require.NoError(t, err)
+		assert.Equal(t, int64(900), status.TokensUsed)
+		assert.Equal(t, int64(100), status.TokensRemaining)
+		assert.InDelta(t, 0.75, status.CostUsed, 1e-9)
+		assert.False(t, status.Exceeded)
+		assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), status.ResetAt)
+	})
+
+	ctx := WithDimensions(context.Background(), map[string]interface{}{"org_id": "acme"})
+	calls := 0
+	openAIFunc := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+		calls++
+		return openai.ChatCompletionResponse{Usage: openai.Usage{PromptTokens: 80, CompletionTokens: 20}}, nil
+	}
+
+	t.Run("tracked requests update usage", func(t *testing.T) {
+		_, err := client.TraceOpenAIRequest(ctx, openai.ChatCompletionRequest{Model: "gpt-4o"}, openAIFunc)
+		require.NoError(t, err)
+
+		status, err := quotas.GetQuotaStatus(context.Background(), "acme")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1000), status.TokensUsed)
+		assert.True(t, status.Exceeded)
+	})
+
+	t.Run("enforcement rejects calls", func(t *testing.T) {
+		saves := len(storage.SaveCalls)
+		_, err := client.TraceOpenAIRequest(ctx, openai.ChatCompletionRequest{Model: "gpt-4o"}, openAIFunc)
+		assert.True(t, errors.Is(err, ErrQuotaExceeded))
+		assert.Equal(t, 1, calls)
+		assert.Len(t, storage.SaveCalls, saves)
+	})
+
+	t.Run("keys without quota are unlimited", func(t *testing.T) {
+		status, err := quotas.GetQuotaStatus(context.Background(), "globex")
+		require.NoError(t, err)
+		assert.False(t, status.Exceeded)
+		assert.Zero(t, status.TokenLimit)
+
+		other := WithDimensions(context.Background(), map[string]interface{}{"org_id": "globex"})
+		_, err = client.TraceOpenAIRequest(other, openai.ChatCompletionRequest{Model: "gpt-4o"}, openAIFunc)
+		assert.NoError(t, err)
+	})
+
+	t.Run("usage resets with the period", func(t *testing.T) {
+		now = now.AddDate(0, 1, 0)
+		storage.QueryFunc = func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			return nil, nil
+		}
+
+		status, err := quotas.GetQuotaStatus(context.Background(), "acme")
+		require.NoError(t, err)
+		assert.Zero(t, status.TokensUsed)
+		assert.False(t, status.Exceeded)
+	})
+}
@@ -0,0 +1,52 @@
+package llmtracer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaPeriod(t *testing.T) {
+	now := time.Date(2024, 3, 14, 15, 0, 0, 0, time.UTC) // a Thursday
+
+	tests := []struct {
+		name  string
+		quota Quota
+		start time.Time
+		end   time.Time
+	}{
+		{"monthly default", Quota{}, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"monthly reset day passed", Quota{ResetDay: 10}, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC)},
+		{"monthly reset day ahead", Quota{ResetDay: 20}, time.Date(2024, 2, 20, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{"weekly", Quota{Period: QuotaPeriodWeekly}, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC)},
+		{"daily", Quota{Period: QuotaPeriodDaily}, time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := tt.quota.period(now)
+			assert.True(t, tt.start.Equal(start), "start: expected %s, got %s", tt.start, start)
+			assert.True(t, tt.end.Equal(end), "end: expected %s, got %s", tt.end, end)
+		})
+	}
+}
+
+func TestQuotaProration(t *testing.T) {
+	start := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	quota := Quota{
+		TokenLimit: 30_000,
+		CostLimit:  30,
+		StartedAt:  time.Date(2024, 4, 21, 0, 0, 0, 0, time.UTC),
+		Prorate:    true,
+	}
+
+	tokens, cost := quota.limits(start, end)
+	assert.Equal(t, int64(10_000), tokens)
+	assert.InDelta(t, 10.0, cost, 1e-9)
+
+	// Later periods get the full allowance
+	tokens, _ = quota.limits(end, end.AddDate(0, 1, 0))
+	assert.Equal(t, int64(30_000), tokens)
+}
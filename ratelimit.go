@@ -0,0 +1,174 @@
+package llmtracer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateWindowSeconds is the length of the sliding window used by RateLimiter
+const rateWindowSeconds = 60
+
+// RateLimits are per-minute limits for a single key. Zero means unlimited.
+type RateLimits struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// RateLimitDecision is the outcome of RateLimiter.Allow
+type RateLimitDecision struct {
+	Allowed bool
+	// Requests and Tokens are the usage in the current window, excluding this request
+	Requests int
+	Tokens   int
+	// RetryAfter estimates when capacity frees up; zero when allowed
+	RetryAfter time.Duration
+}
+
+// RateLimiter enforces per-minute request and token limits per dimension value (e.g. per
+// user_id or feature) over an in-memory sliding window. Requests are counted when Allow
+// admits them; tokens are counted from the requests the client tracks, so a call can
+// overshoot the token limit once before later calls are rejected. Limits apply to a
+// single process.
+type RateLimiter struct {
+	dimension string
+	now       func() time.Time
+
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	lastPrune int64
+}
+
+// rateWindow holds one bucket per second of the sliding window
+type rateWindow struct {
+	buckets  [rateWindowSeconds]rateBucket
+	lastSeen int64
+}
+
+type rateBucket struct {
+	second   int64
+	requests int
+	tokens   int
+}
+
+// NewRateLimiter creates a rate limiter keyed by the given dimension (e.g. "user_id") that
+// counts the tokens of every request tracked by client
+func NewRateLimiter(client *Client, dimension string) *RateLimiter {
+	if client == nil {
+		panic("client cannot be nil")
+	}
+
+	limiter := &RateLimiter{
+		dimension: dimension,
+		now:       time.Now,
+		windows:   make(map[string]*rateWindow),
+	}
+	client.observers.add(limiter.observe)
+	return limiter
+}
+
+// Allow reports whether a request for key fits within limits and, if so, counts it
+func (l *RateLimiter) Allow(ctx context.Context, key string, limits RateLimits) RateLimitDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now().Unix()
+	l.prune(now)
+	window := l.window(key)
+	requests, tokens, oldest := window.usage(now)
+
+	decision := RateLimitDecision{
+		Requests: requests,
+		Tokens:   tokens,
+	}
+	if (limits.RequestsPerMinute > 0 && requests >= limits.RequestsPerMinute) ||
+		(limits.TokensPerMinute > 0 && tokens >= limits.TokensPerMinute) {
+		decision.RetryAfter = time.Duration(oldest+rateWindowSeconds-now) * time.Second
+		return decision
+	}
+
+	decision.Allowed = true
+	window.bucket(now).requests++
+	window.lastSeen = now
+	return decision
+}
+
+// Usage returns the requests and tokens counted for key in the current window
+func (l *RateLimiter) Usage(key string) (requests, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	window, ok := l.windows[key]
+	if !ok {
+		return 0, 0
+	}
+	requests, tokens, _ = window.usage(l.now().Unix())
+	return requests, tokens
+}
+
+// observe counts the tokens of a tracked request against its dimension value
+func (l *RateLimiter) observe(request *Request) {
+	key := request.Dimension(l.dimension)
+	if key == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now().Unix()
+	window := l.window(key)
+	window.bucket(now).tokens += int(request.InputTokens + request.OutputTokens)
+	window.lastSeen = now
+}
+
+func (l *RateLimiter) window(key string) *rateWindow {
+	window, ok := l.windows[key]
+	if !ok {
+		window = &rateWindow{}
+		l.windows[key] = window
+	}
+	return window
+}
+
+// prune drops keys without activity in the current window, at most once per window
+func (l *RateLimiter) prune(now int64) {
+	if now-l.lastPrune < rateWindowSeconds {
+		return
+	}
+	l.lastPrune = now
+
+	for key, window := range l.windows {
+		if now-window.lastSeen >= rateWindowSeconds {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// bucket returns the bucket for the given second, resetting it if it held an older second
+func (w *rateWindow) bucket(second int64) *rateBucket {
+	b := &w.buckets[second%rateWindowSeconds]
+	if b.second != second {
+		*b = rateBucket{second: second}
+	}
+	return b
+}
+
+// usage sums the buckets within the window and returns the oldest active second
+func (w *rateWindow) usage(now int64) (requests, tokens int, oldest int64) {
+	oldest = now
+	for _, b := range w.buckets {
+		if now-b.second >= rateWindowSeconds || b.second > now {
+			continue
+		}
+		if b.requests == 0 && b.tokens == 0 {
+			continue
+		}
+		requests += b.requests
+		tokens += b.tokens
+		if b.second < oldest {
+			oldest = b.second
+		}
+	}
+	return requests, tokens, oldest
+}
@@ -0,0 +1,70 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	newLimiter := func() (*Client, *RateLimiter) {
+		client := NewClient(&MockStorageAdapter{})
+		limiter := NewRateLimiter(client, "user_id")
+		limiter.now = func() time.Time { return now }
+		return client, limiter
+	}
+
+	t.Run("requests per minute", func(t *testing.T) {
+		_, limiter := newLimiter()
+		limits := RateLimits{RequestsPerMinute: 2}
+
+		assert.True(t, limiter.Allow(context.Background(), "user-1", limits).Allowed)
+		assert.True(t, limiter.Allow(context.Background(), "user-1", limits).Allowed)
+
+		decision := limiter.Allow(context.Background(), "user-1", limits)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, 2, decision.Requests)
+		assert.Equal(t, time.Minute, decision.RetryAfter)
+
+		// Other keys have their own window
+		assert.True(t, limiter.Allow(context.Background(), "user-2", limits).Allowed)
+
+		// The window slides
+		now = now.Add(time.Minute)
+		assert.True(t, limiter.Allow(context.Background(), "user-1", limits).Allowed)
+	})
+
+	t.Run("tokens per minute from tracked requests", func(t *testing.T) {
+		client, limiter := newLimiter()
+		limits := RateLimits{TokensPerMinute: 100}
+
+		ctx := WithUserID(context.Background(), "user-1")
+		require.True(t, limiter.Allow(ctx, "user-1", limits).Allowed)
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 80, 40, time.Millisecond, nil, nil))
+
+		requests, tokens := limiter.Usage("user-1")
+		assert.Equal(t, 1, requests)
+		assert.Equal(t, 120, tokens)
+
+		now = now.Add(30 * time.Second)
+		decision := limiter.Allow(ctx, "user-1", limits)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, 30*time.Second, decision.RetryAfter)
+
+		// Requests without the dimension are not counted
+		require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 500, 0, time.Millisecond, nil, nil))
+		_, tokens = limiter.Usage("user-1")
+		assert.Equal(t, 120, tokens)
+	})
+
+	t.Run("zero limits are unlimited", func(t *testing.T) {
+		_, limiter := newLimiter()
+		for i := 0; i < 100; i++ {
+			assert.True(t, limiter.Allow(context.Background(), "user-1", RateLimits{}).Allowed)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// defaultRecalculateBatchSize is the number of requests Recalculate reads at a time when no
+// batch size is given
+const defaultRecalculateBatchSize = 500
+
+// RecalculateOptions configures Client.Recalculate
+type RecalculateOptions struct {
+	// BatchSize is the number of requests read and updated at a time; 500 when zero
+	BatchSize int
+	// Pricing prices the requests, defaulting to the client's pricing. Without either,
+	// costs are left unchanged.
+	Pricing *PricingRegistry
+	// DryRun counts the requests that would change without updating them
+	DryRun bool
+	// Progress is called after each batch with the totals so far
+	Progress func(RecalculateProgress)
+}
+
+// RecalculateProgress reports how far Client.Recalculate has got
+type RecalculateProgress struct {
+	// Scanned is the number of requests read
+	Scanned int64 `json:"scanned"`
+	// Updated is the number of requests whose derived fields changed, and were updated
+	// unless running dry
+	Updated int64 `json:"updated"`
+	// CostDelta is the total change in cost of the updated requests
+	CostDelta float64 `json:"cost_delta"`
+}
+
+// Recalculate re-derives TotalTokens, Cost and ErrorType of the stored requests matching
+// filter, e.g. after fixing a price or improving error categorization, and updates the
+// requests that changed. Requests are read in batches ordered by ID; the filter's Limit,
+// Offset and ordering are ignored.
+//
+// Costs are recomputed from RecalculateOptions.Pricing, or the client's pricing, for
+// models with a price, replacing costs passed explicitly when the requests were tracked.
+// Aborted streams and requests abandoned by the watchdog keep their error type. It needs
+// an adapter implementing LifecycleStorage, except for dry runs, and returns the totals,
+// which are also passed to the progress callback after each batch.
+func (c *Client) Recalculate(ctx context.Context, filter *RequestFilter, opts RecalculateOptions) (RecalculateProgress, error) {
+	var progress RecalculateProgress
+	if !opts.DryRun && !c.storage.Capabilities().Lifecycle {
+		return progress, ErrLifecycleNotSupported
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRecalculateBatchSize
+	}
+	pricing := opts.Pricing
+	if pricing == nil {
+		pricing = c.pricing
+	}
+
+	batchFilter := RequestFilter{}
+	if filter != nil {
+		batchFilter = *filter
+	}
+	batchFilter.Limit = batchSize
+	batchFilter.OrderBy = "id"
+	batchFilter.OrderDesc = false
+	batchFilter.Offset = 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		requests, err := c.query(ctx, &batchFilter)
+		if err != nil {
+			return progress, err
+		}
+		if len(requests) == 0 {
+			return progress, nil
+		}
+
+		for _, request := range requests {
+			progress.Scanned++
+			previousCost := request.Cost
+			changed := recalculate(request, pricing)
+			if changed {
+				if !opts.DryRun {
+					if err := updateRequest(ctx, c.storage, request); err != nil {
+						return progress, err
+					}
+				}
+				progress.Updated++
+				progress.CostDelta += request.Cost - previousCost
+			}
+			// Updated requests that no longer match, e.g. of a filtered error type, drop
+			// out of later pages, so only the ones still matching are skipped
+			if !changed || opts.DryRun || batchFilter.Matches(request) {
+				batchFilter.Offset++
+			}
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+		if len(requests) < batchSize {
+			return progress, nil
+		}
+	}
+}
+
+// recalculate re-derives the computed fields of a stored request, reporting whether any
+// changed
+func recalculate(request *Request, pricing *PricingRegistry) bool {
+	changed := false
+
+	if totalTokens := AddTokens(request.InputTokens, request.OutputTokens); totalTokens != request.TotalTokens {
+		request.TotalTokens = totalTokens
+		changed = true
+	}
+
+	if pricing != nil {
+		if cost, ok := pricing.RequestCost(request); ok && cost != request.Cost {
+			request.Cost = cost
+			changed = true
+		}
+	}
+
+	errorType := ErrorTypeNone
+	switch {
+	case request.ErrorType == ErrorTypeAborted || request.Dimension(DimensionAbandoned) == "true":
+		errorType = request.ErrorType
+	case request.Error != "":
+		// 500 is also recorded for failed calls without a status, so it is not passed on
+		statusCode := request.StatusCode
+		if statusCode == http.StatusInternalServerError {
+			statusCode = 0
+		}
+		errorType = CategorizeErrorInfo(ErrorInfo{Provider: request.Provider, StatusCode: statusCode, Err: errors.New(request.Error)})
+	}
+	if errorType != request.ErrorType {
+		request.ErrorType = errorType
+		changed = true
+	}
+
+	return changed
+}
@@ -0,0 +1,88 @@
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecalculate(t *testing.T) {
+	ctx := context.Background()
+	pricing := NewPricingRegistry()
+	pricing.Set(ProviderOpenAI, "gpt-4o", ModelPrice{InputPerMillion: 2, OutputPerMillion: 8})
+
+	seed := func() *lifecycleStorage {
+		storage := newLifecycleStorage()
+		for i := 0; i < 5; i++ {
+			id := fmt.Sprintf("unpriced-%d", i)
+			storage.requests[id] = Request{ID: id, Provider: ProviderOpenAI, Model: "gpt-4o", InputTokens: 1_000_000, OutputTokens: 1_000_000}
+		}
+		storage.requests["current"] = Request{ID: "current", Provider: ProviderOpenAI, Model: "gpt-4o", InputTokens: 1_000_000, TotalTokens: 1_000_000, Cost: 2}
+		storage.requests["unknown-model"] = Request{ID: "unknown-model", Provider: ProviderOpenAI, Model: "custom", InputTokens: 10, TotalTokens: 10, Cost: 1}
+		storage.requests["rate-limited"] = Request{ID: "rate-limited", Provider: ProviderOpenAI, Model: "custom", Error: "429 too many requests", ErrorType: ErrorTypeUnknown}
+		storage.requests["aborted"] = Request{ID: "aborted", Provider: ProviderOpenAI, Model: "custom", Error: "stream aborted", ErrorType: ErrorTypeAborted}
+		return storage
+	}
+
+	t.Run("Updates the requests that changed", func(t *testing.T) {
+		storage := seed()
+		client := NewClient(storage, WithPricing(pricing))
+
+		var reports []RecalculateProgress
+		progress, err := client.Recalculate(ctx, nil, RecalculateOptions{
+			BatchSize: 3,
+			Progress:  func(p RecalculateProgress) { reports = append(reports, p) },
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(9), progress.Scanned)
+		assert.Equal(t, int64(6), progress.Updated)
+		assert.InDelta(t, 50.0, progress.CostDelta, 1e-9)
+		assert.Len(t, reports, 3)
+		assert.Equal(t, progress, reports[len(reports)-1])
+
+		require.Len(t, storage.updates, 6)
+		updated := storage.requests["unpriced-0"]
+		assert.Equal(t, int64(2_000_000), updated.TotalTokens)
+		assert.Equal(t, 10.0, updated.Cost)
+		assert.Equal(t, ErrorTypeRateLimit, storage.requests["rate-limited"].ErrorType)
+		assert.Equal(t, ErrorTypeAborted, storage.requests["aborted"].ErrorType)
+		assert.Equal(t, 1.0, storage.requests["unknown-model"].Cost, "models without a price keep their cost")
+	})
+
+	t.Run("Requests leaving the filter are not skipped", func(t *testing.T) {
+		storage := newLifecycleStorage()
+		for i := 0; i < 7; i++ {
+			id := fmt.Sprintf("request-%d", i)
+			storage.requests[id] = Request{ID: id, Error: "rate limit exceeded", ErrorType: ErrorTypeUnknown}
+		}
+		client := NewClient(storage)
+
+		progress, err := client.Recalculate(ctx, &RequestFilter{ErrorType: ErrorTypeUnknown}, RecalculateOptions{BatchSize: 2})
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), progress.Updated)
+		for _, request := range storage.requests {
+			assert.Equal(t, ErrorTypeRateLimit, request.ErrorType)
+		}
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		storage := seed()
+		progress, err := NewClient(storage).Recalculate(ctx, nil, RecalculateOptions{Pricing: pricing, BatchSize: 4})
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), progress.Updated)
+
+		storage = seed()
+		progress, err = NewClient(storage).Recalculate(ctx, nil, RecalculateOptions{Pricing: pricing, DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), progress.Updated)
+		assert.Empty(t, storage.updates)
+	})
+
+	t.Run("Needs lifecycle storage", func(t *testing.T) {
+		_, err := NewClient(&MockStorageAdapter{}).Recalculate(ctx, nil, RecalculateOptions{})
+		assert.ErrorIs(t, err, ErrLifecycleNotSupported)
+	})
+}
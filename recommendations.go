@@ -0,0 +1,122 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrPricingNotConfigured is returned by analyses that need a pricing registry
+var ErrPricingNotConfigured = errors.New("pricing is not configured; use WithPricing")
+
+// ModelRef identifies a provider model
+type ModelRef struct {
+	Provider Provider `json:"provider"`
+	Model    string   `json:"model"`
+}
+
+// ModelCostComparison is the observed usage of a model for one dimension value, priced
+// on alternative models
+type ModelCostComparison struct {
+	DimensionValue string   `json:"dimension_value"`
+	Provider       Provider `json:"provider"`
+	Model          string   `json:"model"`
+	Requests       int64    `json:"requests"`
+	InputTokens    int64    `json:"input_tokens"`
+	OutputTokens   int64    `json:"output_tokens"`
+	// Cost is the recorded cost, or the priced cost for requests recorded without one
+	Cost         float64           `json:"cost"`
+	Alternatives []AlternativeCost `json:"alternatives"`
+}
+
+// AlternativeCost is what the observed tokens would have cost on another model
+type AlternativeCost struct {
+	Provider Provider `json:"provider"`
+	Model    string   `json:"model"`
+	Cost     float64  `json:"cost"`
+	// Savings is the fraction of the current cost saved (negative when more expensive)
+	Savings float64 `json:"savings"`
+}
+
+// CompareModelCosts reprices the observed token counts of each dimension value (e.g. each
+// feature) and model on the candidate models, to support downgrade decisions such as
+// "feature X on gpt-4o-mini would cost 84% less". Results are ordered by dimension value
+// and then by cost, most expensive first; alternatives are ordered cheapest first and
+// skip the current model and candidates without a price. Quality differences between
+// models are not considered.
+func (c *Client) CompareModelCosts(ctx context.Context, dimension string, candidates []ModelRef, filter *RequestFilter) ([]*ModelCostComparison, error) {
+	if c.pricing == nil {
+		return nil, ErrPricingNotConfigured
+	}
+	if filter == nil {
+		filter = &RequestFilter{}
+	}
+
+	requests, err := c.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	comparisons := make(map[string]*ModelCostComparison)
+	for _, req := range requests {
+		value := req.Dimension(dimension)
+		key := fmt.Sprintf("%s\x00%s\x00%s", value, req.Provider, req.Model)
+		comparison, exists := comparisons[key]
+		if !exists {
+			comparison = &ModelCostComparison{
+				DimensionValue: value,
+				Provider:       req.Provider,
+				Model:          req.Model,
+			}
+			comparisons[key] = comparison
+		}
+
+		comparison.Requests++
+		comparison.InputTokens = AddTokens(comparison.InputTokens, req.InputTokens)
+		comparison.OutputTokens = AddTokens(comparison.OutputTokens, req.OutputTokens)
+
+		cost := req.Cost
+		if cost == 0 {
+			cost, _ = c.pricing.RequestCost(req)
+		}
+		comparison.Cost += cost
+	}
+
+	results := make([]*ModelCostComparison, 0, len(comparisons))
+	for _, comparison := range comparisons {
+		for _, candidate := range candidates {
+			if candidate.Provider == comparison.Provider && candidate.Model == comparison.Model {
+				continue
+			}
+			price, ok := c.pricing.Lookup(candidate.Provider, candidate.Model)
+			if !ok {
+				continue
+			}
+
+			alternative := AlternativeCost{
+				Provider: candidate.Provider,
+				Model:    candidate.Model,
+				// Cost charges the flat price once; the other requests pay it too
+				Cost: price.Cost(comparison.InputTokens, comparison.OutputTokens) + float64(comparison.Requests-1)*price.PerRequest,
+			}
+			if comparison.Cost > 0 {
+				alternative.Savings = 1 - alternative.Cost/comparison.Cost
+			}
+			comparison.Alternatives = append(comparison.Alternatives, alternative)
+		}
+		sort.Slice(comparison.Alternatives, func(i, j int) bool {
+			return comparison.Alternatives[i].Cost < comparison.Alternatives[j].Cost
+		})
+		results = append(results, comparison)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].DimensionValue != results[j].DimensionValue {
+			return results[i].DimensionValue < results[j].DimensionValue
+		}
+		return results[i].Cost > results[j].Cost
+	})
+
+	return results, nil
+}
@@ -0,0 +1,60 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareModelCosts(t *testing.T) {
+	pricing := NewPricingRegistry()
+	pricing.Set(ProviderOpenAI, "gpt-4o", ModelPrice{InputPerMillion: 2.5, OutputPerMillion: 10})
+	pricing.Set(ProviderOpenAI, "gpt-4o-mini", ModelPrice{InputPerMillion: 0.15, OutputPerMillion: 0.6})
+	pricing.Set(ProviderAnthropic, "claude-3-5-sonnet-latest", ModelPrice{InputPerMillion: 3, OutputPerMillion: 15})
+
+	search := []DimensionTag{{Key: "feature", Value: "search"}}
+	storage := &MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			return []*Request{
+				{Provider: ProviderOpenAI, Model: "gpt-4o", InputTokens: 1_000_000, OutputTokens: 0, Cost: 2.5, Dimensions: search},
+				// Recorded without a cost; priced on the fly
+				{Provider: ProviderOpenAI, Model: "gpt-4o", InputTokens: 0, OutputTokens: 250_000, Dimensions: search},
+				{Provider: ProviderOpenAI, Model: "gpt-4o-mini", InputTokens: 1_000_000, Dimensions: []DimensionTag{{Key: "feature", Value: "chat"}}},
+			}, nil
+		},
+	}
+
+	t.Run("requires pricing", func(t *testing.T) {
+		_, err := NewClient(storage).CompareModelCosts(context.Background(), "feature", nil, nil)
+		assert.ErrorIs(t, err, ErrPricingNotConfigured)
+	})
+
+	client := NewClient(storage, WithPricing(pricing))
+	results, err := client.CompareModelCosts(context.Background(), "feature", []ModelRef{
+		{Provider: ProviderOpenAI, Model: "gpt-4o-mini"},
+		{Provider: ProviderAnthropic, Model: "claude-3-5-sonnet-latest"},
+		{Provider: ProviderMistral, Model: "unpriced"},
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	chat, searchResult := results[0], results[1]
+	assert.Equal(t, "chat", chat.DimensionValue)
+	assert.Len(t, chat.Alternatives, 1)
+
+	assert.Equal(t, "search", searchResult.DimensionValue)
+	assert.Equal(t, int64(2), searchResult.Requests)
+	assert.InDelta(t, 5.0, searchResult.Cost, 1e-9)
+	require.Len(t, searchResult.Alternatives, 2)
+
+	mini := searchResult.Alternatives[0]
+	assert.Equal(t, "gpt-4o-mini", mini.Model)
+	assert.InDelta(t, 0.3, mini.Cost, 1e-9)
+	assert.InDelta(t, 0.94, mini.Savings, 1e-9)
+
+	sonnet := searchResult.Alternatives[1]
+	assert.InDelta(t, 6.75, sonnet.Cost, 1e-9)
+	assert.InDelta(t, -0.35, sonnet.Savings, 1e-9)
+}
@@ -0,0 +1,52 @@
+package llmtracer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StorageFactory opens a storage adapter from a data source name
+type StorageFactory func(dsn string) (StorageAdapter, error)
+
+var (
+	storageFactoriesMu sync.RWMutex
+	storageFactories   = make(map[string]StorageFactory)
+)
+
+// RegisterStorage makes a storage adapter available by name to NewFromConfig. Adapters live
+// outside this package, so applications register the ones they link in, e.g.
+// RegisterStorage("postgres", adapters.GormStorageFactory(postgres.Open)).
+func RegisterStorage(name string, factory StorageFactory) {
+	if factory == nil {
+		panic("storage factory cannot be nil")
+	}
+
+	storageFactoriesMu.Lock()
+	defer storageFactoriesMu.Unlock()
+	storageFactories[name] = factory
+}
+
+// OpenStorage opens a storage adapter using the factory registered under name
+func OpenStorage(name, dsn string) (StorageAdapter, error) {
+	storageFactoriesMu.RLock()
+	factory, ok := storageFactories[name]
+	storageFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage type %q (registered: %v)", name, registeredStorage())
+	}
+
+	return factory(dsn)
+}
+
+func registeredStorage() []string {
+	storageFactoriesMu.RLock()
+	defer storageFactoriesMu.RUnlock()
+
+	names := make([]string, 0, len(storageFactories))
+	for name := range storageFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
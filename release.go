@@ -0,0 +1,203 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DimensionRelease is the release version stamped on every request by WithRelease, next
+// to the commit in DimensionGitSHA
+const DimensionRelease = "release"
+
+// WithRelease stamps every request with the release version and git commit the service was
+// built from, as the release and git_sha dimensions, so a cost or error regression can be
+// pinned to a deploy with CompareReleases. Empty values are skipped, and dimensions set by
+// the call take precedence.
+func WithRelease(version, commit string) ClientOption {
+	return func(c *Client) {
+		c.addDefaultDimensions(map[string]string{
+			DimensionRelease: version,
+			DimensionGitSHA:  commit,
+		})
+	}
+}
+
+// ReleaseStats summarizes the requests tracked under one release
+type ReleaseStats struct {
+	Release string `json:"release"`
+	// Commits lists the commits recorded with the release, sorted
+	Commits           []string      `json:"commits,omitempty"`
+	TotalRequests     int64         `json:"total_requests"`
+	ErrorCount        int64         `json:"error_count"`
+	ErrorRate         float64       `json:"error_rate"`
+	TotalCost         float64       `json:"total_cost"`
+	AvgCost           float64       `json:"avg_cost"`
+	TotalInputTokens  int64         `json:"total_input_tokens"`
+	TotalOutputTokens int64         `json:"total_output_tokens"`
+	AvgTokens         float64       `json:"avg_tokens"`
+	AvgLatency        time.Duration `json:"avg_latency"`
+
+	totalLatency time.Duration
+}
+
+// ReleaseComparison compares a candidate release with a baseline. Deltas are candidate
+// minus baseline, and changes are the delta relative to the baseline (0 when the baseline
+// is 0). Per-request averages are compared so releases serving different traffic stay
+// comparable.
+type ReleaseComparison struct {
+	Baseline  *ReleaseStats `json:"baseline"`
+	Candidate *ReleaseStats `json:"candidate"`
+
+	AvgCostDelta    float64       `json:"avg_cost_delta"`
+	AvgCostChange   float64       `json:"avg_cost_change"`
+	AvgTokensDelta  float64       `json:"avg_tokens_delta"`
+	AvgTokensChange float64       `json:"avg_tokens_change"`
+	AvgLatencyDelta time.Duration `json:"avg_latency_delta"`
+	LatencyChange   float64       `json:"latency_change"`
+	ErrorRateDelta  float64       `json:"error_rate_delta"`
+}
+
+// GetReleaseStats summarizes the requests matching filter per release recorded by
+// WithRelease, ordered by release. Requests without a release are left out. A nil filter
+// covers every request.
+//
+// The requests are aggregated by the storage adapter; adapters that cannot aggregate are
+// aggregated in memory instead.
+func (c *Client) GetReleaseStats(ctx context.Context, filter *RequestFilter) ([]*ReleaseStats, error) {
+	var stats map[string]*ReleaseStats
+	var err error
+	if c.storage.Capabilities().Aggregate {
+		stats, err = c.releaseStatsFromAggregates(ctx, filter)
+	}
+	if !c.storage.Capabilities().Aggregate || errors.Is(err, ErrAggregateNotSupported) {
+		stats, err = c.releaseStatsFromQuery(ctx, filter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]*ReleaseStats, 0, len(stats))
+	for _, s := range stats {
+		n := float64(s.TotalRequests)
+		s.ErrorRate = float64(s.ErrorCount) / n
+		s.AvgCost = s.TotalCost / n
+		s.AvgTokens = float64(s.TotalInputTokens+s.TotalOutputTokens) / n
+		s.AvgLatency = s.totalLatency / time.Duration(s.TotalRequests)
+		sort.Strings(s.Commits)
+		releases = append(releases, s)
+	}
+	sort.Slice(releases, func(i, j int) bool { return releases[i].Release < releases[j].Release })
+	return releases, nil
+}
+
+// CompareReleases compares the requests of the candidate release matching filter with
+// those of the baseline release on cost, tokens, latency and errors, e.g. to check a deploy
+// against the one before it. Restrict filter to the same time window or workload to keep
+// the comparison fair.
+func (c *Client) CompareReleases(ctx context.Context, baseline, candidate string, filter *RequestFilter) (*ReleaseComparison, error) {
+	if baseline == "" || candidate == "" {
+		return nil, fmt.Errorf("release cannot be empty")
+	}
+	stats, err := c.GetReleaseStats(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	byRelease := make(map[string]*ReleaseStats, len(stats))
+	for _, s := range stats {
+		byRelease[s.Release] = s
+	}
+	base, ok := byRelease[baseline]
+	if !ok {
+		return nil, fmt.Errorf("no requests found for release %q", baseline)
+	}
+	cand, ok := byRelease[candidate]
+	if !ok {
+		return nil, fmt.Errorf("no requests found for release %q", candidate)
+	}
+
+	return &ReleaseComparison{
+		Baseline:        base,
+		Candidate:       cand,
+		AvgCostDelta:    cand.AvgCost - base.AvgCost,
+		AvgCostChange:   relativeChange(base.AvgCost, cand.AvgCost),
+		AvgTokensDelta:  cand.AvgTokens - base.AvgTokens,
+		AvgTokensChange: relativeChange(base.AvgTokens, cand.AvgTokens),
+		AvgLatencyDelta: cand.AvgLatency - base.AvgLatency,
+		LatencyChange:   relativeChange(float64(base.AvgLatency), float64(cand.AvgLatency)),
+		ErrorRateDelta:  cand.ErrorRate - base.ErrorRate,
+	}, nil
+}
+
+// releaseStatsFromAggregates totals the requests grouped by release and commit
+func (c *Client) releaseStatsFromAggregates(ctx context.Context, filter *RequestFilter) (map[string]*ReleaseStats, error) {
+	results, err := c.storage.Aggregate(ctx, []string{GroupByDimension(DimensionRelease), GroupByDimension(DimensionGitSHA)}, filter)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]*ReleaseStats)
+	for _, result := range results {
+		if result.TotalRequests == 0 {
+			continue
+		}
+		s := releaseStatsFor(stats, result.Dimension(DimensionRelease), result.Dimension(DimensionGitSHA))
+		if s == nil {
+			continue
+		}
+		s.TotalRequests += result.TotalRequests
+		s.ErrorCount += result.ErrorCount
+		s.TotalCost += result.TotalCost
+		s.TotalInputTokens = AddTokens(s.TotalInputTokens, result.TotalInputTokens)
+		s.TotalOutputTokens = AddTokens(s.TotalOutputTokens, result.TotalOutputTokens)
+		s.totalLatency += result.AvgLatency * time.Duration(result.TotalRequests)
+	}
+	return stats, nil
+}
+
+// releaseStatsFromQuery totals the requests per release in memory
+func (c *Client) releaseStatsFromQuery(ctx context.Context, filter *RequestFilter) (map[string]*ReleaseStats, error) {
+	requests, err := c.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]*ReleaseStats)
+	for _, req := range requests {
+		s := releaseStatsFor(stats, req.Dimension(DimensionRelease), req.Dimension(DimensionGitSHA))
+		if s == nil {
+			continue
+		}
+		s.TotalRequests++
+		if req.Error != "" {
+			s.ErrorCount++
+		}
+		s.TotalCost += req.Cost
+		s.TotalInputTokens = AddTokens(s.TotalInputTokens, req.InputTokens)
+		s.TotalOutputTokens = AddTokens(s.TotalOutputTokens, req.OutputTokens)
+		s.totalLatency += req.Latency
+	}
+	return stats, nil
+}
+
+// releaseStatsFor returns the stats of release, recording commit with it, or nil for
+// requests without a release
+func releaseStatsFor(stats map[string]*ReleaseStats, release, commit string) *ReleaseStats {
+	if release == "" {
+		return nil
+	}
+	s, ok := stats[release]
+	if !ok {
+		s = &ReleaseStats{Release: release}
+		stats[release] = s
+	}
+	if commit != "" {
+		for _, known := range s.Commits {
+			if known == commit {
+				return s
+			}
+		}
+		s.Commits = append(s.Commits, commit)
+	}
+	return s
+}
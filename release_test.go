@@ -0,0 +1,89 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRelease(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithServiceInfo("checkout", "", ""), WithRelease("v1.5.0", "abc123"))
+
+	ctx := WithDimensions(context.Background(), map[string]interface{}{DimensionGitSHA: "local"})
+	require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+
+	require.Len(t, storage.SaveCalls, 2)
+	dimensions := storage.SaveCalls[0].Request.DimensionsMap()
+	assert.Equal(t, "v1.5.0", dimensions[DimensionRelease])
+	assert.Equal(t, "abc123", dimensions[DimensionGitSHA])
+	assert.Equal(t, "checkout", dimensions[DimensionService], "service info is kept")
+	assert.Equal(t, "local", storage.SaveCalls[1].Request.Dimension(DimensionGitSHA), "the call's dimensions take precedence")
+}
+
+func TestCompareReleases(t *testing.T) {
+	ctx := context.Background()
+	release := func(version, commit string) []DimensionTag {
+		return []DimensionTag{{Key: DimensionRelease, Value: version}, {Key: DimensionGitSHA, Value: commit}}
+	}
+
+	t.Run("In memory", func(t *testing.T) {
+		requests := []*Request{
+			{Cost: 0.01, InputTokens: 100, OutputTokens: 50, Latency: 100 * time.Millisecond, Dimensions: release("v1", "aaa")},
+			{Cost: 0.01, InputTokens: 100, OutputTokens: 50, Latency: 300 * time.Millisecond, Dimensions: release("v1", "aaa")},
+			{Cost: 0.03, InputTokens: 200, OutputTokens: 100, Latency: 400 * time.Millisecond, Dimensions: release("v2", "bbb")},
+			{Cost: 0.01, InputTokens: 100, OutputTokens: 50, Latency: 400 * time.Millisecond, Error: "boom", Dimensions: release("v2", "ccc")},
+			{Cost: 1, InputTokens: 1000, OutputTokens: 1000},
+		}
+		storage := &MockStorageAdapter{QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			return requests, nil
+		}}
+		client := NewClient(storage)
+
+		stats, err := client.GetReleaseStats(ctx, nil)
+		require.NoError(t, err)
+		require.Len(t, stats, 2, "requests without a release are left out")
+		assert.Equal(t, "v1", stats[0].Release)
+		assert.Equal(t, []string{"bbb", "ccc"}, stats[1].Commits)
+
+		comparison, err := client.CompareReleases(ctx, "v1", "v2", nil)
+		require.NoError(t, err)
+		assert.InDelta(t, 0.01, comparison.Baseline.AvgCost, 1e-9)
+		assert.InDelta(t, 0.02, comparison.Candidate.AvgCost, 1e-9)
+		assert.InDelta(t, 0.01, comparison.AvgCostDelta, 1e-9)
+		assert.InDelta(t, 1.0, comparison.AvgCostChange, 1e-9)
+		assert.InDelta(t, 75.0, comparison.AvgTokensDelta, 1e-9)
+		assert.Equal(t, 200*time.Millisecond, comparison.AvgLatencyDelta)
+		assert.InDelta(t, 0.5, comparison.ErrorRateDelta, 1e-9)
+
+		_, err = client.CompareReleases(ctx, "v1", "v3", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Aggregated", func(t *testing.T) {
+		var groupBy []string
+		storage := &MockStorageAdapter{AggregateFunc: func(ctx context.Context, g []string, filter *RequestFilter) ([]*AggregateResult, error) {
+			groupBy = g
+			return []*AggregateResult{
+				{TotalRequests: 2, TotalCost: 0.02, TotalInputTokens: 200, TotalOutputTokens: 100, AvgLatency: 200 * time.Millisecond, Dimensions: release("v1", "aaa")},
+				{TotalRequests: 1, TotalCost: 0.03, TotalInputTokens: 200, TotalOutputTokens: 100, AvgLatency: 400 * time.Millisecond, Dimensions: release("v2", "bbb")},
+				{TotalRequests: 3, TotalCost: 0.03, TotalInputTokens: 300, TotalOutputTokens: 150, AvgLatency: 800 * time.Millisecond, ErrorCount: 3, Dimensions: release("v2", "ccc")},
+				{TotalRequests: 5, TotalCost: 5},
+			}, nil
+		}}
+		client := NewClient(storage)
+
+		comparison, err := client.CompareReleases(ctx, "v1", "v2", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{GroupByDimension(DimensionRelease), GroupByDimension(DimensionGitSHA)}, groupBy)
+		assert.Equal(t, int64(4), comparison.Candidate.TotalRequests)
+		assert.Equal(t, []string{"bbb", "ccc"}, comparison.Candidate.Commits)
+		assert.InDelta(t, 0.015, comparison.Candidate.AvgCost, 1e-9)
+		assert.Equal(t, 700*time.Millisecond, comparison.Candidate.AvgLatency)
+		assert.InDelta(t, 0.75, comparison.ErrorRateDelta, 1e-9)
+	})
+}
@@ -0,0 +1,93 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// DimensionReplayOf links a replayed request to the ID of the request it re-issued
+const DimensionReplayOf = "replay_of"
+
+// ErrNoPayload is returned for requests that were tracked without WithPayloadCapture
+var ErrNoPayload = errors.New("request has no captured payload")
+
+// ReplayFunc re-issues a captured request. The context carries the replay trace ID and the
+// replay_of dimension, so the call should go through a trace wrapper or the HTTP transport
+// of the same client to be tracked.
+type ReplayFunc func(ctx context.Context, original *Request) error
+
+// ReplayResult pairs a captured request with its replay
+type ReplayResult struct {
+	Original *Request
+	// Replay is the tracked replay, or nil when the replay function did not track a request
+	Replay *Request
+	Err    error
+}
+
+// Replay re-issues every request matching filter that has a captured payload, one at a
+// time in the order they were originally made. All replays of a run share a new trace ID,
+// keep the original prompt version, and record the original's ID in the replay_of
+// dimension. Requests without a payload are reported with ErrNoPayload. Use it to
+// regression-test prompts against new model versions:
+//
+//	results, err := tracer.Replay(ctx, &llmtracer.RequestFilter{PromptVersion: "v7"},
+//		llmtracer.OpenAIReplay(tracer, openaiClient.CreateChatCompletion, "gpt-4o-2024-11-20"))
+func (c *Client) Replay(ctx context.Context, filter *RequestFilter, replay ReplayFunc) ([]*ReplayResult, error) {
+	if replay == nil {
+		return nil, fmt.Errorf("replay function cannot be nil")
+	}
+	if filter == nil {
+		filter = &RequestFilter{}
+	}
+
+	originals, err := c.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	// Adapters order differently; replays always run in request order
+	sort.SliceStable(originals, func(i, j int) bool {
+		if !originals[i].RequestedAt.Equal(originals[j].RequestedAt) {
+			return originals[i].RequestedAt.Before(originals[j].RequestedAt)
+		}
+		return originals[i].ID < originals[j].ID
+	})
+
+	runCtx := WithTraceID(ctx, uuid.New().String())
+	results := make([]*ReplayResult, 0, len(originals))
+	for _, original := range originals {
+		result := &ReplayResult{Original: original}
+		results = append(results, result)
+		if original.RequestPayload == "" {
+			result.Err = ErrNoPayload
+			continue
+		}
+
+		replayCtx, handle := WithRequestHandle(replayContext(runCtx, original))
+		result.Err = replay(replayCtx, original)
+		result.Replay = handle.Request()
+	}
+
+	return results, nil
+}
+
+// replayContext adds the replay_of dimension and the original prompt version to ctx,
+// keeping any dimensions already set on it
+func replayContext(ctx context.Context, original *Request) context.Context {
+	dimensions := make(map[string]interface{})
+	if existing, ok := ctx.Value(dimensionsKey).(map[string]interface{}); ok {
+		for k, v := range existing {
+			dimensions[k] = v
+		}
+	}
+	dimensions[DimensionReplayOf] = original.ID
+
+	ctx = WithDimensions(ctx, dimensions)
+	if original.PromptVersion != "" {
+		ctx = WithPromptVersion(ctx, original.PromptVersion)
+	}
+	return ctx
+}
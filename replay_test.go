@@ -0,0 +1,94 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplay(t *testing.T) {
+	// Capture an original request with its payload
+	captureStorage := &MockStorageAdapter{}
+	capturing := NewClient(captureStorage, WithPayloadCapture(true))
+	createChatCompletion := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+		return openai.ChatCompletionResponse{Usage: openai.Usage{PromptTokens: 10, CompletionTokens: 4}}, nil
+	}
+	ctx := WithPromptVersion(context.Background(), "summarize-v2")
+	_, err := capturing.TraceOpenAIRequest(ctx, openai.ChatCompletionRequest{
+		Model:       "gpt-4o-mini",
+		Temperature: 0,
+		Messages:    []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Summarize this"}},
+	}, createChatCompletion)
+	require.NoError(t, err)
+	require.Len(t, captureStorage.SaveCalls, 1)
+	original := captureStorage.SaveCalls[0].Request
+	require.NotEmpty(t, original.RequestPayload)
+
+	withoutPayload := &Request{ID: "no-payload", Provider: ProviderOpenAI, RequestedAt: original.RequestedAt.Add(-time.Minute)}
+
+	storage := &MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			return []*Request{original, withoutPayload}, nil
+		},
+	}
+	client := NewClient(storage)
+
+	var replayedModels []string
+	var replayedContent string
+	replayCreate := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+		replayedModels = append(replayedModels, request.Model)
+		replayedContent = request.Messages[0].Content
+		return openai.ChatCompletionResponse{Usage: openai.Usage{PromptTokens: 10, CompletionTokens: 6}}, nil
+	}
+
+	results, err := client.Replay(context.Background(), &RequestFilter{PromptVersion: "summarize-v2"},
+		OpenAIReplay(client, replayCreate, "gpt-4o"))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	// Replays run in original request order
+	assert.Equal(t, withoutPayload, results[0].Original)
+	assert.ErrorIs(t, results[0].Err, ErrNoPayload)
+	assert.Nil(t, results[0].Replay)
+
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, []string{"gpt-4o"}, replayedModels)
+	assert.Equal(t, "Summarize this", replayedContent)
+
+	require.Len(t, storage.SaveCalls, 1)
+	replayed := storage.SaveCalls[0].Request
+	require.NotNil(t, results[1].Replay)
+	assert.Equal(t, replayed.TraceID, results[1].Replay.TraceID)
+	assert.Equal(t, original.ID, replayed.Dimension(DimensionReplayOf))
+	assert.Equal(t, "summarize-v2", replayed.PromptVersion)
+	assert.Equal(t, "gpt-4o", replayed.Model)
+	assert.NotEqual(t, original.TraceID, replayed.TraceID)
+}
+
+func TestReplayErrors(t *testing.T) {
+	queryErr := errors.New("query failed")
+	storage := &MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			return nil, queryErr
+		},
+	}
+	client := NewClient(storage)
+
+	_, err := client.Replay(context.Background(), nil, nil)
+	assert.Error(t, err)
+
+	_, err = client.Replay(context.Background(), nil, func(ctx context.Context, original *Request) error { return nil })
+	assert.ErrorIs(t, err, queryErr)
+
+	// Undecodable payloads are reported per request
+	replay := OpenAIReplay(client, nil, "")
+	err = replay(context.Background(), &Request{RequestPayload: "not json"})
+	assert.ErrorContains(t, err, "failed to decode OpenAI payload")
+}
@@ -0,0 +1,91 @@
+package llmtracer
+
+import "context"
+
+// WithCostRecomputation sets Request.RecomputedCost on reads through the client from the
+// prices in pricing at read time. See NewRepricedStorage.
+func WithCostRecomputation(pricing *PricingRegistry) ClientOption {
+	return func(c *Client) {
+		c.storage = NewRepricedStorage(c.storage, pricing)
+	}
+}
+
+// NewRepricedStorage wraps storage so that requests returned by Get, GetByTraceID and
+// Query carry RecomputedCost, their cost at the prices currently in pricing, next to the
+// Cost stored when they were tracked. This shows what rows saved before pricing was
+// configured, or with stale rates, would cost today, without rewriting them.
+//
+// Requests whose model has no price keep a nil RecomputedCost. Aggregates are not
+// repriced and still total the stored cost.
+func NewRepricedStorage(storage StorageAdapter, pricing *PricingRegistry) StorageAdapter {
+	if storage == nil {
+		panic("storage adapter cannot be nil")
+	}
+	if pricing == nil {
+		panic("pricing registry cannot be nil")
+	}
+	return preserveSoftDelete(&repricedStorage{StorageAdapter: storage, pricing: pricing}, storage)
+}
+
+// repricedStorage recomputes the cost of requests on the way out of the wrapped adapter
+type repricedStorage struct {
+	StorageAdapter
+	pricing *PricingRegistry
+}
+
+func (s *repricedStorage) unwrap() StorageAdapter {
+	return s.StorageAdapter
+}
+
+func (s *repricedStorage) Capabilities() StorageCapabilities {
+	return wrappedCapabilities(s.StorageAdapter)
+}
+
+func (s *repricedStorage) Update(ctx context.Context, request *Request) error {
+	return updateRequest(ctx, s.StorageAdapter, request)
+}
+
+func (s *repricedStorage) Get(ctx context.Context, id string) (*Request, error) {
+	request, err := s.StorageAdapter.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.reprice(request)
+	return request, nil
+}
+
+func (s *repricedStorage) GetByTraceID(ctx context.Context, traceID string) ([]*Request, error) {
+	requests, err := s.StorageAdapter.GetByTraceID(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, request := range requests {
+		s.reprice(request)
+	}
+	return requests, nil
+}
+
+func (s *repricedStorage) Query(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+	requests, err := s.StorageAdapter.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, request := range requests {
+		s.reprice(request)
+	}
+	return requests, nil
+}
+
+func (s *repricedStorage) AggregateMulti(ctx context.Context, specs []AggregateSpec) ([][]*AggregateResult, error) {
+	return aggregateMulti(ctx, s.StorageAdapter, specs)
+}
+
+// reprice sets the recomputed cost of a request whose model has a price
+func (s *repricedStorage) reprice(request *Request) {
+	if request == nil {
+		return
+	}
+	if cost, ok := s.pricing.RequestCost(request); ok {
+		request.RecomputedCost = &cost
+	}
+}
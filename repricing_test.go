@@ -0,0 +1,56 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepricedStorage(t *testing.T) {
+	ctx := context.Background()
+	pricing := NewPricingRegistry()
+	pricing.Set(ProviderOpenAI, "gpt-4o", ModelPrice{InputPerMillion: 2, OutputPerMillion: 8})
+
+	mock, stored := newRecordingStorage()
+	stored["priced"] = &Request{ID: "priced", Provider: ProviderOpenAI, Model: "gpt-4o", InputTokens: 1_000_000, OutputTokens: 1_000_000}
+	stored["stale"] = &Request{ID: "stale", Provider: ProviderOpenAI, Model: "gpt-4o", InputTokens: 1_000_000, Cost: 5}
+	stored["unpriced"] = &Request{ID: "unpriced", Provider: ProviderAnthropic, Model: "claude-x", InputTokens: 10, Cost: 1}
+	client := NewClient(mock, WithCostRecomputation(pricing))
+
+	request, err := client.storage.Get(ctx, "stale")
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, request.Cost, "the stored cost is kept")
+	require.NotNil(t, request.RecomputedCost)
+	assert.Equal(t, 2.0, *request.RecomputedCost)
+
+	requests, err := client.storage.Query(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, requests, 3)
+	for _, request := range requests {
+		switch request.ID {
+		case "priced":
+			require.NotNil(t, request.RecomputedCost)
+			assert.Equal(t, 10.0, *request.RecomputedCost)
+			assert.Zero(t, request.Cost)
+		case "unpriced":
+			assert.Nil(t, request.RecomputedCost)
+		}
+	}
+
+	t.Run("Uses the current prices", func(t *testing.T) {
+		pricing.Set(ProviderOpenAI, "gpt-4o", ModelPrice{InputPerMillion: 1})
+		request, err := client.storage.Get(ctx, "stale")
+		require.NoError(t, err)
+		require.NotNil(t, request.RecomputedCost)
+		assert.Equal(t, 1.0, *request.RecomputedCost)
+	})
+
+	t.Run("Updates reach the wrapped adapter", func(t *testing.T) {
+		storage := newLifecycleStorage()
+		repriced := NewRepricedStorage(storage, pricing)
+		require.NoError(t, updateRequest(ctx, repriced, &Request{ID: "updated"}))
+		assert.Len(t, storage.updates, 1)
+	})
+}
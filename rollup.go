@@ -0,0 +1,382 @@
+package llmtracer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrRollupsNotSupported is returned by compaction and rollup reads when the storage
+// adapter does not implement RollupStorage
+var ErrRollupsNotSupported = errors.New("rollups are not supported by this storage adapter")
+
+// ErrCompactionConflict is returned by RollupStorage.CompactRequests when some of the
+// requests to compact no longer exist, e.g. because another compaction deleted them first
+var ErrCompactionConflict = errors.New("requests to compact were already deleted")
+
+// RequestRollup totals the requests of one hour that share a provider, model, request
+// type, error type and set of dimensions. Compaction replaces old requests with rollups,
+// so usage can still be reported per dimension long after the raw rows are gone.
+type RequestRollup struct {
+	// ID is derived from the hour and the grouped fields, so compacting more requests of
+	// the same group adds to the same rollup
+	ID          string      `json:"id" gorm:"primaryKey;size:64"`
+	Hour        time.Time   `json:"hour" gorm:"index"`
+	Provider    Provider    `json:"provider" gorm:"index"`
+	Model       string      `json:"model" gorm:"index"`
+	RequestType RequestType `json:"request_type"`
+	ErrorType   ErrorType   `json:"error_type,omitempty"`
+	// Dimensions are stored as JSON
+	Dimensions map[string]string `json:"dimensions,omitempty" gorm:"serializer:json;type:text"`
+
+	Requests      int64   `json:"requests"`
+	Errors        int64   `json:"errors"`
+	InputTokens   int64   `json:"input_tokens"`
+	OutputTokens  int64   `json:"output_tokens"`
+	TotalTokens   int64   `json:"total_tokens"`
+	Cost          float64 `json:"cost"`
+	LatencyMs     int64   `json:"latency_ms"`
+	Messages      int64   `json:"messages"`
+	RequestBytes  int64   `json:"request_bytes"`
+	ResponseBytes int64   `json:"response_bytes"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// newRequestRollup returns the empty rollup a request is compacted into, leaving out the
+// dimensions in drop
+func newRequestRollup(request *Request, drop map[string]bool) *RequestRollup {
+	rollup := &RequestRollup{
+		Hour:        request.RequestedAt.UTC().Truncate(time.Hour),
+		Provider:    request.Provider,
+		Model:       request.Model,
+		RequestType: request.RequestType,
+		ErrorType:   request.ErrorType,
+	}
+	for _, dim := range request.Dimensions {
+		if drop[dim.Key] {
+			continue
+		}
+		if rollup.Dimensions == nil {
+			rollup.Dimensions = make(map[string]string)
+		}
+		rollup.Dimensions[dim.Key] = dim.Value
+	}
+	rollup.ID = rollupID(rollup)
+	return rollup
+}
+
+// rollupID derives the ID of a rollup from its hour and grouped fields. Wrappers that
+// transform dimension values derive it again from the stored values.
+func rollupID(rollup *RequestRollup) string {
+	keys := make([]string, 0, len(rollup.Dimensions))
+	for key := range rollup.Dimensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := []string{rollup.Hour.Format(time.RFC3339), string(rollup.Provider), rollup.Model, string(rollup.RequestType), string(rollup.ErrorType)}
+	for _, key := range keys {
+		parts = append(parts, key+"="+rollup.Dimensions[key])
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// rollupDimensions returns the dimensions of a rollup as tags, sorted by key
+func rollupDimensions(rollup *RequestRollup) []DimensionTag {
+	dimensions := make([]DimensionTag, 0, len(rollup.Dimensions))
+	for key, value := range rollup.Dimensions {
+		dimensions = append(dimensions, DimensionTag{Key: key, Value: value})
+	}
+	sort.Slice(dimensions, func(i, j int) bool { return dimensions[i].Key < dimensions[j].Key })
+	return dimensions
+}
+
+// setDimensions replaces the dimensions of the rollup with a new map of dimensions
+func (r *RequestRollup) setDimensions(dimensions []DimensionTag) {
+	r.Dimensions = nil
+	for _, dim := range dimensions {
+		if r.Dimensions == nil {
+			r.Dimensions = make(map[string]string, len(dimensions))
+		}
+		r.Dimensions[dim.Key] = dim.Value
+	}
+}
+
+// add counts a request in the rollup
+func (r *RequestRollup) add(request *Request) {
+	r.Requests++
+	if request.Error != "" {
+		r.Errors++
+	}
+	r.InputTokens = AddTokens(r.InputTokens, request.InputTokens)
+	r.OutputTokens = AddTokens(r.OutputTokens, request.OutputTokens)
+	r.TotalTokens = AddTokens(r.TotalTokens, request.InputTokens, request.OutputTokens)
+	r.Cost += request.Cost
+	r.LatencyMs += request.LatencyMs
+	r.Messages += int64(request.MessageCount)
+	r.RequestBytes += int64(request.RequestBytes)
+	r.ResponseBytes += int64(request.ResponseBytes)
+}
+
+// Merge adds the totals of other, a rollup of the same group, to r. Adapters use it to
+// add newly compacted requests to a stored rollup.
+func (r *RequestRollup) Merge(other *RequestRollup) {
+	r.Requests += other.Requests
+	r.Errors += other.Errors
+	r.InputTokens = AddTokens(r.InputTokens, other.InputTokens)
+	r.OutputTokens = AddTokens(r.OutputTokens, other.OutputTokens)
+	r.TotalTokens = AddTokens(r.TotalTokens, other.TotalTokens)
+	r.Cost += other.Cost
+	r.LatencyMs += other.LatencyMs
+	r.Messages += other.Messages
+	r.RequestBytes += other.RequestBytes
+	r.ResponseBytes += other.ResponseBytes
+}
+
+// RollupStorage is implemented by adapters that can store rollups of compacted requests
+type RollupStorage interface {
+	// CompactRequests permanently deletes the requests with the given IDs and adds rollups
+	// to the stored rollups with the same IDs, creating the missing ones, in one
+	// transaction so that no request is lost or counted twice. If any of the requests no
+	// longer exists, it changes nothing and returns ErrCompactionConflict.
+	CompactRequests(ctx context.Context, rollups []*RequestRollup, requestIDs []string) error
+
+	// QueryRollups returns the rollups matching the filter as described in
+	// RequestFilter.MatchesRollup
+	QueryRollups(ctx context.Context, filter *RequestFilter) ([]*RequestRollup, error)
+}
+
+// CompactionOptions configures Client.Compact and WithCompaction
+type CompactionOptions struct {
+	// OlderThan is the age from which requests are compacted; whole hours are compacted,
+	// so the cutoff is rounded down to the hour
+	OlderThan time.Duration
+	// DropDimensions are dimension keys left out of rollups, such as user_id, whose
+	// values would otherwise make a rollup per request
+	DropDimensions []string
+	// BatchSize is the number of requests compacted at a time; 500 when zero
+	BatchSize int
+}
+
+// CompactionResult reports what Client.Compact did
+type CompactionResult struct {
+	// Before is the cutoff: requests sent before it were compacted
+	Before time.Time `json:"before"`
+	// Requests is the number of requests replaced by rollups
+	Requests int64 `json:"requests"`
+	// Rollups is the number of distinct rollups written
+	Rollups int64 `json:"rollups"`
+}
+
+// WithCompaction runs Compact every interval (daily when zero) until the client is closed,
+// replacing requests older than opts.OlderThan with hourly rollups. It needs an adapter
+// implementing RollupStorage.
+func WithCompaction(opts CompactionOptions, interval time.Duration) ClientOption {
+	return func(c *Client) {
+		c.compaction = &opts
+		c.compactionInterval = interval
+	}
+}
+
+// Compact replaces the requests sent more than opts.OlderThan ago with hourly rollups
+// keeping their totals per provider, model, request type, error type and dimensions, then
+// permanently deletes them. Long-range reports read the rollups with GetRollupAggregates.
+// Pending and soft-deleted requests are left to retention. Rollups keep totals only, so
+// latency percentiles and the fields of individual requests are lost.
+func (c *Client) Compact(ctx context.Context, opts CompactionOptions) (CompactionResult, error) {
+	result := CompactionResult{}
+	storage, ok := storageCapability[RollupStorage](c.storage)
+	if !ok {
+		return result, ErrRollupsNotSupported
+	}
+	if opts.OlderThan <= 0 {
+		return result, fmt.Errorf("compaction age must be positive")
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRecalculateBatchSize
+	}
+	drop := make(map[string]bool, len(opts.DropDimensions))
+	for _, key := range opts.DropDimensions {
+		drop[key] = true
+	}
+
+	result.Before = time.Now().Add(-opts.OlderThan).Truncate(time.Hour)
+	last := result.Before.Add(-time.Nanosecond)
+	// Compacted requests are deleted, so every batch is read from the start. Batches are
+	// read from the primary, as a lagging read replica would return compacted requests again.
+	ctx = WithPrimaryRead(ctx)
+	filter := &RequestFilter{EndTime: &last, Limit: batchSize, OrderBy: "requested_at"}
+	written := make(map[string]bool)
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		requests, err := c.query(ctx, filter)
+		if err != nil {
+			return result, err
+		}
+		if len(requests) == 0 {
+			return result, nil
+		}
+
+		rollups := make(map[string]*RequestRollup)
+		var order []*RequestRollup
+		ids := make([]string, len(requests))
+		for i, request := range requests {
+			ids[i] = request.ID
+			rollup := newRequestRollup(request, drop)
+			if existing, ok := rollups[rollup.ID]; ok {
+				rollup = existing
+			} else {
+				rollups[rollup.ID] = rollup
+				order = append(order, rollup)
+			}
+			rollup.add(request)
+		}
+		if err := storage.CompactRequests(ctx, order, ids); err != nil {
+			return result, fmt.Errorf("failed to compact requests: %w", err)
+		}
+		result.Requests += int64(len(requests))
+		for id := range rollups {
+			if !written[id] {
+				written[id] = true
+				result.Rollups++
+			}
+		}
+
+		if len(requests) < batchSize {
+			return result, nil
+		}
+	}
+}
+
+// GetRollupAggregates totals the rollups matching filter (see RequestFilter.MatchesRollup),
+// grouped like Aggregate by provider, model, request_type, error_type and GroupByDimension
+// keys. Requests not compacted yet are not included; add them with Aggregate over the time
+// since the compaction cutoff.
+func (c *Client) GetRollupAggregates(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+	storage, ok := storageCapability[RollupStorage](c.storage)
+	if !ok {
+		return nil, ErrRollupsNotSupported
+	}
+	rollups, err := storage.QueryRollups(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*AggregateResult
+	groups := make(map[string]*AggregateResult)
+	for _, rollup := range rollups {
+		group := &AggregateResult{Dimensions: []DimensionTag{}}
+		for _, field := range groupBy {
+			if key, ok := DimensionGroupKey(field); ok {
+				group.Dimensions = append(group.Dimensions, DimensionTag{Key: key, Value: rollup.Dimensions[key]})
+				continue
+			}
+			switch field {
+			case "provider":
+				group.Provider = rollup.Provider
+			case "model":
+				group.Model = rollup.Model
+			case "request_type":
+				group.RequestType = rollup.RequestType
+			case "error_type":
+				group.ErrorType = rollup.ErrorType
+			}
+		}
+
+		groupKey := fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%v", group.Provider, group.Model, group.RequestType, group.ErrorType, group.Dimensions)
+		result, ok := groups[groupKey]
+		if !ok {
+			result = group
+			groups[groupKey] = result
+			results = append(results, result)
+		}
+		result.TotalRequests += rollup.Requests
+		result.ErrorCount += rollup.Errors
+		result.TotalInputTokens = AddTokens(result.TotalInputTokens, rollup.InputTokens)
+		result.TotalOutputTokens = AddTokens(result.TotalOutputTokens, rollup.OutputTokens)
+		result.TotalTokens = AddTokens(result.TotalTokens, rollup.TotalTokens)
+		result.TotalCost += rollup.Cost
+		// Summed here and averaged below
+		result.AvgLatency += time.Duration(rollup.LatencyMs) * time.Millisecond
+		result.TotalMessages += rollup.Messages
+		result.TotalRequestBytes += rollup.RequestBytes
+		result.TotalResponseBytes += rollup.ResponseBytes
+	}
+
+	for _, result := range results {
+		finishAggregate(result)
+	}
+	return SortAggregates(results, filter), nil
+}
+
+// MatchesRollup reports whether a rollup satisfies the criteria of the filter that rollups
+// keep: its provider, model, request type, error type and dimensions, and its time range,
+// which rollups match on the start of their hour. Other criteria are ignored, and a nil
+// filter matches every rollup.
+func (f *RequestFilter) MatchesRollup(rollup *RequestRollup) bool {
+	if f == nil {
+		return true
+	}
+	if f.Provider != "" && rollup.Provider != f.Provider {
+		return false
+	}
+	if f.Model != "" && rollup.Model != f.Model {
+		return false
+	}
+	if f.RequestType != "" && rollup.RequestType != f.RequestType {
+		return false
+	}
+	if f.ErrorType != "" && rollup.ErrorType != f.ErrorType {
+		return false
+	}
+	if f.StartTime != nil && rollup.Hour.Before(*f.StartTime) {
+		return false
+	}
+	if f.EndTime != nil && rollup.Hour.After(*f.EndTime) {
+		return false
+	}
+	for _, dim := range f.Dimensions {
+		if value, ok := rollup.Dimensions[dim.Key]; !ok || value != dim.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// runCompaction periodically compacts old requests
+func (c *Client) runCompaction() {
+	ticker := time.NewTicker(c.compactionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.applyCompaction()
+		case <-c.stopCompaction:
+			return
+		}
+	}
+}
+
+// applyCompaction runs the compaction configured with WithCompaction
+func (c *Client) applyCompaction() {
+	result, err := c.Compact(context.Background(), *c.compaction)
+	if err != nil {
+		c.logger.Error("Failed to compact requests", slog.Any("error", err))
+		return
+	}
+	if result.Requests > 0 {
+		c.logger.Debug("Compacted requests", slog.Int64("count", result.Requests), slog.Int64("rollups", result.Rollups))
+	}
+}
@@ -0,0 +1,233 @@
+package llmtracer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rollupStore keeps rollups next to the requests of a memoryStore
+type rollupStore struct {
+	*MockStorageAdapter
+	store   *memoryStore
+	rollups map[string]*RequestRollup
+}
+
+func newRollupStore() *rollupStore {
+	store := newMemoryStore()
+	return &rollupStore{MockStorageAdapter: store.adapter(), store: store, rollups: make(map[string]*RequestRollup)}
+}
+
+func (r *rollupStore) CompactRequests(ctx context.Context, rollups []*RequestRollup, requestIDs []string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range requestIDs {
+		if _, ok := r.store.requests[id]; !ok {
+			return ErrCompactionConflict
+		}
+	}
+	for _, rollup := range rollups {
+		if current, ok := r.rollups[rollup.ID]; ok {
+			current.Merge(rollup)
+			continue
+		}
+		stored := *rollup
+		r.rollups[rollup.ID] = &stored
+	}
+	for _, id := range requestIDs {
+		delete(r.store.requests, id)
+	}
+	return nil
+}
+
+func (r *rollupStore) QueryRollups(ctx context.Context, filter *RequestFilter) ([]*RequestRollup, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var rollups []*RequestRollup
+	for _, rollup := range r.rollups {
+		if filter.MatchesRollup(rollup) {
+			copied := *rollup
+			rollups = append(rollups, &copied)
+		}
+	}
+	return rollups, nil
+}
+
+func TestCompact(t *testing.T) {
+	storage := newRollupStore()
+	ctx := context.Background()
+	hour := time.Now().Add(-72 * time.Hour).Truncate(time.Hour)
+	save := func(id string, requestedAt time.Time, feature, user, errMsg string) {
+		t.Helper()
+		require.NoError(t, storage.Save(ctx, &Request{
+			ID:           id,
+			Provider:     ProviderOpenAI,
+			Model:        "gpt-4o",
+			InputTokens:  100,
+			OutputTokens: 10,
+			Cost:         0.5,
+			LatencyMs:    200,
+			MessageCount: 2,
+			Error:        errMsg,
+			RequestedAt:  requestedAt,
+			Dimensions:   []DimensionTag{{Key: DimensionFeature, Value: feature}, {Key: "user_id", Value: user}},
+		}))
+	}
+	save("a", hour.Add(time.Minute), "search", "u1", "")
+	save("b", hour.Add(10*time.Minute), "search", "u2", "timeout")
+	save("c", hour.Add(20*time.Minute), "chat", "u1", "")
+	save("d", hour.Add(time.Hour), "search", "u3", "")
+	save("recent", time.Now(), "search", "u1", "")
+
+	client := NewClient(storage)
+	defer client.Shutdown(ctx)
+	result, err := client.Compact(ctx, CompactionOptions{OlderThan: 24 * time.Hour, DropDimensions: []string{"user_id"}, BatchSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.Requests)
+	assert.Equal(t, int64(3), result.Rollups, "search and chat in the first hour, search in the second")
+	assert.Len(t, storage.store.requests, 1)
+	assert.Contains(t, storage.store.requests, "recent")
+
+	search := storage.rollups[newRequestRollup(&Request{Provider: ProviderOpenAI, Model: "gpt-4o", RequestedAt: hour,
+		Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "search"}}}, nil).ID]
+	require.NotNil(t, search)
+	assert.Equal(t, hour.UTC(), search.Hour)
+	assert.Equal(t, map[string]string{DimensionFeature: "search"}, search.Dimensions)
+	assert.Equal(t, int64(2), search.Requests)
+	assert.Equal(t, int64(1), search.Errors)
+	assert.Equal(t, int64(220), search.TotalTokens)
+	assert.InDelta(t, 1.0, search.Cost, 1e-9)
+	assert.Equal(t, int64(400), search.LatencyMs)
+	assert.Equal(t, int64(4), search.Messages)
+
+	results, err := client.GetRollupAggregates(ctx, []string{GroupByDimension(DimensionFeature)}, &RequestFilter{
+		Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "search"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "search", results[0].Dimension(DimensionFeature))
+	assert.Equal(t, int64(3), results[0].TotalRequests)
+	assert.Equal(t, int64(330), results[0].TotalTokens)
+	assert.Equal(t, 200*time.Millisecond, results[0].AvgLatency)
+	assert.InDelta(t, 2.0/3, results[0].SuccessRate, 1e-9)
+	assert.InDelta(t, 2.0, results[0].AvgMessageCount, 1e-9)
+}
+
+func TestCompactAddsToExistingRollups(t *testing.T) {
+	storage := newRollupStore()
+	ctx := context.Background()
+	hour := time.Now().Add(-48 * time.Hour).Truncate(time.Hour)
+	client := NewClient(storage)
+	defer client.Shutdown(ctx)
+
+	for run := 0; run < 2; run++ {
+		for i := 0; i < 3; i++ {
+			require.NoError(t, storage.Save(ctx, &Request{
+				ID:          fmt.Sprintf("req-%d-%d", run, i),
+				Model:       "gpt-4o",
+				InputTokens: 10,
+				RequestedAt: hour.Add(time.Duration(i) * time.Minute),
+			}))
+		}
+		_, err := client.Compact(ctx, CompactionOptions{OlderThan: time.Hour})
+		require.NoError(t, err)
+	}
+
+	require.Len(t, storage.rollups, 1)
+	for _, rollup := range storage.rollups {
+		assert.Equal(t, int64(6), rollup.Requests)
+		assert.Equal(t, int64(60), rollup.InputTokens)
+	}
+}
+
+func TestCompactRequiresRollupStorage(t *testing.T) {
+	client := NewClient(&MockStorageAdapter{})
+	_, err := client.Compact(context.Background(), CompactionOptions{OlderThan: time.Hour})
+	assert.ErrorIs(t, err, ErrRollupsNotSupported)
+
+	_, err = client.GetRollupAggregates(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, ErrRollupsNotSupported)
+}
+
+func TestCompactThroughStorageWrappers(t *testing.T) {
+	ctx := context.Background()
+	hour := time.Now().Add(-48 * time.Hour).Truncate(time.Hour)
+	// setup stores a request per user and feature, old enough to be compacted
+	setup := func(t *testing.T, opts ...ClientOption) (*rollupStore, *Client) {
+		storage := newRollupStore()
+		client := NewClient(storage, opts...)
+		t.Cleanup(func() { client.Shutdown(ctx) })
+		for i, user := range []string{"alice", "bob"} {
+			for j, feature := range []string{"search", "ads"} {
+				require.NoError(t, client.storage.Save(ctx, &Request{
+					ID:          fmt.Sprintf("%s-%s", user, feature),
+					Model:       "gpt-4o",
+					InputTokens: int64(10 * (i + 1)),
+					RequestedAt: hour.Add(time.Duration(j) * time.Minute),
+					Dimensions:  []DimensionTag{{Key: DimensionUserID, Value: user}, {Key: DimensionFeature, Value: feature}},
+				}))
+			}
+		}
+		return storage, client
+	}
+
+	t.Run("Encryption", func(t *testing.T) {
+		keys, err := NewStaticKeyProvider("v1", map[string][]byte{"v1": make([]byte, 32)})
+		require.NoError(t, err)
+		storage, client := setup(t, WithEncryption(keys))
+		_, err = client.Compact(ctx, CompactionOptions{OlderThan: time.Hour})
+		require.NoError(t, err)
+
+		require.Len(t, storage.rollups, 4)
+		for _, rollup := range storage.rollups {
+			assert.True(t, strings.HasPrefix(rollup.Dimensions[DimensionUserID], encryptedPrefix), "user IDs are stored encrypted")
+		}
+
+		results, err := client.GetRollupAggregates(ctx, []string{GroupByDimension(DimensionUserID)}, &RequestFilter{
+			Dimensions: []DimensionTag{{Key: DimensionUserID, Value: "bob"}},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "bob", results[0].Dimension(DimensionUserID))
+		assert.Equal(t, int64(40), results[0].TotalInputTokens)
+	})
+
+	t.Run("Pseudonymization", func(t *testing.T) {
+		storage, client := setup(t, WithPseudonymization([]byte("secret")))
+		_, err := client.Compact(ctx, CompactionOptions{OlderThan: time.Hour})
+		require.NoError(t, err)
+
+		require.Len(t, storage.rollups, 4)
+		for _, rollup := range storage.rollups {
+			assert.True(t, isPseudonym(rollup.Dimensions[DimensionUserID]), "user IDs are stored as pseudonyms")
+		}
+
+		results, err := client.GetRollupAggregates(ctx, nil, &RequestFilter{
+			Dimensions: []DimensionTag{{Key: DimensionUserID, Value: "alice"}},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, int64(20), results[0].TotalInputTokens)
+	})
+
+	t.Run("Access scope", func(t *testing.T) {
+		storage, client := setup(t, WithAccessScoping())
+		team := WithAccessScope(ctx, &AccessScope{Name: "team-search", Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "search"}}})
+		_, err := client.Compact(team, CompactionOptions{OlderThan: time.Hour})
+		assert.ErrorIs(t, err, ErrAccessDenied)
+		assert.Empty(t, storage.rollups)
+
+		_, err = client.Compact(ctx, CompactionOptions{OlderThan: time.Hour})
+		require.NoError(t, err)
+		results, err := client.GetRollupAggregates(team, []string{GroupByDimension(DimensionFeature)}, nil)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "search", results[0].Dimension(DimensionFeature))
+		assert.Equal(t, int64(2), results[0].TotalRequests)
+	})
+}
@@ -0,0 +1,150 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrSavedFiltersNotSupported is returned by the saved filter methods when the storage
+	// adapter does not implement SavedFilterStorage
+	ErrSavedFiltersNotSupported = errors.New("saved filters are not supported by this storage adapter")
+	// ErrFilterNotFound is returned for a saved filter name that does not exist
+	ErrFilterNotFound = errors.New("saved filter not found")
+)
+
+// SavedFilter is a named RequestFilter, so teams can share canonical views such as
+// "prod errors last 24h" between the CLI, dashboards and services
+type SavedFilter struct {
+	Name        string `json:"name" gorm:"primaryKey"`
+	Description string `json:"description,omitempty"`
+	// Filter is stored as JSON
+	Filter RequestFilter `json:"filter" gorm:"serializer:json;type:text"`
+	// Window makes the filter relative: when it is used, StartTime is set to Window before
+	// the current time and EndTime is cleared. Adapters store WindowMs like LatencyMs.
+	Window    time.Duration `json:"window,omitempty" gorm:"-"`
+	WindowMs  int64         `json:"window_ms,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Resolve returns the filter to run at now, with the window applied
+func (s *SavedFilter) Resolve(now time.Time) *RequestFilter {
+	filter := s.Filter
+	if s.Window > 0 {
+		start := now.Add(-s.Window)
+		filter.StartTime = &start
+		filter.EndTime = nil
+	}
+	return &filter
+}
+
+// SavedFilterStorage is implemented by adapters that can store named filters
+type SavedFilterStorage interface {
+	// SaveFilter stores a filter, replacing the stored one with the same name
+	SaveFilter(ctx context.Context, filter *SavedFilter) error
+
+	// GetFilter returns the filter with the given name, or ErrFilterNotFound
+	GetFilter(ctx context.Context, name string) (*SavedFilter, error)
+
+	// ListFilters returns every stored filter, ordered by name
+	ListFilters(ctx context.Context) ([]*SavedFilter, error)
+
+	// DeleteFilter removes the filter with the given name, or returns ErrFilterNotFound
+	DeleteFilter(ctx context.Context, name string) error
+}
+
+// SavedFilterOption configures a filter saved with SaveFilter
+type SavedFilterOption func(*SavedFilter)
+
+// WithFilterWindow makes a saved filter cover the given duration before the time it is
+// used, e.g. 24 hours for "last 24h"
+func WithFilterWindow(window time.Duration) SavedFilterOption {
+	return func(s *SavedFilter) {
+		s.Window = window
+	}
+}
+
+// WithFilterDescription describes a saved filter for the people browsing them
+func WithFilterDescription(description string) SavedFilterOption {
+	return func(s *SavedFilter) {
+		s.Description = description
+	}
+}
+
+// SaveFilter stores filter under name, replacing any filter saved with that name. A
+// window set with WithFilterWindow replaces the filter's time range when it is used.
+//
+//	tracer.SaveFilter(ctx, "prod-errors-24h", &llmtracer.RequestFilter{
+//		HasError:   &hasError,
+//		Dimensions: []llmtracer.DimensionTag{{Key: llmtracer.DimensionEnvironment, Value: "prod"}},
+//	}, llmtracer.WithFilterWindow(24*time.Hour))
+func (c *Client) SaveFilter(ctx context.Context, name string, filter *RequestFilter, opts ...SavedFilterOption) error {
+	storage, ok := storageCapability[SavedFilterStorage](c.storage)
+	if !ok {
+		return ErrSavedFiltersNotSupported
+	}
+	if name == "" {
+		return fmt.Errorf("saved filter name cannot be empty")
+	}
+
+	saved := &SavedFilter{Name: name}
+	if filter != nil {
+		saved.Filter = *filter
+	}
+	for _, opt := range opts {
+		opt(saved)
+	}
+	if saved.Window < 0 {
+		return fmt.Errorf("saved filter window cannot be negative")
+	}
+	return storage.SaveFilter(ctx, saved)
+}
+
+// GetSavedFilter returns the filter saved under name, or ErrFilterNotFound
+func (c *Client) GetSavedFilter(ctx context.Context, name string) (*SavedFilter, error) {
+	storage, ok := storageCapability[SavedFilterStorage](c.storage)
+	if !ok {
+		return nil, ErrSavedFiltersNotSupported
+	}
+	return storage.GetFilter(ctx, name)
+}
+
+// ListSavedFilters returns every saved filter, ordered by name
+func (c *Client) ListSavedFilters(ctx context.Context) ([]*SavedFilter, error) {
+	storage, ok := storageCapability[SavedFilterStorage](c.storage)
+	if !ok {
+		return nil, ErrSavedFiltersNotSupported
+	}
+	return storage.ListFilters(ctx)
+}
+
+// DeleteSavedFilter removes the filter saved under name, or returns ErrFilterNotFound
+func (c *Client) DeleteSavedFilter(ctx context.Context, name string) error {
+	storage, ok := storageCapability[SavedFilterStorage](c.storage)
+	if !ok {
+		return ErrSavedFiltersNotSupported
+	}
+	return storage.DeleteFilter(ctx, name)
+}
+
+// ResolveFilter returns the filter saved under name as of now, for use with the methods
+// taking a RequestFilter, such as GetAggregatesMulti
+func (c *Client) ResolveFilter(ctx context.Context, name string) (*RequestFilter, error) {
+	saved, err := c.GetSavedFilter(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return saved.Resolve(time.Now()), nil
+}
+
+// QueryByName returns the requests matching the filter saved under name
+func (c *Client) QueryByName(ctx context.Context, name string) ([]*Request, error) {
+	filter, err := c.ResolveFilter(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return c.query(ctx, filter)
+}
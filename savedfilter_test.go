@@ -0,0 +1,99 @@
+package llmtracer
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// savedFilterStorage adds in-memory saved filters to the mock
+type savedFilterStorage struct {
+	*MockStorageAdapter
+	filters map[string]*SavedFilter
+}
+
+func (s *savedFilterStorage) SaveFilter(ctx context.Context, filter *SavedFilter) error {
+	s.filters[filter.Name] = filter
+	return nil
+}
+
+func (s *savedFilterStorage) GetFilter(ctx context.Context, name string) (*SavedFilter, error) {
+	filter, ok := s.filters[name]
+	if !ok {
+		return nil, ErrFilterNotFound
+	}
+	return filter, nil
+}
+
+func (s *savedFilterStorage) ListFilters(ctx context.Context) ([]*SavedFilter, error) {
+	var filters []*SavedFilter
+	for _, filter := range s.filters {
+		filters = append(filters, filter)
+	}
+	sort.Slice(filters, func(i, j int) bool { return filters[i].Name < filters[j].Name })
+	return filters, nil
+}
+
+func (s *savedFilterStorage) DeleteFilter(ctx context.Context, name string) error {
+	if _, ok := s.filters[name]; !ok {
+		return ErrFilterNotFound
+	}
+	delete(s.filters, name)
+	return nil
+}
+
+func TestSavedFilters(t *testing.T) {
+	ctx := context.Background()
+	var queried *RequestFilter
+	storage := &savedFilterStorage{
+		MockStorageAdapter: &MockStorageAdapter{
+			QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+				queried = filter
+				return []*Request{{ID: "r1"}}, nil
+			},
+		},
+		filters: map[string]*SavedFilter{},
+	}
+	client := NewClient(storage)
+
+	hasError := true
+	stale := time.Now().Add(-30 * 24 * time.Hour)
+	require.NoError(t, client.SaveFilter(ctx, "prod-errors-24h", &RequestFilter{HasError: &hasError, StartTime: &stale, EndTime: &stale},
+		WithFilterWindow(24*time.Hour), WithFilterDescription("Errors in production")))
+	require.NoError(t, client.SaveFilter(ctx, "all", nil))
+	assert.Error(t, client.SaveFilter(ctx, "", nil))
+	assert.Error(t, client.SaveFilter(ctx, "negative", nil, WithFilterWindow(-time.Hour)))
+
+	requests, err := client.QueryByName(ctx, "prod-errors-24h")
+	require.NoError(t, err)
+	assert.Len(t, requests, 1)
+	require.NotNil(t, queried.StartTime)
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), *queried.StartTime, time.Minute, "the window replaces the stored time range")
+	assert.Nil(t, queried.EndTime)
+	assert.Equal(t, &hasError, queried.HasError)
+
+	saved, err := client.GetSavedFilter(ctx, "prod-errors-24h")
+	require.NoError(t, err)
+	assert.Equal(t, "Errors in production", saved.Description)
+	assert.Equal(t, &stale, saved.Filter.StartTime, "resolving doesn't change the saved filter")
+
+	filters, err := client.ListSavedFilters(ctx)
+	require.NoError(t, err)
+	require.Len(t, filters, 2)
+	assert.Equal(t, "all", filters[0].Name)
+
+	require.NoError(t, client.DeleteSavedFilter(ctx, "all"))
+	_, err = client.QueryByName(ctx, "all")
+	assert.ErrorIs(t, err, ErrFilterNotFound)
+
+	t.Run("Adapters without saved filters", func(t *testing.T) {
+		client := NewClient(&MockStorageAdapter{})
+		assert.ErrorIs(t, client.SaveFilter(ctx, "all", nil), ErrSavedFiltersNotSupported)
+		_, err := client.QueryByName(ctx, "all")
+		assert.ErrorIs(t, err, ErrSavedFiltersNotSupported)
+	})
+}
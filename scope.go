@@ -0,0 +1,463 @@
+package llmtracer
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAccessDenied is returned for reads of requests outside the caller's access scope, and
+// for deletes by callers whose scope is restricted
+var ErrAccessDenied = errors.New("access denied")
+
+// AccessScope restricts what a caller may read to the requests carrying every one of its
+// dimensions, e.g. a team that may only see feature=search. A scope without dimensions
+// grants full access.
+type AccessScope struct {
+	// Name identifies the scope, e.g. "team-search"
+	Name       string
+	Dimensions []DimensionTag
+}
+
+// Allows reports whether a request is within the scope
+func (s *AccessScope) Allows(request *Request) bool {
+	if s == nil {
+		return true
+	}
+	for _, dim := range s.Dimensions {
+		if value, ok := request.LookupDimension(dim.Key); !ok || value != dim.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// restricted reports whether the scope limits access
+func (s *AccessScope) restricted() bool {
+	return s != nil && len(s.Dimensions) > 0
+}
+
+// covers reports whether every request matching filter is within the scope, because the
+// filter requires each of the scope's dimensions
+func (s *AccessScope) covers(filter *RequestFilter) bool {
+	if !s.restricted() {
+		return true
+	}
+	if filter == nil {
+		return false
+	}
+	for _, dim := range s.Dimensions {
+		if value, ok := lookupDimension(filter.Dimensions, dim.Key); !ok || value != dim.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// Restrict returns a copy of filter that also requires the scope's dimensions. A filter
+// asking for other values of a scoped dimension matches nothing.
+func (s *AccessScope) Restrict(filter *RequestFilter) *RequestFilter {
+	if !s.restricted() {
+		return filter
+	}
+	restricted := RequestFilter{}
+	if filter != nil {
+		restricted = *filter
+	}
+	restricted.Dimensions = append(append([]DimensionTag(nil), restricted.Dimensions...), s.Dimensions...)
+	return &restricted
+}
+
+const accessScopeKey contextKey = "llm_access_scope"
+
+// WithAccessScope returns a context whose reads through a client created with
+// WithAccessScoping are restricted to scope
+func WithAccessScope(ctx context.Context, scope *AccessScope) context.Context {
+	return context.WithValue(ctx, accessScopeKey, scope)
+}
+
+// GetAccessScopeFromContext returns the access scope of a context, or nil when it has none
+func GetAccessScopeFromContext(ctx context.Context) *AccessScope {
+	if ctx == nil {
+		return nil
+	}
+	scope, _ := ctx.Value(accessScopeKey).(*AccessScope)
+	return scope
+}
+
+// WithAccessScoping enforces the access scope carried by the context of each read (see
+// WithAccessScope and AccessTokens.Middleware): Query, Aggregate and the client methods
+// built on them only see requests within the scope, Get and GetByTraceID hide requests
+// outside it, and callers with a restricted scope cannot delete or restore requests. The
+// scope also applies to the data stored next to requests: feedback is limited to requests
+// within the scope, saved filters to those requiring the scope's dimensions, incidents to
+// the providers of requests within the scope, and restricted scopes cannot write any of
+// them. Reads without a scope are not restricted, so serve untrusted callers only through
+// a handler that always sets one. Tracking is not affected.
+//
+// The scope is applied around every other storage wrapper, such as WithEncryption or
+// WithPseudonymization, whatever the order of the options.
+func WithAccessScoping() ClientOption {
+	return func(c *Client) {
+		c.accessScoping = true
+	}
+}
+
+// NewScopedStorage wraps storage so that reads are restricted to the access scope of their
+// context, as described in WithAccessScoping
+func NewScopedStorage(storage StorageAdapter) StorageAdapter {
+	if storage == nil {
+		panic("storage adapter cannot be nil")
+	}
+	scoped := &scopedStorage{StorageAdapter: storage}
+	if softDelete, ok := storage.(SoftDeleteStorage); ok {
+		return &scopedSoftDeleteStorage{scopedStorage: scoped, softDelete: softDelete}
+	}
+	return scoped
+}
+
+// scopedStorage applies the access scope of the context to reads and deletes
+type scopedStorage struct {
+	StorageAdapter
+}
+
+// scopedSoftDeleteStorage is scoped storage over an adapter that soft deletes. Restoring
+// and purging are denied to restricted scopes like deletes.
+type scopedSoftDeleteStorage struct {
+	*scopedStorage
+	softDelete SoftDeleteStorage
+}
+
+func (s *scopedSoftDeleteStorage) Restore(ctx context.Context, id string) error {
+	if GetAccessScopeFromContext(ctx).restricted() {
+		return ErrAccessDenied
+	}
+	return s.softDelete.Restore(ctx, id)
+}
+
+func (s *scopedSoftDeleteStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	if GetAccessScopeFromContext(ctx).restricted() {
+		return 0, ErrAccessDenied
+	}
+	return s.softDelete.PurgeDeleted(ctx, before)
+}
+
+func (s *scopedStorage) unwrap() StorageAdapter {
+	return s.StorageAdapter
+}
+
+func (s *scopedStorage) Capabilities() StorageCapabilities {
+	return wrappedCapabilities(s.StorageAdapter)
+}
+
+func (s *scopedStorage) Get(ctx context.Context, id string) (*Request, error) {
+	request, err := s.StorageAdapter.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !GetAccessScopeFromContext(ctx).Allows(request) {
+		return nil, ErrAccessDenied
+	}
+	return request, nil
+}
+
+func (s *scopedStorage) GetByTraceID(ctx context.Context, traceID string) ([]*Request, error) {
+	requests, err := s.StorageAdapter.GetByTraceID(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	scope := GetAccessScopeFromContext(ctx)
+	if !scope.restricted() {
+		return requests, nil
+	}
+	allowed := requests[:0:0]
+	for _, request := range requests {
+		if scope.Allows(request) {
+			allowed = append(allowed, request)
+		}
+	}
+	return allowed, nil
+}
+
+func (s *scopedStorage) Query(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+	return s.StorageAdapter.Query(ctx, GetAccessScopeFromContext(ctx).Restrict(filter))
+}
+
+func (s *scopedStorage) Aggregate(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+	return s.StorageAdapter.Aggregate(ctx, groupBy, GetAccessScopeFromContext(ctx).Restrict(filter))
+}
+
+func (s *scopedStorage) AggregateMulti(ctx context.Context, specs []AggregateSpec) ([][]*AggregateResult, error) {
+	scope := GetAccessScopeFromContext(ctx)
+	restricted := make([]AggregateSpec, len(specs))
+	for i, spec := range specs {
+		restricted[i] = AggregateSpec{GroupBy: spec.GroupBy, Filter: scope.Restrict(spec.Filter)}
+	}
+	return aggregateMulti(ctx, s.StorageAdapter, restricted)
+}
+
+func (s *scopedStorage) Delete(ctx context.Context, id string) error {
+	if GetAccessScopeFromContext(ctx).restricted() {
+		return ErrAccessDenied
+	}
+	return s.StorageAdapter.Delete(ctx, id)
+}
+
+func (s *scopedStorage) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	if GetAccessScopeFromContext(ctx).restricted() {
+		return 0, ErrAccessDenied
+	}
+	return s.StorageAdapter.DeleteOlderThan(ctx, before)
+}
+
+// CompactRequests is a delete, so it is denied to restricted scopes
+func (s *scopedStorage) CompactRequests(ctx context.Context, rollups []*RequestRollup, requestIDs []string) error {
+	if GetAccessScopeFromContext(ctx).restricted() {
+		return ErrAccessDenied
+	}
+	storage, ok := storageCapability[RollupStorage](s.StorageAdapter)
+	if !ok {
+		return ErrRollupsNotSupported
+	}
+	return storage.CompactRequests(ctx, rollups, requestIDs)
+}
+
+func (s *scopedStorage) QueryRollups(ctx context.Context, filter *RequestFilter) ([]*RequestRollup, error) {
+	storage, ok := storageCapability[RollupStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrRollupsNotSupported
+	}
+	return storage.QueryRollups(ctx, GetAccessScopeFromContext(ctx).Restrict(filter))
+}
+
+func (s *scopedStorage) SaveFeedback(ctx context.Context, feedback *Feedback) error {
+	if GetAccessScopeFromContext(ctx).restricted() {
+		return ErrAccessDenied
+	}
+	storage, ok := storageCapability[FeedbackStorage](s.StorageAdapter)
+	if !ok {
+		return ErrFeedbackNotSupported
+	}
+	return storage.SaveFeedback(ctx, feedback)
+}
+
+// QueryFeedback hides feedback on requests outside the scope, or that can no longer be
+// read. Hidden records count towards the filter's limit.
+func (s *scopedStorage) QueryFeedback(ctx context.Context, filter *FeedbackFilter) ([]*Feedback, error) {
+	storage, ok := storageCapability[FeedbackStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrFeedbackNotSupported
+	}
+	feedback, err := storage.QueryFeedback(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	scope := GetAccessScopeFromContext(ctx)
+	if !scope.restricted() {
+		return feedback, nil
+	}
+
+	allowed := make(map[string]bool)
+	visible := feedback[:0:0]
+	for _, fb := range feedback {
+		ok, checked := allowed[fb.RequestID]
+		if !checked {
+			request, err := s.StorageAdapter.Get(ctx, fb.RequestID)
+			ok = err == nil && scope.Allows(request)
+			allowed[fb.RequestID] = ok
+		}
+		if ok {
+			visible = append(visible, fb)
+		}
+	}
+	return visible, nil
+}
+
+func (s *scopedStorage) SaveFilter(ctx context.Context, filter *SavedFilter) error {
+	if GetAccessScopeFromContext(ctx).restricted() {
+		return ErrAccessDenied
+	}
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return ErrSavedFiltersNotSupported
+	}
+	return storage.SaveFilter(ctx, filter)
+}
+
+func (s *scopedStorage) GetFilter(ctx context.Context, name string) (*SavedFilter, error) {
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrSavedFiltersNotSupported
+	}
+	filter, err := storage.GetFilter(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !GetAccessScopeFromContext(ctx).covers(&filter.Filter) {
+		return nil, ErrAccessDenied
+	}
+	return filter, nil
+}
+
+func (s *scopedStorage) ListFilters(ctx context.Context) ([]*SavedFilter, error) {
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrSavedFiltersNotSupported
+	}
+	filters, err := storage.ListFilters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scope := GetAccessScopeFromContext(ctx)
+	if !scope.restricted() {
+		return filters, nil
+	}
+	visible := filters[:0:0]
+	for _, filter := range filters {
+		if scope.covers(&filter.Filter) {
+			visible = append(visible, filter)
+		}
+	}
+	return visible, nil
+}
+
+func (s *scopedStorage) DeleteFilter(ctx context.Context, name string) error {
+	if GetAccessScopeFromContext(ctx).restricted() {
+		return ErrAccessDenied
+	}
+	storage, ok := storageCapability[SavedFilterStorage](s.StorageAdapter)
+	if !ok {
+		return ErrSavedFiltersNotSupported
+	}
+	return storage.DeleteFilter(ctx, name)
+}
+
+func (s *scopedStorage) SaveIncident(ctx context.Context, incident *ProviderIncident) error {
+	if GetAccessScopeFromContext(ctx).restricted() {
+		return ErrAccessDenied
+	}
+	storage, ok := storageCapability[IncidentStorage](s.StorageAdapter)
+	if !ok {
+		return ErrIncidentsNotSupported
+	}
+	return storage.SaveIncident(ctx, incident)
+}
+
+// QueryIncidents only returns the incidents of providers that requests within the scope
+// used during the filter's time range
+func (s *scopedStorage) QueryIncidents(ctx context.Context, filter *IncidentFilter) ([]*ProviderIncident, error) {
+	storage, ok := storageCapability[IncidentStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrIncidentsNotSupported
+	}
+	incidents, err := storage.QueryIncidents(ctx, filter)
+	if err != nil || !GetAccessScopeFromContext(ctx).restricted() || len(incidents) == 0 {
+		return incidents, err
+	}
+
+	providers, err := s.providers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	visible := incidents[:0:0]
+	for _, incident := range incidents {
+		if providers[incident.Provider] {
+			visible = append(visible, incident)
+		}
+	}
+	return visible, nil
+}
+
+// providers returns the providers of the requests within the scope of ctx during the time
+// range of filter
+func (s *scopedStorage) providers(ctx context.Context, filter *IncidentFilter) (map[Provider]bool, error) {
+	requestFilter := &RequestFilter{}
+	if filter != nil {
+		requestFilter.Provider = filter.Provider
+		requestFilter.StartTime = filter.StartTime
+		requestFilter.EndTime = filter.EndTime
+	}
+
+	providers := make(map[Provider]bool)
+	results, err := s.Aggregate(ctx, []string{"provider"}, requestFilter)
+	if err == nil {
+		for _, result := range results {
+			providers[result.Provider] = true
+		}
+		return providers, nil
+	}
+	if !errors.Is(err, ErrAggregateNotSupported) {
+		return nil, err
+	}
+	requests, err := s.Query(ctx, requestFilter)
+	if err != nil {
+		return nil, err
+	}
+	for _, request := range requests {
+		providers[request.Provider] = true
+	}
+	return providers, nil
+}
+
+// Update passes lifecycle updates through, since tracking is not scoped
+func (s *scopedStorage) Update(ctx context.Context, request *Request) error {
+	return updateRequest(ctx, s.StorageAdapter, request)
+}
+
+// AccessTokens maps bearer tokens to the access scopes they grant. Only SHA-256 hashes of
+// the tokens are kept in memory. It is safe for concurrent use.
+type AccessTokens struct {
+	mu     sync.RWMutex
+	scopes map[[sha256.Size]byte]*AccessScope
+}
+
+// NewAccessTokens creates an empty token set
+func NewAccessTokens() *AccessTokens {
+	return &AccessTokens{scopes: make(map[[sha256.Size]byte]*AccessScope)}
+}
+
+// Add grants scope to token, replacing any scope it had
+func (t *AccessTokens) Add(token string, scope AccessScope) {
+	scope.Dimensions = append([]DimensionTag(nil), scope.Dimensions...)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scopes[sha256.Sum256([]byte(token))] = &scope
+}
+
+// Revoke removes a token
+func (t *AccessTokens) Revoke(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.scopes, sha256.Sum256([]byte(token)))
+}
+
+// Scope returns the scope granted to token
+func (t *AccessTokens) Scope(token string) (*AccessScope, bool) {
+	if token == "" {
+		return nil, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	scope, ok := t.scopes[sha256.Sum256([]byte(token))]
+	return scope, ok
+}
+
+// Middleware authenticates requests by their "Authorization: Bearer <token>" header and
+// passes the token's scope to next through the request context, for a client created with
+// WithAccessScoping. Requests without a known token get 401 Unauthorized.
+func (t *AccessTokens) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		scope, known := t.Scope(strings.TrimSpace(token))
+		if !ok || !known {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="llmtracer"`)
+			http.Error(w, "invalid or missing access token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithAccessScope(r.Context(), scope)))
+	})
+}
@@ -0,0 +1,245 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAccessScoping(t *testing.T) {
+	search := &Request{ID: "search", Provider: ProviderOpenAI, Model: "gpt-4o", Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "search"}}}
+	ads := &Request{ID: "ads", Provider: ProviderOpenAI, Model: "gpt-4o", Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "ads"}}}
+	stored := []*Request{search, ads}
+
+	var filters []*RequestFilter
+	deletes := 0
+	storage := &MockStorageAdapter{
+		GetFunc: func(ctx context.Context, id string) (*Request, error) {
+			for _, request := range stored {
+				if request.ID == id {
+					return request, nil
+				}
+			}
+			return nil, errors.New("not found")
+		},
+		GetByTraceIDFunc: func(ctx context.Context, traceID string) ([]*Request, error) {
+			return stored, nil
+		},
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			filters = append(filters, filter)
+			var matched []*Request
+			for _, request := range stored {
+				if filter.Matches(request) {
+					matched = append(matched, request)
+				}
+			}
+			return matched, nil
+		},
+		DeleteFunc: func(ctx context.Context, id string) error {
+			deletes++
+			return nil
+		},
+	}
+	client := NewClient(storage, WithAccessScoping())
+	team := &AccessScope{Name: "team-search", Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "search"}}}
+	ctx := WithAccessScope(context.Background(), team)
+
+	requests, err := client.query(ctx, &RequestFilter{Model: "gpt-4o"})
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "search", requests[0].ID)
+	assert.Equal(t, "gpt-4o", filters[0].Model, "the caller's criteria are kept")
+
+	requests, err = client.query(ctx, &RequestFilter{Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "ads"}}})
+	require.NoError(t, err)
+	assert.Empty(t, requests, "the scope cannot be widened by the filter")
+
+	_, err = client.storage.Get(ctx, "ads")
+	assert.ErrorIs(t, err, ErrAccessDenied)
+	request, err := client.storage.Get(ctx, "search")
+	require.NoError(t, err)
+	assert.Equal(t, search, request)
+
+	timeline, err := client.GetTraceTimeline(ctx, "trace-1")
+	require.NoError(t, err)
+	assert.Len(t, timeline.Entries, 1)
+
+	assert.ErrorIs(t, client.storage.Delete(ctx, "search"), ErrAccessDenied)
+	_, err = client.storage.DeleteOlderThan(ctx, time.Now())
+	assert.ErrorIs(t, err, ErrAccessDenied)
+	assert.Zero(t, deletes)
+
+	requests, err = client.query(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Len(t, requests, 2, "reads without a scope are not restricted")
+	require.NoError(t, client.storage.Delete(WithAccessScope(context.Background(), &AccessScope{Name: "admin"}), "ads"))
+	assert.Equal(t, 1, deletes, "unrestricted scopes may delete")
+
+	t.Run("Tracking is not scoped", func(t *testing.T) {
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		assert.Len(t, storage.SaveCalls, 1)
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		updates, err := client.Watch(watchCtx, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, client.TrackRequest(WithFeature(context.Background(), "ads"), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		require.NoError(t, client.TrackRequest(WithFeature(context.Background(), "search"), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+		select {
+		case request := <-updates:
+			assert.Equal(t, "search", request.Dimension(DimensionFeature))
+		case <-time.After(time.Second):
+			t.Fatal("Expected the request within the scope")
+		}
+	})
+}
+
+func TestAccessTokensMiddleware(t *testing.T) {
+	tokens := NewAccessTokens()
+	tokens.Add("team-token", AccessScope{Name: "team-search", Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "search"}}})
+	tokens.Add("revoked-token", AccessScope{Name: "old"})
+	tokens.Revoke("revoked-token")
+
+	var scope *AccessScope
+	handler := tokens.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope = GetAccessScopeFromContext(r.Context())
+	}))
+
+	for header, status := range map[string]int{
+		"Bearer team-token":    http.StatusOK,
+		"Bearer revoked-token": http.StatusUnauthorized,
+		"Bearer unknown":       http.StatusUnauthorized,
+		"team-token":           http.StatusUnauthorized,
+		"":                     http.StatusUnauthorized,
+	} {
+		scope = nil
+		req := httptest.NewRequest(http.MethodGet, "/requests", nil)
+		req.Header.Set("Authorization", header)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		assert.Equal(t, status, recorder.Code, header)
+		if status == http.StatusOK {
+			require.NotNil(t, scope)
+			assert.Equal(t, "team-search", scope.Name)
+		} else {
+			assert.Nil(t, scope, header)
+		}
+	}
+}
+
+func TestAccessScopeCapabilities(t *testing.T) {
+	search := &Request{ID: "search", Provider: ProviderOpenAI, Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "search"}}}
+	ads := &Request{ID: "ads", Provider: ProviderAnthropic, Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "ads"}}}
+	newMock := func() *MockStorageAdapter {
+		return &MockStorageAdapter{
+			GetFunc: func(ctx context.Context, id string) (*Request, error) {
+				for _, request := range []*Request{search, ads} {
+					if request.ID == id {
+						return request, nil
+					}
+				}
+				return nil, errors.New("not found")
+			},
+			AggregateFunc: func(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+				var results []*AggregateResult
+				for _, request := range []*Request{search, ads} {
+					if filter.Matches(request) {
+						results = append(results, &AggregateResult{Provider: request.Provider, TotalRequests: 1})
+					}
+				}
+				return results, nil
+			},
+		}
+	}
+	ctx := context.Background()
+	team := WithAccessScope(ctx, &AccessScope{Name: "team-search", Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "search"}}})
+
+	t.Run("Feedback", func(t *testing.T) {
+		storage := &feedbackStorage{MockStorageAdapter: newMock()}
+		client := NewClient(storage, WithAccessScoping())
+		for _, id := range []string{"search", "ads"} {
+			require.NoError(t, client.RecordFeedback(ctx, &Feedback{RequestID: id, Comment: id}))
+		}
+
+		feedback, err := client.QueryFeedback(team, nil)
+		require.NoError(t, err)
+		require.Len(t, feedback, 1)
+		assert.Equal(t, "search", feedback[0].RequestID)
+
+		all, err := client.QueryFeedback(ctx, nil)
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		assert.ErrorIs(t, client.RecordFeedback(team, &Feedback{RequestID: "search"}), ErrAccessDenied)
+		assert.Len(t, storage.feedback, 2)
+	})
+
+	t.Run("Saved filters", func(t *testing.T) {
+		storage := &savedFilterStorage{MockStorageAdapter: newMock(), filters: map[string]*SavedFilter{}}
+		client := NewClient(storage, WithAccessScoping())
+		require.NoError(t, client.SaveFilter(ctx, "search-errors", &RequestFilter{Dimensions: []DimensionTag{{Key: DimensionFeature, Value: "search"}}}))
+		require.NoError(t, client.SaveFilter(ctx, "alice", &RequestFilter{Dimensions: []DimensionTag{{Key: DimensionUserID, Value: "alice"}}}))
+
+		filters, err := client.ListSavedFilters(team)
+		require.NoError(t, err)
+		require.Len(t, filters, 1)
+		assert.Equal(t, "search-errors", filters[0].Name)
+
+		_, err = client.GetSavedFilter(team, "alice")
+		assert.ErrorIs(t, err, ErrAccessDenied)
+		_, err = client.GetSavedFilter(team, "search-errors")
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, client.SaveFilter(team, "mine", nil), ErrAccessDenied)
+		assert.ErrorIs(t, client.DeleteSavedFilter(team, "search-errors"), ErrAccessDenied)
+		assert.Len(t, storage.filters, 2)
+	})
+
+	t.Run("Incidents", func(t *testing.T) {
+		storage := &incidentStorage{MockStorageAdapter: newMock()}
+		client := NewClient(storage, WithAccessScoping())
+		source := StatusSourceFunc(func(ctx context.Context) ([]*ProviderIncident, error) {
+			return []*ProviderIncident{
+				{ID: "openai:1", Provider: ProviderOpenAI, StartedAt: time.Now()},
+				{ID: "anthropic:1", Provider: ProviderAnthropic, StartedAt: time.Now()},
+			}, nil
+		})
+		assert.ErrorIs(t, client.PollIncidents(team, source), ErrAccessDenied)
+		require.NoError(t, client.PollIncidents(ctx, source))
+
+		incidents, err := client.GetIncidents(team, nil)
+		require.NoError(t, err)
+		require.Len(t, incidents, 1, "only the providers used within the scope")
+		assert.Equal(t, ProviderOpenAI, incidents[0].Provider)
+
+		all, err := client.GetIncidents(ctx, nil)
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("Soft delete", func(t *testing.T) {
+		storage := &softDeleteStorage{MockStorageAdapter: newMock(), purges: make(chan time.Time, 1)}
+		client := NewClient(storage, WithAccessScoping())
+		softDelete, ok := client.storage.(SoftDeleteStorage)
+		require.True(t, ok, "soft delete support is kept")
+
+		assert.ErrorIs(t, softDelete.Restore(team, "search"), ErrAccessDenied)
+		_, err := softDelete.PurgeDeleted(team, time.Now())
+		assert.ErrorIs(t, err, ErrAccessDenied)
+		assert.Empty(t, storage.purges)
+
+		require.NoError(t, softDelete.Restore(ctx, "search"))
+		_, err = softDelete.PurgeDeleted(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Len(t, storage.purges, 1)
+	})
+}
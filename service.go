@@ -0,0 +1,90 @@
+package llmtracer
+
+import (
+	"fmt"
+	"os"
+)
+
+// Dimensions stamped on every request by WithServiceInfo, next to DimensionEnvironment
+const (
+	DimensionService        = "service"
+	DimensionServiceVersion = "service_version"
+	DimensionHostname       = "hostname"
+)
+
+// WithServiceInfo stamps every request with the service name, version and environment,
+// and the host name, as the service, service_version, environment and hostname
+// dimensions, so cross-service reports don't depend on each call site setting them. Empty
+// values are skipped. Dimensions set by the call take precedence, and the dimension
+// policy applies as usual, so an allowlist must list these keys to keep them.
+func WithServiceInfo(name, version, env string) ClientOption {
+	return func(c *Client) {
+		hostname, _ := os.Hostname()
+		c.addDefaultDimensions(map[string]string{
+			DimensionService:        name,
+			DimensionServiceVersion: version,
+			DimensionEnvironment:    env,
+			DimensionHostname:       hostname,
+		})
+	}
+}
+
+// WithGlobalDimensions stamps every request with static dimensions, such as a team or
+// tenant that doesn't vary per call, without passing them through WithDimensions at each
+// call site. Values are formatted like other dimension values, and nil values are skipped.
+// Dimensions set by the call take precedence, and the dimension policy applies as usual.
+// It can be given several times; later values replace earlier ones for the same key.
+func WithGlobalDimensions(dimensions map[string]any) ClientOption {
+	return func(c *Client) {
+		formatted := make(map[string]string, len(dimensions))
+		for key, value := range dimensions {
+			if value != nil {
+				formatted[key] = fmt.Sprintf("%v", value)
+			}
+		}
+		c.addDefaultDimensions(formatted)
+	}
+}
+
+// WithRegion stamps every request with the region the client calls, e.g. the Azure or
+// Bedrock region of its deployments, as the region dimension. Calls served from another
+// region can set the dimension themselves, which takes precedence. Costs are computed at
+// the price of the request's region when the pricing has one; see ModelPrice.Regions.
+func WithRegion(region string) ClientOption {
+	return func(c *Client) {
+		c.addDefaultDimensions(map[string]string{DimensionRegion: region})
+	}
+}
+
+// addDefaultDimensions adds the non-empty values to the dimensions stamped on every request
+func (c *Client) addDefaultDimensions(dimensions map[string]string) {
+	for key, value := range dimensions {
+		if value == "" {
+			continue
+		}
+		if c.dimensions.defaults == nil {
+			c.dimensions.defaults = make(map[string]string)
+		}
+		c.dimensions.defaults[key] = value
+	}
+}
+
+// withDefaultDimensions returns the dimensions with the client's default dimensions added
+// where the call did not set them
+func (c *Client) withDefaultDimensions(trackingContext map[string]interface{}) map[string]interface{} {
+	if len(c.dimensions.defaults) == 0 {
+		return trackingContext
+	}
+	merged := make(map[string]interface{}, len(trackingContext)+len(c.dimensions.defaults))
+	for key, value := range c.dimensions.defaults {
+		// The call's keys are normalized by now, so the defaults must be for them to match
+		if c.dimensions.normalizeKeys {
+			key = NormalizeDimensionKey(key)
+		}
+		merged[key] = value
+	}
+	for key, value := range trackingContext {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,88 @@
+package llmtracer
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithServiceInfo(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithServiceInfo("checkout", "1.4.2", "prod"))
+
+	ctx := WithDimensions(WithUserID(context.Background(), "alice"), map[string]interface{}{DimensionEnvironment: "canary"})
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+	require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+
+	require.Len(t, storage.SaveCalls, 2)
+	assert.Equal(t, map[string]string{
+		DimensionService:        "checkout",
+		DimensionServiceVersion: "1.4.2",
+		DimensionEnvironment:    "canary",
+		DimensionHostname:       hostname,
+		DimensionUserID:         "alice",
+	}, storage.SaveCalls[0].Request.DimensionsMap(), "the call's dimensions take precedence")
+	assert.Equal(t, "prod", storage.SaveCalls[1].Request.Dimension(DimensionEnvironment))
+
+	t.Run("Empty values are skipped", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage, WithServiceInfo("checkout", "", ""))
+		require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+
+		dimensions := storage.SaveCalls[0].Request.DimensionsMap()
+		assert.Equal(t, "checkout", dimensions[DimensionService])
+		assert.NotContains(t, dimensions, DimensionServiceVersion)
+		assert.NotContains(t, dimensions, DimensionEnvironment)
+	})
+}
+
+func TestWithRegion(t *testing.T) {
+	pricing := NewPricingRegistry()
+	pricing.Set(ProviderOpenAI, "gpt-4o", ModelPrice{
+		InputPerMillion: 2.5,
+		Regions:         map[string]ModelPrice{"swedencentral": {InputPerMillion: 2.75}},
+	})
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage, WithPricing(pricing), WithRegion("SwedenCentral"))
+
+	ctx := WithDimensions(context.Background(), map[string]interface{}{DimensionRegion: "eastus"})
+	require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1_000_000, 0, 0, nil, nil))
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1_000_000, 0, 0, nil, nil))
+
+	require.Len(t, storage.SaveCalls, 2)
+	inSweden, inEastUS := storage.SaveCalls[0].Request, storage.SaveCalls[1].Request
+	assert.Equal(t, "SwedenCentral", inSweden.Region())
+	assert.InDelta(t, 2.75, inSweden.Cost, 1e-9, "regions match regardless of case")
+	assert.Equal(t, "eastus", inEastUS.Region())
+	assert.InDelta(t, 2.5, inEastUS.Cost, 1e-9, "regions without a price of their own use the model's")
+
+	estimate, err := client.EstimateCost(ProviderOpenAI, "gpt-4o", []string{"hi"}, 0)
+	require.NoError(t, err)
+	assert.InDelta(t, float64(estimate.InputTokens)*2.75/1_000_000, estimate.MinCost, 1e-12)
+}
+
+func TestWithGlobalDimensions(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage,
+		WithGlobalDimensions(map[string]any{"team": "payments", "costCenter": 4711, "tier": nil}),
+		WithGlobalDimensions(map[string]any{"team": "checkout"}),
+		WithDimensionKeyNormalization(true),
+	)
+
+	ctx := WithDimensions(context.Background(), map[string]interface{}{"cost_center": "override"})
+	require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, 0, nil, nil))
+
+	require.Len(t, storage.SaveCalls, 2)
+	assert.Equal(t, map[string]string{
+		"team":        "checkout",
+		"cost_center": "4711",
+	}, storage.SaveCalls[0].Request.DimensionsMap(), "nil values are skipped and later values win")
+	assert.Equal(t, "override", storage.SaveCalls[1].Request.Dimension("cost_center"), "the call's dimensions take precedence")
+}
@@ -0,0 +1,425 @@
+package llmtracer
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultShadowCompareInterval = time.Hour
+	defaultShadowCompareWindow   = 24 * time.Hour
+	defaultShadowMaxIDs          = 100
+)
+
+// ShadowStorage writes requests to a primary adapter and mirrors the writes to a shadow
+// adapter, e.g. a new backend being validated before cutting over. Reads, and every
+// optional interface other than request lifecycle and soft delete, are served by the
+// primary alone. Shadow failures never fail the caller; they are counted in Stats, and
+// Compare reports how the data stored by the two adapters diverges.
+//
+// Shadow writes are synchronous, so they add the shadow's latency to each save; combine
+// with WithAsyncTracking to keep it off the request path.
+type ShadowStorage struct {
+	StorageAdapter
+	shadow StorageAdapter
+
+	mu    sync.Mutex
+	stats ShadowStats
+}
+
+// ShadowStats counts the writes mirrored to the shadow adapter
+type ShadowStats struct {
+	// Writes is the number of writes that succeeded on the primary and were mirrored
+	Writes int64 `json:"writes"`
+	// Errors is the number of mirrored writes that failed on the shadow
+	Errors      int64     `json:"errors"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at"`
+}
+
+// NewShadowStorage mirrors the writes to primary onto shadow
+func NewShadowStorage(primary, shadow StorageAdapter) *ShadowStorage {
+	if primary == nil || shadow == nil {
+		panic("storage adapter cannot be nil")
+	}
+	return &ShadowStorage{StorageAdapter: primary, shadow: shadow}
+}
+
+// Shadow returns the shadow adapter
+func (s *ShadowStorage) Shadow() StorageAdapter {
+	return s.shadow
+}
+
+// Stats returns the counts of mirrored writes so far
+func (s *ShadowStorage) Stats() ShadowStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// mirror records the outcome of a write mirrored to the shadow
+func (s *ShadowStorage) mirror(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Writes++
+	if err != nil {
+		s.stats.Errors++
+		s.stats.LastError = err.Error()
+		s.stats.LastErrorAt = time.Now()
+	}
+}
+
+func (s *ShadowStorage) unwrap() StorageAdapter {
+	return s.StorageAdapter
+}
+
+func (s *ShadowStorage) Capabilities() StorageCapabilities {
+	return wrappedCapabilities(s.StorageAdapter)
+}
+
+func (s *ShadowStorage) AggregateMulti(ctx context.Context, specs []AggregateSpec) ([][]*AggregateResult, error) {
+	return aggregateMulti(ctx, s.StorageAdapter, specs)
+}
+
+func (s *ShadowStorage) Save(ctx context.Context, request *Request) error {
+	// Adapters may rewrite the request, e.g. with the IDs of its dimensions in the primary
+	// database, so the shadow gets a copy taken beforehand
+	shadowed := portableCopy(request)
+	if err := s.StorageAdapter.Save(ctx, request); err != nil {
+		return err
+	}
+	s.mirror(s.shadow.Save(ctx, shadowed))
+	return nil
+}
+
+func (s *ShadowStorage) Update(ctx context.Context, request *Request) error {
+	shadowed := portableCopy(request)
+	if err := updateRequest(ctx, s.StorageAdapter, request); err != nil {
+		return err
+	}
+	s.mirror(updateRequest(ctx, s.shadow, shadowed))
+	return nil
+}
+
+func (s *ShadowStorage) Delete(ctx context.Context, id string) error {
+	if err := s.StorageAdapter.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.mirror(s.shadow.Delete(ctx, id))
+	return nil
+}
+
+func (s *ShadowStorage) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	deleted, err := s.StorageAdapter.DeleteOlderThan(ctx, before)
+	if err != nil {
+		return deleted, err
+	}
+	_, err = s.shadow.DeleteOlderThan(ctx, before)
+	s.mirror(err)
+	return deleted, nil
+}
+
+func (s *ShadowStorage) Restore(ctx context.Context, id string) error {
+	softDelete, ok := s.StorageAdapter.(SoftDeleteStorage)
+	if !ok {
+		return errSoftDeleteNotSupported
+	}
+	if err := softDelete.Restore(ctx, id); err != nil {
+		return err
+	}
+	if shadow, ok := s.shadow.(SoftDeleteStorage); ok {
+		s.mirror(shadow.Restore(ctx, id))
+	}
+	return nil
+}
+
+// PurgeDeleted purges both adapters when the primary implements SoftDeleteStorage
+func (s *ShadowStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	softDelete, ok := s.StorageAdapter.(SoftDeleteStorage)
+	if !ok {
+		return 0, nil
+	}
+	purged, err := softDelete.PurgeDeleted(ctx, before)
+	if err != nil {
+		return purged, err
+	}
+	if shadow, ok := s.shadow.(SoftDeleteStorage); ok {
+		_, err := shadow.PurgeDeleted(ctx, before)
+		s.mirror(err)
+	}
+	return purged, nil
+}
+
+// CompactRequests compacts requests in the primary and, when the shadow stores rollups,
+// in the shadow
+func (s *ShadowStorage) CompactRequests(ctx context.Context, rollups []*RequestRollup, requestIDs []string) error {
+	primary, ok := storageCapability[RollupStorage](s.StorageAdapter)
+	if !ok {
+		return ErrRollupsNotSupported
+	}
+	if err := primary.CompactRequests(ctx, rollups, requestIDs); err != nil {
+		return err
+	}
+	if shadow, ok := storageCapability[RollupStorage](s.shadow); ok {
+		s.mirror(shadow.CompactRequests(ctx, rollups, requestIDs))
+	}
+	return nil
+}
+
+// QueryRollups reads rollups from the primary
+func (s *ShadowStorage) QueryRollups(ctx context.Context, filter *RequestFilter) ([]*RequestRollup, error) {
+	primary, ok := storageCapability[RollupStorage](s.StorageAdapter)
+	if !ok {
+		return nil, ErrRollupsNotSupported
+	}
+	return primary.QueryRollups(ctx, filter)
+}
+
+// Close closes both adapters, returning the primary's error
+func (s *ShadowStorage) Close() error {
+	shadowErr := s.shadow.Close()
+	if err := s.StorageAdapter.Close(); err != nil {
+		return err
+	}
+	return shadowErr
+}
+
+// ShadowCompareOptions configures ShadowStorage.Compare
+type ShadowCompareOptions struct {
+	// Start and End bound the requests compared; End defaults to now and Start to 24
+	// hours before End
+	Start time.Time
+	End   time.Time
+	// Interval is the width of each bucket of the report; an hour when zero
+	Interval time.Duration
+	// Filter narrows the requests compared; its time range, Limit, Offset and ordering
+	// are ignored
+	Filter *RequestFilter
+	// BatchSize is the number of requests read at a time; 500 when zero
+	BatchSize int
+	// MaxIDs caps each list of divergent request IDs in the report; 100 when zero
+	MaxIDs int
+}
+
+// ShadowReport describes how the requests stored by the shadow adapter diverge from the
+// primary's over a time range
+type ShadowReport struct {
+	Start   time.Time      `json:"start"`
+	End     time.Time      `json:"end"`
+	Buckets []ShadowBucket `json:"buckets"`
+	// PrimaryRequests and ShadowRequests count the requests found in each adapter
+	PrimaryRequests int64 `json:"primary_requests"`
+	ShadowRequests  int64 `json:"shadow_requests"`
+	// MissingInShadow, MissingInPrimary and Mismatched list the IDs of divergent requests,
+	// up to ShadowCompareOptions.MaxIDs each
+	MissingInShadow  []string `json:"missing_in_shadow,omitempty"`
+	MissingInPrimary []string `json:"missing_in_primary,omitempty"`
+	Mismatched       []string `json:"mismatched,omitempty"`
+	// Writes are the write counts of the ShadowStorage when the report was made
+	Writes ShadowStats `json:"writes"`
+}
+
+// Diverged reports whether any bucket diverged
+func (r *ShadowReport) Diverged() bool {
+	for _, bucket := range r.Buckets {
+		if bucket.Diverged() {
+			return true
+		}
+	}
+	return false
+}
+
+// ShadowBucket compares the requests sent during one interval. Checksums are
+// order-independent hashes of the stored requests, equal when both adapters hold the
+// same data.
+type ShadowBucket struct {
+	Start           time.Time `json:"start"`
+	PrimaryRequests int64     `json:"primary_requests"`
+	ShadowRequests  int64     `json:"shadow_requests"`
+	PrimaryChecksum uint64    `json:"primary_checksum"`
+	ShadowChecksum  uint64    `json:"shadow_checksum"`
+	// MissingInShadow, MissingInPrimary and Mismatched count the divergent requests
+	MissingInShadow  int64 `json:"missing_in_shadow,omitempty"`
+	MissingInPrimary int64 `json:"missing_in_primary,omitempty"`
+	Mismatched       int64 `json:"mismatched,omitempty"`
+}
+
+// Diverged reports whether the adapters hold different requests for the bucket
+func (b ShadowBucket) Diverged() bool {
+	return b.PrimaryRequests != b.ShadowRequests || b.PrimaryChecksum != b.ShadowChecksum
+}
+
+// Compare reads the requests sent between Start and End from both adapters, one bucket at
+// a time, and reports the buckets whose counts or checksums differ along with the IDs of
+// the requests missing from either adapter or stored differently. Checksums cover the
+// fields adapters are expected to store exactly: IDs, provider, model, request type,
+// token counts, cost, latency, status, error type, dimensions and the time of the
+// request to the millisecond. Error text and payloads are not compared, since
+// WithEncryption stores them with random nonces.
+func (s *ShadowStorage) Compare(ctx context.Context, opts ShadowCompareOptions) (*ShadowReport, error) {
+	end := opts.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	start := opts.Start
+	if start.IsZero() {
+		start = end.Add(-defaultShadowCompareWindow)
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultShadowCompareInterval
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRecalculateBatchSize
+	}
+	maxIDs := opts.MaxIDs
+	if maxIDs <= 0 {
+		maxIDs = defaultShadowMaxIDs
+	}
+
+	report := &ShadowReport{Start: start, End: end, Buckets: []ShadowBucket{}}
+	for bucketStart := start; bucketStart.Before(end); bucketStart = bucketStart.Add(interval) {
+		// Time filters are inclusive, so each bucket ends just before the next starts
+		bucketEnd := bucketStart.Add(interval)
+		if !bucketEnd.Before(end) {
+			bucketEnd = end.Add(time.Nanosecond)
+		}
+		filter := RequestFilter{}
+		if opts.Filter != nil {
+			filter = *opts.Filter
+		}
+		filter.StartTime = &bucketStart
+		last := bucketEnd.Add(-time.Nanosecond)
+		filter.EndTime = &last
+
+		primary, err := shadowChecksums(ctx, s.StorageAdapter, filter, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		shadow, err := shadowChecksums(ctx, s.shadow, filter, batchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		bucket := ShadowBucket{
+			Start:           bucketStart,
+			PrimaryRequests: int64(len(primary)),
+			ShadowRequests:  int64(len(shadow)),
+		}
+		for _, id := range sortedKeys(primary) {
+			sum := primary[id]
+			bucket.PrimaryChecksum += sum
+			shadowSum, ok := shadow[id]
+			switch {
+			case !ok:
+				bucket.MissingInShadow++
+				report.MissingInShadow = appendCapped(report.MissingInShadow, id, maxIDs)
+			case shadowSum != sum:
+				bucket.Mismatched++
+				report.Mismatched = appendCapped(report.Mismatched, id, maxIDs)
+			}
+		}
+		for _, id := range sortedKeys(shadow) {
+			bucket.ShadowChecksum += shadow[id]
+			if _, ok := primary[id]; !ok {
+				bucket.MissingInPrimary++
+				report.MissingInPrimary = appendCapped(report.MissingInPrimary, id, maxIDs)
+			}
+		}
+
+		report.PrimaryRequests += bucket.PrimaryRequests
+		report.ShadowRequests += bucket.ShadowRequests
+		report.Buckets = append(report.Buckets, bucket)
+	}
+	report.Writes = s.Stats()
+	return report, nil
+}
+
+// shadowChecksums reads the requests matching filter from storage in batches, returning
+// the checksum of each by ID
+func shadowChecksums(ctx context.Context, storage StorageAdapter, filter RequestFilter, batchSize int) (map[string]uint64, error) {
+	filter.Limit = batchSize
+	filter.OrderBy = "id"
+	filter.OrderDesc = false
+	filter.Offset = 0
+
+	sums := make(map[string]uint64)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		requests, err := storage.Query(ctx, &filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, request := range requests {
+			sums[request.ID] = requestChecksum(request)
+		}
+		if len(requests) < batchSize {
+			return sums, nil
+		}
+		filter.Offset += len(requests)
+	}
+}
+
+// requestChecksum hashes the fields of a stored request compared by ShadowStorage.Compare
+func requestChecksum(request *Request) uint64 {
+	hash := fnv.New64a()
+	write := func(value string) {
+		hash.Write([]byte(value))
+		hash.Write([]byte{0})
+	}
+	writeInt := func(value int64) {
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], uint64(value))
+		hash.Write(buf[:])
+	}
+
+	write(request.ID)
+	write(request.TraceID)
+	write(string(request.Provider))
+	write(request.Model)
+	write(string(request.RequestType))
+	writeInt(request.InputTokens)
+	writeInt(request.OutputTokens)
+	writeInt(request.TotalTokens)
+	// Formatted so that backends storing cost with less precision than a float64 still
+	// compare equal
+	write(strconv.FormatFloat(request.Cost, 'g', 9, 64))
+	writeInt(request.LatencyMs)
+	writeInt(int64(request.StatusCode))
+	write(string(request.ErrorType))
+	writeInt(request.RequestedAt.UnixMilli())
+
+	dimensions := make([]string, len(request.Dimensions))
+	for i, dim := range request.Dimensions {
+		dimensions[i] = dim.Key + "=" + dim.Value
+	}
+	sort.Strings(dimensions)
+	for _, dim := range dimensions {
+		write(dim)
+	}
+	return hash.Sum64()
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func appendCapped(ids []string, id string, limit int) []string {
+	if len(ids) >= limit {
+		return ids
+	}
+	return append(ids, id)
+}
@@ -0,0 +1,203 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore keeps saved requests in memory behind a mock adapter
+type memoryStore struct {
+	mu       sync.Mutex
+	requests map[string]*Request
+	saveErr  error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{requests: make(map[string]*Request)}
+}
+
+func (m *memoryStore) adapter() *MockStorageAdapter {
+	return &MockStorageAdapter{
+		SaveFunc: func(ctx context.Context, request *Request) error {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if m.saveErr != nil {
+				return m.saveErr
+			}
+			if _, ok := m.requests[request.ID]; ok {
+				return errors.New("duplicate key")
+			}
+			stored := *request
+			stored.Dimensions = append([]DimensionTag(nil), request.Dimensions...)
+			m.requests[request.ID] = &stored
+			// Like the GORM adapter, replace the dimensions with stored ones carrying IDs
+			for i := range request.Dimensions {
+				request.Dimensions[i].ID = uint(i + 1)
+			}
+			return nil
+		},
+		GetFunc: func(ctx context.Context, id string) (*Request, error) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			request, ok := m.requests[id]
+			if !ok {
+				return nil, errors.New("not found")
+			}
+			return request, nil
+		},
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			var matched []*Request
+			for _, request := range m.requests {
+				if filter.Matches(request) {
+					matched = append(matched, request)
+				}
+			}
+			sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+			if filter.Offset >= len(matched) {
+				return nil, nil
+			}
+			matched = matched[filter.Offset:]
+			if filter.Limit > 0 && len(matched) > filter.Limit {
+				matched = matched[:filter.Limit]
+			}
+			return matched, nil
+		},
+		DeleteFunc: func(ctx context.Context, id string) error {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			delete(m.requests, id)
+			return nil
+		},
+	}
+}
+
+func TestShadowStorageMirrorsWrites(t *testing.T) {
+	primary, shadow := newMemoryStore(), newMemoryStore()
+	var shadowDimensions []DimensionTag
+	shadowAdapter := shadow.adapter()
+	saveShadow := shadowAdapter.SaveFunc
+	shadowAdapter.SaveFunc = func(ctx context.Context, request *Request) error {
+		shadowDimensions = append([]DimensionTag(nil), request.Dimensions...)
+		return saveShadow(ctx, request)
+	}
+	storage := NewShadowStorage(primary.adapter(), shadowAdapter)
+
+	client := NewClient(storage)
+	ctx := WithFeature(context.Background(), "search")
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 20, time.Second, nil, nil))
+
+	assert.Len(t, primary.requests, 1)
+	assert.Len(t, shadow.requests, 1)
+	require.NotEmpty(t, shadowDimensions)
+	for _, dim := range shadowDimensions {
+		assert.Zero(t, dim.ID, "the shadow gets dimensions without the primary's IDs")
+	}
+
+	// Shadow failures are counted but never fail the caller
+	shadow.saveErr = errors.New("shadow unavailable")
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 20, time.Second, nil, nil))
+	assert.Len(t, primary.requests, 2)
+
+	stats := storage.Stats()
+	assert.Equal(t, int64(2), stats.Writes)
+	assert.Equal(t, int64(1), stats.Errors)
+	assert.Equal(t, "shadow unavailable", stats.LastError)
+
+	// Primary failures are returned and not mirrored
+	primary.saveErr = errors.New("primary unavailable")
+	assert.Error(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 100, 20, time.Second, nil, nil))
+	assert.Equal(t, int64(2), storage.Stats().Writes)
+}
+
+func TestShadowStorageCompare(t *testing.T) {
+	primary, shadow := newMemoryStore(), newMemoryStore()
+	storage := NewShadowStorage(primary.adapter(), shadow.adapter())
+	ctx := context.Background()
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	save := func(id string, at time.Duration, tokens int64) *Request {
+		request := &Request{ID: id, Provider: ProviderOpenAI, Model: "gpt-4o", InputTokens: tokens, RequestedAt: start.Add(at)}
+		require.NoError(t, storage.Save(ctx, request))
+		return request
+	}
+	save("a", 10*time.Minute, 100)
+	save("b", 70*time.Minute, 100)
+	save("c", 80*time.Minute, 100)
+	save("d", 130*time.Minute, 100)
+
+	report, err := storage.Compare(ctx, ShadowCompareOptions{Start: start, End: start.Add(3 * time.Hour), BatchSize: 1})
+	require.NoError(t, err)
+	assert.False(t, report.Diverged())
+	require.Len(t, report.Buckets, 3)
+	assert.Equal(t, int64(4), report.PrimaryRequests)
+	assert.Equal(t, int64(2), report.Buckets[1].ShadowRequests)
+	assert.Equal(t, int64(4), report.Writes.Writes)
+
+	// The shadow loses one request, stores another differently and has one of its own
+	delete(shadow.requests, "b")
+	shadow.requests["c"].InputTokens = 99
+	shadow.requests["e"] = &Request{ID: "e", Provider: ProviderOpenAI, Model: "gpt-4o", RequestedAt: start.Add(150 * time.Minute)}
+
+	report, err = storage.Compare(ctx, ShadowCompareOptions{Start: start, End: start.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	assert.True(t, report.Diverged())
+	assert.False(t, report.Buckets[0].Diverged())
+	assert.Equal(t, int64(1), report.Buckets[1].MissingInShadow)
+	assert.Equal(t, int64(1), report.Buckets[1].Mismatched)
+	assert.Equal(t, int64(1), report.Buckets[2].MissingInPrimary)
+	assert.Equal(t, []string{"b"}, report.MissingInShadow)
+	assert.Equal(t, []string{"c"}, report.Mismatched)
+	assert.Equal(t, []string{"e"}, report.MissingInPrimary)
+
+	// Filters narrow the comparison
+	report, err = storage.Compare(ctx, ShadowCompareOptions{Start: start, End: start.Add(3 * time.Hour), Filter: &RequestFilter{Model: "gpt-4o-mini"}})
+	require.NoError(t, err)
+	assert.False(t, report.Diverged())
+	assert.Zero(t, report.PrimaryRequests)
+}
+
+func TestShadowStorageCompareBucketBoundaries(t *testing.T) {
+	primary, shadow := newMemoryStore(), newMemoryStore()
+	storage := NewShadowStorage(primary.adapter(), shadow.adapter())
+	ctx := context.Background()
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, at := range []time.Duration{0, time.Hour, 2 * time.Hour} {
+		require.NoError(t, storage.Save(ctx, &Request{ID: string(rune('a' + i)), RequestedAt: start.Add(at)}))
+	}
+
+	report, err := storage.Compare(ctx, ShadowCompareOptions{Start: start, End: start.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, report.Buckets, 2)
+	assert.Equal(t, int64(1), report.Buckets[0].PrimaryRequests, "a request on a boundary belongs to the next bucket")
+	assert.Equal(t, int64(2), report.Buckets[1].PrimaryRequests, "the last bucket includes End")
+}
+
+func TestRequestChecksum(t *testing.T) {
+	request := &Request{
+		ID:          "a",
+		Provider:    ProviderOpenAI,
+		Model:       "gpt-4o",
+		Cost:        0.1 + 0.2,
+		RequestedAt: time.Date(2026, 10, 1, 0, 0, 0, 123456789, time.UTC),
+		Dimensions:  []DimensionTag{{ID: 1, Key: "feature", Value: "search"}, {ID: 2, Key: "env", Value: "prod"}},
+	}
+	stored := portableCopy(request)
+	stored.Cost = 0.3
+	stored.RequestedAt = request.RequestedAt.Truncate(time.Microsecond)
+	stored.Dimensions[0], stored.Dimensions[1] = stored.Dimensions[1], stored.Dimensions[0]
+	stored.Error = "encrypted"
+	assert.Equal(t, requestChecksum(request), requestChecksum(stored), "storage precision, order and IDs are ignored")
+
+	stored.Model = "gpt-4o-mini"
+	assert.NotEqual(t, requestChecksum(request), requestChecksum(stored))
+}
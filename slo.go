@@ -0,0 +1,380 @@
+package llmtracer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sloBuckets is the number of buckets a rolling SLO window is divided into
+const sloBuckets = 60
+
+// sloWebhookTimeout bounds the delivery of one SLO event to a webhook
+const sloWebhookTimeout = 10 * time.Second
+
+// LatencySLO is a latency objective over a rolling window, e.g. 99% of gpt-4o requests of
+// the search feature within 2s over the last hour
+type LatencySLO struct {
+	Name string `json:"name"`
+	// Provider, Model and Feature select the requests covered; empty fields match any
+	Provider Provider `json:"provider,omitempty"`
+	Model    string   `json:"model,omitempty"`
+	Feature  string   `json:"feature,omitempty"`
+	// Threshold is the latency a request must not exceed to count as good
+	Threshold time.Duration `json:"threshold"`
+	// Target is the fraction (0-1) of requests that must be good, e.g. 0.99
+	Target float64 `json:"target"`
+	// Window is the rolling window compliance is computed over; defaults to one hour
+	Window time.Duration `json:"window"`
+	// MinRequests is the number of requests the window needs before the objective can be
+	// breached, so a few slow calls after a quiet period don't page; defaults to 10
+	MinRequests int64 `json:"min_requests"`
+}
+
+// matches reports whether the objective covers request
+func (s *LatencySLO) matches(request *Request) bool {
+	return (s.Provider == "" || s.Provider == request.Provider) &&
+		(s.Model == "" || s.Model == request.Model) &&
+		(s.Feature == "" || s.Feature == request.Dimension(DimensionFeature))
+}
+
+// SLOStatus is the compliance of a latency SLO over its current window
+type SLOStatus struct {
+	SLO      LatencySLO `json:"slo"`
+	Requests int64      `json:"requests"`
+	// Good counts the requests within the threshold
+	Good int64 `json:"good"`
+	// Compliance is the fraction of good requests, 1 when the window is empty
+	Compliance float64 `json:"compliance"`
+	Breached   bool    `json:"breached"`
+}
+
+// SLOEventType is the kind of an SLOEvent
+type SLOEventType string
+
+const (
+	// SLOEventBreach is emitted when compliance drops below the target
+	SLOEventBreach SLOEventType = "breach"
+	// SLOEventRecovered is emitted when a breached objective is met again
+	SLOEventRecovered SLOEventType = "recovered"
+)
+
+// SLOEvent reports a latency SLO or a burn-rate alert entering or leaving breach
+type SLOEvent struct {
+	Type SLOEventType `json:"type"`
+	// Status is set for latency SLO events
+	Status *SLOStatus `json:"status,omitempty"`
+	// BurnRate is set for burn-rate alert events
+	BurnRate *BurnRateStatus `json:"burn_rate,omitempty"`
+	At       time.Time       `json:"at"`
+}
+
+// name returns the name of the objective or alert the event is about
+func (e SLOEvent) name() string {
+	if e.BurnRate != nil {
+		return e.BurnRate.Alert.Name
+	}
+	return e.Status.SLO.Name
+}
+
+// SLOOption configures an SLOMonitor
+type SLOOption func(*SLOMonitor)
+
+// WithLatencySLO adds an objective to the monitor. Objectives are identified by name.
+func WithLatencySLO(slo LatencySLO) SLOOption {
+	return func(m *SLOMonitor) {
+		m.add(slo)
+	}
+}
+
+// WithSLOHook calls hook with every breach and recovery of the monitor's latency SLOs and
+// burn-rate alerts. hook runs in the tracking path
+// and must not block.
+func WithSLOHook(hook func(SLOEvent)) SLOOption {
+	return func(m *SLOMonitor) {
+		m.hooks = append(m.hooks, hook)
+	}
+}
+
+// WithSLOWebhook POSTs every breach and recovery as JSON to url. Deliveries run in the
+// background with a 10s timeout; failures are logged and not retried.
+func WithSLOWebhook(url string) SLOOption {
+	return func(m *SLOMonitor) {
+		m.hooks = append(m.hooks, func(event SLOEvent) {
+			go m.postWebhook(url, event)
+		})
+	}
+}
+
+// SLOMonitor computes the rolling compliance of latency SLOs and the burn rates of error
+// budgets from the requests the client tracks, and emits an event whenever an objective
+// or alert enters or leaves breach, so paging can be driven by the tracer. Windows are
+// kept in memory per process; use Load to seed them from storage after a restart.
+// Breaches and recoveries are only detected when a covered request is tracked.
+type SLOMonitor struct {
+	client     *Client
+	httpClient *http.Client
+	hooks      []func(SLOEvent)
+	now        func() time.Time
+
+	mu         sync.Mutex
+	objectives []*sloObjective
+	burnRates  []*burnRateAlert
+}
+
+// sloObjective is the rolling window of one latency objective
+type sloObjective struct {
+	slo      LatencySLO
+	window   rollingWindow
+	breached bool
+}
+
+// rollingWindow counts requests, and the hits among them, over a window divided into
+// sloBuckets buckets
+type rollingWindow struct {
+	width   time.Duration
+	buckets [sloBuckets]sloBucket
+}
+
+// sloBucket counts the requests of one slice of a window
+type sloBucket struct {
+	slot  int64
+	total int64
+	hits  int64
+}
+
+func newRollingWindow(window time.Duration) rollingWindow {
+	return rollingWindow{width: max(window/sloBuckets, time.Nanosecond)}
+}
+
+// add counts a request made at the given time. Requests older than the window are ignored.
+func (w *rollingWindow) add(at, now time.Time, hit bool) {
+	if at.IsZero() || at.After(now) {
+		at = now
+	}
+	slot := at.UnixNano() / int64(w.width)
+	if slot <= now.UnixNano()/int64(w.width)-sloBuckets {
+		return
+	}
+
+	bucket := &w.buckets[slot%sloBuckets]
+	if bucket.slot != slot {
+		*bucket = sloBucket{slot: slot}
+	}
+	bucket.total++
+	if hit {
+		bucket.hits++
+	}
+}
+
+// counts sums the buckets still inside the window
+func (w *rollingWindow) counts(now time.Time) (total, hits int64) {
+	current := now.UnixNano() / int64(w.width)
+	for _, bucket := range w.buckets {
+		if bucket.slot > current-sloBuckets {
+			total += bucket.total
+			hits += bucket.hits
+		}
+	}
+	return total, hits
+}
+
+// reset empties the window
+func (w *rollingWindow) reset() {
+	w.buckets = [sloBuckets]sloBucket{}
+}
+
+// NewSLOMonitor creates a monitor for the requests tracked by client
+func NewSLOMonitor(client *Client, opts ...SLOOption) *SLOMonitor {
+	if client == nil {
+		panic("client cannot be nil")
+	}
+
+	m := &SLOMonitor{
+		client:     client,
+		httpClient: http.DefaultClient,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(m)
+		}
+	}
+
+	client.observers.add(m.observe)
+	return m
+}
+
+// add registers an objective, replacing one with the same name
+func (m *SLOMonitor) add(slo LatencySLO) {
+	if slo.Window <= 0 {
+		slo.Window = time.Hour
+	}
+	if slo.MinRequests <= 0 {
+		slo.MinRequests = 10
+	}
+	objective := &sloObjective{slo: slo, window: newRollingWindow(slo.Window)}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.objectives {
+		if existing.slo.Name == slo.Name {
+			m.objectives[i] = objective
+			return
+		}
+	}
+	m.objectives = append(m.objectives, objective)
+}
+
+// Load seeds the windows from the requests in storage, replacing the counts observed so
+// far. Breach state is recomputed without emitting events.
+func (m *SLOMonitor) Load(ctx context.Context) error {
+	now := m.now()
+	var longest time.Duration
+	m.mu.Lock()
+	for _, objective := range m.objectives {
+		longest = max(longest, objective.slo.Window)
+	}
+	for _, alert := range m.burnRates {
+		longest = max(longest, alert.alert.FastWindow, alert.alert.SlowWindow)
+	}
+	m.mu.Unlock()
+	if longest == 0 {
+		return nil
+	}
+
+	start := now.Add(-longest)
+	requests, err := m.client.query(ctx, &RequestFilter{StartTime: &start})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, objective := range m.objectives {
+		objective.window.reset()
+		for _, request := range requests {
+			if objective.slo.matches(request) {
+				objective.record(request, now)
+			}
+		}
+		objective.breached = objective.status(now).Breached
+	}
+	for _, alert := range m.burnRates {
+		alert.providers = make(map[Provider][]*burnRateWindow)
+		for _, request := range requests {
+			if alert.alert.matches(request) {
+				alert.record(request, now)
+			}
+		}
+		for provider, windows := range alert.providers {
+			for _, w := range windows {
+				w.breached = alert.status(provider, w, now).Breached
+			}
+		}
+	}
+	return nil
+}
+
+// Status returns the current compliance of every objective, in the order they were added
+func (m *SLOMonitor) Status() []SLOStatus {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]SLOStatus, 0, len(m.objectives))
+	for _, objective := range m.objectives {
+		statuses = append(statuses, objective.status(now))
+	}
+	return statuses
+}
+
+// observe counts a tracked request against the objectives covering it
+func (m *SLOMonitor) observe(request *Request) {
+	now := m.now()
+	var events []SLOEvent
+
+	m.mu.Lock()
+	for _, objective := range m.objectives {
+		if !objective.slo.matches(request) {
+			continue
+		}
+		objective.record(request, now)
+		status := objective.status(now)
+		if status.Breached == objective.breached {
+			continue
+		}
+		objective.breached = status.Breached
+		event := SLOEvent{Type: SLOEventBreach, Status: &status, At: now}
+		if !status.Breached {
+			event.Type = SLOEventRecovered
+		}
+		events = append(events, event)
+	}
+	for _, alert := range m.burnRates {
+		if alert.alert.matches(request) {
+			events = append(events, alert.observe(request, now)...)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, event := range events {
+		m.client.logger.Warn("SLO "+string(event.Type), slog.String("slo", event.name()))
+		for _, hook := range m.hooks {
+			hook(event)
+		}
+	}
+}
+
+// record counts a request, which is good when within the threshold
+func (o *sloObjective) record(request *Request, now time.Time) {
+	o.window.add(request.RequestedAt, now, request.Latency <= o.slo.Threshold)
+}
+
+// status returns the compliance over the current window
+func (o *sloObjective) status(now time.Time) SLOStatus {
+	status := SLOStatus{SLO: o.slo, Compliance: 1}
+	status.Requests, status.Good = o.window.counts(now)
+	if status.Requests > 0 {
+		status.Compliance = float64(status.Good) / float64(status.Requests)
+	}
+	status.Breached = status.Requests >= o.slo.MinRequests && status.Compliance < o.slo.Target
+	return status
+}
+
+// postWebhook delivers an event to a webhook
+func (m *SLOMonitor) postWebhook(url string, event SLOEvent) {
+	err := func() error {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), sloWebhookTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := m.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+		}
+		return nil
+	}()
+	if err != nil {
+		m.client.logger.Error("Failed to deliver SLO event",
+			slog.String("slo", event.name()),
+			slog.Any("error", err),
+		)
+	}
+}
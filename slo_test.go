@@ -0,0 +1,111 @@
+package llmtracer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLOMonitor(t *testing.T) {
+	webhook := make(chan SLOEvent, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event SLOEvent
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		webhook <- event
+	}))
+	defer server.Close()
+
+	client := NewClient(&MockStorageAdapter{})
+	var events []SLOEvent
+	monitor := NewSLOMonitor(client,
+		WithLatencySLO(LatencySLO{Name: "search", Model: "gpt-4o", Feature: "search", Threshold: time.Second, Target: 0.9, MinRequests: 5}),
+		WithLatencySLO(LatencySLO{Name: "all", Threshold: time.Minute, Target: 0.5}),
+		WithSLOHook(func(event SLOEvent) { events = append(events, event) }),
+		WithSLOWebhook(server.URL),
+	)
+
+	ctx := WithFeature(context.Background(), "search")
+	track := func(ctx context.Context, model string, latency time.Duration) {
+		require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, model, 10, 10, latency, nil, nil))
+	}
+
+	// Too few requests to judge, then below the 90% target
+	track(ctx, "gpt-4o", 2*time.Second)
+	assert.Empty(t, events)
+	for i := 0; i < 4; i++ {
+		track(ctx, "gpt-4o", 100*time.Millisecond)
+	}
+	require.Len(t, events, 1)
+	assert.Equal(t, SLOEventBreach, events[0].Type)
+	assert.Equal(t, "search", events[0].Status.SLO.Name)
+	assert.Equal(t, int64(5), events[0].Status.Requests)
+	assert.InDelta(t, 0.8, events[0].Status.Compliance, 1e-9)
+
+	select {
+	case event := <-webhook:
+		assert.Equal(t, SLOEventBreach, event.Type)
+		assert.Equal(t, "search", event.Status.SLO.Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+
+	// Requests of other models and features are not covered
+	track(ctx, "gpt-4o-mini", 5*time.Second)
+	track(context.Background(), "gpt-4o", 5*time.Second)
+	require.Len(t, events, 1)
+
+	for i := 0; i < 5; i++ {
+		track(ctx, "gpt-4o", 100*time.Millisecond)
+	}
+	require.Len(t, events, 2)
+	assert.Equal(t, SLOEventRecovered, events[1].Type)
+
+	statuses := monitor.Status()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, int64(10), statuses[0].Requests)
+	assert.Equal(t, int64(9), statuses[0].Good)
+	assert.False(t, statuses[0].Breached)
+	assert.Equal(t, "all", statuses[1].SLO.Name)
+	assert.Equal(t, time.Hour, statuses[1].SLO.Window)
+	assert.Equal(t, int64(12), statuses[1].Requests)
+
+	// Requests leave the window
+	monitor.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	statuses = monitor.Status()
+	assert.Equal(t, int64(0), statuses[0].Requests)
+	assert.Equal(t, 1.0, statuses[0].Compliance)
+}
+
+func TestSLOMonitorLoad(t *testing.T) {
+	now := time.Now()
+	var gotFilter *RequestFilter
+	client := NewClient(&MockStorageAdapter{
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			gotFilter = filter
+			var requests []*Request
+			for i := 0; i < 10; i++ {
+				requests = append(requests, &Request{Model: "gpt-4o", Latency: 3 * time.Second, RequestedAt: now.Add(-time.Duration(i) * time.Minute)})
+			}
+			// Outside the window
+			requests = append(requests, &Request{Model: "gpt-4o", RequestedAt: now.Add(-2 * time.Hour)})
+			return requests, nil
+		},
+	})
+	monitor := NewSLOMonitor(client, WithLatencySLO(LatencySLO{Name: "chat", Threshold: time.Second, Target: 0.99, Window: 30 * time.Minute}))
+	monitor.now = func() time.Time { return now }
+
+	require.NoError(t, monitor.Load(context.Background()))
+	require.NotNil(t, gotFilter.StartTime)
+	assert.Equal(t, now.Add(-30*time.Minute), *gotFilter.StartTime)
+
+	status := monitor.Status()[0]
+	assert.Equal(t, int64(10), status.Requests)
+	assert.Equal(t, int64(0), status.Good)
+	assert.True(t, status.Breached)
+}
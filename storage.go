@@ -2,14 +2,76 @@ package llmtracer
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrAggregateNotSupported is returned by Aggregate from adapters that cannot aggregate
+// natively. Callers such as Client.GetTokenStats then fall back to aggregating Query results.
+var ErrAggregateNotSupported = errors.New("aggregate is not supported by this storage adapter")
+
+// ErrQueryNotSupported is returned by reads from write-only adapters, whose capabilities
+// report Query as false
+var ErrQueryNotSupported = errors.New("query is not supported by this storage adapter")
+
+// ErrSearchNotSupported is returned by reads with RequestFilter.SearchText from storage
+// that cannot search the stored text, such as storage wrapped by WithEncryption, whose
+// errors and payloads are stored as ciphertext
+var ErrSearchNotSupported = errors.New("text search is not supported by this storage adapter")
+
+// StorageCapabilities describes what a storage adapter supports beyond saving requests, so
+// callers can fall back instead of failing
+type StorageCapabilities struct {
+	// Query is false for write-only adapters, e.g. ones that forward requests to a log pipeline
+	Query bool `json:"query"`
+	// Aggregate is true when Aggregate is computed by the backend; otherwise callers
+	// aggregate Query results in memory
+	Aggregate bool `json:"aggregate"`
+	// Watch is true when the adapter implements WatchableStorage
+	Watch bool `json:"watch"`
+	// SoftDelete is true when the adapter implements SoftDeleteStorage
+	SoftDelete bool `json:"soft_delete"`
+	// Feedback is true when the adapter implements FeedbackStorage
+	Feedback bool `json:"feedback"`
+	// Incidents is true when the adapter implements IncidentStorage
+	Incidents bool `json:"incidents"`
+	// Lifecycle is true when the adapter implements LifecycleStorage
+	Lifecycle bool `json:"lifecycle"`
+	// SavedFilters is true when the adapter implements SavedFilterStorage
+	SavedFilters bool `json:"saved_filters"`
+	// Rollups is true when the adapter implements RollupStorage
+	Rollups bool `json:"rollups"`
+}
+
+// SoftDeleteStorage is implemented by adapters whose Delete and DeleteOlderThan soft delete
+// requests. Soft-deleted requests are excluded from reads unless RequestFilter.IncludeDeleted
+// is set, and can be restored until they are purged.
+type SoftDeleteStorage interface {
+	StorageAdapter
+
+	// Restore undoes the soft delete of a request
+	Restore(ctx context.Context, id string) error
+
+	// PurgeDeleted permanently removes requests soft deleted before the given time
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+// LifecycleStorage is implemented by adapters that can update stored requests, which
+// WithLifecycleTracking needs to complete the pending request written when a call starts
+type LifecycleStorage interface {
+	StorageAdapter
+
+	// Update replaces the stored request with the same ID, saving it when there is none
+	Update(ctx context.Context, request *Request) error
+}
+
 type StorageAdapter interface {
 	Save(ctx context.Context, request *Request) error
 
 	Get(ctx context.Context, id string) (*Request, error)
 
+	// GetByTraceID returns the requests of a trace. Adapters normalize traceID with
+	// NormalizeTraceID, as trace IDs are stored normalized.
 	GetByTraceID(ctx context.Context, traceID string) ([]*Request, error)
 
 	Query(ctx context.Context, filter *RequestFilter) ([]*Request, error)
@@ -20,5 +82,104 @@ type StorageAdapter interface {
 
 	DeleteOlderThan(ctx context.Context, before time.Time) (int64, error)
 
+	// Capabilities reports the optional operations the adapter supports
+	Capabilities() StorageCapabilities
+
+	// Ping verifies that the backend is reachable
+	Ping(ctx context.Context) error
+
 	Close() error
 }
+
+// softDeleteWrapper restores the soft delete capability of an adapter hidden by a
+// wrapping adapter
+type softDeleteWrapper struct {
+	StorageAdapter
+	softDelete SoftDeleteStorage
+}
+
+func (w *softDeleteWrapper) Restore(ctx context.Context, id string) error {
+	return w.softDelete.Restore(ctx, id)
+}
+
+func (w *softDeleteWrapper) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	return w.softDelete.PurgeDeleted(ctx, before)
+}
+
+func (w *softDeleteWrapper) AggregateMulti(ctx context.Context, specs []AggregateSpec) ([][]*AggregateResult, error) {
+	return aggregateMulti(ctx, w.StorageAdapter, specs)
+}
+
+func (w *softDeleteWrapper) Update(ctx context.Context, request *Request) error {
+	return updateRequest(ctx, w.StorageAdapter, request)
+}
+
+func (w *softDeleteWrapper) unwrap() StorageAdapter {
+	return w.StorageAdapter
+}
+
+// preserveSoftDelete returns wrapper, implementing SoftDeleteStorage when the wrapped
+// adapter does
+func preserveSoftDelete(wrapper, wrapped StorageAdapter) StorageAdapter {
+	if softDelete, ok := wrapped.(SoftDeleteStorage); ok {
+		return &softDeleteWrapper{StorageAdapter: wrapper, softDelete: softDelete}
+	}
+	return wrapper
+}
+
+// wrappedCapabilities returns the capabilities of a wrapped adapter as seen through a
+// wrapper, which does not forward the change feed
+func wrappedCapabilities(wrapped StorageAdapter) StorageCapabilities {
+	capabilities := wrapped.Capabilities()
+	capabilities.Watch = false
+	return capabilities
+}
+
+// updateRequest updates a request in storage, failing with ErrLifecycleNotSupported unless
+// it implements LifecycleStorage. Wrappers transforming requests forward updates with it,
+// so they are not unwrapped like other optional interfaces.
+func updateRequest(ctx context.Context, storage StorageAdapter, request *Request) error {
+	lifecycle, ok := storage.(LifecycleStorage)
+	if !ok {
+		return ErrLifecycleNotSupported
+	}
+	return lifecycle.Update(ctx, request)
+}
+
+// portableCopy copies a request to save in another adapter, without the database IDs of
+// its dimensions
+func portableCopy(request *Request) *Request {
+	copied := *request
+	copied.pendingWrite = nil
+	if request.Dimensions != nil {
+		copied.Dimensions = make([]DimensionTag, len(request.Dimensions))
+		for i, dim := range request.Dimensions {
+			copied.Dimensions[i] = DimensionTag{Key: dim.Key, Value: dim.Value}
+		}
+	}
+	return &copied
+}
+
+// storageWrapper is implemented by adapters that wrap another adapter, such as encrypted
+// storage
+type storageWrapper interface {
+	unwrap() StorageAdapter
+}
+
+// storageCapability finds an optional interface on storage or on the adapters it wraps.
+// Wrappers that transform or restrict data, such as encrypted and scoped storage,
+// implement the interfaces whose data they apply to, so they are not skipped.
+func storageCapability[T any](storage StorageAdapter) (T, bool) {
+	for storage != nil {
+		if capability, ok := storage.(T); ok {
+			return capability, true
+		}
+		wrapper, ok := storage.(storageWrapper)
+		if !ok {
+			break
+		}
+		storage = wrapper.unwrap()
+	}
+	var zero T
+	return zero, false
+}
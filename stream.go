@@ -0,0 +1,195 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAICreateChatCompletionStreamFunc represents the signature of OpenAI's CreateChatCompletionStream method
+type OpenAICreateChatCompletionStreamFunc func(ctx context.Context, request openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error)
+
+// TraceOpenAIStream wraps OpenAI's CreateChatCompletionStream. It sets
+// stream_options.include_usage so OpenAI sends a final usage chunk, and tracks the request
+// once the stream reaches io.EOF, fails, or is closed. When no usage chunk arrives (e.g.
+// from OpenAI-compatible servers that ignore the option) token counts are estimated from
+// the message and completion text and the usage_estimated dimension is set.
+//
+// Streams closed before the generation finished, or whose context is canceled, are tracked
+// as aborted (ErrStreamAborted, status 499) with the tokens streamed so far.
+func (c *Client) TraceOpenAIStream(ctx context.Context, request openai.ChatCompletionRequest, createStream OpenAICreateChatCompletionStreamFunc) (*TrackedChatCompletionStream, error) {
+	if createStream == nil {
+		return nil, fmt.Errorf("createStream function cannot be nil")
+	}
+	if err := c.admit(ctx, ProviderOpenAI, request.Model); err != nil {
+		return nil, err
+	}
+	release, queueWait, err := c.acquire(ctx, ProviderOpenAI, request.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.StreamOptions == nil {
+		request.StreamOptions = &openai.StreamOptions{}
+	}
+	request.StreamOptions.IncludeUsage = true
+
+	tracked := &TrackedChatCompletionStream{
+		client:    c,
+		ctx:       ctx,
+		request:   request,
+		release:   release,
+		startTime: time.Now(),
+		tracked: c.startRequest(ctx, &Request{
+			Provider:     ProviderOpenAI,
+			Model:        request.Model,
+			MessageCount: len(request.Messages),
+			QueueWait:    queueWait,
+		}),
+	}
+	if c.captureGenerationParams {
+		tracked.tracked.Params = openAIGenerationParams(request)
+	}
+	if c.capturePayloadSizes {
+		tracked.tracked.RequestBytes = jsonSize(request)
+	}
+	if c.capturePayloads {
+		tracked.tracked.RequestPayload = jsonString(request)
+	}
+
+	callCtx, headers := withResponseHeaders(ctx)
+	stream, err := createStream(callCtx, request)
+	if err != nil {
+		// go-openai's errors have no headers; they are only seen through a
+		// ResponseHeaderTransport
+		tracked.rateLimits = c.recordRateLimitHeaders(ProviderOpenAI, headers.get())
+		tracked.finish(err)
+		return nil, err
+	}
+	tracked.stream = stream
+	if header := stream.Header(); header != nil {
+		tracked.tracked.ProviderRequestID = header.Get("X-Request-Id")
+		tracked.rateLimits = c.recordRateLimitHeaders(ProviderOpenAI, header)
+	}
+
+	return tracked, nil
+}
+
+// TrackedChatCompletionStream wraps an OpenAI chat completion stream and tracks its usage
+// when the stream ends
+type TrackedChatCompletionStream struct {
+	stream  *openai.ChatCompletionStream
+	client  *Client
+	ctx     context.Context
+	request openai.ChatCompletionRequest
+	tracked *Request
+	// release frees the concurrency slot held by the stream
+	release   func()
+	startTime time.Time
+
+	rateLimits *ProviderRateLimitStatus
+
+	// mu guards the state gathered by Recv, which Close may read from another goroutine
+	mu            sync.Mutex
+	usage         *openai.Usage
+	completionLen int
+	responseBytes int
+	// finished is set once a choice reports a finish reason
+	finished bool
+	// once tracks the stream exactly once, whether Recv or Close ends it
+	once sync.Once
+}
+
+// Recv returns the next chunk, tracking the request when the stream ends. io.EOF is the
+// normal end of the stream and is tracked as a success.
+func (s *TrackedChatCompletionStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	response, err := s.stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			s.finish(nil)
+		} else {
+			s.finish(abortError(s.ctx, err))
+		}
+		return response, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if response.Usage != nil {
+		s.usage = response.Usage
+	}
+	for _, choice := range response.Choices {
+		s.completionLen += len(choice.Delta.Content)
+		if choice.FinishReason != "" {
+			s.finished = true
+			s.tracked.FinishReason = normalizeFinishReason(string(choice.FinishReason))
+		}
+	}
+	if s.client.capturePayloadSizes {
+		s.responseBytes += jsonSize(response)
+	}
+
+	return response, nil
+}
+
+// Close closes the underlying stream, tracking the request if it has not been tracked yet.
+// Closing before the generation finished tracks the stream as aborted.
+func (s *TrackedChatCompletionStream) Close() error {
+	s.mu.Lock()
+	finished := s.finished
+	s.mu.Unlock()
+	if finished {
+		s.finish(nil)
+	} else {
+		s.finish(fmt.Errorf("%w: closed before the generation finished", ErrStreamAborted))
+	}
+	return s.stream.Close()
+}
+
+// finish tracks the stream exactly once. The stream's context may already be canceled, e.g.
+// when the caller gave up on the stream, so tracking keeps its values but not its
+// cancellation; otherwise storage would refuse to save the aborted request.
+func (s *TrackedChatCompletionStream) finish(err error) {
+	s.once.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.release()
+		tracked := s.tracked
+		tracked.RequestedAt = s.startTime
+		tracked.Latency = time.Since(s.startTime)
+		tracked.ResponseBytes = s.responseBytes
+		applyOpenAIMetadata(tracked, openai.ChatCompletionResponse{}, err)
+
+		trackingContext := GetDimensionsFromContext(s.ctx)
+		switch {
+		case s.usage != nil:
+			tracked.SetUsage(OpenAIUsage(*s.usage))
+		case err == nil || errors.Is(err, ErrStreamAborted):
+			tracked.InputTokens = estimateMessageTokens(s.request.Messages)
+			tracked.OutputTokens = estimateTokens(s.completionLen)
+			trackingContext[DimensionUsageEstimated] = true
+		}
+		s.rateLimits.apply(tracked)
+
+		s.client.track(context.WithoutCancel(s.ctx), tracked, err, trackingContext)
+	})
+}
+
+// estimateMessageTokens approximates the token count of the text in chat messages
+func estimateMessageTokens(messages []openai.ChatCompletionMessage) int64 {
+	textLen := 0
+	for _, message := range messages {
+		textLen += len(message.Content)
+		for _, part := range message.MultiContent {
+			textLen += len(part.Text)
+		}
+	}
+	return estimateTokens(textLen)
+}
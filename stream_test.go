@@ -0,0 +1,178 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStreamServer returns an OpenAI client whose chat completion stream sends the given
+// chunks, recording whether the request asked for usage
+func newStreamServer(t *testing.T, chunks []string, includeUsage *bool) *openai.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		*includeUsage = body.StreamOptions != nil && body.StreamOptions.IncludeUsage
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("X-Request-Id", "req_stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+
+	config := openai.DefaultConfig("test-key")
+	config.BaseURL = server.URL + "/v1"
+	return openai.NewClientWithConfig(config)
+}
+
+func drain(t *testing.T, stream *TrackedChatCompletionStream) string {
+	t.Helper()
+	var content string
+	for {
+		response, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return content
+		}
+		require.NoError(t, err)
+		for _, choice := range response.Choices {
+			content += choice.Delta.Content
+		}
+	}
+}
+
+func TestTraceOpenAIStream(t *testing.T) {
+	request := openai.ChatCompletionRequest{
+		Model: "gpt-4o",
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: "Say hello to everyone"},
+		},
+	}
+
+	t.Run("captures the usage chunk", func(t *testing.T) {
+		var includeUsage bool
+		openaiClient := newStreamServer(t, []string{
+			`{"choices":[{"index":0,"delta":{"content":"Hello"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":" world"}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":2,"total_tokens":14}}`,
+		}, &includeUsage)
+
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		stream, err := client.TraceOpenAIStream(context.Background(), request, openaiClient.CreateChatCompletionStream)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello world", drain(t, stream))
+		assert.True(t, includeUsage)
+
+		// Tracked on EOF; Close does not track again
+		require.Len(t, storage.SaveCalls, 1)
+		require.NoError(t, stream.Close())
+		require.Len(t, storage.SaveCalls, 1)
+
+		tracked := storage.SaveCalls[0].Request
+		assert.Equal(t, int64(12), tracked.InputTokens)
+		assert.Equal(t, int64(2), tracked.OutputTokens)
+		assert.Equal(t, 200, tracked.StatusCode)
+		assert.Equal(t, "req_stream", tracked.ProviderRequestID)
+		assert.Empty(t, tracked.Error)
+		assert.Empty(t, tracked.Dimension(DimensionUsageEstimated))
+	})
+
+	t.Run("estimates tokens without a usage chunk", func(t *testing.T) {
+		var includeUsage bool
+		openaiClient := newStreamServer(t, []string{
+			`{"choices":[{"index":0,"delta":{"content":"Hello there, everyone"}}]}`,
+		}, &includeUsage)
+
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		stream, err := client.TraceOpenAIStream(context.Background(), request, openaiClient.CreateChatCompletionStream)
+		require.NoError(t, err)
+		drain(t, stream)
+
+		require.Len(t, storage.SaveCalls, 1)
+		tracked := storage.SaveCalls[0].Request
+		assert.Equal(t, int64(6), tracked.InputTokens)
+		assert.Equal(t, int64(6), tracked.OutputTokens)
+		assert.Equal(t, "true", tracked.Dimension(DimensionUsageEstimated))
+	})
+
+	t.Run("tracks streams closed mid-generation as aborted", func(t *testing.T) {
+		var includeUsage bool
+		openaiClient := newStreamServer(t, []string{
+			`{"choices":[{"index":0,"delta":{"content":"Hello there"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":", everyone"}}]}`,
+		}, &includeUsage)
+
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		stream, err := client.TraceOpenAIStream(context.Background(), request, openaiClient.CreateChatCompletionStream)
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		require.NoError(t, stream.Close())
+
+		require.Len(t, storage.SaveCalls, 1)
+		tracked := storage.SaveCalls[0].Request
+		assert.Equal(t, StatusClientClosedRequest, tracked.StatusCode)
+		assert.Equal(t, ErrorTypeAborted, tracked.ErrorType)
+		assert.Contains(t, tracked.Error, ErrStreamAborted.Error())
+		assert.Equal(t, int64(3), tracked.OutputTokens, "partial output is estimated from the text received")
+		assert.Equal(t, int64(6), tracked.InputTokens)
+		assert.Equal(t, "true", tracked.Dimension(DimensionUsageEstimated))
+		assert.Positive(t, tracked.Latency)
+	})
+
+	t.Run("closing after the finish reason is not an abort", func(t *testing.T) {
+		var includeUsage bool
+		openaiClient := newStreamServer(t, []string{
+			`{"choices":[{"index":0,"delta":{"content":"Hello"},"finish_reason":"stop"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":1,"total_tokens":13}}`,
+		}, &includeUsage)
+
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		stream, err := client.TraceOpenAIStream(context.Background(), request, openaiClient.CreateChatCompletionStream)
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		require.NoError(t, stream.Close())
+
+		require.Len(t, storage.SaveCalls, 1)
+		assert.Equal(t, 200, storage.SaveCalls[0].Request.StatusCode)
+		assert.Empty(t, storage.SaveCalls[0].Request.ErrorType)
+	})
+
+	t.Run("tracks stream creation errors", func(t *testing.T) {
+		storage := &MockStorageAdapter{}
+		client := NewClient(storage)
+
+		createStream := func(ctx context.Context, request openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error) {
+			return nil, &openai.APIError{HTTPStatusCode: 429, Message: "rate limit exceeded"}
+		}
+		_, err := client.TraceOpenAIStream(context.Background(), request, createStream)
+		assert.Error(t, err)
+
+		require.Len(t, storage.SaveCalls, 1)
+		assert.Equal(t, 429, storage.SaveCalls[0].Request.StatusCode)
+		assert.Equal(t, ErrorTypeRateLimit, storage.SaveCalls[0].Request.ErrorType)
+	})
+}
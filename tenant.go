@@ -0,0 +1,377 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidTenant is returned for tenant IDs that are not 1 to 63 letters, digits,
+	// underscores or hyphens
+	ErrInvalidTenant = errors.New("invalid tenant ID")
+
+	errSoftDeleteNotSupported = errors.New("soft delete is not supported by this storage adapter")
+	errTenantStorageClosed    = errors.New("tenant storage is closed")
+)
+
+// tenantPattern keeps tenant IDs safe to use in schema names, table prefixes, DSNs and
+// file paths
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}$`)
+
+const tenantKey contextKey = "llm_tenant"
+
+// WithTenant returns a context whose storage calls through a TenantStorage go to tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// GetTenantFromContext returns the tenant of a context, or "" when it has none
+func GetTenantFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenant, _ := ctx.Value(tenantKey).(string)
+	return tenant
+}
+
+// TenantOpener opens the storage of a tenant, e.g. a GormAdapter on a Postgres schema or
+// a SQLite file of its own. The tenant ID has been validated before it is called.
+type TenantOpener func(tenant string) (StorageAdapter, error)
+
+// TenantStorage routes each storage call to the adapter of the tenant in its context (see
+// WithTenant), so one tracer can serve several products whose requests never share a
+// table. Adapters are opened on first use and kept open until Close.
+//
+// Calls without a tenant go to the default tenant, except DeleteOlderThan and
+// PurgeDeleted, which apply to every open tenant so that WithRetention covers them all.
+// Background work started by the client without a caller context, such as the
+// WithLifecycleTracking watchdog, only sees the default tenant.
+type TenantStorage struct {
+	open          TenantOpener
+	defaultTenant string
+
+	mu       sync.Mutex
+	adapters map[string]StorageAdapter
+	closed   bool
+}
+
+// NewTenantStorage creates a TenantStorage opening tenant adapters with open. The default
+// tenant is opened right away, and its adapter's capabilities are reported for all tenants.
+func NewTenantStorage(open TenantOpener, defaultTenant string) (*TenantStorage, error) {
+	if open == nil {
+		return nil, fmt.Errorf("tenant opener cannot be nil")
+	}
+	t := &TenantStorage{
+		open:          open,
+		defaultTenant: defaultTenant,
+		adapters:      make(map[string]StorageAdapter),
+	}
+	if _, err := t.Tenant(defaultTenant); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Tenant returns the adapter of tenant, opening it on first use. Opening tenants ahead of
+// time lets retention cover them before they are used.
+func (t *TenantStorage) Tenant(tenant string) (StorageAdapter, error) {
+	if !tenantPattern.MatchString(tenant) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTenant, tenant)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, errTenantStorageClosed
+	}
+	if adapter, ok := t.adapters[tenant]; ok {
+		return adapter, nil
+	}
+	adapter, err := t.open(tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage for tenant %s: %w", tenant, err)
+	}
+	t.adapters[tenant] = adapter
+	return adapter, nil
+}
+
+// Tenants returns the IDs of the open tenants, sorted
+func (t *TenantStorage) Tenants() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tenants := make([]string, 0, len(t.adapters))
+	for tenant := range t.adapters {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// adapter returns the adapter of the tenant in ctx
+func (t *TenantStorage) adapter(ctx context.Context) (StorageAdapter, error) {
+	tenant := GetTenantFromContext(ctx)
+	if tenant == "" {
+		tenant = t.defaultTenant
+	}
+	return t.Tenant(tenant)
+}
+
+// each calls fn with the adapter of the tenant in ctx, or with every open adapter when
+// ctx has no tenant
+func (t *TenantStorage) each(ctx context.Context, fn func(StorageAdapter) error) error {
+	if GetTenantFromContext(ctx) != "" {
+		adapter, err := t.adapter(ctx)
+		if err != nil {
+			return err
+		}
+		return fn(adapter)
+	}
+
+	var errs []error
+	for _, tenant := range t.Tenants() {
+		adapter, err := t.Tenant(tenant)
+		if err == nil {
+			err = fn(adapter)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenant, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *TenantStorage) Save(ctx context.Context, request *Request) error {
+	adapter, err := t.adapter(ctx)
+	if err != nil {
+		return err
+	}
+	return adapter.Save(ctx, request)
+}
+
+func (t *TenantStorage) Get(ctx context.Context, id string) (*Request, error) {
+	adapter, err := t.adapter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.Get(ctx, id)
+}
+
+func (t *TenantStorage) GetByTraceID(ctx context.Context, traceID string) ([]*Request, error) {
+	adapter, err := t.adapter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.GetByTraceID(ctx, traceID)
+}
+
+func (t *TenantStorage) Query(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+	adapter, err := t.adapter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.Query(ctx, filter)
+}
+
+func (t *TenantStorage) Aggregate(ctx context.Context, groupBy []string, filter *RequestFilter) ([]*AggregateResult, error) {
+	adapter, err := t.adapter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.Aggregate(ctx, groupBy, filter)
+}
+
+func (t *TenantStorage) AggregateMulti(ctx context.Context, specs []AggregateSpec) ([][]*AggregateResult, error) {
+	adapter, err := t.adapter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return aggregateMulti(ctx, adapter, specs)
+}
+
+func (t *TenantStorage) Delete(ctx context.Context, id string) error {
+	adapter, err := t.adapter(ctx)
+	if err != nil {
+		return err
+	}
+	return adapter.Delete(ctx, id)
+}
+
+func (t *TenantStorage) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	var total int64
+	err := t.each(ctx, func(adapter StorageAdapter) error {
+		deleted, err := adapter.DeleteOlderThan(ctx, before)
+		total += deleted
+		return err
+	})
+	return total, err
+}
+
+func (t *TenantStorage) Restore(ctx context.Context, id string) error {
+	adapter, err := t.adapter(ctx)
+	if err != nil {
+		return err
+	}
+	softDelete, ok := adapter.(SoftDeleteStorage)
+	if !ok {
+		return errSoftDeleteNotSupported
+	}
+	return softDelete.Restore(ctx, id)
+}
+
+// PurgeDeleted purges the tenants whose adapters implement SoftDeleteStorage
+func (t *TenantStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var total int64
+	err := t.each(ctx, func(adapter StorageAdapter) error {
+		softDelete, ok := adapter.(SoftDeleteStorage)
+		if !ok {
+			return nil
+		}
+		purged, err := softDelete.PurgeDeleted(ctx, before)
+		total += purged
+		return err
+	})
+	return total, err
+}
+
+func (t *TenantStorage) Update(ctx context.Context, request *Request) error {
+	adapter, err := t.adapter(ctx)
+	if err != nil {
+		return err
+	}
+	return updateRequest(ctx, adapter, request)
+}
+
+func (t *TenantStorage) SaveFeedback(ctx context.Context, feedback *Feedback) error {
+	storage, err := tenantCapability[FeedbackStorage](ctx, t, ErrFeedbackNotSupported)
+	if err != nil {
+		return err
+	}
+	return storage.SaveFeedback(ctx, feedback)
+}
+
+func (t *TenantStorage) QueryFeedback(ctx context.Context, filter *FeedbackFilter) ([]*Feedback, error) {
+	storage, err := tenantCapability[FeedbackStorage](ctx, t, ErrFeedbackNotSupported)
+	if err != nil {
+		return nil, err
+	}
+	return storage.QueryFeedback(ctx, filter)
+}
+
+func (t *TenantStorage) SaveIncident(ctx context.Context, incident *ProviderIncident) error {
+	storage, err := tenantCapability[IncidentStorage](ctx, t, ErrIncidentsNotSupported)
+	if err != nil {
+		return err
+	}
+	return storage.SaveIncident(ctx, incident)
+}
+
+func (t *TenantStorage) QueryIncidents(ctx context.Context, filter *IncidentFilter) ([]*ProviderIncident, error) {
+	storage, err := tenantCapability[IncidentStorage](ctx, t, ErrIncidentsNotSupported)
+	if err != nil {
+		return nil, err
+	}
+	return storage.QueryIncidents(ctx, filter)
+}
+
+func (t *TenantStorage) SaveFilter(ctx context.Context, filter *SavedFilter) error {
+	storage, err := tenantCapability[SavedFilterStorage](ctx, t, ErrSavedFiltersNotSupported)
+	if err != nil {
+		return err
+	}
+	return storage.SaveFilter(ctx, filter)
+}
+
+func (t *TenantStorage) GetFilter(ctx context.Context, name string) (*SavedFilter, error) {
+	storage, err := tenantCapability[SavedFilterStorage](ctx, t, ErrSavedFiltersNotSupported)
+	if err != nil {
+		return nil, err
+	}
+	return storage.GetFilter(ctx, name)
+}
+
+func (t *TenantStorage) ListFilters(ctx context.Context) ([]*SavedFilter, error) {
+	storage, err := tenantCapability[SavedFilterStorage](ctx, t, ErrSavedFiltersNotSupported)
+	if err != nil {
+		return nil, err
+	}
+	return storage.ListFilters(ctx)
+}
+
+func (t *TenantStorage) DeleteFilter(ctx context.Context, name string) error {
+	storage, err := tenantCapability[SavedFilterStorage](ctx, t, ErrSavedFiltersNotSupported)
+	if err != nil {
+		return err
+	}
+	return storage.DeleteFilter(ctx, name)
+}
+
+func (t *TenantStorage) CompactRequests(ctx context.Context, rollups []*RequestRollup, requestIDs []string) error {
+	storage, err := tenantCapability[RollupStorage](ctx, t, ErrRollupsNotSupported)
+	if err != nil {
+		return err
+	}
+	return storage.CompactRequests(ctx, rollups, requestIDs)
+}
+
+func (t *TenantStorage) QueryRollups(ctx context.Context, filter *RequestFilter) ([]*RequestRollup, error) {
+	storage, err := tenantCapability[RollupStorage](ctx, t, ErrRollupsNotSupported)
+	if err != nil {
+		return nil, err
+	}
+	return storage.QueryRollups(ctx, filter)
+}
+
+// tenantCapability finds an optional interface on the adapter of the tenant in ctx,
+// failing with unsupported when it does not implement it
+func tenantCapability[T any](ctx context.Context, t *TenantStorage, unsupported error) (T, error) {
+	var zero T
+	adapter, err := t.adapter(ctx)
+	if err != nil {
+		return zero, err
+	}
+	capability, ok := storageCapability[T](adapter)
+	if !ok {
+		return zero, unsupported
+	}
+	return capability, nil
+}
+
+// Capabilities reports the capabilities of the default tenant's adapter. Changes are not
+// watched across tenants.
+func (t *TenantStorage) Capabilities() StorageCapabilities {
+	adapter, err := t.Tenant(t.defaultTenant)
+	if err != nil {
+		return StorageCapabilities{}
+	}
+	return wrappedCapabilities(adapter)
+}
+
+// Ping pings every open tenant
+func (t *TenantStorage) Ping(ctx context.Context) error {
+	return t.each(WithTenant(ctx, ""), func(adapter StorageAdapter) error {
+		return adapter.Ping(ctx)
+	})
+}
+
+// Close closes every open tenant
+func (t *TenantStorage) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+
+	var errs []error
+	for tenant, adapter := range t.adapters {
+		if err := adapter.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenant, err))
+		}
+	}
+	return errors.Join(errs...)
+}
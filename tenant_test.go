@@ -0,0 +1,177 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantStores opens an in-memory mock per tenant
+type tenantStores struct {
+	mu     sync.Mutex
+	saved  map[string][]*Request
+	opened []string
+	closed []string
+}
+
+func newTenantStores() *tenantStores {
+	return &tenantStores{saved: make(map[string][]*Request)}
+}
+
+func (s *tenantStores) requests(tenant string) []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.saved[tenant]...)
+}
+
+func (s *tenantStores) open(tenant string) (StorageAdapter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opened = append(s.opened, tenant)
+	return &MockStorageAdapter{
+		SaveFunc: func(ctx context.Context, request *Request) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.saved[tenant] = append(s.saved[tenant], request)
+			return nil
+		},
+		QueryFunc: func(ctx context.Context, filter *RequestFilter) ([]*Request, error) {
+			return s.requests(tenant), nil
+		},
+		DeleteOlderThanFunc: func(ctx context.Context, before time.Time) (int64, error) {
+			return int64(len(s.requests(tenant))), nil
+		},
+		CloseFunc: func() error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.closed = append(s.closed, tenant)
+			return nil
+		},
+	}, nil
+}
+
+func TestTenantStorageIsolatesTenants(t *testing.T) {
+	stores := newTenantStores()
+	storage, err := NewTenantStorage(stores.open, "default")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default"}, stores.opened, "the default tenant is opened eagerly")
+
+	client := NewClient(storage)
+	acme := WithTenant(context.Background(), "acme")
+	globex := WithTenant(context.Background(), "globex")
+
+	client.TrackRequest(acme, ProviderOpenAI, "gpt-4o", 10, 5, time.Second, nil, nil)
+	client.TrackRequest(acme, ProviderOpenAI, "gpt-4o", 10, 5, time.Second, nil, nil)
+	client.TrackRequest(globex, ProviderAnthropic, "claude-sonnet-4", 10, 5, time.Second, nil, nil)
+	client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o-mini", 10, 5, time.Second, nil, nil)
+
+	assert.Len(t, stores.requests("acme"), 2)
+	assert.Len(t, stores.requests("globex"), 1)
+	assert.Len(t, stores.requests("default"), 1)
+
+	requests, err := storage.Query(globex, &RequestFilter{})
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "claude-sonnet-4", requests[0].Model)
+
+	assert.Equal(t, []string{"acme", "default", "globex"}, storage.Tenants())
+	require.NoError(t, client.Shutdown(context.Background()))
+	assert.ElementsMatch(t, []string{"acme", "default", "globex"}, stores.closed)
+	assert.Equal(t, 3, len(stores.opened), "each tenant is opened once")
+}
+
+func TestTenantStorageAsyncTrackingKeepsTenant(t *testing.T) {
+	stores := newTenantStores()
+	storage, err := NewTenantStorage(stores.open, "default")
+	require.NoError(t, err)
+
+	client := NewClient(storage, WithAsyncTracking(true))
+	ctx, cancel := context.WithCancel(WithTenant(context.Background(), "acme"))
+	client.track(ctx, &Request{Provider: ProviderOpenAI, Model: "gpt-4o"}, nil, nil)
+	cancel()
+	require.NoError(t, client.Shutdown(context.Background()))
+
+	assert.Len(t, stores.requests("acme"), 1)
+	assert.Empty(t, stores.requests("default"))
+}
+
+func TestTenantStorageRejectsInvalidTenants(t *testing.T) {
+	stores := newTenantStores()
+	storage, err := NewTenantStorage(stores.open, "default")
+	require.NoError(t, err)
+
+	for _, tenant := range []string{"../etc", "acme; DROP SCHEMA public", "a b", string(make([]byte, 64))} {
+		_, err := storage.Query(WithTenant(context.Background(), tenant), &RequestFilter{})
+		assert.ErrorIs(t, err, ErrInvalidTenant, tenant)
+	}
+	assert.Equal(t, []string{"default"}, stores.opened)
+
+	_, err = NewTenantStorage(stores.open, "")
+	assert.ErrorIs(t, err, ErrInvalidTenant, "a default tenant is required")
+}
+
+func TestTenantStorageDeleteOlderThanCoversOpenTenants(t *testing.T) {
+	stores := newTenantStores()
+	storage, err := NewTenantStorage(stores.open, "default")
+	require.NoError(t, err)
+	ctx := context.Background()
+	for _, tenant := range []string{"acme", "globex"} {
+		require.NoError(t, storage.Save(WithTenant(ctx, tenant), &Request{ID: tenant}))
+	}
+
+	deleted, err := storage.DeleteOlderThan(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted, "calls without a tenant cover every tenant")
+
+	deleted, err = storage.DeleteOlderThan(WithTenant(ctx, "acme"), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestTenantStorageOptionalInterfaces(t *testing.T) {
+	filters := &savedFilterStorage{MockStorageAdapter: &MockStorageAdapter{}, filters: make(map[string]*SavedFilter)}
+	storage, err := NewTenantStorage(func(tenant string) (StorageAdapter, error) {
+		if tenant == "acme" {
+			return filters, nil
+		}
+		return &MockStorageAdapter{}, nil
+	}, "default")
+	require.NoError(t, err)
+	client := NewClient(storage)
+	defer client.Close()
+
+	acme := WithTenant(context.Background(), "acme")
+	require.NoError(t, client.SaveFilter(acme, "errors", &RequestFilter{Model: "gpt-4o"}))
+	saved, err := client.GetSavedFilter(acme, "errors")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o", saved.Filter.Model)
+
+	_, err = client.GetSavedFilter(context.Background(), "errors")
+	assert.ErrorIs(t, err, ErrSavedFiltersNotSupported)
+	_, err = client.QueryFeedback(acme, &FeedbackFilter{})
+	assert.ErrorIs(t, err, ErrFeedbackNotSupported)
+}
+
+func TestTenantStorageOpenError(t *testing.T) {
+	openErr := errors.New("schema does not exist")
+	storage, err := NewTenantStorage(func(tenant string) (StorageAdapter, error) {
+		if tenant == "missing" {
+			return nil, openErr
+		}
+		return &MockStorageAdapter{}, nil
+	}, "default")
+	require.NoError(t, err)
+
+	err = storage.Save(WithTenant(context.Background(), "missing"), &Request{})
+	assert.ErrorIs(t, err, openErr)
+	assert.Equal(t, []string{"default"}, storage.Tenants(), "failed opens are retried on next use")
+
+	require.NoError(t, storage.Close())
+	_, err = storage.Tenant("default")
+	assert.Error(t, err)
+}
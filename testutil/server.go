@@ -0,0 +1,351 @@
+// Package testutil provides an in-process fake OpenAI and Anthropic API for testing code
+// that uses the tracer. Responses, usage, errors and streams are configurable, so the
+// tracking wrappers, the HTTP transport and the proxy can be tested without API keys.
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Response configures how the server answers a single request
+type Response struct {
+	// Content is the generated text; it is split into words when streamed
+	Content      string
+	InputTokens  int
+	OutputTokens int
+
+	// Status is the HTTP status; values >= 400 return a provider-shaped error body
+	Status       int
+	ErrorType    string
+	ErrorMessage string
+
+	// OmitStreamUsage leaves usage out of streams, like OpenAI-compatible servers that
+	// ignore stream_options.include_usage
+	OmitStreamUsage bool
+
+	// Headers are added to the response, e.g. rate-limit headers
+	Headers map[string]string
+
+	// Delay is waited before responding
+	Delay time.Duration
+}
+
+// DefaultResponse is returned when no response is queued
+var DefaultResponse = Response{
+	Content:      "Hello from the fake provider",
+	InputTokens:  10,
+	OutputTokens: 20,
+}
+
+// RecordedRequest is a request received by the server
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Server is a fake provider API. It serves OpenAI chat completions at
+// /v1/chat/completions and Anthropic messages at /v1/messages and
+// /v1/messages/count_tokens, streamed or not as the request asks.
+type Server struct {
+	*httptest.Server
+
+	mu              sync.Mutex
+	queue           []Response
+	defaultResponse Response
+	requests        []RecordedRequest
+}
+
+// NewServer starts a fake provider server that is closed when the test ends
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{defaultResponse: DefaultResponse}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Enqueue queues responses that are returned in order, one per request. Once the queue
+// is empty the default response is used.
+func (s *Server) Enqueue(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, responses...)
+}
+
+// SetDefault sets the response returned when no response is queued
+func (s *Server) SetDefault(response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultResponse = response
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RecordedRequest(nil), s.requests...)
+}
+
+// OpenAIConfig returns an OpenAI client configuration pointing at the server
+func (s *Server) OpenAIConfig() openai.ClientConfig {
+	config := openai.DefaultConfig("test-key")
+	config.BaseURL = s.URL + "/v1"
+	return config
+}
+
+// OpenAIClient returns an OpenAI client pointing at the server
+func (s *Server) OpenAIClient() *openai.Client {
+	return openai.NewClientWithConfig(s.OpenAIConfig())
+}
+
+// AnthropicOptions returns Anthropic client options pointing at the server, with retries
+// disabled so queued errors reach the caller
+func (s *Server) AnthropicOptions() []option.RequestOption {
+	return []option.RequestOption{
+		option.WithBaseURL(s.URL),
+		option.WithAPIKey("test-key"),
+		option.WithMaxRetries(0),
+	}
+}
+
+// AnthropicClient returns an Anthropic client pointing at the server
+func (s *Server) AnthropicClient(opts ...option.RequestOption) *anthropic.Client {
+	client := anthropic.NewClient(append(s.AnthropicOptions(), opts...)...)
+	return &client
+}
+
+// next records the request and returns the response to send
+func (s *Server) next(r *http.Request, body []byte) (Response, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, RecordedRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+
+	response := s.defaultResponse
+	if len(s.queue) > 0 {
+		response = s.queue[0]
+		s.queue = s.queue[1:]
+	}
+	return response, len(s.requests)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	response, n := s.next(r, body)
+	if response.Delay > 0 {
+		time.Sleep(response.Delay)
+	}
+
+	var payload struct {
+		Model         string `json:"model"`
+		Stream        bool   `json:"stream"`
+		StreamOptions *struct {
+			IncludeUsage bool `json:"include_usage"`
+		} `json:"stream_options"`
+	}
+	_ = json.Unmarshal(body, &payload)
+
+	requestID := fmt.Sprintf("req_testutil_%d", n)
+	for name, value := range response.Headers {
+		w.Header().Set(name, value)
+	}
+
+	switch {
+	case r.Method != http.MethodPost:
+		http.NotFound(w, r)
+	case strings.HasSuffix(r.URL.Path, "/chat/completions"):
+		w.Header().Set("X-Request-Id", requestID)
+		if response.Status >= 400 {
+			writeOpenAIError(w, response)
+			return
+		}
+		if payload.Stream {
+			includeUsage := payload.StreamOptions != nil && payload.StreamOptions.IncludeUsage
+			writeOpenAIStream(w, payload.Model, response, includeUsage && !response.OmitStreamUsage)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"id":     "chatcmpl-" + requestID,
+			"object": "chat.completion",
+			"model":  payload.Model,
+			"choices": []map[string]interface{}{{
+				"index":         0,
+				"message":       map[string]string{"role": "assistant", "content": response.Content},
+				"finish_reason": "stop",
+			}},
+			"usage": openAIUsage(response),
+		})
+	case strings.HasSuffix(r.URL.Path, "/v1/messages/count_tokens"):
+		w.Header().Set("Request-Id", requestID)
+		if response.Status >= 400 {
+			writeAnthropicError(w, response)
+			return
+		}
+		writeJSON(w, map[string]int{"input_tokens": response.InputTokens})
+	case strings.HasSuffix(r.URL.Path, "/v1/messages"):
+		w.Header().Set("Request-Id", requestID)
+		if response.Status >= 400 {
+			writeAnthropicError(w, response)
+			return
+		}
+		if payload.Stream {
+			writeAnthropicStream(w, payload.Model, requestID, response)
+			return
+		}
+		writeJSON(w, anthropicMessage(payload.Model, requestID, response))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeEvent(w http.ResponseWriter, event string, v interface{}) {
+	data, _ := json.Marshal(v)
+	if event != "" {
+		fmt.Fprintf(w, "event: %s\n", event)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func openAIUsage(response Response) map[string]int {
+	return map[string]int{
+		"prompt_tokens":     response.InputTokens,
+		"completion_tokens": response.OutputTokens,
+		"total_tokens":      response.InputTokens + response.OutputTokens,
+	}
+}
+
+func writeOpenAIError(w http.ResponseWriter, response Response) {
+	errorType := response.ErrorType
+	if errorType == "" {
+		errorType = "server_error"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.Status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": errorMessage(response),
+			"type":    errorType,
+			"code":    errorType,
+		},
+	})
+}
+
+func writeOpenAIStream(w http.ResponseWriter, model string, response Response, includeUsage bool) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, word := range streamChunks(response.Content) {
+		writeEvent(w, "", map[string]interface{}{
+			"object":  "chat.completion.chunk",
+			"model":   model,
+			"choices": []map[string]interface{}{{"index": 0, "delta": map[string]string{"content": word}}},
+		})
+	}
+	if includeUsage {
+		writeEvent(w, "", map[string]interface{}{
+			"object":  "chat.completion.chunk",
+			"model":   model,
+			"choices": []interface{}{},
+			"usage":   openAIUsage(response),
+		})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func writeAnthropicError(w http.ResponseWriter, response Response) {
+	errorType := response.ErrorType
+	if errorType == "" {
+		errorType = "api_error"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.Status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":  "error",
+		"error": map[string]string{"type": errorType, "message": errorMessage(response)},
+	})
+}
+
+func anthropicMessage(model, requestID string, response Response) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          "msg_" + requestID,
+		"type":        "message",
+		"role":        "assistant",
+		"model":       model,
+		"content":     []map[string]string{{"type": "text", "text": response.Content}},
+		"stop_reason": "end_turn",
+		"usage": map[string]int{
+			"input_tokens":  response.InputTokens,
+			"output_tokens": response.OutputTokens,
+		},
+	}
+}
+
+func writeAnthropicStream(w http.ResponseWriter, model, requestID string, response Response) {
+	w.Header().Set("Content-Type", "text/event-stream")
+
+	message := anthropicMessage(model, requestID, Response{InputTokens: response.InputTokens, OutputTokens: 1})
+	message["content"] = []interface{}{}
+	writeEvent(w, "message_start", map[string]interface{}{"type": "message_start", "message": message})
+	writeEvent(w, "content_block_start", map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         0,
+		"content_block": map[string]string{"type": "text", "text": ""},
+	})
+	for _, word := range streamChunks(response.Content) {
+		writeEvent(w, "content_block_delta", map[string]interface{}{
+			"type":  "content_block_delta",
+			"index": 0,
+			"delta": map[string]string{"type": "text_delta", "text": word},
+		})
+	}
+	writeEvent(w, "content_block_stop", map[string]interface{}{"type": "content_block_stop", "index": 0})
+	writeEvent(w, "message_delta", map[string]interface{}{
+		"type":  "message_delta",
+		"delta": map[string]string{"stop_reason": "end_turn"},
+		"usage": map[string]int{"output_tokens": response.OutputTokens},
+	})
+	writeEvent(w, "message_stop", map[string]string{"type": "message_stop"})
+}
+
+// streamChunks splits content into words, keeping the separating spaces
+func streamChunks(content string) []string {
+	var chunks []string
+	for i, word := range strings.SplitAfter(content, " ") {
+		if word != "" || i == 0 {
+			chunks = append(chunks, word)
+		}
+	}
+	return chunks
+}
+
+func errorMessage(response Response) string {
+	if response.ErrorMessage != "" {
+		return response.ErrorMessage
+	}
+	return http.StatusText(response.Status)
+}
@@ -0,0 +1,136 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestServer(t *testing.T) {
+	server := NewServer(t)
+	ctx := context.Background()
+
+	t.Run("OpenAI chat completion", func(t *testing.T) {
+		server.Enqueue(Response{Content: "hi", InputTokens: 3, OutputTokens: 1})
+
+		response, err := server.OpenAIClient().CreateChatCompletion(ctx, openai.ChatCompletionRequest{Model: "gpt-4o"})
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if response.Choices[0].Message.Content != "hi" || response.Usage.PromptTokens != 3 || response.Usage.CompletionTokens != 1 {
+			t.Errorf("Unexpected response: %+v", response)
+		}
+		if response.Header().Get("X-Request-Id") == "" {
+			t.Error("Expected a request ID header")
+		}
+	})
+
+	t.Run("OpenAI stream with usage", func(t *testing.T) {
+		stream, err := server.OpenAIClient().CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
+			Model:         "gpt-4o",
+			StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+		})
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer stream.Close()
+
+		var content string
+		var usage *openai.Usage
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Stream failed: %v", err)
+			}
+			for _, choice := range chunk.Choices {
+				content += choice.Delta.Content
+			}
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+		}
+		if content != DefaultResponse.Content {
+			t.Errorf("Expected content %q, got %q", DefaultResponse.Content, content)
+		}
+		if usage == nil || usage.PromptTokens != DefaultResponse.InputTokens {
+			t.Errorf("Expected usage chunk, got %+v", usage)
+		}
+	})
+
+	t.Run("OpenAI error", func(t *testing.T) {
+		server.Enqueue(Response{Status: http.StatusTooManyRequests, ErrorType: "rate_limit_exceeded"})
+
+		_, err := server.OpenAIClient().CreateChatCompletion(ctx, openai.ChatCompletionRequest{Model: "gpt-4o"})
+		var apiErr *openai.APIError
+		if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != http.StatusTooManyRequests {
+			t.Errorf("Expected 429 API error, got %v", err)
+		}
+	})
+
+	t.Run("Anthropic message", func(t *testing.T) {
+		server.Enqueue(Response{Content: "hello", InputTokens: 7, OutputTokens: 2})
+
+		message, err := server.AnthropicClient().Messages.New(ctx, anthropic.MessageNewParams{
+			Model:     anthropic.ModelClaude3_5HaikuLatest,
+			MaxTokens: 16,
+		})
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if message.Content[0].Text != "hello" || message.Usage.InputTokens != 7 || message.Usage.OutputTokens != 2 {
+			t.Errorf("Unexpected message: %+v", message)
+		}
+	})
+
+	t.Run("Anthropic stream", func(t *testing.T) {
+		server.Enqueue(Response{Content: "one two three", InputTokens: 5, OutputTokens: 3})
+
+		stream := server.AnthropicClient().Messages.NewStreaming(ctx, anthropic.MessageNewParams{
+			Model:     anthropic.ModelClaude3_5HaikuLatest,
+			MaxTokens: 16,
+		})
+		message := anthropic.Message{}
+		for stream.Next() {
+			if err := message.Accumulate(stream.Current()); err != nil {
+				t.Fatalf("Failed to accumulate: %v", err)
+			}
+		}
+		if err := stream.Err(); err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if message.Content[0].Text != "one two three" || message.Usage.InputTokens != 5 || message.Usage.OutputTokens != 3 {
+			t.Errorf("Unexpected message: %+v", message)
+		}
+	})
+
+	t.Run("Anthropic error", func(t *testing.T) {
+		server.Enqueue(Response{Status: 529, ErrorType: "overloaded_error"})
+
+		_, err := server.AnthropicClient().Messages.New(ctx, anthropic.MessageNewParams{
+			Model:     anthropic.ModelClaude3_5HaikuLatest,
+			MaxTokens: 16,
+		})
+		var apiErr *anthropic.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != 529 {
+			t.Errorf("Expected 529 API error, got %v", err)
+		}
+	})
+
+	t.Run("records requests", func(t *testing.T) {
+		requests := server.Requests()
+		if len(requests) != 6 {
+			t.Fatalf("Expected 6 recorded requests, got %d", len(requests))
+		}
+		if requests[0].Path != "/v1/chat/completions" || requests[3].Path != "/v1/messages" {
+			t.Errorf("Unexpected paths: %s, %s", requests[0].Path, requests[3].Path)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package llmtracer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithModelRateLimits throttles the trace wrapper calls to a provider model so they stay
+// under the account's requests and tokens per minute, instead of sleeping in application
+// code after hitting 429s. An empty model sets the limits of the provider's models without
+// their own; each model is still throttled separately.
+//
+// Limits are enforced with token buckets that start full and refill evenly over a minute.
+// A call waits, or until its context is done, while the model has no request left or has
+// used more tokens than it was allowed. Token counts are only known once a call is tracked,
+// so calls in flight can overshoot the token limit, and the following calls wait for the
+// overshoot to be paid back. The wait is added to Request.QueueWait and counted in
+// ClientMetrics. Calls made through the HTTP transport are neither limited nor counted.
+func WithModelRateLimits(provider Provider, model string, limits RateLimits) ClientOption {
+	return func(c *Client) {
+		c.throttles.mu.Lock()
+		defer c.throttles.mu.Unlock()
+
+		if c.throttles.limits == nil {
+			c.throttles.limits = make(map[string]RateLimits)
+		}
+		c.throttles.limits[string(provider)+"/"+model] = limits
+	}
+}
+
+// tokenBucket holds the requests and tokens a provider model may still use
+type tokenBucket struct {
+	limits RateLimits
+	// requests and tokens are the allowance left; tokens goes negative when tracked calls
+	// used more than was left
+	requests float64
+	tokens   float64
+	updated  time.Time
+}
+
+// newTokenBucket returns a full bucket
+func newTokenBucket(limits RateLimits, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		limits:   limits,
+		requests: float64(limits.RequestsPerMinute),
+		tokens:   float64(limits.TokensPerMinute),
+		updated:  now,
+	}
+}
+
+// refill adds the allowance accrued since the last update
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Minutes()
+	if elapsed <= 0 {
+		return
+	}
+	b.updated = now
+	if perMinute := float64(b.limits.RequestsPerMinute); perMinute > 0 {
+		b.requests = min(b.requests+elapsed*perMinute, perMinute)
+	}
+	if perMinute := float64(b.limits.TokensPerMinute); perMinute > 0 {
+		b.tokens = min(b.tokens+elapsed*perMinute, perMinute)
+	}
+}
+
+// take uses one request when a call fits now, otherwise it returns how long until it may
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	b.refill(now)
+
+	var wait time.Duration
+	if perMinute := float64(b.limits.RequestsPerMinute); perMinute > 0 && b.requests < 1 {
+		wait = max(wait, minutes((1-b.requests)/perMinute))
+	}
+	if perMinute := float64(b.limits.TokensPerMinute); perMinute > 0 && b.tokens < 0 {
+		wait = max(wait, minutes(-b.tokens/perMinute))
+	}
+	if wait > 0 {
+		return wait
+	}
+	if b.limits.RequestsPerMinute > 0 {
+		b.requests--
+	}
+	return 0
+}
+
+// minutes converts a fraction of minutes to a duration, rounding up so waits never end
+// before the allowance is there
+func minutes(m float64) time.Duration {
+	return time.Duration(m*float64(time.Minute)) + 1
+}
+
+// modelThrottles holds the limits set by WithModelRateLimits and a bucket per model
+type modelThrottles struct {
+	mu      sync.Mutex
+	limits  map[string]RateLimits
+	buckets map[string]*tokenBucket
+}
+
+// bucket returns the bucket of a provider model, or nil when it has no limits
+func (t *modelThrottles) bucket(provider Provider, model string, now time.Time) *tokenBucket {
+	key := string(provider) + "/" + model
+	if bucket, ok := t.buckets[key]; ok {
+		return bucket
+	}
+	limits, ok := t.limits[key]
+	if !ok {
+		if limits, ok = t.limits[string(provider)+"/"]; !ok {
+			return nil
+		}
+	}
+	if t.buckets == nil {
+		t.buckets = make(map[string]*tokenBucket)
+	}
+	bucket := newTokenBucket(limits, now)
+	t.buckets[key] = bucket
+	return bucket
+}
+
+// take uses one request of a provider model, or returns how long until a call fits
+func (t *modelThrottles) take(provider Provider, model string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.limits) == 0 {
+		return 0
+	}
+	bucket := t.bucket(provider, model, now)
+	if bucket == nil {
+		return 0
+	}
+	return bucket.take(now)
+}
+
+// charge counts the tokens of a tracked request against its model's limits
+func (t *modelThrottles) charge(request *Request, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.limits) == 0 {
+		return
+	}
+	bucket := t.bucket(request.Provider, request.Model, now)
+	if bucket == nil || bucket.limits.TokensPerMinute <= 0 {
+		return
+	}
+	bucket.refill(now)
+	bucket.tokens -= float64(AddTokens(request.InputTokens, request.OutputTokens))
+}
+
+// throttle waits until a call to a provider model fits its rate limits, returning how long
+// it waited, or the context's error when ctx is done first
+func (c *Client) throttle(ctx context.Context, provider Provider, model string) (time.Duration, error) {
+	var waited time.Duration
+	defer func() {
+		if waited > 0 {
+			c.metrics.throttled.Add(1)
+			c.metrics.throttleNanos.Add(int64(waited))
+		}
+	}()
+
+	for {
+		wait := c.throttles.take(provider, model, time.Now())
+		if wait == 0 {
+			return waited, nil
+		}
+
+		start := time.Now()
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			waited += time.Since(start)
+		case <-ctx.Done():
+			timer.Stop()
+			waited += time.Since(start)
+			return 0, ctx.Err()
+		}
+	}
+}
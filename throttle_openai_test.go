@@ -0,0 +1,48 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithModelRateLimits(t *testing.T) {
+	storage := newLifecycleStorage()
+	client := NewClient(storage, WithModelRateLimits(ProviderOpenAI, "gpt-4o", RateLimits{RequestsPerMinute: 1200}))
+	call := func(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+		return openai.ChatCompletionResponse{}, nil
+	}
+
+	// Drain the bucket so the next call waits for the 50ms a request takes to refill
+	client.throttles.mu.Lock()
+	client.throttles.bucket(ProviderOpenAI, "gpt-4o", time.Now()).requests = 0
+	client.throttles.mu.Unlock()
+
+	_, err := client.TraceOpenAIRequest(context.Background(), openai.ChatCompletionRequest{Model: "gpt-4o"}, call)
+	require.NoError(t, err)
+	require.Len(t, storage.saves, 1)
+	assert.GreaterOrEqual(t, storage.saves[0].QueueWait, 40*time.Millisecond)
+
+	metrics := client.Metrics()
+	assert.Equal(t, int64(1), metrics.Throttled)
+	assert.Equal(t, storage.saves[0].QueueWait, metrics.ThrottleWait)
+
+	t.Run("Calls are canceled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		client.throttles.mu.Lock()
+		client.throttles.bucket(ProviderOpenAI, "gpt-4o", time.Now()).requests = -100
+		client.throttles.mu.Unlock()
+
+		_, err := client.TraceOpenAIRequest(ctx, openai.ChatCompletionRequest{Model: "gpt-4o"}, call)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Len(t, storage.saves, 1)
+		assert.Equal(t, int64(2), client.Metrics().Throttled)
+	})
+}
@@ -0,0 +1,42 @@
+package llmtracer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelThrottles(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Requests per minute", func(t *testing.T) {
+		throttles := &modelThrottles{limits: map[string]RateLimits{"openai/gpt-4o": {RequestsPerMinute: 2}}}
+
+		assert.Zero(t, throttles.take(ProviderOpenAI, "gpt-4o", now))
+		assert.Zero(t, throttles.take(ProviderOpenAI, "gpt-4o", now))
+		assert.InDelta(t, float64(30*time.Second), float64(throttles.take(ProviderOpenAI, "gpt-4o", now)), float64(time.Millisecond))
+		assert.Zero(t, throttles.take(ProviderOpenAI, "gpt-4o", now.Add(30*time.Second)))
+		assert.Zero(t, throttles.take(ProviderOpenAI, "gpt-4o-mini", now), "other models are not limited")
+	})
+
+	t.Run("Tokens per minute", func(t *testing.T) {
+		throttles := &modelThrottles{limits: map[string]RateLimits{"anthropic/": {TokensPerMinute: 60_000}}}
+		request := &Request{Provider: ProviderAnthropic, Model: "claude-3-5-haiku-latest", InputTokens: 70_000, OutputTokens: 2_000}
+
+		assert.Zero(t, throttles.take(ProviderAnthropic, request.Model, now))
+		throttles.charge(request, now)
+		assert.InDelta(t, float64(12*time.Second), float64(throttles.take(ProviderAnthropic, request.Model, now)), float64(time.Millisecond),
+			"the overshoot is paid back first")
+		assert.Zero(t, throttles.take(ProviderAnthropic, "claude-3-opus-latest", now), "models have their own buckets")
+		assert.Zero(t, throttles.take(ProviderAnthropic, request.Model, now.Add(12*time.Second)))
+	})
+
+	t.Run("Buckets refill up to the limit", func(t *testing.T) {
+		throttles := &modelThrottles{limits: map[string]RateLimits{"openai/gpt-4o": {RequestsPerMinute: 1}}}
+
+		assert.Zero(t, throttles.take(ProviderOpenAI, "gpt-4o", now))
+		assert.Zero(t, throttles.take(ProviderOpenAI, "gpt-4o", now.Add(time.Hour)))
+		assert.Positive(t, throttles.take(ProviderOpenAI, "gpt-4o", now.Add(time.Hour)))
+	})
+}
@@ -0,0 +1,159 @@
+package llmtracer
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TraceTimeline lays out the requests of one trace in time, ready to render as a
+// waterfall. Offsets are relative to the first request of the trace.
+type TraceTimeline struct {
+	TraceID string    `json:"trace_id"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	// Duration spans from the first request to the last response
+	Duration time.Duration `json:"duration"`
+	// IdleTime is the time within Duration when no request was in flight
+	IdleTime     time.Duration    `json:"idle_time"`
+	Requests     int64            `json:"requests"`
+	ErrorCount   int64            `json:"error_count"`
+	TotalCost    float64          `json:"total_cost"`
+	TotalTokens  int64            `json:"total_tokens"`
+	TotalLatency time.Duration    `json:"total_latency"`
+	Entries      []*TimelineEntry `json:"entries"`
+	Steps        []*TimelineStep  `json:"steps"`
+}
+
+// TimelineEntry is one request of a trace timeline
+type TimelineEntry struct {
+	Request *Request `json:"request"`
+	// Offset is when the request was sent and EndOffset when its response arrived
+	Offset    time.Duration `json:"offset"`
+	EndOffset time.Duration `json:"end_offset"`
+	// Gap is the time since the previous requests finished; zero when it overlaps them
+	Gap time.Duration `json:"gap"`
+	// CumulativeCost and CumulativeLatency add up this request and the ones before it
+	CumulativeCost    float64       `json:"cumulative_cost"`
+	CumulativeLatency time.Duration `json:"cumulative_latency"`
+	// Step is the index of the entry's step in TraceTimeline.Steps
+	Step int `json:"step"`
+}
+
+// TimelineStep summarizes the requests of one workflow step of a trace, or the requests
+// made outside workflow steps, which form a step with index 0 and no name. Steps are in
+// the order they started.
+type TimelineStep struct {
+	Index      int           `json:"index"`
+	Name       string        `json:"name,omitempty"`
+	Offset     time.Duration `json:"offset"`
+	EndOffset  time.Duration `json:"end_offset"`
+	Requests   int64         `json:"requests"`
+	ErrorCount int64         `json:"error_count"`
+	TotalCost  float64       `json:"total_cost"`
+	// TotalLatency adds up the latency of the step's requests; it exceeds the step's span
+	// when they ran concurrently
+	TotalLatency time.Duration `json:"total_latency"`
+	TotalTokens  int64         `json:"total_tokens"`
+}
+
+// GetTraceTimeline returns the requests of a trace ordered by the time they were sent, with
+// the gaps between them, running totals and a summary per workflow step (see
+// BeginWorkflow). A trace without stored requests has an empty timeline. Requests still in
+// flight end when they started. traceID is normalized as by WithTraceID.
+func (c *Client) GetTraceTimeline(ctx context.Context, traceID string) (*TraceTimeline, error) {
+	if !c.storage.Capabilities().Query {
+		return nil, ErrQueryNotSupported
+	}
+	traceID = NormalizeTraceID(traceID)
+	requests, err := c.storage.GetByTraceID(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	return newTraceTimeline(traceID, requests), nil
+}
+
+// newTraceTimeline lays out the requests of a trace
+func newTraceTimeline(traceID string, requests []*Request) *TraceTimeline {
+	timeline := &TraceTimeline{
+		TraceID: traceID,
+		Entries: make([]*TimelineEntry, 0, len(requests)),
+		Steps:   []*TimelineStep{},
+	}
+	if len(requests) == 0 {
+		return timeline
+	}
+
+	sorted := make([]*Request, len(requests))
+	copy(sorted, requests)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].RequestedAt.Equal(sorted[j].RequestedAt) {
+			return sorted[i].RequestedAt.Before(sorted[j].RequestedAt)
+		}
+		return requestEnd(sorted[i]).Before(requestEnd(sorted[j]))
+	})
+
+	timeline.Start = sorted[0].RequestedAt
+	timeline.End = timeline.Start
+	steps := make(map[string]int)
+	for _, request := range sorted {
+		end := requestEnd(request)
+		entry := &TimelineEntry{
+			Request:   request,
+			Offset:    request.RequestedAt.Sub(timeline.Start),
+			EndOffset: end.Sub(timeline.Start),
+		}
+		if request.RequestedAt.After(timeline.End) {
+			entry.Gap = request.RequestedAt.Sub(timeline.End)
+			timeline.IdleTime += entry.Gap
+		}
+		if end.After(timeline.End) {
+			timeline.End = end
+		}
+
+		timeline.Requests++
+		timeline.TotalCost += request.Cost
+		timeline.TotalTokens = AddTokens(timeline.TotalTokens, request.TotalTokens)
+		timeline.TotalLatency += request.Latency
+		if request.Error != "" {
+			timeline.ErrorCount++
+		}
+		entry.CumulativeCost = timeline.TotalCost
+		entry.CumulativeLatency = timeline.TotalLatency
+
+		index, _ := strconv.Atoi(request.Dimension(DimensionWorkflowStep))
+		name := request.Dimension(DimensionWorkflowStepName)
+		key := strconv.Itoa(index) + "/" + name
+		position, ok := steps[key]
+		if !ok {
+			position = len(timeline.Steps)
+			steps[key] = position
+			timeline.Steps = append(timeline.Steps, &TimelineStep{Index: index, Name: name, Offset: entry.Offset})
+		}
+		entry.Step = position
+
+		step := timeline.Steps[position]
+		step.EndOffset = max(step.EndOffset, entry.EndOffset)
+		step.Requests++
+		step.TotalCost += request.Cost
+		step.TotalLatency += request.Latency
+		step.TotalTokens = AddTokens(step.TotalTokens, request.TotalTokens)
+		if request.Error != "" {
+			step.ErrorCount++
+		}
+
+		timeline.Entries = append(timeline.Entries, entry)
+	}
+	timeline.Duration = timeline.End.Sub(timeline.Start)
+	return timeline
+}
+
+// requestEnd returns when a request's response arrived, or when it was sent while it is in
+// flight
+func requestEnd(request *Request) time.Time {
+	if request.RespondedAt.Before(request.RequestedAt) {
+		return request.RequestedAt
+	}
+	return request.RespondedAt
+}
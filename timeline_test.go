@@ -0,0 +1,68 @@
+package llmtracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTraceTimeline(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) time.Time { return start.Add(offset) }
+	step := func(index, name string) []DimensionTag {
+		return []DimensionTag{{Key: DimensionWorkflowStep, Value: index}, {Key: DimensionWorkflowStepName, Value: name}}
+	}
+
+	requests := []*Request{
+		{ID: "answer", RequestedAt: at(5 * time.Second), RespondedAt: at(8 * time.Second), Latency: 3 * time.Second, Cost: 0.03, TotalTokens: 300, Dimensions: step("2", "answer")},
+		{ID: "retrieve-b", RequestedAt: at(time.Second), RespondedAt: at(4 * time.Second), Latency: 3 * time.Second, Cost: 0.01, TotalTokens: 100, Error: "timeout", Dimensions: step("1", "retrieve")},
+		{ID: "retrieve-a", RequestedAt: at(0), RespondedAt: at(2 * time.Second), Latency: 2 * time.Second, Cost: 0.01, TotalTokens: 100, Dimensions: step("1", "retrieve")},
+		{ID: "log", RequestedAt: at(9 * time.Second), Pending: true},
+	}
+	storage := &MockStorageAdapter{
+		GetByTraceIDFunc: func(ctx context.Context, traceID string) ([]*Request, error) {
+			return requests, nil
+		},
+	}
+
+	timeline, err := NewClient(storage).GetTraceTimeline(context.Background(), "trace-1")
+	require.NoError(t, err)
+	assert.Equal(t, start, timeline.Start)
+	assert.Equal(t, 9*time.Second, timeline.Duration)
+	assert.Equal(t, 2*time.Second, timeline.IdleTime)
+	assert.Equal(t, int64(4), timeline.Requests)
+	assert.Equal(t, int64(1), timeline.ErrorCount)
+	assert.InDelta(t, 0.05, timeline.TotalCost, 1e-9)
+	assert.Equal(t, int64(500), timeline.TotalTokens)
+
+	require.Len(t, timeline.Entries, 4)
+	var ids []string
+	for _, entry := range timeline.Entries {
+		ids = append(ids, entry.Request.ID)
+	}
+	assert.Equal(t, []string{"retrieve-a", "retrieve-b", "answer", "log"}, ids)
+	answer := timeline.Entries[2]
+	assert.Equal(t, 5*time.Second, answer.Offset)
+	assert.Equal(t, 8*time.Second, answer.EndOffset)
+	assert.Equal(t, time.Second, answer.Gap)
+	assert.Zero(t, timeline.Entries[1].Gap, "overlapping requests have no gap")
+	assert.InDelta(t, 0.05, answer.CumulativeCost, 1e-9)
+	assert.Equal(t, 8*time.Second, answer.CumulativeLatency)
+	assert.Equal(t, 9*time.Second, timeline.Entries[3].EndOffset, "requests in flight end when they started")
+
+	require.Len(t, timeline.Steps, 3)
+	retrieve := timeline.Steps[0]
+	assert.Equal(t, TimelineStep{Index: 1, Name: "retrieve", EndOffset: 4 * time.Second, Requests: 2, ErrorCount: 1, TotalCost: 0.02, TotalLatency: 5 * time.Second, TotalTokens: 200}, *retrieve)
+	assert.Equal(t, 1, answer.Step)
+	assert.Equal(t, 0, timeline.Steps[2].Index, "requests outside workflow steps share a step")
+
+	t.Run("Empty traces", func(t *testing.T) {
+		timeline, err := NewClient(&MockStorageAdapter{}).GetTraceTimeline(context.Background(), "missing")
+		require.NoError(t, err)
+		assert.Empty(t, timeline.Entries)
+		assert.Zero(t, timeline.Duration)
+	})
+}
@@ -0,0 +1,67 @@
+package llmtracer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// MaxTraceIDLength is the longest trace ID stored. Longer IDs are shortened by
+// NormalizeTraceID.
+const MaxTraceIDLength = 128
+
+// traceIDHashLength is the number of hex characters of the hash that replaces the end of a
+// trace ID longer than MaxTraceIDLength
+const traceIDHashLength = 16
+
+// NormalizeTraceID returns the trace ID stored for id, so the same trace is found whichever
+// system reported it:
+//   - a W3C traceparent header becomes its 32-character trace ID
+//   - hex trace IDs (OpenTelemetry, Jaeger, Zipkin) and UUIDs are lowercased
+//   - other identifiers are kept, without surrounding whitespace, control characters and
+//     invalid UTF-8
+//
+// IDs of any kind longer than MaxTraceIDLength keep their beginning followed by a hash of
+// the whole ID, so distinct long IDs stay distinct. An ID that is empty once cleaned
+// returns "". Trace IDs in a RequestFilter and passed to GetByTraceID are normalized the
+// same way, so a trace can be looked up by the ID any system reported.
+func NormalizeTraceID(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return ""
+	}
+	if traceID, _ := parseTraceParent(id); traceID != "" {
+		id = traceID
+	} else if isHex(id) {
+		id = strings.ToLower(id)
+	} else if parsed, err := uuid.Parse(id); err == nil && len(id) == 36 {
+		id = parsed.String()
+	} else {
+		id = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, strings.ToValidUTF8(id, ""))
+	}
+	if len(id) <= MaxTraceIDLength {
+		return id
+	}
+
+	sum := sha256.Sum256([]byte(id))
+	prefix := id[:MaxTraceIDLength-traceIDHashLength-1]
+	// Don't cut a multi-byte character in half
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix + "-" + hex.EncodeToString(sum[:])[:traceIDHashLength]
+}
+
+// isHex reports whether s consists of hex digits only
+func isHex(s string) bool {
+	return strings.Trim(s, "0123456789abcdefABCDEF") == ""
+}
@@ -0,0 +1,72 @@
+package llmtracer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTraceID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want string
+	}{
+		{"traceparent", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"hex trace ID", "4BF92F3577B34DA6A3CE929D0E0E4736", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"64-bit hex trace ID", "00F067AA0BA902B7", "00f067aa0ba902b7"},
+		{"uuid", "550E8400-E29B-41D4-A716-446655440000", "550e8400-e29b-41d4-a716-446655440000"},
+		{"arbitrary", "  Root=1-5759e988-bd862e3fe1be46a994272793\n", "Root=1-5759e988-bd862e3fe1be46a994272793"},
+		{"case kept", "Req-ABC", "Req-ABC"},
+		{"control characters", "req\x00-1\x7f\xff", "req-1"},
+		{"blank", " \t", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NormalizeTraceID(tt.id))
+		})
+	}
+}
+
+func TestNormalizeTraceIDLength(t *testing.T) {
+	long := strings.Repeat("a-", 100)
+	got := NormalizeTraceID(long)
+	assert.Len(t, got, MaxTraceIDLength)
+	assert.True(t, strings.HasPrefix(got, long[:100]))
+	assert.Equal(t, got, NormalizeTraceID(long), "shortening is stable")
+	assert.NotEqual(t, got, NormalizeTraceID(long+"b"), "distinct IDs stay distinct")
+	assert.Equal(t, got, NormalizeTraceID(got), "normalized IDs are kept")
+
+	longHex := strings.Repeat("AB", 100)
+	got = NormalizeTraceID(longHex)
+	assert.Len(t, got, MaxTraceIDLength, "hex IDs are shortened too")
+	assert.True(t, strings.HasPrefix(got, strings.ToLower(longHex[:100])))
+	assert.Equal(t, got, NormalizeTraceID(got))
+
+	multibyte := NormalizeTraceID(strings.Repeat("é", 100))
+	assert.True(t, utf8.ValidString(multibyte))
+	assert.LessOrEqual(t, len(multibyte), MaxTraceIDLength)
+}
+
+func TestWithTraceIDNormalizes(t *testing.T) {
+	ctx := WithTraceID(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", GetTraceIDFromContext(ctx))
+
+	ctx = WithTraceID(context.Background(), "\n")
+	assert.NotEmpty(t, GetTraceIDFromContext(ctx), "a blank ID is replaced by a generated one")
+}
+
+func TestRequestFilterNormalizesTraceID(t *testing.T) {
+	request := &Request{TraceID: NormalizeTraceID("4BF92F3577B34DA6A3CE929D0E0E4736")}
+	for _, traceID := range []string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"4BF92F3577B34DA6A3CE929D0E0E4736",
+		" 4bf92f3577b34da6a3ce929d0e0e4736\n",
+	} {
+		assert.True(t, (&RequestFilter{TraceID: traceID}).Matches(request), traceID)
+	}
+	assert.False(t, (&RequestFilter{TraceID: "00f067aa0ba902b7"}).Matches(request))
+}
@@ -0,0 +1,579 @@
+package llmtracer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TracingTransport is an http.RoundTripper that tracks calls to OpenAI-compatible,
+// Anthropic and Gemini endpoints by parsing the usage reported in their responses.
+// It lets any SDK (or raw HTTP caller) be instrumented by swapping its HTTP client.
+// Requests to unrecognized endpoints pass through untracked.
+type TracingTransport struct {
+	client *Client
+	base   http.RoundTripper
+}
+
+// NewTracingTransport wraps base (http.DefaultTransport when nil) with usage tracking
+func NewTracingTransport(client *Client, base http.RoundTripper) *TracingTransport {
+	if client == nil {
+		panic("client cannot be nil")
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &TracingTransport{
+		client: client,
+		base:   base,
+	}
+}
+
+// HTTPClient returns an *http.Client that routes requests through the tracing transport
+func (t *TracingTransport) HTTPClient() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	provider, ok := detectProvider(req)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	// Buffer the body so the model and message count can be read without consuming it
+	var requestBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		requestBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(requestBody)), nil
+		}
+	}
+
+	tracked := newTransportRequest(provider, req, requestBody)
+	if t.client.capturePayloadSizes {
+		tracked.RequestBytes = len(requestBody)
+	}
+	if t.client.capturePayloads {
+		tracked.RequestPayload = string(requestBody)
+	}
+	tracked = t.client.startRequest(req.Context(), tracked)
+
+	startTime := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		tracked.RequestedAt = startTime
+		tracked.Latency = time.Since(startTime)
+		t.client.track(req.Context(), tracked, err, GetDimensionsFromContext(req.Context()))
+		return nil, err
+	}
+
+	tracked.StatusCode = resp.StatusCode
+	tracked.ProviderRequestID = providerRequestIDFromHeader(provider, resp.Header)
+	if resp.StatusCode >= 400 {
+		tracked.RetryAfter = parseRetryAfter(resp.Header, time.Now())
+	}
+	rateLimits := t.client.recordRateLimitHeaders(provider, resp.Header)
+
+	// Streams are tracked when the caller finishes reading the body
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = &trackingStreamBody{
+			ReadCloser: resp.Body,
+			transport:  t,
+			ctx:        req.Context(),
+			request:    tracked,
+			rateLimits: rateLimits,
+			startTime:  startTime,
+		}
+		return resp, nil
+	}
+
+	responseBody, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+	tracked.RequestedAt = startTime
+	tracked.Latency = time.Since(startTime)
+	if t.client.capturePayloadSizes {
+		tracked.ResponseBytes = len(responseBody)
+	}
+
+	apiErr := readErr
+	if apiErr == nil {
+		apiErr = applyResponseBody(tracked, responseBody)
+	}
+	trackingContext := GetDimensionsFromContext(req.Context())
+	rateLimits.apply(tracked)
+	t.client.track(req.Context(), tracked, apiErr, trackingContext)
+
+	return resp, nil
+}
+
+// azureContentSafetyPath prefixes the operations of Azure AI Content Safety, e.g.
+// /contentsafety/text:analyze
+const azureContentSafetyPath = "/contentsafety/"
+
+// detectProvider recognizes LLM API endpoints by path, so OpenAI-compatible gateways and
+// self-hosted servers are covered regardless of host
+func detectProvider(req *http.Request) (Provider, bool) {
+	if req.Method != http.MethodPost {
+		return "", false
+	}
+
+	path := req.URL.Path
+	switch {
+	case strings.HasSuffix(path, "/v1/messages"), strings.HasSuffix(path, "/v1/messages/count_tokens"):
+		return ProviderAnthropic, true
+	case strings.Contains(path, ":generateContent"), strings.Contains(path, ":streamGenerateContent"),
+		strings.Contains(path, ":countTokens"), strings.Contains(path, ":embedContent"):
+		return ProviderGoogle, true
+	case strings.Contains(path, azureContentSafetyPath):
+		return ProviderAzure, true
+	case strings.HasSuffix(path, "/chat/completions"),
+		strings.HasSuffix(path, "/completions"),
+		strings.HasSuffix(path, "/embeddings"),
+		strings.HasSuffix(path, "/moderations"),
+		strings.HasSuffix(path, "/responses"):
+		if strings.Contains(req.URL.Host, "mistral.ai") {
+			return ProviderMistral, true
+		}
+		return ProviderOpenAI, true
+	}
+
+	return "", false
+}
+
+// newTransportRequest builds the tracked request from the outgoing HTTP request
+func newTransportRequest(provider Provider, req *http.Request, body []byte) *Request {
+	var payload struct {
+		Model    string            `json:"model"`
+		Messages []json.RawMessage `json:"messages"`
+		Contents []json.RawMessage `json:"contents"`
+	}
+	_ = json.Unmarshal(body, &payload)
+
+	tracked := &Request{
+		Provider:     provider,
+		Model:        payload.Model,
+		RequestType:  transportRequestType(req.URL.Path),
+		MessageCount: len(payload.Messages),
+	}
+
+	// Gemini carries the model in the path: /v1beta/models/gemini-1.5-flash:generateContent
+	if provider == ProviderGoogle {
+		tracked.MessageCount = len(payload.Contents)
+		if idx := strings.LastIndex(req.URL.Path, "/models/"); idx >= 0 {
+			model := req.URL.Path[idx+len("/models/"):]
+			if colon := strings.Index(model, ":"); colon >= 0 {
+				model = model[:colon]
+			}
+			tracked.Model = model
+		}
+	}
+
+	// Azure AI Content Safety has no models, so the operation stands in for one, e.g.
+	// text:analyze from /contentsafety/text:analyze, and can be priced per request
+	if provider == ProviderAzure {
+		if idx := strings.LastIndex(req.URL.Path, azureContentSafetyPath); idx >= 0 {
+			tracked.Model = req.URL.Path[idx+len(azureContentSafetyPath):]
+		}
+	}
+
+	return tracked
+}
+
+// transportRequestType classifies an endpoint path; counted tokens are not parsed as usage
+func transportRequestType(path string) RequestType {
+	switch {
+	case strings.HasSuffix(path, "/count_tokens"), strings.Contains(path, ":countTokens"):
+		return RequestTypeTokenCount
+	case strings.HasSuffix(path, "/embeddings"), strings.Contains(path, ":embedContent"):
+		return RequestTypeEmbedding
+	case strings.HasSuffix(path, "/moderations"), strings.Contains(path, azureContentSafetyPath):
+		return RequestTypeModeration
+	}
+	return RequestTypeChat
+}
+
+// providerRequestIDFromHeader returns the provider-issued request ID header
+func providerRequestIDFromHeader(provider Provider, header http.Header) string {
+	if provider == ProviderAnthropic {
+		return header.Get("Request-Id")
+	}
+	return header.Get("X-Request-Id")
+}
+
+// usagePayload covers the usage shapes reported by OpenAI, Anthropic and Gemini, both in
+// full responses and in individual stream events
+type usagePayload struct {
+	Usage         *tokenUsage `json:"usage"`
+	UsageMetadata *struct {
+		PromptTokenCount        int64 `json:"promptTokenCount"`
+		CandidatesTokenCount    int64 `json:"candidatesTokenCount"`
+		CachedContentTokenCount int64 `json:"cachedContentTokenCount"`
+		ThoughtsTokenCount      int64 `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+	// Anthropic reports input usage on the message_start event
+	Message *struct {
+		Usage *tokenUsage `json:"usage"`
+	} `json:"message"`
+	// The OpenAI Responses API nests usage in the response.completed event
+	Response *struct {
+		Usage *tokenUsage `json:"usage"`
+		responsesStatus
+	} `json:"response"`
+	Error json.RawMessage `json:"error"`
+
+	// Finish reasons not covered by the stream events below
+	StopReason string `json:"stop_reason"`
+	responsesStatus
+
+	// Stream events, used to detect the end of a generation and to estimate the output
+	// tokens of aborted streams
+	Type    string `json:"type"`
+	Choices []struct {
+		FinishReason string `json:"finish_reason"`
+		Delta        struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Delta *struct {
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Candidates []struct {
+		FinishReason string `json:"finishReason"`
+		Content      struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+}
+
+// responsesStatus is the status of an OpenAI Responses API response
+type responsesStatus struct {
+	Status            string `json:"status"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details"`
+}
+
+// finishReason returns the reason a Responses API generation ended
+func (s responsesStatus) finishReason() string {
+	if s.IncompleteDetails != nil && s.IncompleteDetails.Reason != "" {
+		return s.IncompleteDetails.Reason
+	}
+	if s.Status == "completed" {
+		return s.Status
+	}
+	return ""
+}
+
+// streamProgress returns the length of the generated text in a stream event and whether
+// the event ends the generation
+func streamProgress(payload *usagePayload) (textLen int, finished bool) {
+	finished = payload.Type == "message_stop" || payload.Type == "response.completed"
+	for _, choice := range payload.Choices {
+		textLen += len(choice.Delta.Content)
+		finished = finished || choice.FinishReason != ""
+	}
+	if payload.Delta != nil {
+		textLen += len(payload.Delta.Text)
+	}
+	for _, candidate := range payload.Candidates {
+		for _, part := range candidate.Content.Parts {
+			textLen += len(part.Text)
+		}
+		finished = finished || candidate.FinishReason != ""
+	}
+	return textLen, finished
+}
+
+// tokenUsage covers the usage objects of the OpenAI Chat Completions and Responses APIs
+// and the Anthropic Messages API
+type tokenUsage struct {
+	PromptTokens             int64 `json:"prompt_tokens"`
+	CompletionTokens         int64 `json:"completion_tokens"`
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	PromptTokensDetails      *struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	InputTokensDetails *struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+	CompletionTokensDetails *struct {
+		ReasoningTokens int64 `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+	OutputTokensDetails *struct {
+		ReasoningTokens int64 `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+}
+
+// normalize maps the usage object to Usage
+func (u *tokenUsage) normalize() Usage {
+	usage := Usage{
+		InputTokens:       max(u.PromptTokens, u.InputTokens+u.CacheReadInputTokens+u.CacheCreationInputTokens),
+		OutputTokens:      max(u.CompletionTokens, u.OutputTokens),
+		CachedInputTokens: u.CacheReadInputTokens,
+	}
+	if u.PromptTokensDetails != nil {
+		usage.CachedInputTokens = max(usage.CachedInputTokens, u.PromptTokensDetails.CachedTokens)
+	}
+	if u.InputTokensDetails != nil {
+		usage.CachedInputTokens = max(usage.CachedInputTokens, u.InputTokensDetails.CachedTokens)
+	}
+	if u.CompletionTokensDetails != nil {
+		usage.ReasoningTokens = u.CompletionTokensDetails.ReasoningTokens
+	}
+	if u.OutputTokensDetails != nil {
+		usage.ReasoningTokens = max(usage.ReasoningTokens, u.OutputTokensDetails.ReasoningTokens)
+	}
+	return usage
+}
+
+// applyUsage copies the usage found in a payload onto the request. Counts only ever
+// increase so that partial stream events don't reset earlier values.
+func applyUsage(request *Request, payload *usagePayload) {
+	var reported []Usage
+	if payload.Usage != nil {
+		reported = append(reported, payload.Usage.normalize())
+	}
+	if payload.UsageMetadata != nil {
+		reported = append(reported, Usage{
+			InputTokens:       payload.UsageMetadata.PromptTokenCount,
+			OutputTokens:      payload.UsageMetadata.CandidatesTokenCount + payload.UsageMetadata.ThoughtsTokenCount,
+			CachedInputTokens: payload.UsageMetadata.CachedContentTokenCount,
+			ReasoningTokens:   payload.UsageMetadata.ThoughtsTokenCount,
+		})
+	}
+	if payload.Message != nil && payload.Message.Usage != nil {
+		reported = append(reported, payload.Message.Usage.normalize())
+	}
+	if payload.Response != nil && payload.Response.Usage != nil {
+		reported = append(reported, payload.Response.Usage.normalize())
+	}
+
+	for _, usage := range reported {
+		request.InputTokens = max(request.InputTokens, usage.InputTokens)
+		request.OutputTokens = max(request.OutputTokens, usage.OutputTokens)
+		request.CachedInputTokens = max(request.CachedInputTokens, usage.CachedInputTokens)
+		request.ReasoningTokens = max(request.ReasoningTokens, usage.ReasoningTokens)
+	}
+}
+
+// applyFinishReason records the finish reason found in a payload. Stream events report it
+// once the generation ends, so a later event overrides an earlier one.
+func applyFinishReason(request *Request, payload *usagePayload) {
+	set := func(reason string) {
+		if reason != "" {
+			request.FinishReason = normalizeFinishReason(reason)
+		}
+	}
+
+	set(payload.StopReason)
+	set(payload.finishReason())
+	if payload.Delta != nil {
+		set(payload.Delta.StopReason)
+	}
+	if len(payload.Choices) > 0 {
+		set(payload.Choices[0].FinishReason)
+	}
+	if len(payload.Candidates) > 0 {
+		set(payload.Candidates[0].FinishReason)
+	}
+	if payload.Response != nil {
+		set(payload.Response.finishReason())
+	}
+}
+
+// applyResponseBody parses a complete response body, returning an error describing
+// non-2xx responses so they are tracked as failed requests
+func applyResponseBody(request *Request, body []byte) error {
+	var payload usagePayload
+	_ = json.Unmarshal(body, &payload)
+	applyUsage(request, &payload)
+	applyFinishReason(request, &payload)
+
+	if request.StatusCode < 400 {
+		return nil
+	}
+
+	message := applyErrorPayload(request, payload.Error)
+	if message == "" {
+		message = strings.TrimSpace(string(body))
+	}
+	return fmt.Errorf("HTTP %d: %s", request.StatusCode, message)
+}
+
+// applyErrorPayload extracts the provider error code and type, returning the error message.
+// OpenAI uses {"type","code","message"}, Anthropic {"type","message"} without a code and
+// Gemini {"code","status","message"}.
+func applyErrorPayload(request *Request, raw json.RawMessage) string {
+	var providerErr struct {
+		Type    string      `json:"type"`
+		Code    interface{} `json:"code"`
+		Status  string      `json:"status"`
+		Message string      `json:"message"`
+	}
+	if len(raw) == 0 || json.Unmarshal(raw, &providerErr) != nil {
+		return ""
+	}
+
+	request.ProviderErrorType = providerErr.Type
+	if providerErr.Status != "" {
+		request.ProviderErrorType = providerErr.Status
+	}
+	switch code := providerErr.Code.(type) {
+	case string:
+		request.ProviderErrorCode = code
+	case float64:
+		request.ProviderErrorCode = fmt.Sprintf("%d", int(code))
+	}
+
+	return providerErr.Message
+}
+
+// trackingStreamBody parses server-sent events as the caller reads them and tracks the
+// request once the stream ends or is closed. Streams closed before the generation
+// finished, or whose context is canceled, are tracked as aborted.
+type trackingStreamBody struct {
+	io.ReadCloser
+	transport  *TracingTransport
+	ctx        context.Context
+	request    *Request
+	rateLimits *ProviderRateLimitStatus
+	startTime  time.Time
+
+	pending   []byte
+	bytes     int
+	textLen   int
+	completed bool
+	once      sync.Once
+	readErr   error
+}
+
+func (b *trackingStreamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.bytes += n
+		b.consume(p[:n])
+	}
+	if err != nil {
+		if err == io.EOF {
+			b.completed = true
+		} else if b.readErr == nil {
+			b.readErr = abortError(b.ctx, err)
+		}
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *trackingStreamBody) Close() error {
+	if !b.completed && b.readErr == nil {
+		b.readErr = fmt.Errorf("%w: body closed before the generation finished", ErrStreamAborted)
+	}
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+// consume feeds complete SSE lines to the usage parser
+func (b *trackingStreamBody) consume(data []byte) {
+	b.pending = append(b.pending, data...)
+	for {
+		idx := bytes.IndexByte(b.pending, '\n')
+		if idx < 0 {
+			return
+		}
+		b.parseLine(b.pending[:idx])
+		b.pending = b.pending[idx+1:]
+	}
+}
+
+func (b *trackingStreamBody) parseLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return
+	}
+	data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+	if bytes.Equal(data, []byte("[DONE]")) {
+		b.completed = true
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	var payload usagePayload
+	if json.Unmarshal(data, &payload) != nil {
+		return
+	}
+	applyUsage(b.request, &payload)
+	applyFinishReason(b.request, &payload)
+	textLen, finished := streamProgress(&payload)
+	b.textLen += textLen
+	b.completed = b.completed || finished
+	if len(payload.Error) > 0 && b.readErr == nil {
+		b.readErr = fmt.Errorf("stream error: %s", applyErrorPayload(b.request, payload.Error))
+	}
+}
+
+// finish tracks the stream exactly once
+func (b *trackingStreamBody) finish() {
+	b.once.Do(func() {
+		if len(b.pending) > 0 {
+			b.parseLine(b.pending)
+			b.pending = nil
+		}
+
+		b.request.RequestedAt = b.startTime
+		b.request.Latency = time.Since(b.startTime)
+		if b.transport.client.capturePayloadSizes {
+			b.request.ResponseBytes = b.bytes
+		}
+
+		err := b.readErr
+		if err == nil && b.request.StatusCode >= 400 {
+			err = fmt.Errorf("HTTP %d", b.request.StatusCode)
+		}
+		trackingContext := GetDimensionsFromContext(b.ctx)
+		if errors.Is(err, ErrStreamAborted) && b.request.OutputTokens == 0 && b.textLen > 0 {
+			b.request.OutputTokens = estimateTokens(b.textLen)
+			trackingContext[DimensionUsageEstimated] = true
+		}
+		b.rateLimits.apply(b.request)
+		// The request context is canceled when the caller aborts the stream
+		b.transport.client.track(context.WithoutCancel(b.ctx), b.request, err, trackingContext)
+	})
+}
+
+// abortError marks stream read errors caused by canceling ctx as ErrStreamAborted
+func abortError(ctx context.Context, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
+		return fmt.Errorf("%w: %v", ErrStreamAborted, err)
+	}
+	return err
+}
+
+// estimateTokens approximates the token count of text from its length, using the common
+// rule of thumb of four characters per token
+func estimateTokens(textLen int) int64 {
+	return (int64(textLen) + 3) / 4
+}
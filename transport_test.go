@@ -0,0 +1,188 @@
+package llmtracer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracingTransport(t *testing.T) {
+	newServer := func(contentType string, status int, body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("X-Request-Id", "req_transport")
+			w.Header().Set("Request-Id", "req_anthropic")
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+		}))
+	}
+
+	t.Run("OpenAI chat completion", func(t *testing.T) {
+		server := newServer("application/json", http.StatusOK,
+			`{"id":"chatcmpl-1","usage":{"prompt_tokens":12,"completion_tokens":30,"total_tokens":42}}`)
+		defer server.Close()
+
+		storage := &MockStorageAdapter{}
+		transport := NewTracingTransport(NewClient(storage), nil)
+
+		resp, err := transport.HTTPClient().Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Contains(t, string(body), "chatcmpl-1", "response body should be passed through")
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, ProviderOpenAI, saved.Provider)
+		assert.Equal(t, "gpt-4o", saved.Model)
+		assert.Equal(t, int64(12), saved.InputTokens)
+		assert.Equal(t, int64(30), saved.OutputTokens)
+		assert.Equal(t, 1, saved.MessageCount)
+		assert.Equal(t, "req_transport", saved.ProviderRequestID)
+		assert.Equal(t, 200, saved.StatusCode)
+	})
+
+	t.Run("Anthropic error response", func(t *testing.T) {
+		server := newServer("application/json", 529,
+			`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+		defer server.Close()
+
+		storage := &MockStorageAdapter{}
+		transport := NewTracingTransport(NewClient(storage), nil)
+
+		resp, err := transport.HTTPClient().Post(server.URL+"/v1/messages", "application/json",
+			strings.NewReader(`{"model":"claude-3-5-haiku-latest","messages":[]}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, ProviderAnthropic, saved.Provider)
+		assert.Equal(t, 529, saved.StatusCode)
+		assert.Equal(t, "overloaded_error", saved.ProviderErrorType)
+		assert.Equal(t, "req_anthropic", saved.ProviderRequestID)
+		assert.Contains(t, saved.Error, "Overloaded")
+	})
+
+	t.Run("Gemini model from path", func(t *testing.T) {
+		server := newServer("application/json", http.StatusOK,
+			`{"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":9}}`)
+		defer server.Close()
+
+		storage := &MockStorageAdapter{}
+		transport := NewTracingTransport(NewClient(storage), nil)
+
+		resp, err := transport.HTTPClient().Post(server.URL+"/v1beta/models/gemini-1.5-flash:generateContent", "application/json",
+			strings.NewReader(`{"contents":[{"parts":[{"text":"hi"}]}]}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, ProviderGoogle, saved.Provider)
+		assert.Equal(t, "gemini-1.5-flash", saved.Model)
+		assert.Equal(t, int64(4), saved.InputTokens)
+		assert.Equal(t, int64(9), saved.OutputTokens)
+	})
+
+	t.Run("Azure Content Safety operation from path", func(t *testing.T) {
+		server := newServer("application/json", http.StatusOK,
+			`{"categoriesAnalysis":[{"category":"Hate","severity":0}]}`)
+		defer server.Close()
+
+		pricing := NewPricingRegistry()
+		pricing.Set(ProviderAzure, "text:analyze", ModelPrice{PerRequest: 0.001})
+		storage := &MockStorageAdapter{}
+		transport := NewTracingTransport(NewClient(storage, WithPricing(pricing)), nil)
+
+		resp, err := transport.HTTPClient().Post(server.URL+"/contentsafety/text:analyze?api-version=2024-09-01", "application/json",
+			strings.NewReader(`{"text":"hi"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, ProviderAzure, saved.Provider)
+		assert.Equal(t, "text:analyze", saved.Model)
+		assert.Equal(t, RequestTypeModeration, saved.RequestType)
+		assert.Equal(t, 0.001, saved.Cost)
+	})
+
+	t.Run("streaming usage is tracked at end of stream", func(t *testing.T) {
+		stream := "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+			"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":2}}\n\n" +
+			"data: [DONE]\n\n"
+		server := newServer("text/event-stream", http.StatusOK, stream)
+		defer server.Close()
+
+		storage := &MockStorageAdapter{}
+		transport := NewTracingTransport(NewClient(storage), nil)
+
+		resp, err := transport.HTTPClient().Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"gpt-4o-mini","stream":true}`))
+		require.NoError(t, err)
+		assert.Empty(t, storage.SaveCalls, "stream should not be tracked before it is read")
+
+		_, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, int64(7), saved.InputTokens)
+		assert.Equal(t, int64(2), saved.OutputTokens)
+	})
+
+	t.Run("streams closed mid-generation are tracked as aborted", func(t *testing.T) {
+		stream := "event: message_start\n" +
+			"data: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":25,\"output_tokens\":1}}}\n\n" +
+			"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello there\"}}\n\n"
+		// The server keeps the stream open until the client goes away
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, stream)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+
+		storage := &MockStorageAdapter{}
+		transport := NewTracingTransport(NewClient(storage), nil)
+
+		resp, err := transport.HTTPClient().Post(server.URL+"/v1/messages", "application/json",
+			strings.NewReader(`{"model":"claude-3-5-sonnet","stream":true}`))
+		require.NoError(t, err)
+		buf := make([]byte, len(stream))
+		_, err = io.ReadFull(resp.Body, buf)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Len(t, storage.SaveCalls, 1)
+		saved := storage.SaveCalls[0].Request
+		assert.Equal(t, ErrorTypeAborted, saved.ErrorType)
+		assert.Equal(t, StatusClientClosedRequest, saved.StatusCode)
+		assert.Equal(t, int64(25), saved.InputTokens)
+		assert.Equal(t, int64(1), saved.OutputTokens, "reported partial usage is kept")
+	})
+
+	t.Run("unrecognized endpoints are not tracked", func(t *testing.T) {
+		server := newServer("application/json", http.StatusOK, `{}`)
+		defer server.Close()
+
+		storage := &MockStorageAdapter{}
+		transport := NewTracingTransport(NewClient(storage), nil)
+
+		resp, err := transport.HTTPClient().Get(server.URL + "/v1/models")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Empty(t, storage.SaveCalls)
+	})
+}
@@ -2,6 +2,7 @@ package llmtracer
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 )
@@ -13,8 +14,57 @@ const (
 	ProviderAnthropic Provider = "anthropic"
 	ProviderGoogle    Provider = "google"
 	ProviderMistral   Provider = "mistral"
+	ProviderAzure     Provider = "azure"
 )
 
+// RequestType distinguishes generation calls from auxiliary endpoints
+type RequestType string
+
+const (
+	// RequestTypeChat is a chat or text generation call
+	RequestTypeChat RequestType = "chat"
+	// RequestTypeEmbedding is an embeddings call
+	RequestTypeEmbedding RequestType = "embedding"
+	// RequestTypeTokenCount is a token counting call
+	RequestTypeTokenCount RequestType = "count_tokens"
+	// RequestTypeModeration is a content moderation or safety check
+	RequestTypeModeration RequestType = "moderation"
+)
+
+// DimensionCountedTokens holds the result of a token counting call. Counted tokens are not
+// recorded as usage since counting does not consume them.
+const DimensionCountedTokens = "counted_tokens"
+
+// DimensionUsageEstimated marks requests whose token counts were estimated from text
+// because the provider did not report usage
+const DimensionUsageEstimated = "usage_estimated"
+
+// Finish reasons recorded in Request.FinishReason. Providers name them differently, e.g.
+// Anthropic's max_tokens and Gemini's MAX_TOKENS are both recorded as FinishReasonLength;
+// reasons without a common name are recorded lowercased as the provider reported them.
+const (
+	FinishReasonStop          = "stop"
+	FinishReasonLength        = "length"
+	FinishReasonToolCalls     = "tool_calls"
+	FinishReasonContentFilter = "content_filter"
+)
+
+// normalizeFinishReason maps a provider's finish reason to the common names
+func normalizeFinishReason(reason string) string {
+	reason = strings.ToLower(reason)
+	switch reason {
+	case "end_turn", "stop_sequence", "completed":
+		return FinishReasonStop
+	case "max_tokens", "max_output_tokens", "model_length":
+		return FinishReasonLength
+	case "tool_use", "function_call":
+		return FinishReasonToolCalls
+	case "safety", "refusal":
+		return FinishReasonContentFilter
+	}
+	return reason
+}
+
 // ErrorType represents the category of error that occurred
 type ErrorType string
 
@@ -35,128 +85,422 @@ const (
 	ErrorTypeServerError ErrorType = "server_error"
 	// ErrorTypeUnknown indicates an unknown error type
 	ErrorTypeUnknown ErrorType = "unknown"
+	// ErrorTypeAborted indicates a stream was canceled by the caller before it ended
+	ErrorTypeAborted ErrorType = "aborted"
+	// ErrorTypeContentFilter indicates the provider's content policy blocked the request
+	ErrorTypeContentFilter ErrorType = "content_filter"
+	// ErrorTypeContextLength indicates the request exceeded the model's context window
+	ErrorTypeContextLength ErrorType = "context_length"
+	// ErrorTypeQuota indicates the account's quota or credit is exhausted, which, unlike
+	// rate limits, doesn't clear by waiting
+	ErrorTypeQuota ErrorType = "quota"
+	// ErrorTypeOverloaded indicates the provider was temporarily overloaded, e.g.
+	// Anthropic's 529 overloaded_error
+	ErrorTypeOverloaded ErrorType = "overloaded"
 )
 
+// StatusClientClosedRequest is the status code recorded for aborted streams, following
+// nginx's 499 Client Closed Request
+const StatusClientClosedRequest = 499
+
+// ErrStreamAborted is tracked for streams that are closed or whose context is canceled
+// before the provider finished the generation
+var ErrStreamAborted = errors.New("stream aborted")
+
 // Circuit breaker errors
 var (
 	// ErrCircuitOpen is returned when the circuit breaker is open
 	ErrCircuitOpen = errors.New("circuit breaker is open")
 )
 
+// ErrClientClosed is returned when using a client after Close
+var ErrClientClosed = errors.New("client is closed")
+
 type Request struct {
-	ID           string         `json:"id" gorm:"primaryKey"`
-	TraceID      string         `json:"trace_id" gorm:"index"`
-	Provider     Provider       `json:"provider" gorm:"index"`
-	Model        string         `json:"model" gorm:"index"`
-	InputTokens  int            `json:"input_tokens"`
-	OutputTokens int            `json:"output_tokens"`
-	Latency      time.Duration  `json:"latency"`
-	StatusCode   int            `json:"status_code"`
-	Error        string         `json:"error,omitempty"`
-	ErrorType    ErrorType      `json:"error_type,omitempty" gorm:"index"`
-	Dimensions   []DimensionTag `json:"dimensions,omitempty" gorm:"many2many:request_dimensions;"`
-	RequestedAt  time.Time      `json:"requested_at" gorm:"index"`
-	RespondedAt  time.Time      `json:"responded_at"`
-	CreatedAt    time.Time      `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt    time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	ID            string      `json:"id" gorm:"primaryKey"`
+	TraceID       string      `json:"trace_id" gorm:"index"`
+	Provider      Provider    `json:"provider" gorm:"index"`
+	Model         string      `json:"model" gorm:"index"`
+	RequestType   RequestType `json:"request_type" gorm:"index"`
+	PromptVersion string      `json:"prompt_version,omitempty" gorm:"index"`
+	InputTokens   int64       `json:"input_tokens"`
+	OutputTokens  int64       `json:"output_tokens"`
+	TotalTokens   int64       `json:"total_tokens"`
+	// CachedInputTokens, ReasoningTokens, Images and AudioSeconds break usage down further
+	// where the provider reports it; see Usage
+	CachedInputTokens int64   `json:"cached_input_tokens,omitempty"`
+	ReasoningTokens   int64   `json:"reasoning_tokens,omitempty"`
+	Images            int     `json:"images,omitempty"`
+	AudioSeconds      float64 `json:"audio_seconds,omitempty"`
+	Cost              float64 `json:"cost"`
+	// RecomputedCost is the cost at the prices current when the request was read, set on
+	// reads through NewRepricedStorage when the model has a price
+	RecomputedCost *float64 `json:"recomputed_cost,omitempty" gorm:"-"`
+	// Latency is not stored directly, since databases disagree on how to store durations;
+	// adapters store LatencyMs and restore Latency from it
+	Latency   time.Duration `json:"latency" gorm:"-"`
+	LatencyMs int64         `json:"latency_ms"`
+	// QueueWait is how long the call waited before it was sent, for a slot under
+	// WithConcurrencyLimit or for WithModelRateLimits; adapters store QueueWaitMs like
+	// LatencyMs
+	QueueWait         time.Duration `json:"queue_wait,omitempty" gorm:"-"`
+	QueueWaitMs       int64         `json:"queue_wait_ms,omitempty"`
+	StatusCode        int           `json:"status_code"`
+	Error             string        `json:"error,omitempty"`
+	ErrorType         ErrorType     `json:"error_type,omitempty" gorm:"index"`
+	ProviderRequestID string        `json:"provider_request_id,omitempty" gorm:"index"`
+	ProviderErrorCode string        `json:"provider_error_code,omitempty"`
+	ProviderErrorType string        `json:"provider_error_type,omitempty"`
+	// RetryAfter is how long the provider asked callers to wait, from the Retry-After
+	// header of a failed response; adapters store RetryAfterMs like LatencyMs
+	RetryAfter   time.Duration `json:"retry_after,omitempty" gorm:"-"`
+	RetryAfterMs int64         `json:"retry_after_ms,omitempty"`
+	// RateLimits is the rate-limit state the provider reported on the response, for
+	// investigating 429s after the fact
+	RateLimits    RateLimitSnapshot `json:"rate_limits" gorm:"embedded;embeddedPrefix:ratelimit_"`
+	FinishReason  string            `json:"finish_reason,omitempty" gorm:"index"`
+	Params        GenerationParams  `json:"params" gorm:"embedded;embeddedPrefix:param_"`
+	MessageCount  int               `json:"message_count"`
+	RequestBytes  int               `json:"request_bytes"`
+	ResponseBytes int               `json:"response_bytes"`
+	// RequestPayload is the JSON request sent to the provider, captured with WithPayloadCapture
+	RequestPayload string         `json:"request_payload,omitempty" gorm:"type:text"`
+	Dimensions     []DimensionTag `json:"dimensions,omitempty" gorm:"many2many:request_dimensions;"`
+	RequestedAt    time.Time      `json:"requested_at" gorm:"index"`
+	RespondedAt    time.Time      `json:"responded_at"`
+	CreatedAt      time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	// DeletedAt is set when the request is soft deleted by adapters that support it
+	DeletedAt *time.Time `json:"deleted_at,omitempty" gorm:"index"`
+	// Pending is set on the row written when a call starts under WithLifecycleTracking,
+	// until the call completes
+	Pending bool `json:"pending,omitempty" gorm:"not null;default:false;index"`
+
+	// pendingWrite is the write of the pending row, while lifecycle tracking completes it
+	pendingWrite *pendingWrite
+	// costSet is set when the caller supplied Cost, which is then kept even when zero
+	costSet bool
 }
 
-type RequestFilter struct {
-	TraceID    string
-	Provider   Provider
-	Model      string
-	ErrorType  ErrorType
-	StartTime  *time.Time
-	EndTime    *time.Time
-	Dimensions []DimensionTag
-	MinTokens  *int
-	MaxTokens  *int
-	HasError   *bool
-	Limit      int
-	Offset     int
-	OrderBy    string
-	OrderDesc  bool
+// Dimension returns the value of the dimension with the given key, or "" when it is not set
+// or the request is nil
+func (r *Request) Dimension(key string) string {
+	value, _ := r.LookupDimension(key)
+	return value
 }
 
-type AggregateResult struct {
-	Provider      Provider       `json:"provider"`
-	Model         string         `json:"model"`
-	TotalRequests int64          `json:"total_requests"`
-	TotalTokens   int64          `json:"total_tokens"`
-	AvgLatency    time.Duration  `json:"avg_latency"`
-	ErrorCount    int64          `json:"error_count"`
-	Dimensions    []DimensionTag `json:"dimensions"`
+// LookupDimension returns the value of the dimension with the given key and whether it is
+// set, distinguishing unset dimensions from empty values
+func (r *Request) LookupDimension(key string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	return lookupDimension(r.Dimensions, key)
 }
 
-type DimensionTag struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Key       string    `json:"key" gorm:"uniqueIndex:idx_key_value;size:100"`
-	Value     string    `json:"value" gorm:"uniqueIndex:idx_key_value;size:255"`
-	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+// Region returns the region the request was served from, from the region dimension
+func (r *Request) Region() string {
+	return r.Dimension(DimensionRegion)
+}
+
+// DimensionsMap returns the request's dimensions keyed by dimension key. The map is a new
+// copy on every call, so callers may modify it, and reading dimensions this way is safe
+// while other goroutines read the same request, as watch subscribers do.
+func (r *Request) DimensionsMap() map[string]string {
+	if r == nil {
+		return map[string]string{}
+	}
+	return dimensionMap(r.Dimensions)
+}
+
+// lookupDimension finds the value of key in dimensions
+func lookupDimension(dimensions []DimensionTag, key string) (string, bool) {
+	for _, dim := range dimensions {
+		if dim.Key == key {
+			return dim.Value, true
+		}
+	}
+	return "", false
+}
+
+// dimensionMap copies dimensions into a map keyed by dimension key
+func dimensionMap(dimensions []DimensionTag) map[string]string {
+	m := make(map[string]string, len(dimensions))
+	for _, dim := range dimensions {
+		m[dim.Key] = dim.Value
+	}
+	return m
 }
 
-// CategorizeError analyzes an error message and returns the appropriate ErrorType
-func CategorizeError(err error) ErrorType {
-	if err == nil {
-		return ErrorTypeNone
+// GenerationParams holds the key sampling parameters of an outgoing request.
+// Pointer fields are nil when the parameter was not set on the request.
+type GenerationParams struct {
+	Temperature    *float64 `json:"temperature,omitempty"`
+	TopP           *float64 `json:"top_p,omitempty"`
+	MaxTokens      *int     `json:"max_tokens,omitempty"`
+	ToolCount      int      `json:"tool_count,omitempty"`
+	ResponseFormat string   `json:"response_format,omitempty"`
+}
+
+// RateLimitSnapshot holds the remaining counts and reset times of the rate limits a
+// provider reported on a response. Pointer fields are nil when they were not reported.
+type RateLimitSnapshot struct {
+	RemainingRequests *int       `json:"remaining_requests,omitempty"`
+	RemainingTokens   *int       `json:"remaining_tokens,omitempty"`
+	RequestsResetAt   *time.Time `json:"requests_reset_at,omitempty"`
+	TokensResetAt     *time.Time `json:"tokens_reset_at,omitempty"`
+}
+
+type RequestFilter struct {
+	// TraceID is compared as returned by NormalizeTraceID
+	TraceID           string
+	ProviderRequestID string
+	Provider          Provider
+	Model             string
+	RequestType       RequestType
+	PromptVersion     string
+	ErrorType         ErrorType
+	StatusCodes       []int
+	StartTime         *time.Time
+	EndTime           *time.Time
+	Dimensions        []DimensionTag
+	MinTokens         *int64
+	MaxTokens         *int64
+	HasError          *bool
+	Limit             int
+	Offset            int
+	OrderBy           string
+	OrderDesc         bool
+	// IncludeDeleted also returns soft-deleted requests
+	IncludeDeleted bool
+	// InFlight selects only pending requests, which are otherwise excluded
+	InFlight bool
+	// SearchText selects requests whose error or captured request payload contains every
+	// term, ignoring case; quote a phrase to search for it as one term, e.g.
+	// `"model not found" gpt-4`. Storage wrapped by WithEncryption stores them as
+	// ciphertext, so reads with SearchText fail with ErrSearchNotSupported.
+	SearchText string
+	// MinLatency and MaxLatency bound the request latency, inclusively. Adapters compare
+	// whole milliseconds, as latencies are stored.
+	MinLatency *time.Duration
+	MaxLatency *time.Duration
+	// AggregateOrderBy sorts Aggregate results by a total, largest first unless
+	// AggregateAscending is set, and AggregateLimit keeps only the first groups, e.g. the
+	// top 20 models by spend. Query ignores them.
+	AggregateOrderBy   AggregateOrder
+	AggregateAscending bool
+	AggregateLimit     int
+}
+
+// Matches reports whether a request satisfies the filter's criteria. A nil filter matches
+// every completed request that is not soft deleted; Limit, Offset and ordering are
+// ignored. Token bounds apply to the total of input and output tokens.
+func (f *RequestFilter) Matches(r *Request) bool {
+	if f == nil {
+		return r.DeletedAt == nil && !r.Pending
 	}
 
-	errStr := strings.ToLower(err.Error())
+	if !f.IncludeDeleted && r.DeletedAt != nil {
+		return false
+	}
+	if r.Pending != f.InFlight {
+		return false
+	}
+
+	if f.TraceID != "" && r.TraceID != NormalizeTraceID(f.TraceID) {
+		return false
+	}
+	if f.ProviderRequestID != "" && r.ProviderRequestID != f.ProviderRequestID {
+		return false
+	}
+	if f.Provider != "" && r.Provider != f.Provider {
+		return false
+	}
+	if f.Model != "" && r.Model != f.Model {
+		return false
+	}
+	if f.RequestType != "" && r.RequestType != f.RequestType {
+		return false
+	}
+	if f.PromptVersion != "" && r.PromptVersion != f.PromptVersion {
+		return false
+	}
+	if f.ErrorType != "" && r.ErrorType != f.ErrorType {
+		return false
+	}
+	if len(f.StatusCodes) > 0 {
+		found := false
+		for _, code := range f.StatusCodes {
+			if r.StatusCode == code {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.StartTime != nil && r.RequestedAt.Before(*f.StartTime) {
+		return false
+	}
+	if f.EndTime != nil && r.RequestedAt.After(*f.EndTime) {
+		return false
+	}
 
-	// Check for rate limit errors
-	if strings.Contains(errStr, "rate limit") ||
-		strings.Contains(errStr, "too many requests") ||
-		strings.Contains(errStr, "429") {
-		return ErrorTypeRateLimit
+	totalTokens := AddTokens(r.InputTokens, r.OutputTokens)
+	if f.MinTokens != nil && totalTokens < *f.MinTokens {
+		return false
+	}
+	if f.MaxTokens != nil && totalTokens > *f.MaxTokens {
+		return false
+	}
+	latencyMs := r.LatencyMs
+	if r.Latency != 0 {
+		latencyMs = r.Latency.Milliseconds()
+	}
+	if f.MinLatency != nil && latencyMs < f.MinLatency.Milliseconds() {
+		return false
+	}
+	if f.MaxLatency != nil && latencyMs > f.MaxLatency.Milliseconds() {
+		return false
+	}
+	if f.HasError != nil && (r.Error != "") != *f.HasError {
+		return false
 	}
 
-	// Check for authentication errors
-	if strings.Contains(errStr, "unauthorized") ||
-		strings.Contains(errStr, "authentication") ||
-		strings.Contains(errStr, "api key") ||
-		strings.Contains(errStr, "401") ||
-		strings.Contains(errStr, "403") ||
-		strings.Contains(errStr, "forbidden") {
-		return ErrorTypeAuthentication
+	for _, dim := range f.Dimensions {
+		if r.Dimension(dim.Key) != dim.Value {
+			return false
+		}
 	}
 
-	// Check for network errors (before timeout to handle "dial tcp: connection timeout" correctly)
-	if strings.Contains(errStr, "connection") ||
-		strings.Contains(errStr, "network") ||
-		strings.Contains(errStr, "dial tcp") ||
-		strings.Contains(errStr, "dns") ||
-		strings.Contains(errStr, "no such host") {
-		return ErrorTypeNetwork
+	if f.SearchText != "" {
+		searched := strings.ToLower(r.Error + "\n" + r.RequestPayload)
+		for _, term := range SearchTerms(f.SearchText) {
+			if !strings.Contains(searched, strings.ToLower(term)) {
+				return false
+			}
+		}
 	}
 
-	// Check for timeout errors (after network to avoid false positives)
-	if strings.Contains(errStr, "timeout") ||
-		strings.Contains(errStr, "deadline exceeded") ||
-		strings.Contains(errStr, "context canceled") {
-		// Special case: 504 Gateway Timeout is a server error
-		if strings.Contains(errStr, "504") {
-			return ErrorTypeServerError
+	return true
+}
+
+// SearchTerms splits the SearchText of a RequestFilter into its terms: words separated by
+// whitespace, and double-quoted phrases
+func SearchTerms(text string) []string {
+	var terms []string
+	for i, part := range strings.Split(text, `"`) {
+		if i%2 == 1 {
+			if phrase := strings.Join(strings.Fields(part), " "); phrase != "" {
+				terms = append(terms, phrase)
+			}
+			continue
 		}
-		return ErrorTypeTimeout
+		terms = append(terms, strings.Fields(part)...)
 	}
+	return terms
+}
 
-	// Check for invalid request errors
-	if strings.Contains(errStr, "invalid") ||
-		strings.Contains(errStr, "bad request") ||
-		strings.Contains(errStr, "400") ||
-		strings.Contains(errStr, "malformed") {
-		return ErrorTypeInvalidRequest
+type AggregateResult struct {
+	Provider           Provider      `json:"provider"`
+	Model              string        `json:"model"`
+	RequestType        RequestType   `json:"request_type,omitempty"`
+	PromptVersion      string        `json:"prompt_version,omitempty"`
+	StatusCode         int           `json:"status_code,omitempty"`
+	StatusClass        string        `json:"status_class,omitempty"`
+	ErrorType          ErrorType     `json:"error_type,omitempty"`
+	TotalRequests      int64         `json:"total_requests"`
+	TotalTokens        int64         `json:"total_tokens"`
+	TotalInputTokens   int64         `json:"total_input_tokens"`
+	TotalOutputTokens  int64         `json:"total_output_tokens"`
+	TotalCost          float64       `json:"total_cost"`
+	AvgLatency         time.Duration `json:"avg_latency"`
+	ErrorCount         int64         `json:"error_count"`
+	SuccessRate        float64       `json:"success_rate"`
+	TotalMessages      int64         `json:"total_messages"`
+	TotalRequestBytes  int64         `json:"total_request_bytes"`
+	TotalResponseBytes int64         `json:"total_response_bytes"`
+	AvgMessageCount    float64       `json:"avg_message_count"`
+	AvgRequestBytes    float64       `json:"avg_request_bytes"`
+	AvgResponseBytes   float64       `json:"avg_response_bytes"`
+	// CostPer1KTokens and OutputTokensPerSecond compare the efficiency of models; see
+	// ComputeEfficiency
+	CostPer1KTokens       float64        `json:"cost_per_1k_tokens"`
+	OutputTokensPerSecond float64        `json:"output_tokens_per_second"`
+	Dimensions            []DimensionTag `json:"dimensions"`
+	// IncidentActive and Incidents are set by Client.AnnotateIncidents
+	IncidentActive bool                `json:"incident_active,omitempty"`
+	Incidents      []*ProviderIncident `json:"incidents,omitempty"`
+}
+
+// Dimension returns the grouped value of the dimension with the given key, or "" when the
+// result is not grouped by it
+func (r *AggregateResult) Dimension(key string) string {
+	if r == nil {
+		return ""
+	}
+	value, _ := lookupDimension(r.Dimensions, key)
+	return value
+}
+
+// ComputeEfficiency sets CostPer1KTokens, the cost of a thousand tokens, input and output
+// together, and OutputTokensPerSecond, the output tokens generated per second spent waiting
+// on the provider across all requests of the group, including failed ones. They are zero
+// when the group has no tokens or no latency. Adapters call it once the totals and
+// AvgLatency are set.
+func (r *AggregateResult) ComputeEfficiency() {
+	if r == nil {
+		return
+	}
+	r.CostPer1KTokens = 0
+	if r.TotalTokens > 0 {
+		r.CostPer1KTokens = r.TotalCost / float64(r.TotalTokens) * 1000
+	}
+	r.OutputTokensPerSecond = 0
+	if latency := r.AvgLatency.Seconds() * float64(r.TotalRequests); latency > 0 {
+		r.OutputTokensPerSecond = float64(r.TotalOutputTokens) / latency
+	}
+}
+
+// DimensionsMap returns the grouped dimension values keyed by dimension key
+func (r *AggregateResult) DimensionsMap() map[string]string {
+	if r == nil {
+		return map[string]string{}
+	}
+	return dimensionMap(r.Dimensions)
+}
+
+// StatusClass returns the class of an HTTP status code as used by the "status_class"
+// Aggregate group, e.g. "4xx", or "" for codes outside 100-599
+func StatusClass(code int) string {
+	if code < 100 || code > 599 {
+		return ""
 	}
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// dimensionGroupPrefix marks an Aggregate groupBy entry as a dimension key rather than a column
+const dimensionGroupPrefix = "dimension:"
 
-	// Check for server errors
-	if strings.Contains(errStr, "500") ||
-		strings.Contains(errStr, "502") ||
-		strings.Contains(errStr, "503") ||
-		strings.Contains(errStr, "504") ||
-		strings.Contains(errStr, "server error") ||
-		strings.Contains(errStr, "internal error") {
-		return ErrorTypeServerError
+// GroupByDimension returns an Aggregate groupBy entry that groups results by the value
+// of the given dimension key (e.g. GroupByDimension("feature")). The grouped values are
+// returned in AggregateResult.Dimensions.
+func GroupByDimension(key string) string {
+	return dimensionGroupPrefix + key
+}
+
+// DimensionGroupKey returns the dimension key of a groupBy entry created by GroupByDimension
+func DimensionGroupKey(groupBy string) (string, bool) {
+	if !strings.HasPrefix(groupBy, dimensionGroupPrefix) {
+		return "", false
 	}
+	return strings.TrimPrefix(groupBy, dimensionGroupPrefix), true
+}
 
-	return ErrorTypeUnknown
+type DimensionTag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Key       string    `json:"key" gorm:"uniqueIndex:idx_key_value;size:100"`
+	Value     string    `json:"value" gorm:"uniqueIndex:idx_key_value;size:255"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 }
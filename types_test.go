@@ -143,6 +143,41 @@ func TestCategorizeError(t *testing.T) {
 			err:      errors.New("RATE LIMIT EXCEEDED"),
 			expected: ErrorTypeRateLimit,
 		},
+		{
+			name:     "quota - insufficient quota reported as 429",
+			err:      errors.New("error, status code: 429, message: You exceeded your current quota, please check your plan and billing details"),
+			expected: ErrorTypeQuota,
+		},
+		{
+			name:     "quota - anthropic credit balance",
+			err:      errors.New("400 Bad Request: Your credit balance is too low to access the Anthropic API"),
+			expected: ErrorTypeQuota,
+		},
+		{
+			name:     "content filter - azure",
+			err:      errors.New("400: The response was filtered due to the prompt triggering Azure OpenAI's content management policy"),
+			expected: ErrorTypeContentFilter,
+		},
+		{
+			name:     "context length - openai",
+			err:      errors.New("400 invalid_request_error: This model's maximum context length is 128000 tokens (context_length_exceeded)"),
+			expected: ErrorTypeContextLength,
+		},
+		{
+			name:     "context length - anthropic",
+			err:      errors.New("invalid_request_error: prompt is too long: 210000 tokens > 200000 maximum"),
+			expected: ErrorTypeContextLength,
+		},
+		{
+			name:     "overloaded - anthropic",
+			err:      errors.New("529 Overloaded: {\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}"),
+			expected: ErrorTypeOverloaded,
+		},
+		{
+			name:     "overloaded - google",
+			err:      errors.New("googleapi: Error 503: The model is overloaded. Please try again later."),
+			expected: ErrorTypeOverloaded,
+		},
 	}
 
 	for _, tt := range tests {
@@ -164,6 +199,11 @@ func TestErrorTypeConstants(t *testing.T) {
 		ErrorTypeTimeout,
 		ErrorTypeServerError,
 		ErrorTypeUnknown,
+		ErrorTypeAborted,
+		ErrorTypeContentFilter,
+		ErrorTypeContextLength,
+		ErrorTypeQuota,
+		ErrorTypeOverloaded,
 	}
 
 	seen := make(map[ErrorType]bool)
@@ -176,3 +216,48 @@ func TestErrorTypeConstants(t *testing.T) {
 func TestCircuitBreakerError(t *testing.T) {
 	assert.Equal(t, "circuit breaker is open", ErrCircuitOpen.Error())
 }
+
+func TestStatusClass(t *testing.T) {
+	assert.Equal(t, "2xx", StatusClass(200))
+	assert.Equal(t, "4xx", StatusClass(429))
+	assert.Equal(t, "5xx", StatusClass(503))
+	assert.Equal(t, "", StatusClass(0))
+	assert.Equal(t, "", StatusClass(600))
+}
+
+func TestDimensionHelpers(t *testing.T) {
+	request := &Request{Dimensions: []DimensionTag{
+		{Key: "user_id", Value: "u-1"},
+		{Key: "feature", Value: ""},
+	}}
+
+	assert.Equal(t, "u-1", request.Dimension("user_id"))
+	value, ok := request.LookupDimension("feature")
+	assert.True(t, ok)
+	assert.Empty(t, value)
+	_, ok = request.LookupDimension("tenant")
+	assert.False(t, ok)
+
+	dims := request.DimensionsMap()
+	assert.Equal(t, map[string]string{"user_id": "u-1", "feature": ""}, dims)
+	dims["user_id"] = "changed"
+	assert.Equal(t, "u-1", request.Dimension("user_id"), "DimensionsMap returns a copy")
+
+	var missing *Request
+	assert.Empty(t, missing.Dimension("user_id"))
+	assert.Empty(t, missing.DimensionsMap())
+
+	result := &AggregateResult{Dimensions: []DimensionTag{{Key: "org_id", Value: "acme"}}}
+	assert.Equal(t, "acme", result.Dimension("org_id"))
+	assert.Empty(t, result.Dimension("user_id"))
+	assert.Equal(t, map[string]string{"org_id": "acme"}, result.DimensionsMap())
+}
+
+func TestRequestFilterSearchText(t *testing.T) {
+	assert.Equal(t, []string{"model_not_found", "rate limit", "gpt-4o"}, SearchTerms(`model_not_found "rate  limit" gpt-4o ""`))
+
+	request := &Request{Error: "Rate limit reached for gpt-4o", RequestPayload: `{"model":"gpt-4o"}`}
+	assert.True(t, (&RequestFilter{SearchText: `"rate limit" GPT-4O`}).Matches(request))
+	assert.True(t, (&RequestFilter{SearchText: `"model":`}).Matches(request), "payloads are searched")
+	assert.False(t, (&RequestFilter{SearchText: "rate model_not_found"}).Matches(request), "every term must match")
+}
@@ -0,0 +1,76 @@
+package llmtracer
+
+import "math"
+
+// Usage is the resource usage of a call in provider-neutral terms. The trace wrappers map
+// each provider's usage report into it, so supporting a new provider only takes a
+// mapping function; callers tracking calls themselves can pass it in RequestOptions.
+type Usage struct {
+	// InputTokens counts every prompt token, including cached ones
+	InputTokens int64 `json:"input_tokens"`
+	// OutputTokens counts every generated token, including reasoning tokens
+	OutputTokens int64 `json:"output_tokens"`
+	// CachedInputTokens are the input tokens read from the provider's prompt cache
+	CachedInputTokens int64 `json:"cached_input_tokens,omitempty"`
+	// ReasoningTokens are the output tokens spent on hidden reasoning
+	ReasoningTokens int64 `json:"reasoning_tokens,omitempty"`
+	// Images is the number of images generated
+	Images int `json:"images,omitempty"`
+	// AudioSeconds is the duration of audio transcribed or generated
+	AudioSeconds float64 `json:"audio_seconds,omitempty"`
+}
+
+// TotalTokens returns the input and output tokens combined
+func (u Usage) TotalTokens() int64 {
+	return AddTokens(u.InputTokens, u.OutputTokens)
+}
+
+// Add returns the sum of two usages
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		InputTokens:       AddTokens(u.InputTokens, other.InputTokens),
+		OutputTokens:      AddTokens(u.OutputTokens, other.OutputTokens),
+		CachedInputTokens: AddTokens(u.CachedInputTokens, other.CachedInputTokens),
+		ReasoningTokens:   AddTokens(u.ReasoningTokens, other.ReasoningTokens),
+		Images:            u.Images + other.Images,
+		AudioSeconds:      u.AudioSeconds + other.AudioSeconds,
+	}
+}
+
+// Usage returns the usage recorded on the request
+func (r *Request) Usage() Usage {
+	return Usage{
+		InputTokens:       r.InputTokens,
+		OutputTokens:      r.OutputTokens,
+		CachedInputTokens: r.CachedInputTokens,
+		ReasoningTokens:   r.ReasoningTokens,
+		Images:            r.Images,
+		AudioSeconds:      r.AudioSeconds,
+	}
+}
+
+// SetUsage records usage on the request, replacing its token counts
+func (r *Request) SetUsage(usage Usage) {
+	r.InputTokens = usage.InputTokens
+	r.OutputTokens = usage.OutputTokens
+	r.CachedInputTokens = usage.CachedInputTokens
+	r.ReasoningTokens = usage.ReasoningTokens
+	r.Images = usage.Images
+	r.AudioSeconds = usage.AudioSeconds
+}
+
+// AddTokens returns the sum of token counts, saturating at the bounds of int64 instead of
+// wrapping around, so long-running totals never turn negative
+func AddTokens(counts ...int64) int64 {
+	var total int64
+	for _, n := range counts {
+		switch {
+		case n > 0 && total > math.MaxInt64-n:
+			return math.MaxInt64
+		case n < 0 && total < math.MinInt64-n:
+			return math.MinInt64
+		}
+		total += n
+	}
+	return total
+}
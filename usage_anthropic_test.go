@@ -0,0 +1,15 @@
+//go:build !llmtracer_no_anthropic
+
+package llmtracer
+
+import (
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnthropicUsage(t *testing.T) {
+	usage := AnthropicUsage(anthropic.Usage{InputTokens: 10, CacheReadInputTokens: 200, CacheCreationInputTokens: 50, OutputTokens: 20})
+	assert.Equal(t, Usage{InputTokens: 260, OutputTokens: 20, CachedInputTokens: 200}, usage)
+}
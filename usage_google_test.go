@@ -0,0 +1,16 @@
+//go:build !llmtracer_no_google
+
+package llmtracer
+
+import (
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoogleUsage(t *testing.T) {
+	usage := GoogleUsage(&genai.UsageMetadata{PromptTokenCount: 40, CachedContentTokenCount: 32, CandidatesTokenCount: 8})
+	assert.Equal(t, Usage{InputTokens: 40, OutputTokens: 8, CachedInputTokens: 32}, usage)
+	assert.Equal(t, Usage{}, GoogleUsage(nil))
+}
@@ -0,0 +1,14 @@
+//go:build !llmtracer_no_mistral
+
+package llmtracer
+
+import (
+	"testing"
+
+	mistral "github.com/gage-technologies/mistral-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMistralUsage(t *testing.T) {
+	assert.Equal(t, Usage{InputTokens: 7, OutputTokens: 9}, MistralUsage(mistral.UsageInfo{PromptTokens: 7, CompletionTokens: 9, TotalTokens: 16}))
+}
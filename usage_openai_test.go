@@ -0,0 +1,22 @@
+//go:build !llmtracer_no_openai
+
+package llmtracer
+
+import (
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAIUsage(t *testing.T) {
+	usage := OpenAIUsage(openai.Usage{
+		PromptTokens:            100,
+		CompletionTokens:        50,
+		PromptTokensDetails:     &openai.PromptTokensDetails{CachedTokens: 80},
+		CompletionTokensDetails: &openai.CompletionTokensDetails{ReasoningTokens: 30},
+	})
+	assert.Equal(t, Usage{InputTokens: 100, OutputTokens: 50, CachedInputTokens: 80, ReasoningTokens: 30}, usage)
+	assert.Equal(t, int64(150), usage.TotalTokens())
+	assert.Equal(t, Usage{InputTokens: 3, OutputTokens: 4}, OpenAIUsage(openai.Usage{PromptTokens: 3, CompletionTokens: 4}))
+}
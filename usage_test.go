@@ -0,0 +1,102 @@
+package llmtracer
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageRoundTrip(t *testing.T) {
+	usage := Usage{InputTokens: 10, OutputTokens: 5, CachedInputTokens: 4, ReasoningTokens: 2, Images: 1, AudioSeconds: 1.5}
+	request := &Request{}
+	request.SetUsage(usage)
+	assert.Equal(t, usage, request.Usage())
+	assert.Equal(t, Usage{InputTokens: 20, OutputTokens: 10, CachedInputTokens: 8, ReasoningTokens: 4, Images: 2, AudioSeconds: 3}, usage.Add(usage))
+}
+
+func TestAddTokens(t *testing.T) {
+	assert.Equal(t, int64(0), AddTokens())
+	assert.Equal(t, int64(6_000_000_000), AddTokens(math.MaxInt32, math.MaxInt32, 6_000_000_000-2*math.MaxInt32))
+	assert.Equal(t, int64(math.MaxInt64), AddTokens(math.MaxInt64-1, 2), "sums saturate instead of overflowing")
+	assert.Equal(t, int64(math.MaxInt64), AddTokens(math.MaxInt64, 1, -1), "saturated sums stay saturated")
+	assert.Equal(t, int64(math.MinInt64), AddTokens(math.MinInt64+1, -2))
+
+	large := Usage{InputTokens: math.MaxInt64 - 10, OutputTokens: 20}
+	assert.Equal(t, int64(math.MaxInt64), large.TotalTokens())
+	assert.Equal(t, int64(math.MaxInt64), large.Add(large).InputTokens)
+}
+
+func TestTrackRequestUsage(t *testing.T) {
+	storage := &MockStorageAdapter{}
+	client := NewClient(storage)
+
+	usage := Usage{InputTokens: 120, OutputTokens: 40, CachedInputTokens: 100, AudioSeconds: 12}
+	require.NoError(t, client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 0, 0, time.Second, nil, &RequestOptions{Usage: &usage}))
+
+	require.Len(t, storage.SaveCalls, 1)
+	saved := storage.SaveCalls[0].Request
+	assert.Equal(t, usage, saved.Usage())
+	assert.Equal(t, int64(160), saved.TotalTokens)
+}
+
+func TestTransportUsageDetails(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		expected Usage
+	}{
+		{
+			name:     "OpenAI chat completion",
+			path:     "/v1/chat/completions",
+			body:     `{"usage":{"prompt_tokens":100,"completion_tokens":50,"prompt_tokens_details":{"cached_tokens":64},"completion_tokens_details":{"reasoning_tokens":20}}}`,
+			expected: Usage{InputTokens: 100, OutputTokens: 50, CachedInputTokens: 64, ReasoningTokens: 20},
+		},
+		{
+			name:     "OpenAI responses",
+			path:     "/v1/responses",
+			body:     `{"usage":{"input_tokens":30,"output_tokens":15,"input_tokens_details":{"cached_tokens":10},"output_tokens_details":{"reasoning_tokens":5}}}`,
+			expected: Usage{InputTokens: 30, OutputTokens: 15, CachedInputTokens: 10, ReasoningTokens: 5},
+		},
+		{
+			name:     "Anthropic messages",
+			path:     "/v1/messages",
+			body:     `{"usage":{"input_tokens":10,"output_tokens":20,"cache_read_input_tokens":200,"cache_creation_input_tokens":50}}`,
+			expected: Usage{InputTokens: 260, OutputTokens: 20, CachedInputTokens: 200},
+		},
+		{
+			name:     "Gemini",
+			path:     "/v1beta/models/gemini-2.5-flash:generateContent",
+			body:     `{"usageMetadata":{"promptTokenCount":40,"candidatesTokenCount":8,"cachedContentTokenCount":32,"thoughtsTokenCount":12}}`,
+			expected: Usage{InputTokens: 40, OutputTokens: 20, CachedInputTokens: 32, ReasoningTokens: 12},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			storage := &MockStorageAdapter{}
+			transport := NewTracingTransport(NewClient(storage), nil)
+			resp, err := transport.HTTPClient().Post(server.URL+tt.path, "application/json", strings.NewReader(`{"model":"m"}`))
+			require.NoError(t, err)
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			require.Len(t, storage.SaveCalls, 1)
+			assert.Equal(t, tt.expected, storage.SaveCalls[0].Request.Usage())
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package llmtracer
+
+import (
+	"context"
+	"sync"
+)
+
+// watchBufferSize is the number of records buffered per subscription. Records published
+// while a subscriber's buffer is full are dropped for that subscriber.
+const watchBufferSize = 256
+
+// WatchableStorage is implemented by storage adapters that provide a change feed of saved
+// requests, e.g. via database notifications. Watch uses it when available so subscribers
+// also see requests tracked by other processes.
+type WatchableStorage interface {
+	Watch(ctx context.Context, filter *RequestFilter) (<-chan *Request, error)
+}
+
+// Watch streams newly tracked requests matching filter (nil matches everything) until ctx
+// is done or the client is closed, at which point the channel is closed. Limit, Offset and
+// ordering fields of the filter are ignored. Without a storage change feed only requests
+// tracked by this client are delivered, and a subscriber that falls behind misses records
+// rather than slowing down tracking. Received requests must be treated as read-only.
+func (c *Client) Watch(ctx context.Context, filter *RequestFilter) (<-chan *Request, error) {
+	if c.accessScoping {
+		filter = GetAccessScopeFromContext(ctx).Restrict(filter)
+	}
+	if watchable, ok := c.storage.(WatchableStorage); ok && c.storage.Capabilities().Watch {
+		return watchable.Watch(ctx, filter)
+	}
+	return c.watchers.subscribe(ctx, filter)
+}
+
+// observerList holds internal components, such as rate limiters, that are notified
+// synchronously of every saved request. Observers must be fast and must not block.
+type observerList struct {
+	mu        sync.RWMutex
+	observers []func(*Request)
+}
+
+func (l *observerList) add(fn func(*Request)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.observers = append(l.observers, fn)
+}
+
+func (l *observerList) notify(request *Request) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, fn := range l.observers {
+		fn(request)
+	}
+}
+
+// watchHub fans tracked requests out to in-process subscribers
+type watchHub struct {
+	mu          sync.Mutex
+	subscribers map[*watchSubscriber]struct{}
+	closed      bool
+}
+
+type watchSubscriber struct {
+	filter *RequestFilter
+	ch     chan *Request
+}
+
+func (h *watchHub) subscribe(ctx context.Context, filter *RequestFilter) (<-chan *Request, error) {
+	sub := &watchSubscriber{
+		filter: filter,
+		ch:     make(chan *Request, watchBufferSize),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	if h.subscribers == nil {
+		h.subscribers = make(map[*watchSubscriber]struct{})
+	}
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.unsubscribe(sub)
+	}()
+
+	return sub.ch, nil
+}
+
+func (h *watchHub) unsubscribe(sub *watchSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// publish delivers a saved request to every matching subscriber without blocking
+func (h *watchHub) publish(request *Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		if !sub.filter.Matches(request) {
+			continue
+		}
+		select {
+		case sub.ch <- request:
+		default:
+		}
+	}
+}
+
+// closeAll ends every subscription and rejects new ones
+func (h *watchHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+}
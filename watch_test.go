@@ -0,0 +1,153 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, ch <-chan *Request) *Request {
+	t.Helper()
+	select {
+	case request, ok := <-ch:
+		require.True(t, ok, "watch channel closed")
+		return request
+	case <-time.After(time.Second):
+		t.Fatal("no request received")
+		return nil
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Run("streams matching requests", func(t *testing.T) {
+		client := NewClient(&MockStorageAdapter{})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ch, err := client.Watch(ctx, &RequestFilter{Provider: ProviderAnthropic})
+		require.NoError(t, err)
+
+		track := func(provider Provider, model string) {
+			err := client.TrackRequest(context.Background(), provider, model, 1, 1, time.Millisecond, nil, nil)
+			require.NoError(t, err)
+		}
+		track(ProviderOpenAI, "gpt-4o")
+		track(ProviderAnthropic, "claude-3-haiku")
+
+		request := receive(t, ch)
+		assert.Equal(t, "claude-3-haiku", request.Model)
+		assert.Empty(t, ch)
+	})
+
+	t.Run("failed saves are not published", func(t *testing.T) {
+		client := NewClient(&MockStorageAdapter{
+			SaveFunc: func(ctx context.Context, request *Request) error {
+				return errors.New("database down")
+			},
+		})
+		ch, err := client.Watch(context.Background(), nil)
+		require.NoError(t, err)
+
+		_ = client.TrackRequest(context.Background(), ProviderOpenAI, "gpt-4o", 1, 1, time.Millisecond, nil, nil)
+		assert.Empty(t, ch)
+	})
+
+	t.Run("channel closes with context", func(t *testing.T) {
+		client := NewClient(&MockStorageAdapter{})
+		ctx, cancel := context.WithCancel(context.Background())
+
+		ch, err := client.Watch(ctx, nil)
+		require.NoError(t, err)
+		cancel()
+
+		select {
+		case _, ok := <-ch:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("watch channel not closed")
+		}
+	})
+
+	t.Run("channel closes with client", func(t *testing.T) {
+		client := NewClient(&MockStorageAdapter{})
+		ch, err := client.Watch(context.Background(), nil)
+		require.NoError(t, err)
+
+		require.NoError(t, client.Close())
+		_, ok := <-ch
+		assert.False(t, ok)
+
+		_, err = client.Watch(context.Background(), nil)
+		assert.ErrorIs(t, err, ErrClientClosed)
+	})
+}
+
+func TestRequestFilterMatches(t *testing.T) {
+	now := time.Now()
+	request := &Request{
+		TraceID:      "trace-1",
+		Provider:     ProviderOpenAI,
+		Model:        "gpt-4o",
+		InputTokens:  100,
+		OutputTokens: 50,
+		Error:        "rate limit exceeded",
+		ErrorType:    ErrorTypeRateLimit,
+		StatusCode:   429,
+		Latency:      2 * time.Second,
+		RequestedAt:  now,
+		Dimensions:   []DimensionTag{{Key: "feature", Value: "search"}},
+	}
+
+	hasError := true
+	noError := false
+	minTokens := int64(150)
+	maxTokens := int64(149)
+	later := now.Add(time.Minute)
+	slow := 2 * time.Second
+	slower := 2*time.Second + time.Millisecond
+	fast := time.Second
+
+	tests := []struct {
+		name   string
+		filter *RequestFilter
+		want   bool
+	}{
+		{"nil filter", nil, true},
+		{"empty filter", &RequestFilter{}, true},
+		{"provider and model", &RequestFilter{Provider: ProviderOpenAI, Model: "gpt-4o"}, true},
+		{"other model", &RequestFilter{Model: "gpt-4o-mini"}, false},
+		{"trace ID", &RequestFilter{TraceID: "trace-2"}, false},
+		{"error type", &RequestFilter{ErrorType: ErrorTypeRateLimit}, true},
+		{"has error", &RequestFilter{HasError: &hasError}, true},
+		{"no error", &RequestFilter{HasError: &noError}, false},
+		{"status codes", &RequestFilter{StatusCodes: []int{429, 503}}, true},
+		{"other status codes", &RequestFilter{StatusCodes: []int{500, 503}}, false},
+		{"min tokens", &RequestFilter{MinTokens: &minTokens}, true},
+		{"max tokens", &RequestFilter{MaxTokens: &maxTokens}, false},
+		{"min latency", &RequestFilter{MinLatency: &slow, MaxLatency: &slow}, true},
+		{"above min latency", &RequestFilter{MinLatency: &slower}, false},
+		{"max latency", &RequestFilter{MaxLatency: &fast}, false},
+		{"start time", &RequestFilter{StartTime: &later}, false},
+		{"dimension", &RequestFilter{Dimensions: []DimensionTag{{Key: "feature", Value: "search"}}}, true},
+		{"missing dimension", &RequestFilter{Dimensions: []DimensionTag{{Key: "user_id", Value: "u1"}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(request))
+		})
+	}
+
+	t.Run("soft deleted", func(t *testing.T) {
+		deleted := *request
+		deleted.DeletedAt = &now
+		var nilFilter *RequestFilter
+		assert.False(t, nilFilter.Matches(&deleted))
+		assert.False(t, (&RequestFilter{}).Matches(&deleted))
+		assert.True(t, (&RequestFilter{IncludeDeleted: true}).Matches(&deleted))
+	})
+}
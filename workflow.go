@@ -0,0 +1,239 @@
+package llmtracer
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Dimensions recorded for the steps of a workflow run, next to the workflow dimension
+// set by WithWorkflow
+const (
+	DimensionWorkflowRun      = "workflow_run"
+	DimensionWorkflowStep     = "workflow_step"
+	DimensionWorkflowStepName = "workflow_step_name"
+)
+
+// Workflow is one run of a named multi-step pipeline, e.g. retrieve → summarize → answer.
+// Requests tracked with the contexts returned by Step are tagged with the workflow name,
+// the run ID and the step's position, and share the run's trace ID. A Workflow is safe
+// for concurrent use.
+type Workflow struct {
+	Name  string
+	RunID string
+	steps atomic.Int64
+	ctx   context.Context
+}
+
+// BeginWorkflow starts a run of the named workflow. The returned context tags requests
+// with the workflow name and run; use Step to trace the individual steps. Unless ctx
+// already carries a trace ID, the run ID becomes the trace ID of every step.
+func BeginWorkflow(ctx context.Context, name string) (context.Context, *Workflow) {
+	workflow := &Workflow{Name: name, RunID: uuid.New().String()}
+	if traceID, ok := ctx.Value(traceIDKey).(string); !ok || traceID == "" {
+		ctx = WithTraceID(ctx, workflow.RunID)
+	}
+	ctx = WithWorkflow(ctx, name)
+	ctx = mergeDimensions(ctx, map[string]interface{}{DimensionWorkflowRun: workflow.RunID})
+	workflow.ctx = ctx
+	return ctx, workflow
+}
+
+// Step returns a context for the next step of the run. Steps are numbered from 1 in the
+// order Step is called; every request tracked with the returned context, including
+// retries, belongs to that step.
+func (w *Workflow) Step(ctx context.Context, name string) context.Context {
+	index := w.steps.Add(1)
+	if ctx == nil {
+		ctx = w.ctx
+	}
+	if traceID, ok := ctx.Value(traceIDKey).(string); !ok || traceID == "" {
+		ctx = WithTraceID(ctx, GetTraceIDFromContext(w.ctx))
+	}
+	ctx = WithWorkflow(ctx, w.Name)
+	return mergeDimensions(ctx, map[string]interface{}{
+		DimensionWorkflowRun:      w.RunID,
+		DimensionWorkflowStep:     strconv.FormatInt(index, 10),
+		DimensionWorkflowStepName: name,
+	})
+}
+
+// Steps returns the number of steps started so far
+func (w *Workflow) Steps() int {
+	return int(w.steps.Load())
+}
+
+// mergeDimensions returns ctx with dimensions added to the custom dimensions it carries
+func mergeDimensions(ctx context.Context, dimensions map[string]interface{}) context.Context {
+	merged := make(map[string]interface{})
+	if existing, ok := ctx.Value(dimensionsKey).(map[string]interface{}); ok {
+		for k, v := range existing {
+			merged[k] = v
+		}
+	}
+	for k, v := range dimensions {
+		merged[k] = v
+	}
+	return WithDimensions(ctx, merged)
+}
+
+// WorkflowStepSummary summarizes one step across the runs of a workflow. Steps are told
+// apart by position and name, and averages are per run that reached the step.
+type WorkflowStepSummary struct {
+	Index      int           `json:"index"`
+	Name       string        `json:"name"`
+	Runs       int64         `json:"runs"`
+	Requests   int64         `json:"requests"`
+	ErrorCount int64         `json:"error_count"`
+	TotalCost  float64       `json:"total_cost"`
+	AvgCost    float64       `json:"avg_cost"`
+	AvgLatency time.Duration `json:"avg_latency"`
+}
+
+// WorkflowSummary rolls the steps of every run of a workflow type into end-to-end figures.
+// A run's latency spans from its first request to its last response, so steps that ran
+// concurrently are not double counted. A run failed when any of its requests failed.
+type WorkflowSummary struct {
+	Workflow   string                 `json:"workflow"`
+	Runs       int64                  `json:"runs"`
+	FailedRuns int64                  `json:"failed_runs"`
+	TotalCost  float64                `json:"total_cost"`
+	AvgCost    float64                `json:"avg_cost"`
+	AvgSteps   float64                `json:"avg_steps"`
+	AvgLatency time.Duration          `json:"avg_latency"`
+	LatencyP50 time.Duration          `json:"latency_p50"`
+	LatencyP95 time.Duration          `json:"latency_p95"`
+	Steps      []*WorkflowStepSummary `json:"steps"`
+}
+
+// workflowRun collects the requests of one run
+type workflowRun struct {
+	start, end time.Time
+	cost       float64
+	failed     bool
+	steps      map[int]*workflowRunStep
+}
+
+type workflowRunStep struct {
+	name     string
+	requests int64
+	errors   int64
+	cost     float64
+	latency  time.Duration
+}
+
+// GetWorkflowSummary summarizes the runs started with BeginWorkflow among the requests
+// matching filter (nil matches everything), keyed by workflow name. Requests tracked
+// outside a workflow run are ignored.
+func (c *Client) GetWorkflowSummary(ctx context.Context, filter *RequestFilter) (map[string]*WorkflowSummary, error) {
+	if filter == nil {
+		filter = &RequestFilter{}
+	}
+	requests, err := c.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make(map[string]map[string]*workflowRun)
+	for _, req := range requests {
+		dims := req.DimensionsMap()
+		name, runID := dims[DimensionWorkflow], dims[DimensionWorkflowRun]
+		if name == "" || runID == "" {
+			continue
+		}
+		if runs[name] == nil {
+			runs[name] = make(map[string]*workflowRun)
+		}
+		run, ok := runs[name][runID]
+		if !ok {
+			run = &workflowRun{start: req.RequestedAt, end: req.RespondedAt, steps: make(map[int]*workflowRunStep)}
+			runs[name][runID] = run
+		}
+		if req.RequestedAt.Before(run.start) {
+			run.start = req.RequestedAt
+		}
+		if req.RespondedAt.After(run.end) {
+			run.end = req.RespondedAt
+		}
+		run.cost += req.Cost
+		run.failed = run.failed || req.Error != ""
+
+		index, err := strconv.Atoi(dims[DimensionWorkflowStep])
+		if err != nil {
+			continue
+		}
+		step, ok := run.steps[index]
+		if !ok {
+			step = &workflowRunStep{name: dims[DimensionWorkflowStepName]}
+			run.steps[index] = step
+		}
+		step.requests++
+		step.cost += req.Cost
+		step.latency += req.Latency
+		if req.Error != "" {
+			step.errors++
+		}
+	}
+
+	summaries := make(map[string]*WorkflowSummary, len(runs))
+	for name, workflowRuns := range runs {
+		summary := &WorkflowSummary{Workflow: name, Steps: []*WorkflowStepSummary{}}
+		steps := make(map[string]*WorkflowStepSummary)
+		var totalLatency time.Duration
+		var totalSteps int
+		latencies := make([]time.Duration, 0, len(workflowRuns))
+		for _, run := range workflowRuns {
+			summary.Runs++
+			summary.TotalCost += run.cost
+			if run.failed {
+				summary.FailedRuns++
+			}
+			latency := run.end.Sub(run.start)
+			totalLatency += latency
+			latencies = append(latencies, latency)
+			totalSteps += len(run.steps)
+
+			for index, runStep := range run.steps {
+				// Runs that branch can reach different steps at the same position
+				key := strconv.Itoa(index) + "\x00" + runStep.name
+				step, ok := steps[key]
+				if !ok {
+					step = &WorkflowStepSummary{Index: index, Name: runStep.name}
+					steps[key] = step
+					summary.Steps = append(summary.Steps, step)
+				}
+				step.Runs++
+				step.Requests += runStep.requests
+				step.ErrorCount += runStep.errors
+				step.TotalCost += runStep.cost
+				step.AvgLatency += runStep.latency
+			}
+		}
+
+		n := float64(summary.Runs)
+		summary.AvgCost = summary.TotalCost / n
+		summary.AvgSteps = float64(totalSteps) / n
+		summary.AvgLatency = totalLatency / time.Duration(summary.Runs)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		summary.LatencyP50 = percentile(latencies, 50)
+		summary.LatencyP95 = percentile(latencies, 95)
+
+		for _, step := range summary.Steps {
+			step.AvgCost = step.TotalCost / float64(step.Runs)
+			step.AvgLatency /= time.Duration(step.Runs)
+		}
+		sort.Slice(summary.Steps, func(i, j int) bool {
+			a, b := summary.Steps[i], summary.Steps[j]
+			if a.Index != b.Index {
+				return a.Index < b.Index
+			}
+			return a.Name < b.Name
+		})
+		summaries[name] = summary
+	}
+	return summaries, nil
+}
@@ -0,0 +1,69 @@
+package llmtracer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflows(t *testing.T) {
+	mock, _ := newRecordingStorage()
+	client := NewClient(mock)
+	ctx := WithUserID(context.Background(), "u-1")
+	cost := func(c float64) *RequestOptions { return &RequestOptions{Cost: &c} }
+
+	runCtx, run := BeginWorkflow(ctx, "support-answer")
+	assert.Equal(t, run.RunID, GetTraceIDFromContext(runCtx), "the run ID becomes the trace ID")
+
+	retrieve := run.Step(runCtx, "retrieve")
+	dims := GetDimensionsFromContext(retrieve)
+	assert.Equal(t, "support-answer", dims["workflow"])
+	assert.Equal(t, run.RunID, dims[DimensionWorkflowRun])
+	assert.Equal(t, "1", dims[DimensionWorkflowStep])
+	assert.Equal(t, "retrieve", dims[DimensionWorkflowStepName])
+	assert.Equal(t, "u-1", dims["user_id"])
+
+	require.NoError(t, client.TrackRequest(retrieve, ProviderOpenAI, "text-embedding-3-small", 100, 0, time.Second, nil, cost(0.001)))
+	answer := run.Step(runCtx, "answer")
+	assert.Equal(t, "2", GetDimensionsFromContext(answer)[DimensionWorkflowStep])
+	// A retry belongs to the same step
+	require.NoError(t, client.TrackRequest(answer, ProviderOpenAI, "gpt-4o", 500, 0, time.Second, errors.New("server error"), cost(0)))
+	require.NoError(t, client.TrackRequest(answer, ProviderOpenAI, "gpt-4o", 500, 200, 2*time.Second, nil, cost(0.02)))
+	assert.Equal(t, 2, run.Steps())
+
+	// A second run that skips retrieval
+	runCtx2, run2 := BeginWorkflow(WithTraceID(ctx, "existing-trace"), "support-answer")
+	assert.Equal(t, "existing-trace", GetTraceIDFromContext(runCtx2))
+	require.NoError(t, client.TrackRequest(run2.Step(runCtx2, "answer"), ProviderOpenAI, "gpt-4o", 500, 100, time.Second, nil, cost(0.01)))
+
+	// Outside any workflow
+	require.NoError(t, client.TrackRequest(ctx, ProviderOpenAI, "gpt-4o", 1, 1, time.Second, nil, cost(1)))
+
+	summaries, err := client.GetWorkflowSummary(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	summary := summaries["support-answer"]
+	require.NotNil(t, summary)
+	assert.Equal(t, int64(2), summary.Runs)
+	assert.Equal(t, int64(1), summary.FailedRuns)
+	assert.InDelta(t, 0.031, summary.TotalCost, 1e-9)
+	assert.InDelta(t, 0.0155, summary.AvgCost, 1e-9)
+	assert.Equal(t, 1.5, summary.AvgSteps)
+	assert.Positive(t, summary.AvgLatency)
+
+	require.Len(t, summary.Steps, 3)
+	assert.Equal(t, 1, summary.Steps[0].Index)
+	assert.Equal(t, "answer", summary.Steps[0].Name, "the second run answered in its first step")
+	assert.Equal(t, "retrieve", summary.Steps[1].Name)
+	step := summary.Steps[2]
+	assert.Equal(t, "answer", step.Name)
+	assert.Equal(t, 2, step.Index)
+	assert.Equal(t, int64(1), step.Runs)
+	assert.Equal(t, int64(2), step.Requests)
+	assert.Equal(t, int64(1), step.ErrorCount)
+	assert.Equal(t, 3*time.Second, step.AvgLatency)
+}